@@ -0,0 +1,125 @@
+package memcache
+
+import (
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AdminHandler is an http.Handler reporting a client's ring layout, server health,
+// connection pool, hot keys and slow operation log. It serves an HTML page, or JSON
+// when requested with ?format=json or "Accept: application/json". It can be
+// mounted under any prefix:
+//
+//	http.Handle("/debug/memcache/", memcache.NewAdminHandler(client))
+type AdminHandler struct {
+	c *Client
+
+	// Chaos, when set, is shown by the handler and its settings can be changed
+	// with a POST of ChaosConfig JSON to ?chaos
+	Chaos *Chaos
+}
+
+// NewAdminHandler returns an AdminHandler for c
+func NewAdminHandler(c *Client) *AdminHandler {
+	return &AdminHandler{c: c}
+}
+
+type adminReport struct {
+	Ring    []string      `json:"ring"`
+	Health  []ServerStats `json:"health"`
+	Pool    []PoolStats   `json:"pool"`
+	HotKeys []HotKey      `json:"hot_keys"`
+	SlowOps []SlowOp      `json:"slow_ops"`
+	Chaos   *ChaosConfig  `json:"chaos,omitempty"`
+}
+
+func (h *AdminHandler) report() adminReport {
+	var ring []string
+	h.c.selector.Each(func(addr net.Addr) error {
+		ring = append(ring, addr.String())
+		return nil
+	})
+	report := adminReport{
+		Ring:    ring,
+		Health:  h.c.ServerStats(),
+		Pool:    h.c.PoolStats(),
+		HotKeys: h.c.HotKeys(),
+		SlowOps: h.c.SlowOps(),
+	}
+	if h.Chaos != nil {
+		cfg := h.Chaos.Config()
+		report.Chaos = &cfg
+	}
+	return report
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.URL.Query()["chaos"]; ok && r.Method == http.MethodPost {
+		h.setChaos(w, r)
+		return
+	}
+	report := h.report()
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := adminTemplate.Execute(w, report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// setChaos replaces the Chaos settings with the ChaosConfig JSON in the request body
+func (h *AdminHandler) setChaos(w http.ResponseWriter, r *http.Request) {
+	if h.Chaos == nil {
+		http.Error(w, "chaos injection not configured", http.StatusNotFound)
+		return
+	}
+	var cfg ChaosConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.Chaos.SetConfig(cfg)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+var adminTemplate = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html>
+<head><title>memcache</title>
+<style>body{font-family:sans-serif} table{border-collapse:collapse;margin-bottom:2em} td,th{border:1px solid #ccc;padding:2px 8px;text-align:left}</style>
+</head>
+<body>
+<p><a href="?format=json">json</a></p>
+<h2>Ring</h2>
+<table><tr><th>server</th></tr>
+{{range .Ring}}<tr><td>{{.}}</td></tr>{{end}}
+</table>
+<h2>Health</h2>
+<table><tr><th>server</th><th>requests</th><th>errors</th><th>in flight</th><th>p50</th><th>p95</th><th>p99</th><th>backoff</th><th>breaker</th></tr>
+{{range .Health}}<tr><td>{{.Addr}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.InFlight}}</td><td>{{.P50}}</td><td>{{.P95}}</td><td>{{.P99}}</td><td>{{.Backoff}}</td><td>{{.Breaker}}</td></tr>{{end}}
+</table>
+<h2>Connections</h2>
+<table><tr><th>server</th><th>open</th><th>dials</th><th>dial errors</th></tr>
+{{range .Pool}}<tr><td>{{.Addr}}</td><td>{{.Open}}</td><td>{{.Dials}}</td><td>{{.DialErrors}}</td></tr>{{end}}
+</table>
+<h2>Hot keys</h2>
+<table><tr><th>key</th><th>reads</th></tr>
+{{range .HotKeys}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>{{end}}
+</table>
+{{with .Chaos}}<h2>Chaos</h2>
+<table><tr><th>enabled</th><th>latency</th><th>jitter</th><th>error rate</th><th>corrupt rate</th></tr>
+<tr><td>{{.Enabled}}</td><td>{{.Latency}}</td><td>{{.LatencyJitter}}</td><td>{{.ErrorRate}}</td><td>{{.CorruptRate}}</td></tr>
+</table>{{end}}
+<h2>Slow operations</h2>
+<table><tr><th>at</th><th>op</th><th>key</th><th>server</th><th>duration</th><th>error</th><th>trace</th></tr>
+{{range .SlowOps}}<tr><td>{{.At.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Op}}</td><td>{{.Key}}</td><td>{{.Addr}}</td><td>{{.Duration}}</td><td>{{.Err}}</td><td>{{.TraceID}}{{with .SpanID}}/{{.}}{{end}}</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,58 @@
+package memcache
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{
+		ServerStatsWindow: time.Minute,
+		HotKeys:           10,
+		SlowOpThreshold:   time.Nanosecond,
+	})
+	mc.Set(StringItem("admin_hot", "v"))
+	for i := 0; i < 3; i++ {
+		mc.GetString("admin_hot")
+	}
+	h := NewAdminHandler(mc)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/memcache/?format=json", nil))
+	var report adminReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Ring) != 1 || report.Ring[0] != "127.0.0.1:11211" {
+		t.Errorf("unexpected ring %v", report.Ring)
+	}
+	if len(report.HotKeys) == 0 || report.HotKeys[0].Key != "admin_hot" || report.HotKeys[0].Count != 3 {
+		t.Errorf("unexpected hot keys %v", report.HotKeys)
+	}
+	if len(report.SlowOps) != 4 || report.SlowOps[0].Op != OpGet || report.SlowOps[3].Op != OpSet {
+		t.Errorf("unexpected slow ops %v", report.SlowOps)
+	}
+	if len(report.Pool) != 1 || report.Pool[0].Open < 1 {
+		t.Errorf("unexpected pool %v", report.Pool)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/memcache/", nil))
+	if !strings.Contains(w.Body.String(), "admin_hot") {
+		t.Errorf("Expected html report to include hot key")
+	}
+}
+
+func TestHotKeyTracker(t *testing.T) {
+	tracker := newHotKeyTracker(2)
+	for _, k := range []string{"a", "a", "a", "b", "b", "c"} {
+		tracker.add(k)
+	}
+	top := tracker.top()
+	if len(top) != 2 || top[0] != (HotKey{"a", 3}) || top[1] != (HotKey{"c", 3}) {
+		t.Errorf("unexpected top keys %v", top)
+	}
+}
@@ -0,0 +1,207 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrAdminOnly is returned by Client.FlushAll; flushing requires an AdminClient
+var ErrAdminOnly = errors.New("memcache: admin operation; use an AdminClient")
+
+// ErrAdminDenied is returned for operations not in AdminOptions.Allow
+var ErrAdminDenied = errors.New("memcache: admin operation not allowed")
+
+// AdminOp names a dangerous operation run through an AdminClient
+type AdminOp string
+
+const (
+	AdminFlushAll         AdminOp = "flush_all"
+	AdminFlushServer      AdminOp = "flush_server"
+	AdminVerbosity        AdminOp = "verbosity"
+	AdminInvalidatePrefix AdminOp = "invalidate_prefix"
+)
+
+// AdminRecord is the audit record of one AdminClient operation, allowed or not
+type AdminRecord struct {
+	At     time.Time
+	Actor  string
+	Op     AdminOp
+	Target string // the server, prefix or "*" for every server
+	Detail string // e.g. the verbosity level or number of keys invalidated
+	Err    error
+}
+
+// AdminOptions configures an AdminClient
+type AdminOptions struct {
+	// Actor identifies who is running the operations (a user, job or service)
+	// in audit records. Required.
+	Actor string
+	// Allow lists the operations the AdminClient may run; others fail with
+	// ErrAdminDenied
+	Allow []AdminOp
+	// Audit receives a record of every operation attempted, including denied
+	// and failed ones
+	Audit func(AdminRecord)
+}
+
+// AdminClient runs operations that affect a whole server or cluster, such as
+// flush_all. It must be constructed explicitly with the operations allowed so
+// they aren't a one liner away in application code.
+type AdminClient struct {
+	c     *Client
+	opts  AdminOptions
+	allow map[AdminOp]bool
+}
+
+// NewAdminClient returns an AdminClient running operations on c's servers
+func NewAdminClient(c *Client, opts AdminOptions) (*AdminClient, error) {
+	if opts.Actor == "" {
+		return nil, errors.New("memcache: AdminOptions.Actor is required")
+	}
+	a := &AdminClient{c: c, opts: opts, allow: make(map[AdminOp]bool)}
+	for _, op := range opts.Allow {
+		a.allow[op] = true
+	}
+	return a, nil
+}
+
+// run checks op is allowed, runs fn and audits the outcome
+func (a *AdminClient) run(op AdminOp, target string, fn func() (detail string, err error)) error {
+	var detail string
+	err := ErrAdminDenied
+	if a.allow[op] {
+		detail, err = fn()
+	}
+	if a.opts.Audit != nil {
+		a.opts.Audit(AdminRecord{At: a.c.now(), Actor: a.opts.Actor, Op: op, Target: target, Detail: detail, Err: err})
+	}
+	return err
+}
+
+// FlushAll always fails with ErrAdminOnly; flush through an AdminClient
+// allowing AdminFlushAll instead
+func (c *Client) FlushAll() error {
+	return ErrAdminOnly
+}
+
+// FlushAll invalidates every item on every server
+func (a *AdminClient) FlushAll(ctx context.Context) error {
+	return a.FlushAllStaggered(ctx, 0, 0)
+}
+
+// FlushAllStaggered invalidates every item on every server, the first after
+// delay and each following one (in address order) stagger later, so a cluster
+// can be flushed without every key missing at once. Delays are rounded to
+// seconds and run on the servers, which are all sent flush_all immediately.
+// It's audited as AdminFlushAll.
+func (a *AdminClient) FlushAllStaggered(ctx context.Context, delay, stagger time.Duration) error {
+	return a.run(AdminFlushAll, "*", func() (string, error) {
+		addrs, err := a.c.servers()
+		if err != nil {
+			return "", err
+		}
+		sort.Slice(addrs, func(i, j int) bool { return addrs[i].String() < addrs[j].String() })
+		var errs []error
+		for i, addr := range addrs {
+			d := delay + time.Duration(i)*stagger
+			if d > 0 {
+				err = a.c.simpleCommand(ctx, addr, "flush_all %d", ttlSeconds(d))
+			} else {
+				err = a.c.simpleCommand(ctx, addr, "flush_all")
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		var detail string
+		if delay > 0 || stagger > 0 {
+			detail = fmt.Sprintf("delay %s stagger %s", delay, stagger)
+		}
+		return detail, errors.Join(errs...)
+	})
+}
+
+// FlushServer invalidates every item on addr after delay (rounded to seconds;
+// zero flushes immediately)
+func (a *AdminClient) FlushServer(ctx context.Context, addr net.Addr, delay time.Duration) error {
+	return a.run(AdminFlushServer, addr.String(), func() (string, error) {
+		if delay > 0 {
+			return delay.String(), a.c.simpleCommand(ctx, addr, "flush_all %d", ttlSeconds(delay))
+		}
+		return "", a.c.simpleCommand(ctx, addr, "flush_all")
+	})
+}
+
+// Verbosity sets the logging level of every server
+func (a *AdminClient) Verbosity(ctx context.Context, level int) error {
+	return a.run(AdminVerbosity, "*", func() (string, error) {
+		addrs, err := a.c.servers()
+		if err != nil {
+			return "", err
+		}
+		var errs []error
+		for _, addr := range addrs {
+			if err := a.c.simpleCommand(ctx, addr, "verbosity %d", level); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return fmt.Sprintf("level %d", level), errors.Join(errs...)
+	})
+}
+
+// InvalidatePrefix deletes every key starting with prefix (as stored, including
+// any Options.KeyPrefix), found with metadump, returning the number deleted. prefix may not be empty; use
+// FlushAll to invalidate everything.
+func (a *AdminClient) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	var n int
+	err := a.run(AdminInvalidatePrefix, prefix, func() (string, error) {
+		if prefix == "" {
+			return "", errors.New("memcache: empty prefix")
+		}
+		err := a.c.Metadump(ctx, func(_ net.Addr, e MetadumpEntry) error {
+			if !strings.HasPrefix(e.Key, prefix) {
+				return nil
+			}
+			// metadump keys are stored keys, already prefixed
+			if err := a.c.delete(e.Key); err != nil && err != memcache.ErrCacheMiss {
+				return err
+			}
+			n++
+			return nil
+		})
+		return fmt.Sprintf("%d keys", n), err
+	})
+	return n, err
+}
+
+// simpleCommand sends a command to addr expecting an OK response. Under
+// Options.DryRun it's recorded but not sent.
+func (c *Client) simpleCommand(ctx context.Context, addr net.Addr, format string, args ...interface{}) error {
+	if c.opts.DryRun {
+		if err := proxyCheck(c.opts.ProxyMode, format); err != nil {
+			return err
+		}
+		c.dryRunCommand(addr, fmt.Sprintf(format, args...))
+		return nil
+	}
+	return c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		if err := sc.command(format, args...); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "OK" {
+			return fmt.Errorf("memcache: %s on %s: %s", fmt.Sprintf(format, args...), addr, line)
+		}
+		return nil
+	})
+}
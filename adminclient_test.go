@@ -0,0 +1,190 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// flushAll empties mc's servers for a test
+func flushAll(t *testing.T, mc *Client) {
+	t.Helper()
+	a, err := NewAdminClient(mc, AdminOptions{Actor: t.Name(), Allow: []AdminOp{AdminFlushAll}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.FlushAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdminClient(t *testing.T) {
+	ctx := context.Background()
+	mc := NewClient([]string{LocalAddress})
+	if err := mc.FlushAll(); !errors.Is(err, ErrAdminOnly) {
+		t.Errorf("Expected ErrAdminOnly, got %v", err)
+	}
+	if _, err := NewAdminClient(mc, AdminOptions{}); err == nil {
+		t.Errorf("Expected an error without an actor")
+	}
+
+	var records []AdminRecord
+	a, err := NewAdminClient(mc, AdminOptions{
+		Actor: "ops@example.com",
+		Allow: []AdminOp{AdminInvalidatePrefix, AdminVerbosity},
+		Audit: func(r AdminRecord) { records = append(records, r) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.Set(StringItem("user:1", "a"))
+	mc.Set(StringItem("user:2", "b"))
+	mc.Set(StringItem("session:1", "c"))
+
+	if err := a.FlushAll(ctx); err != ErrAdminDenied {
+		t.Errorf("Expected ErrAdminDenied, got %v", err)
+	}
+	if _, err := mc.Get("user:1"); err != nil {
+		t.Errorf("Expected denied flush to leave items, got %v", err)
+	}
+	if err := a.Verbosity(ctx, 1); err != nil {
+		t.Errorf("Verbosity failed: %v", err)
+	}
+	n, err := a.InvalidatePrefix(ctx, "user:")
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 keys invalidated, got %d %v", n, err)
+	}
+	if _, err := mc.Get("user:2"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected user:2 invalidated, got %v", err)
+	}
+	if _, err := mc.Get("session:1"); err != nil {
+		t.Errorf("Expected session:1 kept, got %v", err)
+	}
+	if _, err := a.InvalidatePrefix(ctx, ""); err == nil {
+		t.Errorf("Expected an error for an empty prefix")
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 audit records, got %d", len(records))
+	}
+	if r := records[0]; r.Actor != "ops@example.com" || r.Op != AdminFlushAll || r.Err != ErrAdminDenied || r.At.IsZero() {
+		t.Errorf("Unexpected record for denied flush %+v", r)
+	}
+	if r := records[1]; r.Op != AdminVerbosity || r.Detail != "level 1" || r.Err != nil {
+		t.Errorf("Unexpected record for verbosity %+v", r)
+	}
+	if r := records[2]; r.Op != AdminInvalidatePrefix || r.Target != "user:" || r.Detail != "2 keys" {
+		t.Errorf("Unexpected record for invalidation %+v", r)
+	}
+}
+
+func TestAdminClient_FlushServer(t *testing.T) {
+	ctx := context.Background()
+	mc := NewClient([]string{LocalAddress})
+	var records []AdminRecord
+	a, _ := NewAdminClient(mc, AdminOptions{
+		Actor: "deploy",
+		Allow: []AdminOp{AdminFlushServer},
+		Audit: func(r AdminRecord) { records = append(records, r) },
+	})
+	mc.Set(StringItem("flush_server", "v"))
+	addr, _ := mc.selector.PickServer("flush_server")
+	if err := a.FlushServer(ctx, addr, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Get("flush_server"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss after flush, got %v", err)
+	}
+	if err := a.FlushServer(ctx, addr, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Target != addr.String() || records[1].Detail != "10s" {
+		t.Errorf("Unexpected records %+v", records)
+	}
+}
+
+// commandServer answers every command line with OK, sending it to lines
+func commandServer(t *testing.T, lines chan<- string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				r := bufio.NewReader(nc)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- strings.TrimSpace(line)
+					nc.Write([]byte("OK\r\n"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestAdminClient_FlushAllStaggered(t *testing.T) {
+	lines := make(chan string, 3)
+	addrs := []string{commandServer(t, lines), commandServer(t, lines), commandServer(t, lines)}
+	sort.Strings(addrs)
+	mc := NewClient(addrs)
+	var records []AdminRecord
+	a, _ := NewAdminClient(mc, AdminOptions{
+		Actor: "deploy",
+		Allow: []AdminOp{AdminFlushAll},
+		Audit: func(r AdminRecord) { records = append(records, r) },
+	})
+	if err := a.FlushAllStaggered(context.Background(), 0, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"flush_all", "flush_all 30", "flush_all 60"} {
+		if got := <-lines; got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+	if len(records) != 1 || records[0].Op != AdminFlushAll || records[0].Detail != "delay 0s stagger 30s" {
+		t.Errorf("Unexpected records %+v", records)
+	}
+}
+
+func TestAdminClient_InvalidatePrefixKeyPrefix(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	mc := NewClientWithOptions([]string{s.ln.Addr().String()}, Options{KeyPrefix: "app:"})
+	mc.SetString("user:1", "a")
+	mc.SetString("user:2", "b")
+	mc.SetString("other", "c")
+	a, _ := NewAdminClient(mc, AdminOptions{Actor: t.Name(), Allow: []AdminOp{AdminInvalidatePrefix}})
+	n, err := a.InvalidatePrefix(context.Background(), "app:user:")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 keys invalidated, got %d %v", n, err)
+	}
+	for _, k := range []string{"user:1", "user:2"} {
+		if _, err := mc.Get(k); err != memcache.ErrCacheMiss {
+			t.Errorf("Expected %s deleted, got %v", k, err)
+		}
+	}
+	if _, ok := mc.GetString("other"); !ok {
+		t.Error("Expected other kept")
+	}
+}
@@ -0,0 +1,139 @@
+package memcache
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// DefaultAdvisorSampleRate is the fraction of keys inspected when
+// AdvisorOptions.SampleRate is unset
+const DefaultAdvisorSampleRate = 0.1
+
+// AdvisorOptions configures a QuotaAdvisor
+type AdvisorOptions struct {
+	// Namespaces maps key prefixes to their allowed share (0-1) of the memory in
+	// use across the cluster. A key counts towards its longest matching prefix.
+	Namespaces map[string]float64
+	// SampleRate is the fraction of keys (chosen by key hash, so the same keys are
+	// sampled each run) used for the estimate. Defaults to DefaultAdvisorSampleRate.
+	SampleRate float64
+}
+
+// NamespaceUsage is the estimated memory footprint of one namespace
+type NamespaceUsage struct {
+	Prefix string `json:"prefix"`
+	// Keys and Bytes are estimates scaled up from the sampled keys. Bytes counts
+	// the slab chunk each item occupies.
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+	// Share is Bytes as a fraction of the memory in use across the cluster
+	Share float64 `json:"share"`
+	// Allowed is the configured share and Over whether Share exceeds it
+	Allowed float64 `json:"allowed"`
+	Over    bool    `json:"over"`
+}
+
+// QuotaAdvisor estimates how much of a shared cluster each namespace uses, from
+// `stats slabs` and a sampled metadump scan, and flags namespaces using more than
+// their configured share. Nothing is evicted; it is advice for platform owners.
+type QuotaAdvisor struct {
+	c        *Client
+	opts     AdvisorOptions
+	prefixes []string
+}
+
+// NewQuotaAdvisor returns a QuotaAdvisor for the servers of c
+func NewQuotaAdvisor(c *Client, opts AdvisorOptions) *QuotaAdvisor {
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = DefaultAdvisorSampleRate
+	}
+	prefixes := make([]string, 0, len(opts.Namespaces))
+	for p := range opts.Namespaces {
+		prefixes = append(prefixes, p)
+	}
+	// longest first so a key matches its most specific namespace
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return &QuotaAdvisor{c: c, opts: opts, prefixes: prefixes}
+}
+
+// Advise scans the cluster returning the usage of every configured namespace
+// sorted by prefix
+func (a *QuotaAdvisor) Advise(ctx context.Context) ([]NamespaceUsage, error) {
+	addrs, err := a.c.servers()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*NamespaceUsage, len(a.prefixes))
+	for _, p := range a.prefixes {
+		usage[p] = &NamespaceUsage{Prefix: p, Allowed: a.opts.Namespaces[p]}
+	}
+	var total int64
+	for _, addr := range addrs {
+		slabs, err := a.c.SlabStats(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range slabs {
+			total += s.ChunkSize * s.UsedChunks
+		}
+		err = a.c.MetadumpServer(ctx, addr, func(e MetadumpEntry) error {
+			if !a.sampled(e.Key) {
+				return nil
+			}
+			p, ok := a.namespace(e.Key)
+			if !ok {
+				return nil
+			}
+			size := int64(e.Size)
+			if s, ok := slabs[e.Class]; ok && s.ChunkSize > 0 {
+				size = s.ChunkSize
+			}
+			usage[p].Keys++
+			usage[p].Bytes += size
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]NamespaceUsage, 0, len(usage))
+	for _, u := range usage {
+		u.Keys = int64(float64(u.Keys) / a.opts.SampleRate)
+		u.Bytes = int64(float64(u.Bytes) / a.opts.SampleRate)
+		if total > 0 {
+			u.Share = float64(u.Bytes) / float64(total)
+		}
+		u.Over = u.Share > u.Allowed
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Prefix < result[j].Prefix })
+	return result, nil
+}
+
+// sampled reports whether key is part of the sample
+func (a *QuotaAdvisor) sampled(key string) bool {
+	if a.opts.SampleRate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32())/(1<<32) < a.opts.SampleRate
+}
+
+// namespace returns the longest configured prefix of key
+func (a *QuotaAdvisor) namespace(key string) (string, bool) {
+	for _, p := range a.prefixes {
+		if strings.HasPrefix(key, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
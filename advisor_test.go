@@ -0,0 +1,54 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestQuotaAdvisor(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	flushAll(t, mc)
+	for n := 0; n < 30; n++ {
+		mc.Set(&memcache.Item{Key: fmt.Sprintf("big:%d", n), Value: []byte(strings.Repeat("x", 1000))})
+	}
+	for n := 0; n < 10; n++ {
+		mc.Set(StringItem(fmt.Sprintf("big:small:%d", n), "x"))
+	}
+	mc.Set(StringItem("other", "x"))
+
+	advisor := NewQuotaAdvisor(mc, AdvisorOptions{
+		Namespaces: map[string]float64{"big:": 0.5, "big:small:": 0.5},
+		SampleRate: 1,
+	})
+	usage, err := advisor.Advise(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	big, small := usage[0], usage[1]
+	if big.Prefix != "big:" || big.Keys != 30 || !big.Over || big.Share < 0.8 {
+		t.Errorf("unexpected usage for big: %+v", big)
+	}
+	if small.Prefix != "big:small:" || small.Keys != 10 || small.Over || small.Bytes != 10*96 {
+		t.Errorf("unexpected usage for big:small: %+v", small)
+	}
+}
+
+func TestSlabStats(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(StringItem("slab_key", "x"))
+	addrs, _ := mc.servers()
+	slabs, err := mc.SlabStats(context.Background(), addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := slabs[1]; !ok || s.ChunkSize != 96 || s.UsedChunks < 1 {
+		t.Errorf("unexpected slab class 1: %+v", s)
+	}
+}
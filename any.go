@@ -0,0 +1,222 @@
+package memcache
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// Type identifies the kind of value returned by GetAny
+type Type int
+
+const (
+	// TypeNone is Python None, returned as nil
+	TypeNone Type = iota
+	// TypeString is a str, or a value stored without flags, returned as string
+	TypeString
+	// TypeBytes is Python 3 bytes, returned as []byte
+	TypeBytes
+	// TypeInt is an int, returned as int64 or *big.Int when it doesn't fit
+	TypeInt
+	// TypeBool is a bool
+	TypeBool
+	// TypeFloat is a float, returned as float64
+	TypeFloat
+	// TypeList is a list or tuple, returned as []interface{}
+	TypeList
+	// TypeDict is a dict, returned as map[string]interface{} when every key is
+	// a str and map[interface{}]interface{} otherwise
+	TypeDict
+	// TypeSet is a set or frozenset, returned as map[interface{}]struct{}
+	TypeSet
+	// TypeTime is a datetime.datetime, returned as time.Time
+	TypeTime
+	// TypeDuration is a datetime.timedelta, returned as time.Duration
+	TypeDuration
+	// TypeDecimal is a decimal.Decimal, returned as picklecompat.Decimal
+	TypeDecimal
+	// TypeOther is any other value: a registered Codec's result or a pickled
+	// object of another class, returned as decoded
+	TypeOther
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNone:
+		return "none"
+	case TypeString:
+		return "str"
+	case TypeBytes:
+		return "bytes"
+	case TypeInt:
+		return "int"
+	case TypeBool:
+		return "bool"
+	case TypeFloat:
+		return "float"
+	case TypeList:
+		return "list"
+	case TypeDict:
+		return "dict"
+	case TypeSet:
+		return "set"
+	case TypeTime:
+		return "datetime"
+	case TypeDuration:
+		return "timedelta"
+	case TypeDecimal:
+		return "decimal"
+	case TypeOther:
+		return "other"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// GetAny gets a value of any type from cache, decoded according to its flags,
+// returning the value, its Type and whether or not the get was successful.
+// Containers are converted to Go slices and maps, with their elements
+// converted the same way, so callers can switch on the Type rather than try
+// each typed getter in turn. Values of registered Codecs are decoded by the
+// codec.
+func (c *Client) GetAny(k string, opts ...CallOption) (interface{}, Type, bool) {
+	return getAny(c.getter(opts), k, func(i *Item) (interface{}, error) {
+		return c.opts.Pipeline.deserialize(i.Value, i.Flags)
+	})
+}
+
+func getAny(c itemGetter, k string, deserialize func(*Item) (interface{}, error)) (interface{}, Type, bool) {
+	v, err := getDecoded(c, k, func(i *Item) (interface{}, error) {
+		v, err := deserialize(i)
+		if err != nil {
+			return nil, err
+		}
+		return goValue(v), nil
+	})
+	if err != nil {
+		return nil, TypeNone, false
+	}
+	return v, typeOf(v), true
+}
+
+// Any returns the value decoded according to its flags and its Type, as GetAny
+// does, without consulting registered Codecs
+func (i *Item) Any() (interface{}, Type, error) {
+	v, err := deserializeItem(i)
+	if err != nil {
+		return nil, TypeNone, err
+	}
+	v = goValue(v)
+	return v, typeOf(v), nil
+}
+
+// deserializeItem is Deserialize for the getAny of Cacher implementations
+// without codecs
+func deserializeItem(i *Item) (interface{}, error) {
+	return Deserialize(i.Value, i.Flags)
+}
+
+// goValue converts a decoded value to the Go types GetAny returns
+func goValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64()
+		}
+	case *types.List:
+		return goList(*v)
+	case *types.Tuple:
+		return goList(*v)
+	case []interface{}:
+		return goList(v)
+	case *types.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, e := range *v {
+			k, ok := e.Key.(string)
+			if !ok {
+				return goMap(*v)
+			}
+			m[k] = goValue(e.Value)
+		}
+		return m
+	case map[string]interface{}:
+		// decoded JSON
+		for k, e := range v {
+			v[k] = goValue(e)
+		}
+	case *types.Set:
+		m := make(map[interface{}]struct{}, len(*v))
+		for e := range *v {
+			m[goKey(e)] = struct{}{}
+		}
+		return m
+	case *types.FrozenSet:
+		m := make(map[interface{}]struct{}, len(*v))
+		for e := range *v {
+			m[goKey(e)] = struct{}{}
+		}
+		return m
+	}
+	return v
+}
+
+func goList(l []interface{}) []interface{} {
+	out := make([]interface{}, len(l))
+	for n, e := range l {
+		out[n] = goValue(e)
+	}
+	return out
+}
+
+// goMap converts a dict with keys other than str
+func goMap(d types.Dict) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, len(d))
+	for _, e := range d {
+		m[goKey(e.Key)] = goValue(e.Value)
+	}
+	return m
+}
+
+// goKey converts a dict key or set element; only ints are converted as
+// tuples, unlike slices, are hashable
+func goKey(k interface{}) interface{} {
+	if n, ok := k.(int); ok {
+		return int64(n)
+	}
+	return k
+}
+
+// typeOf returns the Type of a value converted by goValue
+func typeOf(v interface{}) Type {
+	switch v.(type) {
+	case nil:
+		return TypeNone
+	case string:
+		return TypeString
+	case []byte:
+		return TypeBytes
+	case int64, *big.Int:
+		return TypeInt
+	case bool:
+		return TypeBool
+	case float64:
+		return TypeFloat
+	case []interface{}:
+		return TypeList
+	case map[string]interface{}, map[interface{}]interface{}:
+		return TypeDict
+	case map[interface{}]struct{}:
+		return TypeSet
+	case time.Time:
+		return TypeTime
+	case time.Duration:
+		return TypeDuration
+	case picklecompat.Decimal:
+		return TypeDecimal
+	}
+	return TypeOther
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+func TestGetAny(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	huge, _ := new(big.Int).SetString("1180591620717411303424", 10)
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	// pickle.dumps({'a': [1, (2, u'x')], 'b': set([3])}, 2) from python 2
+	nested := &memcache.Item{Flags: FLAG_PICKLE, Value: []byte("\x80\x02}q\x00(U\x01aq\x01]q\x02(K\x01K\x02X\x01\x00\x00\x00xq\x03\x86q\x04eU\x01bq\x05c__builtin__\nset\nq\x06]q\x07K\x03a\x85q\x08Rq\tu.")}
+	// pickle.dumps({(1, 2): 1.5}, 2) from python 2
+	tupleKeys := &memcache.Item{Flags: FLAG_PICKLE, Value: []byte("\x80\x02}q\x00K\x01K\x02\x86q\x01G?\xf8\x00\x00\x00\x00\x00\x00s.")}
+	jsonItem, _ := JSONItem("", map[string]int{"n": 1})
+	for _, tc := range []struct {
+		item     *memcache.Item
+		want     interface{}
+		wantType Type
+	}{
+		{StringItem("", "raw"), "raw", TypeString},
+		{UnicodeItem("", "ünicode"), "ünicode", TypeString},
+		{Int64Item("", 42), int64(42), TypeInt},
+		{BigIntItem("", huge), huge, TypeInt},
+		{BoolItem("", true), true, TypeBool},
+		{Float64Item("", 1.5), 1.5, TypeFloat},
+		{DatetimeItem("", when), when, TypeTime},
+		{DecimalItem("", "12.50"), picklecompat.Decimal("12.50"), TypeDecimal},
+		{nested, map[string]interface{}{"a": []interface{}{int64(1), []interface{}{int64(2), "x"}}, "b": map[interface{}]struct{}{int64(3): {}}}, TypeDict},
+		{jsonItem, map[string]interface{}{"n": 1.0}, TypeDict},
+	} {
+		tc.item.Key = "any"
+		if err := mc.Set(tc.item); err != nil {
+			t.Fatal(err)
+		}
+		v, typ, ok := mc.GetAny("any")
+		if !ok || typ != tc.wantType || !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("Expected %v %s got %#v %s %v", tc.want, tc.wantType, v, typ, ok)
+		}
+	}
+
+	mc.Set(NoneItem("any"))
+	if v, typ, ok := mc.GetAny("any"); !ok || typ != TypeNone || v != nil {
+		t.Errorf("Expected None got %v %s %v", v, typ, ok)
+	}
+	mc.Delete("any")
+	if _, _, ok := mc.GetAny("any"); ok {
+		t.Error("Expected a miss")
+	}
+
+	v, typ, err := (&Item{tupleKeys}).Any()
+	m, ok := v.(map[interface{}]interface{})
+	if err != nil || typ != TypeDict || !ok || len(m) != 1 {
+		t.Fatalf("unexpected Any %#v %s %v", v, typ, err)
+	}
+	for k, e := range m {
+		if k, ok := k.(interface{ Len() int }); !ok || k.Len() != 2 || e != 1.5 {
+			t.Errorf("unexpected entry %#v %#v", k, e)
+		}
+	}
+	if typ.String() != "dict" || Type(99).String() != "Type(99)" {
+		t.Errorf("unexpected names %s %s", typ, Type(99))
+	}
+}
@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrNotAppendable is returned by AppendString and PrependString for values
+// that aren't a plain str: appending to a pickle, an int or a compressed value
+// would corrupt it for Python readers
+var ErrNotAppendable = errors.New("memcache: value isn't a str")
+
+// appendRetries bounds how often AppendString retries when the key is added or
+// deleted by another writer between its steps
+const appendRetries = 3
+
+// AppendString appends s to the str value of k, creating k holding s (as
+// StringItem does) if it is missing. Values stored with flags, or looking
+// pickled, fail with ErrNotAppendable. The flags are checked with a get before
+// the append, so a writer replacing the value in between isn't detected.
+func (c *Client) AppendString(k, s string) error {
+	return c.appendString(OpAppend, k, s, c.Append)
+}
+
+// PrependString is AppendString prepending s
+func (c *Client) PrependString(k, s string) error {
+	return c.appendString(OpPrepend, k, s, c.Prepend)
+}
+
+func (c *Client) appendString(op, k, s string, store func(*memcache.Item) error) error {
+	for attempt := 0; ; attempt++ {
+		i, err := c.Get(k)
+		switch err {
+		case nil:
+			if i.Flags != FLAG_NONE || looksPickled(i.Value) {
+				return fmt.Errorf("%w: %s to %s with flags %d", ErrNotAppendable, op, k, i.Flags)
+			}
+			err = store(StringItem(k, s))
+		case memcache.ErrCacheMiss:
+			err = c.Add(StringItem(k, s))
+		}
+		// ErrNotStored is a race with a delete (for store) or an add
+		if err != memcache.ErrNotStored || attempt >= appendRetries {
+			return err
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAppendString(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("append_log")
+
+	if err := mc.AppendString("append_log", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.AppendString("append_log", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.PrependString("append_log", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("append_log"); !ok || s != "abc" {
+		t.Errorf("Expected abc got %q %v", s, ok)
+	}
+
+	mc.Set(UnicodeItem("append_unicode", "x"))
+	mc.Set(Int64Item("append_int", 1))
+	for _, k := range []string{"append_unicode", "append_int"} {
+		if err := mc.AppendString(k, "y"); !errors.Is(err, ErrNotAppendable) {
+			t.Errorf("%s: expected ErrNotAppendable got %v", k, err)
+		}
+	}
+	if n, ok := mc.GetInt64("append_int"); !ok || n != 1 {
+		t.Errorf("Expected the int untouched got %d %v", n, ok)
+	}
+}
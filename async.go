@@ -0,0 +1,51 @@
+package memcache
+
+import (
+	"context"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Future is the pending result of an asynchronous Get
+type Future struct {
+	done chan struct{}
+	item *memcache.Item
+	err  error
+}
+
+// GetAsync starts a Get for key in the background and returns immediately.
+// Lookups can be started early in a request and joined later with Wait:
+//
+//	user, prefs := mc.GetAsync("user:1"), mc.GetAsync("prefs:1")
+//	...
+//	u, err := user.Wait()
+func (c *Client) GetAsync(key string) *Future {
+	f := &Future{done: make(chan struct{})}
+	go func() {
+		f.item, f.err = c.Get(key)
+		close(f.done)
+	}()
+	return f
+}
+
+// Done is closed when the result is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the Get completes and returns its result. It may be called
+// any number of times.
+func (f *Future) Wait() (*memcache.Item, error) {
+	<-f.done
+	return f.item, f.err
+}
+
+// WaitCtx is Wait returning ctx.Err() if ctx is done before the Get completes
+func (f *Future) WaitCtx(ctx context.Context) (*memcache.Item, error) {
+	select {
+	case <-f.done:
+		return f.item, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
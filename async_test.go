@@ -0,0 +1,39 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestGetAsync(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(StringItem("async_a", "a"))
+	mc.Delete("async_missing")
+
+	a, missing := mc.GetAsync("async_a"), mc.GetAsync("async_missing")
+	if i, err := a.Wait(); err != nil || string(i.Value) != "a" {
+		t.Errorf("unexpected result %v %v", i, err)
+	}
+	if _, err := missing.Wait(); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got: %v", err)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Errorf("Expected Done to be closed after Wait")
+	}
+}
+
+func TestFutureWaitCtx(t *testing.T) {
+	mc := NewClient([]string{hungServer(t)})
+	mc.Timeout = time.Second
+	f := mc.GetAsync("async_hung")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.WaitCtx(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got: %v", err)
+	}
+}
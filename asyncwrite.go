@@ -0,0 +1,148 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultAsyncBatchSize is the number of queued keys that triggers a flush
+// when Options.AsyncBatchSize is unset
+const DefaultAsyncBatchSize = 100
+
+// DefaultAsyncFlushInterval is how long writes stay queued when
+// Options.AsyncFlushInterval is unset
+const DefaultAsyncFlushInterval = 10 * time.Millisecond
+
+// asyncWriter queues the Sets and Deletes of a Client under Options.AsyncWrites
+// and writes them from the background
+type asyncWriter struct {
+	c        *Client
+	size     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*memcache.Item // the item to set, or nil to delete
+	order   []string
+	timer   *time.Timer
+	closed  bool
+
+	// flushing serializes flushes so batches are written in order
+	flushing sync.Mutex
+}
+
+func newAsyncWriter(c *Client, size int, interval time.Duration) *asyncWriter {
+	return &asyncWriter{c: c, size: size, interval: interval}
+}
+
+// enqueue queues setting item, or deleting key for a nil item, replacing any
+// write of key already queued. It returns false once the writer is closed.
+func (w *asyncWriter) enqueue(key string, item *memcache.Item) bool {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return false
+	}
+	if w.pending == nil {
+		w.pending = make(map[string]*memcache.Item)
+		w.timer = time.AfterFunc(w.interval, w.flush)
+	}
+	if _, ok := w.pending[key]; !ok {
+		w.order = append(w.order, key)
+	}
+	if item != nil {
+		cp := *item
+		item = &cp
+	}
+	w.pending[key] = item
+	full := len(w.order) >= w.size
+	w.mu.Unlock()
+	if full {
+		go w.flush()
+	}
+	return true
+}
+
+// flush writes the queued writes, concurrently for each server and in the
+// order queued for each
+func (w *asyncWriter) flush() {
+	w.flushing.Lock()
+	defer w.flushing.Unlock()
+	w.mu.Lock()
+	pending, order := w.pending, w.order
+	w.pending, w.order = nil, nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mu.Unlock()
+
+	byServer := make(map[string][]string)
+	for _, key := range order {
+		server := ""
+		if addr, err := w.c.selector.PickServer(key); err == nil {
+			server = addr.String()
+		}
+		byServer[server] = append(byServer[server], key)
+	}
+	var wg sync.WaitGroup
+	for _, keys := range byServer {
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			for _, key := range keys {
+				w.write(key, pending[key])
+			}
+		}(keys)
+	}
+	wg.Wait()
+}
+
+// write sets item, or deletes key for a nil item, reporting failures to
+// Options.OnAsyncError
+func (w *asyncWriter) write(key string, item *memcache.Item) {
+	op, err := OpSet, error(nil)
+	if item != nil {
+		err = w.c.write(OpSet, item, w.c.backendSet)
+	} else {
+		op, err = OpDelete, w.c.delete(key)
+		if err == memcache.ErrCacheMiss {
+			err = nil
+		}
+	}
+	if err != nil && w.c.opts.OnAsyncError != nil {
+		w.c.opts.OnAsyncError(op, key, err)
+	}
+}
+
+// close flushes the queued writes and stops queueing
+func (w *asyncWriter) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.flush()
+}
+
+// Flush writes the Sets and Deletes queued by Options.AsyncWrites, returning
+// once they are written. Failures are reported to Options.OnAsyncError.
+func (c *Client) Flush() {
+	if c.async != nil {
+		c.async.flush()
+	}
+}
+
+// Close flushes the writes queued by Options.AsyncWrites; later Sets and
+// Deletes are written synchronously. It also stops the re-resolution of
+// Options.ResolveInterval and the polling of NewElastiCacheClient.
+func (c *Client) Close() {
+	if c.async != nil {
+		c.async.close()
+	}
+	if c.resolver != nil {
+		c.resolver.close()
+	}
+	if c.discovery != nil {
+		c.discovery.close()
+	}
+}
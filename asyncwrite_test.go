@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestAsyncWrites(t *testing.T) {
+	var mu sync.Mutex
+	failed := make(map[string]error)
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		AsyncWrites:        true,
+		AsyncFlushInterval: time.Hour,
+		OnAsyncError: func(op, key string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed[op+" "+key] = err
+		},
+	})
+	direct := NewClient([]string{LocalAddress})
+	direct.Set(StringItem("async_b", "b"))
+
+	if err := mc.Set(StringItem("async_a", "a")); err != nil {
+		t.Fatal(err)
+	}
+	mc.Delete("async_b")
+	mc.Delete("async_missing")
+	mc.Set(StringItem("bad key", "x"))
+	if _, err := direct.Get("async_a"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the set queued got %v", err)
+	}
+
+	mc.Flush()
+	if s, ok := direct.GetString("async_a"); !ok || s != "a" {
+		t.Errorf("Expected a after Flush got %q %v", s, ok)
+	}
+	if _, err := direct.Get("async_b"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected async_b deleted after Flush got %v", err)
+	}
+	mu.Lock()
+	if len(failed) != 1 || failed["set bad key"] != memcache.ErrMalformedKey {
+		t.Errorf("Expected only the malformed key reported got %v", failed)
+	}
+	mu.Unlock()
+
+	// the last queued write of a key wins
+	mc.Set(StringItem("async_a", "1"))
+	mc.Delete("async_a")
+	mc.Set(StringItem("async_a", "2"))
+	mc.Close()
+	if s, ok := direct.GetString("async_a"); !ok || s != "2" {
+		t.Errorf("Expected 2 after Close got %q %v", s, ok)
+	}
+	// writes after Close are synchronous
+	mc.Set(StringItem("async_a", "3"))
+	if s, ok := direct.GetString("async_a"); !ok || s != "3" {
+		t.Errorf("Expected 3 got %q %v", s, ok)
+	}
+}
+
+func TestAsyncBatchSize(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{AsyncWrites: true, AsyncBatchSize: 10, AsyncFlushInterval: time.Hour})
+	for n := 0; n < 10; n++ {
+		mc.Set(Int64Item(fmt.Sprintf("async_batch_%d", n), int64(n)))
+	}
+	eventually(t, func() error {
+		if n, ok := NewClient([]string{LocalAddress}).GetInt64("async_batch_9"); !ok || n != 9 {
+			return fmt.Errorf("Expected a full batch flushed got %d %v", n, ok)
+		}
+		return nil
+	})
+}
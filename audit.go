@@ -0,0 +1,153 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// AuditProblem identifies the kind of problem found by an Auditor
+type AuditProblem string
+
+const (
+	// AuditUnknownFlags is an item whose flags aren't a known pylibmc combination
+	AuditUnknownFlags AuditProblem = "unknown_flags"
+	// AuditUndecodable is an item whose value doesn't decode according to its flags
+	AuditUndecodable AuditProblem = "undecodable"
+	// AuditOversized is an item larger than AuditOptions.MaxSize
+	AuditOversized AuditProblem = "oversized"
+	// AuditMissingPrefix is a key without any of AuditOptions.Prefixes
+	AuditMissingPrefix AuditProblem = "missing_prefix"
+)
+
+// AuditFinding is a problem found with one item
+type AuditFinding struct {
+	Server  string
+	Key     string
+	Problem AuditProblem
+	Flags   uint32
+	Size    int
+	Detail  string
+}
+
+// AuditOptions configures an Auditor
+type AuditOptions struct {
+	// KnownFlags are the acceptable flag values. Defaults to the pylibmc flags
+	// (FLAG_NONE, FLAG_PICKLE, FLAG_INTEGER, FLAG_LONG, FLAG_BOOL), each with or
+	// without FLAG_ZLIB.
+	KnownFlags []uint32
+	// MaxSize reports items whose size as reported by metadump (which includes
+	// memcached's per item overhead) exceeds it. Zero disables the check.
+	MaxSize int
+	// Prefixes reports keys not starting with any of them. Empty disables the check.
+	Prefixes []string
+	// Decode fetches every item and checks its value decodes according to its flags.
+	// This is also needed to check flags on servers older than memcached 1.6.
+	Decode bool
+}
+
+// Auditor scans every key in the cluster (via metadump) and reports items that
+// don't conform: unknown flags, undecodable values, oversized values or keys
+// missing a namespace prefix. It finds garbage written by old or foreign clients.
+type Auditor struct {
+	c     *Client
+	opts  AuditOptions
+	known map[uint32]bool
+}
+
+// NewAuditor returns an Auditor scanning the servers of c
+func NewAuditor(c *Client, opts AuditOptions) *Auditor {
+	if len(opts.KnownFlags) == 0 {
+		for _, f := range []uint32{FLAG_NONE, FLAG_PICKLE, FLAG_INTEGER, FLAG_LONG, FLAG_BOOL} {
+			opts.KnownFlags = append(opts.KnownFlags, f, f|FLAG_ZLIB)
+		}
+	}
+	known := make(map[uint32]bool, len(opts.KnownFlags))
+	for _, f := range opts.KnownFlags {
+		known[f] = true
+	}
+	return &Auditor{c: c, opts: opts, known: known}
+}
+
+// Audit scans every item calling fn for each finding. An item may produce
+// several findings. Scanning stops at the first error returned by fn.
+func (a *Auditor) Audit(ctx context.Context, fn func(AuditFinding) error) error {
+	return a.c.Metadump(ctx, func(server net.Addr, e MetadumpEntry) error {
+		for _, f := range a.check(e) {
+			f.Server = server.String()
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *Auditor) check(e MetadumpEntry) []AuditFinding {
+	var findings []AuditFinding
+	add := func(p AuditProblem, flags uint32, detail string) {
+		findings = append(findings, AuditFinding{Key: e.Key, Problem: p, Flags: flags, Size: e.Size, Detail: detail})
+	}
+	if len(a.opts.Prefixes) > 0 && !hasAnyPrefix(e.Key, a.opts.Prefixes) {
+		add(AuditMissingPrefix, e.Flags, "")
+	}
+	if a.opts.MaxSize > 0 && e.Size > a.opts.MaxSize {
+		add(AuditOversized, e.Flags, fmt.Sprintf("%d bytes", e.Size))
+	}
+	if e.HasFlags && !a.known[e.Flags] {
+		add(AuditUnknownFlags, e.Flags, "")
+	}
+	if !a.opts.Decode {
+		return findings
+	}
+	i, err := a.c.Client.Get(e.Key)
+	if err != nil {
+		// expired or evicted since the dump
+		return findings
+	}
+	if !e.HasFlags && !a.known[i.Flags] {
+		add(AuditUnknownFlags, i.Flags, "")
+	}
+	if err := checkDecodes(i); err != nil {
+		add(AuditUndecodable, i.Flags, err.Error())
+	}
+	return findings
+}
+
+// checkDecodes returns an error if i's value doesn't decode according to its flags
+func checkDecodes(i *memcache.Item) error {
+	switch i.Flags {
+	case FLAG_PICKLE:
+		_, err := unpickle(i.Value)
+		return err
+	case FLAG_NONE:
+		if looksPickled(i.Value) {
+			_, err := unpickle(i.Value)
+			return err
+		}
+	case FLAG_INTEGER:
+		_, err := (&Item{i}).Int64()
+		return err
+	case FLAG_LONG:
+		if _, ok := new(big.Int).SetString(string(i.Value), 10); !ok {
+			return fmt.Errorf("invalid long %q", i.Value)
+		}
+	case FLAG_BOOL:
+		_, err := (&Item{i}).Bool()
+		return err
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
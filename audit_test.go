@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestParseMetadumpLine(t *testing.T) {
+	e, err := parseMetadumpLine("key=user%3A1%20x exp=-1 la=1700000000 cas=12 fetch=no cls=1 size=64 flags=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MetadumpEntry{Key: "user:1 x", Exp: -1, LastAccess: 1700000000, CAS: 12, Class: 1, Size: 64, Flags: 2, HasFlags: true}
+	if e != expected {
+		t.Errorf("Expected %+v, got: %+v", expected, e)
+	}
+	if e, _ := parseMetadumpLine("key=a exp=-1 la=1 cas=1 fetch=no cls=1 size=1"); e.HasFlags {
+		t.Errorf("Expected no flags for pre 1.6 metadump")
+	}
+	if _, err := parseMetadumpLine("exp=-1"); err == nil {
+		t.Errorf("Expected error for line without key")
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	flushAll(t, mc)
+	mc.Set(StringItem("app:ok", "fine"))
+	mc.Set(Int64Item("app:int", 5))
+	mc.Set(&memcache.Item{Key: "app:foreign", Value: []byte("x"), Flags: 1 << 10})
+	mc.Set(&memcache.Item{Key: "app:badpickle", Value: []byte("garbage"), Flags: FLAG_PICKLE})
+	mc.Set(StringItem("noprefix", "fine"))
+
+	found := make(map[string]AuditProblem)
+	err := NewAuditor(mc, AuditOptions{Prefixes: []string{"app:"}, Decode: true}).Audit(context.Background(), func(f AuditFinding) error {
+		found[f.Key] = f.Problem
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]AuditProblem{
+		"app:foreign":   AuditUnknownFlags,
+		"app:badpickle": AuditUndecodable,
+		"noprefix":      AuditMissingPrefix,
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected %v, got: %v", expected, found)
+	}
+	for k, p := range expected {
+		if found[k] != p {
+			t.Errorf("Expected %s for %s, got: %q", p, k, found[k])
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrAuthFailed is returned by operations on servers rejecting
+// Options.Username and Options.Password
+var ErrAuthFailed = errors.New("memcache: authentication failed")
+
+// binary protocol opcodes and statuses of the SASL handshake
+const (
+	binRequest      = 0x80
+	binResponse     = 0x81
+	opSASLListMechs = 0x20
+	opSASLAuth      = 0x21
+
+	statusOK             = 0x0000
+	statusAuthError      = 0x0020
+	statusUnknownCommand = 0x0081
+)
+
+// binHeaderLen is the size of a binary protocol request or response header
+const binHeaderLen = 24
+
+// maxSASLBody bounds the response bodies read during the handshake
+const maxSASLBody = 4096
+
+// authenticate runs a binary protocol SASL PLAIN handshake with
+// Options.Username and Options.Password on a new connection, as pylibmc does:
+// SASL_LIST_MECHS checks the server offers PLAIN, then SASL_AUTH sends the
+// credentials. The handshake is bounded by the ctx deadline or else the client
+// Timeout.
+func (c *Client) authenticate(ctx context.Context, nc net.Conn) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		timeout := c.Timeout
+		if timeout == 0 {
+			timeout = memcache.DefaultTimeout
+		}
+		deadline = time.Now().Add(timeout)
+	}
+	nc.SetDeadline(deadline)
+	defer nc.SetDeadline(time.Time{})
+
+	status, mechs, err := binRoundTrip(nc, opSASLListMechs, "", nil)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case statusOK:
+	case statusUnknownCommand:
+		return fmt.Errorf("%w: SASL", ErrFeatureUnsupported)
+	default:
+		return fmt.Errorf("memcache: SASL_LIST_MECHS failed with status 0x%04x", status)
+	}
+	offered := false
+	for _, m := range bytes.Fields(mechs) {
+		offered = offered || string(m) == "PLAIN"
+	}
+	if !offered {
+		return fmt.Errorf("memcache: server doesn't offer SASL PLAIN, only %q", mechs)
+	}
+
+	creds := []byte("\x00" + c.opts.Username + "\x00" + c.opts.Password)
+	status, body, err := binRoundTrip(nc, opSASLAuth, "PLAIN", creds)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case statusOK:
+		return nil
+	case statusAuthError:
+		return fmt.Errorf("%w: %s", ErrAuthFailed, body)
+	}
+	return fmt.Errorf("memcache: SASL_AUTH failed with status 0x%04x: %s", status, body)
+}
+
+// binRoundTrip sends a binary protocol request without extras and returns the
+// status and body of its response
+func binRoundTrip(rw io.ReadWriter, opcode byte, key string, value []byte) (uint16, []byte, error) {
+	req := make([]byte, binHeaderLen, binHeaderLen+len(key)+len(value))
+	req[0] = binRequest
+	req[1] = opcode
+	binary.BigEndian.PutUint16(req[2:], uint16(len(key)))
+	binary.BigEndian.PutUint32(req[8:], uint32(len(key)+len(value)))
+	req = append(append(req, key...), value...)
+	if _, err := rw.Write(req); err != nil {
+		return 0, nil, err
+	}
+
+	// the server sends nothing after the response, so nothing is buffered
+	var header [binHeaderLen]byte
+	if _, err := io.ReadFull(rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != binResponse || header[1] != opcode {
+		return 0, nil, fmt.Errorf("memcache: unexpected SASL response header % x", header[:2])
+	}
+	n := binary.BigEndian.Uint32(header[8:])
+	if n > maxSASLBody {
+		return 0, nil, fmt.Errorf("memcache: SASL response of %d bytes", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(rw, body); err != nil {
+		return 0, nil, err
+	}
+	// skip the extras and key, which the SASL responses don't have
+	skip := int(header[4]) + int(binary.BigEndian.Uint16(header[2:]))
+	if skip > len(body) {
+		return 0, nil, fmt.Errorf("memcache: malformed SASL response")
+	}
+	return binary.BigEndian.Uint16(header[6:]), body[skip:], nil
+}
@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// authServer starts an embedded server requiring a SASL PLAIN handshake with
+// the credentials user and pass, offering mechs
+func authServer(t *testing.T, mechs string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &localServer{ln: ln, clock: systemClock{}, items: make(map[string]*localItem)}
+	respond := func(nc net.Conn, opcode byte, status uint16, body string) {
+		header := make([]byte, binHeaderLen)
+		header[0], header[1] = binResponse, opcode
+		binary.BigEndian.PutUint16(header[6:], status)
+		binary.BigEndian.PutUint32(header[8:], uint32(len(body)))
+		nc.Write(append(header, body...))
+	}
+	read := func(nc net.Conn) (byte, string, string, error) {
+		header := make([]byte, binHeaderLen)
+		if _, err := io.ReadFull(nc, header); err != nil {
+			return 0, "", "", err
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(nc, body); err != nil {
+			return 0, "", "", err
+		}
+		keyLen := binary.BigEndian.Uint16(header[2:])
+		return header[1], string(body[:keyLen]), string(body[keyLen:]), nil
+	}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if op, _, _, err := read(nc); err != nil || op != opSASLListMechs {
+					nc.Close()
+					return
+				}
+				respond(nc, opSASLListMechs, statusOK, mechs)
+				op, mech, creds, err := read(nc)
+				if err != nil || op != opSASLAuth || mech != "PLAIN" || creds != "\x00user\x00pass" {
+					respond(nc, opSASLAuth, statusAuthError, "Auth failure")
+					nc.Close()
+					return
+				}
+				respond(nc, opSASLAuth, statusOK, "Authenticated")
+				s.handle(nc)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestAuthentication(t *testing.T) {
+	addr := authServer(t, "CRAM-MD5 PLAIN")
+
+	mc := NewClientWithOptions([]string{addr}, Options{Username: "user", Password: "pass"})
+	if err := mc.Set(StringItem("auth", "value")); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("auth"); !ok || s != "value" {
+		t.Errorf("Expected value got %q %v", s, ok)
+	}
+
+	mc = NewClientWithOptions([]string{addr}, Options{Username: "user", Password: "wrong"})
+	if _, err := mc.Get("auth"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed got %v", err)
+	}
+
+	mc = NewClientWithOptions([]string{authServer(t, "CRAM-MD5")}, Options{Username: "user", Password: "pass"})
+	if _, err := mc.Get("auth"); err == nil || errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected an error for a server without PLAIN got %v", err)
+	}
+}
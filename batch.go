@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultBatchMaxKeys is the batch size that triggers an early flush when
+// Options.BatchMaxKeys is unset
+const DefaultBatchMaxKeys = 100
+
+// getBatcher coalesces concurrent Gets issued within a window into one GetMulti
+type getBatcher struct {
+	c       *Client
+	window  time.Duration
+	maxKeys int
+
+	mu      sync.Mutex
+	pending map[string][]chan batchResult
+	timer   *time.Timer
+}
+
+type batchResult struct {
+	item *memcache.Item
+	err  error
+}
+
+func newGetBatcher(c *Client, window time.Duration, maxKeys int) *getBatcher {
+	return &getBatcher{c: c, window: window, maxKeys: maxKeys}
+}
+
+// get queues key for the next batch and waits for its result
+func (b *getBatcher) get(key string) (*memcache.Item, error) {
+	ch := make(chan batchResult, 1)
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string][]chan batchResult)
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.pending[key] = append(b.pending[key], ch)
+	full := len(b.pending) >= b.maxKeys
+	b.mu.Unlock()
+	if full {
+		b.flush()
+	}
+	r := <-ch
+	return r.item, r.err
+}
+
+// flush fetches every pending key with a single GetMulti and hands each waiter
+// its result. Keys missing from a failed GetMulti get its error.
+func (b *getBatcher) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	items, err := b.c.getMultiFull(keys)
+	if err == nil {
+		err = memcache.ErrCacheMiss
+	}
+	for k, waiters := range pending {
+		r := batchResult{err: err}
+		if i, ok := items[k]; ok {
+			r = batchResult{item: i}
+		}
+		for _, ch := range waiters {
+			wr := r
+			if r.item != nil {
+				// each caller gets its own copy, value included, to modify and CAS
+				wr.item = cloneItem(r.item)
+			}
+			ch <- wr
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestBatchedGets(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{
+		BatchWindow:       50 * time.Millisecond,
+		ServerStatsWindow: time.Minute,
+	})
+	for n := 0; n < 10; n++ {
+		mc.Set(StringItem(fmt.Sprintf("batch_%d", n), fmt.Sprint(n)))
+	}
+	mc.Delete("batch_missing")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 21)
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			key := fmt.Sprintf("batch_%d", n%10)
+			i, err := mc.Get(key)
+			if err != nil || string(i.Value) != fmt.Sprint(n%10) {
+				errs <- fmt.Errorf("%s: %v %v", key, i, err)
+			}
+		}(n)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := mc.Get("batch_missing"); err != memcache.ErrCacheMiss {
+			errs <- fmt.Errorf("Expected ErrCacheMiss, got: %v", err)
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stats := mc.ServerStats()
+	if len(stats) != 1 || stats[0].Requests >= 21 {
+		t.Errorf("Expected Gets to be batched, got: %+v", stats)
+	}
+}
+
+func TestBatchMaxKeys(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{
+		BatchWindow:  time.Hour,
+		BatchMaxKeys: 1,
+	})
+	mc.Set(StringItem("batch_max", "x"))
+	if i, err := mc.Get("batch_max"); err != nil || string(i.Value) != "x" {
+		t.Errorf("Expected full batch to flush immediately, got: %v %v", i, err)
+	}
+}
+
+func TestBatchedGetsCopies(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{BatchWindow: 50 * time.Millisecond})
+	mc.Set(&memcache.Item{Key: "batch_copies", Value: []byte("v")})
+	var wg sync.WaitGroup
+	results := make([]*memcache.Item, 5)
+	for n := range results {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			i, err := mc.Get("batch_copies")
+			if err != nil {
+				t.Error(err)
+			}
+			results[n] = i
+		}(n)
+	}
+	wg.Wait()
+	for n, i := range results {
+		if i == nil || string(i.Value) != "v" {
+			t.Fatalf("Expected the shared value, got %v", i)
+		}
+		for _, other := range results[n+1:] {
+			if i == other || &i.Value[0] == &other.Value[0] {
+				t.Error("Expected each caller to get its own item and value")
+			}
+		}
+	}
+}
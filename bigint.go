@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// GetBigInt gets a python int of any size from cache returning whether or not
+// the get was successful
+func (c *Client) GetBigInt(k string, opts ...CallOption) (*big.Int, bool) {
+	return getBigInt(c.getter(opts), k)
+}
+
+func getBigInt(c itemGetter, k string) (*big.Int, bool) {
+	n, err := getDecoded(c, k, (*Item).BigInt)
+	return n, err == nil
+}
+
+// BigInt returns the compatible python int value, including those beyond
+// int64 that Int64 fails on with strconv.ErrRange
+func (i *Item) BigInt() (*big.Int, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return nil, err
+	}
+	if i.Flags != FLAG_INTEGER && i.Flags != FLAG_LONG {
+		return nil, InvalidType
+	}
+	return parseBigInt(i.Value)
+}
+
+// parseBigInt parses the decimal integer stored for FLAG_INTEGER and FLAG_LONG
+// values of any size
+func parseBigInt(b []byte) (*big.Int, error) {
+	if n, err := parseInt64(b); err == nil {
+		return big.NewInt(n), nil
+	}
+	n, ok := new(big.Int).SetString(string(b), 10)
+	if !ok {
+		return nil, fmt.Errorf("memcache: invalid int %q", b)
+	}
+	return n, nil
+}
+
+// BigIntItem returns a memcache.Item storing a python int of any size the way
+// pylibmc stores a long: as its decimal digits with FLAG_LONG
+func BigIntItem(k string, v *big.Int) *memcache.Item {
+	return &memcache.Item{
+		Key:   k,
+		Value: v.Append(nil, 10),
+		Flags: FLAG_LONG,
+	}
+}
+
+// deserializeInt is Deserialize for FLAG_INTEGER and FLAG_LONG values, giving
+// an int64 or, beyond its range, a *big.Int
+func deserializeInt(value []byte) (interface{}, error) {
+	n, err := parseInt64(value)
+	if errors.Is(err, strconv.ErrRange) {
+		return parseBigInt(value)
+	}
+	return n, err
+}
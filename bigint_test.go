@@ -0,0 +1,56 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestBigInt(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	// 2**70 and -2**70 as stored by pylibmc
+	for _, s := range []string{"1180591620717411303424", "-1180591620717411303424", "42"} {
+		want, _ := new(big.Int).SetString(s, 10)
+		item := BigIntItem("bigint", want)
+		if string(item.Value) != s || item.Flags != FLAG_LONG {
+			t.Errorf("unexpected item %q %d", item.Value, item.Flags)
+		}
+		if err := mc.Set(item); err != nil {
+			t.Fatal(err)
+		}
+		if n, ok := mc.GetBigInt("bigint"); !ok || n.Cmp(want) != 0 {
+			t.Errorf("Expected %s got %v %v", s, n, ok)
+		}
+		if n, err := Get[*big.Int](mc, "bigint"); err != nil || n.Cmp(want) != 0 {
+			t.Errorf("Get: expected %s got %v %v", s, n, err)
+		}
+		mc.Delete("bigint")
+		if err := Set(mc, "bigint", want, 0); err != nil {
+			t.Fatal(err)
+		}
+		if i, err := mc.Get("bigint"); err != nil || string(i.Value) != s || i.Flags != FLAG_LONG {
+			t.Errorf("Set: expected %s got %v", s, i)
+		}
+	}
+
+	long := &Item{&memcache.Item{Value: []byte("1180591620717411303424"), Flags: FLAG_LONG}}
+	if _, err := long.Int64(); !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("Expected ErrRange from Int64 got %v", err)
+	}
+	if v, err := Deserialize(long.Value, long.Flags); err != nil || fmt.Sprint(v) != "1180591620717411303424" {
+		t.Errorf("unexpected Deserialize %v %v", v, err)
+	}
+	if v, err := Deserialize([]byte("42"), FLAG_INTEGER); err != nil || v != int64(42) {
+		t.Errorf("unexpected Deserialize %v %v", v, err)
+	}
+	if _, err := (&Item{StringItem("", "1")}).BigInt(); err != InvalidType {
+		t.Errorf("Expected InvalidType got %v", err)
+	}
+	if _, err := (&Item{&memcache.Item{Value: []byte("1.5"), Flags: FLAG_LONG}}).BigInt(); err == nil {
+		t.Error("Expected an error for an invalid int")
+	}
+}
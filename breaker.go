@@ -0,0 +1,138 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is how long a server's circuit stays open when
+// Options.BreakerCooldown is unset
+const DefaultBreakerCooldown = 5 * time.Second
+
+// MetricBreakerTrips counts circuits opening, tagged by server
+const MetricBreakerTrips = "memcache.breaker.trips"
+
+// ErrCircuitOpen is returned without contacting a server whose circuit is open
+// (see Options.BreakerFailures). The typed getters report it as a miss.
+var ErrCircuitOpen = errors.New("memcache: server circuit open")
+
+// BreakerState is the state of a server's circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets operations through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails operations with ErrCircuitOpen until the cooldown ends
+	BreakerOpen
+	// BreakerHalfOpen lets one trial operation through after the cooldown; its
+	// success closes the circuit and its failure opens it again
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// MarshalText encodes the state as its name
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a state name written by MarshalText
+func (s *BreakerState) UnmarshalText(b []byte) error {
+	for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		if state.String() == string(b) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("memcache: unknown breaker state %q", b)
+}
+
+// circuitBreaker opens a server's circuit after consecutive failed operations
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	cooldown time.Duration
+	servers  map[string]*breakerState
+}
+
+type breakerState struct {
+	failures int
+	open     bool
+	openedAt time.Time
+	trial    bool // a half-open trial operation is in flight
+}
+
+func newCircuitBreaker(failures int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failures: failures,
+		cooldown: cooldown,
+		servers:  make(map[string]*breakerState),
+	}
+}
+
+// allow reports whether an operation may be sent to server, letting one trial
+// through once an open circuit's cooldown has passed
+func (b *circuitBreaker) allow(server string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.servers[server]
+	if !ok || !s.open {
+		return true
+	}
+	if now.Before(s.openedAt.Add(b.cooldown)) || s.trial {
+		return false
+	}
+	s.trial = true
+	return true
+}
+
+// record updates server's circuit with the outcome of an operation, reporting
+// whether it opened. Misses and failed conditions are reported as a nil err.
+func (b *circuitBreaker) record(server string, err error, now time.Time) (tripped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.servers, server)
+		return false
+	}
+	s, ok := b.servers[server]
+	if !ok {
+		s = &breakerState{}
+		b.servers[server] = s
+	}
+	s.failures++
+	switch {
+	case s.trial:
+		s.trial = false
+		s.openedAt = now
+	case !s.open && s.failures >= b.failures:
+		s.open = true
+		s.openedAt = now
+		return true
+	}
+	return false
+}
+
+// state returns server's circuit state at now
+func (b *circuitBreaker) state(server string, now time.Time) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.servers[server]
+	switch {
+	case !ok || !s.open:
+		return BreakerClosed
+	case s.trial || !now.Before(s.openedAt.Add(b.cooldown)):
+		return BreakerHalfOpen
+	}
+	return BreakerOpen
+}
@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	metrics := &countingMetrics{}
+	addr := closedAddr(t)
+	mc := NewClientWithOptions([]string{addr}, Options{
+		Clock:           clock,
+		Metrics:         metrics,
+		BreakerFailures: 2,
+		BreakerCooldown: time.Minute,
+	})
+
+	for n := 0; n < 2; n++ {
+		if _, err := mc.Get("breaker_key"); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("attempt %d: expected a dial error, got: %v", n, err)
+		}
+	}
+	if _, err := mc.Get("breaker_key"); err != ErrCircuitOpen {
+		t.Errorf("Expected the circuit to be open, got: %v", err)
+	}
+	if _, ok := mc.GetString("breaker_key"); ok {
+		t.Error("Expected an open circuit to read as a miss")
+	}
+	if err := mc.Set(StringItem("breaker_key", "v")); err != ErrCircuitOpen {
+		t.Errorf("Expected writes to be short-circuited too, got: %v", err)
+	}
+	if s := mc.ServerStats()[0]; s.Breaker != BreakerOpen {
+		t.Errorf("Expected an open breaker, got: %s", s.Breaker)
+	}
+	if n := metrics.get(MetricBreakerTrips); n != 1 {
+		t.Errorf("Expected 1 trip counted, got: %d", n)
+	}
+
+	// after the cooldown one trial goes through, and its failure reopens
+	clock.Advance(time.Minute)
+	if s := mc.ServerStats()[0]; s.Breaker != BreakerHalfOpen {
+		t.Errorf("Expected a half-open breaker, got: %s", s.Breaker)
+	}
+	if _, err := mc.Get("breaker_key"); err == nil || err == ErrCircuitOpen {
+		t.Errorf("Expected the trial to reach the server, got: %v", err)
+	}
+	if _, err := mc.Get("breaker_key"); err != ErrCircuitOpen {
+		t.Errorf("Expected the circuit to reopen, got: %v", err)
+	}
+}
+
+func TestCircuitBreakerCloses(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Second)
+	if !b.record("a", errors.New("reset"), now) {
+		t.Fatal("Expected the circuit to open")
+	}
+	if b.allow("a", now) {
+		t.Error("Expected the open circuit to refuse operations")
+	}
+	now = now.Add(time.Second)
+	if !b.allow("a", now) || b.allow("a", now) {
+		t.Error("Expected exactly one trial")
+	}
+	b.record("a", nil, now)
+	if s := b.state("a", now); s != BreakerClosed || !b.allow("a", now) {
+		t.Errorf("Expected the successful trial to close the circuit, got: %s", s)
+	}
+	if s := b.state("b", now); s != BreakerClosed {
+		t.Errorf("Expected an unknown server to be closed, got: %s", s)
+	}
+}
+
+func TestBreakerStateJSON(t *testing.T) {
+	b, err := json.Marshal(ServerStats{Addr: "a", Breaker: BreakerHalfOpen})
+	if err != nil || !strings.Contains(string(b), `"breaker":"half-open"`) {
+		t.Errorf("unexpected json %s %v", b, err)
+	}
+}
@@ -0,0 +1,127 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricBulkLimited counts bulk operations over Options.MaxBulkKeys or
+// MaxBulkBytes, tagged by op and by result: "chunked" or "rejected"
+const MetricBulkLimited = "memcache.bulk.limited"
+
+// BulkLimitPolicy selects what happens to a bulk operation over
+// Options.MaxBulkKeys or Options.MaxBulkBytes
+type BulkLimitPolicy int
+
+const (
+	// BulkLimitReject fails the operation with a *BulkLimitError before
+	// anything is sent
+	BulkLimitReject BulkLimitPolicy = iota
+	// BulkLimitChunk splits the operation into operations within the limits,
+	// made one after another
+	BulkLimitChunk
+)
+
+// ErrBulkLimit is matched with errors.Is by every *BulkLimitError
+var ErrBulkLimit = errors.New("memcache: bulk operation over limit")
+
+// BulkLimitError is returned under BulkLimitReject for bulk operations over
+// Options.MaxBulkKeys or Options.MaxBulkBytes
+type BulkLimitError struct {
+	Op    string // OpGetMulti or OpSetMulti
+	Keys  int
+	Bytes int
+	// MaxKeys and MaxBytes are the limits; zero is unlimited
+	MaxKeys  int
+	MaxBytes int
+}
+
+func (e *BulkLimitError) Error() string {
+	return fmt.Sprintf("memcache: %s of %d keys (%d bytes) over the limit of %d keys, %d bytes", e.Op, e.Keys, e.Bytes, e.MaxKeys, e.MaxBytes)
+}
+
+func (e *BulkLimitError) Is(target error) bool { return target == ErrBulkLimit }
+
+// bulkChunks checks a bulk operation on n keys, the ith adding size(i) bytes
+// to the request, against Options.MaxBulkKeys and MaxBulkBytes. It returns
+// nil within the limits. Over them it returns a *BulkLimitError or, under
+// BulkLimitChunk, the [start, end) bounds of consecutive chunks within them,
+// a key larger than MaxBulkBytes on its own being a chunk by itself.
+func (c *Client) bulkChunks(op string, n int, size func(i int) int) ([][2]int, error) {
+	maxKeys, maxBytes := c.opts.MaxBulkKeys, c.opts.MaxBulkBytes
+	if maxKeys <= 0 && maxBytes <= 0 {
+		return nil, nil
+	}
+	var chunks [][2]int
+	start, keys, bytes, total := 0, 0, 0, 0
+	for i := 0; i < n; i++ {
+		s := size(i)
+		total += s
+		if keys > 0 && ((maxKeys > 0 && keys+1 > maxKeys) || (maxBytes > 0 && bytes+s > maxBytes)) {
+			chunks = append(chunks, [2]int{start, i})
+			start, keys, bytes = i, 0, 0
+		}
+		keys++
+		bytes += s
+	}
+	if len(chunks) == 0 && (maxBytes <= 0 || total <= maxBytes) {
+		return nil, nil
+	}
+	if c.opts.BulkLimit != BulkLimitChunk {
+		c.opts.Metrics.Count(MetricBulkLimited, 1, map[string]string{"op": op, "result": "rejected"})
+		return nil, &BulkLimitError{Op: op, Keys: n, Bytes: total, MaxKeys: maxKeys, MaxBytes: maxBytes}
+	}
+	if len(chunks) == 0 {
+		// a single key can't be split
+		return nil, nil
+	}
+	c.opts.Metrics.Count(MetricBulkLimited, 1, map[string]string{"op": op, "result": "chunked"})
+	return append(chunks, [2]int{start, n}), nil
+}
+
+// getSize is the size a key adds to a get request
+func getSize(keys []string) func(i int) int {
+	return func(i int) int { return len(keys[i]) + 1 }
+}
+
+// getMultiChunked makes a GetMulti over the bulk limits as one get per chunk,
+// returning the items of every chunk and the first error
+func getMultiChunked(keys []string, chunks [][2]int, get func(keys []string) (map[string]*memcache.Item, error)) (map[string]*memcache.Item, error) {
+	m := make(map[string]*memcache.Item, len(keys))
+	var firstErr error
+	for _, ch := range chunks {
+		items, err := get(keys[ch[0]:ch[1]])
+		for k, i := range items {
+			m[k] = i
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return m, firstErr
+}
+
+// SetMulti writes every item with Set, returning the first error after trying
+// them all. Options.MaxBulkKeys and MaxBulkBytes, counting keys and values,
+// bound the items of one call; as items are written one at a time
+// BulkLimitChunk needs no splitting and writes them all.
+func (c *Client) SetMulti(items []*memcache.Item) error {
+	return c.SetMultiCtx(context.Background(), items)
+}
+
+// SetMultiCtx is SetMulti writing each item with SetCtx
+func (c *Client) SetMultiCtx(ctx context.Context, items []*memcache.Item) error {
+	_, err := c.bulkChunks(OpSetMulti, len(items), func(i int) int { return len(items[i].Key) + len(items[i].Value) })
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if serr := c.SetCtx(ctx, item); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestBulkLimits(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{MaxBulkKeys: 3, MaxBulkBytes: 100, Metrics: metrics})
+	var items []*memcache.Item
+	var keys []string
+	for n := 0; n < 5; n++ {
+		k := fmt.Sprintf("bulk_%d", n)
+		keys = append(keys, k)
+		items = append(items, StringItem(k, "v"))
+	}
+
+	var le *BulkLimitError
+	if err := mc.SetMulti(items); !errors.As(err, &le) || !errors.Is(err, ErrBulkLimit) || le.Op != OpSetMulti || le.Keys != 5 || le.MaxKeys != 3 {
+		t.Errorf("Expected a *BulkLimitError got %v", err)
+	}
+	if err := mc.SetMulti(items[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.GetMulti(keys); !errors.Is(err, ErrBulkLimit) {
+		t.Errorf("Expected ErrBulkLimit got %v", err)
+	}
+	if _, err := mc.GetMultiCtx(context.Background(), keys); !errors.Is(err, ErrBulkLimit) {
+		t.Errorf("Expected ErrBulkLimit got %v", err)
+	}
+	// repeated keys count once
+	if m, err := mc.GetMulti([]string{"bulk_0", "bulk_0", "bulk_0", "bulk_1"}); err != nil || len(m) != 2 {
+		t.Errorf("unexpected GetMulti %v %v", m, err)
+	}
+	big := StringItem("bulk_big", strings.Repeat("x", 200))
+	if err := mc.SetMulti([]*memcache.Item{big}); !errors.Is(err, ErrBulkLimit) {
+		t.Errorf("Expected ErrBulkLimit for an item over MaxBulkBytes got %v", err)
+	}
+	if n := metrics.get(MetricBulkLimited); n != 4 {
+		t.Errorf("Expected 4 limited operations got %d", n)
+	}
+
+	mc = NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{MaxBulkKeys: 2, MaxBulkBytes: 100, BulkLimit: BulkLimitChunk})
+	if err := mc.SetMulti(append(items, big)); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := mc.GetMulti(append(keys, "bulk_big")); err != nil || len(m) != 6 {
+		t.Errorf("Expected 6 items from chunks got %d %v", len(m), err)
+	}
+	if m, err := mc.GetMultiCtx(context.Background(), keys); err != nil || len(m) != 5 {
+		t.Errorf("Expected 5 items from chunks got %d %v", len(m), err)
+	}
+
+	chunks, _ := mc.bulkChunks(OpGetMulti, 5, func(i int) int { return []int{60, 30, 30, 200, 1}[i] })
+	if fmt.Sprint(chunks) != "[[0 2] [2 3] [3 4] [4 5]]" {
+		t.Errorf("unexpected chunks %v", chunks)
+	}
+}
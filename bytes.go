@@ -0,0 +1,64 @@
+package memcache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// GetBytes gets a python bytes value from cache returning whether or not the
+// get was successful
+func (c *Client) GetBytes(k string, opts ...CallOption) ([]byte, bool) {
+	return getBytes(c.getter(opts), k)
+}
+
+func getBytes(c itemGetter, k string) ([]byte, bool) {
+	b, err := getDecoded(c, k, (*Item).Bytes)
+	return b, err == nil
+}
+
+// Bytes returns the compatible python bytes value: a pickled bytes object, or
+// the value of an item stored without flags, which is how pylibmc stores bytes
+// on Python 3. Pickled values of other types, including str, fail with
+// InvalidType.
+func (i *Item) Bytes() ([]byte, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return nil, err
+	}
+	if i.Flags == FLAG_PICKLE || (i.Flags == FLAG_NONE && looksPickled(i.Value)) {
+		v, err := picklecompat.Decode(i.Value)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, InvalidType
+		}
+		return b, nil
+	}
+	if i.Flags == FLAG_NONE {
+		return i.Value, nil
+	}
+	return nil, InvalidType
+}
+
+// BytesItem returns a memcache.Item storing v pickled as a python bytes object
+// the way Python 3's pickle.dumps(v, 2) does, which Python 2 loads as a str
+func BytesItem(k string, v []byte) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(v, picklecompat.EncodeOptions{Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// BytesItem is BytesItem pickling v with Options.PickleProtocol
+func (c *Client) BytesItem(k string, v []byte) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(v, picklecompat.EncodeOptions{Protocol: c.opts.PickleProtocol, Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
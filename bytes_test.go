@@ -0,0 +1,41 @@
+package memcache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestBytes(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	raw := []byte("r\xffw\x00")
+
+	// pickle.dumps(b"r\xffw\x00", 2) from python 3
+	py := "\x80\x02c_codecs\nencode\nq\x00X\x05\x00\x00\x00r\xc3\xbfw\x00q\x01X\x06\x00\x00\x00latin1q\x02\x86q\x03Rq\x04."
+	if i := BytesItem("bytes", raw); string(i.Value) != py || i.Flags != FLAG_PICKLE {
+		t.Errorf("unexpected item %q %d", i.Value, i.Flags)
+	}
+	for _, i := range []*memcache.Item{
+		BytesItem("bytes", raw),
+		NewClientWithOptions(nil, Options{PickleProtocol: 4}).BytesItem("bytes", raw),
+		{Key: "bytes", Value: raw},
+	} {
+		mc.Set(i)
+		if b, ok := mc.GetBytes("bytes"); !ok || !bytes.Equal(b, raw) {
+			t.Errorf("%q: expected %q got %q %v", i.Value, raw, b, ok)
+		}
+	}
+
+	// bytes and str are distinct
+	mc.Set(UnicodeItem("bytes", "str"))
+	if _, ok := mc.GetBytes("bytes"); ok {
+		t.Error("Expected a pickled str not to be read as bytes")
+	}
+	if _, err := (&Item{BytesItem("", raw)}).String(); err != InvalidType {
+		t.Errorf("Expected InvalidType reading bytes as a str, got %v", err)
+	}
+	if _, err := (&Item{Int64Item("", 1)}).Bytes(); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// Cacher is the API provided by Client. Application code can depend on Cacher
+// so wrappers (such as Chaos) or alternate implementations can be substituted.
+// CallOptions are honored by Client; other implementations may ignore them.
+type Cacher interface {
+	ItemCacher
+
+	GetString(k string, opts ...CallOption) (string, bool)
+	GetInt64(k string, opts ...CallOption) (int64, bool)
+	GetBool(k string, opts ...CallOption) (bool, bool)
+	GetFloat64(k string, opts ...CallOption) (float64, bool)
+	GetMap(k string, opts ...CallOption) (map[string]interface{}, bool)
+	GetBigInt(k string, opts ...CallOption) (*big.Int, bool)
+	GetBytes(k string, opts ...CallOption) ([]byte, bool)
+	GetTime(k string, opts ...CallOption) (time.Time, bool)
+	GetDecimal(k string, opts ...CallOption) (picklecompat.Decimal, bool)
+	GetAny(k string, opts ...CallOption) (interface{}, Type, bool)
+}
+
+// ItemCacher is the item operations of Cacher, the part a wrapper or
+// alternate backend has to provide; NewCacher adds the typed getters.
+type ItemCacher interface {
+	Get(key string) (*memcache.Item, error)
+	GetMulti(keys []string) (map[string]*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Add(item *memcache.Item) error
+	Replace(item *memcache.Item) error
+	CompareAndSwap(item *memcache.Item) error
+	Delete(key string) error
+	Touch(key string, seconds int32) error
+	Increment(key string, delta uint64) (uint64, error)
+	Decrement(key string, delta uint64) (uint64, error)
+}
+
+var _ Cacher = (*Client)(nil)
+
+// NewCacher returns a Cacher over c whose typed getters decode the pylibmc
+// values read with c.Get. A wrapper embedding a Cacher and overriding some of
+// its item operations, e.g. to count or namespace them, is made a Cacher with
+// NewCacher so the typed getters go through its Get too:
+//
+//	type counting struct {
+//		memcache.Cacher
+//		gets atomic.Int64
+//	}
+//
+//	func (c *counting) Get(key string) (*memcache.Item, error) {
+//		c.gets.Add(1)
+//		return c.Cacher.Get(key)
+//	}
+//
+//	cache := memcache.NewCacher(&counting{Cacher: client})
+//
+// CallOptions passed to the typed getters are ignored.
+func NewCacher(c ItemCacher) Cacher {
+	return itemCacher{c}
+}
+
+// itemCacher is the Cacher returned by NewCacher
+type itemCacher struct{ ItemCacher }
+
+func (c itemCacher) GetString(k string, _ ...CallOption) (string, bool)   { return getString(c, k) }
+func (c itemCacher) GetInt64(k string, _ ...CallOption) (int64, bool)     { return getInt64(c, k) }
+func (c itemCacher) GetBool(k string, _ ...CallOption) (bool, bool)       { return getBool(c, k) }
+func (c itemCacher) GetFloat64(k string, _ ...CallOption) (float64, bool) { return getFloat64(c, k) }
+func (c itemCacher) GetMap(k string, _ ...CallOption) (map[string]interface{}, bool) {
+	return getMap(c, k)
+}
+func (c itemCacher) GetBigInt(k string, _ ...CallOption) (*big.Int, bool) { return getBigInt(c, k) }
+func (c itemCacher) GetBytes(k string, _ ...CallOption) ([]byte, bool)    { return getBytes(c, k) }
+func (c itemCacher) GetTime(k string, _ ...CallOption) (time.Time, bool)  { return getTime(c, k) }
+func (c itemCacher) GetDecimal(k string, _ ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(c, k)
+}
+func (c itemCacher) GetAny(k string, _ ...CallOption) (interface{}, Type, bool) {
+	return getAny(c, k, deserializeItem)
+}
+
+// itemGetter is the part of Cacher the typed getters are built on so Cacher
+// implementations can share the same decoding
+type itemGetter interface {
+	Get(key string) (*memcache.Item, error)
+}
@@ -0,0 +1,41 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+type countingCacher struct {
+	Cacher
+	gets int
+}
+
+func (c *countingCacher) Get(key string) (*memcache.Item, error) {
+	c.gets++
+	return c.Cacher.Get(key)
+}
+
+func TestNewCacher(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.SetString("cacher_s", "value")
+	mc.SetInt64("cacher_n", 42)
+
+	counting := &countingCacher{Cacher: mc}
+	var c Cacher = NewCacher(counting)
+	if s, ok := c.GetString("cacher_s"); !ok || s != "value" {
+		t.Errorf("Expected value, got %q %v", s, ok)
+	}
+	if n, ok := c.GetInt64("cacher_n"); !ok || n != 42 {
+		t.Errorf("Expected 42, got %d %v", n, ok)
+	}
+	if _, ok := c.GetBool("cacher_missing"); ok {
+		t.Error("Expected a miss")
+	}
+	if counting.gets != 3 {
+		t.Errorf("Expected the typed getters to read through the wrapper, got %d gets", counting.gets)
+	}
+	if err := c.Delete("cacher_s"); err != nil {
+		t.Fatal(err)
+	}
+}
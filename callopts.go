@@ -0,0 +1,198 @@
+package memcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// CallOption changes the behavior of a single typed get or set call
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	skipLocal    bool
+	forceRefresh bool
+	cas          bool // read for a CompareAndSwap, from the owning server only
+	ttl          time.Duration
+	hasTTL       bool
+	timeout      time.Duration
+	early        *EarlyRefresh
+	prefix       string
+	hasPrefix    bool
+}
+
+// SkipLocalCache reads from memcached without consulting or updating any
+// in-process copy (the local cache tier, stale values or Get batching)
+func SkipLocalCache() CallOption {
+	return func(o *callOptions) { o.skipLocal = true }
+}
+
+// ForceRefresh reads from memcached even when an in-process copy could be used,
+// then updates the in-process copy with the result
+func ForceRefresh() CallOption {
+	return func(o *callOptions) { o.forceRefresh = true }
+}
+
+// WithTTLOverride sets the expiration of a typed set. Durations under a second
+// are rounded up to one second.
+func WithTTLOverride(ttl time.Duration) CallOption {
+	return func(o *callOptions) { o.ttl, o.hasTTL = ttl, true }
+}
+
+// WithTimeout bounds the call to d regardless of the client's socket Timeout.
+// The socket Timeout still bounds each read and write, so a client serving
+// both tight reads and slow bulk operations should set Options.Timeout to the
+// longest budget and narrow individual calls with WithTimeout (or a context
+// deadline) rather than use one client per budget.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) { o.timeout = d }
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// getterFunc adapts a function reading through c to itemGetter
+type getterFunc struct {
+	c   *Client
+	get func(key string) (*memcache.Item, error)
+}
+
+func (f getterFunc) Get(key string) (*memcache.Item, error) { return f.get(key) }
+func (f getterFunc) client() *Client                        { return f.c }
+
+// getter returns the itemGetter for a typed get with opts applied
+func (c *Client) getter(opts []CallOption) itemGetter {
+	if len(opts) == 0 && c.opts.Pipeline.empty() && !c.checksFlags() {
+		return c
+	}
+	o := newCallOptions(opts)
+	return getterFunc{c, func(key string) (i *memcache.Item, err error) {
+		err = c.runCtx(context.Background(), o.timeout, func() (err error) {
+			i, err = c.getWith(key, o)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		return c.decodeRead(i)
+	}}
+}
+
+// ctxGetter returns the itemGetter for a typed get honoring ctx
+func (c *Client) ctxGetter(ctx context.Context) itemGetter {
+	return getterFunc{c, func(key string) (*memcache.Item, error) {
+		i, err := c.GetCtx(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return c.decodeRead(i)
+	}}
+}
+
+// decodeRead applies Options.Pipeline and the unknown flags check to an item
+// read by a typed get
+func (c *Client) decodeRead(i *memcache.Item) (*memcache.Item, error) {
+	i, err := c.opts.Pipeline.DecodeItem(i)
+	if err != nil {
+		return nil, decodeError{err}
+	}
+	if i, err = c.unknownFlags(i); err != nil {
+		return nil, decodeError{err}
+	}
+	return i, nil
+}
+
+// getWith is Get honoring SkipLocalCache, ForceRefresh and WithKeyPrefix
+func (c *Client) getWith(key string, o callOptions) (*memcache.Item, error) {
+	full := c.keyWith(key, o)
+	span := c.startSpan(context.Background(), OpGet, key, full)
+	item, err := c.getFull(full, o)
+	span.read(item, err)
+	return unprefixed(item, key), err
+}
+
+// getFull is getWith of a full key
+func (c *Client) getFull(key string, o callOptions) (item *memcache.Item, err error) {
+	if o.cas {
+		err = c.do(OpGet, key, func() (err error) {
+			item, err = c.backendGet(context.Background(), key)
+			return
+		})
+		return c.softExpiry(item, err)
+	}
+	if !o.skipLocal && !o.forceRefresh {
+		return c.get(context.Background(), key)
+	}
+	item, err = c.fetchTiers(context.Background(), key)
+	if o.forceRefresh {
+		c.localStore(key, item, err)
+	}
+	if o.forceRefresh && c.stale != nil {
+		switch err {
+		case nil:
+			c.stale.add(key, item, c.now())
+		case memcache.ErrCacheMiss:
+			c.stale.remove(key)
+		}
+	}
+	return item, err
+}
+
+// setWith stores v under key through the pipeline honoring WithTTLOverride,
+// WithTimeout and WithKeyPrefix
+func (c *Client) setWith(key string, v interface{}, opts []CallOption) error {
+	item, err := c.opts.Pipeline.Encode(key, v)
+	if err != nil {
+		return err
+	}
+	o := newCallOptions(opts)
+	if o.hasTTL {
+		item.Expiration = ttlSeconds(o.ttl)
+	}
+	item.Key = c.keyWith(key, o)
+	span := c.startSpan(context.Background(), OpSet, key, item.Key)
+	err = c.runCtx(context.Background(), o.timeout, func() error {
+		return c.set(item)
+	})
+	span.write(item, err)
+	return err
+}
+
+// ttlSeconds converts d to a memcache expiration in seconds, rounding up
+func ttlSeconds(d time.Duration) int32 {
+	if d <= 0 {
+		return 0
+	}
+	return int32((d + time.Second - 1) / time.Second)
+}
+
+// SetString stores s under k as a pylibmc compatible string
+func (c *Client) SetString(k, s string, opts ...CallOption) error {
+	return c.setWith(k, s, opts)
+}
+
+// SetInt64 stores n under k as a pylibmc compatible integer
+func (c *Client) SetInt64(k string, n int64, opts ...CallOption) error {
+	return c.setWith(k, n, opts)
+}
+
+// SetBool stores b under k as a pylibmc compatible boolean
+func (c *Client) SetBool(k string, b bool, opts ...CallOption) error {
+	return c.setWith(k, b, opts)
+}
+
+// SetFloat64 stores f under k as a pylibmc compatible (pickled) float
+func (c *Client) SetFloat64(k string, f float64, opts ...CallOption) error {
+	return c.setWith(k, f, opts)
+}
+
+// SetNone stores Python None under k as pylibmc does (pickled)
+func (c *Client) SetNone(k string, opts ...CallOption) error {
+	return c.setWith(k, nil, opts)
+}
@@ -0,0 +1,73 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypedSetters(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+
+	if err := mc.SetString("callopt_s", "hello", WithTTLOverride(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := mc.Get("callopt_s"); err != nil || string(i.Value) != "hello" {
+		t.Errorf("unexpected item %v %v", i, err)
+	}
+	if err := mc.SetInt64("callopt_n", 5, WithTimeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := mc.GetInt64("callopt_n", WithTimeout(time.Second)); !ok || n != 5 {
+		t.Errorf("Expected 5, got: %d %v", n, ok)
+	}
+	if err := mc.SetBool("callopt_b", true, WithTTLOverride(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := mc.GetBool("callopt_b", SkipLocalCache()); !ok || !b {
+		t.Errorf("Expected true, got: %v %v", b, ok)
+	}
+}
+
+func TestSkipLocalCache(t *testing.T) {
+	addr := closedAddr(t)
+	mc := NewClientWithOptions([]string{addr}, Options{MaxStaleness: time.Hour})
+	mc.stale.add("callopt_stale", StringItem("callopt_stale", "old"), mc.now())
+
+	if s, ok := mc.GetString("callopt_stale"); !ok || s != "old" {
+		t.Errorf("Expected stale value by default, got: %q %v", s, ok)
+	}
+	if _, ok := mc.GetString("callopt_stale", SkipLocalCache()); ok {
+		t.Errorf("Expected SkipLocalCache to bypass stale value")
+	}
+	if _, ok := mc.GetString("callopt_stale", ForceRefresh()); ok {
+		t.Errorf("Expected ForceRefresh to bypass stale value")
+	}
+}
+
+func TestTTLSeconds(t *testing.T) {
+	for d, want := range map[time.Duration]int32{
+		0:                       0,
+		-time.Second:            0,
+		time.Millisecond:        1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+		time.Hour:               3600,
+	} {
+		if got := ttlSeconds(d); got != want {
+			t.Errorf("ttlSeconds(%s) = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestSkipLocalCacheTiers(t *testing.T) {
+	secondary := NewClient([]string{"127.0.0.1:11211"})
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Secondary: secondary})
+	mc.Delete("callopt_tiers")
+	secondary.Set(StringItem("callopt_tiers", "regional"))
+
+	for _, opt := range []CallOption{SkipLocalCache(), ForceRefresh()} {
+		if s, ok := mc.GetString("callopt_tiers", opt); !ok || s != "regional" {
+			t.Errorf("Expected the secondary value got %q %v", s, ok)
+		}
+	}
+}
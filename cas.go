@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"context"
+
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// CASToken is the version of a value read by GetsString or GetsInt64, for the
+// CompareAndSwapString or CompareAndSwapInt64 call replacing it
+type CASToken struct {
+	Key   string
+	CasID uint64
+	// Flags are the pylibmc flags of the value read, once Pipeline stages are
+	// undone, which the replacement is stored with
+	Flags uint32
+}
+
+// GetsString is GetString also returning the CASToken of the value
+func (c *Client) GetsString(k string, opts ...CallOption) (string, CASToken, bool) {
+	var tok CASToken
+	s, err := getDecoded(c.casGetter(opts), k, func(i *Item) (string, error) {
+		tok = CASToken{Key: k, CasID: i.CasID, Flags: i.Flags}
+		return i.StringPolicy(c.opts.Unicode)
+	})
+	return s, tok, err == nil
+}
+
+// GetsInt64 is GetInt64 also returning the CASToken of the value
+func (c *Client) GetsInt64(k string, opts ...CallOption) (int64, CASToken, bool) {
+	var tok CASToken
+	n, err := getDecoded(c.casGetter(opts), k, func(i *Item) (int64, error) {
+		tok = CASToken{Key: k, CasID: i.CasID, Flags: i.Flags}
+		return i.Int64()
+	})
+	return n, tok, err == nil
+}
+
+// casGetter is getter for the typed Gets, reading from the server owning the
+// key as casGet does
+func (c *Client) casGetter(opts []CallOption) itemGetter {
+	return c.getter(append([]CallOption{func(o *callOptions) { o.cas = true }}, opts...))
+}
+
+// CompareAndSwapString stores s under the key of tok if its value hasn't
+// changed since it was read, encoded as the value read was: a pickled unicode
+// string for a pickle, else a str. ErrCASConflict is returned if it changed
+// and ErrCacheMiss if it was deleted. The remaining TTL is carried forward, as
+// UpdateInPlace does, unless WithTTLOverride is given.
+func (c *Client) CompareAndSwapString(tok CASToken, s string, opts ...CallOption) error {
+	value, flags := []byte(s), uint32(FLAG_NONE)
+	if tok.Flags == FLAG_PICKLE {
+		var err error
+		if value, err = picklecompat.EncodeWith(s, picklecompat.EncodeOptions{Protocol: c.opts.Pipeline.protocol}); err != nil {
+			return err
+		}
+		flags = FLAG_PICKLE
+	}
+	return c.compareAndSwapWith(tok, value, flags, opts)
+}
+
+// CompareAndSwapInt64 stores n under the key of tok as CompareAndSwapString
+// does, keeping FLAG_LONG for a value read as a Python 2 long
+func (c *Client) CompareAndSwapInt64(tok CASToken, n int64, opts ...CallOption) error {
+	flags := uint32(FLAG_INTEGER)
+	if tok.Flags == FLAG_LONG {
+		flags = FLAG_LONG
+	}
+	return c.compareAndSwapWith(tok, formatInt64(n), flags, opts)
+}
+
+// compareAndSwapWith stores a serialized value through the pipeline with the
+// CAS ID of tok
+func (c *Client) compareAndSwapWith(tok CASToken, value []byte, flags uint32, opts []CallOption) error {
+	item, err := c.opts.Pipeline.encode(tok.Key, value, flags)
+	if err != nil {
+		return err
+	}
+	item.CasID = tok.CasID
+	o := newCallOptions(opts)
+	if o.hasTTL {
+		item.Expiration = ttlSeconds(o.ttl)
+	} else if item.Expiration, err = c.currentExpiration(tok.Key); err != nil {
+		return err
+	}
+	return c.runCtx(context.Background(), o.timeout, func() error {
+		return c.CompareAndSwap(item)
+	})
+}
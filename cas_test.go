@@ -0,0 +1,54 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestCompareAndSwapTyped(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	i := UnicodeItem("cas_unicode", "one")
+	i.Expiration = 100
+	mc.Set(i)
+
+	s, tok, ok := mc.GetsString("cas_unicode")
+	if !ok || s != "one" || tok.CasID == 0 || tok.Flags != FLAG_PICKLE {
+		t.Fatalf("Expected one with a token got %q %+v %v", s, tok, ok)
+	}
+	if err := mc.CompareAndSwapString(tok, "two"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mc.Get("cas_unicode")
+	if err != nil || got.Flags != FLAG_PICKLE {
+		t.Fatalf("Expected the pickle flag kept got %v %v", got, err)
+	}
+	if s, _ := (&Item{got}).String(); s != "two" {
+		t.Errorf("Expected two got %q", s)
+	}
+	if ttl, err := mc.remainingTTL(context.Background(), "cas_unicode"); err != nil || ttl < 99 {
+		t.Errorf("Expected the TTL carried forward got %d %v", ttl, err)
+	}
+	// the token is stale once the value changed
+	if err := mc.CompareAndSwapString(tok, "three"); err != memcache.ErrCASConflict {
+		t.Errorf("Expected ErrCASConflict got %v", err)
+	}
+
+	mc.Set(&memcache.Item{Key: "cas_long", Value: []byte("5"), Flags: FLAG_LONG})
+	n, tok, ok := mc.GetsInt64("cas_long")
+	if !ok || n != 5 {
+		t.Fatalf("Expected 5 got %d %v", n, ok)
+	}
+	if err := mc.CompareAndSwapInt64(tok, n+1, WithTTLOverride(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := mc.Get("cas_long"); err != nil || got.Flags != FLAG_LONG || string(got.Value) != "6" {
+		t.Errorf("Expected a long 6 got %v %v", got, err)
+	}
+
+	mc.Delete("cas_long")
+	if err := mc.CompareAndSwapInt64(tok, 7); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package memcache
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// ErrChaos is the error injected by Chaos
+var ErrChaos = errors.New("memcache: injected chaos error")
+
+// ChaosConfig controls the faults injected by Chaos
+type ChaosConfig struct {
+	// Enabled turns fault injection on. When false Chaos passes operations through.
+	Enabled bool `json:"enabled"`
+	// Latency is added before every operation, plus a random amount up to LatencyJitter
+	Latency       time.Duration `json:"latency"`
+	LatencyJitter time.Duration `json:"latency_jitter"`
+	// ErrorRate is the fraction (0-1) of operations failing with ErrChaos
+	ErrorRate float64 `json:"error_rate"`
+	// CorruptRate is the fraction (0-1) of read values returned with corrupted bytes
+	CorruptRate float64 `json:"corrupt_rate"`
+}
+
+// Chaos is a Cacher that injects latency, errors and value corruption into the
+// operations of the wrapped Cacher, for cache failure game days. Its settings can
+// be changed at runtime with SetConfig or through an AdminHandler.
+type Chaos struct {
+	Cacher
+
+	mu   sync.RWMutex
+	cfg  ChaosConfig
+	rand func() float64
+}
+
+var _ Cacher = (*Chaos)(nil)
+
+// NewChaos returns a Chaos wrapping c with fault injection disabled
+func NewChaos(c Cacher) *Chaos {
+	return &Chaos{Cacher: c, rand: rand.Float64}
+}
+
+// Config returns the current fault injection settings
+func (ch *Chaos) Config() ChaosConfig {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.cfg
+}
+
+// SetConfig replaces the fault injection settings
+func (ch *Chaos) SetConfig(cfg ChaosConfig) {
+	ch.mu.Lock()
+	ch.cfg = cfg
+	ch.mu.Unlock()
+}
+
+// inject applies latency and returns ErrChaos for the configured fraction of operations
+func (ch *Chaos) inject() (ChaosConfig, error) {
+	cfg := ch.Config()
+	if !cfg.Enabled {
+		return cfg, nil
+	}
+	if d := cfg.Latency + time.Duration(ch.rand()*float64(cfg.LatencyJitter)); d > 0 {
+		time.Sleep(d)
+	}
+	if cfg.ErrorRate > 0 && ch.rand() < cfg.ErrorRate {
+		return cfg, ErrChaos
+	}
+	return cfg, nil
+}
+
+// corrupt returns a copy of i with a damaged value for the configured fraction of reads
+func (ch *Chaos) corrupt(cfg ChaosConfig, i *memcache.Item) *memcache.Item {
+	if !cfg.Enabled || cfg.CorruptRate <= 0 || ch.rand() >= cfg.CorruptRate {
+		return i
+	}
+	damaged := *i
+	damaged.Value = make([]byte, len(i.Value)/2, len(i.Value)/2+1)
+	copy(damaged.Value, i.Value)
+	for n := range damaged.Value {
+		damaged.Value[n] ^= 0xff
+	}
+	damaged.Value = append(damaged.Value, 0xff)
+	return &damaged
+}
+
+// The Cacher methods below inject faults before calling the wrapped Cacher.
+
+func (ch *Chaos) Get(key string) (*memcache.Item, error) {
+	cfg, err := ch.inject()
+	if err != nil {
+		return nil, err
+	}
+	i, err := ch.Cacher.Get(key)
+	if err != nil {
+		return i, err
+	}
+	return ch.corrupt(cfg, i), nil
+}
+
+func (ch *Chaos) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	cfg, err := ch.inject()
+	if err != nil {
+		return nil, err
+	}
+	m, err := ch.Cacher.GetMulti(keys)
+	for k, i := range m {
+		m[k] = ch.corrupt(cfg, i)
+	}
+	return m, err
+}
+
+func (ch *Chaos) Set(item *memcache.Item) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.Set(item)
+}
+
+func (ch *Chaos) Add(item *memcache.Item) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.Add(item)
+}
+
+func (ch *Chaos) Replace(item *memcache.Item) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.Replace(item)
+}
+
+func (ch *Chaos) CompareAndSwap(item *memcache.Item) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.CompareAndSwap(item)
+}
+
+func (ch *Chaos) Delete(key string) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.Delete(key)
+}
+
+func (ch *Chaos) Touch(key string, seconds int32) error {
+	if _, err := ch.inject(); err != nil {
+		return err
+	}
+	return ch.Cacher.Touch(key, seconds)
+}
+
+func (ch *Chaos) Increment(key string, delta uint64) (uint64, error) {
+	if _, err := ch.inject(); err != nil {
+		return 0, err
+	}
+	return ch.Cacher.Increment(key, delta)
+}
+
+func (ch *Chaos) Decrement(key string, delta uint64) (uint64, error) {
+	if _, err := ch.inject(); err != nil {
+		return 0, err
+	}
+	return ch.Cacher.Decrement(key, delta)
+}
+
+func (ch *Chaos) GetString(k string, _ ...CallOption) (string, bool)   { return getString(ch, k) }
+func (ch *Chaos) GetInt64(k string, _ ...CallOption) (int64, bool)     { return getInt64(ch, k) }
+func (ch *Chaos) GetBool(k string, _ ...CallOption) (bool, bool)       { return getBool(ch, k) }
+func (ch *Chaos) GetFloat64(k string, _ ...CallOption) (float64, bool) { return getFloat64(ch, k) }
+func (ch *Chaos) GetMap(k string, _ ...CallOption) (map[string]interface{}, bool) {
+	return getMap(ch, k)
+}
+func (ch *Chaos) GetBigInt(k string, _ ...CallOption) (*big.Int, bool) {
+	return getBigInt(ch, k)
+}
+func (ch *Chaos) GetBytes(k string, _ ...CallOption) ([]byte, bool)   { return getBytes(ch, k) }
+func (ch *Chaos) GetTime(k string, _ ...CallOption) (time.Time, bool) { return getTime(ch, k) }
+func (ch *Chaos) GetDecimal(k string, _ ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(ch, k)
+}
+func (ch *Chaos) GetAny(k string, _ ...CallOption) (interface{}, Type, bool) {
+	return getAny(ch, k, deserializeItem)
+}
@@ -0,0 +1,52 @@
+package memcache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChaos(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	ch := NewChaos(mc)
+	ch.rand = func() float64 { return 0.5 }
+
+	if err := ch.Set(StringItem("chaos", "value")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := ch.GetString("chaos"); !ok || v != "value" {
+		t.Errorf("Expected passthrough while disabled, got: %q", v)
+	}
+
+	ch.SetConfig(ChaosConfig{Enabled: true, ErrorRate: 0.6})
+	if err := ch.Set(StringItem("chaos", "value")); err != ErrChaos {
+		t.Errorf("Expected ErrChaos, got: %v", err)
+	}
+	if _, ok := ch.GetString("chaos"); ok {
+		t.Errorf("Expected injected error to fail the typed getter")
+	}
+
+	ch.SetConfig(ChaosConfig{Enabled: true, CorruptRate: 0.6})
+	i, err := ch.Get("chaos")
+	if err != nil || string(i.Value) == "value" {
+		t.Errorf("Expected corrupted value, got: %q %v", i.Value, err)
+	}
+	if i, _ := mc.Get("chaos"); string(i.Value) != "value" {
+		t.Errorf("corruption must not modify the stored value")
+	}
+}
+
+func TestAdminHandlerChaos(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	h := NewAdminHandler(mc)
+	h.Chaos = NewChaos(mc)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/?chaos", strings.NewReader(`{"enabled":true,"error_rate":0.25}`)))
+	if w.Code != 200 {
+		t.Fatalf("unexpected status %d %s", w.Code, w.Body)
+	}
+	if cfg := h.Chaos.Config(); !cfg.Enabled || cfg.ErrorRate != 0.25 {
+		t.Errorf("Expected chaos settings to be applied, got: %+v", cfg)
+	}
+}
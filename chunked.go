@@ -0,0 +1,125 @@
+package memcache
+
+import (
+	"errors"
+	"hash/crc32"
+	"net/url"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// FLAG_CHUNKED marks the manifest of a value split across keys by
+// Options.ChunkSize
+const FLAG_CHUNKED uint32 = 1 << 19
+
+// MetricChunkedReads counts chunked values read, tagged by result: "hit" or
+// "incomplete" (a chunk was evicted or overwritten, which reads as a miss)
+const MetricChunkedReads = "memcache.chunked.reads"
+
+// MetricChunkedWrites counts values Set in chunks
+const MetricChunkedWrites = "memcache.chunked.writes"
+
+// maxChunks bounds the chunks a manifest may name, so a corrupt one can't make
+// a read fetch an unbounded number of keys
+const maxChunks = 1 << 16
+
+// chunkKey returns the key of chunk n of key
+func chunkKey(key string, n int) string {
+	return key + "." + strconv.Itoa(n)
+}
+
+// chunkSet wraps set, writing an item to the servers, so values longer than
+// Options.ChunkSize are written as chunks under key.0 to key.N-1, each with
+// the item's expiration, then a manifest under key flagged FLAG_CHUNKED with
+// the value's own flags. The manifest holds the number of chunks, the value's
+// size and its CRC-32 (IEEE), e.g. "chunks=3&crc32=2591183329&size=2500000".
+// Python readers get the manifest and chunks raw and reassemble them with
+//
+//	meta = urllib.parse.parse_qs(manifest.decode())
+//	keys = ['%s.%d' % (key, n) for n in range(int(meta['chunks'][0]))]
+//	chunks = get_multi_raw(keys)
+//	value = b''.join(chunks[k] for k in keys)  # a missing chunk is a miss
+//	if len(value) != int(meta['size'][0]) or zlib.crc32(value) != int(meta['crc32'][0]):
+//	    value = None  # chunks of another write: a miss
+//	flags &= ~(1 << 19)
+func (c *Client) chunkSet(set func(*memcache.Item) error) func(*memcache.Item) error {
+	return func(item *memcache.Item) error {
+		size := c.opts.ChunkSize
+		if len(item.Value) <= size {
+			return set(item)
+		}
+		n := 0
+		for off := 0; off < len(item.Value); off += size {
+			end := off + size
+			if end > len(item.Value) {
+				end = len(item.Value)
+			}
+			if err := set(&memcache.Item{Key: chunkKey(item.Key, n), Value: item.Value[off:end], Expiration: item.Expiration}); err != nil {
+				return err
+			}
+			n++
+		}
+		manifest := url.Values{
+			"chunks": {strconv.Itoa(n)},
+			"size":   {strconv.Itoa(len(item.Value))},
+			"crc32":  {strconv.FormatUint(uint64(crc32.ChecksumIEEE(item.Value)), 10)},
+		}
+		c.opts.Metrics.Count(MetricChunkedWrites, 1, nil)
+		return set(&memcache.Item{Key: item.Key, Value: []byte(manifest.Encode()), Flags: item.Flags | FLAG_CHUNKED, Expiration: item.Expiration})
+	}
+}
+
+// unchunk reassembles a value read as a FLAG_CHUNKED manifest from its chunks.
+// A missing or mismatched chunk is a miss. Items without the flag are
+// returned as they are.
+func (c *Client) unchunk(i *memcache.Item, err error) (*memcache.Item, error) {
+	if err != nil || i.Flags&FLAG_CHUNKED == 0 {
+		return i, err
+	}
+	q, err := url.ParseQuery(string(i.Value))
+	if err != nil {
+		return nil, errors.New("memcache: corrupt chunked value manifest")
+	}
+	n, nerr := strconv.Atoi(q.Get("chunks"))
+	size, serr := strconv.Atoi(q.Get("size"))
+	sum, cerr := strconv.ParseUint(q.Get("crc32"), 10, 32)
+	if nerr != nil || serr != nil || cerr != nil || n < 0 || n > maxChunks || size < 0 {
+		return nil, errors.New("memcache: corrupt chunked value manifest")
+	}
+	keys := make([]string, n)
+	for k := range keys {
+		keys[k] = chunkKey(i.Key, k)
+	}
+	chunks, err := c.getMultiFull(keys)
+	if err != nil {
+		return nil, err
+	}
+	// the buffer is sized by the chunks read rather than trusting the manifest
+	var total int
+	for _, k := range keys {
+		ci, ok := chunks[k]
+		if !ok {
+			c.opts.Metrics.Count(MetricChunkedReads, 1, map[string]string{"result": "incomplete"})
+			return nil, memcache.ErrCacheMiss
+		}
+		total += len(ci.Value)
+	}
+	if total != size {
+		c.opts.Metrics.Count(MetricChunkedReads, 1, map[string]string{"result": "incomplete"})
+		return nil, memcache.ErrCacheMiss
+	}
+	value := make([]byte, 0, total)
+	for _, k := range keys {
+		value = append(value, chunks[k].Value...)
+	}
+	if crc32.ChecksumIEEE(value) != uint32(sum) {
+		c.opts.Metrics.Count(MetricChunkedReads, 1, map[string]string{"result": "incomplete"})
+		return nil, memcache.ErrCacheMiss
+	}
+	c.opts.Metrics.Count(MetricChunkedReads, 1, map[string]string{"result": "hit"})
+	// a copy keeps the manifest's cas id
+	cp := *i
+	cp.Value, cp.Flags = value, i.Flags&^FLAG_CHUNKED
+	return &cp, nil
+}
@@ -0,0 +1,79 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestChunkSize(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{ChunkSize: 10, Metrics: metrics})
+	raw := NewClient([]string{LocalAddress})
+	value := strings.Repeat("0123456789", 3) + "abcde"
+
+	if err := mc.SetString("chunked_key", value); err != nil {
+		t.Fatal(err)
+	}
+	i, err := raw.Client.Get("chunked_key")
+	if err != nil || i.Flags != FLAG_NONE|FLAG_CHUNKED || string(i.Value) != "chunks=4&crc32=2036001847&size=35" {
+		t.Errorf("Expected a manifest, got %v %v", i, err)
+	}
+	if i, err := raw.Client.Get("chunked_key.3"); err != nil || string(i.Value) != "abcde" {
+		t.Errorf("Expected the last chunk, got %v %v", i, err)
+	}
+	if s, ok := mc.GetString("chunked_key"); !ok || s != value {
+		t.Errorf("Expected the value reassembled, got %q %v", s, ok)
+	}
+	if s, ok := raw.GetString("chunked_key"); !ok || s != value {
+		t.Errorf("Expected clients without ChunkSize to reassemble it, got %q %v", s, ok)
+	}
+	m, err := mc.GetMulti([]string{"chunked_key"})
+	if err != nil || m["chunked_key"] == nil || string(m["chunked_key"].Value) != value {
+		t.Errorf("Expected GetMulti to reassemble it, got %v %v", m, err)
+	}
+
+	// short values aren't chunked
+	mc.SetString("chunked_short", "short")
+	if i, err := raw.Client.Get("chunked_short"); err != nil || i.Flags != FLAG_NONE {
+		t.Errorf("Expected a plain value, got %v %v", i, err)
+	}
+
+	// a lost chunk is a miss
+	raw.Delete("chunked_key.1")
+	if _, err := mc.Get("chunked_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss, got %v", err)
+	}
+	if m, err := mc.GetMulti([]string{"chunked_key", "chunked_short"}); err != nil || len(m) != 1 {
+		t.Errorf("Expected GetMulti to miss it, got %v %v", m, err)
+	}
+	if n := metrics.get(MetricChunkedWrites); n != 1 {
+		t.Errorf("Expected 1 chunked write, got %d", n)
+	}
+
+	// soft TTL envelopes are chunked with the value
+	if err := mc.SetSoftTTL(StringItem("chunked_soft", value), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("chunked_soft"); !ok || s != value {
+		t.Errorf("Expected the soft TTL value reassembled, got %q %v", s, ok)
+	}
+}
+
+func TestChunkedCorruptManifest(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	for _, manifest := range []string{
+		"chunks=1&size=9223372036854775807&crc32=1",
+		"chunks=99999999&size=10&crc32=1",
+		"chunks=-1&size=0&crc32=1",
+		"chunks=x",
+	} {
+		mc.Client.Set(&memcache.Item{Key: "chunked_corrupt", Value: []byte(manifest), Flags: FLAG_CHUNKED})
+		mc.Client.Set(&memcache.Item{Key: chunkKey("chunked_corrupt", 0), Value: []byte("x")})
+		if _, err := mc.Get("chunked_corrupt"); err == nil {
+			t.Errorf("Expected an error for manifest %q", manifest)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the time source used for client side TTL logic so tests can control
+// the passage of time instead of sleeping
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper is implemented by Clocks that also control waiting, such as
+// FakeClock, so backoffs such as RetryPolicy's follow the clock rather than
+// taking real time. Sleep returns ctx.Err() if ctx is done first.
+type Sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// now returns the current time according to the configured Clock
+func (c *Client) now() time.Time {
+	return c.opts.Clock.Now()
+}
+
+// sleep waits d on the configured Clock, returning ctx.Err() if ctx is done first
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	if s, ok := c.opts.Clock.(Sleeper); ok {
+		return s.Sleep(ctx, d)
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FakeClock is a Clock that only moves when told to. It is safe for concurrent use.
+type FakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewFakeClock returns a FakeClock starting at t
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{t: t}
+}
+
+// Now returns the fake current time
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+// Advance moves the fake clock forward by d
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.t = f.t.Add(d)
+	f.mu.Unlock()
+}
+
+// Sleep advances the fake clock by d without waiting, unless ctx is done
+func (f *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.Advance(d)
+	return nil
+}
+
+// Set moves the fake clock to t
+func (f *FakeClock) Set(t time.Time) {
+	f.mu.Lock()
+	f.t = t
+	f.mu.Unlock()
+}
@@ -0,0 +1,20 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Clock: clock})
+
+	if now := mc.now(); !now.Equal(start) {
+		t.Errorf("Expected %v, got: %v", start, now)
+	}
+	clock.Advance(time.Hour)
+	if now := mc.now(); !now.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected %v, got: %v", start.Add(time.Hour), now)
+	}
+}
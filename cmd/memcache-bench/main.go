@@ -0,0 +1,150 @@
+// memcache-bench generates load against memcached using memcache_pycompat so
+// cluster sizing can be validated with the same serialization services use.
+//
+//	memcache-bench -servers=10.0.0.1:11211,10.0.0.2:11211 -read-ratio=0.9 -distribution=zipf -value-type=pickle
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	pycompat "github.com/jehiah/memcache_pycompat"
+)
+
+type result struct {
+	gets, sets   []time.Duration
+	hits, misses int64
+	errors       int64
+}
+
+func main() {
+	servers := flag.String("servers", "127.0.0.1:11211", "comma separated list of memcached servers")
+	duration := flag.Duration("duration", 10*time.Second, "how long to run")
+	concurrency := flag.Int("concurrency", 16, "number of concurrent workers")
+	keys := flag.Int("keys", 100000, "number of distinct keys")
+	readRatio := flag.Float64("read-ratio", 0.9, "fraction of operations that are reads")
+	distribution := flag.String("distribution", "uniform", "key distribution: uniform or zipf")
+	zipfS := flag.Float64("zipf-s", 1.1, "zipf skew (s > 1)")
+	valueType := flag.String("value-type", "string", "value type: string, pickle or compressed")
+	valueSize := flag.Int("value-size", 100, "value size in bytes")
+	timeout := flag.Duration("timeout", 500*time.Millisecond, "socket timeout")
+	flag.Parse()
+
+	newItem, err := itemBuilder(*valueType, *valueSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *distribution != "uniform" && *distribution != "zipf" {
+		log.Fatalf("unknown distribution %q", *distribution)
+	}
+
+	mc := pycompat.NewClient(strings.Split(*servers, ","))
+	mc.Timeout = *timeout
+	mc.MaxIdleConns = *concurrency
+
+	results := make([]result, *concurrency)
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(r *result, seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			nextKey := func() uint64 { return uint64(rnd.Int63n(int64(*keys))) }
+			if *distribution == "zipf" {
+				zipf := rand.NewZipf(rnd, *zipfS, 1, uint64(*keys-1))
+				nextKey = zipf.Uint64
+			}
+			for time.Now().Before(deadline) {
+				key := "bench:" + strconv.FormatUint(nextKey(), 10)
+				start := time.Now()
+				if rnd.Float64() < *readRatio {
+					_, err := mc.Get(key)
+					r.gets = append(r.gets, time.Since(start))
+					switch err {
+					case nil:
+						r.hits++
+					case memcache.ErrCacheMiss:
+						r.misses++
+					default:
+						r.errors++
+					}
+				} else {
+					err := mc.Set(newItem(key))
+					r.sets = append(r.sets, time.Since(start))
+					if err != nil {
+						r.errors++
+					}
+				}
+			}
+		}(&results[w], time.Now().UnixNano()+int64(w))
+	}
+	wg.Wait()
+
+	var total result
+	for _, r := range results {
+		total.gets = append(total.gets, r.gets...)
+		total.sets = append(total.sets, r.sets...)
+		total.hits += r.hits
+		total.misses += r.misses
+		total.errors += r.errors
+	}
+	ops := len(total.gets) + len(total.sets)
+	fmt.Printf("servers=%s duration=%s concurrency=%d keys=%d distribution=%s value=%s/%dB\n",
+		*servers, *duration, *concurrency, *keys, *distribution, *valueType, *valueSize)
+	fmt.Printf("ops=%d (%.0f/s) errors=%d\n", ops, float64(ops)/duration.Seconds(), total.errors)
+	if reads := total.hits + total.misses; reads > 0 {
+		fmt.Printf("hit ratio=%.3f\n", float64(total.hits)/float64(reads))
+	}
+	report("get", total.gets)
+	report("set", total.sets)
+}
+
+// itemBuilder returns a func creating items of the requested value type
+func itemBuilder(valueType string, size int) (func(key string) *memcache.Item, error) {
+	value := strings.Repeat("x", size)
+	switch valueType {
+	case "string":
+		return func(key string) *memcache.Item { return pycompat.StringItem(key, value) }, nil
+	case "pickle":
+		return func(key string) *memcache.Item { return pycompat.UnicodeItem(key, value) }, nil
+	case "compressed":
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		w.Write([]byte(value))
+		w.Close()
+		compressed := b.Bytes()
+		return func(key string) *memcache.Item {
+			return &memcache.Item{Key: key, Value: compressed, Flags: pycompat.FLAG_ZLIB}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown value type %q", valueType)
+}
+
+// report prints latency percentiles for samples
+func report(op string, samples []time.Duration) {
+	if len(samples) == 0 {
+		return
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(p float64) time.Duration {
+		i := int(float64(len(samples))*p+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		return samples[i]
+	}
+	fmt.Fprintf(os.Stdout, "%s: n=%d p50=%s p95=%s p99=%s max=%s\n",
+		op, len(samples), at(0.50), at(0.95), at(0.99), samples[len(samples)-1])
+}
@@ -0,0 +1,388 @@
+// memcache-cli is a REPL for operating memcached clusters shared with pylibmc
+// services: values are written and read with the same serialization and keys
+// are placed with the same ketama ring. Commands are read from stdin, so it can
+// also be scripted:
+//
+//	echo 'set-int counts:1 5
+//	get counts:1' | memcache-cli -servers=10.0.0.1:11211,10.0.0.2:11211
+//
+// A single command can be given as arguments instead:
+//
+//	memcache-cli -servers=10.0.0.1:11211,10.0.0.2:11211 which-server counts:1
+//
+// Run it and type help for the commands.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	pycompat "github.com/jehiah/memcache_pycompat"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+const usage = `commands:
+  get <key>                      print the decoded value and its python type
+  set <key> <value>              store value as a str (raw bytes)
+  set-int <key> <n>              store an int
+  set-unicode <key> <text>       store text as a pickled unicode string
+  set-pickle-json <key> <json>   store a JSON value pickled (objects as dicts)
+  delete <key>
+  which-server <key>             print the server the ring places key on
+  copy-to <servers> [prefix]     copy every item (or those under prefix) to
+                                 a comma separated list of servers, keeping
+                                 values on the destination
+  stats [server]                 print the stats of every server, or one
+  watch <interval> <count> <stat>...
+                                 print stats of every server count times
+  help
+  quit`
+
+type cli struct {
+	mc      *pycompat.Client
+	servers []string
+	timeout time.Duration
+	// expiration is the expiration of items set
+	expiration int32
+	out        io.Writer
+}
+
+func main() {
+	servers := flag.String("servers", "127.0.0.1:11211", "comma separated list of memcached servers")
+	timeout := flag.Duration("timeout", 500*time.Millisecond, "socket timeout")
+	expiration := flag.Int("expiration", 0, "expiration in seconds of items set (0 never expires)")
+	flag.Parse()
+
+	addrs := strings.Split(*servers, ",")
+	mc := pycompat.NewClient(addrs)
+	mc.Timeout = *timeout
+	c := &cli{
+		mc:         mc,
+		servers:    addrs,
+		timeout:    *timeout,
+		expiration: int32(*expiration),
+		out:        os.Stdout,
+	}
+
+	if flag.NArg() > 0 {
+		// each argument is a token as the shell quoted it, so values keep
+		// their spaces
+		if err := c.exec(flag.Arg(0), flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	interactive := false
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
+	failed := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if interactive {
+			fmt.Fprint(os.Stdout, "> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			break
+		}
+		if err := c.run(line); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			failed = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if failed && !interactive {
+		os.Exit(1)
+	}
+}
+
+// run runs one command line
+func (c *cli) run(line string) error {
+	cmd, rest, _ := strings.Cut(line, " ")
+	// key and value commands take the rest of the line as the value
+	key, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	var args []string
+	for _, arg := range []string{key, strings.TrimSpace(value)} {
+		if arg != "" {
+			args = append(args, arg)
+		}
+	}
+	return c.exec(cmd, args)
+}
+
+// exec runs cmd with its arguments. Values are the arguments after the key
+// joined with spaces.
+func (c *cli) exec(cmd string, args []string) error {
+	var key, value string
+	if len(args) > 0 {
+		key, value = args[0], strings.Join(args[1:], " ")
+	}
+	needKey := func() error {
+		if key == "" {
+			return fmt.Errorf("%s needs a key", cmd)
+		}
+		return nil
+	}
+
+	switch cmd {
+	case "help":
+		fmt.Fprintln(c.out, usage)
+		return nil
+	case "get":
+		if err := needKey(); err != nil {
+			return err
+		}
+		return c.get(key)
+	case "set", "set-int", "set-unicode", "set-pickle-json":
+		if err := needKey(); err != nil {
+			return err
+		}
+		item, err := c.item(cmd, key, value)
+		if err != nil {
+			return err
+		}
+		item.Expiration = c.expiration
+		if err := c.mc.Set(item); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "STORED")
+		return nil
+	case "delete":
+		if err := needKey(); err != nil {
+			return err
+		}
+		if err := c.mc.Delete(key); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "DELETED")
+		return nil
+	case "which-server":
+		if err := needKey(); err != nil {
+			return err
+		}
+		addr, err := c.mc.ServerForKey(key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, addr)
+		return nil
+	case "stats":
+		servers := c.servers
+		if key != "" {
+			servers = []string{key}
+		}
+		for _, server := range servers {
+			stats, err := c.stats(server)
+			if err != nil {
+				return fmt.Errorf("%s: %w", server, err)
+			}
+			names := make([]string, 0, len(stats))
+			for name := range stats {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(c.out, "%s %s %s\n", server, name, stats[name])
+			}
+		}
+		return nil
+	case "watch":
+		return c.watch(strings.Fields(strings.Join(args, " ")))
+	case "copy-to":
+		if key == "" {
+			return errors.New("copy-to needs destination servers")
+		}
+		return c.copyTo(strings.Split(key, ","), value)
+	}
+	return fmt.Errorf("unknown command %q (try help)", cmd)
+}
+
+// get prints the decoded value of key
+func (c *cli) get(key string) error {
+	item, err := c.mc.Get(key)
+	if err != nil {
+		return err
+	}
+	v, typ, err := (&pycompat.Item{Item: item}).Any()
+	if err != nil {
+		return fmt.Errorf("flags %d: %w", item.Flags, err)
+	}
+	switch v := v.(type) {
+	case string:
+		fmt.Fprintf(c.out, "%s %q\n", typ, v)
+	case []byte:
+		fmt.Fprintf(c.out, "%s %q\n", typ, v)
+	default:
+		fmt.Fprintf(c.out, "%s %v\n", typ, v)
+	}
+	return nil
+}
+
+// item returns the item a set command stores
+func (c *cli) item(cmd, key, value string) (*memcache.Item, error) {
+	switch cmd {
+	case "set-int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", value)
+		}
+		return pycompat.Int64Item(key, n), nil
+	case "set-unicode":
+		return pycompat.UnicodeItem(key, value), nil
+	case "set-pickle-json":
+		d := json.NewDecoder(strings.NewReader(value))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		v, err := pythonValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return pycompat.PickleItem(key, v)
+	}
+	return pycompat.StringItem(key, value), nil
+}
+
+// pythonValue converts decoded JSON for pickling, with integral numbers as ints
+// rather than floats
+func pythonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, ok := new(big.Int).SetString(string(v), 10); ok {
+			if n.IsInt64() {
+				return n.Int64(), nil
+			}
+			return n, nil
+		}
+		return v.Float64()
+	case []interface{}:
+		for n, e := range v {
+			var err error
+			if v[n], err = pythonValue(e); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			var err error
+			if v[k], err = pythonValue(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// copyTo copies the items under prefix to servers
+func (c *cli) copyTo(servers []string, prefix string) error {
+	dst := pycompat.NewClient(servers)
+	dst.Timeout = c.timeout
+	res, err := c.mc.CopyTo(context.Background(), dst, pycompat.CopyOptions{
+		Prefix: prefix,
+		Report: func(key string, err error) {
+			fmt.Fprintf(c.out, "%s error: %s\n", key, err)
+		},
+	})
+	fmt.Fprintf(c.out, "scanned %d copied %d skipped %d failed %d\n", res.Scanned, res.Copied, res.Skipped, res.Failed)
+	return err
+}
+
+// watch prints the named stats of every server count times, interval apart
+func (c *cli) watch(args []string) error {
+	if len(args) < 3 {
+		return errors.New("watch needs an interval, a count and stat names")
+	}
+	interval, err := time.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid interval %q", args[0])
+	}
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count < 1 {
+		return fmt.Errorf("invalid count %q", args[1])
+	}
+	names := args[2:]
+	for n := 0; n < count; n++ {
+		if n > 0 {
+			time.Sleep(interval)
+		}
+		now := time.Now().Format("15:04:05")
+		for _, server := range c.servers {
+			stats, err := c.stats(server)
+			if err != nil {
+				fmt.Fprintf(c.out, "%s %s error: %s\n", now, server, err)
+				continue
+			}
+			values := make([]string, len(names))
+			for i, name := range names {
+				values[i] = name + "=" + stats[name]
+			}
+			fmt.Fprintf(c.out, "%s %s %s\n", now, server, strings.Join(values, " "))
+		}
+	}
+	return nil
+}
+
+// stats returns the general-purpose stats of server
+func (c *cli) stats(server string) (map[string]string, error) {
+	network := "tcp"
+	if path, ok := ketamacompat.SocketPath(server); ok {
+		network, server = "unix", path
+	}
+	conn, err := net.DialTimeout(network, server, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := conn.Write([]byte("stats\r\n")); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]string)
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		switch {
+		case string(line) == "END":
+			return stats, nil
+		case bytes.HasPrefix(line, []byte("STAT ")):
+			fields := strings.SplitN(string(line), " ", 3)
+			if len(fields) == 3 {
+				stats[fields[1]] = fields[2]
+			}
+		default:
+			return nil, fmt.Errorf("unexpected response %q", line)
+		}
+	}
+}
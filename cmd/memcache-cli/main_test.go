@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	pycompat "github.com/jehiah/memcache_pycompat"
+)
+
+func TestExecArgs(t *testing.T) {
+	var out bytes.Buffer
+	c := &cli{mc: pycompat.NewClient([]string{pycompat.LocalAddress}), out: &out}
+
+	// arguments keep their spaces
+	if err := c.exec("set-unicode", []string{"cli_args", "two  spaces "}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := c.exec("get", []string{"cli_args"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "str \"two  spaces \"\n" {
+		t.Errorf("Expected the value as given, got %q", got)
+	}
+
+	// a command line takes the rest of the line as the value
+	if err := c.run("set cli_args a  b"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := c.run("get cli_args"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "str \"a  b\"\n" {
+		t.Errorf("Expected the rest of the line, got %q", got)
+	}
+	if err := c.exec("get", nil); err == nil {
+		t.Error("Expected get without a key to fail")
+	}
+}
@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Codec serializes values under its own flag bits, e.g. as msgpack, gob or
+// JSON, alongside the built-in pylibmc serialization. Implementations must be
+// safe for concurrent use.
+type Codec interface {
+	// Flags returns the bits set on values the codec encodes and the mask of
+	// bits compared with them when reading: values whose flags masked by mask
+	// equal flags are decoded by the codec
+	Flags() (flags, mask uint32)
+	// Encode serializes v, returning ErrCodecSkip for values left to the next
+	// codec
+	Encode(v interface{}) ([]byte, error)
+	Decode(value []byte) (interface{}, error)
+}
+
+// ErrCodecSkip is returned by Codec.Encode for values it doesn't serialize
+var ErrCodecSkip = errors.New("memcache: value not handled by codec")
+
+// codecRegistry holds the codecs registered with RegisterCodec
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// RegisterCodec adds codec to the Pipeline serializing values written by Set
+// and the typed setters and read by Get and Pipeline.Decode. Strings, []byte,
+// bools and integers are always stored as pylibmc stores them; other values
+// are offered to the codecs in the order registered and pickled when every
+// codec skips them, so codecs should skip types Python readers expect
+// pickled. A codec can't use the pylibmc flag bits, FLAG_JSON or FLAG_CHUNKED,
+// or match the flags of another codec's values.
+func (c *Client) RegisterCodec(codec Codec) error {
+	flags, mask := codec.Flags()
+	switch {
+	case flags == 0 || flags&^mask != 0:
+		return fmt.Errorf("memcache: codec %T flags %#x outside its mask %#x", codec, flags, mask)
+	case mask&(pylibmcFlags|FLAG_JSON|FLAG_CHUNKED) != 0:
+		return fmt.Errorf("memcache: codec %T mask %#x overlaps the pylibmc, FLAG_JSON or FLAG_CHUNKED bits", codec, mask)
+	}
+	r := c.opts.Pipeline.codecs
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.codecs {
+		oflags, omask := other.Flags()
+		if flags&omask == oflags&mask {
+			return fmt.Errorf("memcache: codec %T flags %#x conflict with codec %T flags %#x", codec, flags, other, oflags)
+		}
+	}
+	r.codecs = append(r.codecs, codec)
+	return nil
+}
+
+// encode serializes v with the first codec that doesn't skip it, reporting
+// whether one did
+func (r *codecRegistry) encode(v interface{}) ([]byte, uint32, bool, error) {
+	if r == nil {
+		return nil, 0, false, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, codec := range r.codecs {
+		value, err := codec.Encode(v)
+		if err == ErrCodecSkip {
+			continue
+		}
+		flags, _ := codec.Flags()
+		return value, flags, true, err
+	}
+	return nil, 0, false, nil
+}
+
+// match returns the codec decoding values with flags, if any
+func (r *codecRegistry) match(flags uint32) Codec {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, codec := range r.codecs {
+		if f, mask := codec.Flags(); flags&mask == f {
+			return codec
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const flagJSON uint32 = 1 << 10
+
+type point struct{ X, Y int }
+
+// jsonCodec is a toy Codec storing points as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Flags() (uint32, uint32) { return flagJSON, flagJSON }
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	if _, ok := v.(point); !ok {
+		return nil, ErrCodecSkip
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(value []byte) (interface{}, error) {
+	var p point
+	err := json.Unmarshal(value, &p)
+	return p, err
+}
+
+func TestRegisterCodec(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{UnknownFlags: UnknownFlagsError})
+	if err := mc.RegisterCodec(jsonCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(mc, "codec", point{1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := mc.Get("codec"); err != nil || i.Flags != flagJSON || string(i.Value) != `{"X":1,"Y":2}` {
+		t.Errorf("unexpected stored item %v %v", i, err)
+	}
+	if p, err := Get[point](mc, "codec"); err != nil || p != (point{1, 2}) {
+		t.Errorf("unexpected point %v %v", p, err)
+	}
+	i, _ := mc.Get("codec")
+	if v, err := mc.opts.Pipeline.Decode(i); err != nil || v != (point{1, 2}) {
+		t.Errorf("unexpected Decode %v %v", v, err)
+	}
+
+	// values the codec skips keep the pylibmc serialization
+	Set(mc, "codec", []string{"a"}, 0)
+	if i, err := mc.Get("codec"); err != nil || i.Flags != FLAG_PICKLE {
+		t.Errorf("Expected a pickled list got %v %v", i, err)
+	}
+	Set(mc, "codec", "s", 0)
+	if s, ok := mc.GetString("codec"); !ok || s != "s" {
+		t.Errorf("unexpected string %q %v", s, ok)
+	}
+	// and codecs are per client
+	Set(NewClient([]string{"127.0.0.1:11211"}), "codec", map[string]interface{}{"a": 1}, 0)
+	if m, ok := mc.GetMap("codec"); !ok || m["a"] != 1 {
+		t.Errorf("unexpected map %v %v", m, ok)
+	}
+
+	for _, codec := range []Codec{
+		flagsCodec{flagJSON, flagJSON},
+		flagsCodec{flagJSON | 1<<11, flagJSON | 1<<11},
+		flagsCodec{FLAG_PICKLE, FLAG_PICKLE},
+		flagsCodec{1 << 12, 0},
+		flagsCodec{0, 1 << 12},
+	} {
+		if err := mc.RegisterCodec(codec); err == nil {
+			t.Errorf("Expected an error registering %v", codec)
+		}
+	}
+	if err := mc.RegisterCodec(flagsCodec{1 << 11, 1<<11 | flagJSON}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// flagsCodec is a Codec with the given flags encoding nothing
+type flagsCodec struct{ flags, mask uint32 }
+
+func (c flagsCodec) Flags() (uint32, uint32)                { return c.flags, c.mask }
+func (flagsCodec) Encode(v interface{}) ([]byte, error)     { return nil, ErrCodecSkip }
+func (flagsCodec) Decode(value []byte) (interface{}, error) { return nil, nil }
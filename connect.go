@@ -0,0 +1,117 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ConnectClient is NewClientWithOptions connecting to every server before
+// returning, so a bad server list fails at startup instead of on first traffic.
+// Each server is checked with ValidateConfig, has its features detected when
+// Options.DetectFeatures is set, then one connection to it is pooled.
+func ConnectClient(ctx context.Context, addresses []string, opts Options) (*Client, error) {
+	c := NewClientWithOptions(addresses, opts)
+	if err := c.ValidateConfig(ctx); err != nil {
+		return nil, err
+	}
+	if c.opts.DetectFeatures {
+		if err := c.DetectFeatures(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if c.opts.EagerConnect {
+		// NewClientWithOptions already started warming the pool
+		return c, nil
+	}
+	if err := c.Warm(ctx, 1); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ValidateConfig checks every configured server is reachable and speaks the
+// memcached protocol by requesting its version. Nothing is stored. The returned
+// error joins the failures for each server.
+func (c *Client) ValidateConfig(ctx context.Context) error {
+	addrs, err := c.servers()
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errors.New("memcache: no servers configured")
+	}
+	errs := c.checkServers(ctx, addrs)
+	for n, err := range errs {
+		if err != nil {
+			errs[n] = fmt.Errorf("memcache: %s: %w", addrs[n], err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkServers requests the version of each of addrs concurrently returning the
+// failure for each
+func (c *Client) checkServers(ctx context.Context, addrs []net.Addr) []error {
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for n, addr := range addrs {
+		wg.Add(1)
+		go func(n int, addr net.Addr) {
+			defer wg.Done()
+			errs[n] = c.withServerConn(ctx, addr, func(sc *serverConn) error {
+				if proxyCheck(sc.proxy, "version") != nil {
+					return checkWithGet(sc)
+				}
+				_, err := readVersion(sc)
+				return err
+			})
+		}(n, addr)
+	}
+	wg.Wait()
+	return errs
+}
+
+// readVersion requests the server's version on sc
+func readVersion(sc *serverConn) (string, error) {
+	if err := sc.command("version"); err != nil {
+		return "", err
+	}
+	line, err := sc.readLine()
+	if err != nil {
+		return "", err
+	}
+	version, ok := strings.CutPrefix(line, "VERSION ")
+	if !ok {
+		return "", fmt.Errorf("unexpected version response %q", line)
+	}
+	return version, nil
+}
+
+// checkWithGet checks a proxy not supporting version by getting a key that
+// shouldn't exist
+func checkWithGet(sc *serverConn) error {
+	if err := sc.command("get memcache_pycompat_check"); err != nil {
+		return err
+	}
+	for {
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "END":
+			return nil
+		case strings.HasPrefix(line, "VALUE "):
+			// skip the data line
+			if _, err := sc.readLine(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected get response %q", line)
+		}
+	}
+}
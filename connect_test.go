@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	ctx := context.Background()
+	if err := NewClient([]string{LocalAddress}).ValidateConfig(ctx); err != nil {
+		t.Errorf("Expected local server to validate, got %v", err)
+	}
+	mc := NewClientWithOptions([]string{LocalAddress, "127.0.0.1:1"}, Options{})
+	err := mc.ValidateConfig(ctx)
+	if err == nil || !strings.Contains(err.Error(), "127.0.0.1:1") || strings.Contains(err.Error(), "local") {
+		t.Errorf("Expected only the unreachable server to fail, got %v", err)
+	}
+	if err := NewClient(nil).ValidateConfig(ctx); err == nil {
+		t.Errorf("Expected error with no servers")
+	}
+}
+
+func TestConnectClient(t *testing.T) {
+	ctx := context.Background()
+	mc, err := ConnectClient(ctx, []string{LocalAddress}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := mc.PoolStats(); len(stats) != 1 || stats[0].Open != 1 {
+		t.Errorf("Expected one pooled connection, got %+v", stats)
+	}
+	if _, err := ConnectClient(ctx, []string{"127.0.0.1:1"}, Options{}); err == nil {
+		t.Errorf("Expected ConnectClient to fail for an unreachable server")
+	}
+}
+
+func TestEagerConnect(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{EagerConnect: true})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if stats := mc.PoolStats(); len(stats) == 1 && stats[0].Open == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected a connection to be made without a request, got %+v", mc.PoolStats())
+}
@@ -0,0 +1,155 @@
+package memcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// The underlying memcache.Client only knows its socket Timeout so the context
+// variants run the operation in a goroutine and return as soon as ctx is done.
+// An abandoned operation finishes (or times out) in the background and its
+// connection is not reused if it errors.
+
+// GetCtx is Get honoring ctx cancellation and deadline. When ctx has no deadline
+// Options.DefaultReadDeadline applies.
+func (c *Client) GetCtx(ctx context.Context, key string) (*memcache.Item, error) {
+	b := writeBufferFrom(ctx)
+	if b != nil {
+		if i, err, ok := b.get(key); ok {
+			return i, err
+		}
+	}
+	var i *memcache.Item
+	full := c.fullKey(key)
+	span := c.startSpan(ctx, OpGet, key, full)
+	err := c.runCtx(ctx, c.opts.DefaultReadDeadline, func() (err error) {
+		i, err = c.get(ctx, full)
+		return
+	})
+	if err != nil {
+		// i may still be written by an operation abandoned when ctx was done
+		span.read(nil, err)
+		return nil, err
+	}
+	span.read(i, nil)
+	return unprefixed(i, key), nil
+}
+
+// GetStringCtx is GetString honoring ctx cancellation and deadline. When ctx
+// has no deadline Options.DefaultReadDeadline applies.
+func (c *Client) GetStringCtx(ctx context.Context, k string) (string, bool) {
+	return getStringPolicy(c.ctxGetter(ctx), k, c.opts.Unicode)
+}
+
+// GetMultiCtx is GetMulti honoring ctx cancellation and deadline. When ctx has no
+// deadline Options.DefaultReadDeadline applies. Servers are queried concurrently
+// under the same deadline; if it passes before all of them answer the items
+// received are returned with a *DeadlineError naming the servers still pending.
+func (c *Client) GetMultiCtx(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+	var written map[string]*memcache.Item
+	if b := writeBufferFrom(ctx); b != nil {
+		written = make(map[string]*memcache.Item)
+		remaining := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if i, _, ok := b.get(k); ok {
+				if i != nil {
+					written[k] = i
+				}
+				continue
+			}
+			remaining = append(remaining, k)
+		}
+		keys = remaining
+	}
+	if _, ok := ctx.Deadline(); !ok && c.opts.DefaultReadDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.DefaultReadDeadline)
+		defer cancel()
+	}
+	m := make(map[string]*memcache.Item)
+	var err error
+	if len(keys) > 0 {
+		keys = c.dedupKeys(keys)
+		var chunks [][2]int
+		if chunks, err = c.bulkChunks(OpGetMulti, len(keys), getSize(keys)); err != nil {
+			return nil, err
+		}
+		if chunks != nil {
+			m, err = getMultiChunked(keys, chunks, func(keys []string) (map[string]*memcache.Item, error) {
+				return c.GetMultiCtx(ctx, keys)
+			})
+		} else {
+			span := c.startSpan(ctx, OpGetMulti, "", "")
+			full := c.fullKeys(keys)
+			m, err = c.softExpiryMulti(c.getMultiCtx(ctx, full))
+			m, err = c.overflowGetMulti(full, m, err)
+			m, err = c.secondaryGetMulti(full, m, err)
+			m, err = c.staleGetMulti(full, m, err)
+			span.readMulti(len(keys), m, err)
+			m = c.unprefixedMulti(keys, full, m)
+		}
+		if m == nil {
+			return nil, err
+		}
+	}
+	for k, i := range written {
+		m[k] = i
+	}
+	return m, err
+}
+
+// SetCtx is Set honoring ctx cancellation and deadline. When ctx has no deadline
+// Options.DefaultWriteDeadline applies.
+func (c *Client) SetCtx(ctx context.Context, item *memcache.Item) error {
+	full := c.prefixed(item)
+	span := c.startSpan(ctx, OpSet, item.Key, full.Key)
+	err := c.runCtx(ctx, c.opts.DefaultWriteDeadline, func() error {
+		return c.writeCtx(ctx, OpSet, full, func(i *memcache.Item) error { return c.backendSetCtx(ctx, i) })
+	})
+	span.write(item, err)
+	if b := writeBufferFrom(ctx); b != nil && err == nil {
+		b.set(item)
+	}
+	return err
+}
+
+// DeleteCtx is Delete honoring ctx cancellation and deadline. When ctx has no
+// deadline Options.DefaultWriteDeadline applies.
+func (c *Client) DeleteCtx(ctx context.Context, key string) error {
+	full := c.fullKey(key)
+	span := c.startSpan(ctx, OpDelete, key, full)
+	err := c.runCtx(ctx, c.opts.DefaultWriteDeadline, func() error {
+		return c.overflowDelete(full, c.doCtx(ctx, OpDelete, full, func() error { return c.backendDelete(ctx, full) }))
+	})
+	span.end(err)
+	if b := writeBufferFrom(ctx); b != nil && (err == nil || err == memcache.ErrCacheMiss) {
+		b.delete(key)
+	}
+	return err
+}
+
+// runCtx runs fn, returning early with ctx.Err() if ctx is done first. If ctx has
+// no deadline and fallback is non-zero, fallback is used as the timeout.
+func (c *Client) runCtx(ctx context.Context, fallback time.Duration, fn func() error) error {
+	if _, ok := ctx.Deadline(); !ok && fallback > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fallback)
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,121 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// hungServer accepts connections and never responds
+func hungServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { c.Close() })
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	mc := NewClientWithOptions([]string{hungServer(t)}, Options{
+		DefaultReadDeadline:  50 * time.Millisecond,
+		DefaultWriteDeadline: 50 * time.Millisecond,
+	})
+	mc.Timeout = 5 * time.Second
+
+	start := time.Now()
+	if _, err := mc.GetCtx(context.Background(), "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got: %v", err)
+	}
+	if err := mc.SetCtx(context.Background(), StringItem("key", "value")); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("default deadline not applied, took %s", d)
+	}
+
+	// an explicit deadline on the context wins over the default
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := mc.GetCtx(ctx, "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got: %v", err)
+	}
+	if d := time.Since(start); d > 40*time.Millisecond {
+		t.Errorf("context deadline not honored, took %s", d)
+	}
+}
+
+func TestGetSetCtx(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	ctx := context.Background()
+
+	if err := mc.SetCtx(ctx, StringItem("ctx", "value")); err != nil {
+		t.Fatal(err)
+	}
+	i, err := mc.GetCtx(ctx, "ctx")
+	if err != nil || string(i.Value) != "value" {
+		t.Errorf("Expected value, got: %v %v", i, err)
+	}
+	if err := mc.DeleteCtx(ctx, "ctx"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetStringCtx(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	if err := mc.Set(UnicodeItem("ctx_string", "caf\u00e9")); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetStringCtx(context.Background(), "ctx_string"); !ok || s != "caf\u00e9" {
+		t.Errorf("Expected caf\u00e9 got %q %v", s, ok)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s, ok := mc.GetStringCtx(ctx, "ctx_string"); ok {
+		t.Errorf("Expected a canceled context to fail got %q", s)
+	}
+}
+
+func TestGetMultiCtxDeadline(t *testing.T) {
+	hung := hungServer(t)
+	mc := NewClient([]string{LocalAddress, hung})
+	mc.Timeout = 5 * time.Second
+	probes, err := mc.serverProbeKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	localKey, hungKey := probes[LocalAddress], probes[hung]
+	if err := mc.Set(StringItem(localKey, "fast")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	m, err := mc.GetMultiCtx(ctx, []string{localKey, hungKey})
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("deadline not honored, took %s", d)
+	}
+	var de *DeadlineError
+	if !errors.As(err, &de) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a DeadlineError, got: %v", err)
+	}
+	if len(de.Servers) != 1 || de.Servers[0] != hung {
+		t.Errorf("Expected %s to be reported, got: %v", hung, de.Servers)
+	}
+	if i, ok := m[localKey]; !ok || string(i.Value) != "fast" {
+		t.Errorf("Expected the responsive server's item, got: %v", m)
+	}
+}
@@ -0,0 +1,125 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultCopyBatchSize is the number of keys read at once when
+// CopyOptions.BatchSize is unset
+const DefaultCopyBatchSize = 100
+
+// CopyOptions configures Client.CopyTo
+type CopyOptions struct {
+	// Prefix limits the copy to keys starting with it (as stored, including
+	// any Options.KeyPrefix)
+	Prefix string
+	// Overwrite replaces items already on the destination. By default items
+	// are copied with Add so values written to the destination since it went
+	// live aren't clobbered by older ones.
+	Overwrite bool
+	// BatchSize is the number of keys read from the source with each
+	// GetMulti. Defaults to DefaultCopyBatchSize.
+	BatchSize int
+	// Report is called with each key that failed to copy
+	Report func(key string, err error)
+}
+
+// CopyResult counts the items seen by Client.CopyTo
+type CopyResult struct {
+	// Scanned is the number of items enumerated on the source
+	Scanned int
+	// Copied is the number of items written to the destination
+	Copied int
+	// Skipped is the number of items that expired or were evicted before they
+	// were read, or that the destination already held
+	Skipped int
+	// Failed is the number of items passed to CopyOptions.Report
+	Failed int
+}
+
+// CopyTo copies the items on c's servers, enumerated with metadump, to dst,
+// e.g. to warm a new cluster before cutting over to it. Values and flags are
+// copied as they are and keep their expiration; items are placed on dst's
+// servers by dst's ring. Keys are copied as stored, so dst's own KeyPrefix
+// and LongKeyHash aren't applied again. Failures to read or write single items
+// are reported and counted but don't stop the copy.
+func (c *Client) CopyTo(ctx context.Context, dst *Client, opts CopyOptions) (CopyResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultCopyBatchSize
+	}
+	var res CopyResult
+	batch := make([]MetadumpEntry, 0, opts.BatchSize)
+	flush := func() {
+		c.copyBatch(dst, batch, opts, &res)
+		batch = batch[:0]
+	}
+	err := c.Metadump(ctx, func(_ net.Addr, e MetadumpEntry) error {
+		if !strings.HasPrefix(e.Key, opts.Prefix) {
+			return nil
+		}
+		res.Scanned++
+		batch = append(batch, e)
+		if len(batch) == opts.BatchSize {
+			flush()
+		}
+		return ctx.Err()
+	})
+	if err == nil {
+		flush()
+	}
+	return res, err
+}
+
+// copyBatch copies the items of entries from c to dst
+func (c *Client) copyBatch(dst *Client, entries []MetadumpEntry, opts CopyOptions, res *CopyResult) {
+	if len(entries) == 0 {
+		return
+	}
+	fail := func(key string, err error) {
+		res.Failed++
+		if opts.Report != nil {
+			opts.Report(key, err)
+		}
+	}
+	keys := make([]string, len(entries))
+	for n, e := range entries {
+		keys[n] = e.Key
+	}
+	items, err := c.Client.GetMulti(keys)
+	if err != nil {
+		for _, k := range keys {
+			fail(k, err)
+		}
+		return
+	}
+	now := c.now().Unix()
+	for _, e := range entries {
+		i, ok := items[e.Key]
+		if !ok || (e.Exp > 0 && e.Exp <= now) {
+			res.Skipped++
+			continue
+		}
+		item := &memcache.Item{Key: i.Key, Value: i.Value, Flags: i.Flags}
+		if e.Exp > 0 {
+			// an absolute unix timestamp, which memcached distinguishes from
+			// relative seconds by being over 30 days
+			item.Expiration = int32(e.Exp)
+		}
+		write := dst.Client.Add
+		if opts.Overwrite {
+			write = dst.Client.Set
+		}
+		switch err := write(item); err {
+		case nil:
+			res.Copied++
+		case memcache.ErrNotStored:
+			res.Skipped++
+		default:
+			fail(e.Key, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestCopyTo(t *testing.T) {
+	var addrs []string
+	for n := 0; n < 3; n++ {
+		s, err := newLocalServer(systemClock{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.ln.Close()
+		addrs = append(addrs, s.ln.Addr().String())
+	}
+	src := NewClient(addrs[:1])
+	dst := NewClient(addrs[1:])
+
+	src.SetInt64("copy:n", 42)
+	src.Set(&memcache.Item{Key: "copy:ttl", Value: []byte("v"), Expiration: 3600})
+	src.SetString("copy:kept", "old")
+	src.SetString("other", "x")
+	dst.SetString("copy:kept", "new")
+
+	var failed []string
+	res, err := src.CopyTo(context.Background(), dst, CopyOptions{Prefix: "copy:", BatchSize: 2, Report: func(key string, err error) {
+		failed = append(failed, key)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (CopyResult{Scanned: 3, Copied: 2, Skipped: 1}) || len(failed) != 0 {
+		t.Errorf("Unexpected result %+v (failed %v)", res, failed)
+	}
+	if n, ok := dst.GetInt64("copy:n"); !ok || n != 42 {
+		t.Errorf("Expected the int copied with its flags, got %d %v", n, ok)
+	}
+	if s, _ := dst.GetString("copy:kept"); s != "new" {
+		t.Errorf("Expected the destination's value kept, got %q", s)
+	}
+	if _, err := dst.Get("other"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected keys outside the prefix not copied, got %v", err)
+	}
+	if ttl, err := dst.remainingTTL(context.Background(), "copy:ttl"); err != nil || ttl < 3590 || ttl > 3600 {
+		t.Errorf("Expected the expiration kept, got %d %v", ttl, err)
+	}
+
+	res, err = src.CopyTo(context.Background(), dst, CopyOptions{Prefix: "copy:", Overwrite: true})
+	if err != nil || res.Copied != 3 {
+		t.Errorf("Expected every item copied with Overwrite, got %+v %v", res, err)
+	}
+	if s, _ := dst.GetString("copy:kept"); s != "old" {
+		t.Errorf("Expected the destination's value replaced, got %q", s)
+	}
+}
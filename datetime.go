@@ -0,0 +1,66 @@
+package memcache
+
+import (
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// GetTime gets a python datetime.datetime from cache returning whether or not
+// the get was successful
+func (c *Client) GetTime(k string, opts ...CallOption) (time.Time, bool) {
+	return getTime(c.getter(opts), k)
+}
+
+func getTime(c itemGetter, k string) (time.Time, bool) {
+	t, err := getDecoded(c, k, (*Item).Time)
+	return t, err == nil
+}
+
+// Time returns the compatible python datetime.datetime value. Naive datetimes
+// are returned in time.Local; tz-aware ones in a location for their tzinfo:
+// a fixed zone for datetime.timezone and localized pytz zones, and the named
+// zone for zoneinfo.ZoneInfo and other pytz zones.
+func (i *Item) Time() (time.Time, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if i.Flags != FLAG_PICKLE && !(i.Flags == FLAG_NONE && looksPickled(i.Value)) {
+		return time.Time{}, InvalidType
+	}
+	v, err := picklecompat.Decode(i.Value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, ok := v.(time.Time)
+	if !ok {
+		return time.Time{}, InvalidType
+	}
+	return t, nil
+}
+
+// DatetimeItem returns a memcache.Item storing t pickled as a python
+// datetime.datetime the way Python 3's pickle.dumps(v, 2) does. Times in
+// time.Local are stored naive, others tz-aware with a datetime.timezone of
+// their UTC offset (use t.UTC() for the aware UTC values Django stores with
+// USE_TZ). Python datetimes have microsecond precision.
+func DatetimeItem(k string, t time.Time) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(t, picklecompat.EncodeOptions{Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// DatetimeItem is DatetimeItem pickling t with Options.PickleProtocol
+func (c *Client) DatetimeItem(k string, t time.Time) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(t, picklecompat.EncodeOptions{Protocol: c.opts.PickleProtocol, Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
@@ -0,0 +1,50 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestDatetime(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 678901000, time.UTC)
+
+	// pickle.dumps(datetime(2024, 1, 2, 3, 4, 5, 678901, tzinfo=timezone.utc), 2)
+	// from python 3, as Django stores aware datetimes
+	py := "\x80\x02cdatetime\ndatetime\nq\x00c_codecs\nencode\nq\x01X\x0c\x00\x00\x00\x07\xc3\xa8\x01\x02\x03\x04\x05\n[\xc3\xb5q\x02X\x06\x00\x00\x00latin1q\x03\x86q\x04Rq\x05cdatetime\ntimezone\nq\x06cdatetime\ntimedelta\nq\x07K\x00K\x00K\x00\x87q\x08Rq\t\x85q\nRq\x0b\x86q\x0cRq\r."
+	if i := DatetimeItem("datetime", utc); string(i.Value) != py || i.Flags != FLAG_PICKLE {
+		t.Errorf("unexpected item %q %d", i.Value, i.Flags)
+	}
+	est := time.FixedZone("EST", -5*3600)
+	for _, i := range []*memcache.Item{
+		DatetimeItem("datetime", utc),
+		DatetimeItem("datetime", utc.In(est)),
+		NewClientWithOptions(nil, Options{PickleProtocol: 4}).DatetimeItem("datetime", utc),
+		{Key: "datetime", Value: []byte(py)},
+	} {
+		mc.Set(i)
+		if got, ok := mc.GetTime("datetime"); !ok || !got.Equal(utc) {
+			t.Errorf("%q: expected %v got %v %v", i.Value, utc, got, ok)
+		}
+	}
+	if got, err := Get[time.Time](mc, "datetime"); err != nil || !got.Equal(utc) {
+		t.Errorf("Get: expected %v got %v %v", utc, got, err)
+	}
+
+	// naive datetimes are local times
+	local := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	mc.Set(DatetimeItem("datetime", local))
+	if got, ok := mc.GetTime("datetime"); !ok || !got.Equal(local) || got.Location() != time.Local {
+		t.Errorf("Expected %v got %v %v", local, got, ok)
+	}
+
+	mc.Set(UnicodeItem("datetime", "2024-01-02"))
+	if _, ok := mc.GetTime("datetime"); ok {
+		t.Error("Expected a pickled str not to be read as a datetime")
+	}
+	if _, err := (&Item{Int64Item("", 1)}).Time(); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
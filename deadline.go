@@ -0,0 +1,78 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DeadlineError is returned by multi-server context operations when ctx is done
+// before every server answered. The items from servers that did answer are
+// returned along with it.
+type DeadlineError struct {
+	// Servers are the servers still pending when ctx was done, in address order
+	Servers []string
+	// Err is ctx.Err()
+	Err error
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("memcache: %v waiting on %s", e.Err, strings.Join(e.Servers, ", "))
+}
+
+func (e *DeadlineError) Unwrap() error { return e.Err }
+
+// getMultiCtx requests keys from every server concurrently under the one ctx
+// deadline so a slow server can't consume the budget of the others. When ctx
+// is done first the items received so far are returned with a *DeadlineError
+// naming the servers that hadn't answered.
+func (c *Client) getMultiCtx(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	byServer := make(map[string][]string)
+	for _, k := range keys {
+		addr, err := c.selector.PickServer(k)
+		if err != nil {
+			return nil, err
+		}
+		byServer[addr.String()] = append(byServer[addr.String()], k)
+	}
+	type result struct {
+		server string
+		items  map[string]*memcache.Item
+		err    error
+	}
+	results := make(chan result, len(byServer))
+	for server, keys := range byServer {
+		go func(server string, keys []string) {
+			items, err := c.getMulti(ctx, keys)
+			results <- result{server, items, err}
+		}(server, keys)
+	}
+	m := make(map[string]*memcache.Item, len(keys))
+	var firstErr error
+	for len(byServer) > 0 {
+		select {
+		case r := <-results:
+			delete(byServer, r.server)
+			for k, i := range r.items {
+				m[k] = i
+			}
+			if r.err != nil && firstErr == nil {
+				firstErr = r.err
+			}
+		case <-ctx.Done():
+			pending := make([]string, 0, len(byServer))
+			for server := range byServer {
+				pending = append(pending, server)
+			}
+			sort.Strings(pending)
+			return m, &DeadlineError{Servers: pending, Err: ctx.Err()}
+		}
+	}
+	return m, firstErr
+}
@@ -0,0 +1,136 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FLAG_DEBUG_ENVELOPE marks values wrapped by a DebugEnvelope
+const FLAG_DEBUG_ENVELOPE uint32 = 1 << 16
+
+// ErrNoEnvelope is returned by WhoWrote for values written without a DebugEnvelope
+var ErrNoEnvelope = errors.New("memcache: value has no debug envelope")
+
+// WriterInfo is what a DebugEnvelope records about a write
+type WriterInfo struct {
+	Host    string
+	Service string
+	At      time.Time
+}
+
+// DebugEnvelope is a Pipeline stage prefixing values with a line recording the
+// host and service that wrote them and when, to find the writer of bad values
+// on shared clusters. The line is a URL encoded query string, e.g.
+// "host=web1&service=api&at=1700000000.123\n", so Python readers can unwrap
+// values with
+//
+//	meta, _, value = raw.partition(b"\n")
+//	urllib.parse.parse_qs(meta)
+//
+// Every client reading the values, Go or Python, must strip the envelope. Get
+// and Set work on items as stored, so only the typed setters add it.
+type DebugEnvelope struct {
+	// Host is recorded as the writer's host. "" uses os.Hostname.
+	Host string
+	// Service names the writing service or job
+	Service string
+	// Now returns the write time. nil uses time.Now.
+	Now func() time.Time
+}
+
+// Encode prefixes value with the envelope line
+func (d DebugEnvelope) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&FLAG_DEBUG_ENVELOPE != 0 {
+		return value, flags, nil
+	}
+	host := d.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	now := time.Now
+	if d.Now != nil {
+		now = d.Now
+	}
+	at := float64(now().UnixNano()) / float64(time.Second)
+	meta := url.Values{
+		"host":    {host},
+		"service": {d.Service},
+		"at":      {strconv.FormatFloat(at, 'f', 3, 64)},
+	}.Encode()
+	b := make([]byte, 0, len(meta)+1+len(value))
+	b = append(b, meta...)
+	b = append(b, '\n')
+	b = append(b, value...)
+	return b, flags | FLAG_DEBUG_ENVELOPE, nil
+}
+
+// Decode strips the envelope from values with FLAG_DEBUG_ENVELOPE set
+func (DebugEnvelope) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&FLAG_DEBUG_ENVELOPE == 0 {
+		return value, flags, nil
+	}
+	_, value, err := splitEnvelope(value)
+	return value, flags &^ FLAG_DEBUG_ENVELOPE, err
+}
+
+func splitEnvelope(value []byte) (meta, rest []byte, err error) {
+	n := bytes.IndexByte(value, '\n')
+	if n < 0 {
+		return nil, nil, errors.New("memcache: corrupt debug envelope")
+	}
+	return value[:n], value[n+1:], nil
+}
+
+// parseEnvelope decodes the envelope line
+func parseEnvelope(meta []byte) (WriterInfo, error) {
+	q, err := url.ParseQuery(string(meta))
+	if err != nil {
+		return WriterInfo{}, fmt.Errorf("memcache: corrupt debug envelope: %w", err)
+	}
+	w := WriterInfo{Host: q.Get("host"), Service: q.Get("service")}
+	if at, err := strconv.ParseFloat(q.Get("at"), 64); err == nil {
+		w.At = time.UnixMilli(int64(math.Round(at * 1000)))
+	}
+	return w, nil
+}
+
+// WhoWrote returns the writer recorded in the debug envelope of key, undoing
+// the Pipeline stages applied after the DebugEnvelope stage (e.g. compression)
+// and any zlib compression to read it. ErrNoEnvelope is returned for values
+// written without one.
+func (c *Client) WhoWrote(key string) (WriterInfo, error) {
+	i, err := c.Get(key)
+	if err != nil {
+		return WriterInfo{}, err
+	}
+	value, flags := i.Value, i.Flags
+	stages := c.opts.Pipeline.ordered()
+	for n := len(stages) - 1; n >= 0; n-- {
+		if _, ok := stages[n].(DebugEnvelope); ok {
+			break
+		}
+		if value, flags, err = decodeStage(stages[n], key, value, flags); err != nil {
+			return WriterInfo{}, err
+		}
+	}
+	if flags&FLAG_ZLIB != 0 {
+		// written compressed by a client with a Compress stage this one lacks
+		if value, flags, err = (ZlibStage{}).Decode(value, flags); err != nil {
+			return WriterInfo{}, err
+		}
+	}
+	if flags&FLAG_DEBUG_ENVELOPE == 0 {
+		return WriterInfo{}, ErrNoEnvelope
+	}
+	meta, _, err := splitEnvelope(value)
+	if err != nil {
+		return WriterInfo{}, err
+	}
+	return parseEnvelope(meta)
+}
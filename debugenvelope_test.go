@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugEnvelope(t *testing.T) {
+	at := time.Date(2023, 11, 14, 22, 13, 20, 123e6, time.UTC)
+	env := DebugEnvelope{Host: "web 1", Service: "api", Now: func() time.Time { return at }}
+	b, flags, err := env.Encode([]byte("value"), FLAG_PICKLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "at=1700000000.123&host=web+1&service=api\nvalue" || flags != FLAG_PICKLE|FLAG_DEBUG_ENVELOPE {
+		t.Errorf("unexpected envelope %q %d", b, flags)
+	}
+	v, flags, err := env.Decode(b, flags)
+	if err != nil || string(v) != "value" || flags != FLAG_PICKLE {
+		t.Errorf("unexpected decode %q %d %v", v, flags, err)
+	}
+	if _, _, err := env.Decode([]byte("no newline"), FLAG_DEBUG_ENVELOPE); err == nil {
+		t.Errorf("Expected an error for a corrupt envelope")
+	}
+
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		Pipeline: Pipeline{Stages: []Stage{env}, Compress: ZlibStage{}},
+	})
+	if err := mc.SetString("debug_env", strings.Repeat("hello ", 100)); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := mc.GetString("debug_env"); s != strings.Repeat("hello ", 100) {
+		t.Errorf("unexpected value %q", s)
+	}
+	i, _ := mc.Get("debug_env")
+	if i.Flags != FLAG_ZLIB|FLAG_DEBUG_ENVELOPE {
+		t.Errorf("Expected a compressed envelope, got flags %d", i.Flags)
+	}
+	w, err := mc.WhoWrote("debug_env")
+	if err != nil || w.Host != "web 1" || w.Service != "api" || !w.At.Equal(at) {
+		t.Errorf("unexpected writer %+v %v", w, err)
+	}
+
+	mc.Set(StringItem("debug_plain", "x"))
+	if _, err := mc.WhoWrote("debug_plain"); err != ErrNoEnvelope {
+		t.Errorf("Expected ErrNoEnvelope, got %v", err)
+	}
+}
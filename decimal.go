@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// GetDecimal gets a python decimal.Decimal from cache returning whether or not
+// the get was successful
+func (c *Client) GetDecimal(k string, opts ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(c.getter(opts), k)
+}
+
+func getDecimal(c itemGetter, k string) (picklecompat.Decimal, bool) {
+	d, err := getDecoded(c, k, (*Item).Decimal)
+	return d, err == nil
+}
+
+// Decimal returns the compatible python decimal.Decimal value in its string
+// form, e.g. "12.50"; its Rat method gives the value as a *big.Rat
+func (i *Item) Decimal() (picklecompat.Decimal, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return "", err
+	}
+	if i.Flags != FLAG_PICKLE && !(i.Flags == FLAG_NONE && looksPickled(i.Value)) {
+		return "", InvalidType
+	}
+	v, err := picklecompat.Decode(i.Value)
+	if err != nil {
+		return "", err
+	}
+	d, ok := v.(picklecompat.Decimal)
+	if !ok {
+		return "", InvalidType
+	}
+	return d, nil
+}
+
+// DecimalItem returns a memcache.Item storing v, a python Decimal literal such
+// as "-12.50", pickled as decimal.Decimal(v) the way Python 3's
+// pickle.dumps(v, 2) does. A *big.Rat r can be written with
+// DecimalItem(k, r.FloatString(places)).
+func DecimalItem(k string, v string) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(picklecompat.Decimal(v), picklecompat.EncodeOptions{Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// DecimalItem is DecimalItem pickling v with Options.PickleProtocol
+func (c *Client) DecimalItem(k string, v string) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(picklecompat.Decimal(v), picklecompat.EncodeOptions{Protocol: c.opts.PickleProtocol, Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+func TestDecimal(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+
+	// pickle.dumps(Decimal("-12.50"), 2) from python 3
+	py := "\x80\x02cdecimal\nDecimal\nq\x00X\x06\x00\x00\x00-12.50q\x01\x85q\x02Rq\x03."
+	if i := DecimalItem("decimal", "-12.50"); string(i.Value) != py || i.Flags != FLAG_PICKLE {
+		t.Errorf("unexpected item %q %d", i.Value, i.Flags)
+	}
+	for _, i := range []*memcache.Item{
+		DecimalItem("decimal", "-12.50"),
+		NewClientWithOptions(nil, Options{PickleProtocol: 4}).DecimalItem("decimal", "-12.50"),
+		// pickle.dumps(Decimal("-12.50"), 2) from python 2
+		{Key: "decimal", Value: []byte("\x80\x02cdecimal\nDecimal\nq\x01U\x06-12.50\x85Rq\x02."), Flags: FLAG_PICKLE},
+	} {
+		mc.Set(i)
+		if d, ok := mc.GetDecimal("decimal"); !ok || d != "-12.50" {
+			t.Errorf("%q: expected -12.50 got %q %v", i.Value, d, ok)
+		}
+	}
+	if d, ok := mc.GetDecimal("decimal"); !ok {
+		t.Error("Expected a decimal")
+	} else if r, ok := d.Rat(); !ok || r.Cmp(big.NewRat(-25, 2)) != 0 {
+		t.Errorf("unexpected Rat %v %v", r, ok)
+	}
+	if d, err := Get[picklecompat.Decimal](mc, "decimal"); err != nil || d != "-12.50" {
+		t.Errorf("Get: unexpected %q %v", d, err)
+	}
+
+	mc.Set(UnicodeItem("decimal", "12.50"))
+	if _, ok := mc.GetDecimal("decimal"); ok {
+		t.Error("Expected a pickled str not to be read as a decimal")
+	}
+	if _, err := (&Item{Int64Item("", 1)}).Decimal(); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
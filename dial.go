@@ -0,0 +1,140 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultMaxReconnectBackoff caps the reconnect backoff when Options.MaxReconnectBackoff is unset
+const DefaultMaxReconnectBackoff = 30 * time.Second
+
+// ErrReconnectBackoff is returned instead of dialing a server whose recent dials
+// failed until its reconnect backoff has elapsed
+var ErrReconnectBackoff = errors.New("memcache: server in reconnect backoff")
+
+// dial is installed as the memcache.Client DialContext. Every new connection to a
+// server goes through here.
+func (c *Client) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if c.backoff != nil {
+		if err := c.backoff.check(address, c.now()); err != nil {
+			return nil, err
+		}
+	}
+	if c.reconnects != nil {
+		if wait := c.reconnects.reserve(); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				c.reconnects.cancel()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	var nc net.Conn
+	var err error
+	if address == LocalAddress {
+		nc, err = dialLocal(ctx)
+	} else if address == disabledAddress {
+		nc = dialDisabled()
+	} else {
+		nc, err = c.dialer()(ctx, network, address)
+	}
+	if err == nil && c.opts.Username != "" {
+		if err = c.authenticate(ctx, nc); err != nil {
+			nc.Close()
+			nc = nil
+		}
+	}
+	if c.backoff != nil {
+		c.backoff.record(address, err, c.now())
+	}
+	return c.conns.dialed(address, nc, err), err
+}
+
+// dialer returns the configured dial function
+func (c *Client) dialer() dialFunc {
+	var base dialFunc
+	if c.opts.DialContext != nil {
+		base = c.opts.DialContext
+	} else {
+		d := net.Dialer{KeepAlive: c.opts.KeepAlive}
+		base = d.DialContext
+	}
+	if c.opts.AddressFamily != PreferSystem {
+		return dialDualStack(base, c.opts.AddressFamily, c.opts.FallbackDelay)
+	}
+	return base
+}
+
+// reconnectBackoff tracks consecutive dial failures per server and delays
+// redialing with capped exponential backoff and jitter
+type reconnectBackoff struct {
+	mu      sync.Mutex
+	base    time.Duration
+	max     time.Duration
+	servers map[string]*backoffState
+}
+
+type backoffState struct {
+	failures int
+	retryAt  time.Time
+}
+
+func newReconnectBackoff(base, max time.Duration) *reconnectBackoff {
+	return &reconnectBackoff{
+		base:    base,
+		max:     max,
+		servers: make(map[string]*backoffState),
+	}
+}
+
+// check returns ErrReconnectBackoff if address may not be dialed yet
+func (b *reconnectBackoff) check(address string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.servers[address]; ok && now.Before(s.retryAt) {
+		return ErrReconnectBackoff
+	}
+	return nil
+}
+
+// record updates the backoff state for address with the result of a dial
+func (b *reconnectBackoff) record(address string, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.servers, address)
+		return
+	}
+	s, ok := b.servers[address]
+	if !ok {
+		s = &backoffState{}
+		b.servers[address] = s
+	}
+	s.failures++
+	s.retryAt = now.Add(b.delay(s.failures))
+}
+
+// delay returns the backoff after the given number of consecutive failures: base
+// doubled per failure, capped at max, with up to half of it randomized so a fleet
+// of clients doesn't redial in lockstep
+func (b *reconnectBackoff) delay(failures int) time.Duration {
+	d := b.base
+	for i := 1; i < failures && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
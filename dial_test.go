@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	mc := NewClientWithOptions([]string{closedAddr(t)}, Options{
+		Clock:               clock,
+		ReconnectBackoff:    time.Second,
+		MaxReconnectBackoff: 4 * time.Second,
+	})
+
+	if _, err := mc.Get("key"); err == nil || err == ErrReconnectBackoff {
+		t.Fatalf("Expected dial error, got: %v", err)
+	}
+	if _, err := mc.Get("key"); err != ErrReconnectBackoff {
+		t.Errorf("Expected ErrReconnectBackoff, got: %v", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := mc.Get("key"); err == ErrReconnectBackoff {
+		t.Errorf("Expected redial after backoff elapsed")
+	}
+}
+
+func TestReconnectBackoffDelay(t *testing.T) {
+	b := newReconnectBackoff(time.Second, 4*time.Second)
+	for failures, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 4 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if d := b.delay(failures); d < max/2 || d > max {
+				t.Fatalf("delay after %d failures %s outside [%s, %s]", failures, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	b := newTokenBucket(clock, 10, 2)
+	if !b.allow() || !b.allow() {
+		t.Fatal("Expected burst of 2 to be allowed")
+	}
+	if b.allow() {
+		t.Error("Expected bucket to be empty")
+	}
+	if wait := b.reserve(); wait != 100*time.Millisecond {
+		t.Errorf("Expected to wait 100ms, got: %s", wait)
+	}
+	clock.Advance(200 * time.Millisecond)
+	if !b.allow() {
+		t.Error("Expected refill after 200ms")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memcached.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	s := &localServer{ln: ln, clock: systemClock{}, items: make(map[string]*localItem)}
+	go s.serve()
+	defer ln.Close()
+
+	for _, server := range []string{path, "unix://" + path} {
+		mc := NewClient([]string{server})
+		addr, err := mc.ServerForKey("sock")
+		if err != nil || addr.Network() != "unix" || addr.String() != path {
+			t.Fatalf("%s: expected the unix address %s got %v %v", server, path, addr, err)
+		}
+		if err := mc.Set(Int64Item("sock", 5)); err != nil {
+			t.Fatalf("%s: %s", server, err)
+		}
+		if n, ok := mc.GetInt64("sock"); !ok || n != 5 {
+			t.Errorf("%s: expected 5 got %d %v", server, n, ok)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// ErrNoField is returned by DictGetField when the dict has no such field
+var ErrNoField = errors.New("memcache: dict field not found")
+
+// DefaultDictRetries is how many times DictSetField retries after losing a CAS race
+const DefaultDictRetries = 10
+
+// DictGetField returns one field of the pickled Python dict stored at key. Nested
+// containers are returned as the gopickle types (*types.Dict, *types.List...).
+func (c *Client) DictGetField(key, field string) (interface{}, error) {
+	i, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeDict(i.Value, i.Flags)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := d.Get(field)
+	if !ok {
+		return nil, ErrNoField
+	}
+	return v, nil
+}
+
+// DictSetField sets one field of the pickled Python dict stored at key with
+// gets/cas, leaving other fields (including ones written concurrently by Python)
+// intact. The dict is created if key doesn't exist. value has the same type
+// restrictions as PushEvent's event.
+func (c *Client) DictSetField(key, field string, value interface{}) error {
+	if _, err := picklecompat.Encode(value); err != nil {
+		return err
+	}
+	return c.upsert(key, func(old []byte, flags uint32) ([]byte, uint32, error) {
+		d, err := decodeDict(old, flags)
+		if err != nil {
+			return nil, 0, err
+		}
+		dictSet(d, field, value)
+		b, err := c.pickle(d)
+		return b, FLAG_PICKLE, err
+	}, map[string]interface{}{field: value}, DefaultDictRetries)
+}
+
+// Map returns the entries of a pickled python dict. Values (and keys, such as
+// tuples) are decoded as by picklecompat.Decode, so containers are the gopickle
+// types. Use StringMap for dicts with str keys.
+func (i *Item) Map() (map[interface{}]interface{}, error) {
+	d, err := i.dict()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[interface{}]interface{}, d.Len())
+	for _, e := range *d {
+		m[e.Key] = e.Value
+	}
+	return m, nil
+}
+
+// StringMap is Map for dicts whose keys are all strings
+func (i *Item) StringMap() (map[string]interface{}, error) {
+	d, err := i.dict()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, d.Len())
+	for _, e := range *d {
+		k, ok := e.Key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: dict key %T isn't a string", InvalidType, e.Key)
+		}
+		m[k] = e.Value
+	}
+	return m, nil
+}
+
+// dict decodes a pickled dict, including one stored without the pickle flag
+func (i *Item) dict() (*types.Dict, error) {
+	return decodeDict(i.Value, i.Flags)
+}
+
+// GetMap returns a python dict with str keys
+func (c *Client) GetMap(k string, opts ...CallOption) (map[string]interface{}, bool) {
+	return getMap(c.getter(opts), k)
+}
+
+func getMap(c itemGetter, k string) (map[string]interface{}, bool) {
+	m, err := getDecoded(c, k, (*Item).StringMap)
+	return m, err == nil
+}
+
+// MapItem returns a memcache.Item storing m as a python dict pickled with
+// protocol 2 the way pylibmc does, with keys in sorted order. Values may be the
+// types picklecompat.Encode supports; anything else is an error.
+func MapItem(k string, m map[string]interface{}) (*memcache.Item, error) {
+	return PickleItem(k, m)
+}
+
+// decodeDict decodes a pickled dict, inflating it first when pylibmc
+// compressed it
+func decodeDict(value []byte, flags uint32) (*types.Dict, error) {
+	value, flags, err := inflatePickle(value, flags)
+	if err != nil {
+		return nil, err
+	}
+	if flags != FLAG_PICKLE {
+		return nil, InvalidType
+	}
+	v, err := unpickle(value)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := v.(*types.Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected dict got %T", InvalidType, v)
+	}
+	return d, nil
+}
+
+// dictSet sets key in d replacing an existing entry (types.Dict.Set always appends)
+func dictSet(d *types.Dict, key string, value interface{}) {
+	for n, e := range *d {
+		if k, ok := e.Key.(string); ok && k == key {
+			(*d)[n].Value = value
+			return
+		}
+	}
+	d.Set(key, value)
+}
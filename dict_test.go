@@ -0,0 +1,144 @@
+package memcache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestDictFields(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	// {'name': 'jehiah', 'visits': 1} as pickled by python with protocol 2
+	py := "\x80\x02}q\x00(X\x04\x00\x00\x00nameq\x01X\x06\x00\x00\x00jehiahq\x02X\x06\x00\x00\x00visitsq\x03K\x01u."
+	mc.Set(&memcache.Item{Key: "dict", Value: []byte(py), Flags: FLAG_PICKLE})
+
+	if v, err := mc.DictGetField("dict", "name"); err != nil || v != "jehiah" {
+		t.Errorf("unexpected field %v %v", v, err)
+	}
+	if _, err := mc.DictGetField("dict", "missing"); err != ErrNoField {
+		t.Errorf("Expected ErrNoField, got: %v", err)
+	}
+	if err := mc.DictSetField("dict", "visits", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.DictSetField("dict", "admin", true); err != nil {
+		t.Fatal(err)
+	}
+	i, _ := mc.Get("dict")
+	d, err := decodeDict(i.Value, i.Flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 3 || d.MustGet("visits") != 2 || d.MustGet("admin") != true || d.MustGet("name") != "jehiah" {
+		t.Errorf("unexpected dict %v", d)
+	}
+
+	mc.Delete("dict_new")
+	if err := mc.DictSetField("dict_new", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := mc.DictGetField("dict_new", "a"); err != nil || v != "b" {
+		t.Errorf("Expected dict to be created, got: %v %v", v, err)
+	}
+}
+
+func TestDictFieldsCompressed(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	// a dict large enough for pylibmc to compress
+	item, err := PickleItem("dict_zlib", map[string]interface{}{"name": strings.Repeat("jehiah", 100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Value, item.Flags, err = (ZlibStage{MinCompressLen: 1}).Encode(item.Value, item.Flags); err != nil || item.Flags != FLAG_PICKLE|FLAG_ZLIB {
+		t.Fatalf("Expected a compressed dict, got flags %d %v", item.Flags, err)
+	}
+	mc.Set(item)
+
+	if v, err := mc.DictGetField("dict_zlib", "name"); err != nil || v != strings.Repeat("jehiah", 100) {
+		t.Errorf("unexpected field %v %v", v, err)
+	}
+	if err := mc.DictSetField("dict_zlib", "visits", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := mc.DictGetField("dict_zlib", "visits"); err != nil || v != 2 {
+		t.Errorf("Expected the field set, got %v %v", v, err)
+	}
+}
+
+func TestDictSetFieldConcurrent(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("dict_concurrent")
+	fields := []string{"a", "b", "c", "d", "e", "f"}
+	var wg sync.WaitGroup
+	for _, f := range fields {
+		wg.Add(1)
+		go func(f string) {
+			defer wg.Done()
+			if err := mc.DictSetField("dict_concurrent", f, f); err != nil {
+				t.Error(err)
+			}
+		}(f)
+	}
+	wg.Wait()
+	i, err := mc.Get("dict_concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := unpickle(i.Value)
+	if d := v.(*types.Dict); d.Len() != len(fields) {
+		t.Errorf("Expected every field to be kept, got: %v", d)
+	}
+}
+
+func TestItem_Map(t *testing.T) {
+	// {(1, 2): 't', 'k': 3} as pickled by python with protocol 2
+	py := "\x80\x02}q\x00(K\x01K\x02\x86q\x01X\x01\x00\x00\x00tq\x02X\x01\x00\x00\x00kq\x03K\x03u."
+	i := &Item{&memcache.Item{Value: []byte(py), Flags: FLAG_PICKLE}}
+	m, err := i.Map()
+	if err != nil || len(m) != 2 || m["k"] != 3 {
+		t.Errorf("unexpected map %v %v", m, err)
+	}
+	for k, v := range m {
+		if tup, ok := k.(*types.Tuple); ok && (tup.Len() != 2 || v != "t") {
+			t.Errorf("unexpected tuple entry %v: %v", k, v)
+		}
+	}
+	if _, err := i.StringMap(); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a tuple key, got %v", err)
+	}
+	if _, err := (&Item{Int64Item("", 1)}).Map(); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
+
+func TestMapItem(t *testing.T) {
+	i, err := MapItem("map", map[string]interface{}{"b": []interface{}{1, "x"}, "a": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pickletools.optimize(pickle.dumps({'a': None, 'b': [1, 'x']}, 2))
+	if want := "\x80\x02}(X\x01\x00\x00\x00aNX\x01\x00\x00\x00b](K\x01X\x01\x00\x00\x00xeu."; string(i.Value) != want || i.Flags != FLAG_PICKLE {
+		t.Errorf("MapItem = %q %d, want %q", i.Value, i.Flags, want)
+	}
+	if _, err := MapItem("map", map[string]interface{}{"bad": struct{}{}}); err == nil {
+		t.Errorf("Expected an error for an unsupported value")
+	}
+
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(i)
+	m, ok := mc.GetMap("map")
+	if !ok || len(m) != 2 || m["a"] != nil {
+		t.Fatalf("unexpected map %v %v", m, ok)
+	}
+	if l, _ := m["b"].(*types.List); l == nil || l.Len() != 2 || l.Get(1) != "x" {
+		t.Errorf("unexpected list %#v", m["b"])
+	}
+	mc.Set(StringItem("map", "x"))
+	if _, ok := mc.GetMap("map"); ok {
+		t.Errorf("Expected a string not to decode as a map")
+	}
+}
@@ -0,0 +1,34 @@
+package memcache
+
+import (
+	"net"
+	"sync"
+)
+
+// disabledAddress is the server address of clients from NewDisabledClient
+const disabledAddress = "disabled"
+
+var (
+	disabledOnce   sync.Once
+	disabledServer *localServer
+)
+
+// NewDisabledClient returns a client for running with caching turned off, e.g.
+// behind a feature flag or where there's no memcached, without checking for a
+// nil client around every call. Every read misses and every write, delete and
+// touch succeeds without storing anything; increments and decrements miss as
+// they do for missing keys. Nothing goes over the network: the client talks to
+// an embedded server (see LocalAddress) over in-memory connections.
+func NewDisabledClient() *Client {
+	return NewClient([]string{disabledAddress})
+}
+
+// dialDisabled connects to the process wide embedded server discarding writes
+func dialDisabled() net.Conn {
+	disabledOnce.Do(func() {
+		disabledServer = &localServer{clock: systemClock{}, items: make(map[string]*localItem), discard: true}
+	})
+	client, server := net.Pipe()
+	go disabledServer.handle(server)
+	return client
+}
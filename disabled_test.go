@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestDisabledClient(t *testing.T) {
+	mc := NewDisabledClient()
+	if err := mc.SetString("disabled", "v"); err != nil {
+		t.Fatalf("Expected writes to succeed, got %v", err)
+	}
+	if _, ok := mc.GetString("disabled"); ok {
+		t.Error("Expected a miss after set")
+	}
+	if _, err := mc.Get("disabled"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+	if err := mc.Add(StringItem("disabled", "v")); err != nil {
+		t.Errorf("Expected add to succeed, got %v", err)
+	}
+	if err := mc.Replace(StringItem("disabled", "v")); err != nil {
+		t.Errorf("Expected replace to succeed, got %v", err)
+	}
+	if err := mc.Delete("disabled"); err != nil {
+		t.Errorf("Expected delete to succeed, got %v", err)
+	}
+	if err := mc.Touch("disabled", 60); err != nil {
+		t.Errorf("Expected touch to succeed, got %v", err)
+	}
+	if _, err := mc.Increment("disabled_n", 1); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected increment to miss, got %v", err)
+	}
+	items, err := mc.GetMulti([]string{"a", "b"})
+	if err != nil || len(items) != 0 {
+		t.Errorf("Expected no items, got %v %v", items, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mc.ValidateConfig(ctx); err != nil {
+		t.Errorf("Expected the disabled client to validate, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricDryRunSkipped counts mutating operations validated but not sent under
+// Options.DryRun, tagged by op
+const MetricDryRunSkipped = "memcache.dry_run.skipped"
+
+// DryRunRecord describes a mutating operation skipped under Options.DryRun
+type DryRunRecord struct {
+	At     time.Time
+	Op     string // e.g. "set", "delete" or "flush_all"
+	Key    string // "" for server commands such as flush_all
+	Server string // the server the operation would have been sent to
+	// Size, Flags and Expiration are those of the item for item writes
+	Size       int
+	Flags      uint32
+	Expiration int32
+}
+
+// dryRunOps are the operations through do that modify the cache; item writes
+// are skipped in write before reaching do
+var dryRunOps = map[string]bool{
+	OpDelete:    true,
+	OpTouch:     true,
+	OpIncrement: true,
+	OpDecrement: true,
+}
+
+// dryRun validates a mutating operation for key (or item) the way sending it
+// would, then records and counts it instead
+func (c *Client) dryRun(op, key string, item *memcache.Item) error {
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return err
+	}
+	r := DryRunRecord{Op: op, Key: c.sanitizeKey(key)}
+	if item != nil {
+		r.Size, r.Flags, r.Expiration = len(item.Value), item.Flags, item.Expiration
+	}
+	c.recordDryRun(r, addr)
+	return nil
+}
+
+// dryRunCommand records a server command such as flush_all instead of sending it
+func (c *Client) dryRunCommand(addr net.Addr, command string) {
+	op := command
+	if i := strings.IndexByte(command, ' '); i >= 0 {
+		op = command[:i]
+	}
+	c.recordDryRun(DryRunRecord{Op: op}, addr)
+}
+
+func (c *Client) recordDryRun(r DryRunRecord, addr net.Addr) {
+	r.At = c.now()
+	r.Server = addr.String()
+	c.opts.Metrics.Count(MetricDryRunSkipped, 1, map[string]string{"op": r.Op})
+	if c.opts.OnDryRun != nil {
+		c.opts.OnDryRun(r)
+	}
+}
@@ -0,0 +1,57 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestDryRun(t *testing.T) {
+	live := NewClient([]string{LocalAddress})
+	live.Set(StringItem("dry", "before"))
+
+	metrics := &countingMetrics{}
+	var records []DryRunRecord
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		DryRun:   true,
+		Metrics:  metrics,
+		OnDryRun: func(r DryRunRecord) { records = append(records, r) },
+	})
+	if err := mc.Set(&memcache.Item{Key: "dry", Value: []byte("after"), Flags: 1, Expiration: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Delete("dry"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Increment("dry_n", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Set(StringItem("bad key", "x")); err != memcache.ErrMalformedKey {
+		t.Errorf("Expected ErrMalformedKey, got %v", err)
+	}
+	if s, _ := mc.GetString("dry"); s != "before" {
+		t.Errorf("Expected reads to see the unmodified value, got %q", s)
+	}
+
+	admin, _ := NewAdminClient(mc, AdminOptions{Actor: "rehearsal", Allow: []AdminOp{AdminFlushAll}})
+	if err := admin.FlushAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := live.Get("dry"); err != nil {
+		t.Errorf("Expected dry run flush not to be sent, got %v", err)
+	}
+
+	if n := metrics.get(MetricDryRunSkipped); n != 4 {
+		t.Errorf("Expected 4 skipped operations, got %d", n)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %+v", records)
+	}
+	if r := records[0]; r.Op != OpSet || r.Key != "dry" || r.Size != 5 || r.Flags != 1 || r.Expiration != 60 || r.Server != LocalAddress || r.At.IsZero() {
+		t.Errorf("Unexpected set record %+v", r)
+	}
+	if r := records[3]; r.Op != "flush_all" || r.Key != "" || r.Server != LocalAddress {
+		t.Errorf("Unexpected flush record %+v", r)
+	}
+}
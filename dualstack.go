@@ -0,0 +1,143 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultFallbackDelay is how long the preferred address family is given before
+// racing the other family when Options.FallbackDelay is unset (RFC 8305 suggests 250ms)
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// AddressFamily selects which address family is dialed first for hostnames that
+// resolve to both IPv4 and IPv6 addresses
+type AddressFamily int
+
+const (
+	// PreferSystem leaves address ordering and fallback to net.Dialer
+	PreferSystem AddressFamily = iota
+	// PreferIPv6 dials IPv6 addresses first, racing IPv4 after the fallback delay
+	PreferIPv6
+	// PreferIPv4 dials IPv4 addresses first, racing IPv6 after the fallback delay
+	PreferIPv4
+)
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dialDualStack returns a dialFunc that resolves hostnames itself and races the
+// preferred and fallback address families with base ("happy eyeballs"). The
+// server's identity for hashing remains the configured hostname; only the dialed
+// IP varies.
+func dialDualStack(base dialFunc, prefer AddressFamily, delay time.Duration) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil || (network != "tcp" && network != "") {
+			return base(ctx, network, address)
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		primary, fallback := partitionFamilies(addrs, prefer)
+		if len(primary) == 0 {
+			primary, fallback = fallback, nil
+		}
+		if len(fallback) == 0 {
+			return dialSerial(ctx, base, network, primary, port)
+		}
+		return dialRace(ctx, base, network, primary, fallback, port, delay)
+	}
+}
+
+// partitionFamilies splits addrs into the preferred family and the other family
+func partitionFamilies(addrs []net.IPAddr, prefer AddressFamily) (primary, fallback []net.IP) {
+	var v4, v6 []net.IP
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			v4 = append(v4, a.IP)
+		} else {
+			v6 = append(v6, a.IP)
+		}
+	}
+	if prefer == PreferIPv4 {
+		return v4, v6
+	}
+	return v6, v4
+}
+
+// dialSerial dials each ip in turn returning the first successful connection
+func dialSerial(ctx context.Context, base dialFunc, network string, ips []net.IP, port string) (net.Conn, error) {
+	err := errors.New("memcache: no addresses to dial")
+	for _, ip := range ips {
+		var nc net.Conn
+		nc, err = base(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return nc, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// dialRace dials primary immediately and fallback after delay (or as soon as
+// primary fails) returning the first connection established and closing the other
+func dialRace(ctx context.Context, base dialFunc, network string, primary, fallback []net.IP, port string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		nc  net.Conn
+		err error
+	}
+	results := make(chan result)
+	returned := make(chan struct{})
+	defer close(returned)
+	start := func(ips []net.IP) {
+		go func() {
+			nc, err := dialSerial(ctx, base, network, ips, port)
+			select {
+			case results <- result{nc, err}:
+			case <-returned:
+				if nc != nil {
+					nc.Close()
+				}
+			}
+		}()
+	}
+
+	start(primary)
+	pending := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	fallbackStarted := false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallback)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.nc, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallback)
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPartitionFamilies(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}, {IP: net.ParseIP("10.0.0.1")}}
+	primary, fallback := partitionFamilies(addrs, PreferIPv4)
+	if len(primary) != 2 || len(fallback) != 1 || primary[0].String() != "127.0.0.1" {
+		t.Errorf("unexpected IPv4 preference %v %v", primary, fallback)
+	}
+	primary, fallback = partitionFamilies(addrs, PreferIPv6)
+	if len(primary) != 1 || len(fallback) != 2 || primary[0].String() != "::1" {
+		t.Errorf("unexpected IPv6 preference %v %v", primary, fallback)
+	}
+}
+
+func TestDialRaceFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var d net.Dialer
+	// nothing listens on 127.0.0.2 so the preferred family fails and the fallback wins
+	nc, err := dialRace(context.Background(), d.DialContext, "tcp",
+		[]net.IP{net.ParseIP("127.0.0.2")}, []net.IP{net.ParseIP("127.0.0.1")}, port, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	if nc.RemoteAddr().String() != ln.Addr().String() {
+		t.Errorf("Expected fallback connection to %s, got: %s", ln.Addr(), nc.RemoteAddr())
+	}
+}
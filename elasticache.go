@@ -0,0 +1,172 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDiscoveryInterval is how often NewElastiCacheClient polls the
+// configuration endpoint when Options.DiscoveryInterval is unset, as AWS's
+// auto-discovery clients do
+const DefaultDiscoveryInterval = time.Minute
+
+// MetricDiscoveryErrors counts failed polls of an ElastiCache configuration
+// endpoint
+const MetricDiscoveryErrors = "memcache.discovery.errors"
+
+// ClusterConfig is an ElastiCache cluster's node list, as returned by its
+// configuration endpoint
+type ClusterConfig struct {
+	// Version increases with every change to the nodes
+	Version int
+	// Nodes are "host:port", hashed as Options.DiscoveryByHostname selects
+	Nodes []string
+}
+
+// discovery polls an ElastiCache configuration endpoint, applying node list
+// changes with SetServers
+type discovery struct {
+	endpoint string
+	interval time.Duration
+
+	mu      sync.Mutex
+	version int
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewElastiCacheClient returns a client for the nodes of the ElastiCache
+// memcached cluster behind the configuration endpoint ("name.cfg.use1.cache.amazonaws.com:11211"),
+// polling it every Options.DiscoveryInterval to follow nodes being added,
+// removed or replaced. Nodes are placed on the ketama ring by IP address like
+// django-elasticache (and so pylibmc clients configured by it) place them, or
+// by hostname under Options.DiscoveryByHostname. Close stops the polling.
+func NewElastiCacheClient(ctx context.Context, endpoint string, opts Options) (*Client, error) {
+	c := NewClientWithOptions(nil, opts)
+	d := &discovery{endpoint: endpoint, interval: c.opts.DiscoveryInterval, stop: make(chan struct{})}
+	if err := c.discover(ctx, d); err != nil {
+		return nil, err
+	}
+	c.discovery = d
+	go c.pollDiscovery(d)
+	return c, nil
+}
+
+// pollDiscovery rediscovers the nodes every interval until d is closed
+func (c *Client) pollDiscovery(d *discovery) {
+	t := time.NewTicker(d.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), d.interval)
+			if err := c.discover(ctx, d); err != nil {
+				c.opts.Metrics.Count(MetricDiscoveryErrors, 1, nil)
+			}
+			cancel()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// close stops polling
+func (d *discovery) close() {
+	d.once.Do(func() { close(d.stop) })
+}
+
+// discover reads the cluster config from d's endpoint, switching to its nodes
+// when its version is new
+func (c *Client) discover(ctx context.Context, d *discovery) error {
+	config, err := c.ClusterConfig(ctx, d.endpoint)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.version != 0 && config.Version <= d.version {
+		return nil
+	}
+	if err := c.SetServers(config.Nodes); err != nil {
+		return err
+	}
+	d.version = config.Version
+	return nil
+}
+
+// ClusterConfig reads the node list of an ElastiCache configuration endpoint
+// with "config get cluster", or the "AmazonElastiCache:cluster" key of engines
+// before 1.4.14
+func (c *Client) ClusterConfig(ctx context.Context, endpoint string) (*ClusterConfig, error) {
+	var config *ClusterConfig
+	err := c.withServerConn(ctx, newHostAddress(endpoint), func(sc *serverConn) error {
+		if err := sc.command("config get cluster"); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "ERROR" {
+			if err := sc.command("get AmazonElastiCache:cluster"); err != nil {
+				return err
+			}
+			if line, err = sc.readLine(); err != nil {
+				return err
+			}
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 || (fields[0] != "CONFIG" && fields[0] != "VALUE") {
+			return fmt.Errorf("memcache: unexpected cluster config response from %s: %q", endpoint, line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil || size < 0 {
+			return fmt.Errorf("memcache: unexpected cluster config response from %s: %q", endpoint, line)
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(sc.rw, data); err != nil {
+			return err
+		}
+		if end, err := sc.readLine(); err != nil || end != "END" {
+			return fmt.Errorf("memcache: unterminated cluster config from %s: %q %v", endpoint, end, err)
+		}
+		config, err = parseClusterConfig(string(data[:size]), c.opts.DiscoveryByHostname)
+		if err != nil {
+			return fmt.Errorf("memcache: cluster config from %s: %w", endpoint, err)
+		}
+		return nil
+	})
+	return config, err
+}
+
+// parseClusterConfig parses a config version line followed by a line of
+// space separated "hostname|ip|port" nodes
+func parseClusterConfig(data string, byHostname bool) (*ClusterConfig, error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("expected a version and node line, got %q", data)
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q", lines[0])
+	}
+	config := &ClusterConfig{Version: version}
+	for _, node := range strings.Fields(lines[1]) {
+		parts := strings.Split(node, "|")
+		if len(parts) != 3 || parts[2] == "" {
+			return nil, fmt.Errorf("invalid node %q", node)
+		}
+		host := parts[1]
+		if host == "" || byHostname {
+			host = parts[0]
+		}
+		config.Nodes = append(config.Nodes, host+":"+parts[2])
+	}
+	return config, nil
+}
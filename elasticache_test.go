@@ -0,0 +1,111 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// configEndpoint serves the cluster config returned by config, answering
+// "config get cluster" or, when legacy is set, only the pre 1.4.14 key
+func configEndpoint(t *testing.T, legacy bool, config func() string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data := config()
+					switch {
+					case line == "config get cluster\r\n" && !legacy:
+						fmt.Fprintf(c, "CONFIG cluster 0 %d\r\n%s\r\nEND\r\n", len(data), data)
+					case line == "get AmazonElastiCache:cluster\r\n":
+						fmt.Fprintf(c, "VALUE AmazonElastiCache:cluster 0 %d\r\n%s\r\nEND\r\n", len(data), data)
+					default:
+						io.WriteString(c, "ERROR\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestElastiCacheClient(t *testing.T) {
+	var mu sync.Mutex
+	config := "12\nnode1.cache.amazonaws.com|10.0.0.1|11211 node2.cache.amazonaws.com|10.0.0.2|11211\n"
+	endpoint := configEndpoint(t, false, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return config
+	})
+
+	mc, err := NewElastiCacheClient(context.Background(), endpoint, Options{DiscoveryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+	if want := []string{"10.0.0.1:11211", "10.0.0.2:11211"}; !reflect.DeepEqual(mc.Servers(), want) {
+		t.Errorf("Expected nodes %v got %v", want, mc.Servers())
+	}
+
+	// a stale version is ignored, a new one applied
+	mu.Lock()
+	config = "11\nnode9.cache.amazonaws.com|10.0.0.9|11211\n"
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if len(mc.Servers()) != 2 {
+		t.Errorf("Expected an older config to be ignored, got %v", mc.Servers())
+	}
+	mu.Lock()
+	config = "13\nnode1.cache.amazonaws.com|10.0.0.1|11211 node3.cache.amazonaws.com|10.0.0.3|11211\n"
+	mu.Unlock()
+	eventually(t, func() error {
+		if want := []string{"10.0.0.1:11211", "10.0.0.3:11211"}; !reflect.DeepEqual(mc.Servers(), want) {
+			return fmt.Errorf("expected nodes %v got %v", want, mc.Servers())
+		}
+		return nil
+	})
+}
+
+func TestClusterConfig(t *testing.T) {
+	endpoint := configEndpoint(t, true, func() string {
+		return "3\nnode1.cache.amazonaws.com||11211 node2.cache.amazonaws.com|10.0.0.2|11212\n"
+	})
+	mc := NewClientWithOptions(nil, Options{DiscoveryByHostname: true})
+	config, err := mc.ClusterConfig(context.Background(), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ClusterConfig{Version: 3, Nodes: []string{"node1.cache.amazonaws.com:11211", "node2.cache.amazonaws.com:11212"}}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Expected %+v got %+v", want, config)
+	}
+
+	if _, err := parseClusterConfig("1\nnode1|10.0.0.1\n", false); err == nil || !strings.Contains(err.Error(), "invalid node") {
+		t.Errorf("Expected an invalid node error, got: %v", err)
+	}
+	if _, err := NewElastiCacheClient(context.Background(), closedAddr(t), Options{}); err == nil {
+		t.Error("Expected an error for an unreachable endpoint")
+	}
+}
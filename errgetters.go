@@ -0,0 +1,86 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrCacheMiss is returned by the E getters for keys that aren't cached. It is
+// memcache.ErrCacheMiss, so either can be tested for.
+var ErrCacheMiss = memcache.ErrCacheMiss
+
+// ErrWrongType is wrapped by the errors of the E getters for values that were
+// read but aren't of the requested type or fail to decode. It is InvalidType,
+// so either can be tested for.
+var ErrWrongType = InvalidType
+
+// GetStringE is GetString returning why it failed: ErrCacheMiss,
+// ErrCachedNone, an error wrapping ErrWrongType, or an error wrapping the
+// network error reading k
+func (c *Client) GetStringE(k string, opts ...CallOption) (string, error) {
+	return getE(c.getter(opts), k, func(i *Item) (string, error) { return i.StringPolicy(c.opts.Unicode) })
+}
+
+// GetInt64E is GetInt64 returning why it failed, as GetStringE does
+func (c *Client) GetInt64E(k string, opts ...CallOption) (int64, error) {
+	return getE(c.getter(opts), k, (*Item).Int64)
+}
+
+// GetFloat64E is GetFloat64 returning why it failed, as GetStringE does
+func (c *Client) GetFloat64E(k string, opts ...CallOption) (float64, error) {
+	return getE(c.getter(opts), k, (*Item).Float64)
+}
+
+// GetBoolE is GetBool returning why it failed, as GetStringE does
+func (c *Client) GetBoolE(k string, opts ...CallOption) (bool, error) {
+	return getE(c.getter(opts), k, (*Item).Bool)
+}
+
+// getE is getDecoded sorting its errors into misses, cached Nones, values of
+// the wrong type and failed reads
+func getE[T any](c itemGetter, k string, decode func(*Item) (T, error)) (T, error) {
+	var none bool
+	v, err := getDecoded(readErrorGetter{c}, k, decodeNone(decode, &none))
+	var re readError
+	switch {
+	case err == nil && none:
+		return v, ErrCachedNone
+	case err == nil:
+		return v, nil
+	case errors.Is(err, memcache.ErrCacheMiss):
+		return v, ErrCacheMiss
+	case errors.As(err, &re):
+		return v, fmt.Errorf("memcache: get %s: %w", k, re.err)
+	case errors.Is(err, ErrWrongType):
+		return v, err
+	}
+	return v, fmt.Errorf("%w: %w", ErrWrongType, err)
+}
+
+// readError marks the errors of a getter failing to read a value, as opposed
+// to a miss or a decodeError
+type readError struct{ err error }
+
+func (e readError) Error() string { return e.err.Error() }
+func (e readError) Unwrap() error { return e.err }
+
+// readErrorGetter wraps the read errors of an itemGetter in readError
+type readErrorGetter struct{ itemGetter }
+
+func (g readErrorGetter) Get(key string) (*memcache.Item, error) {
+	i, err := g.itemGetter.Get(key)
+	var de decodeError
+	if err != nil && err != memcache.ErrCacheMiss && !errors.As(err, &de) {
+		return nil, readError{err}
+	}
+	return i, err
+}
+
+func (g readErrorGetter) client() *Client {
+	if cg, ok := g.itemGetter.(clientGetter); ok {
+		return cg.client()
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestGetE(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(StringItem("e_string", "value"))
+	mc.Set(Int64Item("e_int", 7))
+	mc.Delete("e_missing")
+
+	if s, err := mc.GetStringE("e_string"); err != nil || s != "value" {
+		t.Errorf("Expected value got %q %v", s, err)
+	}
+	if n, err := mc.GetInt64E("e_int"); err != nil || n != 7 {
+		t.Errorf("Expected 7 got %d %v", n, err)
+	}
+	if _, err := mc.GetStringE("e_missing"); err != ErrCacheMiss || err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if _, err := mc.GetInt64E("e_string"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType got %v", err)
+	}
+	if _, err := mc.GetBoolE("e_string"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType got %v", err)
+	}
+	// a pickle that doesn't decode
+	mc.Set(&memcache.Item{Key: "e_corrupt", Value: []byte("\x80\x02"), Flags: FLAG_PICKLE})
+	if _, err := mc.GetStringE("e_corrupt"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType for a corrupt pickle got %v", err)
+	}
+
+	down := NewClient([]string{closedAddr(t)})
+	_, err := down.GetStringE("e_string")
+	var ne net.Error
+	if err == nil || errors.Is(err, ErrCacheMiss) || errors.Is(err, ErrWrongType) || !errors.As(err, &ne) {
+		t.Errorf("Expected a network error got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"fmt"
+
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// DefaultEventRetries is how many times PushEvent retries after losing a CAS race
+const DefaultEventRetries = 10
+
+// PushEvent appends event to the pickled Python list stored at key, keeping only
+// the newest maxLen events (maxLen <= 0 keeps all). The list is created if key
+// doesn't exist. Concurrent pushes from Go or Python (using gets/cas) don't lose
+// events. event may be nil, a bool, an integer, a float64, a string, or a
+// []interface{} or map[string]interface{} of those.
+func (c *Client) PushEvent(key string, event interface{}, maxLen int) error {
+	if _, err := picklecompat.Encode(event); err != nil {
+		return err
+	}
+	return c.upsert(key, func(old []byte, flags uint32) ([]byte, uint32, error) {
+		events, err := decodeEvents(old, flags)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = capEvents(append(events, event), maxLen)
+		value, err := c.pickle(events)
+		return value, FLAG_PICKLE, err
+	}, []interface{}{event}, DefaultEventRetries)
+}
+
+// ReadEvents returns the events in the list at key, oldest first. Nested lists,
+// tuples and dicts are returned as the gopickle types (*types.List, *types.Tuple,
+// *types.Dict).
+func (c *Client) ReadEvents(key string) ([]interface{}, error) {
+	i, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEvents(i.Value, i.Flags)
+}
+
+// decodeEvents decodes a pickled list of events, inflating it first when
+// pylibmc compressed it
+func decodeEvents(value []byte, flags uint32) ([]interface{}, error) {
+	value, flags, err := inflatePickle(value, flags)
+	if err != nil {
+		return nil, err
+	}
+	if flags != FLAG_PICKLE {
+		return nil, InvalidType
+	}
+	v, err := unpickle(value)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := v.(*types.List)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected list got %T", InvalidType, v)
+	}
+	return *l, nil
+}
+
+func capEvents(events []interface{}, maxLen int) []interface{} {
+	if maxLen > 0 && len(events) > maxLen {
+		return events[len(events)-maxLen:]
+	}
+	return events
+}
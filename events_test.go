@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestEvents(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Delete("events")
+
+	for n := 0; n < 5; n++ {
+		if err := mc.PushEvent("events", fmt.Sprintf("event %d", n), 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := mc.ReadEvents("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(events) != "[event 2 event 3 event 4]" {
+		t.Errorf("unexpected events %v", events)
+	}
+	i, _ := mc.Get("events")
+	if i.Flags != FLAG_PICKLE {
+		t.Errorf("Expected pickled list, got flags %d", i.Flags)
+	}
+
+	mc.Set(StringItem("events_str", "x"))
+	if err := mc.PushEvent("events_str", "e", 0); err != InvalidType {
+		t.Errorf("Expected InvalidType for a non list value, got: %v", err)
+	}
+	mc.Delete("events_missing")
+	if _, err := mc.ReadEvents("events_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got: %v", err)
+	}
+}
+
+func TestEventsCompressed(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	// a list large enough for pylibmc to compress
+	events := make([]interface{}, 50)
+	for n := range events {
+		events[n] = "event"
+	}
+	item, err := PickleItem("events_zlib", events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Value, item.Flags, err = (ZlibStage{MinCompressLen: 1}).Encode(item.Value, item.Flags); err != nil || item.Flags != FLAG_PICKLE|FLAG_ZLIB {
+		t.Fatalf("Expected a compressed list, got flags %d %v", item.Flags, err)
+	}
+	mc.Set(item)
+
+	if err := mc.PushEvent("events_zlib", "last", 0); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := mc.ReadEvents("events_zlib"); err != nil || len(events) != 51 || events[50] != "last" {
+		t.Errorf("unexpected events %v %v", events, err)
+	}
+}
+
+func TestEventsConcurrent(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("events_concurrent")
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if err := mc.PushEvent("events_concurrent", n, 0); err != nil {
+				t.Error(err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	events, err := mc.ReadEvents("events_concurrent")
+	if err != nil || len(events) != 10 {
+		t.Errorf("Expected 10 events, got: %v %v", events, err)
+	}
+}
@@ -0,0 +1,253 @@
+package memcache
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// FailureDetector decides from the outcomes of operations which servers should
+// receive traffic. Keys of an unavailable server go to the next server on the
+// ketama ring, as if it had been removed, until it is available again.
+// Implementations must be safe for concurrent use.
+type FailureDetector interface {
+	// Observe records the outcome of an operation against server completing at
+	// at. Misses and failed conditions are reported as a nil err.
+	Observe(server string, at time.Time, err error)
+	// Available reports whether server should receive traffic at now
+	Available(server string, now time.Time) bool
+}
+
+// Defaults for PhiAccrualDetector
+const (
+	DefaultPhiThreshold     = 8.0
+	DefaultPhiWindow        = 100
+	DefaultPhiMinStdDev     = 50 * time.Millisecond
+	DefaultPhiRetryInterval = time.Second
+)
+
+// PhiAccrualDetector is a phi accrual failure detector (Hayashibara et al.)
+// treating each successful response as a heartbeat. Once operations against a
+// server start failing, suspicion (phi) grows with the time since its last
+// success relative to the usual gap between successes, so a busy server is
+// ejected quickly while a rarely used one isn't ejected for a single slow
+// failure. An ejected server is retried every RetryInterval and restored by its
+// first success.
+type PhiAccrualDetector struct {
+	// Threshold is the phi above which a failing server is ejected; 8 means the
+	// silence has about a 1e-8 chance of being normal
+	Threshold float64
+	// Window is the number of recent intervals between successes kept per server
+	Window int
+	// MinStdDev keeps very regular traffic from making phi over sensitive
+	MinStdDev time.Duration
+	// RetryInterval is how long an ejected server is left alone before traffic
+	// is sent to it again
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	servers map[string]*phiState
+}
+
+type phiState struct {
+	intervals   []time.Duration // ring buffer of recent intervals between successes
+	next        int
+	lastSuccess time.Time
+	lastFailure time.Time
+	failing     bool // failed since the last success
+}
+
+var _ FailureDetector = (*PhiAccrualDetector)(nil)
+
+// NewPhiAccrualDetector returns a PhiAccrualDetector with the default settings
+func NewPhiAccrualDetector() *PhiAccrualDetector {
+	return &PhiAccrualDetector{
+		Threshold:     DefaultPhiThreshold,
+		Window:        DefaultPhiWindow,
+		MinStdDev:     DefaultPhiMinStdDev,
+		RetryInterval: DefaultPhiRetryInterval,
+		servers:       make(map[string]*phiState),
+	}
+}
+
+func (d *PhiAccrualDetector) state(server string) *phiState {
+	if d.servers == nil {
+		d.servers = make(map[string]*phiState)
+	}
+	s, ok := d.servers[server]
+	if !ok {
+		s = &phiState{}
+		d.servers[server] = s
+	}
+	return s
+}
+
+// Observe records an operation outcome for server
+func (d *PhiAccrualDetector) Observe(server string, at time.Time, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.state(server)
+	if err != nil {
+		s.failing = true
+		s.lastFailure = at
+		return
+	}
+	if !s.lastSuccess.IsZero() && !s.failing && at.After(s.lastSuccess) {
+		// only healthy gaps are history; an outage isn't a normal interval
+		window := d.Window
+		if window <= 0 {
+			window = DefaultPhiWindow
+		}
+		if len(s.intervals) < window {
+			s.intervals = append(s.intervals, at.Sub(s.lastSuccess))
+		} else {
+			s.intervals[s.next] = at.Sub(s.lastSuccess)
+			s.next = (s.next + 1) % window
+		}
+	}
+	s.lastSuccess = at
+	s.failing = false
+}
+
+// Available reports whether server should receive traffic
+func (d *PhiAccrualDetector) Available(server string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.servers[server]
+	if !ok || !s.failing {
+		return true
+	}
+	if d.phi(s, now) < d.Threshold {
+		return true
+	}
+	return now.Sub(s.lastFailure) >= d.RetryInterval
+}
+
+// Phi returns the current suspicion level of server; 0 when it isn't failing
+func (d *PhiAccrualDetector) Phi(server string, now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.servers[server]
+	if !ok || !s.failing {
+		return 0
+	}
+	return d.phi(s, now)
+}
+
+// phi returns -log10 of the probability that a gap since the last success at
+// least this long is normal, using a normal distribution fitted to the recent
+// intervals. Until there is history the interval is assumed to be about
+// RetryInterval. A server that has never succeeded is fully suspect.
+func (d *PhiAccrualDetector) phi(s *phiState, now time.Time) float64 {
+	if s.lastSuccess.IsZero() {
+		return math.Inf(1)
+	}
+	mean, stddev := float64(d.RetryInterval), float64(d.RetryInterval)/4
+	if len(s.intervals) > 0 {
+		var sum float64
+		for _, i := range s.intervals {
+			sum += float64(i)
+		}
+		mean = sum / float64(len(s.intervals))
+		var variance float64
+		for _, i := range s.intervals {
+			variance += (float64(i) - mean) * (float64(i) - mean)
+		}
+		stddev = math.Sqrt(variance / float64(len(s.intervals)))
+	}
+	if min := float64(d.MinStdDev); stddev < min {
+		stddev = min
+	}
+	// logistic approximation of the normal CDF used by Akka and Cassandra
+	y := (float64(now.Sub(s.lastSuccess)) - mean) / stddev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if y > 0 {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+// Defaults for FailureCountDetector, libmemcached's server failure limit and
+// retry timeout
+const (
+	DefaultFailureLimit         = 5
+	DefaultFailureRetryInterval = 2 * time.Second
+)
+
+// FailureCountDetector ejects servers the way libmemcached does under pylibmc's
+// remove_failed (auto_eject_hosts): after Limit consecutive failed operations.
+// An ejected server rejoins the ring after RetryInterval; a success resets its
+// count, while a failure ejects it again straight away.
+type FailureCountDetector struct {
+	// Limit is the number of consecutive failures ejecting a server
+	// (remove_failed or failure_limit)
+	Limit int
+	// RetryInterval is how long an ejected server is left out of the ring
+	// (retry_timeout or dead_timeout)
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	servers map[string]*failureCount
+}
+
+type failureCount struct {
+	failures    int
+	lastFailure time.Time
+}
+
+var _ FailureDetector = (*FailureCountDetector)(nil)
+
+// NewFailureCountDetector returns a FailureCountDetector ejecting servers after
+// limit consecutive failures (DefaultFailureLimit when limit isn't positive)
+// for DefaultFailureRetryInterval
+func NewFailureCountDetector(limit int) *FailureCountDetector {
+	if limit <= 0 {
+		limit = DefaultFailureLimit
+	}
+	return &FailureCountDetector{
+		Limit:         limit,
+		RetryInterval: DefaultFailureRetryInterval,
+		servers:       make(map[string]*failureCount),
+	}
+}
+
+// Observe records an operation outcome for server
+func (d *FailureCountDetector) Observe(server string, at time.Time, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		delete(d.servers, server)
+		return
+	}
+	if d.servers == nil {
+		d.servers = make(map[string]*failureCount)
+	}
+	s, ok := d.servers[server]
+	if !ok {
+		s = &failureCount{}
+		d.servers[server] = s
+	}
+	s.failures++
+	s.lastFailure = at
+}
+
+// Available reports whether server should receive traffic
+func (d *FailureCountDetector) Available(server string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.servers[server]
+	if !ok || s.failures < d.Limit {
+		return true
+	}
+	return now.Sub(s.lastFailure) >= d.RetryInterval
+}
+
+// Failures returns the consecutive failures of server
+func (d *FailureCountDetector) Failures(server string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.servers[server]; ok {
+		return s.failures
+	}
+	return 0
+}
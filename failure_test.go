@@ -0,0 +1,133 @@
+package memcache
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPhiAccrualDetector(t *testing.T) {
+	d := NewPhiAccrualDetector()
+	now := time.Unix(1000, 0)
+	errDown := errors.New("down")
+
+	// a server that has never answered is ejected by its first failure
+	d.Observe("new", now, errDown)
+	if d.Available("new", now) || !math.IsInf(d.Phi("new", now), 1) {
+		t.Errorf("expected new to be ejected, phi %v", d.Phi("new", now))
+	}
+	if !d.Available("new", now.Add(d.RetryInterval)) {
+		t.Error("expected new to be retried after RetryInterval")
+	}
+
+	// successes every 10ms
+	for n := 0; n < 50; n++ {
+		d.Observe("a", now, nil)
+		now = now.Add(10 * time.Millisecond)
+	}
+	if p := d.Phi("a", now); p != 0 || !d.Available("a", now) {
+		t.Errorf("expected a healthy server, phi %v", p)
+	}
+	// a failure shortly after a success isn't enough to eject
+	d.Observe("a", now, errDown)
+	if !d.Available("a", now) {
+		t.Errorf("expected a to stay available, phi %v", d.Phi("a", now))
+	}
+	// failures continuing well past the usual gap are
+	later := now.Add(500 * time.Millisecond)
+	d.Observe("a", later, errDown)
+	if d.Available("a", later) {
+		t.Errorf("expected a to be ejected, phi %v", d.Phi("a", later))
+	}
+	if d.Available("a", later.Add(d.RetryInterval/2)) {
+		t.Error("expected a to stay ejected before RetryInterval")
+	}
+	if !d.Available("a", later.Add(d.RetryInterval)) {
+		t.Error("expected a to be retried after RetryInterval")
+	}
+	// one success restores it
+	d.Observe("a", later.Add(d.RetryInterval), nil)
+	if !d.Available("a", later.Add(d.RetryInterval)) {
+		t.Error("expected a to be restored")
+	}
+
+	if !d.Available("unknown", now) {
+		t.Error("expected a server without history to be available")
+	}
+}
+
+func TestClientFailureDetector(t *testing.T) {
+	const dead = "127.0.0.1:1"
+	clock := NewFakeClock(time.Unix(1000, 0))
+	d := NewPhiAccrualDetector()
+	mc := NewClientWithOptions([]string{LocalAddress, dead}, Options{Clock: clock, FailureDetector: d})
+	var key string
+	for _, k := range []string{"fd_a", "fd_b", "fd_c", "fd_d", "fd_e", "fd_f"} {
+		if addr, _ := mc.selector.PickServer(k); addr.String() == dead {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		t.Fatal("no key owned by the dead server")
+	}
+	if err := mc.SetString(key, "v"); err == nil {
+		t.Fatal("expected an error writing to the dead server")
+	}
+	if d.Available(dead, clock.Now()) {
+		t.Fatal("expected the dead server to be ejected")
+	}
+	if addr, _ := mc.selector.PickServer(key); addr.String() != LocalAddress {
+		t.Errorf("expected %s to move to %s, got %s", key, LocalAddress, addr)
+	}
+	if err := mc.SetString(key, "v"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString(key); !ok || s != "v" {
+		t.Errorf("Expected v, got: %q %v", s, ok)
+	}
+	clock.Advance(d.RetryInterval)
+	if addr, _ := mc.selector.PickServer(key); addr.String() != dead {
+		t.Errorf("expected %s to be retried on %s, got %s", key, dead, addr)
+	}
+}
+
+func TestFailureCountDetector(t *testing.T) {
+	d := NewFailureCountDetector(3)
+	now := time.Unix(1000, 0)
+	errDown := errors.New("down")
+
+	for n := 0; n < 2; n++ {
+		d.Observe("a", now, errDown)
+	}
+	if !d.Available("a", now) || d.Failures("a") != 2 {
+		t.Errorf("expected a to stay available below the limit, %d failures", d.Failures("a"))
+	}
+	// a success resets the count
+	d.Observe("a", now, nil)
+	d.Observe("a", now, errDown)
+	if !d.Available("a", now) || d.Failures("a") != 1 {
+		t.Errorf("expected the count to restart, %d failures", d.Failures("a"))
+	}
+
+	for n := 0; n < 3; n++ {
+		d.Observe("b", now, errDown)
+	}
+	if d.Available("b", now.Add(d.RetryInterval/2)) {
+		t.Error("expected b to be ejected before RetryInterval")
+	}
+	now = now.Add(d.RetryInterval)
+	if !d.Available("b", now) {
+		t.Error("expected b to rejoin after RetryInterval")
+	}
+	// failing again ejects it straight away
+	d.Observe("b", now, errDown)
+	if d.Available("b", now) {
+		t.Error("expected b to be ejected again")
+	}
+	d.Observe("b", now.Add(d.RetryInterval), nil)
+	if !d.Available("b", now.Add(d.RetryInterval)) || d.Failures("b") != 0 {
+		t.Error("expected b to be restored by a success")
+	}
+}
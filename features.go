@@ -0,0 +1,204 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrFeatureUnsupported is returned (wrapped) instead of sending a command the
+// server it's for doesn't support, as found by DetectFeatures
+var ErrFeatureUnsupported = errors.New("memcache: command not supported by server")
+
+// ServerFeatures describes what a server supports, from its version and
+// settings
+type ServerFeatures struct {
+	// Version is the version the server reports, e.g. "1.6.21"
+	Version string
+	// Meta is support for the meta commands (mg, ms, md, ma, mn and me),
+	// memcached 1.6+
+	Meta bool
+	// LRUCrawler is support for lru_crawler metadump, memcached 1.4.33+ with
+	// the LRU crawler enabled
+	LRUCrawler bool
+	// TLS is whether the server has TLS enabled (ssl_enabled in stats settings)
+	TLS bool
+}
+
+// supports reports whether the server supports the command verb
+func (f ServerFeatures) supports(verb string) bool {
+	switch verb {
+	case "mg", "ms", "md", "ma", "mn", "me":
+		return f.Meta
+	case "lru_crawler":
+		return f.LRUCrawler
+	}
+	return true
+}
+
+// featureTable holds the features detected for each server, by address
+type featureTable struct {
+	mu      sync.RWMutex
+	servers map[string]ServerFeatures
+}
+
+func (t *featureTable) get(addr string) (ServerFeatures, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f, ok := t.servers[addr]
+	return f, ok
+}
+
+func (t *featureTable) set(addr string, f ServerFeatures) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.servers == nil {
+		t.servers = make(map[string]ServerFeatures)
+	}
+	t.servers[addr] = f
+}
+
+// ServerFeatures returns the features detected for each server by address.
+// Servers not yet detected, or whose detection failed, are missing and every
+// command is sent to them.
+func (c *Client) ServerFeatures() map[string]ServerFeatures {
+	c.features.mu.RLock()
+	defer c.features.mu.RUnlock()
+	m := make(map[string]ServerFeatures, len(c.features.servers))
+	for addr, f := range c.features.servers {
+		m[addr] = f
+	}
+	return m
+}
+
+// DetectFeatures requests the version and settings of every server, recording
+// what each supports so commands a server doesn't (meta commands before 1.6,
+// lru_crawler metadump before 1.4.33 or when disabled) fail with
+// ErrFeatureUnsupported for that server alone rather than being sent, and
+// mixed-version clusters work without configuring for the oldest server.
+// Options.DetectFeatures runs it when the client is created. The returned error
+// joins the failures for each server, which keep their previous features.
+func (c *Client) DetectFeatures(ctx context.Context) error {
+	if c.opts.ProxyMode != ProxyNone {
+		// a proxy answers version itself, if at all
+		return nil
+	}
+	addrs, err := c.servers()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for n, addr := range addrs {
+		wg.Add(1)
+		go func(n int, addr net.Addr) {
+			defer wg.Done()
+			f, err := c.detectFeatures(ctx, addr)
+			if err != nil {
+				errs[n] = fmt.Errorf("memcache: %s: %w", addr, err)
+				return
+			}
+			c.features.set(addr.String(), f)
+		}(n, addr)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// detectFeatures requests the version and settings of addr
+func (c *Client) detectFeatures(ctx context.Context, addr net.Addr) (ServerFeatures, error) {
+	var f ServerFeatures
+	err := c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		version, err := readVersion(sc)
+		if err != nil {
+			return err
+		}
+		settings, err := readSettings(sc)
+		if err != nil {
+			return err
+		}
+		f = serverFeatures(version, settings)
+		return nil
+	})
+	return f, err
+}
+
+// readSettings returns the values of stats settings, which servers before
+// 1.4.0 may not answer
+func readSettings(sc *serverConn) (map[string]string, error) {
+	if err := sc.command("stats settings"); err != nil {
+		return nil, err
+	}
+	settings := make(map[string]string)
+	for {
+		line, err := sc.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "END":
+			return settings, nil
+		case strings.HasPrefix(line, "STAT "):
+			fields := strings.Fields(line)
+			if len(fields) == 3 {
+				settings[fields[1]] = fields[2]
+			}
+		default:
+			// an error for the unknown subcommand
+			return settings, nil
+		}
+	}
+}
+
+// serverFeatures returns the features of a server reporting version and
+// settings
+func serverFeatures(version string, settings map[string]string) ServerFeatures {
+	v := parseVersion(version)
+	atLeast := func(major, minor, patch int) bool {
+		for n, want := range [3]int{major, minor, patch} {
+			if v[n] != want {
+				return v[n] > want
+			}
+		}
+		return true
+	}
+	return ServerFeatures{
+		Version:    version,
+		Meta:       atLeast(1, 6, 0),
+		LRUCrawler: atLeast(1, 4, 33) && settings["lru_crawler"] != "no",
+		TLS:        settings["ssl_enabled"] == "yes",
+	}
+}
+
+// parseVersion returns the major, minor and patch numbers of a version like
+// "1.6.21" or "1.6.0-local"; missing or non numeric parts are zero
+func parseVersion(version string) [3]int {
+	var v [3]int
+	for n, part := range strings.SplitN(version, ".", 3) {
+		if i := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			part = part[:i]
+		}
+		v[n], _ = strconv.Atoi(part)
+	}
+	return v
+}
+
+// featureCheck returns an error if command can't be sent to a server with
+// features f
+func featureCheck(f *ServerFeatures, addr, command string) error {
+	if f == nil {
+		return nil
+	}
+	verb := command
+	if i := strings.IndexByte(command, ' '); i >= 0 {
+		verb = command[:i]
+	}
+	if !f.supports(verb) {
+		return fmt.Errorf("%w: %s on %s (version %s)", ErrFeatureUnsupported, verb, addr, f.Version)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeOldServer answers version as memcached 1.4.20, which has no meta
+// commands or lru_crawler metadump, and stats settings with the crawler
+// disabled
+func fakeOldServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch line = strings.TrimSpace(line); {
+					case strings.HasPrefix(line, "get"):
+						c.Write([]byte("END\r\n"))
+					case line == "version":
+						c.Write([]byte("VERSION 1.4.20\r\n"))
+					case line == "stats settings":
+						c.Write([]byte("STAT maxbytes 67108864\r\nSTAT lru_crawler no\r\nEND\r\n"))
+					default:
+						c.Write([]byte("ERROR\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDetectFeatures(t *testing.T) {
+	ctx := context.Background()
+	addr := fakeOldServer(t)
+	old, err := ConnectClient(ctx, []string{addr}, Options{DetectFeatures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := old.ServerFeatures()[addr]
+	if f != (ServerFeatures{Version: "1.4.20"}) {
+		t.Errorf("unexpected features %+v", f)
+	}
+	if _, err := old.remainingTTL(ctx, "features"); !errors.Is(err, ErrFeatureUnsupported) {
+		t.Errorf("Expected ErrFeatureUnsupported for mg got %v", err)
+	}
+	err = old.Metadump(ctx, func(net.Addr, MetadumpEntry) error { return nil })
+	if !errors.Is(err, ErrFeatureUnsupported) {
+		t.Errorf("Expected ErrFeatureUnsupported for metadump got %v", err)
+	}
+
+	mc := NewClient([]string{LocalAddress})
+	if _, err := mc.remainingTTL(ctx, "features"); err == nil || errors.Is(err, ErrFeatureUnsupported) {
+		t.Errorf("Expected mg to be sent before detection got %v", err)
+	}
+	if err := mc.DetectFeatures(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range mc.ServerFeatures() {
+		if !f.Meta || !f.LRUCrawler || f.TLS || f.Version != "1.6.0-local" {
+			t.Errorf("unexpected features %+v", f)
+		}
+	}
+	mc.Set(StringItem("features", "v"))
+	if _, err := mc.remainingTTL(ctx, "features"); err != nil {
+		t.Errorf("Expected mg to be sent got %v", err)
+	}
+}
+
+func TestServerFeatures(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		settings map[string]string
+		want     ServerFeatures
+	}{
+		{"1.6.21", map[string]string{"ssl_enabled": "yes"}, ServerFeatures{Meta: true, LRUCrawler: true, TLS: true}},
+		{"1.5.22", nil, ServerFeatures{LRUCrawler: true}},
+		{"1.4.33", map[string]string{"lru_crawler": "yes"}, ServerFeatures{LRUCrawler: true}},
+		{"1.4.32", nil, ServerFeatures{}},
+		{"1.10", nil, ServerFeatures{Meta: true, LRUCrawler: true}},
+		{"garbage", nil, ServerFeatures{}},
+	} {
+		tc.want.Version = tc.version
+		if got := serverFeatures(tc.version, tc.settings); got != tc.want {
+			t.Errorf("%s: expected %+v got %+v", tc.version, tc.want, got)
+		}
+	}
+}
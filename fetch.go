@@ -0,0 +1,173 @@
+package memcache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricEarlyRefreshes counts background refreshes of values Fetched under
+// WithEarlyRefresh, tagged by result: "stored" or "error"
+const MetricEarlyRefreshes = "memcache.fetch.early_refreshes"
+
+// EarlyRefresh configures how Fetch refreshes values before they expire, see
+// WithEarlyRefresh
+type EarlyRefresh struct {
+	// TTL is how long loaded values are fresh. The ttl passed to Fetch must be
+	// longer: it bounds how long stale values are served while refreshing.
+	TTL time.Duration
+	// Beta scales how early, before TTL passes, values are refreshed: the
+	// probability grows with how long the value took to load (XFetch). Zero
+	// uses 1; larger values refresh earlier.
+	Beta float64
+}
+
+// WithEarlyRefresh protects a Fetch from stampedes on hot keys expiring:
+// values are stored with a soft TTL of r.TTL (see SetSoftTTL, which Python
+// readers strip the same way) recording how long they took to load. A read
+// finding a value stale, or about to be with the probability of XFetch's
+// probabilistic early recomputation, serves it and reloads it in the
+// background, leased (see Options.ExpiryLeaseTTL) so one reader across every
+// client reloads it. Only misses load synchronously. Reads under it go to the
+// servers, bypassing Options.LocalCacheSize, BatchWindow and CoalesceGets. It
+// only applies to Fetch and its typed variants.
+func WithEarlyRefresh(r EarlyRefresh) CallOption {
+	return func(o *callOptions) { o.early = &r }
+}
+
+// Fetch returns the value of key decoded as T, as Get does, or when that fails
+// (a miss, a value of another type or an unreachable server) the value load
+// returns, stored under key with expiration ttl as Set stores it so pylibmc
+// reads it too. Storing is best effort: the loaded value is returned even when
+// it can't be stored. load's error is returned as is and nothing is stored,
+// except that load returning ErrCachedNone caches a negative result with
+// SetMiss, which later Fetches return as ErrCachedNone without loading.
+func Fetch[T any](c *Client, key string, ttl int32, load func() (T, error), opts ...CallOption) (T, error) {
+	return fetch(c, key, ttl, load, opts, func(i *Item) (T, error) { return decodeGeneric[T](c, i) })
+}
+
+// FetchString is Fetch for strings read as GetStringE reads them
+func (c *Client) FetchString(key string, ttl int32, load func() (string, error), opts ...CallOption) (string, error) {
+	return fetch(c, key, ttl, load, opts, func(i *Item) (string, error) { return i.StringPolicy(c.opts.Unicode) })
+}
+
+// FetchInt64 is Fetch for integers read as GetInt64E reads them
+func (c *Client) FetchInt64(key string, ttl int32, load func() (int64, error), opts ...CallOption) (int64, error) {
+	return fetch(c, key, ttl, load, opts, (*Item).Int64)
+}
+
+// FetchFloat64 is Fetch for floats read as GetFloat64E reads them
+func (c *Client) FetchFloat64(key string, ttl int32, load func() (float64, error), opts ...CallOption) (float64, error) {
+	return fetch(c, key, ttl, load, opts, (*Item).Float64)
+}
+
+// FetchBool is Fetch for booleans read as GetBoolE reads them
+func (c *Client) FetchBool(key string, ttl int32, load func() (bool, error), opts ...CallOption) (bool, error) {
+	return fetch(c, key, ttl, load, opts, (*Item).Bool)
+}
+
+// fetch returns the value of key decoded with decode, or loads and stores it
+func fetch[T any](c *Client, key string, ttl int32, load func() (T, error), opts []CallOption, decode func(*Item) (T, error)) (T, error) {
+	o := newCallOptions(opts)
+	if o.early != nil {
+		return fetchEarly(c, key, ttl, load, *o.early, decode)
+	}
+	if v, err := getGeneric(c, c.getter(opts), key, decode); err == nil || err == ErrCachedNone {
+		return v, err
+	}
+	v, err := load()
+	if err == ErrCachedNone {
+		c.SetMiss(key, ttl)
+	}
+	if err != nil {
+		return v, err
+	}
+	Set(c, key, v, ttl)
+	return v, nil
+}
+
+// fetchEarly is fetch under WithEarlyRefresh
+func fetchEarly[T any](c *Client, key string, ttl int32, load func() (T, error), r EarlyRefresh, decode func(*Item) (T, error)) (T, error) {
+	var staleAt time.Time
+	var delta time.Duration
+	g := getterFunc{c, func(key string) (*memcache.Item, error) {
+		var i *memcache.Item
+		full := c.fullKey(key)
+		err := c.do(OpGet, full, func() (err error) {
+			i, err = c.backendGet(context.Background(), full)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		if i, err = c.unchunk(i, nil); err != nil {
+			return nil, err
+		}
+		c.countFlags(i)
+		var meta url.Values
+		if i, staleAt, meta, err = softEnvelope(i); err != nil {
+			return nil, decodeError{err}
+		}
+		if d, err := strconv.ParseFloat(meta.Get("delta"), 64); err == nil {
+			delta = time.Duration(d * float64(time.Second))
+		}
+		return c.decodeRead(unprefixed(i, key))
+	}}
+	v, err := getGeneric(c, g, key, decode)
+	if err == ErrCachedNone {
+		return v, err
+	}
+	if err != nil {
+		start := c.now()
+		if v, err = load(); err == ErrCachedNone {
+			c.SetMiss(key, ttl)
+		}
+		if err != nil {
+			return v, err
+		}
+		storeEarly(c, key, v, ttl, r, c.now().Sub(start))
+		return v, nil
+	}
+	if !staleAt.IsZero() && refreshDue(c.now(), staleAt, delta, r.Beta) && c.expiryLease(key, staleAt) {
+		go func() {
+			start := c.now()
+			result := "error"
+			if v, err := load(); err == nil && storeEarly(c, key, v, ttl, r, c.now().Sub(start)) == nil {
+				result = "stored"
+			}
+			c.opts.Metrics.Count(MetricEarlyRefreshes, 1, map[string]string{"result": result})
+		}()
+	}
+	return v, nil
+}
+
+// storeEarly stores v fresh for r.TTL, recording that it took delta to load
+func storeEarly[T any](c *Client, key string, v T, ttl int32, r EarlyRefresh, delta time.Duration) error {
+	item, err := encodeGeneric(c, key, v)
+	if err != nil {
+		return err
+	}
+	item.Expiration = ttl
+	meta := url.Values{"delta": {strconv.FormatFloat(delta.Seconds(), 'f', 3, 64)}}
+	return c.setSoft(item, r.TTL, meta)
+}
+
+// refreshDue reports whether a value going stale at staleAt, which took delta
+// to load, is refreshed at now: always once stale, and before with XFetch's
+// probability, refreshing when now - delta * beta * ln(rand) passes staleAt
+func refreshDue(now, staleAt time.Time, delta time.Duration, beta float64) bool {
+	if !now.Before(staleAt) {
+		return true
+	}
+	if beta <= 0 {
+		beta = 1
+	}
+	// 1 - Float64 is in (0, 1], keeping the log finite
+	early := -float64(delta) * beta * math.Log(1-rand.Float64())
+	return early >= float64(staleAt.Sub(now))
+}
@@ -0,0 +1,129 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("fetch_string")
+	mc.Delete("fetch_list")
+
+	loads := 0
+	load := func() (string, error) {
+		loads++
+		return "loaded", nil
+	}
+	for n := 0; n < 2; n++ {
+		if s, err := mc.FetchString("fetch_string", 60, load); err != nil || s != "loaded" {
+			t.Errorf("Expected the loaded value, got %q %v", s, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected one load, got %d", loads)
+	}
+	if i, err := mc.Get("fetch_string"); err != nil || i.Flags != FLAG_NONE || string(i.Value) != "loaded" {
+		t.Errorf("Expected the value stored as pylibmc stores strings, got %v %v", i, err)
+	}
+
+	// values of another type are reloaded
+	if n, err := mc.FetchInt64("fetch_string", 60, func() (int64, error) { return 7, nil }); err != nil || n != 7 {
+		t.Errorf("Expected the loaded value, got %d %v", n, err)
+	}
+	if n, ok := mc.GetInt64("fetch_string"); !ok || n != 7 {
+		t.Errorf("Expected the loaded value stored, got %d %v", n, ok)
+	}
+
+	l, err := Fetch(mc, "fetch_list", 60, func() ([]string, error) { return []string{"a", "b"}, nil })
+	if err != nil || len(l) != 2 {
+		t.Errorf("Expected the loaded list, got %v %v", l, err)
+	}
+	if l, err := Get[[]string](mc, "fetch_list"); err != nil || len(l) != 2 || l[1] != "b" {
+		t.Errorf("Expected the list stored, got %v %v", l, err)
+	}
+
+	// load errors aren't stored
+	mc.Delete("fetch_string")
+	failed := errors.New("store down")
+	if _, err := mc.FetchString("fetch_string", 60, func() (string, error) { return "", failed }); err != failed {
+		t.Errorf("Expected the loader's error, got %v", err)
+	}
+	if _, ok := mc.GetString("fetch_string"); ok {
+		t.Error("Expected nothing stored after a failed load")
+	}
+
+	// an unreachable server still loads
+	down := NewClient([]string{closedAddr(t)})
+	if s, err := down.FetchString("fetch_string", 60, load); err != nil || s != "loaded" {
+		t.Errorf("Expected the loaded value, got %q %v", s, err)
+	}
+}
+
+func TestFetchEarlyRefresh(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Clock: clock, Metrics: metrics})
+	mc.Delete("fetch_early")
+	var mu sync.Mutex
+	value, loads := "v1", 0
+	load := func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return value, nil
+	}
+	early := WithEarlyRefresh(EarlyRefresh{TTL: 10 * time.Second})
+
+	for n := 0; n < 2; n++ {
+		if s, err := mc.FetchString("fetch_early", 60, load, early); err != nil || s != "v1" {
+			t.Errorf("Expected v1, got %q %v", s, err)
+		}
+	}
+	if i, err := mc.Client.Get("fetch_early"); err != nil || i.Flags&FLAG_SOFT_TTL == 0 || !strings.HasPrefix(string(i.Value), "delta=0.000&stale=") {
+		t.Errorf("Expected a soft TTL envelope recording the load time, got %v %v", i, err)
+	}
+	if s, ok := mc.GetString("fetch_early"); !ok || s != "v1" {
+		t.Errorf("Expected the envelope stripped by GetString, got %q %v", s, ok)
+	}
+
+	// stale values are served while refreshed in the background
+	mu.Lock()
+	value = "v2"
+	mu.Unlock()
+	clock.Advance(11 * time.Second)
+	if s, err := mc.FetchString("fetch_early", 60, load, early); err != nil || s != "v1" {
+		t.Errorf("Expected the stale v1, got %q %v", s, err)
+	}
+	eventually(t, func() error {
+		if s, _ := mc.GetString("fetch_early"); s != "v2" {
+			return fmt.Errorf("expected the refreshed v2, got %q", s)
+		}
+		if n := metrics.get(MetricEarlyRefreshes); n != 1 {
+			return fmt.Errorf("expected 1 refresh, got %d", n)
+		}
+		return nil
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if loads != 2 {
+		t.Errorf("Expected 2 loads, got %d", loads)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	now := time.Now()
+	if !refreshDue(now, now, 0, 1) {
+		t.Error("Expected stale values to be refreshed")
+	}
+	if refreshDue(now, now.Add(time.Second), 0, 1) {
+		t.Error("Expected values loading instantly not to be refreshed early")
+	}
+	if !refreshDue(now, now.Add(time.Second), time.Hour, 1e6) {
+		t.Error("Expected slow loads to be refreshed early")
+	}
+}
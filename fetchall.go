@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Spec names a key to fetch and where to decode its value. Dest must be a
+// *string, *int64, *bool, *[]byte or **memcache.Item.
+type Spec struct {
+	Key  string
+	Dest interface{}
+	// Optional leaves Dest untouched on a cache miss instead of reporting an error
+	Optional bool
+}
+
+// Specs is a set of values for FetchAll
+type Specs []Spec
+
+// FetchAll fetches every spec's key with a single GetMulti and decodes each value
+// into its Dest. Errors for individual specs (misses of required keys, type
+// mismatches) are joined into the returned error; the other specs are still
+// filled in. Each spec error wraps ErrCacheMiss or InvalidType.
+func (c *Client) FetchAll(ctx context.Context, specs Specs) error {
+	keys := make([]string, len(specs))
+	for n, s := range specs {
+		keys[n] = s.Key
+	}
+	items, err := c.GetMultiCtx(ctx, keys)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range specs {
+		i, ok := items[s.Key]
+		if !ok {
+			if !s.Optional {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Key, memcache.ErrCacheMiss))
+			}
+			continue
+		}
+		i, err := c.unknownFlags(i)
+		if err == nil {
+			err = decodeInto(i, s.Dest)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeInto decodes i into the value dest points to
+func decodeInto(i *memcache.Item, dest interface{}) (err error) {
+	item := &Item{i}
+	switch d := dest.(type) {
+	case *string:
+		*d, err = item.String()
+	case *int64:
+		*d, err = item.Int64()
+	case *bool:
+		*d, err = item.Bool()
+	case *[]byte:
+		*d = i.Value
+	case **memcache.Item:
+		*d = i
+	default:
+		return fmt.Errorf("%w: unsupported destination %T", InvalidType, dest)
+	}
+	return err
+}
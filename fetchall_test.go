@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestFetchAll(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(StringItem("fetch_name", "jehiah"))
+	mc.Set(Int64Item("fetch_count", 7))
+	mc.Set(BoolItem("fetch_admin", true))
+	mc.Delete("fetch_missing")
+	mc.Delete("fetch_optional")
+
+	var name string
+	var count int64
+	var admin bool
+	var raw []byte
+	optional := "default"
+	err := mc.FetchAll(context.Background(), Specs{
+		{Key: "fetch_name", Dest: &name},
+		{Key: "fetch_count", Dest: &count},
+		{Key: "fetch_admin", Dest: &admin},
+		{Key: "fetch_name", Dest: &raw},
+		{Key: "fetch_optional", Dest: &optional, Optional: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "jehiah" || count != 7 || !admin || string(raw) != "jehiah" || optional != "default" {
+		t.Errorf("unexpected values %q %d %v %q %q", name, count, admin, raw, optional)
+	}
+
+	err = mc.FetchAll(context.Background(), Specs{
+		{Key: "fetch_missing", Dest: &name},
+		{Key: "fetch_name", Dest: &count},
+		{Key: "fetch_count", Dest: &count},
+	})
+	if !errors.Is(err, memcache.ErrCacheMiss) || !errors.Is(err, InvalidType) {
+		t.Errorf("Expected joined miss and type errors, got: %v", err)
+	}
+	if count != 7 {
+		t.Errorf("Expected valid specs to still be decoded, got: %d", count)
+	}
+}
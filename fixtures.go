@@ -0,0 +1,54 @@
+package memcache
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// FixtureExt is the extension of the pickle files read by LoadFixtures
+const FixtureExt = ".pkl"
+
+// LoadFixture reads the pickle file name from fsys, as written by a Python test
+// suite with
+//
+//	with open(name, 'wb') as f:
+//	    pickle.dump(value, f, protocol)
+//
+// returning an Item keyed by the file name without FixtureExt holding its bytes
+// unchanged with flags, usually FLAG_PICKLE, so tests can assert how values
+// written by the Python codebase decode. Values pylibmc stores compressed must
+// be dumped compressed and loaded with FLAG_ZLIB as well.
+func LoadFixture(fsys fs.FS, name string, flags uint32) (*Item, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("memcache: fixture %s: %w", name, err)
+	}
+	return &Item{&memcache.Item{
+		Key:   strings.TrimSuffix(path.Base(name), FixtureExt),
+		Value: b,
+		Flags: flags,
+	}}, nil
+}
+
+// LoadFixtures loads every FixtureExt file in the root of fsys (e.g.
+// os.DirFS("testdata") or an embed.FS) with LoadFixture, returning the Items
+// by key
+func LoadFixtures(fsys fs.FS, flags uint32) (map[string]*Item, error) {
+	names, err := fs.Glob(fsys, "*"+FixtureExt)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]*Item, len(names))
+	for _, name := range names {
+		i, err := LoadFixture(fsys, name, flags)
+		if err != nil {
+			return nil, err
+		}
+		items[i.Key] = i
+	}
+	return items, nil
+}
@@ -0,0 +1,48 @@
+package memcache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	items, err := LoadFixtures(os.DirFS("testdata/fixtures"), FLAG_PICKLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 fixtures got %d", len(items))
+	}
+	for k, i := range items {
+		if i.Key != k || i.Flags != FLAG_PICKLE {
+			t.Errorf("%s: unexpected key or flags %q %d", k, i.Key, i.Flags)
+		}
+	}
+
+	if s, err := items["py2_unicode"].String(); err != nil || s != "café" {
+		t.Errorf("Expected café got %q %v", s, err)
+	}
+	m, err := items["py2_dict"].StringMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["name"] != "ada" || m["age"] != 36 {
+		t.Errorf("unexpected dict %v", m)
+	}
+	want := time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC)
+	if tm, err := items["py2_datetime"].Time(); err != nil || !tm.Equal(want) {
+		t.Errorf("Expected %s got %s %v", want, tm, err)
+	}
+	v, typ, err := items["py3_list"].Any()
+	if err != nil || typ != TypeList || !reflect.DeepEqual(v, []interface{}{"x", int64(1), 2.5, nil, []byte{0, 0xff}}) {
+		t.Errorf("unexpected list %#v %s %v", v, typ, err)
+	}
+
+	if _, err := LoadFixture(os.DirFS("testdata/fixtures"), "missing.pkl", FLAG_PICKLE); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package memcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Get returns the value of key decoded as T, the way the typed getters do:
+//
+//   - string, int64, int, *big.Int, bool and float64 as GetString, GetInt64,
+//     GetBigInt, GetBool and GetFloat64 read them
+//   - []byte as the value stored (inflated when compressed)
+//   - []interface{}, []string, []int64 and []float64 from a pickled list or tuple
+//   - map[string]interface{}, map[string]string, map[string]int64 and
+//     map[interface{}]interface{} from a pickled dict
+//   - map[interface{}]struct{} from a pickled set
+//
+// Any other T is decoded with Deserialize and must match its result, as must
+// the result of the Codec registered for the flags of values it encoded.
+// Values of another type fail with InvalidType; ErrCacheMiss is returned for
+// a miss. A cached None is nil for interface types and ErrCachedNone for
+// others.
+func Get[T any](c *Client, key string, opts ...CallOption) (T, error) {
+	return getGeneric(c, c.getter(opts), key, func(i *Item) (T, error) {
+		return decodeGeneric[T](c, i)
+	})
+}
+
+// getGeneric reads key through g with decode, as Get does
+func getGeneric[T any](c *Client, g itemGetter, key string, decode func(*Item) (T, error)) (T, error) {
+	var zero T
+	if interface{}(zero) == nil {
+		return getDecoded(g, key, decode)
+	}
+	var none bool
+	v, err := getDecoded(g, key, decodeNone(decode, &none))
+	if err == nil && none {
+		return v, ErrCachedNone
+	}
+	return v, err
+}
+
+// decodeGeneric decodes i as T for Get
+func decodeGeneric[T any](c *Client, i *Item) (T, error) {
+	var zero T
+	var v interface{}
+	var err error
+	if codec := c.opts.Pipeline.codecs.match(i.Flags); codec != nil {
+		v, err = codec.Decode(i.Value)
+	} else {
+		v, err = decodeAs(i, zero)
+	}
+	if err != nil {
+		return zero, err
+	}
+	if v == nil && interface{}(zero) == nil {
+		// Python None for an interface T
+		return zero, nil
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: expected %T got %T", InvalidType, zero, v)
+	}
+	return t, nil
+}
+
+// Set stores v under key with expiration ttl, serialized as pylibmc would store
+// the equivalent python value through the client's Pipeline. T may be any type
+// Get supports except map[interface{}]interface{} and sets.
+func Set[T any](c *Client, key string, v T, ttl int32) error {
+	item, err := encodeGeneric(c, key, v)
+	if err != nil {
+		return err
+	}
+	item.Expiration = ttl
+	return c.Set(item)
+}
+
+// encodeGeneric encodes v as Set stores it
+func encodeGeneric[T any](c *Client, key string, v T) (*memcache.Item, error) {
+	var value interface{} = v
+	switch v := value.(type) {
+	case []string:
+		value = toInterfaces(v)
+	case []int64:
+		value = toInterfaces(v)
+	case []float64:
+		value = toInterfaces(v)
+	case map[string]string:
+		value = toInterfaceMap(v)
+	case map[string]int64:
+		value = toInterfaceMap(v)
+	}
+	return c.opts.Pipeline.Encode(key, value)
+}
+
+// decodeAs decodes i as the type of zero
+func decodeAs(i *Item, zero interface{}) (interface{}, error) {
+	switch zero.(type) {
+	case string:
+		return i.String()
+	case int64:
+		return i.Int64()
+	case int:
+		n, err := i.Int64()
+		return int(n), err
+	case *big.Int:
+		return i.BigInt()
+	case bool:
+		return i.Bool()
+	case float64:
+		return i.Float64()
+	case []byte:
+		i, err := i.inflated()
+		if err != nil {
+			return nil, err
+		}
+		return i.Value, nil
+	case []interface{}:
+		return i.List()
+	case []string:
+		return listAs(i, toString)
+	case []int64:
+		return listAs(i, toInt64)
+	case []float64:
+		return listAs(i, toFloat)
+	case map[string]interface{}:
+		return i.StringMap()
+	case map[string]string:
+		return mapAs(i, toString)
+	case map[string]int64:
+		return mapAs(i, toInt64)
+	case map[interface{}]interface{}:
+		return i.Map()
+	case map[interface{}]struct{}:
+		return i.Set()
+	}
+	i, err := i.inflated()
+	if err != nil {
+		return nil, err
+	}
+	return Deserialize(i.Value, i.Flags)
+}
+
+// listAs decodes a pickled list converting each element with conv
+func listAs[E any](i *Item, conv func(interface{}) (E, bool)) ([]E, error) {
+	l, err := i.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]E, len(l))
+	for n, v := range l {
+		e, ok := conv(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: list element %T", InvalidType, v)
+		}
+		out[n] = e
+	}
+	return out, nil
+}
+
+// mapAs decodes a pickled dict with str keys converting each value with conv
+func mapAs[E any](i *Item, conv func(interface{}) (E, bool)) (map[string]E, error) {
+	m, err := i.StringMap()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]E, len(m))
+	for k, v := range m {
+		e, ok := conv(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: dict value %T", InvalidType, v)
+		}
+		out[k] = e
+	}
+	return out, nil
+}
+
+func toInterfaces[E any](l []E) []interface{} {
+	out := make([]interface{}, len(l))
+	for n, v := range l {
+		out[n] = v
+	}
+	return out
+}
+
+func toInterfaceMap[E any](m map[string]E) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func toString(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package memcache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestGenericGetSet(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	check := func(name string, got, want interface{}, err error) {
+		t.Helper()
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v %v, want %#v", name, got, err, want)
+		}
+	}
+
+	Set(mc, "g_str", "Iñtërnâtiônàlizætiøn", 0)
+	s, err := Get[string](mc, "g_str")
+	check("string", s, "Iñtërnâtiônàlizætiøn", err)
+	i, _ := mc.Get("g_str")
+	if i.Flags != FLAG_NONE {
+		t.Errorf("Expected a string stored as pylibmc does, got flags %d", i.Flags)
+	}
+
+	Set(mc, "g_int", int64(42), 0)
+	n, err := Get[int64](mc, "g_int")
+	check("int64", n, int64(42), err)
+	Set(mc, "g_bool", true, 0)
+	b, err := Get[bool](mc, "g_bool")
+	check("bool", b, true, err)
+	Set(mc, "g_float", 1.5, 0)
+	f, err := Get[float64](mc, "g_float")
+	check("float64", f, 1.5, err)
+
+	Set(mc, "g_list", []string{"a", "b"}, 0)
+	l, err := Get[[]string](mc, "g_list")
+	check("[]string", l, []string{"a", "b"}, err)
+	li, err := Get[[]interface{}](mc, "g_list")
+	check("[]interface{}", li, []interface{}{"a", "b"}, err)
+	if _, err := Get[[]int64](mc, "g_list"); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a list of strings as []int64, got %v", err)
+	}
+
+	Set(mc, "g_map", map[string]int64{"a": 1, "b": 2}, 0)
+	m, err := Get[map[string]int64](mc, "g_map")
+	check("map[string]int64", m, map[string]int64{"a": 1, "b": 2}, err)
+	mi, err := Get[map[string]interface{}](mc, "g_map")
+	check("map[string]interface{}", mi, map[string]interface{}{"a": 1, "b": 2}, err)
+
+	Set(mc, "g_none", (interface{})(nil), 0)
+	v, err := Get[interface{}](mc, "g_none")
+	check("None", v, nil, err)
+
+	if _, err := Get[int64](mc, "g_str"); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+	if _, err := Get[string](mc, "g_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
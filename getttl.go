@@ -0,0 +1,91 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// NoExpiration is the TTL reported for items that don't expire
+const NoExpiration time.Duration = -1
+
+// errFound stops a metadump once the key looked for is found
+var errFound = errors.New("found")
+
+// GetWithTTL gets key with its remaining TTL, in whole seconds, or
+// NoExpiration. Both are read with one meta get; from servers before 1.6 the
+// item is read with get and its TTL found in the server's metadump (lru_crawler
+// metadump, 1.4.33+), which is slow on large servers.
+func (c *Client) GetWithTTL(key string) (*memcache.Item, time.Duration, error) {
+	mi, err := c.MetaGet(key, MetaGetOptions{})
+	switch {
+	case err == nil:
+		ttl := NoExpiration
+		if mi.TTL >= 0 {
+			ttl = time.Duration(mi.TTL) * time.Second
+		}
+		return mi.Item.Item, ttl, nil
+	case !errors.Is(err, ErrFeatureUnsupported):
+		return nil, 0, err
+	}
+	i, err := c.Get(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	full := c.fullKey(key)
+	addr, err := c.selector.PickServer(full)
+	if err != nil {
+		return nil, 0, err
+	}
+	secs, err := c.metadumpTTL(context.Background(), addr, full)
+	if err != nil {
+		return nil, 0, err
+	}
+	if secs < 0 {
+		return i, NoExpiration, nil
+	}
+	return i, time.Duration(secs) * time.Second, nil
+}
+
+// GetStringWithTTL is GetString also returning the remaining TTL of k as
+// GetWithTTL does
+func (c *Client) GetStringWithTTL(k string) (string, time.Duration, bool) {
+	var ttl time.Duration
+	g := getterFunc{c, func(key string) (i *memcache.Item, err error) {
+		if i, ttl, err = c.GetWithTTL(key); err != nil {
+			return nil, err
+		}
+		return c.decodeRead(i)
+	}}
+	s, ok := getStringPolicy(g, k, c.opts.Unicode)
+	return s, ttl, ok
+}
+
+// metadumpTTL returns the seconds until key expires, or -1 if it doesn't, from
+// the metadump of addr
+func (c *Client) metadumpTTL(ctx context.Context, addr net.Addr, key string) (int64, error) {
+	var exp int64
+	err := c.MetadumpServer(ctx, addr, func(e MetadumpEntry) error {
+		if e.Key != key {
+			return nil
+		}
+		exp = e.Exp
+		return errFound
+	})
+	switch {
+	case err == nil:
+		return 0, memcache.ErrCacheMiss
+	case err != errFound:
+		return 0, err
+	case exp < 0:
+		return -1, nil
+	}
+	ttl := exp - c.now().Unix()
+	if ttl < 0 {
+		ttl = 0
+	}
+	return ttl, nil
+}
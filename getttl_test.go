@@ -0,0 +1,39 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestGetWithTTL(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	i := UnicodeItem("ttl_string", "value")
+	i.Expiration = 100
+	mc.Set(i)
+	mc.Set(StringItem("ttl_forever", "value"))
+	mc.Delete("ttl_missing")
+
+	if s, ttl, ok := mc.GetStringWithTTL("ttl_string"); !ok || s != "value" || ttl < 99*time.Second || ttl > 100*time.Second {
+		t.Errorf("Expected value with a TTL of 100s got %q %s %v", s, ttl, ok)
+	}
+	if _, ttl, err := mc.GetWithTTL("ttl_forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected NoExpiration got %s %v", ttl, err)
+	}
+	if _, _, err := mc.GetWithTTL("ttl_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+
+	// servers without meta commands fall back to their metadump
+	mc.features.set(LocalAddress, ServerFeatures{Version: "1.5.0", LRUCrawler: true})
+	if s, ttl, ok := mc.GetStringWithTTL("ttl_string"); !ok || s != "value" || ttl < 98*time.Second || ttl > 100*time.Second {
+		t.Errorf("Expected value with a TTL of 100s from the metadump got %q %s %v", s, ttl, ok)
+	}
+	if _, ttl, err := mc.GetWithTTL("ttl_forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected NoExpiration from the metadump got %s %v", ttl, err)
+	}
+	if _, _, err := mc.GetWithTTL("ttl_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+}
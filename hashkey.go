@@ -0,0 +1,33 @@
+package memcache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// DefaultHashKeyLength is the length of keys from HashKey (132 bits of the HMAC)
+const DefaultHashKeyLength = 22
+
+// HashKey derives a cache key from s (e.g. an email address) without revealing it:
+// the HMAC-SHA256 of s with secret, base64url encoded without padding and
+// truncated to length characters (DefaultHashKeyLength if length <= 0). The
+// Python equivalent is:
+//
+//	import base64, hashlib, hmac
+//
+//	def hash_key(secret, s, length=22):
+//	    digest = hmac.new(secret, s.encode('utf-8'), hashlib.sha256).digest()
+//	    return base64.urlsafe_b64encode(digest).rstrip(b'=').decode('ascii')[:length]
+func HashKey(secret []byte, s string, length int) string {
+	if length <= 0 {
+		length = DefaultHashKeyLength
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	k := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if length < len(k) {
+		k = k[:length]
+	}
+	return k
+}
@@ -0,0 +1,24 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	secret := []byte("secret")
+	// expected values computed with the Python snippet in the HashKey docs
+	if k := HashKey(secret, "user@example.com", 0); k != "_rymVrH6IjQINijRdPJQ3Y" {
+		t.Errorf("unexpected key %q", k)
+	}
+	if k := HashKey(secret, "user@example.com", 8); k != "_rymVrH6" {
+		t.Errorf("unexpected truncated key %q", k)
+	}
+	if k := HashKey(secret, "user@example.com", 100); len(k) != 43 {
+		t.Errorf("Expected full 43 character digest, got: %q", k)
+	}
+
+	kb := KeyBuilder{Prefix: "pii:", HashSecret: secret}
+	if k, err := kb.Join("email", "user@example.com"); err != nil || k != "pii:Mi9JWg47-tQAWpLGvrAOcz" {
+		t.Errorf("unexpected hashed key %q %v", k, err)
+	}
+}
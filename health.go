@@ -0,0 +1,65 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Ping checks every server is reachable, e.g. for a readiness probe. It is
+// ValidateConfig without a context, replacing gomemcache's Ping so servers are
+// dialed with Options.DialContext and checked through proxies.
+func (c *Client) Ping() error {
+	return c.ValidateConfig(context.Background())
+}
+
+// PingAll checks every server concurrently returning the failure, or nil, for
+// each keyed by address
+func (c *Client) PingAll(ctx context.Context) (map[string]error, error) {
+	addrs, err := c.servers()
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]error, len(addrs))
+	for n, err := range c.checkServers(ctx, addrs) {
+		results[addrs[n].String()] = err
+	}
+	return results, nil
+}
+
+// Version returns the version of every server keyed by address, requested
+// concurrently. Servers that can't be reached are left out, with their errors
+// joined in the returned error.
+func (c *Client) Version(ctx context.Context) (map[string]string, error) {
+	addrs, err := c.servers()
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(addrs))
+	errs := make([]error, len(addrs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for n, addr := range addrs {
+		wg.Add(1)
+		go func(n int, addr net.Addr) {
+			defer wg.Done()
+			err := c.withServerConn(ctx, addr, func(sc *serverConn) error {
+				version, err := readVersion(sc)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				versions[addr.String()] = version
+				return nil
+			})
+			if err != nil {
+				errs[n] = fmt.Errorf("memcache: %s: %w", addr, err)
+			}
+		}(n, addr)
+	}
+	wg.Wait()
+	return versions, errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	if err := NewClient([]string{LocalAddress}).Ping(); err != nil {
+		t.Fatal(err)
+	}
+	down := closedAddr(t)
+	if err := NewClient([]string{LocalAddress, down}).Ping(); err == nil {
+		t.Error("Expected an error pinging a closed server")
+	}
+}
+
+func TestPingAll(t *testing.T) {
+	down := closedAddr(t)
+	results, err := NewClient([]string{LocalAddress, down}).PingAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[LocalAddress] != nil || results[down] == nil {
+		t.Errorf("Unexpected results %v", results)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	down := closedAddr(t)
+	versions, err := NewClient([]string{LocalAddress, down}).Version(context.Background())
+	if err == nil {
+		t.Error("Expected an error for the closed server")
+	}
+	if len(versions) != 1 || versions[LocalAddress] != "1.6.0-local" {
+		t.Errorf("Unexpected versions %v", versions)
+	}
+}
@@ -0,0 +1,167 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// DefaultHistogramRetries is how many times Observe retries after losing a CAS race
+const DefaultHistogramRetries = 10
+
+// Histogram counts observations (latencies, sizes...) into fixed buckets, one
+// key per time window named "<name>:<window start unix time>". Each key holds
+// a pickled Python dict mapping a bucket's upper bound (a float, inf for the
+// last bucket) to its count, e.g. {10.0: 3, 100.0: 1, inf: 0}, so notebooks can
+// read windows directly. Counts are not cumulative.
+type Histogram struct {
+	c *Client
+	// Name prefixes the window keys
+	Name string
+	// Bounds are the ascending bucket upper bounds. Values above the last bound
+	// are counted in an extra +Inf bucket.
+	Bounds []float64
+	// Window is the time span each key covers
+	Window time.Duration
+	// Retention is how long a window's key is kept after the window ends. Zero
+	// keeps windows until they are evicted.
+	Retention time.Duration
+}
+
+// HistogramCounts are merged Histogram windows. Counts[i] is the number of
+// observations in the bucket with upper bound Bounds[i]; the last count is the
+// +Inf bucket.
+type HistogramCounts struct {
+	Bounds []float64
+	Counts []int64
+}
+
+// Total returns the number of observations
+func (h HistogramCounts) Total() int64 {
+	var n int64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// Histogram returns a Histogram storing windows of the given size under name
+func (c *Client) Histogram(name string, bounds []float64, window time.Duration) *Histogram {
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{c: c, Name: name, Bounds: b, Window: window}
+}
+
+// Key returns the key of the window holding at
+func (h *Histogram) Key(at time.Time) string {
+	return fmt.Sprintf("%s:%d", h.Name, at.Truncate(h.Window).Unix())
+}
+
+// Observe counts v in the current window
+func (h *Histogram) Observe(v float64) error {
+	return h.ObserveAt(h.c.now(), v)
+}
+
+// ObserveAt counts v in the window holding at. Concurrent observations from Go
+// or Python (using gets/cas) aren't lost.
+func (h *Histogram) ObserveAt(at time.Time, v float64) error {
+	bound := h.bucket(v)
+	var expiration int32
+	if h.Retention > 0 {
+		expiration = int32(at.Truncate(h.Window).Add(h.Window + h.Retention).Unix())
+	}
+	initial := h.empty()
+	increment(initial, bound)
+	return h.c.upsertExpiring(h.Key(at), func(old []byte, flags uint32) ([]byte, uint32, error) {
+		d, err := decodeDict(old, flags)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := increment(d, bound); err != nil {
+			return nil, 0, err
+		}
+		b, err := h.c.pickle(d)
+		return b, FLAG_PICKLE, err
+	}, initial, expiration, DefaultHistogramRetries)
+}
+
+// increment adds one to the count of bound in a window dict. Keys are matched
+// numerically since Python may have written 10 for 10.0.
+func increment(d *types.Dict, bound float64) error {
+	for n, e := range *d {
+		if b, ok := toFloat(e.Key); ok && b == bound {
+			count, ok := toFloat(e.Value)
+			if !ok {
+				return fmt.Errorf("%w: expected a count got %T", InvalidType, e.Value)
+			}
+			(*d)[n].Value = int64(count) + 1
+			return nil
+		}
+	}
+	d.Set(bound, int64(1))
+	return nil
+}
+
+// Read merges the windows overlapping [from, to). Missing windows count as
+// empty. Buckets stored with bounds other than h.Bounds (e.g. before the
+// bounds were changed) are counted in the bucket holding their bound.
+func (h *Histogram) Read(ctx context.Context, from, to time.Time) (HistogramCounts, error) {
+	counts := HistogramCounts{Bounds: h.Bounds, Counts: make([]int64, len(h.Bounds)+1)}
+	var keys []string
+	for at := from.Truncate(h.Window); at.Before(to); at = at.Add(h.Window) {
+		keys = append(keys, h.Key(at))
+	}
+	if len(keys) == 0 {
+		return counts, nil
+	}
+	items, err := h.c.GetMultiCtx(ctx, keys)
+	if err != nil {
+		return counts, err
+	}
+	for _, k := range keys {
+		i, ok := items[k]
+		if !ok {
+			continue
+		}
+		d, err := decodeDict(i.Value, i.Flags)
+		if err != nil {
+			return counts, fmt.Errorf("%s: %w", k, err)
+		}
+		for _, e := range *d {
+			bound, ok := toFloat(e.Key)
+			n, nok := toFloat(e.Value)
+			if !ok || !nok {
+				return counts, fmt.Errorf("%s: %w: unexpected bucket %v: %v", k, InvalidType, e.Key, e.Value)
+			}
+			counts.Counts[h.index(bound)] += int64(n)
+		}
+	}
+	return counts, nil
+}
+
+// index returns the bucket holding v
+func (h *Histogram) index(v float64) int {
+	return sort.SearchFloat64s(h.Bounds, v)
+}
+
+// bucket returns the upper bound of the bucket holding v
+func (h *Histogram) bucket(v float64) float64 {
+	if n := h.index(v); n < len(h.Bounds) {
+		return h.Bounds[n]
+	}
+	return math.Inf(1)
+}
+
+// empty returns a window dict with every bucket at zero
+func (h *Histogram) empty() *types.Dict {
+	d := types.NewDict()
+	for _, b := range h.Bounds {
+		d.Set(b, int64(0))
+	}
+	d.Set(math.Inf(1), int64(0))
+	return d
+}
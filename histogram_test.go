@@ -0,0 +1,106 @@
+package memcache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestHistogram(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	h := mc.Histogram("histogram", []float64{100, 10}, time.Minute)
+	h.Retention = time.Hour
+	start := time.Now().Truncate(time.Minute)
+	mc.Delete(h.Key(start))
+	mc.Delete(h.Key(start.Add(time.Minute)))
+
+	for _, v := range []float64{1, 10, 11, 500} {
+		if err := h.ObserveAt(start, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.ObserveAt(start.Add(time.Minute+time.Second), 50); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := mc.Get(h.Key(start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := picklecompat.Decode(i.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := v.(*types.Dict)
+	for bound, want := range map[float64]float64{10: 2, 100: 1, math.Inf(1): 1} {
+		if n, _ := d.Get(bound); n == nil {
+			t.Errorf("bucket %v: missing", bound)
+		} else if n, _ := toFloat(n); n != want {
+			t.Errorf("bucket %v: expected %v got %v", bound, want, n)
+		}
+	}
+
+	counts, err := h.Read(ctx, start, start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{2, 2, 1}
+	for n := range want {
+		if counts.Counts[n] != want[n] {
+			t.Errorf("Expected counts %v, got %v", want, counts.Counts)
+			break
+		}
+	}
+	if counts.Total() != 5 {
+		t.Errorf("Expected 5 observations, got %d", counts.Total())
+	}
+	if counts, _ := h.Read(ctx, start, start.Add(time.Second)); counts.Total() != 4 {
+		t.Errorf("Expected one window with 4 observations, got %v", counts.Counts)
+	}
+}
+
+func TestHistogramPythonWindow(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	h := mc.Histogram("histogram_py", []float64{10, 100}, time.Minute)
+	at := time.Unix(1700000000, 0)
+	// pickle.dumps({10: 3, 50.0: 2}, 2), with an int key and a bound not in h.Bounds
+	mc.Set(&memcache.Item{Key: h.Key(at), Value: []byte("\x80\x02}q\x00(K\nK\x03G@I\x00\x00\x00\x00\x00\x00K\x02u."), Flags: FLAG_PICKLE})
+	if err := h.ObserveAt(at, 5); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := h.Read(context.Background(), at, at.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Counts[0] != 4 || counts.Counts[1] != 2 || counts.Counts[2] != 0 {
+		t.Errorf("unexpected counts %v", counts.Counts)
+	}
+}
+
+func TestHistogramConcurrent(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	h := mc.Histogram("histogram_concurrent", []float64{1}, time.Minute)
+	at := time.Unix(1700000000, 0)
+	mc.Delete(h.Key(at))
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.ObserveAt(at, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if counts, err := h.Read(context.Background(), at, at.Add(time.Minute)); err != nil || counts.Counts[0] != 10 {
+		t.Errorf("Expected 10 observations, got %v %v", counts.Counts, err)
+	}
+}
@@ -0,0 +1,62 @@
+package memcache
+
+import (
+	"context"
+	"time"
+)
+
+// OpEvent describes an operation passed to Hooks
+type OpEvent struct {
+	// Op is the operation, e.g. OpGet
+	Op string
+	// Key is the operation's key (the first key of a get_multi batch) passed
+	// through Options.KeySanitizer
+	Key string
+	// Server is the address of the server the operation was sent to
+	Server string
+	// Start is when the operation started
+	Start time.Time
+	// Duration and Err are set for OnFinish. Misses and failed conditions are
+	// reported as they are (ErrCacheMiss, ErrNotStored...), so hooks can tell
+	// them from failures with IsProtocolError.
+	Duration time.Duration
+	Err      error
+}
+
+// Hooks observes every round trip to a server, see Options.Hooks, e.g. for
+// slow query logging, sampling or custom metrics. Hooks are called inline,
+// concurrently for concurrent operations and the servers of a GetMulti, so
+// they must be safe for concurrent use and should return quickly.
+type Hooks interface {
+	// OnStart is called before an operation is sent, with the context it was
+	// made with (context.Background for methods without a context)
+	OnStart(ctx context.Context, ev OpEvent)
+	// OnFinish is called once the operation completes
+	OnFinish(ctx context.Context, ev OpEvent)
+}
+
+// HookFuncs implements Hooks with functions, either of which may be nil
+type HookFuncs struct {
+	Start  func(ctx context.Context, ev OpEvent)
+	Finish func(ctx context.Context, ev OpEvent)
+}
+
+// OnStart calls h.Start
+func (h HookFuncs) OnStart(ctx context.Context, ev OpEvent) {
+	if h.Start != nil {
+		h.Start(ctx, ev)
+	}
+}
+
+// OnFinish calls h.Finish
+func (h HookFuncs) OnFinish(ctx context.Context, ev OpEvent) {
+	if h.Finish != nil {
+		h.Finish(ctx, ev)
+	}
+}
+
+// IsProtocolError reports whether err is a normal memcache protocol result, a
+// miss or failed condition, rather than a failure talking to the server
+func IsProtocolError(err error) bool {
+	return isProtocolError(err)
+}
@@ -0,0 +1,60 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestHooks(t *testing.T) {
+	var mu sync.Mutex
+	var started, finished []OpEvent
+	var ctxs []interface{}
+	hooks := HookFuncs{
+		Start: func(ctx context.Context, ev OpEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, ev)
+		},
+		Finish: func(ctx context.Context, ev OpEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			finished = append(finished, ev)
+			ctxs = append(ctxs, ctx.Value(parentKey{}))
+		},
+	}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Hooks: hooks, KeySanitizer: KeyPattern})
+
+	mc.SetString("hooks:12345", "value")
+	ctx := context.WithValue(context.Background(), parentKey{}, "request")
+	mc.GetCtx(ctx, "hooks:12345")
+	mc.Get("hooks:missing")
+
+	if len(started) != 3 || len(finished) != 3 {
+		t.Fatalf("Expected 3 started and finished events, got %d and %d", len(started), len(finished))
+	}
+	set, get, miss := finished[0], finished[1], finished[2]
+	if set.Op != OpSet || set.Key != "hooks:*" || set.Server != "local" || set.Err != nil {
+		t.Errorf("Unexpected set event %+v", set)
+	}
+	if get.Op != OpGet || get.Err != nil || ctxs[1] != "request" {
+		t.Errorf("Expected a hit made with the request context, got %+v (%v)", get, ctxs[1])
+	}
+	if miss.Err != memcache.ErrCacheMiss || !IsProtocolError(miss.Err) {
+		t.Errorf("Expected a miss, got %v", miss.Err)
+	}
+	for i, ev := range started {
+		if ev.Err != nil || ev.Duration != 0 || ev.Start != finished[i].Start {
+			t.Errorf("Unexpected start event %+v", ev)
+		}
+	}
+}
+
+func TestHookFuncsNil(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Hooks: HookFuncs{}})
+	if err := mc.SetString("hooks:nil", "value"); err != nil {
+		t.Fatal(err)
+	}
+}
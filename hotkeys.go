@@ -0,0 +1,72 @@
+package memcache
+
+import (
+	"sort"
+	"sync"
+)
+
+// HotKey is an approximate read count for a frequently read key
+type HotKey struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// HotKeys returns the most frequently read keys, most frequent first. Counts are
+// approximate (over-estimated by at most the smallest tracked count). Tracking
+// requires Options.HotKeys to be set.
+func (c *Client) HotKeys() []HotKey {
+	if c.hotKeys == nil {
+		return nil
+	}
+	return c.hotKeys.top()
+}
+
+// hotKeyTracker estimates the top keys in a stream with bounded memory using the
+// space-saving algorithm: when full, a new key replaces the least counted key and
+// inherits its count.
+type hotKeyTracker struct {
+	mu     sync.Mutex
+	size   int
+	counts map[string]int64
+}
+
+func newHotKeyTracker(size int) *hotKeyTracker {
+	return &hotKeyTracker{
+		size:   size,
+		counts: make(map[string]int64, size),
+	}
+}
+
+func (t *hotKeyTracker) add(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.counts[key]; ok || len(t.counts) < t.size {
+		t.counts[key]++
+		return
+	}
+	var minKey string
+	var min int64 = -1
+	for k, n := range t.counts {
+		if min == -1 || n < min {
+			minKey, min = k, n
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = min + 1
+}
+
+func (t *hotKeyTracker) top() []HotKey {
+	t.mu.Lock()
+	keys := make([]HotKey, 0, len(t.counts))
+	for k, n := range t.counts {
+		keys = append(keys, HotKey{k, n})
+	}
+	t.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count == keys[j].Count {
+			return keys[i].Key < keys[j].Key
+		}
+		return keys[i].Count > keys[j].Count
+	})
+	return keys
+}
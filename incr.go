@@ -0,0 +1,43 @@
+package memcache
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Incr increments the integer at key by delta with memcached's incr, returning
+// the new value. A missing key is created holding initial, flagged as a Python
+// int (FLAG_INTEGER, or FLAG_LONG beyond int64) so pylibmc reads it as an int,
+// and expiring in ttl seconds; initial is then returned without delta applied,
+// as libmemcached's increment_with_initial does.
+func (c *Client) Incr(key string, delta, initial uint64, ttl int32) (uint64, error) {
+	return c.incrWithInitial(key, initial, ttl, func() (uint64, error) { return c.Increment(key, delta) })
+}
+
+// Decr is Incr decrementing with memcached's decr, which stops at 0
+func (c *Client) Decr(key string, delta, initial uint64, ttl int32) (uint64, error) {
+	return c.incrWithInitial(key, initial, ttl, func() (uint64, error) { return c.Decrement(key, delta) })
+}
+
+// incrWithInitial runs incr, adding key holding initial if it is missing. If
+// another writer adds it first incr is run again.
+func (c *Client) incrWithInitial(key string, initial uint64, ttl int32, incr func() (uint64, error)) (uint64, error) {
+	n, err := incr()
+	if err != memcache.ErrCacheMiss {
+		return n, err
+	}
+	flags := uint32(FLAG_INTEGER)
+	if initial > math.MaxInt64 {
+		flags = FLAG_LONG
+	}
+	err = c.Add(&memcache.Item{Key: key, Value: strconv.AppendUint(nil, initial, 10), Flags: flags, Expiration: ttl})
+	switch err {
+	case nil:
+		return initial, nil
+	case memcache.ErrNotStored:
+		return incr()
+	}
+	return 0, err
+}
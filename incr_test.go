@@ -0,0 +1,42 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestIncrDecr(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("incr_counter")
+
+	if n, err := mc.Incr("incr_counter", 2, 10, 0); err != nil || n != 10 {
+		t.Fatalf("Expected the initial 10 got %d %v", n, err)
+	}
+	if i, err := mc.Get("incr_counter"); err != nil || i.Flags != FLAG_INTEGER {
+		t.Errorf("Expected FLAG_INTEGER got %v %v", i, err)
+	}
+	if n, err := mc.Incr("incr_counter", 2, 10, 0); err != nil || n != 12 {
+		t.Errorf("Expected 12 got %d %v", n, err)
+	}
+	if n, ok := mc.GetInt64("incr_counter"); !ok || n != 12 {
+		t.Errorf("Expected GetInt64 12 got %d %v", n, ok)
+	}
+	if n, err := mc.Decr("incr_counter", 20, 10, 0); err != nil || n != 0 {
+		t.Errorf("Expected decr to stop at 0 got %d %v", n, err)
+	}
+
+	mc.Delete("decr_counter")
+	if n, err := mc.Decr("decr_counter", 1, 5, 100); err != nil || n != 5 {
+		t.Errorf("Expected the initial 5 got %d %v", n, err)
+	}
+	if n, err := mc.Decr("decr_counter", 1, 5, 100); err != nil || n != 4 {
+		t.Errorf("Expected 4 got %d %v", n, err)
+	}
+
+	mc.Delete("incr_long")
+	if _, err := mc.Incr("incr_long", 1, 1<<63, 0); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := mc.Get("incr_long"); err != nil || i.Flags != FLAG_LONG {
+		t.Errorf("Expected FLAG_LONG beyond int64 got %v %v", i, err)
+	}
+}
@@ -0,0 +1,88 @@
+package memcache
+
+import (
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultIncrFloatRetries is how many times IncrFloat retries after losing a CAS race
+const DefaultIncrFloatRetries = 10
+
+// IncrFloat adds delta to the float stored at key and returns the new value.
+// memcached's incr only handles integers so this is a gets/cas loop storing a
+// pickled Python float, readable from Python as a float. Integers (including
+// ones stored by incr) are accepted and become floats. key is created holding
+// delta if it doesn't exist. Like UpdateInPlace the item's TTL is carried
+// forward.
+func (c *Client) IncrFloat(key string, delta float64) (float64, error) {
+	for attempt := 0; ; attempt++ {
+		var n float64
+		i, err := c.casGet(key)
+		switch err {
+		case nil:
+			var v float64
+			if v, err = decodeNumber(i.Value, i.Flags); err != nil {
+				return 0, err
+			}
+			n = v + delta
+			if i.Value, err = c.pickle(n); err != nil {
+				return 0, err
+			}
+			i.Flags = FLAG_PICKLE
+			if i.Expiration, err = c.currentExpiration(key); err == nil {
+				err = c.CompareAndSwap(i)
+			}
+		case memcache.ErrCacheMiss:
+			n = delta
+			i = &memcache.Item{Key: key, Flags: FLAG_PICKLE}
+			if i.Value, err = c.pickle(n); err != nil {
+				return 0, err
+			}
+			err = c.Add(i)
+		}
+		switch err {
+		case nil:
+			return n, nil
+		case memcache.ErrCASConflict, memcache.ErrCacheMiss, memcache.ErrNotStored:
+			// lost a race with another writer (or a delete)
+			if attempt < DefaultIncrFloatRetries {
+				continue
+			}
+		}
+		return 0, err
+	}
+}
+
+// decodeNumber decodes an integer or a pickled int or float as a float64
+func decodeNumber(value []byte, flags uint32) (float64, error) {
+	switch flags {
+	case FLAG_INTEGER, FLAG_LONG:
+		n, err := parseInt64(value)
+		return float64(n), err
+	case FLAG_PICKLE:
+		v, err := unpickle(value)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := toFloat(v)
+		if !ok {
+			return 0, fmt.Errorf("%w: expected a number got %T", InvalidType, v)
+		}
+		return n, nil
+	}
+	return 0, InvalidType
+}
+
+// toFloat converts an unpickled int or float to a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+func TestIncrFloat(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("incr_float")
+	if n, err := mc.IncrFloat("incr_float", 1.5); err != nil || n != 1.5 {
+		t.Fatalf("Expected 1.5, got %v %v", n, err)
+	}
+	if n, err := mc.IncrFloat("incr_float", -0.25); err != nil || n != 1.25 {
+		t.Errorf("Expected 1.25, got %v %v", n, err)
+	}
+	i, _ := mc.Get("incr_float")
+	// pickle.dumps(1.25, 2)
+	if string(i.Value) != "\x80\x02G?\xf4\x00\x00\x00\x00\x00\x00." || i.Flags != FLAG_PICKLE {
+		t.Errorf("Expected a pickled float, got %q %d", i.Value, i.Flags)
+	}
+
+	mc.SetInt64("incr_float_int", 3)
+	if n, err := mc.IncrFloat("incr_float_int", 0.5); err != nil || n != 3.5 {
+		t.Errorf("Expected 3.5, got %v %v", n, err)
+	}
+	b, _ := picklecompat.Encode(int64(2))
+	mc.Set(&memcache.Item{Key: "incr_float_pickled_int", Value: b, Flags: FLAG_PICKLE})
+	if n, err := mc.IncrFloat("incr_float_pickled_int", 0.5); err != nil || n != 2.5 {
+		t.Errorf("Expected 2.5, got %v %v", n, err)
+	}
+	mc.SetString("incr_float_string", "x")
+	if _, err := mc.IncrFloat("incr_float_string", 1); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
+
+func TestIncrFloatConcurrent(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("incr_float_concurrent")
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mc.IncrFloat("incr_float_concurrent", 0.5); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := mc.IncrFloat("incr_float_concurrent", 0); err != nil || n != 5 {
+		t.Errorf("Expected 5, got %v %v", n, err)
+	}
+}
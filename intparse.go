@@ -0,0 +1,40 @@
+package memcache
+
+import (
+	"strconv"
+)
+
+// maxFastDigits is the most digits that can't overflow an int64
+const maxFastDigits = 18
+
+// parseInt64 parses the decimal integer stored by pylibmc for FLAG_INTEGER and
+// FLAG_LONG values without allocating. Anything other than an optionally signed
+// run of up to 18 digits is handed to strconv.ParseInt so errors (and the edge
+// cases near the int64 limits) are identical.
+func parseInt64(b []byte) (int64, error) {
+	digits := b
+	neg := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		neg = digits[0] == '-'
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > maxFastDigits {
+		return strconv.ParseInt(string(b), 10, 64)
+	}
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return strconv.ParseInt(string(b), 10, 64)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// formatInt64 returns the decimal bytes of n with a single allocation
+func formatInt64(n int64) []byte {
+	return strconv.AppendInt(make([]byte, 0, 20), n, 10)
+}
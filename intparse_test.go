@@ -0,0 +1,58 @@
+package memcache
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestParseInt64(t *testing.T) {
+	for _, s := range []string{
+		"0", "7", "-7", "+7", "1234567890", "-123456789012345678", "999999999999999999",
+		"9223372036854775807", "-9223372036854775808", "9223372036854775808",
+		"", "-", "+", "12a", " 1", "1 ", "1.5", "0x10", "00012",
+	} {
+		want, wantErr := strconv.ParseInt(s, 10, 64)
+		got, err := parseInt64([]byte(s))
+		if got != want || (err == nil) != (wantErr == nil) || (err != nil && err.Error() != wantErr.Error()) {
+			t.Errorf("parseInt64(%q) = %d %v, want %d %v", s, got, err, want, wantErr)
+		}
+	}
+	for _, n := range []int64{0, -1, 42, math.MaxInt64, math.MinInt64} {
+		if got := string(formatInt64(n)); got != strconv.FormatInt(n, 10) {
+			t.Errorf("formatInt64(%d) = %s", n, got)
+		}
+	}
+}
+
+func TestParseInt64Allocs(t *testing.T) {
+	b := []byte("-1234567890")
+	if n := testing.AllocsPerRun(100, func() { parseInt64(b) }); n != 0 {
+		t.Errorf("Expected no allocations, got %v", n)
+	}
+}
+
+func BenchmarkItemInt64(b *testing.B) {
+	i := &Item{&memcache.Item{Value: []byte("1234567890"), Flags: FLAG_INTEGER}}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i.Int64()
+	}
+}
+
+func BenchmarkItemInt64Strconv(b *testing.B) {
+	value := []byte("1234567890")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		strconv.ParseInt(string(value), 10, 64)
+	}
+}
+
+func BenchmarkInt64Item(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		Int64Item("k", int64(n))
+	}
+}
@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"sync"
+)
+
+// maxPooledBatch is the largest batch kept for reuse so one huge GetMulti doesn't
+// pin its memory for the life of the process
+const maxPooledBatch = 4096
+
+var itemBatchPool = sync.Pool{
+	New: func() interface{} { return &ItemBatch{items: make(map[string]*Item)} },
+}
+
+// ItemBatch holds the Items from one GetMultiBatch call. Its Item wrappers and
+// map are reused across calls to cut allocations on GetMulti heavy paths, so
+// the batch and every Item from it must not be used after Release.
+type ItemBatch struct {
+	items    map[string]*Item
+	wrappers []Item
+}
+
+// GetMultiBatch is GetMulti returning the items as a pooled ItemBatch. As with
+// GetMulti an error may be returned along with the items that were fetched.
+// Call Release once done with the batch.
+func (c *Client) GetMultiBatch(keys []string) (*ItemBatch, error) {
+	m, err := c.GetMulti(keys)
+	b := itemBatchPool.Get().(*ItemBatch)
+	if cap(b.wrappers) < len(m) {
+		b.wrappers = make([]Item, len(m))
+	}
+	b.wrappers = b.wrappers[:len(m)]
+	n := 0
+	for k, i := range m {
+		b.wrappers[n].Item = i
+		b.items[k] = &b.wrappers[n]
+		n++
+	}
+	return b, err
+}
+
+// Get returns the item for key, or false if it was a miss
+func (b *ItemBatch) Get(key string) (*Item, bool) {
+	i, ok := b.items[key]
+	return i, ok
+}
+
+// Len returns the number of items (hits) in the batch
+func (b *ItemBatch) Len() int {
+	return len(b.items)
+}
+
+// Range calls fn for each item in the batch until fn returns false
+func (b *ItemBatch) Range(fn func(key string, i *Item) bool) {
+	for k, i := range b.items {
+		if !fn(k, i) {
+			return
+		}
+	}
+}
+
+// Release returns the batch to the pool. The batch and its Items must not be
+// used afterwards.
+func (b *ItemBatch) Release() {
+	for k := range b.items {
+		delete(b.items, k)
+	}
+	for n := range b.wrappers {
+		b.wrappers[n].Item = nil
+	}
+	if cap(b.wrappers) > maxPooledBatch {
+		return
+	}
+	b.wrappers = b.wrappers[:0]
+	itemBatchPool.Put(b)
+}
@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGetMultiBatch(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(Int64Item("batch_a", 1))
+	mc.Set(StringItem("batch_b", "two"))
+
+	for round := 0; round < 3; round++ {
+		b, err := mc.GetMultiBatch([]string{"batch_a", "batch_b", "batch_missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Len() != 2 {
+			t.Errorf("Expected 2 items, got %d", b.Len())
+		}
+		if i, ok := b.Get("batch_a"); !ok {
+			t.Errorf("Expected batch_a")
+		} else if n, err := i.Int64(); err != nil || n != 1 {
+			t.Errorf("Expected 1, got %d %v", n, err)
+		}
+		if i, ok := b.Get("batch_b"); !ok {
+			t.Errorf("Expected batch_b")
+		} else if s, err := i.String(); err != nil || s != "two" {
+			t.Errorf("Expected two, got %q %v", s, err)
+		}
+		if _, ok := b.Get("batch_missing"); ok {
+			t.Errorf("Expected a miss")
+		}
+		seen := 0
+		b.Range(func(key string, i *Item) bool {
+			if i.Key != key {
+				t.Errorf("Expected item for %s, got %s", key, i.Key)
+			}
+			seen++
+			return true
+		})
+		if seen != 2 {
+			t.Errorf("Expected Range over 2 items, got %d", seen)
+		}
+		b.Release()
+		if b.Len() != 0 {
+			t.Errorf("Expected Release to clear the batch")
+		}
+	}
+}
+
+func BenchmarkGetMultiBatch(b *testing.B) {
+	mc := NewClient([]string{LocalAddress})
+	keys := make([]string, 100)
+	for n := range keys {
+		keys[n] = "bench_batch_" + strconv.Itoa(n)
+		mc.Set(Int64Item(keys[n], int64(n)))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		batch, _ := mc.GetMultiBatch(keys)
+		batch.Range(func(_ string, i *Item) bool {
+			i.Int64()
+			return true
+		})
+		batch.Release()
+	}
+}
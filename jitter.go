@@ -0,0 +1,42 @@
+package memcache
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// withJitter returns item, or a copy with its expiration shortened by a random
+// fraction of up to Options.ExpirationJitter
+func (c *Client) withJitter(item *memcache.Item) *memcache.Item {
+	if c.opts.ExpirationJitter <= 0 || item.Expiration <= 0 {
+		return item
+	}
+	exp := c.jitterExpiration(item.Expiration, rand.Float64())
+	if exp == item.Expiration {
+		return item
+	}
+	cp := *item
+	cp.Expiration = exp
+	return &cp
+}
+
+// jitterExpiration shortens the TTL of expiration exp by r (0-1) times
+// Options.ExpirationJitter of it, to no less than a second
+func (c *Client) jitterExpiration(exp int32, r float64) int32 {
+	jitter := c.opts.ExpirationJitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	ttl := expirationTTL(exp, c.now())
+	if ttl <= time.Second {
+		return exp
+	}
+	ttl -= time.Duration(float64(ttl) * jitter * r)
+	secs := int64(ttl.Round(time.Second) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return c.expirationFor(secs)
+}
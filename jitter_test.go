@@ -0,0 +1,52 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestExpirationJitter(t *testing.T) {
+	clock := NewFakeClock(time.Unix(time.Now().Unix(), 0))
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{ExpirationJitter: 0.2, Clock: clock})
+	for _, tc := range []struct {
+		exp  int32
+		r    float64
+		want int32
+	}{
+		{100, 0, 100},
+		{100, 0.5, 90},
+		{100, 1, 80},
+		{1, 1, 1},
+		{int32(clock.Now().Unix() + 100*24*60*60), 1, int32(clock.Now().Unix() + 80*24*60*60)},
+	} {
+		if got := mc.jitterExpiration(tc.exp, tc.r); got != tc.want {
+			t.Errorf("Expected %d jittered by %v to be %d, got %d", tc.exp, tc.r, tc.want, got)
+		}
+	}
+
+	var ttls []time.Duration
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{ExpirationJitter: 0.5, WriteSampleRate: 1, OnWrite: func(r WriteRecord) {
+		ttls = append(ttls, r.TTL)
+	}})
+	for n := 0; n < 20; n++ {
+		if err := mc.Set(&memcache.Item{Key: "jitter_key", Value: []byte("v"), Expiration: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mc.Set(&memcache.Item{Key: "jitter_key", Value: []byte("v")})
+	distinct := make(map[time.Duration]bool)
+	for _, ttl := range ttls[:20] {
+		if ttl < 500*time.Second || ttl > 1000*time.Second {
+			t.Errorf("Expected a TTL within 500-1000s, got %s", ttl)
+		}
+		distinct[ttl] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("Expected jittered TTLs, got %v", ttls)
+	}
+	if ttls[20] != 0 {
+		t.Errorf("Expected items without expiration to be left alone, got %s", ttls[20])
+	}
+}
@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"encoding/json"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// FLAG_JSON marks values written by SetJSON. Python clients don't read them.
+const FLAG_JSON uint32 = 1 << 18
+
+// SetJSON stores v encoded with encoding/json under FLAG_JSON through the
+// client's Pipeline, for Go only keys holding native structs
+func (c *Client) SetJSON(key string, v interface{}, ttl int32) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	item, err := c.opts.Pipeline.encode(key, b, FLAG_JSON)
+	if err != nil {
+		return err
+	}
+	item.Expiration = ttl
+	return c.Set(item)
+}
+
+// GetJSON decodes the value SetJSON stored under key into dst with
+// encoding/json. ErrCacheMiss is returned for a miss and InvalidType for
+// values not written by SetJSON.
+func (c *Client) GetJSON(key string, dst interface{}, opts ...CallOption) error {
+	_, err := getDecoded(c.getter(opts), key, func(i *Item) (struct{}, error) {
+		return struct{}{}, i.JSON(dst)
+	})
+	return err
+}
+
+// JSON decodes a value written by SetJSON into dst
+func (i *Item) JSON(dst interface{}) error {
+	i, err := i.inflated()
+	if err != nil {
+		return err
+	}
+	if i.Flags != FLAG_JSON {
+		return InvalidType
+	}
+	return json.Unmarshal(i.Value, dst)
+}
+
+// JSONItem returns a memcache.Item storing v the way SetJSON does, without
+// a Pipeline
+func JSONItem(k string, v interface{}) (*memcache.Item, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_JSON,
+	}, nil
+}
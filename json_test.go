@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestJSON(t *testing.T) {
+	type user struct {
+		Name  string
+		Roles []string
+	}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{MinCompressLen: 10, UnknownFlags: UnknownFlagsError})
+	want := user{strings.Repeat("ada", 50), []string{"admin", "dev"}}
+	if err := mc.SetJSON("json", want, 60); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := mc.Get("json"); err != nil || i.Flags != FLAG_JSON|FLAG_ZLIB {
+		t.Errorf("Expected a compressed JSON value got %v %v", i, err)
+	}
+	var got user
+	if err := mc.GetJSON("json", &got, WithTimeout(time.Second)); err != nil || got.Name != want.Name || len(got.Roles) != 2 {
+		t.Errorf("unexpected GetJSON %+v %v", got, err)
+	}
+	if _, ok := mc.GetString("json"); ok {
+		t.Error("Expected JSON not to be read as a string")
+	}
+
+	item, _ := JSONItem("json", map[string]int{"a": 1})
+	mc.Set(item)
+	if v, err := Deserialize(item.Value, item.Flags); err != nil || v.(map[string]interface{})["a"] != 1.0 {
+		t.Errorf("unexpected Deserialize %v %v", v, err)
+	}
+	var m map[string]int
+	if err := mc.GetJSON("json", &m); err != nil || m["a"] != 1 {
+		t.Errorf("unexpected GetJSON %v %v", m, err)
+	}
+
+	mc.Set(UnicodeItem("json", "s"))
+	if err := mc.GetJSON("json", &m); err != InvalidType {
+		t.Errorf("Expected InvalidType got %v", err)
+	}
+	mc.Delete("json")
+	if err := mc.GetJSON("json", &m); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if err := mc.SetJSON("json", func() {}, 0); err == nil {
+		t.Error("Expected an error encoding a func")
+	}
+}
@@ -0,0 +1,199 @@
+package ketamacompat
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+)
+
+// HashFunc hashes keys, and the names of continuum points, to positions on
+// the continuum
+type HashFunc func(s string) uint32
+
+// hashes are the libmemcached hash functions by the names pylibmc's "hash"
+// behavior takes
+var hashes = map[string]HashFunc{
+	"one_at_a_time": Hash,
+	"md5":           HashMD5,
+	"crc":           HashCRC32,
+	"fnv1_64":       HashFNV1_64,
+	"fnv1a_64":      HashFNV1a_64,
+	"fnv1_32":       HashFNV1_32,
+	"fnv1a_32":      HashFNV1a_32,
+	"hsieh":         HashHsieh,
+	"murmur":        HashMurmur,
+	"murmur3":       HashMurmur3,
+}
+
+// HashByName returns the hash function libmemcached names name, e.g. "crc"
+// or "fnv1a_32" as given to pylibmc's "hash" behavior
+func HashByName(name string) (HashFunc, bool) {
+	h, ok := hashes[name]
+	return h, ok
+}
+
+// HashCRC32 is libmemcached's MEMCACHED_HASH_CRC: bits 16 to 30 of the
+// IEEE CRC-32 of s
+func HashCRC32(s string) uint32 {
+	return crc32.ChecksumIEEE([]byte(s)) >> 16 & 0x7fff
+}
+
+const (
+	fnv64Init  = 0xcbf29ce484222325
+	fnv64Prime = 0x100000001b3
+	fnv32Init  = 2166136261
+	fnv32Prime = 16777619
+)
+
+// HashFNV1_64 is libmemcached's MEMCACHED_HASH_FNV1_64: the low 32 bits of
+// the 64 bit FNV-1 hash of s
+func HashFNV1_64(s string) uint32 {
+	h := uint64(fnv64Init)
+	for i := 0; i < len(s); i++ {
+		h *= fnv64Prime
+		h ^= uint64(s[i])
+	}
+	return uint32(h)
+}
+
+// HashFNV1a_64 is libmemcached's MEMCACHED_HASH_FNV1A_64: the low 32 bits of
+// the 64 bit FNV-1a hash of s
+func HashFNV1a_64(s string) uint32 {
+	h := uint64(fnv64Init)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnv64Prime
+	}
+	return uint32(h)
+}
+
+// HashFNV1_32 is libmemcached's MEMCACHED_HASH_FNV1_32
+func HashFNV1_32(s string) uint32 {
+	h := uint32(fnv32Init)
+	for i := 0; i < len(s); i++ {
+		h *= fnv32Prime
+		h ^= uint32(s[i])
+	}
+	return h
+}
+
+// HashFNV1a_32 is libmemcached's MEMCACHED_HASH_FNV1A_32
+func HashFNV1a_32(s string) uint32 {
+	h := uint32(fnv32Init)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= fnv32Prime
+	}
+	return h
+}
+
+// HashHsieh is libmemcached's MEMCACHED_HASH_HSIEH, Paul Hsieh's
+// SuperFastHash starting from zero rather than the length
+func HashHsieh(s string) uint32 {
+	if len(s) == 0 {
+		return 0
+	}
+	get16 := func(i int) uint32 { return uint32(s[i]) | uint32(s[i+1])<<8 }
+	var h uint32
+	i := 0
+	for ; len(s)-i >= 4; i += 4 {
+		h += get16(i)
+		tmp := get16(i+2)<<11 ^ h
+		h = h<<16 ^ tmp
+		h += h >> 11
+	}
+	switch len(s) - i {
+	case 3:
+		h += get16(i)
+		h ^= h << 16
+		// libmemcached reads this byte as a signed char
+		h ^= uint32(int8(s[i+2])) << 18
+		h += h >> 11
+	case 2:
+		h += get16(i)
+		h ^= h << 11
+		h += h >> 17
+	case 1:
+		h += uint32(s[i])
+		h ^= h << 10
+		h += h >> 1
+	}
+	h ^= h << 3
+	h += h >> 5
+	h ^= h << 4
+	h += h >> 17
+	h ^= h << 25
+	h += h >> 6
+	return h
+}
+
+// HashMurmur is libmemcached's MEMCACHED_HASH_MURMUR, MurmurHash2 seeded with
+// 0xdeadbeef times the length
+func HashMurmur(s string) uint32 {
+	const m = 0x5bd1e995
+	n := uint32(len(s))
+	h := 0xdeadbeef*n ^ n
+	i := 0
+	for ; len(s)-i >= 4; i += 4 {
+		k := binary.LittleEndian.Uint32([]byte(s[i : i+4]))
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(s) - i {
+	case 3:
+		h ^= uint32(s[i+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(s[i+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(s[i])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// HashMurmur3 is libmemcached's MEMCACHED_HASH_MURMUR3, the 32 bit x86
+// MurmurHash3 with a zero seed
+func HashMurmur3(s string) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	var h uint32
+	i := 0
+	for ; len(s)-i >= 4; i += 4 {
+		k := binary.LittleEndian.Uint32([]byte(s[i : i+4]))
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	switch len(s) - i {
+	case 3:
+		k ^= uint32(s[i+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(s[i+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(s[i])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(s))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,36 @@
+package ketamacompat
+
+import (
+	"testing"
+)
+
+// TestHashes checks each hash against a transliteration of libhashkit
+func TestHashes(t *testing.T) {
+	inputs := []string{"", "a", "hello", "\xff\xfe\xfd", "memcache:key"}
+	for _, tc := range []struct {
+		name string
+		hash HashFunc
+		want []uint32
+	}{
+		{"crc", HashCRC32, []uint32{0, 26807, 13840, 2282, 21617}},
+		{"fnv1_64", HashFNV1_64, []uint32{2216829733, 2248259518, 3183334599, 1776819591, 4187262433}},
+		{"fnv1a_64", HashFNV1a_64, []uint32{2216829733, 2248273036, 2158673163, 3833053655, 4238259769}},
+		{"fnv1_32", HashFNV1_32, []uint32{2166136261, 84696446, 3069866343, 2962151431, 1583838017}},
+		{"fnv1a_32", HashFNV1a_32, []uint32{2166136261, 3826002220, 1335831723, 2371999255, 226044825}},
+		{"hsieh", HashHsieh, []uint32{0, 2472816263, 327428805, 3649655134, 511060347}},
+		{"murmur", HashMurmur, []uint32{0, 1262581116, 1377504255, 3269769215, 3333548632}},
+		{"murmur3", HashMurmur3, []uint32{0, 1009084850, 613153351, 3535729372, 243139559}},
+	} {
+		for n, s := range inputs {
+			if got := tc.hash(s); got != tc.want[n] {
+				t.Errorf("%s(%q): expected %d got %d", tc.name, s, tc.want[n], got)
+			}
+		}
+		if _, ok := HashByName(tc.name); !ok {
+			t.Errorf("Expected HashByName to know %s", tc.name)
+		}
+	}
+	if _, ok := HashByName("jenkins"); ok {
+		t.Errorf("Expected HashByName not to know jenkins")
+	}
+}
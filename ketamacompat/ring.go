@@ -0,0 +1,291 @@
+// Package ketamacompat places keys on servers exactly like libmemcached's
+// non-weighted ketama distribution with the Jenkins one-at-a-time hash, which is
+// what pylibmc uses with behaviors {"ketama": True, "hash": "jenkins"} (ketama
+// with MEMCACHED_BEHAVIOR_KETAMA_HASH unset), or with another of its hash
+// functions (see HashByName), and like its weighted ketama distribution, which
+// pylibmc uses with {"ketama_weighted": True}. It can be used to shard anything
+// compatibly with those clients, not only memcached.
+package ketamacompat
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/dgryski/dgohash"
+)
+
+// PointsPerServer is the number of points each server gets on the continuum
+const PointsPerServer = 100
+
+// WeightedPointsPerServer is the average number of points a server gets on the
+// weighted continuum
+const WeightedPointsPerServer = 160
+
+// defaultPort is the port libmemcached leaves out when naming the points of
+// servers on the weighted continuum
+const defaultPort = "11211"
+
+// unixPrefix is the scheme of unix domain socket servers given as URLs
+const unixPrefix = "unix://"
+
+// SocketPath returns the path of a unix domain socket server, given as an
+// absolute path ("/var/run/memcached.sock") or URL ("unix:///var/run/memcached.sock")
+func SocketPath(server string) (path string, ok bool) {
+	if strings.HasPrefix(server, unixPrefix) {
+		server = server[len(unixPrefix):]
+	}
+	return server, strings.HasPrefix(server, "/")
+}
+
+// pointName returns the name the points of server are hashed from. libmemcached
+// names a unix domain socket by its path with port 0.
+func pointName(server string) string {
+	if path, ok := SocketPath(server); ok {
+		return path + ":0"
+	}
+	return server
+}
+
+// Point is one position on the continuum owned by a server
+type Point struct {
+	Hash   uint32
+	Server string
+}
+
+type points []Point
+
+func (p points) Less(i, j int) bool { return p[i].Hash < p[j].Hash }
+func (p points) Len() int           { return len(p) }
+func (p points) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Ring is an immutable ketama continuum. It is safe for concurrent use.
+type Ring struct {
+	servers []string
+	points  points
+	hash    HashFunc
+}
+
+// NewRing builds the continuum for servers, given as the "host:port" strings
+// configured in the Python clients (they are hashed as written, not resolved)
+// or as unix domain socket paths (see SocketPath)
+func NewRing(servers []string) *Ring {
+	return NewRingWithHash(servers, Hash)
+}
+
+// NewRingWithHash is NewRing with points and keys hashed by hash rather than
+// Jenkins one-at-a-time, to match clients configured with another hash
+func NewRingWithHash(servers []string, hash HashFunc) *Ring {
+	return NewRingWithOptions(servers, RingOptions{Hash: hash})
+}
+
+// RingOptions configures the continuum built by NewRingWithOptions and
+// NewWeightedRingWithOptions
+type RingOptions struct {
+	// Hash hashes points and keys of the non-weighted continuum. nil uses
+	// Hash. The weighted continuum always hashes with MD5.
+	Hash HashFunc
+	// PointsPerServer is the number of points each server gets on the
+	// non-weighted continuum, or the average number on the weighted one, for
+	// libmemcached builds changing MEMCACHED_POINTS_PER_SERVER or
+	// MEMCACHED_POINTS_PER_SERVER_KETAMA. Zero uses PointsPerServer, or
+	// WeightedPointsPerServer.
+	PointsPerServer int
+}
+
+// NewRingWithOptions is NewRing configured by opts
+func NewRingWithOptions(servers []string, opts RingOptions) *Ring {
+	hash, perServer := opts.Hash, opts.PointsPerServer
+	if hash == nil {
+		hash = Hash
+	}
+	if perServer <= 0 {
+		perServer = PointsPerServer
+	}
+	r := &Ring{
+		servers: append([]string(nil), servers...),
+		points:  make(points, 0, len(servers)*perServer),
+		hash:    hash,
+	}
+	for _, s := range servers {
+		name := pointName(s)
+		for k := 0; k < perServer; k++ {
+			r.points = append(r.points, Point{Hash: hash(fmt.Sprintf("%s-%d", name, k)), Server: s})
+		}
+	}
+	sortPoints(r.points)
+	return r
+}
+
+// NewWeightedRing builds the continuum libmemcached builds with
+// MEMCACHED_BEHAVIOR_KETAMA_WEIGHTED for servers given as "host:port" with
+// their weights (zero is taken as 1, as libmemcached does). Each server gets
+// about WeightedPointsPerServer points per average weight, four from each MD5
+// digest of "host-n" ("host:port-n" when the port isn't 11211, "path:0-n" for
+// unix domain sockets), and keys are hashed with HashMD5.
+func NewWeightedRing(weights map[string]uint64) *Ring {
+	return NewWeightedRingWithOptions(weights, RingOptions{})
+}
+
+// NewWeightedRingWithOptions is NewWeightedRing configured by opts
+func NewWeightedRingWithOptions(weights map[string]uint64, opts RingOptions) *Ring {
+	perServer := opts.PointsPerServer
+	if perServer <= 0 {
+		perServer = WeightedPointsPerServer
+	}
+	servers := make([]string, 0, len(weights))
+	var total uint64
+	for s, w := range weights {
+		servers = append(servers, s)
+		if w == 0 {
+			w = 1
+		}
+		total += w
+	}
+	sort.Strings(servers)
+	r := &Ring{
+		servers: servers,
+		points:  make(points, 0, len(servers)*perServer),
+		hash:    HashMD5,
+	}
+	for _, s := range servers {
+		w := weights[s]
+		if w == 0 {
+			w = 1
+		}
+		// libmemcached's float arithmetic, to round the same way
+		pct := float32(w) / float32(total)
+		hashes := float64(pct * float32(perServer) / 4 * float32(len(servers)))
+		n := uint32(math.Floor(float64(float32(hashes + 0.0000000001))))
+		name := pointName(s)
+		if host, port, err := net.SplitHostPort(s); err == nil && port == defaultPort {
+			name = host
+		}
+		for k := uint32(0); k < n; k++ {
+			digest := md5.Sum([]byte(fmt.Sprintf("%s-%d", name, k)))
+			for x := 0; x < 4; x++ {
+				r.points = append(r.points, Point{Hash: binary.LittleEndian.Uint32(digest[x*4:]), Server: s})
+			}
+		}
+	}
+	sortPoints(r.points)
+	return r
+}
+
+// sortPoints sorts the continuum as libmemcached does with glibc's qsort, a
+// merge sort: points sharing a hash keep the order they were added in, so the
+// first server given (the first sorted for the weighted continuum) owns keys
+// hashing to them. goketama, which this package replaced, left their order to
+// sort.Sort.
+func sortPoints(p points) {
+	sort.Stable(p)
+}
+
+// Collision is a hash shared by continuum points of different servers
+type Collision struct {
+	Hash uint32
+	// Servers holds the server of each point with the hash in continuum order;
+	// the first owns keys hashing to it
+	Servers []string
+}
+
+// Collisions returns the hashes shared by points of different servers, which
+// implementations breaking ties differently place differently. They are rare
+// with 32 bit hashes but common with HashCRC32, which has 15 bits.
+func (r *Ring) Collisions() []Collision {
+	var collisions []Collision
+	for i := 0; i < len(r.points); {
+		j := i + 1
+		distinct := false
+		for ; j < len(r.points) && r.points[j].Hash == r.points[i].Hash; j++ {
+			distinct = distinct || r.points[j].Server != r.points[i].Server
+		}
+		if distinct {
+			c := Collision{Hash: r.points[i].Hash}
+			for _, p := range r.points[i:j] {
+				c.Servers = append(c.Servers, p.Server)
+			}
+			collisions = append(collisions, c)
+		}
+		i = j
+	}
+	return collisions
+}
+
+// HashMD5 returns the hash of s libmemcached uses for keys with the MD5 hash:
+// the first four bytes of its MD5 digest, little endian
+func HashMD5(s string) uint32 {
+	digest := md5.Sum([]byte(s))
+	return binary.LittleEndian.Uint32(digest[:4])
+}
+
+// Hash returns the Jenkins one-at-a-time hash of s used for keys and points
+func Hash(s string) uint32 {
+	h := dgohash.NewJenkins32()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// ServerFor returns the server owning key: the first point at or after the
+// key's hash, wrapping around. It returns "" if the ring has no servers.
+func (r *Ring) ServerFor(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := r.hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].Hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].Server
+}
+
+// ServerForFunc is ServerFor skipping servers for which ok returns false. For
+// the non-weighted continuum this places keys exactly as a ring built without
+// those servers would, so temporarily ejecting a server only moves its keys. It
+// returns "" if no server is accepted.
+func (r *Ring) ServerForFunc(key string, ok func(server string) bool) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := r.hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].Hash >= h })
+	var rejected []string
+	for n := 0; n < len(r.points); n++ {
+		server := r.points[(i+n)%len(r.points)].Server
+		if contains(rejected, server) {
+			continue
+		}
+		if ok(server) {
+			return server
+		}
+		if rejected = append(rejected, server); len(rejected) == len(r.servers) {
+			break
+		}
+	}
+	return ""
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Points returns a copy of the continuum in hash order
+func (r *Ring) Points() []Point {
+	return append([]Point(nil), r.points...)
+}
+
+// Servers returns the servers in the order given to NewRing, or sorted for
+// NewWeightedRing
+func (r *Ring) Servers() []string {
+	return append([]string(nil), r.servers...)
+}
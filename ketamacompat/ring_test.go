@@ -0,0 +1,299 @@
+package ketamacompat
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"reflect"
+	"testing"
+
+	"github.com/dgryski/dgohash"
+	"github.com/rckclmbr/goketama/ketama"
+)
+
+type addr string
+
+func (a addr) Network() string { return "tcp" }
+func (a addr) String() string  { return string(a) }
+
+// TestMatchesGoketama checks placement is identical to goketama (configured the
+// way this package's users configured it before) for a range of clusters
+func TestMatchesGoketama(t *testing.T) {
+	for _, servers := range [][]string{
+		{"127.0.0.1:11211"},
+		{"10.0.0.1:11211", "10.0.0.2:11211"},
+		{"cache1:11211", "cache2:11211", "cache3:11211", "cache4:11211", "cache5:11211"},
+	} {
+		var infos []ketama.ServerInfo
+		for _, s := range servers {
+			infos = append(infos, ketama.ServerInfo{Addr: addr(s)})
+		}
+		continuum := ketama.New(infos, func() hash.Hash { return dgohash.NewJenkins32() })
+		ring := NewRing(servers)
+		for n := 0; n < 10000; n++ {
+			key := fmt.Sprintf("key_%d", n)
+			want, _ := continuum.PickServer(key)
+			if got := ring.ServerFor(key); got != want.String() {
+				t.Fatalf("%v: ServerFor(%q) = %s, goketama picks %s", servers, key, got, want)
+			}
+		}
+	}
+}
+
+func TestRing(t *testing.T) {
+	ring := NewRing([]string{"a:11211", "b:11211"})
+	if p := ring.Points(); len(p) != 2*PointsPerServer {
+		t.Errorf("Expected %d points, got: %d", 2*PointsPerServer, len(p))
+	}
+	points := ring.Points()
+	for n := 1; n < len(points); n++ {
+		if points[n].Hash < points[n-1].Hash {
+			t.Fatalf("points not sorted at %d", n)
+		}
+	}
+	// a key hashing past the last point wraps to the first
+	last := points[len(points)-1]
+	var wrapped string
+	for n := 0; wrapped == ""; n++ {
+		if k := fmt.Sprint(n); Hash(k) > last.Hash {
+			wrapped = k
+		}
+	}
+	if got := ring.ServerFor(wrapped); got != points[0].Server {
+		t.Errorf("Expected wrap around to %s, got: %s", points[0].Server, got)
+	}
+	if s := ring.Servers(); len(s) != 2 || s[0] != "a:11211" {
+		t.Errorf("unexpected servers %v", s)
+	}
+	if got := NewRing(nil).ServerFor("k"); got != "" {
+		t.Errorf("Expected no server for empty ring, got: %q", got)
+	}
+}
+
+func TestServerForFunc(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211", "10.0.0.4:11211"}
+	ring := NewRing(servers)
+	without := NewRing([]string{servers[0], servers[2], servers[3]})
+	up := func(s string) bool { return s != servers[1] }
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		if got, want := ring.ServerForFunc(key, up), without.ServerFor(key); got != want {
+			t.Fatalf("%s: got %s, want %s", key, got, want)
+		}
+		if got, want := ring.ServerForFunc(key, func(string) bool { return true }), ring.ServerFor(key); got != want {
+			t.Fatalf("%s: got %s, want %s", key, got, want)
+		}
+	}
+	if s := ring.ServerForFunc("key", func(string) bool { return false }); s != "" {
+		t.Errorf("Expected no server, got %s", s)
+	}
+	if s := NewRing(nil).ServerForFunc("key", up); s != "" {
+		t.Errorf("Expected no server for an empty ring, got %s", s)
+	}
+}
+
+// TestWeightedRing checks placement against a transliteration of libmemcached
+// 1.0.18's update_continuum and dispatch_host with KETAMA_WEIGHTED
+func TestWeightedRing(t *testing.T) {
+	for _, tc := range []struct {
+		weights map[string]uint64
+		points  map[string]int
+		first   []string
+		counts  map[string]int
+	}{
+		{
+			weights: map[string]uint64{"10.0.0.1:11211": 1, "10.0.0.2:11211": 0, "10.0.0.3:11211": 1},
+			points:  map[string]int{"10.0.0.1:11211": 160, "10.0.0.2:11211": 160, "10.0.0.3:11211": 160},
+			first:   []string{"10.0.0.3:11211", "10.0.0.3:11211", "10.0.0.3:11211", "10.0.0.1:11211", "10.0.0.1:11211", "10.0.0.3:11211", "10.0.0.3:11211", "10.0.0.2:11211", "10.0.0.1:11211", "10.0.0.1:11211", "10.0.0.1:11211", "10.0.0.3:11211"},
+			counts:  map[string]int{"10.0.0.1:11211": 3886, "10.0.0.2:11211": 3080, "10.0.0.3:11211": 3034},
+		},
+		{
+			weights: map[string]uint64{"cache1:11211": 3, "cache2:11211": 1, "cache3:11212": 2},
+			points:  map[string]int{"cache1:11211": 240, "cache2:11211": 80, "cache3:11212": 160},
+			first:   []string{"cache3:11212", "cache3:11212", "cache3:11212", "cache1:11211", "cache2:11211", "cache3:11212", "cache2:11211", "cache3:11212", "cache1:11211", "cache1:11211", "cache1:11211", "cache1:11211"},
+			counts:  map[string]int{"cache1:11211": 4572, "cache2:11211": 1652, "cache3:11212": 3776},
+		},
+	} {
+		ring := NewWeightedRing(tc.weights)
+		points := make(map[string]int)
+		for _, p := range ring.Points() {
+			points[p.Server]++
+		}
+		if !reflect.DeepEqual(points, tc.points) {
+			t.Errorf("%v: expected points %v got %v", tc.weights, tc.points, points)
+		}
+		for n, want := range tc.first {
+			if got := ring.ServerFor(fmt.Sprintf("key_%d", n)); got != want {
+				t.Errorf("%v: ServerFor(key_%d) expected %s got %s", tc.weights, n, want, got)
+			}
+		}
+		counts := make(map[string]int)
+		for n := 0; n < 10000; n++ {
+			counts[ring.ServerFor(fmt.Sprintf("key_%d", n))]++
+		}
+		if !reflect.DeepEqual(counts, tc.counts) {
+			t.Errorf("%v: expected %v got %v", tc.weights, tc.counts, counts)
+		}
+	}
+	if s := NewWeightedRing(map[string]uint64{"b:1": 1, "a:1": 2}).Servers(); !reflect.DeepEqual(s, []string{"a:1", "b:1"}) {
+		t.Errorf("Expected sorted servers got %v", s)
+	}
+}
+
+func TestNewRingWithHash(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	ring := NewRingWithHash(servers, HashFNV1a_32)
+	for _, p := range ring.Points()[:5] {
+		found := false
+		for _, s := range servers {
+			for k := 0; k < PointsPerServer; k++ {
+				if s == p.Server && HashFNV1a_32(fmt.Sprintf("%s-%d", s, k)) == p.Hash {
+					found = true
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected point %v to be an FNV-1a hash of its server", p)
+		}
+	}
+	moved := 0
+	jenkins := NewRing(servers)
+	for n := 0; n < 1000; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		h := HashFNV1a_32(key)
+		points := ring.Points()
+		want := points[0].Server
+		for _, p := range points {
+			if p.Hash >= h {
+				want = p.Server
+				break
+			}
+		}
+		if got := ring.ServerFor(key); got != want {
+			t.Fatalf("%s: expected %s got %s", key, want, got)
+		}
+		if ring.ServerFor(key) != jenkins.ServerFor(key) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("Expected keys to be placed differently than with Jenkins one-at-a-time")
+	}
+}
+
+// TestCollisions checks points sharing a hash belong to the server added first,
+// as with libmemcached's continuum sorted by glibc's (stable) qsort
+func TestCollisions(t *testing.T) {
+	if c := NewRing([]string{"a:11211", "b:11211"}).Collisions(); len(c) != 0 {
+		t.Errorf("Expected no collisions got %v", c)
+	}
+
+	servers := []string{"cache1:11211", "cache2:11211", "cache3:11211", "cache4:11211", "cache5:11211", "cache6:11211"}
+	ring := NewRingWithHash(servers, HashCRC32)
+	collisions := ring.Collisions()
+	if len(collisions) == 0 {
+		t.Fatal("Expected 15 bit CRC hashes to collide")
+	}
+	order := make(map[string]int)
+	for n, s := range servers {
+		order[s] = n
+	}
+	keys := make(map[uint32]string)
+	for n := 0; len(keys) < 1<<15 && n < 1<<20; n++ {
+		k := fmt.Sprint(n)
+		if _, ok := keys[HashCRC32(k)]; !ok {
+			keys[HashCRC32(k)] = k
+		}
+	}
+	for _, c := range collisions {
+		if len(c.Servers) < 2 {
+			t.Fatalf("Expected several servers for %v", c)
+		}
+		for n := 1; n < len(c.Servers); n++ {
+			if order[c.Servers[n]] < order[c.Servers[n-1]] {
+				t.Errorf("Expected servers in the order given for %v", c)
+			}
+		}
+		key, ok := keys[c.Hash]
+		if !ok {
+			t.Fatalf("no key hashes to %d", c.Hash)
+		}
+		if got := ring.ServerFor(key); got != c.Servers[0] {
+			t.Errorf("%q hashing to %d: expected %s got %s", key, c.Hash, c.Servers[0], got)
+		}
+	}
+}
+
+func TestRingOptions_PointsPerServer(t *testing.T) {
+	servers := []string{"a:11211", "b:11211"}
+	ring := NewRingWithOptions(servers, RingOptions{PointsPerServer: 40})
+	if p := ring.Points(); len(p) != 2*40 {
+		t.Errorf("Expected %d points, got: %d", 2*40, len(p))
+	}
+	if !reflect.DeepEqual(NewRingWithOptions(servers, RingOptions{}).Points(), NewRing(servers).Points()) {
+		t.Error("Expected zero options to build NewRing's continuum")
+	}
+
+	// from the libmemcached transliteration with MEMCACHED_POINTS_PER_SERVER_KETAMA 100
+	weights := map[string]uint64{"cache1:11211": 3, "cache2:11211": 1, "cache3:11212": 2}
+	ring = NewWeightedRingWithOptions(weights, RingOptions{PointsPerServer: 100})
+	points := make(map[string]int)
+	for _, p := range ring.Points() {
+		points[p.Server]++
+	}
+	want := map[string]int{"cache1:11211": 148, "cache2:11211": 48, "cache3:11212": 100}
+	if !reflect.DeepEqual(points, want) {
+		t.Errorf("Expected points %v got %v", want, points)
+	}
+	first := []string{"cache3:11212", "cache1:11211", "cache2:11211", "cache1:11211", "cache2:11211", "cache1:11211", "cache1:11211", "cache3:11212", "cache1:11211", "cache1:11211", "cache1:11211", "cache1:11211"}
+	for n, want := range first {
+		if got := ring.ServerFor(fmt.Sprintf("key_%d", n)); got != want {
+			t.Errorf("key_%d: expected %s got %s", n, want, got)
+		}
+	}
+}
+
+func TestSocketServers(t *testing.T) {
+	for _, tc := range []struct {
+		server string
+		path   string
+		ok     bool
+	}{
+		{"/var/run/memcached.sock", "/var/run/memcached.sock", true},
+		{"unix:///var/run/memcached.sock", "/var/run/memcached.sock", true},
+		{"10.0.0.1:11211", "10.0.0.1:11211", false},
+		{"unix://relative.sock", "relative.sock", false},
+	} {
+		if path, ok := SocketPath(tc.server); path != tc.path || ok != tc.ok {
+			t.Errorf("SocketPath(%q) expected %q %v got %q %v", tc.server, tc.path, tc.ok, path, ok)
+		}
+	}
+
+	// libmemcached names socket points "path:0-n"
+	ring := NewRing([]string{"/tmp/mc.sock", "10.0.0.1:11211"})
+	url := NewRing([]string{"unix:///tmp/mc.sock", "10.0.0.1:11211"})
+	want := make(map[uint32]bool)
+	for k := 0; k < PointsPerServer; k++ {
+		want[Hash(fmt.Sprintf("/tmp/mc.sock:0-%d", k))] = true
+	}
+	for n, p := range ring.Points() {
+		if p.Server == "/tmp/mc.sock" && !want[p.Hash] {
+			t.Errorf("unexpected socket point %d", p.Hash)
+		}
+		if u := url.Points()[n]; u.Hash != p.Hash {
+			t.Errorf("Expected the same points for the socket url got %d and %d", u.Hash, p.Hash)
+		}
+	}
+
+	weighted := NewWeightedRing(map[string]uint64{"/tmp/mc.sock": 1, "10.0.0.1:11211": 1})
+	digest := md5.Sum([]byte("/tmp/mc.sock:0-0"))
+	first := binary.LittleEndian.Uint32(digest[:])
+	found := false
+	for _, p := range weighted.Points() {
+		found = found || (p.Server == "/tmp/mc.sock" && p.Hash == first)
+	}
+	if !found {
+		t.Errorf("Expected a weighted socket point hashed from /tmp/mc.sock:0-0")
+	}
+}
@@ -0,0 +1,108 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxKeyLength is the longest key memcached accepts
+const MaxKeyLength = 250
+
+// ErrKeyTooLong is returned by KeyBuilder.Join when the built key exceeds MaxLen
+var ErrKeyTooLong = errors.New("memcache: key too long")
+
+// KeyBuilder builds keys from parts so Go and Python services derive identical
+// keys. Each part is formatted like Python's str() for strings, integers and
+// bools, then every byte that is not printable ASCII, the separator or '%' is
+// percent encoded so parts can't contain whitespace or collide across the separator.
+//
+// The Python equivalent is:
+//
+//	def escape(part, sep=':'):
+//	    return ''.join(chr(b) if 0x20 < b < 0x7f and chr(b) not in ('%', sep) else '%%%02X' % b
+//	                   for b in str(part).encode('utf-8'))
+//
+//	def build_key(prefix, *parts, sep=':'):
+//	    return prefix + sep.join(escape(p, sep) for p in parts)
+type KeyBuilder struct {
+	// Prefix is prepended to every key unescaped, e.g. a namespace like "myapp:"
+	Prefix string
+	// Sep separates parts. Defaults to ":".
+	Sep string
+	// MaxLen is the maximum key length in bytes. Defaults to MaxKeyLength.
+	MaxLen int
+	// HashSecret, when set, replaces the joined parts with their HashKey so values
+	// such as email addresses never appear in keys (Python: prefix + hash_key(secret,
+	// sep.join(escape(p, sep) for p in parts))). The prefix stays readable.
+	HashSecret []byte
+}
+
+// Join returns the key for parts
+func (b KeyBuilder) Join(parts ...interface{}) (string, error) {
+	sep := b.Sep
+	if sep == "" {
+		sep = ":"
+	}
+	max := b.MaxLen
+	if max <= 0 {
+		max = MaxKeyLength
+	}
+	var sb strings.Builder
+	for n, p := range parts {
+		if n > 0 {
+			sb.WriteString(sep)
+		}
+		escapeKeyPart(&sb, formatKeyPart(p), sep)
+	}
+	key := b.Prefix + sb.String()
+	if b.HashSecret != nil {
+		key = b.Prefix + HashKey(b.HashSecret, sb.String(), 0)
+	}
+	if len(key) > max {
+		return "", fmt.Errorf("%w: %d bytes", ErrKeyTooLong, len(key))
+	}
+	return key, nil
+}
+
+// formatKeyPart formats p the way Python's str() would for common types
+func formatKeyPart(p interface{}) string {
+	switch v := p.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(p)
+}
+
+func escapeKeyPart(sb *strings.Builder, s, sep string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c > 0x20 && c < 0x7f && c != '%' && !strings.ContainsRune(sep, rune(c)) {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(sb, "%%%02X", c)
+	}
+}
@@ -0,0 +1,34 @@
+package memcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	kb := KeyBuilder{Prefix: "app:"}
+	for _, tc := range []struct {
+		parts []interface{}
+		want  string
+	}{
+		{[]interface{}{"user", 42, "profile"}, "app:user:42:profile"},
+		{[]interface{}{"user", "a b\r\n"}, "app:user:a%20b%0D%0A"},
+		{[]interface{}{"user", "a:b"}, "app:user:a%3Ab"},
+		{[]interface{}{"pct", "100%"}, "app:pct:100%25"},
+		{[]interface{}{"flag", true, int64(-1)}, "app:flag:True:-1"},
+		{[]interface{}{"name", "café"}, "app:name:caf%C3%A9"},
+	} {
+		got, err := kb.Join(tc.parts...)
+		if err != nil || got != tc.want {
+			t.Errorf("Join(%v) = %q %v, want %q", tc.parts, got, err, tc.want)
+		}
+	}
+
+	if _, err := kb.Join(strings.Repeat("x", 247)); !errors.Is(err, ErrKeyTooLong) {
+		t.Errorf("Expected ErrKeyTooLong, got: %v", err)
+	}
+	if k, err := (KeyBuilder{Sep: "/", MaxLen: 10}).Join("a", "b:c"); err != nil || k != "a/b:c" {
+		t.Errorf("unexpected key %q %v", k, err)
+	}
+}
@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// LongKeyPrefixLen is how much of an over-long key LongKeySHA1 and
+// LongKeySHA256 keep readable
+const LongKeyPrefixLen = 180
+
+// KeyError describes a key memcached would reject, see Options.ValidateKeys. It
+// wraps memcache.ErrMalformedKey.
+type KeyError struct {
+	Key    string
+	Reason string
+}
+
+func (e *KeyError) Error() string {
+	k := e.Key
+	if len(k) > 64 {
+		k = k[:64] + "..."
+	}
+	return fmt.Sprintf("memcache: malformed key %q: %s", k, e.Reason)
+}
+
+func (e *KeyError) Unwrap() error { return memcache.ErrMalformedKey }
+
+// ValidateKey returns a *KeyError if memcached's text protocol can't carry key:
+// empty, longer than MaxKeyLength bytes, or holding whitespace or a control
+// character
+func ValidateKey(key string) error {
+	if len(key) == 0 {
+		return &KeyError{key, "empty"}
+	}
+	if len(key) > MaxKeyLength {
+		return &KeyError{key, fmt.Sprintf("%d bytes, longer than %d", len(key), MaxKeyLength)}
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return &KeyError{key, fmt.Sprintf("byte %#02x at %d is whitespace or a control character", key[i], i)}
+		}
+	}
+	return nil
+}
+
+// LongKeySHA1 is an Options.LongKeyHash replacing a key longer than
+// MaxKeyLength bytes with its first LongKeyPrefixLen bytes, ':' and the hex
+// SHA-1 of the whole key. The Python equivalent is:
+//
+//	def shorten_key(key):
+//	    if len(key) <= 250:  # bytes
+//	        return key
+//	    return key[:180] + b':' + hashlib.sha1(key).hexdigest().encode()
+func LongKeySHA1(key string) string {
+	return shortenKey(key, sha1.New())
+}
+
+// LongKeySHA256 is LongKeySHA1 with SHA-256 (hashlib.sha256 in Python)
+func LongKeySHA256(key string) string {
+	return shortenKey(key, sha256.New())
+}
+
+func shortenKey(key string, h hash.Hash) string {
+	if len(key) <= MaxKeyLength {
+		return key
+	}
+	h.Write([]byte(key))
+	return key[:LongKeyPrefixLen] + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// checkKey returns the error of an operation on key under Options.ValidateKeys
+func (c *Client) checkKey(key string) error {
+	if !c.opts.ValidateKeys {
+		return nil
+	}
+	return ValidateKey(key)
+}
@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestValidateKey(t *testing.T) {
+	for key, reason := range map[string]string{
+		"":                          "empty",
+		strings.Repeat("k", 251):    "251 bytes, longer than 250",
+		"bad key":                   "byte 0x20 at 3 is whitespace or a control character",
+		"tab\tkey":                  "byte 0x09 at 3",
+		strings.Repeat("k", 250):    "",
+		"user:1234:profile|v=2&x=é": "",
+	} {
+		err := ValidateKey(key)
+		if reason == "" {
+			if err != nil {
+				t.Errorf("Expected %q to be valid, got %v", key, err)
+			}
+			continue
+		}
+		var ke *KeyError
+		if !errors.As(err, &ke) || !strings.HasPrefix(ke.Reason, reason) || !errors.Is(err, memcache.ErrMalformedKey) {
+			t.Errorf("Expected %q to fail with %q, got %v", key, reason, err)
+		}
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{ValidateKeys: true})
+	var ke *KeyError
+	if err := mc.Set(StringItem("bad key", "x")); !errors.As(err, &ke) || ke.Key != "bad key" {
+		t.Errorf("Expected a KeyError, got %v", err)
+	}
+	if _, err := mc.GetMulti([]string{"good", "bad key"}); !errors.As(err, &ke) {
+		t.Errorf("Expected a KeyError from GetMulti, got %v", err)
+	}
+	if _, ok := mc.GetString("bad key"); ok {
+		t.Error("Expected a malformed key to fail")
+	}
+}
+
+func TestLongKeyHash(t *testing.T) {
+	long := strings.Repeat("k", 300)
+	short := LongKeySHA1(long)
+	if len(short) != LongKeyPrefixLen+41 || !strings.HasPrefix(short, long[:LongKeyPrefixLen]+":") {
+		t.Errorf("Expected a readable prefix and the SHA-1, got %q", short)
+	}
+	// hashlib.sha1(b'k' * 300).hexdigest()
+	if !strings.HasSuffix(short, ":3413fbe006ad15aea62a1f28045806be6e49b709") {
+		t.Errorf("Expected Python's digest, got %q", short)
+	}
+	if LongKeySHA1("short") != "short" || len(LongKeySHA256(long)) != LongKeyPrefixLen+65 {
+		t.Error("Expected only long keys to be hashed")
+	}
+
+	raw := NewClient([]string{LocalAddress})
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{LongKeyHash: LongKeySHA1})
+	if err := mc.SetString(long, "v"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString(long); !ok || s != "v" {
+		t.Errorf("Expected the long key to be readable, got %q %v", s, ok)
+	}
+	if s, ok := raw.GetString(short); !ok || s != "v" {
+		t.Errorf("Expected the value stored under the hashed key, got %q %v", s, ok)
+	}
+	if m, err := mc.GetMulti([]string{long}); err != nil || m[long] == nil || m[long].Key != long {
+		t.Errorf("Expected GetMulti keyed by the long key, got %v %v", m, err)
+	}
+}
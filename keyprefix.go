@@ -0,0 +1,114 @@
+package memcache
+
+import (
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// KeyFunc builds the full key stored in memcached from a key, the prefix and
+// the version, see Options.KeyFunc
+type KeyFunc func(key, prefix string, version int) string
+
+// PrefixKeyFunc returns prefix followed by key, ignoring version
+func PrefixKeyFunc(key, prefix string, version int) string {
+	return prefix + key
+}
+
+// DjangoKeyFunc returns "prefix:version:key", the keys of Django's default
+// KEY_FUNCTION. Django adds the separators even when KEY_PREFIX is empty.
+func DjangoKeyFunc(key, prefix string, version int) string {
+	return prefix + ":" + strconv.Itoa(version) + ":" + key
+}
+
+// WithKeyPrefix reads or writes the key of a typed call under prefix instead of
+// Options.KeyPrefix, e.g. to share a value with a service using another
+// KEY_PREFIX. It builds the key with Options.KeyFunc (PrefixKeyFunc if unset).
+func WithKeyPrefix(prefix string) CallOption {
+	return func(o *callOptions) { o.prefix, o.hasPrefix = prefix, true }
+}
+
+// rewritesKeys reports whether full keys differ from the caller's
+func (c *Client) rewritesKeys() bool {
+	return c.opts.KeyFunc != nil || c.opts.LongKeyHash != nil || c.opts.RoutingPrefix != ""
+}
+
+// fullKey returns the key stored in memcached for key
+func (c *Client) fullKey(key string) string {
+	if c.opts.KeyFunc != nil {
+		key = c.opts.KeyFunc(key, c.opts.KeyPrefix, c.opts.KeyVersion)
+	}
+	return c.routed(c.shortKey(key))
+}
+
+// routed prepends Options.RoutingPrefix to a full key
+func (c *Client) routed(key string) string {
+	return c.opts.RoutingPrefix + key
+}
+
+// shortKey applies Options.LongKeyHash to a full key
+func (c *Client) shortKey(key string) string {
+	if c.opts.LongKeyHash == nil || len(key) <= MaxKeyLength {
+		return key
+	}
+	return c.opts.LongKeyHash(key)
+}
+
+// keyWith is fullKey honoring WithKeyPrefix
+func (c *Client) keyWith(key string, o callOptions) string {
+	if !o.hasPrefix {
+		return c.fullKey(key)
+	}
+	f := c.opts.KeyFunc
+	if f == nil {
+		f = PrefixKeyFunc
+	}
+	return c.routed(c.shortKey(f(key, o.prefix, c.opts.KeyVersion)))
+}
+
+// fullKeys returns the keys stored in memcached for keys
+func (c *Client) fullKeys(keys []string) []string {
+	if !c.rewritesKeys() {
+		return keys
+	}
+	full := make([]string, len(keys))
+	for n, k := range keys {
+		full[n] = c.fullKey(k)
+	}
+	return full
+}
+
+// prefixed returns a copy of item under its full key
+func (c *Client) prefixed(item *memcache.Item) *memcache.Item {
+	if !c.rewritesKeys() {
+		return item
+	}
+	cp := *item
+	cp.Key = c.fullKey(item.Key)
+	return &cp
+}
+
+// unprefixed returns a copy of an item read under a full key with the caller's key
+func unprefixed(i *memcache.Item, key string) *memcache.Item {
+	if i == nil || i.Key == key {
+		return i
+	}
+	cp := *i
+	cp.Key = key
+	return &cp
+}
+
+// unprefixedMulti rekeys the items read for the full keys of keys by the
+// caller's keys
+func (c *Client) unprefixedMulti(keys, full []string, m map[string]*memcache.Item) map[string]*memcache.Item {
+	if !c.rewritesKeys() || m == nil {
+		return m
+	}
+	out := make(map[string]*memcache.Item, len(m))
+	for n, k := range full {
+		if i, ok := m[k]; ok {
+			out[keys[n]] = unprefixed(i, keys[n])
+		}
+	}
+	return out
+}
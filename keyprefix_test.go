@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	raw := NewClient([]string{LocalAddress})
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{KeyPrefix: "app:"})
+	mc.Delete("prefix_key")
+	mc.Delete("prefix_counter")
+
+	if err := mc.SetString("prefix_key", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := raw.GetString("app:prefix_key"); !ok || s != "v" {
+		t.Errorf("Expected the value stored under the full key, got %q %v", s, ok)
+	}
+	if i, err := mc.Get("prefix_key"); err != nil || i.Key != "prefix_key" {
+		t.Errorf("Expected the item returned under the caller's key, got %v %v", i, err)
+	}
+	m, err := mc.GetMulti([]string{"prefix_key", "prefix_missing"})
+	if err != nil || len(m) != 1 || m["prefix_key"] == nil || m["prefix_key"].Key != "prefix_key" {
+		t.Errorf("Expected GetMulti keyed by the caller's keys, got %v %v", m, err)
+	}
+
+	if n, err := mc.Incr("prefix_counter", 1, 5, 0); err != nil || n != 5 {
+		t.Errorf("Expected the initial value, got %d %v", n, err)
+	}
+	if n, err := raw.Increment("app:prefix_counter", 1); err != nil || n != 6 {
+		t.Errorf("Expected the counter under the full key, got %d %v", n, err)
+	}
+
+	// per call override
+	raw.SetString("other:prefix_key", "o")
+	if s, ok := mc.GetString("prefix_key", WithKeyPrefix("other:")); !ok || s != "o" {
+		t.Errorf("Expected WithKeyPrefix to read the other prefix, got %q %v", s, ok)
+	}
+	mc.SetString("prefix_key", "w", WithKeyPrefix("other:"))
+	if s, _ := raw.GetString("other:prefix_key"); s != "w" {
+		t.Errorf("Expected WithKeyPrefix to write the other prefix, got %q", s)
+	}
+
+	if err := mc.Delete("prefix_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Get("app:prefix_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the full key deleted, got %v", err)
+	}
+}
+
+func TestKeyPrefixBatched(t *testing.T) {
+	raw := NewClient([]string{LocalAddress})
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{KeyPrefix: "batch:", BatchWindow: time.Millisecond})
+	raw.SetString("batch:prefix_key", "v")
+	if s, ok := mc.GetString("prefix_key"); !ok || s != "v" {
+		t.Errorf("Expected batched Gets prefixed once, got %q %v", s, ok)
+	}
+}
+
+func TestDjangoKeyFunc(t *testing.T) {
+	raw := NewClient([]string{LocalAddress})
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{KeyPrefix: "site", KeyVersion: 2, KeyFunc: DjangoKeyFunc})
+	if err := mc.SetInt64("django_key", 3); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := raw.GetInt64("site:2:django_key"); !ok || n != 3 {
+		t.Errorf("Expected Django's key format, got %d %v", n, ok)
+	}
+	if k := DjangoKeyFunc("k", "", 1); k != ":1:k" {
+		t.Errorf("Expected Django's separators without a prefix, got %q", k)
+	}
+}
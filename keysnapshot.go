@@ -0,0 +1,96 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrSnapshotChanged is matched with errors.Is by every *SnapshotChangedError
+var ErrSnapshotChanged = errors.New("memcache: keys changed since snapshot")
+
+// SnapshotChangedError is returned by VerifyUnchanged when keys were written,
+// added or deleted since their KeySnapshot was taken
+type SnapshotChangedError struct {
+	Keys []string // sorted
+}
+
+func (e *SnapshotChangedError) Error() string {
+	return fmt.Sprintf("memcache: %d keys changed since snapshot: %s", len(e.Keys), strings.Join(e.Keys, ", "))
+}
+
+func (e *SnapshotChangedError) Is(target error) bool { return target == ErrSnapshotChanged }
+
+// KeySnapshot is the state of a set of keys read by SnapshotKeys
+type KeySnapshot struct {
+	// Items holds the items of the keys that existed, with their CAS ids.
+	// Missing keys are absent.
+	Items map[string]*memcache.Item
+	keys  []string
+}
+
+// Keys returns the keys the snapshot was taken of, without repeats
+func (s *KeySnapshot) Keys() []string {
+	return append([]string(nil), s.keys...)
+}
+
+// SnapshotKeys reads keys with their CAS ids so a multi-key read-check-act
+// flow can confirm with VerifyUnchanged that none changed before acting, and
+// write with CompareAndSwap of the snapshot's items. Values are read from
+// this client's servers only: Options.Secondary and stale serving are
+// skipped since their CAS ids can't be verified. Any server failing fails the
+// snapshot.
+func (c *Client) SnapshotKeys(ctx context.Context, keys []string) (*KeySnapshot, error) {
+	keys = c.dedupKeys(keys)
+	m, err := c.snapshotRead(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySnapshot{Items: m, keys: keys}, nil
+}
+
+// VerifyUnchanged reads the keys of s again returning a *SnapshotChangedError
+// listing those whose CAS id differs, that were deleted or that were added
+// since s was taken. A nil error only means nothing changed as of the read, so
+// writes that must not clobber concurrent ones should still use
+// CompareAndSwap.
+func (c *Client) VerifyUnchanged(ctx context.Context, s *KeySnapshot) error {
+	m, err := c.snapshotRead(ctx, s.keys)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for _, k := range s.keys {
+		before, had := s.Items[k]
+		now, has := m[k]
+		if had != has || (had && before.CasID != now.CasID) {
+			changed = append(changed, k)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	return &SnapshotChangedError{Keys: changed}
+}
+
+// snapshotRead gets keys from this client's servers with their CAS ids
+func (c *Client) snapshotRead(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+	if len(keys) == 0 {
+		return map[string]*memcache.Item{}, nil
+	}
+	if _, ok := ctx.Deadline(); !ok && c.opts.DefaultReadDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.DefaultReadDeadline)
+		defer cancel()
+	}
+	m, err := c.softExpiryMulti(c.getMultiCtx(ctx, keys))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
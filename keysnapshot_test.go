@@ -0,0 +1,49 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotKeys(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	keys := []string{"ksnap:a", "ksnap:b", "ksnap:c", "ksnap:a"}
+	for _, k := range keys {
+		mc.Delete(k)
+	}
+	mc.Set(StringItem("ksnap:a", "1"))
+	mc.Set(StringItem("ksnap:b", "2"))
+
+	s, err := mc.SnapshotKeys(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Items) != 2 || s.Items["ksnap:a"].CasID == 0 || len(s.Keys()) != 3 {
+		t.Errorf("unexpected snapshot %v %v", s.Items, s.Keys())
+	}
+	if err := mc.VerifyUnchanged(ctx, s); err != nil {
+		t.Errorf("Expected no changes got %v", err)
+	}
+
+	mc.Set(StringItem("ksnap:b", "3"))
+	mc.Set(StringItem("ksnap:c", "new"))
+	err = mc.VerifyUnchanged(ctx, s)
+	var changed *SnapshotChangedError
+	if !errors.Is(err, ErrSnapshotChanged) || !errors.As(err, &changed) || !reflect.DeepEqual(changed.Keys, []string{"ksnap:b", "ksnap:c"}) {
+		t.Errorf("Expected b and c to have changed got %v", err)
+	}
+
+	// acting on the snapshot with CompareAndSwap
+	a := s.Items["ksnap:a"]
+	a.Value = []byte("acted")
+	if err := mc.CompareAndSwap(a); err != nil {
+		t.Errorf("Expected the swap to succeed got %v", err)
+	}
+	mc.Delete("ksnap:c")
+	if err := mc.VerifyUnchanged(ctx, s); !errors.As(err, &changed) || !reflect.DeepEqual(changed.Keys, []string{"ksnap:a", "ksnap:b"}) {
+		t.Errorf("Expected a and b to have changed got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"errors"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricCorruptValues counts values read by the typed getters that failed to
+// decode while Options.LastGoodMaxAge is set, tagged by whether the last
+// known good copy was "served" or there was "none"
+const MetricCorruptValues = "memcache.corrupt_values"
+
+// clientGetter is implemented by the itemGetters reading through a Client,
+// whose last known good copies the typed getters use
+type clientGetter interface {
+	client() *Client
+}
+
+func (c *Client) client() *Client { return c }
+
+// getDecoded reads k with c and decodes it with decode. Reading through a
+// Client with Options.LastGoodMaxAge set, values that decode are kept as the
+// last known good copy of k, which is decoded instead of a later value that
+// fails to decode (a flag mismatch or truncated pickle, e.g. from a bad
+// writer deploy) as long as it is no older than LastGoodMaxAge.
+func getDecoded[T any](c itemGetter, k string, decode func(*Item) (T, error)) (T, error) {
+	var cl *Client
+	if g, ok := c.(clientGetter); ok {
+		cl = g.client()
+	}
+	var v T
+	var de decodeError
+	i, err := c.Get(k)
+	switch {
+	case errors.As(err, &de):
+		err = de.err
+	case err == memcache.ErrCacheMiss && cl != nil && cl.lastGood != nil:
+		cl.lastGood.remove(k)
+		return v, err
+	case err != nil:
+		return v, err
+	default:
+		v, err = decode(&Item{i})
+	}
+	if cl == nil || cl.lastGood == nil {
+		return v, err
+	}
+	now := cl.now()
+	if err == nil {
+		cl.lastGood.add(k, i, now)
+		return v, nil
+	}
+	if gi, ok := cl.lastGood.get(k, now, cl.opts.LastGoodMaxAge); ok {
+		if gv, gerr := decode(&Item{gi}); gerr == nil {
+			cl.opts.Metrics.Count(MetricCorruptValues, 1, map[string]string{"result": "served"})
+			return gv, nil
+		}
+	}
+	cl.opts.Metrics.Count(MetricCorruptValues, 1, map[string]string{"result": "none"})
+	return v, err
+}
+
+// decodeError marks the errors of a getter for values it read but couldn't
+// prepare for decoding, e.g. failing a Pipeline stage or under
+// UnknownFlagsError
+type decodeError struct{ err error }
+
+func (e decodeError) Error() string { return e.err.Error() }
+func (e decodeError) Unwrap() error { return e.err }
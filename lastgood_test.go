@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestLastGood(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Clock: clock, LastGoodMaxAge: time.Minute, Metrics: metrics, UnknownFlags: UnknownFlagsError})
+	plain := NewClient([]string{"127.0.0.1:11211"})
+
+	plain.Set(UnicodeItem("lastgood", "good"))
+	if s, ok := mc.GetString("lastgood"); !ok || s != "good" {
+		t.Fatalf("unexpected %q %v", s, ok)
+	}
+	for _, bad := range []*memcache.Item{
+		{Key: "lastgood", Value: []byte("\x80\x02X\x05\x00\x00"), Flags: FLAG_PICKLE},
+		{Key: "lastgood", Value: []byte("x"), Flags: 1 << 9},
+		Int64Item("lastgood", 1),
+	} {
+		plain.Set(bad)
+		if s, ok := mc.GetString("lastgood"); !ok || s != "good" {
+			t.Errorf("%q: Expected the last good value got %q %v", bad.Value, s, ok)
+		}
+	}
+	if s, err := Get[string](mc, "lastgood"); err != nil || s != "good" {
+		t.Errorf("Get: expected the last good value got %q %v", s, err)
+	}
+	if n := metrics.get(MetricCorruptValues); n != 4 {
+		t.Errorf("Expected 4 corrupt values got %d", n)
+	}
+
+	// the copy expires
+	clock.Advance(2 * time.Minute)
+	if _, ok := mc.GetString("lastgood"); ok {
+		t.Error("Expected the expired copy not to be served")
+	}
+	// as does a deleted key's
+	plain.Set(UnicodeItem("lastgood", "good"))
+	mc.GetString("lastgood")
+	plain.Delete("lastgood")
+	mc.GetString("lastgood")
+	plain.Set(Int64Item("lastgood", 1))
+	if _, ok := mc.GetString("lastgood"); ok {
+		t.Error("Expected no copy after a miss")
+	}
+	// a copy of another type isn't served
+	if n, ok := mc.GetInt64("lastgood"); !ok || n != 1 {
+		t.Errorf("unexpected %d %v", n, ok)
+	}
+}
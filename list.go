@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// List returns the elements of a pickled python list or tuple. Elements are
+// decoded as by picklecompat.Decode, so nested containers are the gopickle
+// types (*types.List, *types.Tuple, *types.Dict...).
+func (i *Item) List() ([]interface{}, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return nil, err
+	}
+	if i.Flags == FLAG_NONE && looksPickled(i.Value) {
+		return (&Item{&memcache.Item{Value: i.Value, Flags: FLAG_PICKLE}}).List()
+	}
+	if i.Flags != FLAG_PICKLE {
+		return nil, InvalidType
+	}
+	v, err := picklecompat.Decode(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case *types.List:
+		return []interface{}(*v), nil
+	case *types.Tuple:
+		return []interface{}(*v), nil
+	}
+	return nil, fmt.Errorf("%w: expected list got %T", InvalidType, v)
+}
+
+// Set returns the elements of a pickled python set or frozenset. Elements are
+// decoded as by picklecompat.Decode.
+func (i *Item) Set() (map[interface{}]struct{}, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return nil, err
+	}
+	if i.Flags == FLAG_NONE && looksPickled(i.Value) {
+		return (&Item{&memcache.Item{Value: i.Value, Flags: FLAG_PICKLE}}).Set()
+	}
+	if i.Flags != FLAG_PICKLE {
+		return nil, InvalidType
+	}
+	v, err := picklecompat.Decode(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[interface{}]struct{})
+	switch v := v.(type) {
+	case *types.Set:
+		for e := range *v {
+			m[e] = struct{}{}
+		}
+	case *types.FrozenSet:
+		for e := range *v {
+			m[e] = struct{}{}
+		}
+	default:
+		return nil, fmt.Errorf("%w: expected set got %T", InvalidType, v)
+	}
+	return m, nil
+}
+
+// ListItem returns a memcache.Item storing l as a python list pickled with
+// protocol 2 the way pylibmc does. l may hold the types picklecompat.Encode
+// supports; anything else is an error.
+func ListItem(k string, l []interface{}) (*memcache.Item, error) {
+	return PickleItem(k, l)
+}
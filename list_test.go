@@ -0,0 +1,81 @@
+package memcache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestItem_List(t *testing.T) {
+	type testCase struct {
+		value string
+		flags uint32
+		want  []interface{}
+	}
+	tests := []testCase{
+		// pickle.dumps([u'a', 1, (2, u'b')], 2)
+		{"\x80\x02]q\x00(X\x01\x00\x00\x00aq\x01K\x01K\x02X\x01\x00\x00\x00bq\x02\x86q\x03e.", FLAG_PICKLE,
+			[]interface{}{"a", 1, types.NewTupleFromSlice([]interface{}{2, "b"})}},
+		// pickle.dumps((u'a', 1), 2)
+		{"\x80\x02X\x01\x00\x00\x00aq\x00K\x01\x86q\x01.", FLAG_PICKLE, []interface{}{"a", 1}},
+		// pickle.dumps([], 2) stored without the pickle flag
+		{"\x80\x02]q\x00.", FLAG_NONE, []interface{}{}},
+		// pickle.dumps([u'a', 1, u'b'], 0) as python-memcached stores it
+		{"(lp0\nVa\np1\naI1\naVb\np2\na.", FLAG_PICKLE, []interface{}{"a", 1, "b"}},
+	}
+	for _, tc := range tests {
+		l, err := (&Item{&memcache.Item{Value: []byte(tc.value), Flags: tc.flags}}).List()
+		if err != nil || !reflect.DeepEqual(l, tc.want) {
+			t.Errorf("List(%q) = %#v %v, want %#v", tc.value, l, err, tc.want)
+		}
+	}
+	if _, err := (&Item{UnicodeItem("", "x")}).List(); err == nil {
+		t.Errorf("Expected an error for a string")
+	}
+	if _, err := (&Item{StringItem("", "x")}).List(); err != InvalidType {
+		t.Errorf("Expected InvalidType, got %v", err)
+	}
+}
+
+func TestItem_Set(t *testing.T) {
+	for _, py := range []string{
+		// pickle.dumps({1, 'a'}, 2)
+		"\x80\x02c__builtin__\nset\nq\x00]q\x01(K\x01X\x01\x00\x00\x00aq\x02e\x85q\x03Rq\x04.",
+		// pickle.dumps(frozenset([1, 'a']), 4)
+		"\x80\x04\x95\n\x00\x00\x00\x00\x00\x00\x00(K\x01\x8c\x01a\x94\x91\x94.",
+	} {
+		s, err := (&Item{&memcache.Item{Value: []byte(py), Flags: FLAG_PICKLE}}).Set()
+		if err != nil || !reflect.DeepEqual(s, map[interface{}]struct{}{1: {}, "a": {}}) {
+			t.Errorf("Set(%q) = %v %v", py, s, err)
+		}
+	}
+	i, _ := ListItem("", []interface{}{1})
+	if _, err := (&Item{i}).Set(); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a list, got %v", err)
+	}
+}
+
+func TestListItem(t *testing.T) {
+	i, err := ListItem("list", []interface{}{"a", int64(1), 2.5, nil, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Flags != FLAG_PICKLE {
+		t.Errorf("Expected pickle flags got %d", i.Flags)
+	}
+	l, err := (&Item{i}).List()
+	if err != nil || !reflect.DeepEqual(l, []interface{}{"a", 1, 2.5, nil, true}) {
+		t.Errorf("Unexpected round trip %#v %v", l, err)
+	}
+	if _, err := ListItem("list", []interface{}{struct{}{}}); err == nil {
+		t.Errorf("Expected an error for an unsupported element")
+	}
+
+	// pickletools.optimize(pickle.dumps(['a', 1, 2.5, None, True], 2))
+	if want := "\x80\x02](X\x01\x00\x00\x00aK\x01G@\x04\x00\x00\x00\x00\x00\x00N\x88e."; string(i.Value) != want {
+		t.Errorf("ListItem = %q, want %q", i.Value, want)
+	}
+}
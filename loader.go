@@ -0,0 +1,114 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrLoaderBudget is returned by GetMultiLoad when a read with too many misses
+// is refused a loader invocation under Options.LoaderBudget
+var ErrLoaderBudget = errors.New("memcache: loader budget exhausted")
+
+// MetricLoaderGuarded counts GetMultiLoad calls whose misses exceeded the
+// LoaderBudget threshold, tagged by action ("waited", "allowed" or "rejected")
+const MetricLoaderGuarded = "memcache.loader.guarded"
+
+// Loader loads the values of keys missing from the cache from the underlying
+// store. Keys it returns no item for are left missing.
+type Loader func(ctx context.Context, keys []string) (map[string]*memcache.Item, error)
+
+// LoaderBudget protects the store behind GetMultiLoad during cold cache events.
+// Reads with more misses than MissThreshold, or a larger fraction of misses
+// than MissRatio, may only invoke the loader Rate times per second.
+type LoaderBudget struct {
+	// MissThreshold guards reads with more misses than this. Zero disables it.
+	MissThreshold int
+	// MissRatio guards reads where more than this fraction (0-1) of keys missed.
+	// Zero disables it.
+	MissRatio float64
+	// Rate is the number of guarded loader invocations allowed per second
+	// across the client. Zero allows none, so guarded reads fail.
+	Rate float64
+	// Burst is the number of guarded loader invocations allowed at once
+	Burst int
+	// FailFast fails guarded reads over budget with ErrLoaderBudget instead of
+	// waiting for budget
+	FailFast bool
+}
+
+// guarded reports whether a read of n keys with misses misses is guarded
+func (b LoaderBudget) guarded(n, misses int) bool {
+	if b.MissThreshold > 0 && misses > b.MissThreshold {
+		return true
+	}
+	return b.MissRatio > 0 && n > 0 && float64(misses)/float64(n) > b.MissRatio
+}
+
+// GetMultiLoad is GetMultiCtx loading missing keys with load and storing the
+// items it returns. When Options.LoaderBudget guards the read, load waits for
+// budget (or the read fails with ErrLoaderBudget) and the hits are returned
+// along with the error.
+func (c *Client) GetMultiLoad(ctx context.Context, keys []string, load Loader) (map[string]*memcache.Item, error) {
+	m, err := c.GetMultiCtx(ctx, keys)
+	if err != nil {
+		return m, err
+	}
+	keys = c.dedupKeys(keys)
+	var missing []string
+	for _, k := range keys {
+		if _, ok := m[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return m, nil
+	}
+	if c.opts.LoaderBudget.guarded(len(keys), len(missing)) {
+		if err := c.loaderBudget(ctx); err != nil {
+			return m, err
+		}
+	}
+	loaded, err := load(ctx, missing)
+	if err != nil {
+		return m, err
+	}
+	for k, i := range loaded {
+		// storing is best effort; the loaded value is returned either way
+		c.Set(i)
+		m[k] = i
+	}
+	return m, nil
+}
+
+// loaderBudget takes a guarded loader invocation from the budget, waiting for
+// one unless FailFast is set
+func (c *Client) loaderBudget(ctx context.Context) error {
+	b := c.opts.LoaderBudget
+	action := "allowed"
+	defer func() {
+		c.opts.Metrics.Count(MetricLoaderGuarded, 1, map[string]string{"action": action})
+	}()
+	if c.loads == nil || (b.FailFast && !c.loads.allow()) {
+		action = "rejected"
+		return ErrLoaderBudget
+	}
+	if b.FailFast {
+		return nil
+	}
+	if wait := c.loads.reserve(); wait > 0 {
+		action = "waited"
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			c.loads.cancel()
+			action = "rejected"
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// countingLoader returns an item for every key counting invocations
+func countingLoader(calls *int) Loader {
+	return func(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+		*calls++
+		m := make(map[string]*memcache.Item)
+		for _, k := range keys {
+			m[k] = StringItem(k, "loaded "+k)
+		}
+		return m, nil
+	}
+}
+
+func TestGetMultiLoad(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	mc.SetString("load_hit", "hit")
+	mc.Delete("load_miss")
+	var calls int
+	m, err := mc.GetMultiLoad(ctx, []string{"load_hit", "load_miss", "load_miss"}, countingLoader(&calls))
+	if err != nil || len(m) != 2 || calls != 1 {
+		t.Fatalf("unexpected result %v %v after %d loads", m, err, calls)
+	}
+	if s, ok := mc.GetString("load_miss"); !ok || s != "loaded load_miss" {
+		t.Errorf("Expected the loaded value to be cached, got %q %v", s, ok)
+	}
+	if _, err := mc.GetMultiLoad(ctx, []string{"load_hit", "load_miss"}, countingLoader(&calls)); err != nil || calls != 1 {
+		t.Errorf("Expected no load for hits, got %v after %d loads", err, calls)
+	}
+}
+
+func TestLoaderBudgetFailFast(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		Clock:        clock,
+		Metrics:      metrics,
+		LoaderBudget: LoaderBudget{MissRatio: 0.5, Rate: 1, Burst: 1, FailFast: true},
+	})
+	ctx := context.Background()
+	mc.SetString("budget_hit", "hit")
+	keys := []string{"budget_hit", "budget_a", "budget_b"}
+	var calls int
+	miss := func() {
+		mc.Delete("budget_a")
+		mc.Delete("budget_b")
+	}
+
+	miss()
+	if _, err := mc.GetMultiLoad(ctx, keys, countingLoader(&calls)); err != nil || calls != 1 {
+		t.Fatalf("Expected the first guarded load to be allowed, got %v", err)
+	}
+	miss()
+	m, err := mc.GetMultiLoad(ctx, keys, countingLoader(&calls))
+	if err != ErrLoaderBudget || calls != 1 {
+		t.Errorf("Expected ErrLoaderBudget, got %v after %d loads", err, calls)
+	}
+	if len(m) != 1 || m["budget_hit"] == nil {
+		t.Errorf("Expected hits with the error, got %v", m)
+	}
+	// one miss in three keys isn't guarded
+	mc.SetString("budget_a", "a")
+	if _, err := mc.GetMultiLoad(ctx, keys, countingLoader(&calls)); err != nil || calls != 2 {
+		t.Errorf("Expected an unguarded load, got %v after %d loads", err, calls)
+	}
+	clock.Advance(time.Second)
+	miss()
+	if _, err := mc.GetMultiLoad(ctx, keys, countingLoader(&calls)); err != nil || calls != 3 {
+		t.Errorf("Expected budget to refill, got %v after %d loads", err, calls)
+	}
+	if n := metrics.get(MetricLoaderGuarded); n != 3 {
+		t.Errorf("Expected 3 guarded reads, got %d", n)
+	}
+}
+
+func TestLoaderBudgetWait(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		LoaderBudget: LoaderBudget{MissThreshold: 1, Rate: 0.1, Burst: 1},
+	})
+	keys := []string{"budget_wait_a", "budget_wait_b"}
+	var calls int
+	for _, k := range keys {
+		mc.Delete(k)
+	}
+	if _, err := mc.GetMultiLoad(context.Background(), keys, countingLoader(&calls)); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		mc.Delete(k)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := mc.GetMultiLoad(ctx, keys, countingLoader(&calls)); err != context.DeadlineExceeded || calls != 1 {
+		t.Errorf("Expected to give up waiting for budget, got %v after %d loads", err, calls)
+	}
+}
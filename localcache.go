@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultLocalCacheTTL is how long values are kept in the local cache tier
+// when Options.LocalCacheTTL is unset
+const DefaultLocalCacheTTL = time.Second
+
+// MetricLocalReads counts Gets consulting the local cache tier, tagged by
+// result: "hit" or "miss"
+const MetricLocalReads = "memcache.local.reads"
+
+// localGet returns key's value from the local cache tier of
+// Options.LocalCacheSize
+func (c *Client) localGet(key string) (*memcache.Item, bool) {
+	if c.local == nil {
+		return nil, false
+	}
+	i, ok := c.local.get(key, c.now(), c.opts.LocalCacheTTL)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	c.opts.Metrics.Count(MetricLocalReads, 1, map[string]string{"result": result})
+	return i, ok
+}
+
+// localStore records the result of a read from the servers in the local
+// cache tier: values are kept, misses drop the key. Errors leave it alone.
+func (c *Client) localStore(key string, i *memcache.Item, err error) {
+	if c.local == nil {
+		return
+	}
+	switch err {
+	case nil:
+		c.local.add(key, i, c.now())
+	case memcache.ErrCacheMiss:
+		c.local.remove(key)
+	}
+}
+
+// localWrite updates the local cache tier after op wrote item: a stored Set
+// replaces the value, anything else drops the key since its value on the
+// server isn't known
+func (c *Client) localWrite(op string, item *memcache.Item, err error) {
+	if c.local == nil {
+		return
+	}
+	if op != OpSet || err != nil {
+		c.local.remove(item.Key)
+		return
+	}
+	now := c.now()
+	stored := now
+	if ttl := expirationTTL(item.Expiration, now); item.Expiration > 0 && ttl < c.opts.LocalCacheTTL {
+		// aged so it is kept no longer than the server keeps it
+		stored = now.Add(ttl - c.opts.LocalCacheTTL)
+	}
+	c.local.add(item.Key, item, stored)
+}
+
+// localRemove drops key from the local cache tier
+func (c *Client) localRemove(key string) {
+	if c.local != nil {
+		c.local.remove(key)
+	}
+}
@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestLocalCache(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	clock := NewFakeClock(time.Now())
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{s.ln.Addr().String()}, Options{LocalCacheSize: 10, LocalCacheTTL: 10 * time.Second, Clock: clock, Metrics: metrics})
+	other := NewClient([]string{s.ln.Addr().String()})
+
+	if err := mc.SetString("local_key", "a"); err != nil {
+		t.Fatal(err)
+	}
+	// written by another client: the local copy is served until it expires
+	other.SetString("local_key", "b")
+	if v, ok := mc.GetString("local_key"); !ok || v != "a" {
+		t.Errorf("Expected the local copy, got %q %v", v, ok)
+	}
+	if v, ok := mc.GetString("local_key", SkipLocalCache()); !ok || v != "b" {
+		t.Errorf("Expected SkipLocalCache to read the server, got %q %v", v, ok)
+	}
+	clock.Advance(11 * time.Second)
+	if v, ok := mc.GetString("local_key"); !ok || v != "b" {
+		t.Errorf("Expected the expired copy to be reread, got %q %v", v, ok)
+	}
+	if v, ok := mc.GetString("local_key"); !ok || v != "b" {
+		t.Errorf("Expected the reread value to be kept, got %q %v", v, ok)
+	}
+	if n := metrics.get(MetricLocalReads); n != 3 {
+		t.Errorf("Expected 3 local reads, got %d", n)
+	}
+
+	// deletes drop the local copy
+	if err := mc.Delete("local_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Get("local_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss after Delete, got %v", err)
+	}
+
+	// items expiring before LocalCacheTTL are kept no longer
+	mc.SetString("local_short", "a", WithTTLOverride(2*time.Second))
+	other.SetString("local_short", "b")
+	clock.Advance(3 * time.Second)
+	if v, ok := mc.GetString("local_short"); !ok || v != "b" {
+		t.Errorf("Expected the short lived copy to expire, got %q %v", v, ok)
+	}
+}
+
+func TestLocalCacheCopies(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{LocalCacheSize: 10})
+	value := []byte("Hello")
+	if err := mc.Set(&memcache.Item{Key: "local_copy", Value: value}); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'J'
+	i, err := mc.Get("local_copy")
+	if err != nil || string(i.Value) != "Hello" {
+		t.Fatalf("Expected the value set, got %q %v", i.Value, err)
+	}
+	i.Value[0], i.Flags = 'X', 99
+	if i, _ := mc.Get("local_copy"); string(i.Value) != "Hello" || i.Flags != 0 {
+		t.Errorf("Expected an unmodified copy, got %q flags %d", i.Value, i.Flags)
+	}
+}
+
+func TestLocalCacheCAS(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{LocalCacheSize: 10})
+	mc.Set(&memcache.Item{Key: "local_cas", Value: []byte("a")})
+	err := mc.UpdateInPlace("local_cas", func(value []byte, flags uint32) ([]byte, uint32, error) {
+		return append(value, 'b'), flags, nil
+	}, 0)
+	if err != nil {
+		t.Fatalf("Expected the update to read a CAS ID from the server, got %v", err)
+	}
+	if i, err := mc.Get("local_cas"); err != nil || string(i.Value) != "ab" {
+		t.Errorf("Expected ab, got %v %v", i, err)
+	}
+}
@@ -0,0 +1,575 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalAddress is a server address that makes the client use an embedded
+// in-process cache instead of memcached, for development and tests on machines
+// without memcached:
+//
+//	mc := memcache.NewClient([]string{memcache.LocalAddress})
+//
+// The embedded cache speaks the memcached text protocol over loopback so values
+// go through exactly the same serialization as with a real server. It is started
+// on first use and shared by every client in the process. It is not bounded in
+// size and is not intended for production use.
+const LocalAddress = "local"
+
+var (
+	localOnce sync.Once
+	local     *localServer
+	localErr  error
+)
+
+// localServerAddr returns the address of the process wide embedded server
+// starting it if needed
+func localServerAddr() (string, error) {
+	localOnce.Do(func() {
+		local, localErr = startLocalServer()
+	})
+	if localErr != nil {
+		return "", localErr
+	}
+	return local.ln.Addr().String(), nil
+}
+
+// dialLocal connects to the embedded server. The configured dialer is bypassed
+// since the server always listens on loopback.
+func dialLocal(ctx context.Context) (net.Conn, error) {
+	addr, err := localServerAddr()
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// localServer is a minimal in-memory memcached text protocol server
+type localServer struct {
+	ln    net.Listener
+	clock Clock
+
+	mu    sync.Mutex
+	items map[string]*localItem
+	cas   uint64
+	// hits and misses count keys read by get and gets
+	hits, misses int64
+	// discard answers writes, deletes and touches as if they succeeded
+	// without keeping anything, for NewDisabledClient
+	discard bool
+}
+
+type localItem struct {
+	flags uint32
+	exp   time.Time
+	value []byte
+	cas   uint64
+
+	// meta get state: whether the item was fetched, when it was last
+	// accessed, and whether a client was sent the win flag to recache it
+	fetched  bool
+	accessed time.Time
+	won      bool
+}
+
+func startLocalServer() (*localServer, error) {
+	return newLocalServer(systemClock{})
+}
+
+// newLocalServer starts an embedded server expiring items by clock
+func newLocalServer(clock Clock) (*localServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &localServer{ln: ln, clock: clock, items: make(map[string]*localItem)}
+	go s.serve()
+	return s, nil
+}
+
+func (s *localServer) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(nc)
+	}
+}
+
+func (s *localServer) handle(nc net.Conn) {
+	defer nc.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		noreply := args[len(args)-1] == "noreply"
+		if noreply {
+			args = args[:len(args)-1]
+		}
+		var out bytes.Buffer
+		if err := s.command(&out, rw.Reader, args); err != nil {
+			return
+		}
+		if noreply {
+			continue
+		}
+		rw.Write(out.Bytes())
+		if rw.Flush() != nil {
+			return
+		}
+	}
+}
+
+// command executes one command writing the response to out. A returned error
+// closes the connection.
+func (s *localServer) command(out *bytes.Buffer, r *bufio.Reader, args []string) error {
+	switch cmd := args[0]; cmd {
+	case "get", "gets":
+		s.get(out, args[1:], cmd == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		if len(args) < 5 || (cmd == "cas" && len(args) < 6) {
+			out.WriteString("ERROR\r\n")
+			return nil
+		}
+		flags, err1 := strconv.ParseUint(args[2], 10, 32)
+		exp, err2 := strconv.ParseInt(args[3], 10, 64)
+		size, err3 := strconv.Atoi(args[4])
+		if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+			out.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		var cas uint64
+		if cmd == "cas" {
+			cas, _ = strconv.ParseUint(args[5], 10, 64)
+		}
+		out.WriteString(s.store(cmd, args[1], uint32(flags), exp, data[:size], cas) + "\r\n")
+	case "mg":
+		if len(args) < 2 {
+			out.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		s.metaGet(out, args[1], args[2:])
+	case "ms":
+		if len(args) < 3 {
+			out.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		size, err := strconv.Atoi(args[2])
+		if err != nil || size < 0 {
+			out.WriteString("CLIENT_ERROR bad data chunk\r\n")
+			return nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		flags, _ := metaToken(args[3:], 'F')
+		exp, _ := metaToken(args[3:], 'T')
+		if s.store("set", args[1], uint32(flags), exp, data[:size], 0) == "STORED" {
+			out.WriteString("HD\r\n")
+		} else {
+			out.WriteString("NS\r\n")
+		}
+	case "md":
+		if len(args) < 2 {
+			out.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		s.mu.Lock()
+		if s.live(args[1]) != nil || s.discard {
+			delete(s.items, args[1])
+			out.WriteString("HD\r\n")
+		} else {
+			out.WriteString("NF\r\n")
+		}
+		s.mu.Unlock()
+	case "delete":
+		if len(args) < 2 {
+			out.WriteString("ERROR\r\n")
+			return nil
+		}
+		s.mu.Lock()
+		if s.live(args[1]) != nil || s.discard {
+			delete(s.items, args[1])
+			out.WriteString("DELETED\r\n")
+		} else {
+			out.WriteString("NOT_FOUND\r\n")
+		}
+		s.mu.Unlock()
+	case "incr", "decr":
+		if len(args) < 3 {
+			out.WriteString("ERROR\r\n")
+			return nil
+		}
+		out.WriteString(s.incr(args[1], args[2], cmd == "incr") + "\r\n")
+	case "touch":
+		if len(args) < 3 {
+			out.WriteString("ERROR\r\n")
+			return nil
+		}
+		exp, _ := strconv.ParseInt(args[2], 10, 64)
+		s.mu.Lock()
+		if i := s.live(args[1]); i != nil {
+			i.exp = s.expiry(exp)
+			out.WriteString("TOUCHED\r\n")
+		} else if s.discard {
+			out.WriteString("TOUCHED\r\n")
+		} else {
+			out.WriteString("NOT_FOUND\r\n")
+		}
+		s.mu.Unlock()
+	case "flush_all":
+		s.mu.Lock()
+		s.items = make(map[string]*localItem)
+		s.mu.Unlock()
+		out.WriteString("OK\r\n")
+	case "version":
+		out.WriteString("VERSION 1.6.0-local\r\n")
+	case "verbosity":
+		out.WriteString("OK\r\n")
+	case "stats":
+		s.stats(out, args[1:])
+	case "lru_crawler":
+		if len(args) < 2 || args[1] != "metadump" {
+			out.WriteString("CLIENT_ERROR unsupported\r\n")
+			return nil
+		}
+		s.metadump(out)
+	case "quit":
+		return io.EOF
+	default:
+		out.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+// live returns the unexpired item for key; s.mu must be held
+func (s *localServer) live(key string) *localItem {
+	i, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !i.exp.IsZero() && !s.clock.Now().Before(i.exp) {
+		delete(s.items, key)
+		return nil
+	}
+	return i
+}
+
+// expiry converts a protocol expiration (relative seconds up to 30 days, else a
+// unix timestamp; negative is already expired) to an absolute time
+func (s *localServer) expiry(exp int64) time.Time {
+	switch {
+	case exp == 0:
+		return time.Time{}
+	case exp < 0:
+		return s.clock.Now().Add(-time.Second)
+	case exp > 60*60*24*30:
+		return time.Unix(exp, 0)
+	}
+	return s.clock.Now().Add(time.Duration(exp) * time.Second)
+}
+
+func (s *localServer) get(out *bytes.Buffer, keys []string, withCAS bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		i := s.live(k)
+		if i == nil {
+			s.misses++
+			continue
+		}
+		s.hits++
+		if withCAS {
+			fmt.Fprintf(out, "VALUE %s %d %d %d\r\n", k, i.flags, len(i.value), i.cas)
+		} else {
+			fmt.Fprintf(out, "VALUE %s %d %d\r\n", k, i.flags, len(i.value))
+		}
+		out.Write(i.value)
+		out.WriteString("\r\n")
+	}
+	out.WriteString("END\r\n")
+}
+
+// metaGet answers a meta get supporting the v, t, f, c, s, k, h and l flags
+// and the N (vivify), R (recache) and T (touch) tokens
+func (s *localServer) metaGet(out *bytes.Buffer, key string, flags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	i := s.live(key)
+	win := false
+	if i == nil {
+		vivify, ok := metaToken(flags, 'N')
+		if !ok {
+			out.WriteString("EN\r\n")
+			return
+		}
+		s.cas++
+		i = &localItem{exp: s.expiry(vivify), cas: s.cas, accessed: now, won: true}
+		s.items[key] = i
+		win = true
+	} else {
+		if exp, ok := metaToken(flags, 'T'); ok {
+			i.exp = s.expiry(exp)
+		}
+		if recache, ok := metaToken(flags, 'R'); ok && !i.won && !i.exp.IsZero() && i.exp.Sub(now) < time.Duration(recache)*time.Second {
+			i.won = true
+			win = true
+		}
+	}
+	var ret []string
+	var value bool
+	for _, f := range flags {
+		switch f {
+		case "v":
+			value = true
+		case "t":
+			ttl := int64(-1)
+			if !i.exp.IsZero() {
+				ttl = int64((i.exp.Sub(now) + time.Second - 1) / time.Second)
+			}
+			ret = append(ret, fmt.Sprintf("t%d", ttl))
+		case "f":
+			ret = append(ret, fmt.Sprintf("f%d", i.flags))
+		case "c":
+			ret = append(ret, fmt.Sprintf("c%d", i.cas))
+		case "s":
+			ret = append(ret, fmt.Sprintf("s%d", len(i.value)))
+		case "k":
+			ret = append(ret, "k"+key)
+		case "h":
+			if i.fetched {
+				ret = append(ret, "h1")
+			} else {
+				ret = append(ret, "h0")
+			}
+		case "l":
+			ret = append(ret, fmt.Sprintf("l%d", int64(now.Sub(i.accessed)/time.Second)))
+		}
+	}
+	switch {
+	case win:
+		ret = append(ret, "W")
+	case i.won:
+		ret = append(ret, "Z")
+	}
+	i.fetched = true
+	i.accessed = now
+	if !value {
+		out.WriteString(strings.TrimSpace("HD "+strings.Join(ret, " ")) + "\r\n")
+		return
+	}
+	fmt.Fprintf(out, "%s\r\n", strings.TrimSpace(fmt.Sprintf("VA %d %s", len(i.value), strings.Join(ret, " "))))
+	out.Write(i.value)
+	out.WriteString("\r\n")
+}
+
+// metaToken returns the numeric argument of the meta flag c, e.g. 30 for T30
+func metaToken(flags []string, c byte) (int64, bool) {
+	for _, f := range flags {
+		if len(f) > 1 && f[0] == c {
+			n, err := strconv.ParseInt(f[1:], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+func (s *localServer) store(cmd, key string, flags uint32, exp int64, value []byte, cas uint64) string {
+	if s.discard {
+		return "STORED"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := s.live(key)
+	switch cmd {
+	case "add":
+		if existing != nil {
+			return "NOT_STORED"
+		}
+	case "replace", "append", "prepend":
+		if existing == nil {
+			return "NOT_STORED"
+		}
+	case "cas":
+		if existing == nil {
+			return "NOT_FOUND"
+		}
+		if existing.cas != cas {
+			return "EXISTS"
+		}
+	}
+	s.cas++
+	switch cmd {
+	case "append":
+		existing.value = append(append([]byte{}, existing.value...), value...)
+		existing.cas = s.cas
+	case "prepend":
+		existing.value = append(append([]byte{}, value...), existing.value...)
+		existing.cas = s.cas
+	default:
+		s.items[key] = &localItem{flags: flags, exp: s.expiry(exp), value: value, cas: s.cas, accessed: s.clock.Now()}
+	}
+	return "STORED"
+}
+
+func (s *localServer) incr(key, delta string, up bool) string {
+	d, err := strconv.ParseUint(delta, 10, 64)
+	if err != nil {
+		return "CLIENT_ERROR invalid numeric delta argument"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.live(key)
+	if i == nil {
+		return "NOT_FOUND"
+	}
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(i.value)), 10, 64)
+	if err != nil {
+		return "CLIENT_ERROR cannot increment or decrement non-numeric value"
+	}
+	switch {
+	case up:
+		n += d
+	case d > n:
+		n = 0
+	default:
+		n -= d
+	}
+	s.cas++
+	i.value = []byte(strconv.FormatUint(n, 10))
+	i.cas = s.cas
+	return string(i.value)
+}
+
+// localItemOverhead approximates memcached's per item header
+const localItemOverhead = 48
+
+// localSlabClass returns the memcached style slab class and chunk size (96 bytes
+// growing by a factor of 1.25) an item of size bytes is stored in
+func localSlabClass(size int) (int, int64) {
+	class, chunk := 1, int64(96)
+	for chunk < int64(size) {
+		class++
+		chunk = (chunk*5/4 + 7) &^ 7
+	}
+	return class, chunk
+}
+
+// size returns the total size of the item stored under key
+func (i *localItem) size(key string) int {
+	return len(key) + len(i.value) + localItemOverhead
+}
+
+func (s *localServer) stats(out *bytes.Buffer, args []string) {
+	if len(args) == 1 && args[0] == "slabs" {
+		s.slabs(out)
+		return
+	}
+	if len(args) == 1 && args[0] == "items" {
+		s.itemClasses(out)
+		return
+	}
+	if len(args) > 0 {
+		out.WriteString("END\r\n")
+		return
+	}
+	s.mu.Lock()
+	var size int
+	for _, i := range s.items {
+		size += len(i.value)
+	}
+	fmt.Fprintf(out, "STAT version 1.6.0-local\r\nSTAT curr_items %d\r\nSTAT bytes %d\r\nSTAT get_hits %d\r\nSTAT get_misses %d\r\nEND\r\n",
+		len(s.items), size, s.hits, s.misses)
+	s.mu.Unlock()
+}
+
+// itemClasses answers stats items with the number of items in each slab class
+func (s *localServer) itemClasses(out *bytes.Buffer) {
+	s.mu.Lock()
+	number := make(map[int]int64)
+	for k, i := range s.items {
+		class, _ := localSlabClass(i.size(k))
+		number[class]++
+	}
+	s.mu.Unlock()
+	classes := make([]int, 0, len(number))
+	for class := range number {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+	for _, class := range classes {
+		fmt.Fprintf(out, "STAT items:%d:number %d\r\n", class, number[class])
+	}
+	out.WriteString("END\r\n")
+}
+
+func (s *localServer) slabs(out *bytes.Buffer) {
+	s.mu.Lock()
+	used := make(map[int]int64)
+	chunks := make(map[int]int64)
+	for k, i := range s.items {
+		class, chunk := localSlabClass(i.size(k))
+		used[class]++
+		chunks[class] = chunk
+	}
+	s.mu.Unlock()
+	classes := make([]int, 0, len(used))
+	for class := range used {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+	for _, class := range classes {
+		pages := (used[class]*chunks[class] + 1<<20 - 1) >> 20
+		fmt.Fprintf(out, "STAT %d:chunk_size %d\r\nSTAT %d:used_chunks %d\r\nSTAT %d:total_pages %d\r\n",
+			class, chunks[class], class, used[class], class, pages)
+	}
+	fmt.Fprintf(out, "STAT active_slabs %d\r\nEND\r\n", len(classes))
+}
+
+func (s *localServer) metadump(out *bytes.Buffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.items))
+	for k := range s.items {
+		if s.live(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		i := s.items[k]
+		exp := int64(-1)
+		if !i.exp.IsZero() {
+			exp = i.exp.Unix()
+		}
+		class, _ := localSlabClass(i.size(k))
+		fmt.Fprintf(out, "key=%s exp=%d la=%d cas=%d fetch=no cls=%d size=%d flags=%d\r\n",
+			url.QueryEscape(k), exp, s.clock.Now().Unix(), i.cas, class, i.size(k), i.flags)
+	}
+	out.WriteString("END\r\n")
+}
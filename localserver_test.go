@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestLocalClient(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+
+	if err := mc.Set(StringItem("local_str", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := mc.GetString("local_str"); !ok || v != "hello" {
+		t.Errorf("Expected hello, got: %q %v", v, ok)
+	}
+	if err := mc.Set(Int64Item("local_int", 41)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := mc.Increment("local_int", 1); err != nil || n != 42 {
+		t.Errorf("Expected 42, got: %d %v", n, err)
+	}
+	if err := mc.Set(BoolItem("local_bool", true)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := mc.GetBool("local_bool"); !ok || !v {
+		t.Errorf("Expected true, got: %v %v", v, ok)
+	}
+
+	if err := mc.Add(&memcache.Item{Key: "local_str", Value: []byte("x")}); err != memcache.ErrNotStored {
+		t.Errorf("Expected ErrNotStored, got: %v", err)
+	}
+	it, err := mc.Get("local_str")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it.Value = []byte("updated")
+	if err := mc.CompareAndSwap(it); err != nil {
+		t.Errorf("Expected CAS to succeed, got: %v", err)
+	}
+	if err := mc.CompareAndSwap(it); err != memcache.ErrCASConflict {
+		t.Errorf("Expected ErrCASConflict, got: %v", err)
+	}
+	if err := mc.Delete("local_str"); err != nil {
+		t.Errorf("Expected delete to succeed, got: %v", err)
+	}
+	if _, err := mc.Get("local_str"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got: %v", err)
+	}
+	if err := mc.Set(&memcache.Item{Key: "local_expired", Value: []byte("x"), Expiration: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Get("local_expired"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected expired item to miss, got: %v", err)
+	}
+
+	var found bool
+	err = mc.Metadump(context.Background(), func(_ net.Addr, e MetadumpEntry) error {
+		if e.Key == "local_bool" {
+			found = e.HasFlags && e.Flags == FLAG_BOOL
+		}
+		return nil
+	})
+	if err != nil || !found {
+		t.Errorf("Expected metadump to list local_bool with flags, got: %v %v", found, err)
+	}
+}
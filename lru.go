@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// lru is a size bounded in-process store of items recording when each was stored.
+// Items are copied in and out, so callers may modify what they store or get.
+// It is safe for concurrent use.
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	item   *memcache.Item
+	stored time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// add stores item under key evicting the least recently used entry when full
+func (l *lru) add(key string, item *memcache.Item, now time.Time) {
+	item = cloneItem(item)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		l.ll.MoveToFront(e)
+		entry := e.Value.(*lruEntry)
+		entry.item = item
+		entry.stored = now
+		return
+	}
+	l.entries[key] = l.ll.PushFront(&lruEntry{key, item, now})
+	if l.ll.Len() > l.size {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// get returns the item stored under key if it was stored no longer than maxAge before now
+func (l *lru) get(key string, now time.Time, maxAge time.Duration) (*memcache.Item, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if now.Sub(entry.stored) > maxAge {
+		return nil, false
+	}
+	l.ll.MoveToFront(e)
+	return cloneItem(entry.item), true
+}
+
+// remove deletes key
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		l.ll.Remove(e)
+		delete(l.entries, key)
+	}
+}
+
+// len returns the number of stored entries
+func (l *lru) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// cloneItem returns a copy of i not sharing its Value
+func cloneItem(i *memcache.Item) *memcache.Item {
+	cp := *i
+	cp.Value = append([]byte(nil), i.Value...)
+	return &cp
+}
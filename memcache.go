@@ -7,17 +7,13 @@ package memcache
 // as stored with https://pypi.python.org/pypi/pylibmc
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"errors"
-	"hash"
 	"strconv"
-	"strings"
 
 	"github.com/bradfitz/gomemcache/memcache"
-	"github.com/dgryski/dgohash"
-	"github.com/nlpodyssey/gopickle/pickle"
-	"github.com/rckclmbr/goketama/ketama"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
 )
 
 // these flags match pylibmc in _pylibmcmodule.h
@@ -33,34 +29,157 @@ const (
 // Client wraps a memcache Client with python/pylibmc/libmemcache compatibility
 type Client struct {
 	*memcache.Client
-}
+	selector  *switchSelector
+	topology  topology
+	opts      Options
+	stale     *lru
+	lastGood  *lru
+	local     *lru
+	stats     *statsTracker
+	conns     *connTracker
+	hotKeys   *hotKeyTracker
+	slowLog   *slowLog
+	batcher   *getBatcher
+	flights   *flightGroup
+	tenants   *tenantBuckets
+	writes    *writeSampler
+	expiry    *expiryWatchers
+	features  *featureTable
+	meta      *metaConns
+	async     *asyncWriter
+	resolver  *resolver
+	discovery *discovery
 
-// Since we use non-weighted ketama, this provides the Jenkins one-at-a-time hash
-// function to ketama. (When using weighted libmemcached chooses md5)
-func ketamaDigest() hash.Hash {
-	return dgohash.NewJenkins32()
+	backoff    *reconnectBackoff
+	breaker    *circuitBreaker
+	reconnects *tokenBucket
+	loads      *tokenBucket
 }
 
 // create an address struct that fulfills net.Addr while still returning hostnames
 type hostAddress struct {
 	hostport string
+	network  string
 }
 
-func (a *hostAddress) Network() string { return "tcp" }
+// newHostAddress returns the address of server, "host:port" or a unix domain
+// socket path or URL (see ketamacompat.SocketPath)
+func newHostAddress(server string) *hostAddress {
+	if path, ok := ketamacompat.SocketPath(server); ok {
+		return &hostAddress{hostport: path, network: "unix"}
+	}
+	return &hostAddress{hostport: server, network: "tcp"}
+}
+
+func (a *hostAddress) Network() string { return a.network }
 func (a *hostAddress) String() string  { return a.hostport }
 
-// NewClient returns a memcache.Client with ketama consistent hashing (non-weighted)
+// NewClient returns a memcache.Client with ketama consistent hashing (non-weighted).
+// Servers are "host:port", or the path ("/var/run/memcached.sock") or URL
+// ("unix:///var/run/memcached.sock") of a unix domain socket, placed on the
+// continuum like libmemcached places sockets.
 func NewClient(addresses []string) *Client {
-	var servers []ketama.ServerInfo
-	for _, endpoint := range addresses {
-		var serverWeight uint64
-		// construct our own address instead of net.ResolveTCPAddress since we want to
-		// keep hostnames for hashing instead of the actual ip address
-		addr := &hostAddress{endpoint}
-		servers = append(servers, ketama.ServerInfo{addr, serverWeight})
+	return NewClientWithOptions(addresses, Options{})
+}
+
+// NewClientWithOptions returns a memcache.Client with ketama consistent hashing (non-weighted)
+// configured by opts
+func NewClientWithOptions(addresses []string, opts Options) *Client {
+	return newClient(append([]string(nil), addresses...), nil, opts)
+}
+
+// NewWeightedClient returns a memcache.Client with weighted ketama consistent
+// hashing, placing keys like libmemcached (and pylibmc with
+// {"ketama_weighted": True}) does for servers with the given weights: points
+// and keys are hashed with MD5 and each server gets points in proportion to
+// its weight.
+func NewWeightedClient(weights map[string]uint64) *Client {
+	return NewWeightedClientWithOptions(weights, Options{})
+}
+
+// NewWeightedClientWithOptions is NewWeightedClient configured by opts.
+// Options.ProxyMode and Options.Distribution are ignored.
+func NewWeightedClientWithOptions(weights map[string]uint64, opts Options) *Client {
+	opts.ProxyMode = ProxyNone
+	copied := make(map[string]uint64, len(weights))
+	for server, weight := range weights {
+		copied[server] = weight
+	}
+	return newClient(nil, copied, opts)
+}
+
+// newClient returns a Client distributing keys over addresses, or the servers
+// of weights for weighted clients
+func newClient(addresses []string, weights map[string]uint64, opts Options) *Client {
+	selector := &switchSelector{}
+	c := &Client{
+		Client:   memcache.NewFromSelector(selector),
+		selector: selector,
+		opts:     opts.withDefaults(),
+		conns:    newConnTracker(),
+		tenants:  &tenantBuckets{buckets: make(map[string]*tokenBucket)},
+		expiry:   &expiryWatchers{},
+		features: &featureTable{},
+		meta:     &metaConns{},
+	}
+	c.DialContext = c.dial
+	c.Timeout = opts.Timeout
+	c.MaxIdleConns = opts.MaxIdleConns
+	c.opts.Pipeline.codecs = &codecRegistry{}
+	c.topology.addresses, c.topology.weights = addresses, weights
+	c.useSelector(newSelector(addresses, weights, c.opts))
+	if c.opts.MaxStaleness > 0 {
+		c.stale = newLRU(c.opts.StaleCacheSize)
+	}
+	if c.opts.LocalCacheSize > 0 {
+		c.local = newLRU(c.opts.LocalCacheSize)
+	}
+	if c.opts.LastGoodMaxAge > 0 {
+		c.lastGood = newLRU(c.opts.StaleCacheSize)
+	}
+	if c.opts.ServerStatsWindow > 0 {
+		c.stats = newStatsTracker(c.opts.ServerStatsWindow)
 	}
-	continuum := ketama.New(servers, ketamaDigest)
-	return &Client{memcache.NewFromSelector(continuum)}
+	if c.opts.HotKeys > 0 {
+		c.hotKeys = newHotKeyTracker(c.opts.HotKeys)
+	}
+	if c.opts.SlowOpThreshold > 0 {
+		c.slowLog = newSlowLog(c.opts.SlowOpThreshold)
+	}
+	if c.opts.CoalesceGets {
+		c.flights = newFlightGroup()
+	}
+	if c.opts.BatchWindow > 0 {
+		c.batcher = newGetBatcher(c, c.opts.BatchWindow, c.opts.BatchMaxKeys)
+	}
+	if c.opts.AsyncWrites {
+		c.async = newAsyncWriter(c, c.opts.AsyncBatchSize, c.opts.AsyncFlushInterval)
+	}
+	if c.opts.OnWrite != nil && c.opts.WriteSampleRate > 0 {
+		c.writes = newWriteSampler(c.opts)
+	}
+	if c.opts.ReconnectBackoff > 0 {
+		c.backoff = newReconnectBackoff(c.opts.ReconnectBackoff, c.opts.MaxReconnectBackoff)
+	}
+	if c.opts.ResolveInterval > 0 {
+		c.resolver = newResolver(c, c.opts.ResolveInterval)
+	}
+	if c.opts.BreakerFailures > 0 {
+		c.breaker = newCircuitBreaker(c.opts.BreakerFailures, c.opts.BreakerCooldown)
+	}
+	if c.opts.ReconnectRate > 0 {
+		c.reconnects = newTokenBucket(c.opts.Clock, c.opts.ReconnectRate, c.opts.ReconnectBurst)
+	}
+	if c.opts.LoaderBudget.Rate > 0 {
+		c.loads = newTokenBucket(c.opts.Clock, c.opts.LoaderBudget.Rate, c.opts.LoaderBudget.Burst)
+	}
+	if c.opts.EagerConnect {
+		go c.Warm(context.Background(), 1)
+	}
+	if c.opts.DetectFeatures {
+		go c.DetectFeatures(context.Background())
+	}
+	return c
 }
 
 type Item struct {
@@ -70,55 +189,116 @@ type Item struct {
 var InvalidType error = errors.New("Invalid Value Type")
 
 // GetString gets k from cache returning whether or not the get was successful
-func (c *Client) GetString(k string) (string, bool) {
-	i, err := c.Get(k)
-	if err == nil {
-		s, err := (&Item{i}).String()
-		if err == nil {
-			return s, true
-		}
-	}
-	return "", false
+func (c *Client) GetString(k string, opts ...CallOption) (string, bool) {
+	return getStringPolicy(c.getter(opts), k, c.opts.Unicode)
+}
+
+func getString(c itemGetter, k string) (string, bool) {
+	return getStringPolicy(c, k, picklecompat.UnicodeRaw)
+}
+
+func getStringPolicy(c itemGetter, k string, p picklecompat.UnicodePolicy) (string, bool) {
+	s, err := getDecoded(c, k, func(i *Item) (string, error) { return i.StringPolicy(p) })
+	return s, err == nil
 }
 
 // String returns the compatible python string value
 func (i *Item) String() (string, error) {
-	switch i.Flags {
-	case FLAG_PICKLE:
-		s, err := unpickle(string(i.Value))
+	return i.StringPolicy(picklecompat.UnicodeRaw)
+}
+
+// StringPolicy is String applying p to pickled strings holding invalid UTF-8
+func (i *Item) StringPolicy(p picklecompat.UnicodePolicy) (string, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return "", err
+	}
+	opts := picklecompat.DecodeOptions{Unicode: p}
+	if i.Flags == FLAG_PICKLE || (i.Flags == FLAG_NONE && looksPickled(i.Value)) {
+		v, err := picklecompat.DecodeWith(i.Value, opts)
 		if err != nil {
 			return "", err
 		}
-		return s.(string), nil
-	case FLAG_NONE:
-		if bytes.HasPrefix(i.Value, []byte{0x80, 0x2}) {
-			s, err := unpickle(string(i.Value))
-			if err != nil {
-				return "", err
-			}
-			return s.(string), nil
+		s, ok := v.(string)
+		if !ok {
+			// e.g. None, which IsNone detects
+			return "", InvalidType
 		}
+		return s, nil
+	}
+	if i.Flags == FLAG_NONE {
 		return string(i.Value), nil
 	}
 	return "", InvalidType
 }
 
-// GetInt64 gets an int64 from cache returning whether or not the get was successful
-func (c *Client) GetInt64(k string) (int64, bool) {
-	i, err := c.Get(k)
-	if err == nil {
-		n, err := (&Item{i}).Int64()
-		if err == nil {
-			return n, true
-		}
+// IsNone reports whether the item holds a pickled Python None
+func (i *Item) IsNone() bool {
+	i, err := i.inflated()
+	if err != nil || (i.Flags != FLAG_PICKLE && (i.Flags != FLAG_NONE || !looksPickled(i.Value))) {
+		return false
 	}
-	return 0, false
+	v, err := picklecompat.Decode(i.Value)
+	return err == nil && v == nil
+}
+
+// GetStringOrNone is GetString distinguishing a cached Python None (isNone and
+// ok are true) from a miss or a value that isn't a string (ok is false)
+func (c *Client) GetStringOrNone(k string, opts ...CallOption) (s string, isNone bool, ok bool) {
+	isNone, ok = getOrNone(c.getter(opts), k, func(i *Item) (err error) {
+		s, err = i.StringPolicy(c.opts.Unicode)
+		return
+	})
+	return
+}
+
+// GetInt64OrNone is GetInt64 distinguishing a cached Python None
+func (c *Client) GetInt64OrNone(k string, opts ...CallOption) (n int64, isNone bool, ok bool) {
+	isNone, ok = getOrNone(c.getter(opts), k, func(i *Item) (err error) {
+		n, err = i.Int64()
+		return
+	})
+	return
+}
+
+// GetFloat64OrNone is GetFloat64 distinguishing a cached Python None
+func (c *Client) GetFloat64OrNone(k string, opts ...CallOption) (f float64, isNone bool, ok bool) {
+	isNone, ok = getOrNone(c.getter(opts), k, func(i *Item) (err error) {
+		f, err = i.Float64()
+		return
+	})
+	return
+}
+
+// getOrNone gets k reporting whether it holds None, or else decoding it with decode
+func getOrNone(c itemGetter, k string, decode func(*Item) error) (isNone bool, ok bool) {
+	isNone, err := getDecoded(c, k, func(i *Item) (bool, error) {
+		if i.IsNone() {
+			return true, nil
+		}
+		return false, decode(i)
+	})
+	return isNone, err == nil
+}
+
+// GetInt64 gets an int64 from cache returning whether or not the get was successful
+func (c *Client) GetInt64(k string, opts ...CallOption) (int64, bool) {
+	return getInt64(c.getter(opts), k)
+}
+
+func getInt64(c itemGetter, k string) (int64, bool) {
+	n, err := getDecoded(c, k, (*Item).Int64)
+	return n, err == nil
 }
 
 // Int64 returns the compatible python int value
 func (i *Item) Int64() (int64, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return 0, err
+	}
 	if i.Flags == FLAG_INTEGER || i.Flags == FLAG_LONG {
-		n, err := strconv.ParseInt(string(i.Value), 10, 64)
+		n, err := parseInt64(i.Value)
 		if err == nil {
 			return n, nil
 		}
@@ -127,32 +307,72 @@ func (i *Item) Int64() (int64, error) {
 	return 0, InvalidType
 }
 
+// GetFloat64 gets a float64 from cache returning whether or not the get was successful
+func (c *Client) GetFloat64(k string, opts ...CallOption) (float64, bool) {
+	return getFloat64(c.getter(opts), k)
+}
+
+func getFloat64(c itemGetter, k string) (float64, bool) {
+	f, err := getDecoded(c, k, (*Item).Float64)
+	return f, err == nil
+}
+
+// Float64 returns the compatible python float value: a pickled float (or int),
+// or a float or integer stored as a string
+func (i *Item) Float64() (float64, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return 0, err
+	}
+	switch i.Flags {
+	case FLAG_PICKLE:
+		v, err := picklecompat.Decode(i.Value)
+		if err != nil {
+			return 0, err
+		}
+		if f, ok := toFloat(v); ok {
+			return f, nil
+		}
+	case FLAG_NONE:
+		if looksPickled(i.Value) {
+			return (&Item{&memcache.Item{Value: i.Value, Flags: FLAG_PICKLE}}).Float64()
+		}
+		return strconv.ParseFloat(string(i.Value), 64)
+	case FLAG_INTEGER, FLAG_LONG:
+		n, err := parseInt64(i.Value)
+		return float64(n), err
+	}
+	return 0, InvalidType
+}
+
 // Bool returns the python compatible boolean.
 func (i *Item) Bool() (bool, error) {
+	i, err := i.inflated()
+	if err != nil {
+		return false, err
+	}
 	if i.Flags != FLAG_BOOL && i.Flags != FLAG_INTEGER {
 		return false, InvalidType
 	}
 
 	// we allow the integer 0/1 values to be interpreted as boolean
-	s := string(i.Value)
-	if s == "0" {
+	switch string(i.Value) {
+	case "0":
 		return false, nil
-	} else if s == "1" {
+	case "1":
 		return true, nil
 	}
 	return false, errors.New("Invalid Boolean Value")
 }
 
 // GetBool returns boolean values or integer 0/1 as a boolean value.
-func (c *Client) GetBool(k string) (bool, bool) {
-	i, err := c.Get(k)
-	if err == nil {
-		b, err := (&Item{i}).Bool()
-		if err == nil {
-			return b, true
-		}
-	}
-	return false, false
+func (c *Client) GetBool(k string, opts ...CallOption) (bool, bool) {
+	return getBool(c.getter(opts), k)
+}
+
+func getBool(c itemGetter, k string) (bool, bool) {
+	b, err := getDecoded(c, k, (*Item).Bool)
+	return b, err == nil
 }
 
 // StringItem returns a memcache.Item suitable for storing a utf-8 string
@@ -168,17 +388,14 @@ func StringItem(k, s string) *memcache.Item {
 // UnicodeItem returns a memcache.Item with a string stored as a python
 // picked unicode object
 func UnicodeItem(k, s string) *memcache.Item {
-	size := len(s)
-	b := make([]byte, size+10)
-	b[0] = 0x80 // 2 byte pickle pre-amble - 0x80, 0x2 (pickle flag and version)
-	b[1] = 0x2
-	b[2] = 0x58 // 1 byte unicode opcode - 0x58
-	// 4 byte size - little endian
-	binary.LittleEndian.PutUint32(b[3:], uint32(len(s)))
-	copy(b[7:], s)
-	b[size+7] = 0x71 // 2 byte BINPUT 1 - 0x71, 0x1
-	b[size+8] = 0x1
-	b[size+9] = 0x2e // 1 byte stop opcode  - 0x2e
+	return UnicodeItemMemo(k, s, picklecompat.MemoPython2)
+}
+
+// UnicodeItemMemo is UnicodeItem with the pickle memoization chosen by memo.
+// UnicodeItem matches picklecompat.MemoPython2; use MemoPython3 or MemoNone when
+// the bytes must equal what a Python 3 client or a memo-less pickler stores.
+func UnicodeItemMemo(k, s string, memo picklecompat.Memo) *memcache.Item {
+	b, _ := picklecompat.EncodeWith(s, picklecompat.EncodeOptions{Memo: memo})
 	return &memcache.Item{
 		Key:   k,
 		Value: b,
@@ -208,17 +425,96 @@ func BoolItem(k string, v bool) *memcache.Item {
 func Int64Item(k string, v int64) *memcache.Item {
 	return &memcache.Item{
 		Key:   k,
-		Value: []byte(strconv.FormatInt(v, 10)),
+		Value: formatInt64(v),
 		Flags: FLAG_INTEGER,
 	}
 }
 
-func unpickle(s string) (interface{}, error) {
-	pickledData := strings.NewReader(s)
-	unpickler := pickle.NewUnpickler(pickledData)
-	value, err := unpickler.Load()
+// NoneItem returns a memcache.Item storing Python None the way pylibmc does:
+// pickled with protocol 2
+func NoneItem(k string) *memcache.Item {
+	return &memcache.Item{
+		Key:   k,
+		Value: []byte("\x80\x02N."),
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// Float64Item returns a memcache.Item storing a python float the way pylibmc
+// does: pickled with protocol 2, e.g. "\x80\x02G?\xf8\x00\x00\x00\x00\x00\x00." for 1.5
+func Float64Item(k string, v float64) *memcache.Item {
+	b, _ := picklecompat.Encode(v)
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// EncodePickle pickles v with protocol 2 so Python's pickle.loads (and pylibmc)
+// can load it. v may be nil, a bool, an integer, *big.Int, float64, a string of
+// any length, []byte (as Python 3 bytes), []interface{} or
+// map[string]interface{} nesting those, or one of the gopickle types decoded
+// values are returned as.
+func EncodePickle(v interface{}) ([]byte, error) {
+	return picklecompat.Encode(v)
+}
+
+// PickleItem returns a memcache.Item storing v pickled with EncodePickle
+func PickleItem(k string, v interface{}) (*memcache.Item, error) {
+	b, err := EncodePickle(v)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return value, nil
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}, nil
+}
+
+// pickle pickles v with Options.PickleProtocol
+func (c *Client) pickle(v interface{}) ([]byte, error) {
+	return picklecompat.EncodeWith(v, picklecompat.EncodeOptions{Protocol: c.opts.PickleProtocol})
+}
+
+// UnicodeItem is UnicodeItem pickling s with Options.PickleProtocol, matching
+// Python 3's pickle.dumps(s, protocol) for protocols 4 and 5
+func (c *Client) UnicodeItem(k, s string) *memcache.Item {
+	if c.opts.PickleProtocol == 0 || c.opts.PickleProtocol == 2 {
+		return UnicodeItem(k, s)
+	}
+	b, _ := picklecompat.EncodeWith(s, picklecompat.EncodeOptions{Protocol: c.opts.PickleProtocol, Memo: picklecompat.MemoPython3})
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}
+}
+
+// PickleItem is PickleItem pickling v with Options.PickleProtocol
+func (c *Client) PickleItem(k string, v interface{}) (*memcache.Item, error) {
+	b, err := c.pickle(v)
+	if err != nil {
+		return nil, err
+	}
+	return &memcache.Item{
+		Key:   k,
+		Value: b,
+		Flags: FLAG_PICKLE,
+	}, nil
+}
+
+// looksPickled reports whether a FLAG_NONE value is a pickle stored without the
+// pickle flag by its binary protocol preamble: \x80\x02 from Python 2 and
+// \x80\x03 to \x80\x05 from Python 3, whose protocol 4+ pickles continue with a
+// FRAME opcode. Protocol 0 and 1 pickles (e.g. from python-memcached) have no
+// preamble and are only decoded with FLAG_PICKLE set: plain strings such as
+// "N." or "I1\n." are valid protocol 0 pickles too.
+func looksPickled(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x80 && b[1] >= 2 && b[1] <= 5
+}
+
+func unpickle(b []byte) (interface{}, error) {
+	return picklecompat.Decode(b)
 }
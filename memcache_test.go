@@ -1,11 +1,18 @@
 package memcache
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
 )
 
 func TestGetSet(t *testing.T) {
@@ -34,6 +41,110 @@ func TestGetSet(t *testing.T) {
 	}
 }
 
+func TestKetamaCollisions(t *testing.T) {
+	servers := []string{"cache1:11211", "cache2:11211", "cache3:11211", "cache4:11211", "cache5:11211", "cache6:11211"}
+	mc := NewClientWithOptions(servers, Options{KetamaHash: ketamacompat.HashCRC32})
+	want := ketamacompat.NewRingWithHash(servers, ketamacompat.HashCRC32).Collisions()
+	if got := mc.KetamaCollisions(); len(got) == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected collisions %v got %v", want, got)
+	}
+	if got := NewClientWithOptions(servers[:1], Options{ProxyMode: ProxyTwemproxy}).KetamaCollisions(); got != nil {
+		t.Errorf("Expected no collisions in proxy mode got %v", got)
+	}
+}
+
+// TestModulaDistribution checks placement against a transliteration of
+// libmemcached's one-at-a-time hash and modula distribution
+func TestModulaDistribution(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	mc := NewClientWithOptions(servers, Options{Distribution: DistributionModula})
+	want := []string{"10.0.0.1:11211", "10.0.0.1:11211", "10.0.0.1:11211", "10.0.0.3:11211", "10.0.0.3:11211", "10.0.0.3:11211", "10.0.0.1:11211", "10.0.0.1:11211"}
+	for n, w := range want {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.selector.PickServer(key); err != nil || addr.String() != w {
+			t.Errorf("%s: expected %s got %v %v", key, w, addr, err)
+		}
+	}
+	var each []string
+	mc.selector.Each(func(addr net.Addr) error {
+		each = append(each, addr.String())
+		return nil
+	})
+	if !reflect.DeepEqual(each, servers) {
+		t.Errorf("Expected servers %v got %v", servers, each)
+	}
+
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{Distribution: DistributionModula})
+	if err := mc.SetString("modula", "m"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("modula"); !ok || s != "m" {
+		t.Errorf("Expected m got %q %v", s, ok)
+	}
+}
+
+func TestKetamaPoints(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	mc := NewClientWithOptions(servers, Options{KetamaPoints: 160})
+	ring := ketamacompat.NewRingWithOptions(servers, ketamacompat.RingOptions{PointsPerServer: 160})
+	defaultRing := ketamacompat.NewRing(servers)
+	moved := 0
+	for n := 0; n < 1000; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.selector.PickServer(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+		if ring.ServerFor(key) != defaultRing.ServerFor(key) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("Expected 160 points per server to place keys differently than 100")
+	}
+}
+
+func TestServerForKey(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	mc := NewClient(servers)
+	ring := ketamacompat.NewRing(servers)
+	for n := 0; n < 100; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.ServerForKey(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+	}
+	if !reflect.DeepEqual(mc.Continuum(), ring.Points()) {
+		t.Error("Expected the continuum of NewRing")
+	}
+
+	proxy := NewClientWithOptions(servers[:1], Options{ProxyMode: ProxyTwemproxy})
+	if addr, err := proxy.ServerForKey("a"); err != nil || addr.String() != servers[0] {
+		t.Errorf("Expected the proxy got %v %v", addr, err)
+	}
+	if proxy.Continuum() != nil {
+		t.Error("Expected no continuum in proxy mode")
+	}
+	if _, err := NewClient(nil).ServerForKey("a"); err != memcache.ErrNoServers {
+		t.Errorf("Expected ErrNoServers got %v", err)
+	}
+}
+
+func TestConnectionOptions(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Timeout: 2 * time.Second, MaxIdleConns: 8, KeepAlive: -1})
+	if mc.Timeout != 2*time.Second || mc.MaxIdleConns != 8 {
+		t.Errorf("Expected timeout 2s and 8 idle connections got %s %d", mc.Timeout, mc.MaxIdleConns)
+	}
+	if err := mc.SetString("conn_opts", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("conn_opts"); !ok || s != "v" {
+		t.Errorf("Expected v got %q %v", s, ok)
+	}
+	if mc := NewClient([]string{"127.0.0.1:11211"}); mc.Timeout != 0 || mc.MaxIdleConns != 0 {
+		t.Errorf("Expected gomemcache defaults got %s %d", mc.Timeout, mc.MaxIdleConns)
+	}
+}
+
 func TestItem_String(t *testing.T) {
 	mc := NewClient([]string{"127.0.0.1:11211"})
 
@@ -80,6 +191,97 @@ func TestItem_String(t *testing.T) {
 	}
 }
 
+func TestItem_StringProtocols(t *testing.T) {
+	// pickle.dumps("Iñtër", protocol) from python 3; protocol 4 and 5 add a FRAME.
+	// Protocol 0 and 1 have no preamble so are only decoded with FLAG_PICKLE.
+	for _, value := range []string{"VI\xf1t\xebr\np0\n.", "X\x07\x00\x00\x00I\xc3\xb1t\xc3\xabrq\x00."} {
+		i := &Item{&memcache.Item{Value: []byte(value), Flags: FLAG_PICKLE}}
+		if s, err := i.String(); err != nil || s != "Iñtër" {
+			t.Errorf("%q: got %q %v", value, s, err)
+		}
+	}
+	for _, value := range []string{
+		"\x80\x03X\x07\x00\x00\x00I\xc3\xb1t\xc3\xabrq\x00.",
+		"\x80\x04\x95\x0b\x00\x00\x00\x00\x00\x00\x00\x8c\x07I\xc3\xb1t\xc3\xabr\x94.",
+		"\x80\x05\x95\x0b\x00\x00\x00\x00\x00\x00\x00\x8c\x07I\xc3\xb1t\xc3\xabr\x94.",
+	} {
+		for _, flags := range []uint32{FLAG_PICKLE, FLAG_NONE} {
+			i := &Item{&memcache.Item{Value: []byte(value), Flags: flags}}
+			if s, err := i.String(); err != nil || s != "Iñtër" {
+				t.Errorf("%q flags %d: got %q %v", value, flags, s, err)
+			}
+			if v, err := Deserialize([]byte(value), flags); err != nil || v != "Iñtër" {
+				t.Errorf("Deserialize %q flags %d: got %q %v", value, flags, v, err)
+			}
+		}
+	}
+	// other values starting with 0x80 are left alone
+	i := &Item{&memcache.Item{Value: []byte("\x80\x01raw"), Flags: FLAG_NONE}}
+	if s, err := i.String(); err != nil || s != "\x80\x01raw" {
+		t.Errorf("Expected raw value, got %q %v", s, err)
+	}
+	// as are strings, e.g. written by pylibmc, shaped like a text pickle
+	for _, value := range []string{"hello.", "N.", "I1\n.", "F1\n.", "(l.", "K\x05.", "VI\xf1t\xebr\np0\n."} {
+		i = &Item{&memcache.Item{Value: []byte(value), Flags: FLAG_NONE}}
+		if s, err := i.String(); err != nil || s != value {
+			t.Errorf("Expected raw value %q, got %q %v", value, s, err)
+		}
+		if i.IsNone() {
+			t.Errorf("Expected %q not to be None", value)
+		}
+	}
+}
+
+func TestUnicodeItemMemo(t *testing.T) {
+	for _, tc := range []struct {
+		memo picklecompat.Memo
+		want string
+	}{
+		{picklecompat.MemoPython2, "\x80\x02X\x02\x00\x00\x00hiq\x01."},
+		{picklecompat.MemoPython3, "\x80\x02X\x02\x00\x00\x00hiq\x00."},
+		{picklecompat.MemoNone, "\x80\x02X\x02\x00\x00\x00hi."},
+	} {
+		i := UnicodeItemMemo("k", "hi", tc.memo)
+		if string(i.Value) != tc.want || i.Flags != FLAG_PICKLE {
+			t.Errorf("memo %d: got %q flags %d, want %q", tc.memo, i.Value, i.Flags, tc.want)
+		}
+		s, err := (&Item{i}).String()
+		if err != nil || s != "hi" {
+			t.Errorf("memo %d: decoded %q %v", tc.memo, s, err)
+		}
+	}
+	if string(UnicodeItemMemo("k", "hi", picklecompat.MemoPython2).Value) != string(UnicodeItem("k", "hi").Value) {
+		t.Errorf("Expected MemoPython2 to match UnicodeItem")
+	}
+}
+
+func TestGetStringUnicodePolicy(t *testing.T) {
+	// python 3 pickles a lone surrogate with surrogatepass: pickle.dumps("\ud800", 2)
+	surrogate := &memcache.Item{Key: "surrogate", Value: []byte("\x80\x02X\x03\x00\x00\x00\xed\xa0\x80q\x00."), Flags: FLAG_PICKLE}
+	invalid := &memcache.Item{Key: "invalid_utf8", Value: []byte("\x80\x02X\x03\x00\x00\x00a\xffbq\x00."), Flags: FLAG_PICKLE}
+	NewClient([]string{"127.0.0.1:11211"}).Set(surrogate)
+	NewClient([]string{"127.0.0.1:11211"}).Set(invalid)
+
+	for _, tc := range []struct {
+		policy    picklecompat.UnicodePolicy
+		surrogate string
+		invalid   string
+	}{
+		{picklecompat.UnicodeRaw, "\xed\xa0\x80", "a\xffb"},
+		{picklecompat.UnicodeStrict, "", ""},
+		{picklecompat.UnicodeReplace, "\ufffd\ufffd\ufffd", "a\ufffdb"},
+		{picklecompat.UnicodeSurrogatePass, "\xed\xa0\x80", ""},
+	} {
+		mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Unicode: tc.policy})
+		for key, want := range map[string]string{"surrogate": tc.surrogate, "invalid_utf8": tc.invalid} {
+			s, ok := mc.GetString(key)
+			if ok != (want != "") || s != want {
+				t.Errorf("policy %d %s: got %q %v, want %q", tc.policy, key, s, ok, want)
+			}
+		}
+	}
+}
+
 func TestItem_Int64(t *testing.T) {
 	mc := NewClient([]string{"127.0.0.1:11211"})
 
@@ -104,3 +306,165 @@ func TestItem_Int64(t *testing.T) {
 	}
 
 }
+
+func TestItem_Float64(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		flags uint32
+		want  float64
+	}{
+		// pickle.dumps(1.5, 2)
+		{"\x80\x02G?\xf8\x00\x00\x00\x00\x00\x00.", FLAG_PICKLE, 1.5},
+		// pickle.dumps(-0.1, 2)
+		{"\x80\x02G\xbf\xb9\x99\x99\x99\x99\x99\x9a.", FLAG_PICKLE, -0.1},
+		// pickle.dumps(3, 2)
+		{"\x80\x02K\x03.", FLAG_PICKLE, 3},
+		{"2.25", FLAG_NONE, 2.25},
+		{"7", FLAG_INTEGER, 7},
+	} {
+		f, err := (&Item{&memcache.Item{Value: []byte(tc.value), Flags: tc.flags}}).Float64()
+		if err != nil || f != tc.want {
+			t.Errorf("Float64(%q) = %v %v, want %v", tc.value, f, err, tc.want)
+		}
+	}
+	if _, err := (&Item{UnicodeItem("", "x")}).Float64(); err != InvalidType {
+		t.Errorf("Expected InvalidType for a pickled string, got %v", err)
+	}
+	if i := Float64Item("f", 1.5); string(i.Value) != "\x80\x02G?\xf8\x00\x00\x00\x00\x00\x00." || i.Flags != FLAG_PICKLE {
+		t.Errorf("unexpected Float64Item %q %d", i.Value, i.Flags)
+	}
+
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(Float64Item("float", -0.1))
+	if f, ok := mc.GetFloat64("float"); !ok || f != -0.1 {
+		t.Errorf("Expected -0.1, got: %v %v", f, ok)
+	}
+	mc.SetFloat64("float", 2.5)
+	if f, ok := mc.GetFloat64("float"); !ok || f != 2.5 {
+		t.Errorf("Expected 2.5, got: %v %v", f, ok)
+	}
+}
+
+func TestNone(t *testing.T) {
+	if i := NoneItem("n"); string(i.Value) != "\x80\x02N." || i.Flags != FLAG_PICKLE || !(&Item{i}).IsNone() {
+		t.Errorf("unexpected NoneItem %q %d", i.Value, i.Flags)
+	}
+	// pickle.dumps(None, 5) as stored by Python 3
+	if !(&Item{&memcache.Item{Value: []byte("\x80\x05N."), Flags: FLAG_PICKLE}}).IsNone() {
+		t.Errorf("Expected protocol 5 None to be None")
+	}
+	if (&Item{StringItem("s", "N")}).IsNone() || (&Item{UnicodeItem("s", "")}).IsNone() {
+		t.Errorf("Expected strings not to be None")
+	}
+	if _, err := (&Item{NoneItem("n")}).String(); err != InvalidType {
+		t.Errorf("Expected InvalidType for None, got %v", err)
+	}
+
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	if err := mc.SetNone("none"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mc.GetString("none"); ok {
+		t.Errorf("Expected GetString to fail on None")
+	}
+	if s, isNone, ok := mc.GetStringOrNone("none"); !ok || !isNone || s != "" {
+		t.Errorf("Expected None, got %q %v %v", s, isNone, ok)
+	}
+	if _, isNone, ok := mc.GetInt64OrNone("none"); !ok || !isNone {
+		t.Errorf("Expected None, got %v %v", isNone, ok)
+	}
+	mc.Set(StringItem("none", "s"))
+	if s, isNone, ok := mc.GetStringOrNone("none"); !ok || isNone || s != "s" {
+		t.Errorf("Expected s, got %q %v %v", s, isNone, ok)
+	}
+	if _, isNone, ok := mc.GetInt64OrNone("none"); ok || isNone {
+		t.Errorf("Expected failure decoding a string as an int, got %v %v", isNone, ok)
+	}
+	mc.SetFloat64("none", 1.5)
+	if f, isNone, ok := mc.GetFloat64OrNone("none"); !ok || isNone || f != 1.5 {
+		t.Errorf("Expected 1.5, got %v %v %v", f, isNone, ok)
+	}
+	mc.Delete("none")
+	if _, isNone, ok := mc.GetStringOrNone("none"); ok || isNone {
+		t.Errorf("Expected a miss, got %v %v", isNone, ok)
+	}
+}
+
+func TestEncodePickle(t *testing.T) {
+	b, err := EncodePickle(map[string]interface{}{"name": "x", "ok": true, "tags": []interface{}{"a", nil}})
+	// pickletools.optimize(pickle.dumps({'name': 'x', 'ok': True, 'tags': ['a', None]}, 2))
+	want := "\x80\x02}(X\x04\x00\x00\x00nameX\x01\x00\x00\x00xX\x02\x00\x00\x00ok\x88X\x04\x00\x00\x00tags](X\x01\x00\x00\x00aNeu."
+	if err != nil || string(b) != want {
+		t.Errorf("EncodePickle = %q %v, want %q", b, err, want)
+	}
+	if _, err := EncodePickle(struct{}{}); err == nil {
+		t.Errorf("Expected an error for an unsupported type")
+	}
+
+	long := strings.Repeat("x", 70000)
+	i := UnicodeItem("long", long)
+	if !strings.HasPrefix(string(i.Value), "\x80\x02Xp\x11\x01\x00x") || !strings.HasSuffix(string(i.Value), "xq\x01.") {
+		t.Errorf("unexpected long UnicodeItem %q...", i.Value[:10])
+	}
+	if s, err := (&Item{i}).String(); err != nil || s != long {
+		t.Errorf("unexpected long string round trip %v", err)
+	}
+
+	i, err = PickleItem("pickled", []interface{}{int64(1), 1.5})
+	if err != nil || i.Flags != FLAG_PICKLE {
+		t.Fatalf("unexpected PickleItem %v %v", i, err)
+	}
+	if l, err := (&Item{i}).List(); err != nil || len(l) != 2 || l[1] != 1.5 {
+		t.Errorf("unexpected round trip %v %v", l, err)
+	}
+}
+
+func TestPickleProtocol(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{PickleProtocol: 4})
+	// pickle.dumps('hi', 4)
+	if i := mc.UnicodeItem("k", "hi"); string(i.Value) != "\x80\x04\x95\x06\x00\x00\x00\x00\x00\x00\x00\x8c\x02hi\x94." {
+		t.Errorf("unexpected protocol 4 UnicodeItem %q", i.Value)
+	}
+	if i := NewClient([]string{LocalAddress}).UnicodeItem("k", "hi"); string(i.Value) != string(UnicodeItem("k", "hi").Value) {
+		t.Errorf("Expected the default protocol to match UnicodeItem, got %q", i.Value)
+	}
+
+	if err := mc.SetFloat64("proto_float", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.IncrFloat("proto_incr", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.PushEvent("proto_events", "e", 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"proto_float", "proto_incr", "proto_events"} {
+		i, err := mc.Get(k)
+		if err != nil || !strings.HasPrefix(string(i.Value), "\x80\x04") {
+			t.Errorf("%s: Expected a protocol 4 pickle, got %q %v", k, i.Value, err)
+		}
+	}
+	if f, ok := NewClient([]string{LocalAddress}).GetFloat64("proto_float"); !ok || f != 1.5 {
+		t.Errorf("Expected a protocol 2 client to read protocol 4, got %v %v", f, ok)
+	}
+
+	if problems := CheckConfig(context.Background(), []string{LocalAddress}, Options{PickleProtocol: 3}); len(problems) != 1 {
+		t.Errorf("Expected a problem for protocol 3, got %v", problems)
+	}
+}
+
+func BenchmarkItemString(b *testing.B) {
+	i := &Item{UnicodeItem("k", "hello world")}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i.String()
+	}
+}
+
+func BenchmarkItemFloat64(b *testing.B) {
+	i := &Item{Float64Item("k", 1.5)}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		i.Float64()
+	}
+}
@@ -0,0 +1,106 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MetadumpEntry describes one item as reported by `lru_crawler metadump`
+type MetadumpEntry struct {
+	Key string
+	// Exp is the absolute expiration as a unix timestamp, or -1 for no expiration
+	Exp int64
+	// LastAccess is the unix timestamp of the last access
+	LastAccess int64
+	CAS        uint64
+	Class      int
+	Size       int
+	// Flags is only reported by memcached 1.6+; HasFlags is false for older servers
+	Flags    uint32
+	HasFlags bool
+}
+
+// Metadump enumerates the items on every server with `lru_crawler metadump all`
+// calling fn for each. Iteration stops at the first error returned by fn.
+func (c *Client) Metadump(ctx context.Context, fn func(server net.Addr, e MetadumpEntry) error) error {
+	addrs, err := c.servers()
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := c.MetadumpServer(ctx, addr, func(e MetadumpEntry) error { return fn(addr, e) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MetadumpServer enumerates the items on a single server calling fn for each
+func (c *Client) MetadumpServer(ctx context.Context, addr net.Addr, fn func(e MetadumpEntry) error) error {
+	return c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		if err := sc.command("lru_crawler metadump all"); err != nil {
+			return err
+		}
+		for {
+			line, err := sc.readLine()
+			if err != nil {
+				return err
+			}
+			switch {
+			case line == "END":
+				return nil
+			case strings.HasPrefix(line, "BUSY"), strings.HasPrefix(line, "ERROR"), strings.HasPrefix(line, "CLIENT_ERROR"), strings.HasPrefix(line, "SERVER_ERROR"):
+				return fmt.Errorf("memcache: metadump on %s: %s", addr, line)
+			}
+			e, err := parseMetadumpLine(line)
+			if err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// parseMetadumpLine parses a line like
+// "key=foo exp=-1 la=1700000000 cas=12 fetch=no cls=1 size=64 flags=2"
+func parseMetadumpLine(line string) (MetadumpEntry, error) {
+	var e MetadumpEntry
+	for _, field := range strings.Fields(line) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		var err error
+		switch name {
+		case "key":
+			e.Key, err = url.QueryUnescape(value)
+		case "exp":
+			e.Exp, err = strconv.ParseInt(value, 10, 64)
+		case "la":
+			e.LastAccess, err = strconv.ParseInt(value, 10, 64)
+		case "cas":
+			e.CAS, err = strconv.ParseUint(value, 10, 64)
+		case "cls":
+			e.Class, err = strconv.Atoi(value)
+		case "size":
+			e.Size, err = strconv.Atoi(value)
+		case "flags":
+			var flags uint64
+			flags, err = strconv.ParseUint(value, 10, 32)
+			e.Flags, e.HasFlags = uint32(flags), true
+		}
+		if err != nil {
+			return e, fmt.Errorf("memcache: malformed metadump line %q: %w", line, err)
+		}
+	}
+	if e.Key == "" {
+		return e, fmt.Errorf("memcache: malformed metadump line %q", line)
+	}
+	return e, nil
+}
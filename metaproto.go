@@ -0,0 +1,313 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetaGetOptions are the flags of a MetaGet
+type MetaGetOptions struct {
+	// Vivify, when positive, has a miss create an empty placeholder expiring in
+	// Vivify seconds: the one client reading it first gets Won and should fill
+	// it, others get the placeholder with AlreadyWon (the N flag)
+	Vivify int32
+	// Recache, when positive, gives Won to the one client reading a value with
+	// less than Recache seconds left, to refresh it before it expires while
+	// others keep reading it with AlreadyWon (the R flag)
+	Recache int32
+	// Touch, when positive, updates the expiration to Touch seconds (the T
+	// flag)
+	Touch int32
+}
+
+// flags returns the meta get flags requesting the value and metadata
+func (o MetaGetOptions) flags() string {
+	flags := "v f t c h l"
+	if o.Vivify > 0 {
+		flags += fmt.Sprintf(" N%d", o.Vivify)
+	}
+	if o.Recache > 0 {
+		flags += fmt.Sprintf(" R%d", o.Recache)
+	}
+	if o.Touch > 0 {
+		flags += fmt.Sprintf(" T%d", o.Touch)
+	}
+	return flags
+}
+
+// MetaItem is an item read by MetaGet with its metadata. The typed methods of
+// Item decode it as they do items read by Get.
+type MetaItem struct {
+	*Item
+	// TTL is the remaining seconds before the item expires, or -1 if it doesn't
+	TTL int64
+	// HitBefore is whether the item was read before this read
+	HitBefore bool
+	// LastAccess is the time since the item was last read or written, in
+	// whole seconds
+	LastAccess time.Duration
+	// Won is whether this client should recache the item (see
+	// MetaGetOptions). For a vivified placeholder the value is empty.
+	Won bool
+	// AlreadyWon is whether another client was given Won
+	AlreadyWon bool
+	// Stale is whether the item was invalidated and is served stale
+	Stale bool
+}
+
+// metaConns pools the connections meta and quiet commands are sent on, by
+// server
+type metaConns struct {
+	mu   sync.Mutex
+	idle map[string][]*serverConn
+}
+
+// withMetaConn runs fn on an idle connection to addr, or a new one, keeping the
+// connection for reuse unless fn fails with an error leaving the stream out of
+// sync
+func (c *Client) withMetaConn(addr net.Addr, fn func(sc *serverConn) error) error {
+	key := addr.String()
+	c.meta.mu.Lock()
+	var sc *serverConn
+	if idle := c.meta.idle[key]; len(idle) > 0 {
+		sc = idle[len(idle)-1]
+		c.meta.idle[key] = idle[:len(idle)-1]
+	}
+	c.meta.mu.Unlock()
+	if sc == nil {
+		var err error
+		if sc, err = c.dialServerConn(context.Background(), addr); err != nil {
+			return err
+		}
+	}
+	err := fn(sc)
+	if err != nil && !isProtocolError(err) {
+		sc.close()
+		return err
+	}
+	max := c.MaxIdleConns
+	if max <= 0 {
+		max = memcache.DefaultMaxIdleConns
+	}
+	c.meta.mu.Lock()
+	defer c.meta.mu.Unlock()
+	if c.meta.idle == nil {
+		c.meta.idle = make(map[string][]*serverConn)
+	}
+	if len(c.meta.idle[key]) >= max {
+		sc.close()
+		return err
+	}
+	c.meta.idle[key] = append(c.meta.idle[key], sc)
+	return err
+}
+
+// MetaGet reads key with a meta get (memcached 1.6+), returning its value with
+// the flags, TTL, whether it was hit before and when it was last accessed in
+// one round trip, and applying the stampede protection of o
+func (c *Client) MetaGet(key string, o MetaGetOptions) (item *MetaItem, err error) {
+	full := c.fullKey(key)
+	err = c.do(OpGet, full, func() (err error) {
+		item, err = c.metaGet(full, o)
+		return
+	})
+	if item != nil {
+		item.Item.Item = unprefixed(item.Item.Item, key)
+	}
+	return item, err
+}
+
+// metaGet sends a meta get for key
+func (c *Client) metaGet(key string, o MetaGetOptions) (*MetaItem, error) {
+	if !legalKey(key) {
+		return nil, memcache.ErrMalformedKey
+	}
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return nil, err
+	}
+	var item *MetaItem
+	err = c.withMetaConn(addr, func(sc *serverConn) error {
+		if err := sc.command("mg %s %s", key, o.flags()); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		item, err = readMetaValue(sc, key, line)
+		return err
+	})
+	return item, err
+}
+
+// readMetaValue reads the value of a meta get response line like
+// "VA 5 f0 t-1 c3 h0 l0"
+func readMetaValue(sc *serverConn, key, line string) (*MetaItem, error) {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) > 0 && fields[0] == "EN":
+		return nil, memcache.ErrCacheMiss
+	case len(fields) < 2 || fields[0] != "VA":
+		return nil, metaError(sc, "mg", line)
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("memcache: unexpected mg response from %s: %q", sc.addr, line)
+	}
+	i := &MetaItem{Item: &Item{&memcache.Item{Key: key}}, TTL: -1}
+	for _, f := range fields[2:] {
+		var n int64
+		if len(f) > 1 {
+			if n, err = strconv.ParseInt(f[1:], 10, 64); err != nil {
+				return nil, fmt.Errorf("memcache: unexpected mg response from %s: %q", sc.addr, line)
+			}
+		}
+		switch f[0] {
+		case 'f':
+			i.Flags = uint32(n)
+		case 't':
+			i.TTL = n
+		case 'c':
+			i.CasID = uint64(n)
+		case 'h':
+			i.HitBefore = n == 1
+		case 'l':
+			i.LastAccess = time.Duration(n) * time.Second
+		case 'W':
+			i.Won = true
+		case 'Z':
+			i.AlreadyWon = true
+		case 'X':
+			i.Stale = true
+		}
+	}
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(sc.rw, value); err != nil {
+		return nil, err
+	}
+	if string(value[size:]) != "\r\n" {
+		return nil, fmt.Errorf("memcache: corrupt mg response from %s", sc.addr)
+	}
+	i.Value = value[:size]
+	return i, nil
+}
+
+// metaError returns the error for an unexpected response line to the meta
+// command verb. Servers before 1.6 answer ERROR, an unknown command.
+func metaError(sc *serverConn, verb, line string) error {
+	if line == "ERROR" {
+		return fmt.Errorf("%w: %s on %s", ErrFeatureUnsupported, verb, sc.addr)
+	}
+	return fmt.Errorf("memcache: unexpected %s response from %s: %q", verb, sc.addr, line)
+}
+
+// metaSet stores item with a meta set
+func (c *Client) metaSet(item *memcache.Item) error {
+	if !legalKey(item.Key) {
+		return memcache.ErrMalformedKey
+	}
+	addr, err := c.selector.PickServer(item.Key)
+	if err != nil {
+		return err
+	}
+	return c.withMetaConn(addr, func(sc *serverConn) error {
+		if err := sc.commandValue(item.Value, "ms %s %d F%d T%d", item.Key, len(item.Value), item.Flags, item.Expiration); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "HD":
+			return nil
+		case "NS":
+			return memcache.ErrNotStored
+		}
+		return metaError(sc, "ms", line)
+	})
+}
+
+// metaDelete deletes key with a meta delete
+func (c *Client) metaDelete(key string) error {
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return err
+	}
+	return c.withMetaConn(addr, func(sc *serverConn) error {
+		if err := sc.command("md %s", key); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "HD":
+			return nil
+		case "NF":
+			return memcache.ErrCacheMiss
+		}
+		return metaError(sc, "md", line)
+	})
+}
+
+// backendGet reads key with a classic get, or a meta get under
+// Options.MetaProtocol, retrying under Options.Retry
+func (c *Client) backendGet(ctx context.Context, key string) (*memcache.Item, error) {
+	return retry(ctx, c, OpGet, func() (*memcache.Item, error) {
+		if !c.opts.MetaProtocol {
+			return c.Client.Get(key)
+		}
+		i, err := c.metaGet(key, MetaGetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return i.Item.Item, nil
+	})
+}
+
+// backendSet stores item with a classic set, or a meta set under
+// Options.MetaProtocol, retrying under Options.Retry, and writes it to the
+// replicas of Options.Replicas
+func (c *Client) backendSet(item *memcache.Item) error {
+	return c.backendSetCtx(context.Background(), item)
+}
+
+// backendSetCtx is backendSet ending retries early when ctx is done
+func (c *Client) backendSetCtx(ctx context.Context, item *memcache.Item) error {
+	_, err := retry(ctx, c, OpSet, func() (struct{}, error) {
+		if !c.opts.MetaProtocol {
+			return struct{}{}, c.Client.Set(item)
+		}
+		return struct{}{}, c.metaSet(item)
+	})
+	c.replicaSet(item)
+	return err
+}
+
+// backendDelete deletes key with a classic delete, or a meta delete under
+// Options.MetaProtocol, retrying under Options.Retry, and from the replicas of
+// Options.Replicas
+func (c *Client) backendDelete(ctx context.Context, key string) error {
+	_, err := retry(ctx, c, OpDelete, func() (struct{}, error) {
+		if !c.opts.MetaProtocol {
+			return struct{}{}, c.Client.Delete(key)
+		}
+		return struct{}{}, c.metaDelete(key)
+	})
+	c.replicaDelete(key)
+	return err
+}
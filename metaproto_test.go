@@ -0,0 +1,75 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestMetaProtocol(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{MetaProtocol: true})
+	if err := mc.Set(UnicodeItem("meta_unicode", "café")); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mc.GetString("meta_unicode"); !ok || s != "café" {
+		t.Errorf("Expected café got %q %v", s, ok)
+	}
+	// written with meta commands, read with classic ones
+	if n, ok := NewClient([]string{LocalAddress}).GetString("meta_unicode"); !ok || n != "café" {
+		t.Errorf("Expected café with a classic get got %q %v", n, ok)
+	}
+	if err := mc.Delete("meta_unicode"); err != nil {
+		t.Error(err)
+	}
+	if err := mc.Delete("meta_unicode"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if _, err := mc.Get("meta_unicode"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if _, err := mc.Get("bad key"); err != memcache.ErrMalformedKey {
+		t.Errorf("Expected ErrMalformedKey got %v", err)
+	}
+}
+
+func TestMetaGet(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	i := Int64Item("meta_int", 5)
+	i.Expiration = 100
+	mc.Set(i)
+
+	mi, err := mc.MetaGet("meta_int", MetaGetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := mi.Int64(); err != nil || n != 5 {
+		t.Errorf("Expected 5 got %d %v", n, err)
+	}
+	if mi.TTL < 99 || mi.TTL > 100 || mi.HitBefore || mi.Won || mi.CasID == 0 {
+		t.Errorf("Unexpected metadata %+v", mi)
+	}
+	if mi, err = mc.MetaGet("meta_int", MetaGetOptions{}); err != nil || !mi.HitBefore {
+		t.Errorf("Expected a hit before got %+v %v", mi, err)
+	}
+
+	// the first reader of a value expiring within Recache refreshes it
+	if mi, err = mc.MetaGet("meta_int", MetaGetOptions{Recache: 200}); err != nil || !mi.Won {
+		t.Errorf("Expected to win the recache got %+v %v", mi, err)
+	}
+	if mi, err = mc.MetaGet("meta_int", MetaGetOptions{Recache: 200}); err != nil || mi.Won || !mi.AlreadyWon {
+		t.Errorf("Expected the recache already won got %+v %v", mi, err)
+	}
+
+	// a miss vivifies a placeholder for the first reader to fill
+	mc.Delete("meta_missing")
+	if _, err := mc.MetaGet("meta_missing", MetaGetOptions{}); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if mi, err = mc.MetaGet("meta_missing", MetaGetOptions{Vivify: 30}); err != nil || !mi.Won || len(mi.Value) != 0 {
+		t.Errorf("Expected to win the vivified placeholder got %+v %v", mi, err)
+	}
+	if mi, err = mc.MetaGet("meta_missing", MetaGetOptions{Vivify: 30}); err != nil || mi.Won || !mi.AlreadyWon {
+		t.Errorf("Expected the placeholder already won got %+v %v", mi, err)
+	}
+	mc.Delete("meta_missing")
+}
@@ -0,0 +1,19 @@
+package memcache
+
+import (
+	"time"
+)
+
+// Metrics receives counters and timings emitted by the client. Implementations
+// must be safe for concurrent use.
+type Metrics interface {
+	// Count adds n to the counter name
+	Count(name string, n int64, tags map[string]string)
+	// Timing records a duration sample for name
+	Timing(name string, d time.Duration, tags map[string]string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Count(string, int64, map[string]string)          {}
+func (nopMetrics) Timing(string, time.Duration, map[string]string) {}
@@ -0,0 +1,86 @@
+package memcache
+
+import (
+	"context"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricMigrationFallbacks counts values only readable under a MigrationSerde's
+// Fallback scheme, tagged by the fallback scheme name and whether the entry was
+// "rewritten". Once it stays at zero the migration is complete.
+const MetricMigrationFallbacks = "memcache.migration.fallbacks"
+
+// Decode decodes i, stored under scheme s, into the same Go values Deserialize
+// returns for pylibmc values
+func (s Scheme) Decode(i *memcache.Item) (interface{}, error) {
+	out, err := (&Item{i}).Reencode(s, Scheme{Flags: PylibmcFlags})
+	if err != nil {
+		return nil, err
+	}
+	return Deserialize(out.Value, out.Flags)
+}
+
+// MigrationSerde reads values while a cluster is shared by clients writing two
+// schemes, e.g. during a python-memcached to pylibmc migration. Values are
+// decoded under Primary and, if that fails, under Fallback.
+//
+// Flags are ambiguous between some schemes (1<<4 is a bool for pylibmc but text
+// for python-memcached) so a value is only decoded under Fallback when it isn't
+// valid under Primary; the scheme being migrated to should be Primary.
+type MigrationSerde struct {
+	Primary  Scheme
+	Fallback Scheme
+	// Rewrite rewrites entries only readable under Fallback under Primary when
+	// they are read through Client.GetMigrating
+	Rewrite bool
+}
+
+// Decode decodes i under Primary then Fallback, reporting whether Fallback was
+// needed. When neither applies the Primary error is returned.
+func (m MigrationSerde) Decode(i *memcache.Item) (v interface{}, fallback bool, err error) {
+	v, err = m.Primary.Decode(i)
+	if err == nil {
+		return v, false, nil
+	}
+	if fv, ferr := m.Fallback.Decode(i); ferr == nil {
+		return fv, true, nil
+	}
+	return nil, false, err
+}
+
+// GetMigrating gets key decoding it with m. Entries decoded under m.Fallback are
+// rewritten under m.Primary when m.Rewrite is set, preserving their remaining
+// TTL, with CompareAndSwap so a concurrent update isn't clobbered. Rewriting is
+// best effort and doesn't fail the read. Entries are read from the server
+// owning key for their CasID, not an in-process copy.
+func (c *Client) GetMigrating(ctx context.Context, key string, m MigrationSerde) (interface{}, error) {
+	i, err := c.casGet(key)
+	if err != nil {
+		return nil, err
+	}
+	v, fallback, err := m.Decode(i)
+	if err != nil || !fallback {
+		return v, err
+	}
+	rewritten := "false"
+	if m.Rewrite && c.rewriteMigrated(ctx, i, m) == nil {
+		rewritten = "true"
+	}
+	c.opts.Metrics.Count(MetricMigrationFallbacks, 1, map[string]string{"scheme": m.Fallback.Flags.Name, "rewritten": rewritten})
+	return v, nil
+}
+
+// rewriteMigrated stores i, read under m.Fallback, under m.Primary
+func (c *Client) rewriteMigrated(ctx context.Context, i *memcache.Item, m MigrationSerde) error {
+	out, err := (&Item{i}).Reencode(m.Fallback, m.Primary)
+	if err != nil {
+		return err
+	}
+	ttl, err := c.remainingTTL(ctx, i.Key)
+	if err != nil {
+		return err
+	}
+	out.Expiration = c.expirationFor(ttl)
+	return c.CompareAndSwap(out)
+}
@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestMigrationSerdeDecode(t *testing.T) {
+	m := MigrationSerde{Primary: Scheme{Flags: PylibmcFlags}, Fallback: Scheme{Flags: PythonMemcachedFlags}}
+	for _, tc := range []struct {
+		value    string
+		flags    uint32
+		want     interface{}
+		fallback bool
+	}{
+		{"abc", FLAG_NONE, "abc", false},
+		{"1", FLAG_BOOL, true, false},
+		{"42", FLAG_INTEGER, int64(42), false},
+		// python-memcached text
+		{"abc", 1 << 4, "abc", true},
+	} {
+		v, fallback, err := m.Decode(&memcache.Item{Key: "k", Value: []byte(tc.value), Flags: tc.flags})
+		if err != nil || v != tc.want || fallback != tc.fallback {
+			t.Errorf("Decode(%q, %d) = %v %v %v", tc.value, tc.flags, v, fallback, err)
+		}
+	}
+	if _, _, err := m.Decode(&memcache.Item{Key: "k", Value: []byte("x"), Flags: 1 << 9}); err == nil {
+		t.Errorf("Expected error for flags unknown to both schemes")
+	}
+}
+
+func TestClientGetMigrating(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Metrics: metrics})
+	ctx := context.Background()
+	m := MigrationSerde{Primary: Scheme{Flags: PylibmcFlags}, Fallback: Scheme{Flags: PythonMemcachedFlags}}
+	mc.Set(&memcache.Item{Key: "migrating", Value: []byte("abc"), Flags: 1 << 4, Expiration: 300})
+
+	if v, err := mc.GetMigrating(ctx, "migrating", m); err != nil || v != "abc" {
+		t.Fatalf("Expected abc, got %v %v", v, err)
+	}
+	if i, _ := mc.Get("migrating"); i.Flags != 1<<4 {
+		t.Errorf("Expected the entry to be left alone without Rewrite, got flags %d", i.Flags)
+	}
+
+	m.Rewrite = true
+	if v, err := mc.GetMigrating(ctx, "migrating", m); err != nil || v != "abc" {
+		t.Fatalf("Expected abc, got %v %v", v, err)
+	}
+	if i, _ := mc.Get("migrating"); i.Flags != FLAG_NONE || string(i.Value) != "abc" {
+		t.Errorf("Expected the entry rewritten under pylibmc flags, got %v", i)
+	}
+	if ttl, err := mc.remainingTTL(ctx, "migrating"); err != nil || ttl < 290 || ttl > 300 {
+		t.Errorf("Expected TTL to be preserved, got %d %v", ttl, err)
+	}
+	if v, err := mc.GetMigrating(ctx, "migrating", m); err != nil || v != "abc" {
+		t.Errorf("Expected abc, got %v %v", v, err)
+	}
+	if n := metrics.get(MetricMigrationFallbacks); n != 2 {
+		t.Errorf("Expected 2 fallbacks, got %d", n)
+	}
+	if _, err := mc.GetMigrating(ctx, "migrating_missing", m); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestClientGetMigratingLocalCache(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{LocalCacheSize: 10})
+	ctx := context.Background()
+	m := MigrationSerde{Primary: Scheme{Flags: PylibmcFlags}, Fallback: Scheme{Flags: PythonMemcachedFlags}, Rewrite: true}
+	mc.Set(&memcache.Item{Key: "migrating_local", Value: []byte("abc"), Flags: 1 << 4, Expiration: 300})
+	mc.Get("migrating_local")
+
+	// the local copy has no CasID, so the rewrite reads the server's
+	if v, err := mc.GetMigrating(ctx, "migrating_local", m); err != nil || v != "abc" {
+		t.Fatalf("Expected abc, got %v %v", v, err)
+	}
+	if i, err := NewClient([]string{LocalAddress}).Get("migrating_local"); err != nil || i.Flags != FLAG_NONE {
+		t.Errorf("Expected the entry rewritten under pylibmc flags, got %v %v", i, err)
+	}
+}
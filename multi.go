@@ -0,0 +1,114 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricDuplicateKeys counts repeated keys dropped from GetMulti requests
+const MetricDuplicateKeys = "memcache.get_multi.duplicate_keys"
+
+// dedupKeys returns keys with repeats removed, preserving first occurrence order.
+// Since results are keyed by key every caller of a duplicate still sees its item.
+func (c *Client) dedupKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	out := keys[:0:0]
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, k)
+	}
+	if dups := len(keys) - len(out); dups > 0 {
+		c.opts.Metrics.Count(MetricDuplicateKeys, int64(dups), nil)
+		return out
+	}
+	return keys
+}
+
+// GetMultiAliased is a batch Get where aliases maps each requested key to the
+// canonical key actually stored in memcache. The returned map is keyed by the
+// requested keys. Requested keys sharing a canonical key share the same *memcache.Item
+// (whose Key is the canonical key).
+func (c *Client) GetMultiAliased(aliases map[string]string) (map[string]*memcache.Item, error) {
+	keys := make([]string, 0, len(aliases))
+	seen := make(map[string]bool, len(aliases))
+	for _, canonical := range aliases {
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		keys = append(keys, canonical)
+	}
+	items, err := c.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*memcache.Item, len(items))
+	for requested, canonical := range aliases {
+		if i, ok := items[canonical]; ok {
+			m[requested] = i
+		}
+	}
+	return m, nil
+}
+
+// GetMultiDecoded is GetMulti returning the values decoded as GetAny decodes
+// them. Misses are left out, as are values that fail to decode, whose errors
+// ("key: error", wrapping InvalidType for unsupported flags) are joined into
+// the returned error along with any error of the GetMulti. Of the opts only
+// WithTimeout applies: servers that haven't answered in time are left out,
+// reported by a *DeadlineError as GetMultiCtx does.
+func (c *Client) GetMultiDecoded(keys []string, opts ...CallOption) (map[string]interface{}, error) {
+	return getMultiDecoded(c, keys, opts, func(i *Item) (interface{}, error) {
+		v, err := c.opts.Pipeline.deserialize(i.Value, i.Flags)
+		if err != nil {
+			return nil, err
+		}
+		return goValue(v), nil
+	})
+}
+
+// GetMultiString is GetMultiDecoded for string values, read as GetString reads
+// them
+func (c *Client) GetMultiString(keys []string, opts ...CallOption) (map[string]string, error) {
+	return getMultiDecoded(c, keys, opts, func(i *Item) (string, error) { return i.StringPolicy(c.opts.Unicode) })
+}
+
+// GetMultiInt64 is GetMultiDecoded for int values, read as GetInt64 reads them
+func (c *Client) GetMultiInt64(keys []string, opts ...CallOption) (map[string]int64, error) {
+	return getMultiDecoded(c, keys, opts, (*Item).Int64)
+}
+
+// getMultiDecoded gets keys with GetMulti, or GetMultiCtx under WithTimeout,
+// decoding each value with decode
+func getMultiDecoded[T any](c *Client, keys []string, opts []CallOption, decode func(*Item) (T, error)) (map[string]T, error) {
+	var items map[string]*memcache.Item
+	var err error
+	if o := newCallOptions(opts); o.timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+		items, err = c.GetMultiCtx(ctx, keys)
+		cancel()
+	} else {
+		items, err = c.GetMulti(keys)
+	}
+	errs := []error{err}
+	values := make(map[string]T, len(items))
+	for k, i := range items {
+		i, err := c.decodeRead(i)
+		var v T
+		if err == nil {
+			v, err = decode(&Item{i})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", k, err))
+			continue
+		}
+		values[k] = v
+	}
+	return values, errors.Join(errs...)
+}
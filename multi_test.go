@@ -0,0 +1,220 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMultiAliased(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+
+	mc.Set(StringItem("canonical_a", "a"))
+	mc.Set(StringItem("canonical_b", "b"))
+	mc.Delete("canonical_missing")
+
+	items, err := mc.GetMultiAliased(map[string]string{
+		"User A":  "canonical_a",
+		"user a":  "canonical_a",
+		"User B":  "canonical_b",
+		"Missing": "canonical_missing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Errorf("Expected 3 items, got: %d", len(items))
+	}
+	for requested, expected := range map[string]string{"User A": "a", "user a": "a", "User B": "b"} {
+		i, ok := items[requested]
+		if !ok {
+			t.Errorf("missing %q", requested)
+			continue
+		}
+		if s, err := (&Item{i}).String(); err != nil || s != expected {
+			t.Errorf("Expected %q for %q, got: %q", expected, requested, s)
+		}
+	}
+}
+
+func TestGetMultiDuplicateKeys(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Metrics: metrics})
+
+	mc.Set(StringItem("dup_a", "a"))
+	mc.Set(StringItem("dup_b", "b"))
+
+	items, err := mc.GetMulti([]string{"dup_a", "dup_b", "dup_a", "dup_a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || string(items["dup_a"].Value) != "a" || string(items["dup_b"].Value) != "b" {
+		t.Errorf("unexpected items: %v", items)
+	}
+	if n := metrics.get(MetricDuplicateKeys); n != 2 {
+		t.Errorf("Expected 2 duplicate keys counted, got: %d", n)
+	}
+}
+
+func TestGetMultiChunks(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		GetMultiChunkSize:      2,
+		MaxGetMultiConcurrency: 1,
+		ServerStatsWindow:      time.Minute,
+	})
+	keys := []string{"chunk_a", "chunk_b", "chunk_c", "chunk_d", "chunk_missing"}
+	for _, k := range keys[:4] {
+		mc.Set(StringItem(k, k))
+	}
+	before := mc.ServerStats()[0].Requests
+
+	items, err := mc.GetMulti(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 {
+		t.Errorf("Expected 4 items, got: %d", len(items))
+	}
+	for _, k := range keys[:4] {
+		if i, ok := items[k]; !ok || string(i.Value) != k {
+			t.Errorf("unexpected item for %s: %v", k, i)
+		}
+	}
+	if n := mc.ServerStats()[0].Requests - before; n != 3 {
+		t.Errorf("Expected 5 keys in 3 requests, got: %d", n)
+	}
+}
+
+// slowServer answers every get with a miss after delay
+func slowServer(t *testing.T, delay time.Duration) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+					time.Sleep(delay)
+					c.Write([]byte("END\r\n"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestGetMultiFanOut(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	servers := []string{slowServer(t, delay), slowServer(t, delay), slowServer(t, delay)}
+	keys := make([]string, 50)
+	for n := range keys {
+		keys[n] = fmt.Sprintf("fanout_%d", n)
+	}
+
+	mc := NewClient(servers)
+	mc.Timeout = time.Second
+	start := time.Now()
+	if _, err := mc.GetMulti(keys); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= 2*delay {
+		t.Errorf("Expected the servers to be queried concurrently, took %s", d)
+	}
+
+	mc = NewClientWithOptions(servers, Options{MaxGetMultiConcurrency: 1})
+	mc.Timeout = time.Second
+	start = time.Now()
+	if _, err := mc.GetMulti(keys); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 3*delay {
+		t.Errorf("Expected one server to be queried at a time, took %s", d)
+	}
+}
+
+func TestGetMultiTimeout(t *testing.T) {
+	mc := NewClient([]string{slowServer(t, 200*time.Millisecond)})
+	mc.Timeout = time.Second
+	keys := []string{"timeout_a", "timeout_b"}
+
+	start := time.Now()
+	_, err := mc.GetMultiString(keys, WithTimeout(20*time.Millisecond))
+	var de *DeadlineError
+	if !errors.As(err, &de) {
+		t.Errorf("Expected a DeadlineError, got: %v", err)
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("Expected the call to stop after 20ms, took %s", d)
+	}
+
+	// without WithTimeout the slow server is waited on
+	if m, err := mc.GetMultiString(keys); err != nil || len(m) != 0 {
+		t.Errorf("Expected misses, got: %v %v", m, err)
+	}
+}
+
+func TestChunkKeys(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+	for _, tc := range []struct {
+		size int
+		want string
+	}{
+		{0, "[[a b c d e]]"},
+		{5, "[[a b c d e]]"},
+		{2, "[[a b] [c d] [e]]"},
+		{1, "[[a] [b] [c] [d] [e]]"},
+	} {
+		if got := fmt.Sprint(chunkKeys(keys, tc.size)); got != tc.want {
+			t.Errorf("chunkKeys(%d) = %s, want %s", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestGetMultiDecoded(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(StringItem("md_str", "a"))
+	mc.Set(UnicodeItem("md_unicode", "b"))
+	mc.Set(Int64Item("md_int", 3))
+	list, _ := PickleItem("md_list", []interface{}{int64(1), "x"})
+	mc.Set(list)
+	mc.Delete("md_missing")
+	keys := []string{"md_str", "md_unicode", "md_int", "md_list", "md_missing"}
+
+	values, err := mc.GetMultiDecoded(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"md_str": "a", "md_unicode": "b", "md_int": int64(3), "md_list": []interface{}{int64(1), "x"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v got %v", want, values)
+	}
+
+	strs, err := mc.GetMultiString(keys)
+	if want := map[string]string{"md_str": "a", "md_unicode": "b"}; !reflect.DeepEqual(strs, want) {
+		t.Errorf("Expected %v got %v", want, strs)
+	}
+	if !errors.Is(err, InvalidType) || !strings.Contains(err.Error(), "md_int: ") {
+		t.Errorf("Expected InvalidType for md_int got %v", err)
+	}
+
+	ints, err := mc.GetMultiInt64(keys)
+	if want := map[string]int64{"md_int": 3}; !reflect.DeepEqual(ints, want) || err == nil {
+		t.Errorf("Expected %v with errors got %v %v", want, ints, err)
+	}
+}
@@ -0,0 +1,258 @@
+package memcache
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// MetricClusterFallbacks counts keys a MultiClusterClient read from its
+// secondary cluster, tagged by why ("miss" or "error" on the primary) and
+// result: "hit", "miss" or "error"
+const MetricClusterFallbacks = "memcache.multicluster.fallbacks"
+
+// MetricClusterMirrorErrors counts writes mirrored to a MultiClusterClient's
+// secondary cluster that failed, tagged by op
+const MetricClusterMirrorErrors = "memcache.multicluster.mirror_errors"
+
+// MultiClusterOptions configures a MultiClusterClient
+type MultiClusterOptions struct {
+	// MirrorWrites also applies writes, deletes, touches, increments and
+	// decrements to the secondary once applied to the primary, keeping a warm
+	// standby or the old cluster of a migration current. Mirroring is best
+	// effort: failures are counted under MetricClusterMirrorErrors and the
+	// primary's result is returned. Add, Replace and CompareAndSwap are
+	// mirrored as a Set once they succeed on the primary.
+	MirrorWrites bool
+	// FallbackOnMiss reads keys missing on the primary from the secondary, as
+	// while a new primary fills during a migration. Keys are always read from
+	// the secondary when the primary fails.
+	FallbackOnMiss bool
+	// BackfillTTL enables adding values read from the secondary to the
+	// primary, expiring after BackfillTTL. Zero disables backfill.
+	BackfillTTL time.Duration
+	// Metrics receives the MultiClusterClient's counters. nil discards them.
+	Metrics Metrics
+}
+
+// MultiClusterClient is a Cacher over two clusters, e.g. the clusters of two
+// datacenters during a migration or a primary and its warm standby. Reads go
+// to the primary and fall back to the secondary; writes go to the primary and,
+// under MirrorWrites, to the secondary too.
+type MultiClusterClient struct {
+	primary   Cacher
+	secondary Cacher
+	opts      MultiClusterOptions
+}
+
+var _ Cacher = (*MultiClusterClient)(nil)
+
+// NewMultiClusterClient returns a MultiClusterClient reading from primary,
+// falling back to secondary as opts configures
+func NewMultiClusterClient(primary, secondary Cacher, opts MultiClusterOptions) *MultiClusterClient {
+	if opts.Metrics == nil {
+		opts.Metrics = nopMetrics{}
+	}
+	return &MultiClusterClient{primary: primary, secondary: secondary, opts: opts}
+}
+
+// Primary returns the primary cluster
+func (m *MultiClusterClient) Primary() Cacher { return m.primary }
+
+// Secondary returns the secondary cluster
+func (m *MultiClusterClient) Secondary() Cacher { return m.secondary }
+
+// fallback reports whether a primary read failing with err is retried on the
+// secondary, and why
+func (m *MultiClusterClient) fallback(err error) (string, bool) {
+	if _, ok := err.(*KeyError); ok {
+		return "", false
+	}
+	switch err {
+	case nil, memcache.ErrMalformedKey:
+		return "", false
+	case memcache.ErrCacheMiss:
+		return "miss", m.opts.FallbackOnMiss
+	}
+	return "error", true
+}
+
+// Get reads key from the primary, or the secondary when the primary misses
+// (under FallbackOnMiss) or fails. The primary's miss or error is returned
+// when the secondary fails too.
+func (m *MultiClusterClient) Get(key string) (*memcache.Item, error) {
+	i, err := m.primary.Get(key)
+	why, ok := m.fallback(err)
+	if !ok {
+		return i, err
+	}
+	si, serr := m.secondary.Get(key)
+	switch serr {
+	case nil:
+		m.opts.Metrics.Count(MetricClusterFallbacks, 1, map[string]string{"why": why, "result": "hit"})
+		m.backfill(si)
+		return si, nil
+	case memcache.ErrCacheMiss:
+		m.opts.Metrics.Count(MetricClusterFallbacks, 1, map[string]string{"why": why, "result": "miss"})
+		return nil, memcache.ErrCacheMiss
+	}
+	m.opts.Metrics.Count(MetricClusterFallbacks, 1, map[string]string{"why": why, "result": "error"})
+	return i, err
+}
+
+// GetMulti reads keys from the primary, and those it misses (under
+// FallbackOnMiss), or all of them when it fails, from the secondary
+func (m *MultiClusterClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	items, err := m.primary.GetMulti(keys)
+	why, ok := m.fallback(err)
+	if err == nil {
+		why, ok = "miss", m.opts.FallbackOnMiss
+	}
+	if !ok {
+		return items, err
+	}
+	var missing []string
+	for _, k := range keys {
+		if _, hit := items[k]; !hit {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return items, err
+	}
+	sitems, serr := m.secondary.GetMulti(missing)
+	if serr != nil {
+		m.opts.Metrics.Count(MetricClusterFallbacks, int64(len(missing)), map[string]string{"why": why, "result": "error"})
+		return items, err
+	}
+	if items == nil {
+		items = make(map[string]*memcache.Item, len(keys))
+	}
+	for k, i := range sitems {
+		items[k] = i
+		m.backfill(i)
+	}
+	if len(sitems) > 0 {
+		m.opts.Metrics.Count(MetricClusterFallbacks, int64(len(sitems)), map[string]string{"why": why, "result": "hit"})
+	}
+	if len(sitems) < len(missing) {
+		m.opts.Metrics.Count(MetricClusterFallbacks, int64(len(missing)-len(sitems)), map[string]string{"why": why, "result": "miss"})
+	}
+	return items, nil
+}
+
+// backfill adds i, read from the secondary, to the primary under BackfillTTL.
+// Add doesn't clobber a value written since the primary was read.
+func (m *MultiClusterClient) backfill(i *memcache.Item) {
+	if m.opts.BackfillTTL <= 0 {
+		return
+	}
+	m.primary.Add(&memcache.Item{Key: i.Key, Value: i.Value, Flags: i.Flags, Expiration: ttlSeconds(m.opts.BackfillTTL)})
+}
+
+// mirror runs fn against the secondary under MirrorWrites, counting failures.
+// Misses and unstored conditional writes aren't failures: the secondary may
+// not hold every key the primary does.
+func (m *MultiClusterClient) mirror(op string, fn func(Cacher) error) {
+	if !m.opts.MirrorWrites {
+		return
+	}
+	switch err := fn(m.secondary); err {
+	case nil, memcache.ErrCacheMiss, memcache.ErrNotStored:
+	default:
+		m.opts.Metrics.Count(MetricClusterMirrorErrors, 1, map[string]string{"op": op})
+	}
+}
+
+func (m *MultiClusterClient) Set(item *memcache.Item) error {
+	err := m.primary.Set(item)
+	m.mirror(OpSet, func(c Cacher) error { return c.Set(item) })
+	return err
+}
+
+func (m *MultiClusterClient) Add(item *memcache.Item) error {
+	err := m.primary.Add(item)
+	if err == nil {
+		m.mirror(OpAdd, func(c Cacher) error { return c.Set(item) })
+	}
+	return err
+}
+
+func (m *MultiClusterClient) Replace(item *memcache.Item) error {
+	err := m.primary.Replace(item)
+	if err == nil {
+		m.mirror(OpReplace, func(c Cacher) error { return c.Set(item) })
+	}
+	return err
+}
+
+// CompareAndSwap swaps item on the primary, whose CAS ID it must hold, setting
+// it on the secondary under MirrorWrites when the swap succeeds
+func (m *MultiClusterClient) CompareAndSwap(item *memcache.Item) error {
+	err := m.primary.CompareAndSwap(item)
+	if err == nil {
+		m.mirror(OpCompareAndSwap, func(c Cacher) error { return c.Set(item) })
+	}
+	return err
+}
+
+func (m *MultiClusterClient) Delete(key string) error {
+	err := m.primary.Delete(key)
+	m.mirror(OpDelete, func(c Cacher) error { return c.Delete(key) })
+	return err
+}
+
+func (m *MultiClusterClient) Touch(key string, seconds int32) error {
+	err := m.primary.Touch(key, seconds)
+	m.mirror(OpTouch, func(c Cacher) error { return c.Touch(key, seconds) })
+	return err
+}
+
+func (m *MultiClusterClient) Increment(key string, delta uint64) (uint64, error) {
+	n, err := m.primary.Increment(key, delta)
+	m.mirror(OpIncrement, func(c Cacher) error {
+		_, err := c.Increment(key, delta)
+		return err
+	})
+	return n, err
+}
+
+func (m *MultiClusterClient) Decrement(key string, delta uint64) (uint64, error) {
+	n, err := m.primary.Decrement(key, delta)
+	m.mirror(OpDecrement, func(c Cacher) error {
+		_, err := c.Decrement(key, delta)
+		return err
+	})
+	return n, err
+}
+
+// The typed getters decode what Get reads, falling back like Get.
+
+func (m *MultiClusterClient) GetString(k string, _ ...CallOption) (string, bool) {
+	return getString(m, k)
+}
+func (m *MultiClusterClient) GetInt64(k string, _ ...CallOption) (int64, bool) { return getInt64(m, k) }
+func (m *MultiClusterClient) GetBool(k string, _ ...CallOption) (bool, bool)   { return getBool(m, k) }
+func (m *MultiClusterClient) GetFloat64(k string, _ ...CallOption) (float64, bool) {
+	return getFloat64(m, k)
+}
+func (m *MultiClusterClient) GetMap(k string, _ ...CallOption) (map[string]interface{}, bool) {
+	return getMap(m, k)
+}
+func (m *MultiClusterClient) GetBigInt(k string, _ ...CallOption) (*big.Int, bool) {
+	return getBigInt(m, k)
+}
+func (m *MultiClusterClient) GetBytes(k string, _ ...CallOption) ([]byte, bool) {
+	return getBytes(m, k)
+}
+func (m *MultiClusterClient) GetTime(k string, _ ...CallOption) (time.Time, bool) {
+	return getTime(m, k)
+}
+func (m *MultiClusterClient) GetDecimal(k string, _ ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(m, k)
+}
+func (m *MultiClusterClient) GetAny(k string, _ ...CallOption) (interface{}, Type, bool) {
+	return getAny(m, k, deserializeItem)
+}
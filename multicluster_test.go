@@ -0,0 +1,78 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// twoClusters returns clients of two embedded servers
+func twoClusters(t *testing.T) (*Client, *Client) {
+	var clients []*Client
+	for n := 0; n < 2; n++ {
+		s, err := newLocalServer(systemClock{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.ln.Close() })
+		clients = append(clients, NewClient([]string{s.ln.Addr().String()}))
+	}
+	return clients[0], clients[1]
+}
+
+func TestMultiClusterClient(t *testing.T) {
+	primary, secondary := twoClusters(t)
+	metrics := &countingMetrics{}
+	chaos := NewChaos(primary)
+	mc := NewMultiClusterClient(chaos, secondary, MultiClusterOptions{MirrorWrites: true, BackfillTTL: time.Minute, Metrics: metrics})
+
+	if err := mc.Set(&memcache.Item{Key: "mc_key", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*Client{primary, secondary} {
+		if i, err := c.Get("mc_key"); err != nil || string(i.Value) != "v" {
+			t.Errorf("Expected the write mirrored, got %v %v", i, err)
+		}
+	}
+
+	// misses only fall back under FallbackOnMiss
+	secondary.Set(&memcache.Item{Key: "mc_old", Value: []byte("old")})
+	if _, err := mc.Get("mc_old"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss, got %v", err)
+	}
+
+	// a failing primary falls back
+	chaos.SetConfig(ChaosConfig{Enabled: true, ErrorRate: 1})
+	if s, ok := mc.GetString("mc_key"); !ok || s != "v" {
+		t.Errorf("Expected the secondary to be read, got %q %v", s, ok)
+	}
+	if n := metrics.get(MetricClusterFallbacks); n != 1 {
+		t.Errorf("Expected 1 fallback, got %d", n)
+	}
+	if m, err := mc.GetMulti([]string{"mc_key", "mc_old"}); err != nil || len(m) != 2 {
+		t.Errorf("Expected both keys from the secondary, got %v %v", m, err)
+	}
+	if err := mc.Delete("mc_key"); err != ErrChaos {
+		t.Errorf("Expected the primary's error, got %v", err)
+	}
+	if _, err := secondary.Get("mc_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the delete mirrored, got %v", err)
+	}
+	chaos.SetConfig(ChaosConfig{})
+
+	// misses fall back and are backfilled
+	mc = NewMultiClusterClient(primary, secondary, MultiClusterOptions{FallbackOnMiss: true, BackfillTTL: time.Minute, Metrics: metrics})
+	if i, err := mc.Get("mc_old"); err != nil || string(i.Value) != "old" {
+		t.Errorf("Expected the secondary to be read, got %v %v", i, err)
+	}
+	if i, err := primary.Get("mc_old"); err != nil || string(i.Value) != "old" {
+		t.Errorf("Expected the value backfilled, got %v %v", i, err)
+	}
+	if err := mc.Set(&memcache.Item{Key: "mc_new", Value: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secondary.Get("mc_new"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected writes not to be mirrored, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrNamespaceConflict is returned by Register for a prefix that is empty or
+// overlaps a namespace already registered
+var ErrNamespaceConflict = errors.New("memcache: namespace prefix conflicts with a registered namespace")
+
+// Serializer converts the values of a Namespace to and from the value and flags
+// stored in memcached
+type Serializer[T any] interface {
+	Marshal(v T) ([]byte, uint32, error)
+	Unmarshal(value []byte, flags uint32) (T, error)
+}
+
+// PickleSerializer stores values the way pylibmc does with Serialize and reads
+// them with Deserialize, so T must be a type Deserialize returns: string, int64,
+// bool, float64, or a gopickle container type.
+type PickleSerializer[T any] struct{}
+
+func (PickleSerializer[T]) Marshal(v T) ([]byte, uint32, error) {
+	return Serialize(v)
+}
+
+func (PickleSerializer[T]) Unmarshal(value []byte, flags uint32) (T, error) {
+	var zero T
+	v, err := Deserialize(value, flags)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: expected %T got %T", InvalidType, zero, v)
+	}
+	return t, nil
+}
+
+// NamespaceOptions declares how the values of a Namespace are stored
+type NamespaceOptions[T any] struct {
+	// Serializer encodes values. nil uses PickleSerializer.
+	Serializer Serializer[T]
+	// TTL is the expiration of values written with Set. Zero doesn't expire them.
+	TTL time.Duration
+	// TTLPolicy bounds the TTLs values are written with (its Prefix is ignored)
+	TTLPolicy TTLPolicy
+	// Compress, e.g. ZlibStage, is applied to serialized values. nil stores them
+	// uncompressed.
+	Compress Stage
+}
+
+// Registry holds the namespaces declared for a client so each key prefix has a
+// single value type
+type Registry struct {
+	c          *Client
+	mu         sync.Mutex
+	namespaces map[string]string // prefix to value type
+}
+
+// NewRegistry returns an empty Registry for namespaces stored with c
+func NewRegistry(c *Client) *Registry {
+	return &Registry{c: c, namespaces: make(map[string]string)}
+}
+
+// Types returns the value type registered for each namespace prefix
+func (r *Registry) Types() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := make(map[string]string, len(r.namespaces))
+	for p, t := range r.namespaces {
+		m[p] = t
+	}
+	return m
+}
+
+// Register declares the namespace of keys starting with prefix holding values
+// of type T, returning its typed accessors. Prefixes may not overlap, e.g.
+// "user:" and "user:profile:" can't both be registered.
+func Register[T any](r *Registry, prefix string, opts NamespaceOptions[T]) (*Namespace[T], error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("%w: empty prefix", ErrNamespaceConflict)
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem().String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p, t := range r.namespaces {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			return nil, fmt.Errorf("%w: %q (%s) overlaps %q (%s)", ErrNamespaceConflict, prefix, typ, p, t)
+		}
+	}
+	r.namespaces[prefix] = typ
+	if opts.Serializer == nil {
+		opts.Serializer = PickleSerializer[T]{}
+	}
+	opts.TTLPolicy.Prefix = prefix
+	return &Namespace[T]{c: r.c, prefix: prefix, opts: opts}, nil
+}
+
+// Namespace reads and writes values of type T under a registered key prefix.
+// Keys are passed without the prefix.
+type Namespace[T any] struct {
+	c      *Client
+	prefix string
+	opts   NamespaceOptions[T]
+}
+
+// Prefix returns the namespace's key prefix
+func (n *Namespace[T]) Prefix() string { return n.prefix }
+
+// Key returns the memcached key of id
+func (n *Namespace[T]) Key(id string) string { return n.prefix + id }
+
+// Get returns the value of id. ErrCacheMiss is returned for a miss.
+func (n *Namespace[T]) Get(id string) (T, error) {
+	var zero T
+	i, err := n.c.Get(n.Key(id))
+	if err != nil {
+		return zero, err
+	}
+	value, flags := i.Value, i.Flags
+	if n.opts.Compress != nil {
+		if value, flags, err = n.opts.Compress.Decode(value, flags); err != nil {
+			return zero, err
+		}
+	}
+	return n.opts.Serializer.Unmarshal(value, flags)
+}
+
+// Set stores v as the value of id with the namespace's TTL
+func (n *Namespace[T]) Set(id string, v T) error {
+	return n.SetTTL(id, v, n.opts.TTL)
+}
+
+// SetTTL stores v as the value of id expiring after ttl (zero doesn't expire),
+// subject to the namespace's TTLPolicy
+func (n *Namespace[T]) SetTTL(id string, v T, ttl time.Duration) error {
+	value, flags, err := n.opts.Serializer.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if n.opts.Compress != nil {
+		if value, flags, err = n.opts.Compress.Encode(value, flags); err != nil {
+			return err
+		}
+	}
+	key := n.Key(id)
+	var exp int32
+	if ttl > 0 {
+		exp = n.c.expirationFor(int64(ttlSeconds(ttl)))
+	}
+	if exp, err = n.c.applyTTLPolicy(n.opts.TTLPolicy, key, exp); err != nil {
+		return err
+	}
+	return n.c.Set(&memcache.Item{Key: key, Value: value, Flags: flags, Expiration: exp})
+}
+
+// Delete deletes the value of id
+func (n *Namespace[T]) Delete(id string) error {
+	return n.c.Delete(n.Key(id))
+}
@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+type testProfile struct {
+	Name   string `json:"name"`
+	Visits int    `json:"visits"`
+}
+
+type jsonProfiles struct{}
+
+func (jsonProfiles) Marshal(p testProfile) ([]byte, uint32, error) {
+	b, err := json.Marshal(p)
+	return b, FLAG_NONE, err
+}
+
+func (jsonProfiles) Unmarshal(b []byte, _ uint32) (p testProfile, err error) {
+	err = json.Unmarshal(b, &p)
+	return
+}
+
+func TestRegistry(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	r := NewRegistry(mc)
+	users, err := Register(r, "user:", NamespaceOptions[testProfile]{
+		Serializer: jsonProfiles{},
+		TTL:        time.Hour,
+		Compress:   ZlibStage{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := Register(r, "name:", NamespaceOptions[string]{
+		TTLPolicy: TTLPolicy{Max: time.Minute, Reject: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Register(r, "user:admin:", NamespaceOptions[int64]{}); !errors.Is(err, ErrNamespaceConflict) {
+		t.Errorf("Expected ErrNamespaceConflict for an overlapping prefix, got %v", err)
+	}
+	if _, err := Register(r, "", NamespaceOptions[int64]{}); !errors.Is(err, ErrNamespaceConflict) {
+		t.Errorf("Expected ErrNamespaceConflict for an empty prefix, got %v", err)
+	}
+	if got := r.Types(); len(got) != 2 || got["user:"] != "memcache.testProfile" || got["name:"] != "string" {
+		t.Errorf("unexpected types %v", got)
+	}
+
+	if err := users.Set("1", testProfile{Name: strings.Repeat("jehiah", 100), Visits: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := users.Get("1"); err != nil || p.Visits != 3 {
+		t.Errorf("unexpected profile %+v %v", p, err)
+	}
+	i, _ := mc.Get(users.Key("1"))
+	if i.Flags&FLAG_ZLIB == 0 {
+		t.Errorf("Expected a compressed item, got flags %d", i.Flags)
+	}
+
+	if err := names.SetTTL("1", "jehiah", time.Hour); !errors.Is(err, ErrTTLPolicy) {
+		t.Errorf("Expected ErrTTLPolicy, got %v", err)
+	}
+	if err := names.SetTTL("1", "jehiah", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := names.Get("1"); err != nil || s != "jehiah" {
+		t.Errorf("unexpected name %q %v", s, err)
+	}
+	mc.Set(Int64Item(names.Key("2"), 5))
+	if _, err := names.Get("2"); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for an int in a string namespace, got %v", err)
+	}
+	names.Delete("1")
+	if _, err := names.Get("1"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package memcache
+
+import (
+	"errors"
+)
+
+// ErrCachedNone is returned by the E getters (GetStringE...), Get for types
+// other than interfaces and Fetch for keys holding a cached Python None, e.g. a
+// negative result stored with SetMiss. It tells "known not to exist" apart from
+// a miss (ErrCacheMiss), which should be looked up.
+var ErrCachedNone = errors.New("memcache: cached None")
+
+// SetMiss caches a negative result for key, expiring after ttl seconds: a
+// pickled Python None, which pylibmc reads as None and the E getters, Get and
+// Fetch report as ErrCachedNone (GetResult as ResultNone), so "not found"
+// results are cached without magic values both sides must special-case.
+func (c *Client) SetMiss(key string, ttl int32) error {
+	item, err := c.opts.Pipeline.Encode(key, nil)
+	if err != nil {
+		return err
+	}
+	item.Expiration = ttl
+	return c.Set(item)
+}
+
+// decodeNone returns decode setting none, rather than failing, for a cached
+// None. A None isn't a value failing to decode, which Options.LastGoodMaxAge
+// would replace with the last good one.
+func decodeNone[T any](decode func(*Item) (T, error), none *bool) func(*Item) (T, error) {
+	return func(i *Item) (T, error) {
+		if *none = i.IsNone(); *none {
+			var zero T
+			return zero, nil
+		}
+		return decode(i)
+	}
+}
@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestSetMiss(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	if err := mc.SetMiss("neg_user", 60); err != nil {
+		t.Fatal(err)
+	}
+	mc.Delete("neg_missing")
+
+	if i, err := mc.Get("neg_user"); err != nil || !(&Item{i}).IsNone() || i.Expiration != 0 {
+		t.Errorf("Expected a pickled None, got %v %v", i, err)
+	}
+	if _, err := mc.GetStringE("neg_user"); err != ErrCachedNone {
+		t.Errorf("Expected ErrCachedNone, got %v", err)
+	}
+	if _, err := mc.GetInt64E("neg_missing"); err != ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+	if _, err := Get[map[string]string](mc, "neg_user"); err != ErrCachedNone {
+		t.Errorf("Expected ErrCachedNone, got %v", err)
+	}
+	if v, err := Get[interface{}](mc, "neg_user"); err != nil || v != nil {
+		t.Errorf("Expected nil for an interface, got %v %v", v, err)
+	}
+	if r := GetResult[string](mc, "neg_user"); r.Kind != ResultNone {
+		t.Errorf("Expected ResultNone, got %s", r)
+	}
+
+	// negative results are cached by Fetch
+	loads := 0
+	load := func() (string, error) {
+		loads++
+		return "", ErrCachedNone
+	}
+	for n := 0; n < 2; n++ {
+		if _, err := mc.FetchString("neg_missing", 60, load); err != ErrCachedNone {
+			t.Errorf("Expected ErrCachedNone, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected the negative result to be cached, got %d loads", loads)
+	}
+}
@@ -0,0 +1,65 @@
+package memcache
+
+import (
+	"strconv"
+	"time"
+)
+
+// NamespaceVersionPrefix is prepended to a namespace's name to form the key
+// holding its version, see Client.Namespace
+const NamespaceVersionPrefix = "ns_version:"
+
+// Namespace returns the key prefix of the versioned namespace name, "name:version:",
+// reading its version from the key NamespaceVersionPrefix + name. Keys built
+// on the prefix are logically flushed together by InvalidateNamespace, which
+// bumps the version so later prefixes differ; the old keys are never read again
+// and expire or are evicted. A missing version is created from the time in
+// milliseconds, so a version lost to eviction isn't reused. Each call reads
+// the version, so resolve the prefix once per request rather than per key.
+// (Registered Namespaces are unrelated: they fix a prefix and value type.)
+//
+// The Python equivalent is:
+//
+//	def namespace(mc, name):
+//	    key = 'ns_version:' + name
+//	    version = mc.get(key)
+//	    if version is None:
+//	        mc.add(key, int(time.time() * 1000))
+//	        version = mc.incr(key, 0)
+//	    return '%s:%d:' % (name, version)
+func (c *Client) Namespace(name string) (string, error) {
+	key := NamespaceVersionPrefix + name
+	version, err := c.GetInt64E(key)
+	switch err {
+	case nil:
+	case ErrCacheMiss:
+		var v uint64
+		if v, err = c.Incr(key, 0, c.initialNamespaceVersion(), 0); err != nil {
+			return "", err
+		}
+		version = int64(v)
+	default:
+		return "", err
+	}
+	return name + ":" + strconv.FormatInt(version, 10) + ":", nil
+}
+
+// InvalidateNamespace bumps the version of the namespace name, logically
+// flushing every key built on the prefix Namespace returned for it. The
+// Python equivalent is:
+//
+//	def invalidate_namespace(mc, name):
+//	    key = 'ns_version:' + name
+//	    if not mc.add(key, int(time.time() * 1000)):
+//	        mc.incr(key)
+func (c *Client) InvalidateNamespace(name string) error {
+	_, err := c.Incr(NamespaceVersionPrefix+name, 1, c.initialNamespaceVersion(), 0)
+	return err
+}
+
+// initialNamespaceVersion is the version of a namespace without one: the time
+// in milliseconds, later than any version it had before being evicted unless
+// it was invalidated more than once a millisecond
+func (c *Client) initialNamespaceVersion() uint64 {
+	return uint64(c.now().UnixNano() / int64(time.Millisecond))
+}
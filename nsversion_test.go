@@ -0,0 +1,43 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete(NamespaceVersionPrefix + "users")
+
+	prefix, err := mc.Namespace("users")
+	if err != nil || !strings.HasPrefix(prefix, "users:") || !strings.HasSuffix(prefix, ":") {
+		t.Fatalf("Expected a versioned prefix, got %q %v", prefix, err)
+	}
+	if again, err := mc.Namespace("users"); err != nil || again != prefix {
+		t.Errorf("Expected the version to be kept, got %q %v", again, err)
+	}
+	if n, ok := mc.GetInt64(NamespaceVersionPrefix + "users"); !ok || n <= 0 {
+		t.Errorf("Expected the version stored as a Python int, got %d %v", n, ok)
+	}
+
+	mc.SetString(prefix+"1", "alice")
+	if err := mc.InvalidateNamespace("users"); err != nil {
+		t.Fatal(err)
+	}
+	next, err := mc.Namespace("users")
+	if err != nil || next == prefix {
+		t.Fatalf("Expected a new prefix after InvalidateNamespace, got %q %v", next, err)
+	}
+	if _, ok := mc.GetString(next + "1"); ok {
+		t.Error("Expected the keys of the old version to be unreachable")
+	}
+
+	// invalidating a namespace without a version creates one
+	mc.Delete(NamespaceVersionPrefix + "groups")
+	if err := mc.InvalidateNamespace("groups"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mc.GetInt64(NamespaceVersionPrefix + "groups"); !ok {
+		t.Error("Expected InvalidateNamespace to create the version")
+	}
+}
@@ -0,0 +1,84 @@
+package memcache
+
+import (
+	"net"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Operation metrics emitted under Options.OpMetrics, tagged by op and server
+const (
+	// MetricOps counts operations by result: "hit" or "miss" for reads (one
+	// per key for get_multi), "ok", "rejected" (ErrNotStored, ErrCASConflict
+	// or a miss on a write) or "error"
+	MetricOps = "memcache.ops"
+	// MetricOpLatency times each round trip to a server
+	MetricOpLatency = "memcache.op_latency"
+	// MetricBytes counts the bytes of values, tagged by direction: "read" or
+	// "written"
+	MetricBytes = "memcache.bytes"
+)
+
+// opResult classifies the outcome of op for MetricOps
+func opResult(op string, err error) string {
+	switch {
+	case err == nil && (op == OpGet || op == OpGetMulti):
+		return "hit"
+	case err == nil:
+		return "ok"
+	case err == memcache.ErrCacheMiss && (op == OpGet || op == OpGetMulti):
+		return "miss"
+	case isProtocolError(err):
+		return "rejected"
+	}
+	return "error"
+}
+
+// recordOp emits the metrics of a round trip to addr. get_multi results are
+// counted per key by recordKeys.
+func (c *Client) recordOp(op string, addr net.Addr, err error, d time.Duration) {
+	tags := map[string]string{"op": op, "server": addr.String()}
+	c.opts.Metrics.Timing(MetricOpLatency, d, tags)
+	if op == OpGetMulti && err == nil {
+		return
+	}
+	c.opts.Metrics.Count(MetricOps, 1, map[string]string{"op": op, "server": addr.String(), "result": opResult(op, err)})
+}
+
+// recordKeys counts the hits and misses of a get_multi of keys from addr
+func (c *Client) recordKeys(addr net.Addr, keys int, items map[string]*memcache.Item, err error) {
+	if !c.opts.OpMetrics || err != nil {
+		return
+	}
+	tags := func(result string) map[string]string {
+		return map[string]string{"op": OpGetMulti, "server": addr.String(), "result": result}
+	}
+	if len(items) > 0 {
+		c.opts.Metrics.Count(MetricOps, int64(len(items)), tags("hit"))
+	}
+	if keys > len(items) {
+		c.opts.Metrics.Count(MetricOps, int64(keys-len(items)), tags("miss"))
+	}
+	var n int
+	for _, i := range items {
+		n += len(i.Value)
+	}
+	c.countBytes(OpGetMulti, addr, "read", n)
+}
+
+// recordBytes counts n bytes of a value read or written for key
+func (c *Client) recordBytes(op, key, direction string, n int) {
+	if !c.opts.OpMetrics {
+		return
+	}
+	if addr, err := c.selector.PickServer(key); err == nil {
+		c.countBytes(op, addr, direction, n)
+	}
+}
+
+func (c *Client) countBytes(op string, addr net.Addr, direction string, n int) {
+	if n > 0 {
+		c.opts.Metrics.Count(MetricBytes, int64(n), map[string]string{"op": op, "server": addr.String(), "direction": direction})
+	}
+}
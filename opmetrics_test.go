@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestOpMetrics(t *testing.T) {
+	p := &PrometheusMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Metrics: p, OpMetrics: true})
+	mc.Delete("opmetrics_missing")
+	mc.SetString("opmetrics_key", "value")
+	mc.Get("opmetrics_key")
+	mc.Get("opmetrics_missing")
+	mc.GetMulti([]string{"opmetrics_key", "opmetrics_missing"})
+
+	server := mustAddr(t, mc, "opmetrics_key")
+	ops := p.counters[MetricOps]
+	for result, n := range map[string]float64{
+		`op="get",result="hit"`:        1,
+		`op="get",result="miss"`:       1,
+		`op="get_multi",result="hit"`:  1,
+		`op="get_multi",result="miss"`: 1,
+		`op="set",result="ok"`:         1,
+	} {
+		labels := result + `,server="` + server + `"`
+		if ops[labels] != n {
+			t.Errorf("Expected %v %s, got %v in %v", n, labels, ops[labels], ops)
+		}
+	}
+	if n := p.counters[MetricBytes][`direction="written",op="set",server="`+server+`"`]; n != 5 {
+		t.Errorf("Expected 5 bytes written, got %v", n)
+	}
+	if n := p.counters[MetricBytes][`direction="read",op="get",server="`+server+`"`]; n != 5 {
+		t.Errorf("Expected 5 bytes read, got %v", n)
+	}
+	if h := p.histograms[MetricOpLatency][`op="get",server="`+server+`"`]; h == nil || h.count != 2 {
+		t.Errorf("Expected 2 get latencies, got %v", h)
+	}
+}
+
+func mustAddr(t *testing.T, c *Client, key string) string {
+	addr, err := c.ServerForKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.String()
+}
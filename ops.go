@@ -0,0 +1,417 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Operation names used when recording per operation statistics
+const (
+	OpGet            = "get"
+	OpGetMulti       = "get_multi"
+	OpSet            = "set"
+	OpSetMulti       = "set_multi"
+	OpAdd            = "add"
+	OpReplace        = "replace"
+	OpAppend         = "append"
+	OpPrepend        = "prepend"
+	OpCompareAndSwap = "cas"
+	OpDelete         = "delete"
+	OpTouch          = "touch"
+	OpIncrement      = "incr"
+	OpDecrement      = "decr"
+)
+
+// The methods below shadow those of the embedded memcache.Client so that every
+// operation, including those made by the typed getters, passes through do.
+
+// Get gets the item for the given key. ErrCacheMiss is returned for a memcache
+// cache miss. The key must be at most 250 bytes in length. With
+// Options.BatchWindow set it is batched with concurrent Gets.
+func (c *Client) Get(key string) (item *memcache.Item, err error) {
+	full := c.fullKey(key)
+	span := c.startSpan(context.Background(), OpGet, key, full)
+	item, err = c.get(context.Background(), full)
+	span.read(item, err)
+	return unprefixed(item, key), err
+}
+
+// get is Get of a full key (see Options.KeyPrefix) attributing the operation
+// to ctx's trace
+func (c *Client) get(ctx context.Context, key string) (item *memcache.Item, err error) {
+	if i, ok := c.localGet(key); ok {
+		return i, nil
+	}
+	if c.flights == nil {
+		return c.fetch(ctx, key)
+	}
+	item, err, shared := c.flights.do(key, func() (*memcache.Item, error) { return c.fetch(ctx, key) })
+	if shared {
+		c.opts.Metrics.Count(MetricCoalescedGets, 1, nil)
+	}
+	return item, err
+}
+
+// fetch is get reading key from the servers (or the tiers behind them)
+func (c *Client) fetch(ctx context.Context, key string) (item *memcache.Item, err error) {
+	if c.batcher != nil {
+		// the batch was read with getMultiFull, which consults the overflow
+		// tier and the secondary
+		item, err = c.batcher.get(key)
+		item, err = c.softExpiry(c.replicaGet(key, item, err))
+		c.localStore(key, item, err)
+		return item, err
+	}
+	item, err = c.fetchTiers(ctx, key)
+	c.localStore(key, item, err)
+	return c.staleGet(key, item, err)
+}
+
+// fetchTiers reads key from the servers, the replicas, the overflow tier and
+// the secondary, bypassing the in-process tiers
+func (c *Client) fetchTiers(ctx context.Context, key string) (item *memcache.Item, err error) {
+	err = c.doCtx(ctx, OpGet, key, func() (err error) {
+		item, err = c.backendGet(ctx, key)
+		return
+	})
+	if err == nil {
+		c.recordBytes(OpGet, key, "read", len(item.Value))
+	}
+	item, err = c.softExpiry(c.replicaGet(key, item, err))
+	item, err = c.overflowGet(key, item, err)
+	return c.secondaryGet(key, item, err)
+}
+
+// GetMulti is a batch version of Get. The returned map from keys to items may have
+// fewer elements than the input slice, due to memcache cache misses. Repeated
+// keys are only requested once, and the servers holding them are queried
+// concurrently. Options.MaxBulkKeys and MaxBulkBytes bound the
+// keys of one call.
+func (c *Client) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	keys = c.dedupKeys(keys)
+	span := c.startSpan(context.Background(), OpGetMulti, "", "")
+	full := c.fullKeys(keys)
+	m, err := c.getMultiFull(full)
+	span.readMulti(len(keys), m, err)
+	return c.unprefixedMulti(keys, full, m), err
+}
+
+// getMultiFull is GetMulti of deduplicated full keys
+func (c *Client) getMultiFull(keys []string) (map[string]*memcache.Item, error) {
+	chunks, err := c.bulkChunks(OpGetMulti, len(keys), getSize(keys))
+	if err != nil {
+		return nil, err
+	}
+	if chunks != nil {
+		return getMultiChunked(keys, chunks, c.getMultiFull)
+	}
+	m, err := c.softExpiryMulti(c.getMulti(context.Background(), keys))
+	m, err = c.overflowGetMulti(keys, m, err)
+	m, err = c.secondaryGetMulti(keys, m, err)
+	return c.staleGetMulti(keys, m, err)
+}
+
+// getMulti groups keys by the server owning them on the continuum and fetches
+// them with one request per server (or per chunk of Options.GetMultiChunkSize
+// keys), concurrently so a call takes about as long as the slowest server
+// rather than the sum of them, running at most Options.MaxGetMultiConcurrency
+// requests at once. Each server's latency is measured separately.
+func (c *Client) getMulti(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+	if c.hotKeys != nil {
+		for _, k := range keys {
+			c.hotKeys.add(c.sanitizeKey(k))
+		}
+	}
+	type request struct {
+		addr net.Addr
+		keys []string
+	}
+	byServer := make(map[net.Addr][]string)
+	for _, k := range keys {
+		if err := c.checkKey(k); err != nil {
+			return nil, err
+		}
+		if !legalKey(k) {
+			return nil, memcache.ErrMalformedKey
+		}
+		addr, err := c.selector.PickServer(k)
+		if err != nil {
+			return nil, err
+		}
+		byServer[addr] = append(byServer[addr], k)
+	}
+	var requests []request
+	for addr, keys := range byServer {
+		for _, chunk := range chunkKeys(keys, c.opts.GetMultiChunkSize) {
+			requests = append(requests, request{addr, chunk})
+		}
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	m := make(map[string]*memcache.Item, len(keys))
+	fetch := func(r request) {
+		var items map[string]*memcache.Item
+		err := c.doAddr(ctx, OpGetMulti, r.keys[0], r.addr, func() (err error) {
+			items, err = c.Client.GetMulti(r.keys)
+			return
+		})
+		c.recordKeys(r.addr, len(r.keys), items, err)
+		mu.Lock()
+		defer mu.Unlock()
+		for k, i := range items {
+			m[k] = i
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(requests) == 1 {
+		fetch(requests[0])
+		return m, firstErr
+	}
+	var sem chan struct{}
+	if c.opts.MaxGetMultiConcurrency > 0 {
+		sem = make(chan struct{}, c.opts.MaxGetMultiConcurrency)
+	}
+	var wg sync.WaitGroup
+	for _, r := range requests {
+		wg.Add(1)
+		go func(r request) {
+			defer wg.Done()
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			fetch(r)
+		}(r)
+	}
+	wg.Wait()
+	return m, firstErr
+}
+
+// chunkKeys splits keys into chunks of at most size keys; size <= 0 is one chunk
+func chunkKeys(keys []string, size int) [][]string {
+	if size <= 0 || len(keys) <= size {
+		return [][]string{keys}
+	}
+	chunks := make([][]string, 0, (len(keys)+size-1)/size)
+	for len(keys) > size {
+		chunks = append(chunks, keys[:size])
+		keys = keys[size:]
+	}
+	return append(chunks, keys)
+}
+
+// Set writes the given item, unconditionally. Under Options.AsyncWrites the
+// write is queued and nil returned.
+func (c *Client) Set(item *memcache.Item) error {
+	full := c.prefixed(item)
+	span := c.startSpan(context.Background(), OpSet, item.Key, full.Key)
+	err := c.set(full)
+	span.write(item, err)
+	return err
+}
+
+// set is Set of an item under its full key
+func (c *Client) set(item *memcache.Item) error {
+	if c.async != nil && c.async.enqueue(item.Key, item) {
+		return nil
+	}
+	return c.write(OpSet, item, c.backendSet)
+}
+
+// Add writes the given item, if no value already exists for its key.
+// ErrNotStored is returned if that condition is not met.
+func (c *Client) Add(item *memcache.Item) error {
+	return c.write(OpAdd, c.prefixed(item), c.Client.Add)
+}
+
+// Replace writes the given item, but only if the server *does* already hold data
+// for this key.
+func (c *Client) Replace(item *memcache.Item) error {
+	return c.write(OpReplace, c.prefixed(item), c.Client.Replace)
+}
+
+// Append appends the given item to the existing item, if a value already exists
+// for its key. ErrNotStored is returned if that condition is not met.
+func (c *Client) Append(item *memcache.Item) error {
+	return c.write(OpAppend, c.prefixed(item), c.Client.Append)
+}
+
+// Prepend prepends the given item to the existing item, if a value already exists
+// for its key. ErrNotStored is returned if that condition is not met.
+func (c *Client) Prepend(item *memcache.Item) error {
+	return c.write(OpPrepend, c.prefixed(item), c.Client.Prepend)
+}
+
+// CompareAndSwap writes the given item that was previously returned by Get, if
+// the value was neither modified nor evicted between the Get and the
+// CompareAndSwap calls.
+func (c *Client) CompareAndSwap(item *memcache.Item) error {
+	return c.write(OpCompareAndSwap, c.prefixed(item), c.Client.CompareAndSwap)
+}
+
+// Delete deletes the item with the provided key. The error ErrCacheMiss is
+// returned if the item didn't already exist in the cache. Under
+// Options.AsyncWrites the delete is queued and nil returned.
+func (c *Client) Delete(key string) error {
+	full := c.fullKey(key)
+	span := c.startSpan(context.Background(), OpDelete, key, full)
+	if c.async != nil && c.async.enqueue(full, nil) {
+		span.end(nil)
+		return nil
+	}
+	err := c.delete(full)
+	span.end(err)
+	return err
+}
+
+// delete is Delete of a full key without Options.AsyncWrites
+func (c *Client) delete(key string) error {
+	return c.overflowDelete(key, c.do(OpDelete, key, func() error { return c.backendDelete(context.Background(), key) }))
+}
+
+// Touch updates the expiry for the given key.
+func (c *Client) Touch(key string, seconds int32) error {
+	key = c.fullKey(key)
+	seconds, err := c.enforceTTL(key, seconds)
+	if err != nil {
+		return err
+	}
+	return c.do(OpTouch, key, func() error { return c.Client.Touch(key, seconds) })
+}
+
+// Increment atomically increments key by delta. The return value is the new
+// value after being incremented or an error.
+func (c *Client) Increment(key string, delta uint64) (n uint64, err error) {
+	key = c.fullKey(key)
+	err = c.do(OpIncrement, key, func() (err error) {
+		n, err = c.Client.Increment(key, delta)
+		return
+	})
+	return
+}
+
+// Decrement atomically decrements key by delta. The return value is the new
+// value after being decremented or an error.
+func (c *Client) Decrement(key string, delta uint64) (n uint64, err error) {
+	key = c.fullKey(key)
+	err = c.do(OpDecrement, key, func() (err error) {
+		n, err = c.Client.Decrement(key, delta)
+		return
+	})
+	return
+}
+
+// do runs fn as operation op against the server owning key
+func (c *Client) do(op, key string, fn func() error) error {
+	return c.doCtx(context.Background(), op, key, fn)
+}
+
+// doCtx is do attributing the operation to ctx's trace
+func (c *Client) doCtx(ctx context.Context, op, key string, fn func() error) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	if c.opts.DryRun && dryRunOps[op] {
+		return c.dryRun(op, key, nil)
+	}
+	if c.opts.ProxyMode != ProxyNone {
+		run := fn
+		fn = func() error { return c.proxyError(run()) }
+	}
+	if c.local != nil && (op == OpDelete || op == OpIncrement || op == OpDecrement) {
+		// the key's new value isn't known, so the local copy is dropped
+		run := fn
+		fn = func() error {
+			defer c.localRemove(key)
+			return run()
+		}
+	}
+	if c.hotKeys != nil && op == OpGet {
+		c.hotKeys.add(c.sanitizeKey(key))
+	}
+	if !c.observing() {
+		return fn()
+	}
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return fn()
+	}
+	return c.doAddr(ctx, op, key, addr, fn)
+}
+
+// observing reports whether operations need to be timed and attributed to a server
+func (c *Client) observing() bool {
+	return c.stats != nil || c.slowLog != nil || c.opts.FailureDetector != nil || c.breaker != nil || c.opts.OpMetrics || c.opts.Hooks != nil
+}
+
+// doAddr runs fn as operation op for key (the first key of a batch) against
+// addr, within ctx's trace
+func (c *Client) doAddr(ctx context.Context, op, key string, addr net.Addr, fn func() error) error {
+	if !c.observing() {
+		return fn()
+	}
+	if c.breaker != nil && !c.breaker.allow(addr.String(), c.now()) {
+		return ErrCircuitOpen
+	}
+	start := c.now()
+	var done func(error, time.Time)
+	if c.stats != nil {
+		done = c.stats.start(addr.String(), start)
+	}
+	var ev OpEvent
+	if h := c.opts.Hooks; h != nil {
+		ev = OpEvent{Op: op, Key: c.sanitizeKey(key), Server: addr.String(), Start: start}
+		h.OnStart(ctx, ev)
+	}
+	err := fn()
+	end := c.now()
+	if done != nil {
+		done(err, end)
+	}
+	if h := c.opts.Hooks; h != nil {
+		ev.Duration, ev.Err = end.Sub(start), err
+		h.OnFinish(ctx, ev)
+	}
+	if c.opts.OpMetrics {
+		c.recordOp(op, addr, err, end.Sub(start))
+	}
+	observed := err
+	if isProtocolError(err) {
+		observed = nil
+	}
+	if d := c.opts.FailureDetector; d != nil {
+		d.Observe(addr.String(), end, observed)
+	}
+	if c.breaker != nil && c.breaker.record(addr.String(), observed, end) {
+		c.opts.Metrics.Count(MetricBreakerTrips, 1, map[string]string{"server": addr.String()})
+	}
+	if c.slowLog != nil {
+		op := SlowOp{At: start, Op: op, Key: c.sanitizeKey(key), Addr: addr.String(), Duration: end.Sub(start)}
+		if err != nil && !isProtocolError(err) {
+			op.Err = err.Error()
+		}
+		if tc, ok := c.traceContext(ctx); ok {
+			op.TraceID, op.SpanID = tc.TraceID, tc.SpanID
+		}
+		c.slowLog.record(op)
+	}
+	return err
+}
+
+// isProtocolError reports whether err is a normal memcache protocol result
+// (a miss or failed condition) rather than a failure talking to the server
+func isProtocolError(err error) bool {
+	switch err {
+	case memcache.ErrCacheMiss, memcache.ErrCASConflict, memcache.ErrNotStored, memcache.ErrMalformedKey:
+		return true
+	}
+	_, ok := err.(*KeyError)
+	return ok
+}
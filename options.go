@@ -0,0 +1,435 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// Options configures a Client created with NewClientWithOptions. The zero value
+// gives the same behavior as NewClient.
+type Options struct {
+	// Clock is the time source for client side TTL logic. nil uses the system clock.
+	Clock Clock
+
+	// DefaultReadDeadline bounds context aware reads (GetCtx, GetMultiCtx) whose
+	// context has no deadline. Zero leaves them bounded only by the socket Timeout.
+	DefaultReadDeadline time.Duration
+	// DefaultWriteDeadline bounds context aware writes (SetCtx, DeleteCtx) whose
+	// context has no deadline. Zero leaves them bounded only by the socket Timeout.
+	DefaultWriteDeadline time.Duration
+
+	// MaxStaleness enables serving reads from an in-process copy of recently read
+	// values when servers are unreachable, as long as the copy is no older than
+	// MaxStaleness. Zero disables stale serving.
+	MaxStaleness time.Duration
+	// StaleCacheSize is the number of entries kept for stale serving, and for
+	// LastGoodMaxAge. Defaults to DefaultStaleCacheSize.
+	StaleCacheSize int
+	// LastGoodMaxAge enables serving the typed getters (and Get) the last value
+	// of a key read through this client that decoded, if no older than
+	// LastGoodMaxAge, when a later value fails to decode (a flag mismatch,
+	// truncated pickle or failing Pipeline stage), so a writer deploying bad
+	// values doesn't turn every read into a miss. Failures are counted as
+	// MetricCorruptValues. At most StaleCacheSize copies are kept. Zero
+	// disables it.
+	LastGoodMaxAge time.Duration
+	// LocalCacheSize enables an in-process LRU tier of this many values in
+	// front of the servers for hot keys: Get (and so the typed getters) answer
+	// from it without a round trip, values read or Set through this client
+	// are kept for up to LocalCacheTTL, and other writes and deletes through
+	// this client drop them. Writes by other clients aren't seen until the
+	// copy expires, so LocalCacheTTL bounds how stale reads may be.
+	// SkipLocalCache bypasses the tier. Zero disables it.
+	LocalCacheSize int
+	// LocalCacheTTL caps how long values are kept in the local tier; values
+	// Set with a shorter expiration are kept no longer. Defaults to
+	// DefaultLocalCacheTTL.
+	LocalCacheTTL time.Duration
+
+	// ProxyMode is set when the client talks to a proxy (twemproxy or mcrouter)
+	// rather than memcached. Every key is then sent to the first address, which
+	// should be the only one, and commands the proxy doesn't support fail with
+	// ErrProxyUnsupported. SERVER_ERROR responses become *ProxyError.
+	ProxyMode ProxyMode
+	// RoutingPrefix is prepended to every key under ProxyMcrouter, after
+	// KeyPrefix and LongKeyHash, to route keys to an mcrouter pool, e.g.
+	// "/us-east-1/main/". mcrouter removes it before forwarding, so keys are
+	// stored as without it. It's ignored without ProxyMcrouter.
+	RoutingPrefix string
+
+	// KetamaHash hashes keys, and ketama continuum points, to match Python clients
+	// configured with another libmemcached hash, e.g. ketamacompat.HashCRC32 for
+	// pylibmc's {"hash": "crc"}. nil uses Jenkins one-at-a-time. Weighted
+	// clients always hash with MD5.
+	KetamaHash ketamacompat.HashFunc
+	// KetamaPoints is the number of points each server gets on the ketama
+	// continuum (the average for weighted clients), to match libmemcached builds
+	// with other MEMCACHED_POINTS_PER_SERVER constants. Zero uses
+	// ketamacompat.PointsPerServer (WeightedPointsPerServer for weighted
+	// clients).
+	KetamaPoints int
+	// Distribution selects how keys are distributed over servers. The zero
+	// value is DistributionKetama.
+	Distribution Distribution
+
+	// Timeout is the socket read and write timeout, which also bounds dialing
+	// (set on the embedded memcache.Client). Zero uses memcache.DefaultTimeout,
+	// 500ms, which is short for traffic crossing availability zones.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept per server (set on
+	// the embedded memcache.Client). Zero uses memcache.DefaultMaxIdleConns.
+	MaxIdleConns int
+	// KeepAlive is the TCP keep-alive period of connections made without
+	// DialContext. Zero uses the net.Dialer default; negative disables
+	// keep-alives.
+	KeepAlive time.Duration
+	// MetaProtocol sends Get, Set and Delete as the meta commands mg, ms and
+	// md (memcached 1.6+) on connections pooled apart from those of the other
+	// commands, which are unchanged. MetaGet doesn't need it.
+	MetaProtocol bool
+	// Username and Password, when Username is set, authenticate every
+	// connection before it is used with a binary protocol SASL PLAIN
+	// handshake, as pylibmc does. Commands are then sent on the connection as
+	// usual, so the servers (or the proxy in front of them) must accept text
+	// protocol commands from an authenticated connection.
+	Username string
+	Password string
+	// DialContext connects to servers. nil uses a net.Dialer.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	// AddressFamily selects which address family is tried first when a server
+	// hostname resolves to both IPv4 and IPv6 addresses. The other family is raced
+	// after FallbackDelay. Servers are always hashed by their configured hostname.
+	AddressFamily AddressFamily
+	// FallbackDelay is how long the preferred address family is given before the
+	// other family is dialed too. Defaults to DefaultFallbackDelay.
+	FallbackDelay time.Duration
+	// ResolveInterval, when set, re-resolves the servers given by hostname this
+	// often and closes the connections to addresses they no longer resolve to,
+	// so servers rescheduled to new IPs are redialed there instead of after a
+	// restart. Keys stay hashed by hostname, so placement doesn't change. Close
+	// stops it.
+	ResolveInterval time.Duration
+	// DiscoveryInterval is how often NewElastiCacheClient polls the
+	// configuration endpoint for node changes. Defaults to
+	// DefaultDiscoveryInterval.
+	DiscoveryInterval time.Duration
+	// DiscoveryByHostname places ElastiCache nodes on the ring by hostname
+	// rather than IP address. Python clients must hash them the same way.
+	DiscoveryByHostname bool
+	// ReconnectBackoff is the delay before redialing a server after a failed dial.
+	// It doubles with each consecutive failure (with jitter) up to MaxReconnectBackoff.
+	// Zero disables reconnect backoff.
+	ReconnectBackoff time.Duration
+	// MaxReconnectBackoff caps ReconnectBackoff. Defaults to DefaultMaxReconnectBackoff.
+	MaxReconnectBackoff time.Duration
+	// BreakerFailures opens a server's circuit after this many consecutive
+	// failed operations: for BreakerCooldown its operations fail immediately
+	// with ErrCircuitOpen rather than wait on timeouts, then one trial
+	// operation decides whether it closes or stays open for another cooldown.
+	// Zero disables circuit breaking.
+	BreakerFailures int
+	// BreakerCooldown defaults to DefaultBreakerCooldown
+	BreakerCooldown time.Duration
+	// ReconnectRate limits new connections per second across all servers so a
+	// restarted server isn't hit by every client at once. Zero is unlimited.
+	ReconnectRate float64
+	// ReconnectBurst is the number of connections allowed at once under ReconnectRate.
+	ReconnectBurst int
+
+	// ServerStatsWindow enables per server latency and error tracking reported by
+	// ServerStats over a rolling window of this length. Zero disables tracking.
+	ServerStatsWindow time.Duration
+	// HotKeys is the number of most frequently read keys tracked for HotKeys.
+	// Zero disables tracking.
+	HotKeys int
+	// SlowOpThreshold enables logging operations taking at least this long for
+	// SlowOps. Zero disables the slow operation log.
+	SlowOpThreshold time.Duration
+
+	// BatchWindow enables coalescing Get calls issued within this window of each
+	// other into a single GetMulti, trading up to BatchWindow of added latency for
+	// far fewer round trips in fan-out heavy handlers. Zero disables batching.
+	BatchWindow time.Duration
+	// BatchMaxKeys flushes a batch early once it holds this many distinct keys.
+	// Defaults to DefaultBatchMaxKeys.
+	BatchMaxKeys int
+	// CoalesceGets makes concurrent Gets of the same key (and so the typed
+	// getters) share one round trip: Gets made while one for the key is in
+	// flight wait for its result, each getting its own copy of the item.
+	// Waiting Gets are bounded by the Get in flight rather than their own
+	// context. Shared results are counted under MetricCoalescedGets.
+	CoalesceGets bool
+
+	// AsyncWrites makes Set and Delete queue their write and return nil at
+	// once, like pylibmc's buffer_requests behavior. Queued writes are written
+	// from the background once AsyncBatchSize keys are queued or
+	// AsyncFlushInterval has passed, and by Flush and Close; a later write of a
+	// queued key replaces it. Reads don't see queued writes.
+	AsyncWrites bool
+	// AsyncBatchSize defaults to DefaultAsyncBatchSize
+	AsyncBatchSize int
+	// AsyncFlushInterval defaults to DefaultAsyncFlushInterval
+	AsyncFlushInterval time.Duration
+	// OnAsyncError receives the queued writes that failed, with OpSet or
+	// OpDelete. Deleting a missing key isn't a failure. nil drops the errors.
+	OnAsyncError func(op, key string, err error)
+
+	// GetMultiChunkSize splits the keys GetMulti sends to each server into
+	// requests of at most this many keys (1000 is a reasonable choice), bounding
+	// the size of protocol lines and responses. Zero sends one request per server.
+	GetMultiChunkSize int
+	// MaxGetMultiConcurrency limits how many GetMulti requests (one per server or
+	// chunk) run at once. Zero is unlimited.
+	MaxGetMultiConcurrency int
+	// MaxBulkKeys caps the keys of one GetMulti (after removing repeats) or
+	// SetMulti call, so a bug passing a huge key list can't build a huge
+	// request and stall a server. Zero is unlimited.
+	MaxBulkKeys int
+	// MaxBulkBytes caps the aggregate size of one GetMulti (its keys) or
+	// SetMulti call (its keys and values). Zero is unlimited.
+	MaxBulkBytes int
+	// BulkLimit selects whether calls over MaxBulkKeys or MaxBulkBytes fail
+	// with a *BulkLimitError (the zero value) or are split into calls within
+	// them.
+	BulkLimit BulkLimitPolicy
+
+	// Retry retries Gets, Sets and Deletes failing with transient errors. The
+	// zero value doesn't retry.
+	Retry RetryPolicy
+	// Replicas is the number of servers following a key's server, in the order
+	// configured, that Set and Delete also write, like libmemcached's
+	// number_of_replicas (pylibmc's num_replicas). Get reads them in turn when
+	// the key's server misses or fails, so keys survive the loss of a server.
+	// Replica writes are best effort and counted under
+	// MetricReplicaWriteErrors. Other operations only go to the key's server.
+	// It is ignored under ProxyMode.
+	Replicas int
+
+	// LoaderBudget limits how often GetMultiLoad calls its loader for reads
+	// missing many keys. The zero value doesn't limit it.
+	LoaderBudget LoaderBudget
+
+	// Pipeline is applied to values written by the typed setters (SetString...)
+	// and read by the typed getters (GetString...). The zero value only serializes.
+	Pipeline Pipeline
+	// MinCompressLen enables pylibmc compatible zlib compression of values of at
+	// least this many bytes written by the typed setters, when Pipeline.Compress
+	// isn't set. Compressed values are always read. Zero disables compression.
+	MinCompressLen int
+	// CompressLevel is the zlib level used with MinCompressLen. Zero uses the
+	// zlib default.
+	CompressLevel int
+	// PickleProtocol is the pickle protocol (2, 4 or 5) of values the client
+	// pickles: typed sets, dict and list updates and the Client's UnicodeItem and
+	// PickleItem. Zero uses protocol 2, which pylibmc and Python 2 read. Values
+	// of any protocol are read regardless.
+	PickleProtocol int
+	// Unicode controls how GetString handles pickled strings holding invalid
+	// UTF-8 or lone surrogates. The zero value returns them unchecked.
+	Unicode picklecompat.UnicodePolicy
+	// UnknownFlags selects how the typed getters and FetchAll read values with
+	// flag bits pylibmc doesn't set. The zero value fails them as InvalidType.
+	UnknownFlags UnknownFlagsPolicy
+
+	// TenantQuota is the quota applied to TenantClients from WithTenant
+	TenantQuota TenantQuota
+	// TenantQuotas overrides TenantQuota for specific tenants
+	TenantQuotas map[string]TenantQuota
+
+	// FailureDetector, when set, observes every operation and temporarily
+	// ejects servers it considers failed from the ketama ring, e.g.
+	// NewPhiAccrualDetector(), or NewFailureCountDetector() for pylibmc's
+	// remove_failed. nil keeps every server on the ring.
+	FailureDetector FailureDetector
+
+	// EagerConnect starts connecting to every server when the client is created
+	// rather than on its first request. NewClientWithOptions connects in the
+	// background; use ConnectClient to fail on unreachable servers.
+	EagerConnect bool
+	// DetectFeatures detects the features each server supports (see
+	// DetectFeatures) when the client is created, in the background;
+	// ConnectClient waits for it. Commands are sent to servers not yet
+	// detected.
+	DetectFeatures bool
+
+	// TTLPolicies bound the TTL of items written (and touched) by key prefix.
+	// Writes outside a policy are clamped or rejected with ErrTTLPolicy.
+	TTLPolicies []TTLPolicy
+	// ExpirationJitter shortens the TTL of items written (Set, Add, Replace and
+	// CompareAndSwap, not Touch) by a random fraction of up to
+	// ExpirationJitter (0-1) of it, so keys written together, e.g. by a cache
+	// warming job, don't all expire at the same instant. TTLPolicies apply to
+	// the shortened TTL. Zero disables jitter.
+	ExpirationJitter float64
+
+	// OnWrite receives a record of a WriteSampleRate fraction of successful item
+	// writes (Set, Add, Replace, Append, Prepend and CompareAndSwap) for capacity
+	// planning. It is called synchronously so should hand records off quickly.
+	OnWrite func(WriteRecord)
+	// WriteSampleRate is the fraction (0-1) of writes passed to OnWrite. Zero
+	// disables sampling.
+	WriteSampleRate float64
+	// SampleWriteValues includes the value in each WriteRecord. Values are left
+	// out by default since they may hold personal data.
+	SampleWriteValues bool
+	// WriteNamespace returns the namespace recorded for a key. nil uses the part
+	// of the key before the first ':'.
+	WriteNamespace func(key string) string
+	// ReadFlagMetrics counts every value read from the servers under
+	// MetricReadFlags tagged by its flags and the namespace WriteNamespace
+	// returns for its key, to spot writers storing incompatible values in a
+	// shared cluster.
+	ReadFlagMetrics bool
+
+	// DryRun validates, records and counts mutating operations (item writes,
+	// Delete, Touch, Increment, Decrement and AdminClient commands) without
+	// sending them, for rehearsing migrations against a production
+	// configuration. Reads are still sent. Skipped operations succeed; Increment
+	// and Decrement return 0.
+	DryRun bool
+	// OnDryRun receives a record of every operation skipped under DryRun
+	OnDryRun func(DryRunRecord)
+
+	// TraceExtractor returns the trace context of the context an operation was
+	// made with, for tracers keeping it their own way (e.g. OpenTelemetry's
+	// trace.SpanContextFromContext). nil uses TraceContextFrom.
+	TraceExtractor func(context.Context) (TraceContext, bool)
+	// Tracer starts a span for each Get, GetMulti, Set and Delete, e.g. an
+	// OpenTelemetry adapter. nil disables spans.
+	Tracer Tracer
+	// Hooks is called before and after every round trip to a server, e.g. for
+	// slow query logging or custom metrics. nil disables hooks.
+	Hooks Hooks
+
+	// Secondary is a cluster, e.g. a larger regional one in front of the origin,
+	// read when a key misses on this client's servers. Writes and deletes only
+	// go to this client's servers, so invalidations must also be applied to the
+	// secondary. nil disables secondary reads.
+	Secondary Cacher
+	// BackfillTTL enables adding values read from Secondary to this client's
+	// servers, expiring after BackfillTTL, so later reads hit locally. Zero
+	// disables backfill.
+	BackfillTTL time.Duration
+
+	// Overflow is a persistent tier behind the servers for values too large for
+	// memcached or too expensive to lose to eviction. Set writes values of at
+	// least OverflowMinSize to it instead of the servers, and keys missing on the
+	// servers are read from it (before Secondary), with values that fit added
+	// back to the servers. Delete deletes from both. Other writes only go to the
+	// servers. nil disables the overflow tier.
+	Overflow OverflowStore
+	// OverflowMinSize is the value size from which Set writes to Overflow
+	// rather than the servers. Zero uses DefaultOverflowMinSize.
+	OverflowMinSize int
+	// OverflowWriteThrough makes Set write values that fit on the servers to
+	// Overflow as well, so they are read back from it once evicted. Otherwise
+	// Set deletes them from Overflow.
+	OverflowWriteThrough bool
+
+	// ChunkSize enables splitting the values Set that are longer than
+	// ChunkSize bytes, which memcached would reject, across several keys read
+	// back as one (see FLAG_CHUNKED for the format), e.g. 1000 * 1024 for
+	// memcached's default 1MB item size limit. Chunks of overwritten or deleted
+	// values are left to expire. Zero disables chunking; chunked values are
+	// read either way. Under Overflow, values of OverflowMinSize are written
+	// there instead.
+	ChunkSize int
+
+	// ExpiryLeaseTTL is how long the lease taken by a read reporting a stale key
+	// to OnProbableExpiry callbacks is held, keeping other reads from reporting
+	// it again. Zero uses DefaultExpiryLeaseTTL.
+	ExpiryLeaseTTL time.Duration
+
+	// KeySanitizer rewrites keys recorded in SlowOps, HotKeys, WriteRecords and
+	// DryRunRecords, e.g. KeyPattern, so identifiers aren't leaked to logs and
+	// dashboards and the number of distinct keys stays bounded. HotKeys then
+	// counts reads by sanitized key. Keys sent to servers, returned in errors
+	// and passed to OnProbableExpiry callbacks are unchanged. nil records keys
+	// as they are.
+	KeySanitizer func(key string) string
+
+	// KeyPrefix is added to every key, before it's hashed to a server, by
+	// KeyFunc, as Django's KEY_PREFIX is. Keys are passed to and returned by the
+	// client without it; everything behind the client (TTL policies, the local
+	// cache, Secondary, Overflow, KeySanitizer and the metrics) sees full keys.
+	// WithKeyPrefix overrides it for one typed call.
+	KeyPrefix string
+	// KeyVersion is passed to KeyFunc, as Django's VERSION is. Zero uses 1.
+	KeyVersion int
+	// KeyFunc builds the full keys stored in memcached, as Django's
+	// KEY_FUNCTION does. nil uses PrefixKeyFunc when KeyPrefix is set, and
+	// leaves keys unchanged otherwise; set DjangoKeyFunc to share keys with
+	// Django's cache framework.
+	KeyFunc KeyFunc
+	// LongKeyHash shortens the full keys longer than MaxKeyLength bytes, which
+	// memcached rejects, e.g. LongKeySHA1, so they can be used transparently.
+	// Functions hashing with another algorithm (xxhash) may be set as long as
+	// the Python side mirrors them. nil leaves long keys failing.
+	LongKeyHash func(key string) string
+	// ValidateKeys checks keys before sending them, failing operations on
+	// malformed keys with a *KeyError naming the problem rather than
+	// memcache.ErrMalformedKey (which it wraps, for errors.Is)
+	ValidateKeys bool
+
+	// Metrics receives client counters and timings. nil discards them.
+	Metrics Metrics
+	// OpMetrics emits MetricOps, MetricOpLatency and MetricBytes to Metrics for
+	// every operation, tagged by op and server, for hit rates and latency
+	// dashboards (see PrometheusMetrics)
+	OpMetrics bool
+}
+
+// withDefaults returns a copy of o with unset fields filled in
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = systemClock{}
+	}
+	if o.StaleCacheSize <= 0 {
+		o.StaleCacheSize = DefaultStaleCacheSize
+	}
+	if o.LocalCacheTTL <= 0 {
+		o.LocalCacheTTL = DefaultLocalCacheTTL
+	}
+	if o.FallbackDelay <= 0 {
+		o.FallbackDelay = DefaultFallbackDelay
+	}
+	if o.DiscoveryInterval <= 0 {
+		o.DiscoveryInterval = DefaultDiscoveryInterval
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if o.MaxReconnectBackoff <= 0 {
+		o.MaxReconnectBackoff = DefaultMaxReconnectBackoff
+	}
+	if o.BatchMaxKeys <= 0 {
+		o.BatchMaxKeys = DefaultBatchMaxKeys
+	}
+	if o.AsyncBatchSize <= 0 {
+		o.AsyncBatchSize = DefaultAsyncBatchSize
+	}
+	if o.AsyncFlushInterval <= 0 {
+		o.AsyncFlushInterval = DefaultAsyncFlushInterval
+	}
+	if o.MinCompressLen > 0 && o.Pipeline.Compress == nil {
+		o.Pipeline.Compress = ZlibStage{MinCompressLen: o.MinCompressLen, Level: o.CompressLevel}
+	}
+	o.Pipeline.protocol = o.PickleProtocol
+	if o.KeyFunc == nil && o.KeyPrefix != "" {
+		o.KeyFunc = PrefixKeyFunc
+	}
+	if o.ProxyMode != ProxyMcrouter {
+		o.RoutingPrefix = ""
+	}
+	if o.KeyVersion == 0 {
+		o.KeyVersion = 1
+	}
+	if o.Metrics == nil {
+		o.Metrics = nopMetrics{}
+	}
+	return o
+}
@@ -0,0 +1,133 @@
+package memcache
+
+import (
+	"context"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultOverflowMinSize is the OverflowMinSize used when it is unset: a little
+// under memcached's default 1MB item size limit, which also counts the key and
+// item header
+const DefaultOverflowMinSize = 1000 * 1024
+
+// MetricOverflowReads counts keys read from Options.Overflow after missing on
+// the servers, tagged by result: "hit", "miss" or "error"
+const MetricOverflowReads = "memcache.overflow.reads"
+
+// OverflowStore is persistent storage, e.g. a local BoltDB or badger database
+// or an S3 bucket, used by Options.Overflow as a tier behind the servers.
+// Items are stored with their flags, so Python clients sharing the store can
+// decode them as they would from memcached. Get returns memcache.ErrCacheMiss
+// for keys it doesn't hold, including expired ones, and returns items with
+// their remaining Expiration; Delete returns memcache.ErrCacheMiss for keys it
+// didn't hold. Implementations must be safe for concurrent use.
+type OverflowStore interface {
+	Get(key string) (*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+}
+
+// overflowMinSize returns the size from which values are written to
+// Options.Overflow rather than the servers
+func (c *Client) overflowMinSize() int {
+	if c.opts.OverflowMinSize > 0 {
+		return c.opts.OverflowMinSize
+	}
+	return DefaultOverflowMinSize
+}
+
+// overflowSet wraps set, writing an item to the servers, so values of at least
+// OverflowMinSize are written to Options.Overflow instead (deleting any copy
+// the servers hold, which reads would find first) and others are written to
+// both under OverflowWriteThrough, or else deleted from Options.Overflow so a
+// larger value written earlier doesn't reappear once the new one is evicted
+func (c *Client) overflowSet(set func(*memcache.Item) error) func(*memcache.Item) error {
+	return func(item *memcache.Item) error {
+		store := c.opts.Overflow
+		if len(item.Value) >= c.overflowMinSize() {
+			if err := store.Set(item); err != nil {
+				return err
+			}
+			if err := c.backendDelete(context.Background(), item.Key); err != nil && err != memcache.ErrCacheMiss {
+				return err
+			}
+			return nil
+		}
+		if err := set(item); err != nil {
+			return err
+		}
+		if c.opts.OverflowWriteThrough {
+			return store.Set(item)
+		}
+		if err := store.Delete(item.Key); err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+		return nil
+	}
+}
+
+// overflowDelete deletes key from Options.Overflow after deleting it from the
+// servers returned err. ErrCacheMiss is only returned when neither held it.
+func (c *Client) overflowDelete(key string, err error) error {
+	if c.opts.Overflow == nil || c.opts.DryRun || (err != nil && err != memcache.ErrCacheMiss) {
+		return err
+	}
+	switch oerr := c.opts.Overflow.Delete(key); oerr {
+	case nil:
+		return nil
+	case memcache.ErrCacheMiss:
+		return err
+	default:
+		return oerr
+	}
+}
+
+// overflowGet reads key from Options.Overflow when the servers missed. The miss
+// is returned when the store misses or fails.
+func (c *Client) overflowGet(key string, i *memcache.Item, err error) (*memcache.Item, error) {
+	if c.opts.Overflow == nil || err != memcache.ErrCacheMiss {
+		return i, err
+	}
+	oi, oerr := c.opts.Overflow.Get(key)
+	switch oerr {
+	case nil:
+		c.opts.Metrics.Count(MetricOverflowReads, 1, map[string]string{"result": "hit"})
+		c.promote(oi)
+		return oi, nil
+	case memcache.ErrCacheMiss:
+		c.opts.Metrics.Count(MetricOverflowReads, 1, map[string]string{"result": "miss"})
+	default:
+		c.opts.Metrics.Count(MetricOverflowReads, 1, map[string]string{"result": "error"})
+	}
+	return i, err
+}
+
+// overflowGetMulti reads the keys missing from a successful GetMulti from
+// Options.Overflow, adding those found to m
+func (c *Client) overflowGetMulti(keys []string, m map[string]*memcache.Item, err error) (map[string]*memcache.Item, error) {
+	if c.opts.Overflow == nil || err != nil {
+		return m, err
+	}
+	for _, k := range keys {
+		if _, ok := m[k]; ok {
+			continue
+		}
+		if i, err := c.overflowGet(k, nil, memcache.ErrCacheMiss); err == nil {
+			if m == nil {
+				m = make(map[string]*memcache.Item, len(keys))
+			}
+			m[k] = i
+		}
+	}
+	return m, nil
+}
+
+// promote adds a value read from Options.Overflow that fits on the servers back
+// to them, so later reads hit there. Add doesn't clobber a value written since
+// the miss. Promotion is best effort and doesn't fail the read.
+func (c *Client) promote(i *memcache.Item) {
+	if len(i.Value) >= c.overflowMinSize() {
+		return
+	}
+	c.write(OpAdd, &memcache.Item{Key: i.Key, Value: i.Value, Flags: i.Flags, Expiration: i.Expiration}, c.Client.Add)
+}
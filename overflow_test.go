@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// mapStore is an in memory OverflowStore
+type mapStore struct {
+	sync.Mutex
+	items map[string]*memcache.Item
+}
+
+func (s *mapStore) Get(key string) (*memcache.Item, error) {
+	s.Lock()
+	defer s.Unlock()
+	i, ok := s.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	cp := *i
+	return &cp, nil
+}
+
+func (s *mapStore) Set(item *memcache.Item) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.items == nil {
+		s.items = make(map[string]*memcache.Item)
+	}
+	cp := *item
+	s.items[item.Key] = &cp
+	return nil
+}
+
+func (s *mapStore) Delete(key string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(s.items, key)
+	return nil
+}
+
+func TestOverflow(t *testing.T) {
+	store := &mapStore{}
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Overflow: store, OverflowMinSize: 16, Metrics: metrics})
+	large := "a value too large for the servers"
+
+	if err := mc.SetString("of_large", large); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Client.Get("of_large"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the large value not to be on the servers got %v", err)
+	}
+	if s, ok := mc.GetString("of_large"); !ok || s != large {
+		t.Errorf("Expected the large value from the overflow tier got %q %v", s, ok)
+	}
+	if m, err := mc.GetMulti([]string{"of_large", "of_missing"}); err != nil || len(m) != 1 || string(m["of_large"].Value) != large {
+		t.Errorf("Expected the large value from GetMulti got %v %v", m, err)
+	}
+	if n := metrics.get(MetricOverflowReads); n != 3 {
+		t.Errorf("Expected 3 overflow reads got %d", n)
+	}
+
+	// a small value replacing it is only on the servers
+	if err := mc.SetString("of_large", "small"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("of_large"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the large value deleted from the store got %v", err)
+	}
+	if err := mc.Delete("of_large"); err != nil {
+		t.Errorf("Expected the delete to succeed got %v", err)
+	}
+	if err := mc.Delete("of_large"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss got %v", err)
+	}
+
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{Overflow: store, OverflowMinSize: 16, OverflowWriteThrough: true})
+	mc.SetString("of_small", "small")
+	if _, err := store.Get("of_small"); err != nil {
+		t.Errorf("Expected the value written through got %v", err)
+	}
+	// evicted from the servers it is read back and promoted
+	mc.Client.Delete("of_small")
+	if s, ok := mc.GetString("of_small"); !ok || s != "small" {
+		t.Errorf("Expected small got %q %v", s, ok)
+	}
+	if i, err := mc.Client.Get("of_small"); err != nil || string(i.Value) != "small" {
+		t.Errorf("Expected the value promoted to the servers got %v", err)
+	}
+	if err := mc.Delete("of_small"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("of_small"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the value deleted from the store got %v", err)
+	}
+}
+
+func TestOverflowBatched(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Overflow: &mapStore{}, BatchWindow: time.Millisecond, Metrics: metrics})
+	if _, err := mc.Get("of_batched_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if n := metrics.get(MetricOverflowReads); n != 1 {
+		t.Errorf("Expected the overflow tier read once got %d", n)
+	}
+}
@@ -0,0 +1,283 @@
+package picklecompat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// findDatetime resolves the globals a pickled datetime.datetime is rebuilt
+// from: the class itself, the datetime.timezone and datetime.timedelta of a
+// fixed offset, pytz's zones and zoneinfo.ZoneInfo
+func findDatetime(module, name string) (interface{}, bool) {
+	switch module + "." + name {
+	case "datetime.datetime":
+		return datetimeClass{}, true
+	case "datetime.timedelta":
+		return timedeltaClass{}, true
+	case "datetime.timezone":
+		return timezoneClass{}, true
+	case "pytz._UTC", "pytz.utc":
+		return constant{time.UTC}, true
+	case "pytz._p":
+		return pytzZone{}, true
+	case "zoneinfo.ZoneInfo._unpickle":
+		return zoneInfo{}, true
+	case "zoneinfo.ZoneInfo":
+		return zoneInfoClass{}, true
+	case "__builtin__.getattr", "builtins.getattr":
+		return getattr{}, true
+	}
+	return nil, false
+}
+
+// datetimeClass builds a time.Time from the state bytes (a str on Python 2)
+// and optional tzinfo a datetime is reduced to. Naive datetimes are in
+// time.Local.
+type datetimeClass struct{}
+
+func (datetimeClass) Call(args ...interface{}) (interface{}, error) {
+	var state []byte
+	if len(args) > 0 {
+		switch s := args[0].(type) {
+		case []byte:
+			state = s
+		case string:
+			state = []byte(s)
+		}
+	}
+	loc := time.Local
+	if len(args) == 2 {
+		l, ok := args[1].(*time.Location)
+		if !ok {
+			return nil, fmt.Errorf("picklecompat: unsupported tzinfo %v", args[1])
+		}
+		loc = l
+	}
+	if len(state) != 10 || len(args) > 2 {
+		return nil, fmt.Errorf("picklecompat: unexpected datetime arguments %v", args)
+	}
+	// the month's high bit is the fold protocol 4 pickles record
+	us := int(state[7])<<16 | int(state[8])<<8 | int(state[9])
+	return time.Date(int(binary.BigEndian.Uint16(state)), time.Month(state[2]&0x7f), int(state[3]),
+		int(state[4]), int(state[5]), int(state[6]), us*1000, loc), nil
+}
+
+// timedeltaClass builds a time.Duration from days, seconds and microseconds
+type timedeltaClass struct{}
+
+func (timedeltaClass) Call(args ...interface{}) (interface{}, error) {
+	var parts [3]int64
+	for n, a := range args {
+		v, ok := pyInt(a)
+		if !ok || n >= len(parts) {
+			return nil, fmt.Errorf("picklecompat: unexpected timedelta arguments %v", args)
+		}
+		parts[n] = v
+	}
+	return time.Duration(parts[0])*24*time.Hour + time.Duration(parts[1])*time.Second +
+		time.Duration(parts[2])*time.Microsecond, nil
+}
+
+// timezoneClass builds a fixed *time.Location from an offset and optional name,
+// naming unnamed offsets the way Python's tzname does
+type timezoneClass struct{}
+
+func (timezoneClass) Call(args ...interface{}) (interface{}, error) {
+	var offset time.Duration
+	var ok bool
+	if len(args) == 1 || len(args) == 2 {
+		offset, ok = args[0].(time.Duration)
+	}
+	if !ok {
+		return nil, fmt.Errorf("picklecompat: unexpected timezone arguments %v", args)
+	}
+	if len(args) == 2 {
+		name, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("picklecompat: unexpected timezone arguments %v", args)
+		}
+		return time.FixedZone(name, int(offset/time.Second)), nil
+	}
+	if offset == 0 {
+		return time.UTC, nil
+	}
+	sign, abs := '+', offset
+	if offset < 0 {
+		sign, abs = '-', -offset
+	}
+	name := fmt.Sprintf("UTC%c%02d:%02d", sign, abs/time.Hour, abs%time.Hour/time.Minute)
+	if s := abs % time.Minute / time.Second; s != 0 {
+		name += fmt.Sprintf(":%02d", s)
+	}
+	return time.FixedZone(name, int(offset/time.Second)), nil
+}
+
+// pytzZone builds the *time.Location of a pytz timezone, reduced to
+// pytz._p(zone) or, for a zone localized to an offset,
+// pytz._p(zone, utcoffset, dst, tzname) which is kept as that fixed offset
+type pytzZone struct{}
+
+func (pytzZone) Call(args ...interface{}) (interface{}, error) {
+	zone, ok := "", len(args) == 1 || len(args) == 4
+	if ok {
+		zone, ok = args[0].(string)
+	}
+	if ok && len(args) == 4 {
+		offset, okOffset := pyInt(args[1])
+		name, okName := args[3].(string)
+		if okOffset && okName {
+			return time.FixedZone(name, int(offset)), nil
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("picklecompat: unexpected pytz arguments %v", args)
+	}
+	return time.LoadLocation(zone)
+}
+
+// zoneInfo is zoneinfo.ZoneInfo._unpickle(key, from_cache), loading the zone
+// from the Go timezone database
+type zoneInfo struct{}
+
+func (zoneInfo) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("picklecompat: unexpected zoneinfo arguments %v", args)
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("picklecompat: unexpected zoneinfo arguments %v", args)
+	}
+	return time.LoadLocation(key)
+}
+
+// zoneInfoClass is zoneinfo.ZoneInfo, which protocols before 4 pickle zones
+// with as getattr(ZoneInfo, "_unpickle")
+type zoneInfoClass struct{}
+
+func (zoneInfoClass) Call(args ...interface{}) (interface{}, error) {
+	return nil, fmt.Errorf("picklecompat: unexpected zoneinfo.ZoneInfo call %v", args)
+}
+
+// getattr supports the attribute lookups datetime pickles make
+type getattr struct{}
+
+func (getattr) Call(args ...interface{}) (interface{}, error) {
+	if len(args) == 2 && args[1] == "_unpickle" {
+		if _, ok := args[0].(zoneInfoClass); ok {
+			return zoneInfo{}, nil
+		}
+	}
+	return nil, fmt.Errorf("picklecompat: unsupported getattr %v", args)
+}
+
+// constant is a global called to return a fixed value, like pytz._UTC()
+type constant struct{ v interface{} }
+
+func (c constant) Call(args ...interface{}) (interface{}, error) { return c.v, nil }
+
+// pyInt converts a decoded python int to an int64
+func pyInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case *big.Int:
+		return v.Int64(), v.IsInt64()
+	}
+	return 0, false
+}
+
+// datetime writes t as a datetime.datetime the way Python 3 pickles one:
+// datetime(state) for a time in time.Local, which is written naive, and
+// otherwise datetime(state, timezone(timedelta(...))) with t's UTC offset.
+// Python has microsecond precision so nanoseconds are truncated.
+func (e *encoder) datetime(t time.Time) error {
+	if t.Year() < 1 || t.Year() > 9999 {
+		return fmt.Errorf("%w: year %d out of the python datetime range", ErrUnsupportedType, t.Year())
+	}
+	us := t.Nanosecond() / 1000
+	state := []byte{byte(t.Year() >> 8), byte(t.Year()), byte(t.Month()), byte(t.Day()),
+		byte(t.Hour()), byte(t.Minute()), byte(t.Second()), byte(us >> 16), byte(us >> 8), byte(us)}
+	e.global("datetime", "datetime")
+	if err := e.bytes(state); err != nil {
+		return err
+	}
+	if t.Location() == time.Local {
+		e.b.WriteByte(opTuple1)
+	} else {
+		_, offset := t.Zone()
+		days := offset / 86400
+		if offset < 0 && offset%86400 != 0 {
+			days--
+		}
+		e.global("datetime", "timezone")
+		e.global("datetime", "timedelta")
+		e.int(int64(days))
+		e.int(int64(offset - days*86400))
+		e.int(0)
+		e.b.WriteByte(opTuple3)
+		e.put()
+		e.b.WriteByte(opReduce)
+		e.put()
+		e.b.WriteByte(opTuple1)
+		e.put()
+		e.b.WriteByte(opReduce)
+		e.put()
+		e.b.WriteByte(opTuple2)
+	}
+	e.put()
+	e.b.WriteByte(opReduce)
+	e.put()
+	return nil
+}
+
+// global writes module.name with GLOBAL or, in protocol 4, STACK_GLOBAL. Like
+// Python, a global written before and, in protocol 4, a module string are
+// fetched from the memo when memoizing.
+func (e *encoder) global(module, name string) {
+	key := module + "\n" + name
+	if n, ok := e.memoized[key]; ok {
+		e.get(n)
+		return
+	}
+	if e.opts.Protocol < 4 {
+		e.b.WriteString("c" + key + "\n")
+	} else {
+		if n, ok := e.memoized[module]; ok {
+			e.get(n)
+		} else {
+			e.b.Write([]byte{opShortBinUnicode, byte(len(module))})
+			e.b.WriteString(module)
+			e.remember(module)
+		}
+		e.b.Write([]byte{opShortBinUnicode, byte(len(name))})
+		e.b.WriteString(name)
+		e.put()
+		e.b.WriteByte(opStackGlobal)
+	}
+	e.remember(key)
+}
+
+// remember puts the value just written in the memo, recording its index as key
+func (e *encoder) remember(key string) {
+	if e.opts.Memo != MemoNone {
+		if e.memoized == nil {
+			e.memoized = make(map[string]uint32)
+		}
+		e.memoized[key] = e.memo
+	}
+	e.put()
+}
+
+// get fetches memo entry n with BINGET or LONG_BINGET
+func (e *encoder) get(n uint32) {
+	if n < 256 {
+		e.b.Write([]byte{opBinGet, byte(n)})
+	} else {
+		e.b.WriteByte(opLongBinGet)
+		binary.Write(&e.b, binary.LittleEndian, n)
+	}
+}
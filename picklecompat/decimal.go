@@ -0,0 +1,44 @@
+package picklecompat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Decimal is a python decimal.Decimal in its string form, e.g. "-12.50",
+// "1E+3" or "NaN", which keeps its precision and special values. Decode
+// returns pickled decimals as Decimal and Encode writes Decimal the way Python
+// pickles decimals.
+type Decimal string
+
+// Rat returns the value of d, failing for NaN and Infinity
+func (d Decimal) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(d))
+}
+
+// decimalClass builds a Decimal from the str a decimal is reduced to
+type decimalClass struct{}
+
+func (decimalClass) Call(args ...interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return Decimal("0"), nil
+	}
+	s, ok := args[0].(string)
+	if !ok || len(args) > 1 {
+		return nil, fmt.Errorf("picklecompat: unexpected decimal arguments %v", args)
+	}
+	return Decimal(s), nil
+}
+
+// decimal writes d as decimal.Decimal(str)
+func (e *encoder) decimal(d Decimal) error {
+	e.global("decimal", "Decimal")
+	if err := e.value(string(d)); err != nil {
+		return err
+	}
+	e.b.WriteByte(opTuple1)
+	e.put()
+	e.b.WriteByte(opReduce)
+	e.put()
+	return nil
+}
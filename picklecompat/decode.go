@@ -0,0 +1,230 @@
+package picklecompat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/nlpodyssey/gopickle/pickle"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// Decode decodes a pickle of any protocol. Python None is returned as nil, str
+// as string, bytes as []byte, int as int (or *big.Int when large) and containers
+// as the gopickle types (*types.List, *types.Tuple, *types.Dict, *types.Set...).
+// Python 2 str is returned as string too, and bytes pickled by protocols before
+// 3 (as a call of _codecs.encode) as []byte.
+// datetime.datetime is returned as time.Time, in time.Local when naive, and
+// datetime.timedelta as time.Duration. decimal.Decimal is returned as Decimal.
+// Pickles declaring a string, bytes or frame longer than the data are rejected
+// with ErrTooLarge before anything is allocated for them.
+func Decode(b []byte) (interface{}, error) {
+	if v, ok := decodeScalar(b); ok {
+		return v, nil
+	}
+	return decode(b)
+}
+
+// readers pools the readers gopickle unpicklers read from
+var readers = sync.Pool{New: func() interface{} { return new(bytes.Reader) }}
+
+// decode is Decode through gopickle's unpickler
+func decode(b []byte) (interface{}, error) {
+	if err := checkLengths(b); err != nil {
+		return nil, err
+	}
+	r := readers.Get().(*bytes.Reader)
+	defer func() {
+		r.Reset(nil)
+		readers.Put(r)
+	}()
+	r.Reset(rewriteText(b))
+	u := pickle.NewUnpickler(r)
+	u.FindClass = findClass
+	return u.Load()
+}
+
+// findClass resolves the globals gopickle doesn't: set and frozenset, which
+// protocols before 4 pickle as a call of the class with a list, and bytes,
+// which protocols before 3 pickle as _codecs.encode(str, "latin1") or, when
+// empty, bytes(). Datetimes are resolved by findDatetime and decimal.Decimal
+// as Decimal.
+func findClass(module, name string) (interface{}, error) {
+	if c, ok := findDatetime(module, name); ok {
+		return c, nil
+	}
+	if module == "decimal" && name == "Decimal" {
+		return decimalClass{}, nil
+	}
+	if module == "_codecs" && name == "encode" {
+		return encodeLatin1{}, nil
+	}
+	if module == "__builtin__" || module == "builtins" {
+		switch name {
+		case "bytes":
+			return encodeLatin1{}, nil
+		case "set":
+			return setClass(func(l []interface{}) interface{} { return types.NewSetFromSlice(l) }), nil
+		case "frozenset":
+			return setClass(func(l []interface{}) interface{} { return types.NewFrozenSetFromSlice(l) }), nil
+		}
+	}
+	return types.NewGenericClass(module, name), nil
+}
+
+// setClass builds a set from the list (or nothing) it's called with
+type setClass func(l []interface{}) interface{}
+
+func (c setClass) Call(args ...interface{}) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return c(nil), nil
+	case 1:
+		switch l := args[0].(type) {
+		case *types.List:
+			return c(*l), nil
+		case *types.Tuple:
+			return c(*l), nil
+		}
+	}
+	return nil, fmt.Errorf("picklecompat: unexpected set arguments %v", args)
+}
+
+// encodeLatin1 builds bytes from the str it's called with, each character
+// being a byte
+type encodeLatin1 struct{}
+
+func (encodeLatin1) Call(args ...interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return []byte{}, nil
+	}
+	s, ok := args[0].(string)
+	if !ok || (len(args) == 2 && args[1] != "latin1" && args[1] != "latin-1") || len(args) > 2 {
+		return nil, fmt.Errorf("picklecompat: unexpected bytes arguments %v", args)
+	}
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("picklecompat: bytes character %U out of range", r)
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
+
+// argSize is the fixed argument size of opcodes without a length prefix or line
+// argument; opcodes missing from the table take no argument
+var argSize = map[byte]int{
+	'K': 1, 'h': 1, 'q': 1, 0x80: 1, 0x82: 1,
+	'M': 2, 0x83: 2,
+	'J': 4, 'j': 4, 'r': 4, 0x84: 4,
+	'G': 8,
+}
+
+// lengthSize is the size of the length prefix of opcodes followed by that many bytes
+var lengthSize = map[byte]int{
+	'U': 1, 'C': 1, 0x8c: 1, 0x8a: 1,
+	'T': 4, 'B': 4, 'X': 4, 0x8b: 4,
+	0x8d: 8, 0x8e: 8, 0x96: 8,
+}
+
+// lineArgs is the number of newline terminated arguments of text opcodes
+var lineArgs = map[byte]int{
+	'F': 1, 'I': 1, 'L': 1, 'S': 1, 'V': 1, 'P': 1, 'g': 1, 'p': 1,
+	'c': 2, 'i': 2,
+}
+
+// checkLengths walks the opcodes of b verifying each declared length fits in
+// the remaining data, since the unpickler allocates the declared size up front.
+// Anything else wrong is left for the unpickler to report.
+func checkLengths(b []byte) error {
+	for i := 0; i < len(b) && b[i] != '.'; {
+		end, err := opEnd(b, i)
+		if err != nil {
+			return err
+		}
+		i = end
+	}
+	return nil
+}
+
+// opEnd returns the offset just past the opcode at b[i] and its argument, or
+// len(b) when the argument is truncated. A FRAME ends after its length since
+// the frame's opcodes follow inline. Declared lengths exceeding the remaining
+// data fail with ErrTooLarge.
+func opEnd(b []byte, i int) (int, error) {
+	op := b[i]
+	i++
+	switch {
+	case op == 0x95:
+		if len(b)-i < 8 {
+			return len(b), nil
+		}
+		if n := binary.LittleEndian.Uint64(b[i:]); n > uint64(len(b)-i-8) {
+			return 0, fmt.Errorf("%w: FRAME of %d bytes with %d remaining", ErrTooLarge, n, len(b)-i-8)
+		}
+		return i + 8, nil
+	case argSize[op] > 0:
+		if len(b)-i < argSize[op] {
+			return len(b), nil
+		}
+		return i + argSize[op], nil
+	case lengthSize[op] > 0:
+		size := lengthSize[op]
+		if len(b)-i < size {
+			return len(b), nil
+		}
+		var n uint64
+		switch size {
+		case 1:
+			n = uint64(b[i])
+		case 4:
+			n = uint64(binary.LittleEndian.Uint32(b[i:]))
+		case 8:
+			n = binary.LittleEndian.Uint64(b[i:])
+		}
+		i += size
+		if n > uint64(len(b)-i) {
+			return 0, fmt.Errorf("%w: opcode 0x%02x declares %d bytes with %d remaining", ErrTooLarge, op, n, len(b)-i)
+		}
+		return i + int(n), nil
+	case lineArgs[op] > 0:
+		for l := 0; l < lineArgs[op]; l++ {
+			end := bytes.IndexByte(b[i:], '\n')
+			if end < 0 {
+				return len(b), nil
+			}
+			i += end + 1
+		}
+	}
+	return i, nil
+}
+
+// IsPickle reports whether b is shaped like a complete pickle of any protocol:
+// known opcodes with arguments fitting the data, the last being STOP. It's a
+// cheap check for pickles stored without a pickle flag, such as protocol 0 and
+// 1 pickles which have no PROTO preamble; Decode may still fail on b.
+func IsPickle(b []byte) bool {
+	for i := 0; i < len(b); {
+		if !knownOpcode[b[i]] {
+			return false
+		}
+		if b[i] == '.' {
+			return i == len(b)-1
+		}
+		end, err := opEnd(b, i)
+		if err != nil {
+			return false
+		}
+		i = end
+	}
+	return false
+}
+
+var knownOpcode = func() (known [256]bool) {
+	for _, op := range opcodes {
+		known[op.Code] = true
+	}
+	return known
+}()
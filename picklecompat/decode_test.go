@@ -0,0 +1,317 @@
+package picklecompat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want interface{}
+	}{
+		{"protocol 0 str", "Vhello\np0\n.", "hello"},
+		{"protocol 2 unicode", "\x80\x02X\x05\x00\x00\x00helloq\x01.", "hello"},
+		{"protocol 4 short unicode", "\x80\x04\x95\t\x00\x00\x00\x00\x00\x00\x00\x8c\x05hello\x94.", "hello"},
+		{"protocol 2 int", "\x80\x02K*.", 42},
+		{"none", "\x80\x02N.", nil},
+	} {
+		got, err := Decode([]byte(tc.data))
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %#v %v, want %#v", tc.name, got, err, tc.want)
+		}
+	}
+
+	v, err := Decode([]byte("\x80\x02]q\x00(K\x01K\x02e."))
+	if l, ok := v.(*types.List); err != nil || !ok || l.Len() != 2 {
+		t.Errorf("unexpected list %#v %v", v, err)
+	}
+	if _, err := Decode([]byte("\x80\x02")); err == nil {
+		t.Errorf("Expected error decoding truncated pickle")
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want interface{}
+	}{
+		// pickle.dumps(v, 0) and (1)
+		{"protocol 0 unicode", "Vh\xe9llo \\u20ac\\u005cu\\u000a\np0\n.", "h\u00e9llo \u20ac\\u\n"},
+		{"protocol 0 astral", "V\\U0001f600\np0\n.", "\U0001f600"},
+		{"protocol 0 surrogate pair", "V\\ud83d\\ude00\np0\n.", "\U0001f600"},
+		{"protocol 0 lone surrogate", "V\\ud800x\np0\n.", "\xed\xa0\x80x"},
+		{"protocol 1 unicode", "X\r\x00\x00\x00h\xc3\xa9llo \xe2\x82\xac\\u\nq\x00.", "h\u00e9llo \u20ac\\u\n"},
+		// python 2 str
+		{"protocol 0 str", "S'h\\xe9llo\\n\\'\\\\'\np0\n.", "h\xe9llo\n'\\"},
+		{"protocol 0 str double quoted", "S\"it's\\t\\000\"\np0\n.", "it's\t\x00"},
+		{"protocol 1 str", "U\x05helloq\x00.", "hello"},
+	} {
+		got, err := Decode([]byte(tc.data))
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %#v %v, want %#v", tc.name, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{
+		// pickle.dumps(b"r\xffw", protocol)
+		{"protocol 0 bytes", "c_codecs\nencode\np0\n(Vr\xffw\np1\nVlatin1\np2\ntp3\nRp4\n.", "r\xffw"},
+		{"protocol 1 bytes", "c_codecs\nencode\nq\x00(X\x04\x00\x00\x00r\xc3\xbfwq\x01X\x06\x00\x00\x00latin1q\x02tq\x03Rq\x04.", "r\xffw"},
+		{"protocol 2 bytes", "\x80\x02c_codecs\nencode\nq\x00X\x04\x00\x00\x00r\xc3\xbfwq\x01X\x06\x00\x00\x00latin1q\x02\x86q\x03Rq\x04.", "r\xffw"},
+		{"protocol 0 empty bytes", "c__builtin__\nbytes\np0\n(tRp1\n.", ""},
+		{"protocol 2 empty bytes", "\x80\x02c__builtin__\nbytes\nq\x00)Rq\x01.", ""},
+		{"protocol 4 bytes", "\x80\x04\x95\x07\x00\x00\x00\x00\x00\x00\x00C\x03r\xffw\x94.", "r\xffw"},
+	} {
+		got, err := Decode([]byte(tc.data))
+		if b, ok := got.([]byte); err != nil || !ok || string(b) != tc.want {
+			t.Errorf("%s: got %#v %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	// memoized strings, pickle.dumps(["a", "a", "b\xe9"], 0)
+	v, err := Decode([]byte("(lp0\nVa\np1\nag1\naVb\xe9\np2\na."))
+	if l, ok := v.(*types.List); err != nil || !ok || l.Len() != 3 || l.Get(1) != "a" || l.Get(2) != "b\u00e9" {
+		t.Errorf("unexpected list %#v %v", v, err)
+	}
+}
+
+func TestIsPickle(t *testing.T) {
+	for _, data := range []string{
+		"Vhello\np0\n.",
+		"(lp0\nVa\np1\nag1\na.",
+		"X\x05\x00\x00\x00helloq\x00.",
+		"\x80\x02K*.",
+		"\x80\x04\x95\t\x00\x00\x00\x00\x00\x00\x00\x8c\x05hello\x94.",
+	} {
+		if !IsPickle([]byte(data)) {
+			t.Errorf("%q: expected a pickle", data)
+		}
+	}
+	for _, data := range []string{
+		"", "hello", "hello world.", "Vhello\n", "Vhello\n.x", "X\xff\x00\x00\x00hi.", "\x80\x02",
+	} {
+		if IsPickle([]byte(data)) {
+			t.Errorf("%q: unexpected pickle", data)
+		}
+	}
+}
+
+func TestDecodeSets(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+	}{
+		// pickle.dumps({1, 'a'}, protocol)
+		{"protocol 2 set", "\x80\x02c__builtin__\nset\nq\x00]q\x01(K\x01X\x01\x00\x00\x00aq\x02e\x85q\x03Rq\x04."},
+		{"protocol 4 set", "\x80\x04\x95\x0b\x00\x00\x00\x00\x00\x00\x00\x8f\x94(K\x01\x8c\x01a\x94\x90."},
+		// pickle.dumps(frozenset([1, 'a']), protocol)
+		{"protocol 2 frozenset", "\x80\x02c__builtin__\nfrozenset\nq\x00]q\x01(K\x01X\x01\x00\x00\x00aq\x02e\x85q\x03Rq\x04."},
+		{"protocol 4 frozenset", "\x80\x04\x95\n\x00\x00\x00\x00\x00\x00\x00(K\x01\x8c\x01a\x94\x91\x94."},
+		// pickle.dumps(set(), 0)
+		{"protocol 0 empty set", "c__builtin__\nset\np0\n((lp1\ntp2\nRp3\n."},
+	} {
+		v, err := Decode([]byte(tc.data))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		var n int
+		var has func(interface{}) bool
+		switch s := v.(type) {
+		case *types.Set:
+			n, has = s.Len(), s.Has
+		case *types.FrozenSet:
+			n, has = s.Len(), s.Has
+		default:
+			t.Errorf("%s: unexpected %#v", tc.name, v)
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		if n != 2 || !has(1) || !has("a") {
+			t.Errorf("%s: unexpected set %#v", tc.name, v)
+		}
+	}
+}
+
+func TestDecodeLengthGuard(t *testing.T) {
+	for _, data := range []string{
+		"\x80\x04\x8d\x00\x00\x00\x00\x01\x00\x00\x00hi.", // BINUNICODE8 claiming 4GiB
+		"\x80\x02X\xff\xff\xff\xffhi.",
+		"\x80\x04\x8c\x09hi.",
+		"\x80\x04\x95\xff\xff\xff\xff\xff\xff\xff\x7fN.",
+		"\x80\x02]q\x00(K\x01X\x10\x00\x00\x00ae.",
+	} {
+		if _, err := Decode([]byte(data)); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%q: expected ErrTooLarge, got %v", data, err)
+		}
+	}
+	// lengths are checked past text opcodes and inside frames
+	for _, data := range []string{
+		"\x80\x04\x95\x0c\x00\x00\x00\x00\x00\x00\x00\x8d\x02\x00\x00\x00\x00\x00\x00\x00hi.",
+		"(Vx\np0\nX\x01\x00\x00\x00yl.",
+	} {
+		if _, err := Decode([]byte(data)); err != nil {
+			t.Errorf("%q: unexpected error %v", data, err)
+		}
+	}
+}
+
+func TestSupportedOpcodes(t *testing.T) {
+	ops := SupportedOpcodes()
+	seen := make(map[byte]bool)
+	for _, op := range ops {
+		if seen[op.Code] {
+			t.Errorf("duplicate opcode %q", op.Code)
+		}
+		seen[op.Code] = true
+	}
+	// every opcode Encode emits must be listed as encoded
+	for _, code := range []byte{0x80, '.', 'N', 0x88, 0x89, 'K', 'M', 'J', 0x8a, 'G', 'X', ']', '}', '(', 'e', 'u', 't', ')', 'a', 's', 0x85, 0x86, 0x87, 'q', 'r', 0x8c, 0x8d, 0x94, 0x95, 'c', 'R', 'C', 'B', 0x8e, 'h', 'j', 0x93} {
+		found := false
+		for _, op := range ops {
+			if op.Code == code {
+				found = op.Encoded
+			}
+		}
+		if !found {
+			t.Errorf("opcode %q not listed as encoded", code)
+		}
+	}
+	ops[0].Name = "changed"
+	if SupportedOpcodes()[0].Name == "changed" {
+		t.Errorf("Expected SupportedOpcodes to return a copy")
+	}
+}
+
+func TestDecodeDatetime(t *testing.T) {
+	edt := time.FixedZone("EDT", -4*3600)
+	for _, tc := range []struct {
+		name string
+		data string
+		want time.Time
+		zone string
+	}{
+		{"python 2 naive", "\x80\x02cdatetime\ndatetime\nq\x01U\n\x07\xe8\x01\x02\x03\x04\x05\n[\xf5\x85Rq\x02.",
+			time.Date(2024, 1, 2, 3, 4, 5, 678901000, time.Local), "Local"},
+		{"python 2 protocol 0 naive", "cdatetime\ndatetime\np1\n(S'\\x07\\xe8\\x01\\x02\\x03\\x04\\x05\\n[\\xf5'\ntRp2\n.",
+			time.Date(2024, 1, 2, 3, 4, 5, 678901000, time.Local), "Local"},
+		{"python 3 protocol 4 naive", "\x80\x04\x95*\x00\x00\x00\x00\x00\x00\x00\x8c\x08datetime\x94\x8c\x08datetime\x94\x93\x94C\n\x07\xe8\x01\x02\x03\x04\x05\n[\xf5\x94\x85\x94R\x94.",
+			time.Date(2024, 1, 2, 3, 4, 5, 678901000, time.Local), "Local"},
+		{"python 3 timezone", "\x80\x02cdatetime\ndatetime\nq\x00c_codecs\nencode\nq\x01X\x0b\x00\x00\x00\x07\xc3\xa8\x01\x02\x03\x04\x05\x00\x00\x00q\x02X\x06\x00\x00\x00latin1q\x03\x86q\x04Rq\x05cdatetime\ntimezone\nq\x06cdatetime\ntimedelta\nq\x07J\xff\xff\xff\xffJ0\x0b\x01\x00K\x00\x87q\x08Rq\t\x85q\nRq\x0b\x86q\x0cRq\r.",
+			time.Date(2024, 1, 2, 8, 4, 5, 0, time.UTC), "UTC-05:00"},
+		{"python 3 named timezone", "\x80\x02cdatetime\ndatetime\nq\x00c_codecs\nencode\nq\x01X\x0b\x00\x00\x00\x07\xc3\xa8\x07\x02\x03\x04\x05\x00\x00\x00q\x02X\x06\x00\x00\x00latin1q\x03\x86q\x04Rq\x05cdatetime\ntimezone\nq\x06cdatetime\ntimedelta\nq\x07K\x00MXMK\x00\x87q\x08Rq\tX\x03\x00\x00\x00ISTq\n\x86q\x0bRq\x0c\x86q\rRq\x0e.",
+			time.Date(2024, 7, 1, 21, 34, 5, 0, time.UTC), "IST"},
+		{"python 2 pytz utc", "\x80\x02cdatetime\ndatetime\nq\x00U\n\x07\xe8\x01\x02\x03\x04\x05\x00\x00\x00q\x01cpytz\n_UTC\nq\x02)Rq\x03\x86q\x04Rq\x05.",
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "UTC"},
+		{"python 2 pytz zone", "\x80\x02cdatetime\ndatetime\nq\x00U\n\x07\xe8\x07\x02\x03\x04\x05\x00\x00\x00q\x01cpytz\n_p\nq\x02(U\x10America/New_Yorkq\x03J\xc0\xc7\xff\xffM\x10\x0eU\x03EDTq\x04tq\x05Rq\x06\x86q\x07Rq\x08.",
+			time.Date(2024, 7, 2, 3, 4, 5, 0, edt), "EDT"},
+		{"python 3 zoneinfo", "\x80\x04\x95\x82\x00\x00\x00\x00\x00\x00\x00\x8c\x08datetime\x94\x8c\x08datetime\x94\x93\x94C\n\x07\xe8\x07\x02\x03\x04\x05\x00\x00\x00\x94\x8c\x08builtins\x94\x8c\x07getattr\x94\x93\x94\x8c\x08zoneinfo\x94\x8c\x08ZoneInfo\x94\x93\x94\x8c\t_unpickle\x94\x86\x94R\x94\x8c\x10America/New_York\x94K\x01\x86\x94R\x94\x86\x94R\x94.",
+			time.Date(2024, 7, 2, 3, 4, 5, 0, edt), "America/New_York"},
+	} {
+		v, err := Decode([]byte(tc.data))
+		got, ok := v.(time.Time)
+		if err != nil || !ok || !got.Equal(tc.want) || got.Location().String() != tc.zone {
+			t.Errorf("%s: got %v %v, want %v in %s", tc.name, v, err, tc.want, tc.zone)
+		}
+	}
+
+	v, err := Decode([]byte("\x80\x02cdatetime\ntimedelta\nq\x00J\xff\xff\xff\xffK\x05K\x07\x87q\x01Rq\x02."))
+	if want := -24*time.Hour + 5*time.Second + 7*time.Microsecond; err != nil || v != want {
+		t.Errorf("Expected timedelta %v got %v %v", want, v, err)
+	}
+	if _, err := Decode([]byte("\x80\x02cdatetime\ndatetime\nU\x03abc\x85R.")); err == nil {
+		t.Error("Expected an error for a short datetime state")
+	}
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want Decimal
+	}{
+		{"python 2", "\x80\x02cdecimal\nDecimal\nq\x01U\x0512.50\x85Rq\x02.", "12.50"},
+		{"python 2 protocol 0", "cdecimal\nDecimal\np1\n(S'12.50'\ntRp2\n.", "12.50"},
+		{"python 3 protocol 0", "cdecimal\nDecimal\np0\n(V-12.50\np1\ntp2\nRp3\n.", "-12.50"},
+		{"python 3 special", "\x80\x02cdecimal\nDecimal\nq\x00X\t\x00\x00\x00-Infinityq\x01\x85q\x02Rq\x03.", "-Infinity"},
+	} {
+		if got, err := Decode([]byte(tc.data)); err != nil || got != tc.want {
+			t.Errorf("%s: got %#v %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeScalar(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		fast bool
+	}{
+		{"protocol 2 unicode", "\x80\x02X\x05\x00\x00\x00helloq\x01.", true},
+		{"protocol 2 unicode long memo", "\x80\x02X\x05\x00\x00\x00hellor\x00\x01\x00\x00.", true},
+		{"protocol 4 short unicode", "\x80\x04\x95\t\x00\x00\x00\x00\x00\x00\x00\x8c\x05hello\x94.", true},
+		{"python 2 str", "\x80\x02U\x05helloq\x00.", true},
+		{"python 2 long str", "\x80\x02T\x05\x00\x00\x00hello.", true},
+		{"none", "\x80\x02N.", true},
+		{"true", "\x80\x02\x88.", true},
+		{"false", "\x80\x02\x89.", true},
+		{"int1", "\x80\x02K*.", true},
+		{"int2", "\x80\x02M\xe8\x03.", true},
+		{"int4", "\x80\x02J\xff\xff\xff\xff.", true},
+		{"float", "\x80\x02G?\xf8\x00\x00\x00\x00\x00\x00.", true},
+		{"no preamble", "K*.", true},
+		{"protocol 0", "Vhello\np0\n.", false},
+		{"list", "\x80\x02]q\x00(K\x01K\x02e.", false},
+		{"trailing data", "\x80\x02K*.K", false},
+		{"truncated", "\x80\x02X\x05\x00\x00\x00hel", false},
+		{"oversized", "\x80\x02X\xff\xff\xff\x7fhello.", false},
+		{"unsupported protocol", "\x80\x06K*.", false},
+	} {
+		v, ok := decodeScalar([]byte(tc.data))
+		if ok != tc.fast {
+			t.Errorf("%s: expected decodeScalar ok=%v got %v", tc.name, tc.fast, ok)
+			continue
+		}
+		want, err := decode([]byte(tc.data))
+		if ok && (err != nil || v != want) {
+			t.Errorf("%s: decodeScalar got %#v, gopickle got %#v %v", tc.name, v, want, err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		data string
+	}{
+		{"unicode", "\x80\x02X\x05\x00\x00\x00helloq\x01."},
+		{"int", "\x80\x02K*."},
+		{"float", "\x80\x02G?\xf8\x00\x00\x00\x00\x00\x00."},
+		{"list", "\x80\x02]q\x00(K\x01K\x02e."},
+	} {
+		data := []byte(bc.data)
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				Decode(data)
+			}
+		})
+		b.Run(bc.name+"/gopickle", func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				decode(data)
+			}
+		})
+	}
+}
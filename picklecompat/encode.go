@@ -0,0 +1,472 @@
+// Package picklecompat encodes and decodes Python pickles byte compatibly with
+// the values python-memcached and pylibmc store. Encoding uses protocol 2, which
+// both Python 2 and Python 3 read, or protocol 4 and 5 for Python 3.4+ readers.
+// Decoding supports protocols 0 through 5.
+package picklecompat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// pickle opcodes used by the encoder
+const (
+	opProto      = 0x80
+	opStop       = '.'
+	opNone       = 'N'
+	opNewTrue    = 0x88
+	opNewFalse   = 0x89
+	opBinInt     = 'J'
+	opBinInt1    = 'K'
+	opBinInt2    = 'M'
+	opLong1      = 0x8a
+	opLong4      = 0x8b
+	opBinFloat   = 'G'
+	opBinUnicode = 'X'
+	opEmptyList  = ']'
+	opEmptyDict  = '}'
+	opMark       = '('
+	opAppend     = 'a'
+	opAppends    = 'e'
+	opSetItem    = 's'
+	opSetItems   = 'u'
+	opTuple      = 't'
+	opTuple1     = 0x85
+	opEmptyTuple = ')'
+	opBinPut     = 'q'
+	opLongBinPut = 'r'
+	opTuple2     = 0x86
+	opTuple3     = 0x87
+	opReduce     = 'R'
+	opBinGet     = 'h'
+	opLongBinGet = 'j'
+
+	// protocol 4
+	opShortBinUnicode = 0x8c
+	opBinUnicode8     = 0x8d
+	opMemoize         = 0x94
+	opFrame           = 0x95
+	opShortBinBytes   = 'C'
+	opBinBytes        = 'B'
+	opBinBytes8       = 0x8e
+	opStackGlobal     = 0x93
+)
+
+// batchSize is how many list items or dict entries Python emits per APPENDS or SETITEMS
+const batchSize = 1000
+
+// frameTarget is the size at which Python closes a protocol 4 FRAME and starts
+// the next; strings at least this long are written outside any frame
+const frameTarget = 64 * 1024
+
+// frameMin is the smallest frame Python writes; shorter data is left unframed
+const frameMin = 4
+
+// DefaultProtocol is the pickle protocol written when none is set
+const DefaultProtocol = 2
+
+var (
+	// ErrUnsupportedType is returned when encoding a value with no pickle equivalent
+	ErrUnsupportedType = errors.New("picklecompat: unsupported type")
+	// ErrTooLarge is returned encoding a string of 4GiB or more with protocol 2
+	// and decoding a pickle declaring a length longer than its data
+	ErrTooLarge = errors.New("picklecompat: value too large")
+)
+
+// Memo selects whether and how the encoder emits memo (BINPUT) opcodes. Memo
+// entries only matter to unpicklers for shared references, which Encode never
+// emits, but byte for byte comparisons with Python output need them to match.
+type Memo int
+
+const (
+	// MemoNone emits no BINPUT opcodes, giving the shortest output
+	MemoNone Memo = iota
+	// MemoPython3 numbers memo entries from 0 like Python 3's pickle.dumps
+	MemoPython3
+	// MemoPython2 numbers memo entries from 1 like Python 2's cPickle.dumps
+	MemoPython2
+)
+
+// EncodeOptions configures EncodeWith
+type EncodeOptions struct {
+	// Protocol is 2, 4 or 5. Zero uses DefaultProtocol.
+	Protocol int
+	// Memo selects memoization. Protocols 4 and 5 memoize with MEMOIZE, which has
+	// no index, so MemoPython2 and MemoPython3 give the same bytes.
+	Memo Memo
+	// Unicode is applied to every string before it's written
+	Unicode UnicodePolicy
+}
+
+// Encode encodes v as a protocol 2 pickle without memoization. It supports nil,
+// bool, integers, *big.Int, float64, string, []byte (as Python 3 bytes), time.Time
+// (as datetime.datetime), Decimal, []interface{}, map[string]interface{} and the
+// list, tuple and dict types returned by Decode, so decoded values can be
+// re-encoded.
+func Encode(v interface{}) ([]byte, error) {
+	return EncodeWith(v, EncodeOptions{})
+}
+
+// EncodeWith is Encode with options. With MemoPython3 the output is identical to
+// Python 3's pickle.dumps(v, protocol) for values without repeated objects
+// (dicts from a map are written in sorted key order).
+func EncodeWith(v interface{}, opts EncodeOptions) ([]byte, error) {
+	if opts.Protocol == 0 {
+		opts.Protocol = DefaultProtocol
+	}
+	switch opts.Protocol {
+	case 2, 4, 5:
+	default:
+		return nil, fmt.Errorf("picklecompat: unsupported protocol %d", opts.Protocol)
+	}
+	e := &encoder{opts: opts, framing: opts.Protocol >= 4}
+	if opts.Memo == MemoPython2 {
+		e.memo = 1
+	}
+	e.out.Write([]byte{opProto, byte(opts.Protocol)})
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	e.b.WriteByte(opStop)
+	e.commitFrame(true)
+	return e.out.Bytes(), nil
+}
+
+// EncodeString encodes s as a Python (unicode) str
+func EncodeString(s string) []byte {
+	b, _ := Encode(s)
+	return b
+}
+
+// EncodeDict encodes m as a Python dict with keys in sorted order
+func EncodeDict(m map[string]interface{}) ([]byte, error) {
+	return Encode(m)
+}
+
+// encoder writes opcodes to b, which holds the current frame when framing, and
+// moves them to out as frames are committed
+type encoder struct {
+	out     bytes.Buffer
+	b       bytes.Buffer
+	opts    EncodeOptions
+	framing bool
+	memo    uint32
+	// memoized indexes the globals and module names in the memo
+	memoized map[string]uint32
+}
+
+// commitFrame moves the current frame to out once it reaches frameTarget, or
+// whenever it holds data if force is set
+func (e *encoder) commitFrame(force bool) {
+	n := e.b.Len()
+	if n == 0 || (n < frameTarget && !force) {
+		return
+	}
+	if e.framing && n >= frameMin {
+		e.out.WriteByte(opFrame)
+		binary.Write(&e.out, binary.LittleEndian, uint64(n))
+	}
+	e.out.Write(e.b.Bytes())
+	e.b.Reset()
+}
+
+// put emits a memo entry for the value just written
+func (e *encoder) put() {
+	if e.opts.Memo == MemoNone {
+		return
+	}
+	if e.opts.Protocol >= 4 {
+		e.b.WriteByte(opMemoize)
+	} else if e.memo < 256 {
+		e.b.Write([]byte{opBinPut, byte(e.memo)})
+	} else {
+		e.b.WriteByte(opLongBinPut)
+		binary.Write(&e.b, binary.LittleEndian, e.memo)
+	}
+	e.memo++
+}
+
+func (e *encoder) encode(v interface{}) error {
+	if err := e.value(v); err != nil {
+		return err
+	}
+	// like CPython, frames end on an opcode boundary once large enough
+	e.commitFrame(false)
+	return nil
+}
+
+func (e *encoder) value(v interface{}) error {
+	b := &e.b
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(opNone)
+	case bool:
+		if v {
+			b.WriteByte(opNewTrue)
+		} else {
+			b.WriteByte(opNewFalse)
+		}
+	case int:
+		e.int(int64(v))
+	case int32:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case *big.Int:
+		if v.IsInt64() {
+			e.int(v.Int64())
+		} else {
+			e.long(v)
+		}
+	case float64:
+		b.WriteByte(opBinFloat)
+		binary.Write(b, binary.BigEndian, math.Float64bits(v))
+	case string:
+		v, err := checkUnicode(v, e.opts.Unicode)
+		if err != nil {
+			return err
+		}
+		header, err := unicodeHeader(len(v), e.opts.Protocol)
+		if err != nil {
+			return err
+		}
+		if e.framing && len(v) >= frameTarget {
+			// large payloads go straight to the output between frames
+			e.commitFrame(true)
+			e.out.Write(header)
+			e.out.WriteString(v)
+		} else {
+			b.Write(header)
+			b.WriteString(v)
+		}
+		e.put()
+	case []byte:
+		return e.bytes(v)
+	case time.Time:
+		return e.datetime(v)
+	case Decimal:
+		return e.decimal(v)
+	case []interface{}:
+		return e.list(v)
+	case *types.List:
+		return e.list(*v)
+	case *types.Tuple:
+		return e.tuple(*v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := types.NewDict()
+		for _, k := range keys {
+			d.Set(k, v[k])
+		}
+		return e.dict(*d)
+	case *types.Dict:
+		return e.dict(*v)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return nil
+}
+
+// unicodeHeader returns the opcode and length prefix for an n byte string:
+// SHORT_BINUNICODE, BINUNICODE or (beyond 4GiB) BINUNICODE8 in protocol 4 and
+// BINUNICODE in protocol 2, which can't hold 4GiB or more
+func unicodeHeader(n int, protocol int) ([]byte, error) {
+	switch {
+	case protocol >= 4 && n <= math.MaxUint8:
+		return []byte{opShortBinUnicode, byte(n)}, nil
+	case uint64(n) <= math.MaxUint32:
+		h := []byte{opBinUnicode, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(h[1:], uint32(n))
+		return h, nil
+	case protocol >= 4:
+		h := make([]byte, 9)
+		h[0] = opBinUnicode8
+		binary.LittleEndian.PutUint64(h[1:], uint64(n))
+		return h, nil
+	}
+	return nil, fmt.Errorf("%w: %d byte string needs protocol 4", ErrTooLarge, n)
+}
+
+// bytes writes v as Python 3 bytes: SHORT_BINBYTES, BINBYTES or BINBYTES8 in
+// protocol 4 and, as protocol 2 has no bytes opcodes, the call of
+// _codecs.encode(str, "latin1") (or bytes() when empty) Python 3 pickles them as
+func (e *encoder) bytes(v []byte) error {
+	if e.opts.Protocol < 4 {
+		if len(v) == 0 {
+			e.global("__builtin__", "bytes")
+			e.b.WriteByte(opEmptyTuple)
+		} else {
+			e.global("_codecs", "encode")
+			s := make([]byte, 0, len(v)+len(v)/2)
+			for _, c := range v {
+				s = utf8.AppendRune(s, rune(c))
+			}
+			if err := e.value(string(s)); err != nil {
+				return err
+			}
+			e.value("latin1")
+			e.b.WriteByte(opTuple2)
+			e.put()
+		}
+		e.b.WriteByte(opReduce)
+		e.put()
+		return nil
+	}
+	var header []byte
+	switch n := len(v); {
+	case n <= math.MaxUint8:
+		header = []byte{opShortBinBytes, byte(n)}
+	case uint64(n) <= math.MaxUint32:
+		header = binary.LittleEndian.AppendUint32([]byte{opBinBytes}, uint32(n))
+	default:
+		header = binary.LittleEndian.AppendUint64([]byte{opBinBytes8}, uint64(n))
+	}
+	if e.framing && len(v) >= frameTarget {
+		e.commitFrame(true)
+		e.out.Write(header)
+		e.out.Write(v)
+	} else {
+		e.b.Write(header)
+		e.b.Write(v)
+	}
+	e.put()
+	return nil
+}
+
+// list writes l the way CPython's C pickler (pickle.dumps) does: APPEND for a
+// single item, otherwise MARK ... APPENDS for each batch of up to batchSize
+// items, even a last batch of one. The pure Python pickler checks the batch
+// instead, writing APPEND for it.
+func (e *encoder) list(l []interface{}) error {
+	e.b.WriteByte(opEmptyList)
+	e.put()
+	for start := 0; start < len(l); start += batchSize {
+		batch := l[start:]
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		if len(l) > 1 {
+			e.b.WriteByte(opMark)
+		}
+		for _, item := range batch {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+		if len(l) > 1 {
+			e.b.WriteByte(opAppends)
+		} else {
+			e.b.WriteByte(opAppend)
+		}
+	}
+	return nil
+}
+
+// dict is list for dict entries using SETITEM and SETITEMS
+func (e *encoder) dict(d types.Dict) error {
+	e.b.WriteByte(opEmptyDict)
+	e.put()
+	for start := 0; start < len(d); start += batchSize {
+		batch := d[start:]
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		if len(d) > 1 {
+			e.b.WriteByte(opMark)
+		}
+		for _, entry := range batch {
+			if err := e.encode(entry.Key); err != nil {
+				return err
+			}
+			if err := e.encode(entry.Value); err != nil {
+				return err
+			}
+		}
+		if len(d) > 1 {
+			e.b.WriteByte(opSetItems)
+		} else {
+			e.b.WriteByte(opSetItem)
+		}
+	}
+	return nil
+}
+
+// tuple writes t with TUPLE1-3 when short, MARK ... TUPLE otherwise
+func (e *encoder) tuple(t []interface{}) error {
+	if len(t) == 0 {
+		e.b.WriteByte(opEmptyTuple)
+		return nil
+	}
+	if len(t) > 3 {
+		e.b.WriteByte(opMark)
+	}
+	for _, item := range t {
+		if err := e.encode(item); err != nil {
+			return err
+		}
+	}
+	if len(t) > 3 {
+		e.b.WriteByte(opTuple)
+	} else {
+		e.b.WriteByte(opTuple1 + byte(len(t)-1))
+	}
+	e.put()
+	return nil
+}
+
+func (e *encoder) int(n int64) {
+	b := &e.b
+	switch {
+	case n >= 0 && n <= math.MaxUint8:
+		b.Write([]byte{opBinInt1, byte(n)})
+	case n >= 0 && n <= math.MaxUint16:
+		b.WriteByte(opBinInt2)
+		binary.Write(b, binary.LittleEndian, uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b.WriteByte(opBinInt)
+		binary.Write(b, binary.LittleEndian, int32(n))
+	default:
+		e.long(big.NewInt(n))
+	}
+}
+
+// long writes n with LONG1 (or LONG4 when huge): little endian two's complement
+// in the fewest bytes
+func (e *encoder) long(n *big.Int) {
+	nbytes := n.BitLen()/8 + 1
+	data := make([]byte, nbytes)
+	v := new(big.Int).Set(n)
+	if n.Sign() < 0 {
+		// two's complement: 2^(8*nbytes) + n
+		v.Add(v, new(big.Int).Lsh(big.NewInt(1), uint(8*nbytes)))
+	}
+	be := v.Bytes()
+	for i, c := range be {
+		data[len(be)-1-i] = c
+	}
+	// like python, drop a redundant sign byte of a negative number
+	if n.Sign() < 0 && nbytes > 1 && data[nbytes-1] == 0xff && data[nbytes-2]&0x80 != 0 {
+		nbytes--
+		data = data[:nbytes]
+	}
+	if nbytes <= math.MaxUint8 {
+		e.b.Write([]byte{opLong1, byte(nbytes)})
+	} else {
+		e.b.WriteByte(opLong4)
+		binary.Write(&e.b, binary.LittleEndian, int32(nbytes))
+	}
+	e.b.Write(data)
+}
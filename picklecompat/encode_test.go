@@ -0,0 +1,296 @@
+package picklecompat
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestEncode(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	// expected encodings were checked with python's pickle.loads
+	for _, tc := range []struct {
+		v    interface{}
+		want string
+	}{
+		{nil, "80024e2e"},
+		{true, "8002882e"},
+		{5, "80024b052e"},
+		{300, "80024d2c012e"},
+		{-5, "80024afbffffff2e"},
+		{70000, "80024a701101002e"},
+		{int64(1) << 40, "80028a060000000000012e"},
+		{int64(-1) << 40, "80028a060000000000ff2e"},
+		{huge, "80028a0d2ef5c0b1111f8c3c09f01671fe2e"},
+		{1.5, "8002473ff80000000000002e"},
+		{"héllo", "8002580600000068c3a96c6c6f2e"},
+		{[]interface{}{1, "a", []interface{}{}}, "80025d284b015801000000615d652e"},
+		{map[string]interface{}{"b": 2, "a": nil}, "80027d285801000000614e5801000000624b02752e"},
+		{[]byte("r\xffw"), "8002635f636f646563730a656e636f64650a580400000072c3bf7758060000006c6174696e3186522e"},
+		{[]byte{}, "8002635f5f6275696c74696e5f5f0a62797465730a29522e"},
+	} {
+		b, err := Encode(tc.v)
+		if err != nil {
+			t.Errorf("Encode(%v): %v", tc.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tc.want {
+			t.Errorf("Encode(%v) = %s, want %s", tc.v, got, tc.want)
+		}
+	}
+
+	if _, err := Encode(struct{}{}); err == nil {
+		t.Errorf("Expected error pickling unsupported type")
+	}
+}
+
+func TestEncodeWithMemo(t *testing.T) {
+	// expected encodings are python 3's pickle.dumps(v, 2)
+	for _, tc := range []struct {
+		v    interface{}
+		memo Memo
+		want string
+	}{
+		{"hello", MemoPython3, "8002580500000068656c6c6f71002e"},
+		{"hello", MemoPython2, "8002580500000068656c6c6f71012e"},
+		{"hello", MemoNone, "8002580500000068656c6c6f2e"},
+		{[]interface{}{1, "a", map[string]interface{}{"b": 2}}, MemoPython3, "80025d7100284b0158010000006171017d710258010000006271034b0273652e"},
+		{types.NewTupleFromSlice([]interface{}{"x", 1}), MemoPython3, "800258010000007871004b018671012e"},
+		{[]interface{}{"one"}, MemoPython3, "80025d710058030000006f6e657101612e"},
+		{[]byte("r\xffw"), MemoPython3, "8002635f636f646563730a656e636f64650a7100580400000072c3bf77710158060000006c6174696e3171028671035271042e"},
+		{[]byte{}, MemoPython3, "8002635f5f6275696c74696e5f5f0a62797465730a7100295271012e"},
+	} {
+		b, err := EncodeWith(tc.v, EncodeOptions{Memo: tc.memo})
+		if err != nil {
+			t.Errorf("EncodeWith(%v): %v", tc.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tc.want {
+			t.Errorf("EncodeWith(%v, %d) = %s, want %s", tc.v, tc.memo, got, tc.want)
+		}
+	}
+
+	// memo indexes past 255 switch to LONG_BINPUT
+	l := make([]interface{}, 300)
+	for n := range l {
+		l[n] = "s"
+	}
+	b, err := EncodeWith(l, EncodeOptions{Memo: MemoPython3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte{opLongBinPut, 0x00, 0x01, 0, 0}) {
+		t.Errorf("Expected LONG_BINPUT 256 in %x", b)
+	}
+}
+
+func TestEncodeBatches(t *testing.T) {
+	// python 3's pickle.dumps(v, 2), whose C pickler picks APPENDS and SETITEMS
+	// by the length of the container rather than of the batch: the last batch
+	// of 1001 items is MARK x APPENDS, where the pure Python pickler would
+	// write x APPEND
+	l := make([]interface{}, 1001)
+	d := make(map[string]interface{}, 1001)
+	for n := range l {
+		l[n] = 0
+		d[fmt.Sprintf("k%04d", n)] = n
+	}
+	for _, tc := range []struct {
+		v      interface{}
+		size   int
+		sha256 string
+	}{
+		{l, 2012, "685e6dd3cedddb250d6fee56ae90813f56b3e0fe4b9dee48e1950e2a69705ba2"},
+		{d, 17007, "51b52b5258ddefba58261848c874b2cf68ff2ed7befb4c3bbcea58b7007eabf8"},
+	} {
+		b, err := EncodeWith(tc.v, EncodeOptions{Memo: MemoPython3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := sha256.Sum256(b); len(b) != tc.size || hex.EncodeToString(sum[:]) != tc.sha256 {
+			t.Errorf("%T of 1001: unexpected encoding of %d bytes ending %x", tc.v, len(b), b[len(b)-8:])
+		}
+	}
+}
+
+func TestEncodeProtocol(t *testing.T) {
+	// expected encodings are python 3's pickle.dumps(v, protocol)
+	for _, tc := range []struct {
+		v        interface{}
+		protocol int
+		want     string
+	}{
+		{"hi", 4, "80049506000000000000008c026869942e"},
+		{"hi", 5, "80059506000000000000008c026869942e"},
+		{nil, 4, "80044e2e"},
+		{[]interface{}{1, "a", map[string]interface{}{"b": 2}}, 4, "80049514000000000000005d94284b018c0161947d948c0162944b0273652e"},
+		{[]byte("r\xffw"), 4, "8004950700000000000000430372ff77942e"},
+		{[]byte{}, 5, "80059504000000000000004300942e"},
+		{[]interface{}{[]byte("a"), 1}, 5, "8005950b000000000000005d9428430161944b01652e"},
+	} {
+		b, err := EncodeWith(tc.v, EncodeOptions{Protocol: tc.protocol, Memo: MemoPython3})
+		if err != nil {
+			t.Errorf("EncodeWith(%v, %d): %v", tc.v, tc.protocol, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tc.want {
+			t.Errorf("EncodeWith(%v, %d) = %s, want %s", tc.v, tc.protocol, got, tc.want)
+		}
+	}
+
+	// strings of 64KiB or more are written between frames like python does
+	long := strings.Repeat("y", 70000)
+	b, err := EncodeWith([]interface{}{"a", long}, EncodeOptions{Protocol: 4, Memo: MemoPython3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "80049507000000000000005d94288c016194" + "5870110100" + hex.EncodeToString([]byte(long)) + "94652e"
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("unexpected framing of a long string: %.80s...", got)
+	}
+	if v, err := Decode(b); err != nil || (*v.(*types.List))[1] != long {
+		t.Errorf("Expected long string to round trip: %v", err)
+	}
+
+	// as are bytes
+	b, err = EncodeWith([]byte(long), EncodeOptions{Protocol: 4, Memo: MemoPython3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "8004" + "4270110100" + hex.EncodeToString([]byte(long)) + "942e"; hex.EncodeToString(b) != want {
+		t.Errorf("unexpected framing of long bytes: %.80x...", b)
+	}
+	for _, protocol := range []int{2, 4} {
+		b, _ := EncodeWith([]byte("r\xffw"), EncodeOptions{Protocol: protocol})
+		if v, err := Decode(b); err != nil || !bytes.Equal(v.([]byte), []byte("r\xffw")) {
+			t.Errorf("protocol %d: Expected bytes to round trip, got %#v %v", protocol, v, err)
+		}
+	}
+
+	if _, err := EncodeWith("x", EncodeOptions{Protocol: 3}); err == nil {
+		t.Errorf("Expected error for unsupported protocol")
+	}
+}
+
+func TestEncodeDatetime(t *testing.T) {
+	naive := time.Date(2024, 1, 2, 3, 4, 5, 678901999, time.Local)
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	est := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*3600))
+	// expected encodings are python 3's pickle.dumps(v, protocol)
+	for _, tc := range []struct {
+		v        interface{}
+		protocol int
+		memo     Memo
+		want     string
+	}{
+		{naive, 2, MemoPython3, "8002636461746574696d650a6461746574696d650a7100635f636f646563730a656e636f64650a7101580c00000007c3a801020304050a5bc3b5710258060000006c6174696e3171038671045271058571065271072e"},
+		{naive, 4, MemoPython3, "8004952a000000000000008c086461746574696d65948c086461746574696d65949394430a07e801020304050a5bf594859452942e"},
+		{utc, 2, MemoPython3, "8002636461746574696d650a6461746574696d650a7100635f636f646563730a656e636f64650a7101580b00000007c3a80102030405000000710258060000006c6174696e317103867104527105636461746574696d650a74696d657a6f6e650a7106636461746574696d650a74696d6564656c74610a71074b004b004b0087710852710985710a52710b86710c52710d2e"},
+		{utc, 4, MemoPython3, "80049557000000000000008c086461746574696d65948c086461746574696d65949394430a07e801020304050000009468008c0874696d657a6f6e6594939468008c0974696d6564656c74619493944b004b004b008794529485945294869452942e"},
+		{est, 2, MemoPython3, "8002636461746574696d650a6461746574696d650a7100635f636f646563730a656e636f64650a7101580b00000007c3a80102030405000000710258060000006c6174696e317103867104527105636461746574696d650a74696d657a6f6e650a7106636461746574696d650a74696d6564656c74610a71074affffffff4a300b01004b0087710852710985710a52710b86710c52710d2e"},
+		{est, 4, MemoPython3, "8004955d000000000000008c086461746574696d65948c086461746574696d65949394430a07e801020304050000009468008c0874696d657a6f6e6594939468008c0974696d6564656c74619493944affffffff4a300b01004b008794529485945294869452942e"},
+		{est, 2, MemoNone, "8002636461746574696d650a6461746574696d650a635f636f646563730a656e636f64650a580b00000007c3a8010203040500000058060000006c6174696e318652636461746574696d650a74696d657a6f6e650a636461746574696d650a74696d6564656c74610a4affffffff4a300b01004b008752855286522e"},
+		{[]interface{}{naive, time.Date(1999, 12, 31, 0, 0, 0, 0, time.Local)}, 4, MemoPython3, "80049541000000000000005d94288c086461746574696d65948c086461746574696d65949394430a07e801020304050a5bf594859452946803430a07cf0c1f0000000000009485945294652e"},
+	} {
+		b, err := EncodeWith(tc.v, EncodeOptions{Protocol: tc.protocol, Memo: tc.memo})
+		if err != nil {
+			t.Errorf("EncodeWith(%v, %d): %v", tc.v, tc.protocol, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tc.want {
+			t.Errorf("EncodeWith(%v, %d) = %s, want %s", tc.v, tc.protocol, got, tc.want)
+		}
+	}
+
+	for _, v := range []time.Time{naive.Truncate(time.Microsecond), utc, est} {
+		b, _ := Encode(v)
+		if got, err := Decode(b); err != nil || !got.(time.Time).Equal(v) {
+			t.Errorf("Expected %v to round trip got %v %v", v, got, err)
+		}
+	}
+	if _, err := Encode(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for year 0 got %v", err)
+	}
+}
+
+func TestEncodeDecimal(t *testing.T) {
+	// expected encodings are python 3's pickle.dumps(Decimal("-12.50"), protocol)
+	for protocol, want := range map[int]string{
+		2: "\x80\x02cdecimal\nDecimal\nq\x00X\x06\x00\x00\x00-12.50q\x01\x85q\x02Rq\x03.",
+		4: "\x80\x04\x95$\x00\x00\x00\x00\x00\x00\x00\x8c\x07decimal\x94\x8c\x07Decimal\x94\x93\x94\x8c\x06-12.50\x94\x85\x94R\x94.",
+	} {
+		b, err := EncodeWith(Decimal("-12.50"), EncodeOptions{Protocol: protocol, Memo: MemoPython3})
+		if err != nil || string(b) != want {
+			t.Errorf("protocol %d: got %q %v, want %q", protocol, b, err, want)
+		}
+	}
+	if r, ok := Decimal("-12.50").Rat(); !ok || r.Cmp(big.NewRat(-25, 2)) != 0 {
+		t.Errorf("unexpected Rat %v %v", r, ok)
+	}
+	if r, ok := Decimal("1E+3").Rat(); !ok || r.Cmp(big.NewRat(1000, 1)) != 0 {
+		t.Errorf("unexpected Rat %v %v", r, ok)
+	}
+	if _, ok := Decimal("NaN").Rat(); ok {
+		t.Error("Expected NaN to have no Rat")
+	}
+}
+
+func TestUnicodeHeader(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		protocol int
+		want     string
+	}{
+		{5, 2, "5805000000"},
+		{5, 4, "8c05"},
+		{300, 4, "582c010000"},
+		{1 << 32, 4, "8d0000000001000000"},
+	} {
+		h, err := unicodeHeader(tc.n, tc.protocol)
+		if err != nil || hex.EncodeToString(h) != tc.want {
+			t.Errorf("unicodeHeader(%d, %d) = %x %v, want %s", tc.n, tc.protocol, h, err, tc.want)
+		}
+	}
+	if _, err := unicodeHeader(1<<32, 2); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for a 4GiB string in protocol 2, got %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	b, err := Encode([]interface{}{"a", 1, map[string]interface{}{"k": []interface{}{true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(b) {
+		t.Errorf("Expected decoded value to re-encode identically: %x != %x", again, b)
+	}
+}
+
+func TestEncodeHelpers(t *testing.T) {
+	if got := hex.EncodeToString(EncodeString("héllo")); got != "8002580600000068c3a96c6c6f2e" {
+		t.Errorf("unexpected EncodeString %s", got)
+	}
+	b, err := EncodeDict(map[string]interface{}{"b": 2, "a": nil})
+	if err != nil || hex.EncodeToString(b) != "80027d285801000000614e5801000000624b02752e" {
+		t.Errorf("unexpected EncodeDict %x %v", b, err)
+	}
+	if _, err := Encode(struct{}{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType, got: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package picklecompat
+
+// Opcode describes a pickle opcode
+type Opcode struct {
+	Code byte
+	Name string
+	// Protocol is the pickle protocol that introduced the opcode
+	Protocol int
+	// Encoded reports whether Encode emits the opcode; all listed opcodes decode
+	Encoded bool
+}
+
+// SupportedOpcodes returns the opcodes Decode understands in opcode order.
+// Persistent ids, the extension registry and out of band buffers aren't
+// supported since memcached values never use them.
+func SupportedOpcodes() []Opcode {
+	return append([]Opcode(nil), opcodes...)
+}
+
+var opcodes = []Opcode{
+	{'(', "MARK", 0, true},
+	{')', "EMPTY_TUPLE", 1, true},
+	{'.', "STOP", 0, true},
+	{'0', "POP", 0, false},
+	{'1', "POP_MARK", 1, false},
+	{'2', "DUP", 0, false},
+	{'F', "FLOAT", 0, false},
+	{'G', "BINFLOAT", 1, true},
+	{'I', "INT", 0, false},
+	{'J', "BININT", 1, true},
+	{'K', "BININT1", 1, true},
+	{'L', "LONG", 0, false},
+	{'M', "BININT2", 1, true},
+	{'N', "NONE", 0, true},
+	{'R', "REDUCE", 0, true},
+	{'S', "STRING", 0, false},
+	{'T', "BINSTRING", 1, false},
+	{'U', "SHORT_BINSTRING", 1, false},
+	{'V', "UNICODE", 0, false},
+	{'X', "BINUNICODE", 1, true},
+	{'a', "APPEND", 0, true},
+	{'b', "BUILD", 0, false},
+	{'c', "GLOBAL", 0, true},
+	{'d', "DICT", 0, false},
+	{'e', "APPENDS", 1, true},
+	{'g', "GET", 0, false},
+	{'h', "BINGET", 1, true},
+	{'i', "INST", 0, false},
+	{'j', "LONG_BINGET", 1, true},
+	{'l', "LIST", 0, false},
+	{'o', "OBJ", 1, false},
+	{'p', "PUT", 0, false},
+	{'q', "BINPUT", 1, true},
+	{'r', "LONG_BINPUT", 1, true},
+	{'s', "SETITEM", 0, true},
+	{'t', "TUPLE", 0, true},
+	{'u', "SETITEMS", 1, true},
+	{']', "EMPTY_LIST", 1, true},
+	{'}', "EMPTY_DICT", 1, true},
+	{'B', "BINBYTES", 3, true},
+	{'C', "SHORT_BINBYTES", 3, true},
+	{0x80, "PROTO", 2, true},
+	{0x81, "NEWOBJ", 2, false},
+	{0x85, "TUPLE1", 2, true},
+	{0x86, "TUPLE2", 2, true},
+	{0x87, "TUPLE3", 2, true},
+	{0x88, "NEWTRUE", 2, true},
+	{0x89, "NEWFALSE", 2, true},
+	{0x8a, "LONG1", 2, true},
+	{0x8b, "LONG4", 2, true},
+	{0x8c, "SHORT_BINUNICODE", 4, true},
+	{0x8d, "BINUNICODE8", 4, true},
+	{0x8e, "BINBYTES8", 4, true},
+	{0x8f, "EMPTY_SET", 4, false},
+	{0x90, "ADDITEMS", 4, false},
+	{0x91, "FROZENSET", 4, false},
+	{0x92, "NEWOBJ_EX", 4, false},
+	{0x93, "STACK_GLOBAL", 4, true},
+	{0x94, "MEMOIZE", 4, true},
+	{0x95, "FRAME", 4, true},
+	{0x96, "BYTEARRAY8", 5, false},
+}
@@ -0,0 +1,105 @@
+package picklecompat
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// decodeScalar decodes, without the unpickler's allocations, the pickles
+// holding a single None, bool, small int, float or string that make up most
+// cached values, e.g. "\x80\x02X\x05\x00\x00\x00helloq\x01." for u"hello".
+// ok is false for any other pickle, which Decode hands to gopickle.
+func decodeScalar(b []byte) (v interface{}, ok bool) {
+	i := 0
+	if len(b) >= 2 && b[0] == 0x80 {
+		if b[1] > 5 {
+			return nil, false
+		}
+		i = 2
+	}
+	if len(b)-i >= 9 && b[i] == 0x95 {
+		if binary.LittleEndian.Uint64(b[i+1:]) > uint64(len(b)-i-9) {
+			return nil, false
+		}
+		i += 9
+	}
+	if i >= len(b) {
+		return nil, false
+	}
+	op := b[i]
+	i++
+	arg := func(n int) []byte {
+		if n < 0 || len(b)-i < n {
+			return nil
+		}
+		a := b[i : i+n]
+		i += n
+		return a
+	}
+	switch op {
+	case 'N':
+		v = nil
+	case 0x88:
+		v = true
+	case 0x89:
+		v = false
+	case 'K':
+		a := arg(1)
+		if a == nil {
+			return nil, false
+		}
+		v = int(a[0])
+	case 'M':
+		a := arg(2)
+		if a == nil {
+			return nil, false
+		}
+		v = int(binary.LittleEndian.Uint16(a))
+	case 'J':
+		a := arg(4)
+		if a == nil {
+			return nil, false
+		}
+		v = int(int32(binary.LittleEndian.Uint32(a)))
+	case 'G':
+		a := arg(8)
+		if a == nil {
+			return nil, false
+		}
+		v = math.Float64frombits(binary.BigEndian.Uint64(a))
+	case 0x8c, 'U':
+		a := arg(1)
+		if a == nil {
+			return nil, false
+		}
+		s := arg(int(a[0]))
+		if s == nil {
+			return nil, false
+		}
+		v = string(s)
+	case 'X', 'T':
+		a := arg(4)
+		if a == nil {
+			return nil, false
+		}
+		s := arg(int(int32(binary.LittleEndian.Uint32(a))))
+		if s == nil {
+			return nil, false
+		}
+		v = string(s)
+	default:
+		return nil, false
+	}
+	switch {
+	case i < len(b) && b[i] == 0x94: // MEMOIZE
+		i++
+	case i < len(b) && b[i] == 'q': // BINPUT
+		i += 2
+	case i < len(b) && b[i] == 'r': // LONG_BINPUT
+		i += 5
+	}
+	if i != len(b)-1 || b[i] != '.' {
+		return nil, false
+	}
+	return v, true
+}
@@ -0,0 +1,147 @@
+package picklecompat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// rewriteText returns b with the STRING and UNICODE opcodes of protocol 0
+// pickles, which gopickle pushes without unescaping, replaced by BINSTRING and
+// BINUNICODE opcodes holding the decoded value. FRAMEs are dropped since the
+// rewrite changes their length. b is returned as is when it has neither opcode.
+// Lengths must already have been checked.
+func rewriteText(b []byte) []byte {
+	text := false
+	for i := 0; i < len(b) && b[i] != '.'; {
+		text = text || b[i] == 'S' || b[i] == 'V'
+		i, _ = opEnd(b, i)
+	}
+	if !text {
+		return b
+	}
+	out := make([]byte, 0, len(b))
+	i := 0
+	for i < len(b) && b[i] != '.' {
+		op := b[i]
+		end, _ := opEnd(b, i)
+		line := bytes.TrimSuffix(b[i+1:end], []byte("\n"))
+		switch {
+		case op == 0x95:
+		case op == 'V' && end > i+1 && b[end-1] == '\n':
+			out = appendCounted(out, 'X', decodeRawUnicodeEscape(line))
+		case op == 'S' && end > i+1 && b[end-1] == '\n' && isQuoted(line):
+			out = appendCounted(out, 'T', unescapeString(line[1:len(line)-1]))
+		default:
+			out = append(out, b[i:end]...)
+		}
+		i = end
+	}
+	return append(out, b[i:]...)
+}
+
+// appendCounted appends op with a 4 byte length prefixed argument
+func appendCounted(out []byte, op byte, arg []byte) []byte {
+	out = append(out, op)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(arg)))
+	return append(out, arg...)
+}
+
+func isQuoted(b []byte) bool {
+	return len(b) >= 2 && b[0] == b[len(b)-1] && (b[0] == '\'' || b[0] == '"')
+}
+
+// decodeRawUnicodeEscape decodes the raw-unicode-escape argument of UNICODE to
+// UTF-8: \uXXXX and \UXXXXXXXX are escapes and other bytes latin-1 characters.
+// Surrogate pairs (from Python 2 narrow builds) are combined; lone surrogates
+// are kept UTF-8 encoded as Python's surrogatepass would.
+func decodeRawUnicodeEscape(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		r, n := rune(s[i]), 1
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') {
+			digits := 4
+			if s[i+1] == 'U' {
+				digits = 8
+			}
+			if i+2+digits <= len(s) {
+				if v, err := strconv.ParseUint(string(s[i+2:i+2+digits]), 16, 32); err == nil && v <= utf8.MaxRune {
+					r, n = rune(v), 2+digits
+				}
+			}
+		}
+		i += n - 1
+		if !utf16.IsSurrogate(r) {
+			out = utf8.AppendRune(out, r)
+			continue
+		}
+		if r < 0xdc00 && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+			if v, err := strconv.ParseUint(string(s[i+3:i+7]), 16, 32); err == nil {
+				if pair := utf16.DecodeRune(r, rune(v)); pair != utf8.RuneError {
+					out = utf8.AppendRune(out, pair)
+					i += 6
+					continue
+				}
+			}
+		}
+		out = append(out, 0xe0|byte(r>>12), 0x80|byte(r>>6)&0x3f, 0x80|byte(r)&0x3f)
+	}
+	return out
+}
+
+// unescapeString decodes the escapes of a Python 2 str repr, the argument of
+// STRING, to the bytes of the str
+func unescapeString(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case '\\', '\'', '"':
+			out = append(out, c)
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'a':
+			out = append(out, '\a')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'v':
+			out = append(out, '\v')
+		case '\n':
+			// line continuation
+		case 'x':
+			if i+2 < len(s) {
+				if v, err := strconv.ParseUint(string(s[i+1:i+3]), 16, 8); err == nil {
+					out = append(out, byte(v))
+					i += 2
+					continue
+				}
+			}
+			out = append(out, '\\', c)
+		default:
+			if c < '0' || c > '7' {
+				out = append(out, '\\', c)
+				continue
+			}
+			// up to 3 octal digits
+			v, n := 0, 0
+			for ; n < 3 && i+n < len(s) && s[i+n] >= '0' && s[i+n] <= '7'; n++ {
+				v = v*8 + int(s[i+n]-'0')
+			}
+			out = append(out, byte(v))
+			i += n - 1
+		}
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package picklecompat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// ErrInvalidUnicode is returned when a string isn't valid under the UnicodePolicy
+var ErrInvalidUnicode = errors.New("picklecompat: invalid unicode")
+
+// UnicodePolicy selects how strings holding invalid UTF-8 are handled when
+// encoding and decoding pickled str values. Python 3's pickle itself uses
+// surrogatepass, so lone surrogates (UTF-8 encoded as ED A0-BF xx) round trip
+// between Python processes while other invalid bytes fail.
+type UnicodePolicy int
+
+const (
+	// UnicodeRaw copies string bytes unchecked, which may produce pickles Python
+	// can't load
+	UnicodeRaw UnicodePolicy = iota
+	// UnicodeStrict fails on any invalid UTF-8 including encoded surrogates, like
+	// Python's "strict" error handler
+	UnicodeStrict
+	// UnicodeReplace replaces each invalid sequence with U+FFFD the way Python's
+	// "replace" error handler does
+	UnicodeReplace
+	// UnicodeSurrogatePass accepts encoded lone surrogates and fails on other
+	// invalid UTF-8, matching what Python 3's pickle reads and writes
+	UnicodeSurrogatePass
+)
+
+// DecodeOptions configures DecodeWith
+type DecodeOptions struct {
+	Unicode UnicodePolicy
+}
+
+// DecodeWith is Decode applying opts.Unicode to every decoded str, including
+// those inside lists, tuples and dicts
+func DecodeWith(b []byte, opts DecodeOptions) (interface{}, error) {
+	v, err := Decode(b)
+	if err != nil || opts.Unicode == UnicodeRaw {
+		return v, err
+	}
+	return applyUnicode(v, opts.Unicode)
+}
+
+// applyUnicode applies p to the strings in a decoded value
+func applyUnicode(v interface{}, p UnicodePolicy) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case string:
+		return checkUnicode(v, p)
+	case *types.List:
+		for n := range *v {
+			if (*v)[n], err = applyUnicode((*v)[n], p); err != nil {
+				return nil, err
+			}
+		}
+	case *types.Tuple:
+		for n := range *v {
+			if (*v)[n], err = applyUnicode((*v)[n], p); err != nil {
+				return nil, err
+			}
+		}
+	case *types.Dict:
+		for n := range *v {
+			if (*v)[n].Key, err = applyUnicode((*v)[n].Key, p); err != nil {
+				return nil, err
+			}
+			if (*v)[n].Value, err = applyUnicode((*v)[n].Value, p); err != nil {
+				return nil, err
+			}
+		}
+	case *types.Set:
+		s := types.NewSet()
+		for e := range *v {
+			if e, err = applyUnicode(e, p); err != nil {
+				return nil, err
+			}
+			s.Add(e)
+		}
+		return s, nil
+	case *types.FrozenSet:
+		var l []interface{}
+		for e := range *v {
+			if e, err = applyUnicode(e, p); err != nil {
+				return nil, err
+			}
+			l = append(l, e)
+		}
+		return types.NewFrozenSetFromSlice(l), nil
+	}
+	return v, nil
+}
+
+// checkUnicode returns s made valid (or an error) under p
+func checkUnicode(s string, p UnicodePolicy) (string, error) {
+	if p == UnicodeRaw || utf8.ValidString(s) {
+		return s, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r != utf8.RuneError || size > 1 {
+			out.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		if p == UnicodeSurrogatePass && isSurrogate(s[i:]) {
+			out.WriteString(s[i : i+3])
+			i += 3
+			continue
+		}
+		if p != UnicodeReplace {
+			return "", fmt.Errorf("%w: byte 0x%02x at offset %d", ErrInvalidUnicode, s[i], i)
+		}
+		out.WriteRune(utf8.RuneError)
+		i += maximalSubpart(s[i:])
+	}
+	return out.String(), nil
+}
+
+// isSurrogate reports whether s starts with a UTF-8 encoded surrogate (U+D800-U+DFFF)
+func isSurrogate(s string) bool {
+	return len(s) >= 3 && s[0] == 0xed && s[1] >= 0xa0 && s[1] <= 0xbf && s[2] >= 0x80 && s[2] <= 0xbf
+}
+
+// maximalSubpart returns the length of the invalid sequence at the start of s
+// that Python (following the Unicode standard) replaces with a single U+FFFD:
+// the lead byte plus any continuation bytes valid for it
+func maximalSubpart(s string) int {
+	var need int
+	lo, hi := byte(0x80), byte(0xbf)
+	switch b := s[0]; {
+	case b >= 0xc2 && b <= 0xdf:
+		need = 1
+	case b >= 0xe0 && b <= 0xef:
+		need = 2
+		if b == 0xe0 {
+			lo = 0xa0
+		} else if b == 0xed {
+			hi = 0x9f
+		}
+	case b >= 0xf0 && b <= 0xf4:
+		need = 3
+		if b == 0xf0 {
+			lo = 0x90
+		} else if b == 0xf4 {
+			hi = 0x8f
+		}
+	default:
+		return 1
+	}
+	n := 1
+	for ; n <= need && n < len(s); n++ {
+		if s[n] < lo || s[n] > hi {
+			break
+		}
+		lo, hi = 0x80, 0xbf
+	}
+	return n
+}
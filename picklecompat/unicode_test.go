@@ -0,0 +1,80 @@
+package picklecompat
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+func TestCheckUnicode(t *testing.T) {
+	// expected values are python's bytes.decode("utf-8", "replace") and
+	// bytes.decode("utf-8", "surrogatepass"), hex encoded as utf-8
+	for _, tc := range []struct {
+		in, replace, surrogatepass string
+	}{
+		{"ok\xed\xa0\x80x", "6f6befbfbdefbfbdefbfbd78", "6f6beda08078"},
+		{"a\xe2\x82b", "61efbfbd62", ""},
+		{"\xf0\x9f\x98", "efbfbd", ""},
+		{"\xff\xc0\x80", "efbfbdefbfbdefbfbd", ""},
+		{"\xf4\x90\x80\x80", "efbfbdefbfbdefbfbdefbfbd", ""},
+		{"\xe0\x80\xaf", "efbfbdefbfbdefbfbd", ""},
+	} {
+		if got, err := checkUnicode(tc.in, UnicodeReplace); err != nil || hex.EncodeToString([]byte(got)) != tc.replace {
+			t.Errorf("replace %q = %x %v, want %s", tc.in, got, err, tc.replace)
+		}
+		got, err := checkUnicode(tc.in, UnicodeSurrogatePass)
+		if tc.surrogatepass == "" {
+			if !errors.Is(err, ErrInvalidUnicode) {
+				t.Errorf("surrogatepass %q: expected ErrInvalidUnicode, got %x %v", tc.in, got, err)
+			}
+		} else if err != nil || hex.EncodeToString([]byte(got)) != tc.surrogatepass {
+			t.Errorf("surrogatepass %q = %x %v, want %s", tc.in, got, err, tc.surrogatepass)
+		}
+		if _, err := checkUnicode(tc.in, UnicodeStrict); !errors.Is(err, ErrInvalidUnicode) {
+			t.Errorf("strict %q: expected ErrInvalidUnicode, got %v", tc.in, err)
+		}
+		if got, err := checkUnicode(tc.in, UnicodeRaw); err != nil || got != tc.in {
+			t.Errorf("raw %q = %q %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestUnicodePolicy(t *testing.T) {
+	bad := []interface{}{"ok", "a\xffb", map[string]interface{}{"k\xff": "v"}}
+	if _, err := EncodeWith(bad, EncodeOptions{Unicode: UnicodeStrict}); !errors.Is(err, ErrInvalidUnicode) {
+		t.Errorf("Expected ErrInvalidUnicode encoding, got %v", err)
+	}
+	b, err := Encode(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeWith(b, DecodeOptions{Unicode: UnicodeSurrogatePass}); !errors.Is(err, ErrInvalidUnicode) {
+		t.Errorf("Expected ErrInvalidUnicode decoding, got %v", err)
+	}
+	v, err := DecodeWith(b, DecodeOptions{Unicode: UnicodeReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := *v.(*types.List)
+	if l[1] != "a�b" {
+		t.Errorf("Expected replaced string, got %q", l[1])
+	}
+	if k := (*l[2].(*types.Dict))[0].Key; k != "k�" {
+		t.Errorf("Expected replaced dict key, got %q", k)
+	}
+
+	// a lone surrogate written by python 3: pickle.dumps("\ud800", 2)
+	py, _ := hex.DecodeString("80025803000000eda08071002e")
+	if _, err := DecodeWith(py, DecodeOptions{Unicode: UnicodeStrict}); !errors.Is(err, ErrInvalidUnicode) {
+		t.Errorf("Expected strict to reject a surrogate, got %v", err)
+	}
+	if s, err := DecodeWith(py, DecodeOptions{Unicode: UnicodeSurrogatePass}); err != nil || s != "\xed\xa0\x80" {
+		t.Errorf("Expected surrogate to pass, got %q %v", s, err)
+	}
+	again, err := EncodeWith("\xed\xa0\x80", EncodeOptions{Memo: MemoPython3, Unicode: UnicodeSurrogatePass})
+	if err != nil || string(again) != string(py) {
+		t.Errorf("Expected python's bytes, got %x %v", again, err)
+	}
+}
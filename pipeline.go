@@ -0,0 +1,223 @@
+package memcache
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// Stage is one step of a Pipeline transforming an encoded value and its flags.
+// Encode may set flag bits so Decode (and other clients) can recognize its
+// output; Decode is called for every value read and must pass through values
+// it didn't produce.
+type Stage interface {
+	Encode(value []byte, flags uint32) ([]byte, uint32, error)
+	Decode(value []byte, flags uint32) ([]byte, uint32, error)
+}
+
+// KeyedStage is a Stage whose output depends on the key of the item, e.g. to
+// bind values to their keys. A Pipeline calls EncodeKey and DecodeKey with the
+// key passed to the typed setters and getters in place of Encode and Decode.
+type KeyedStage interface {
+	Stage
+	EncodeKey(key string, value []byte, flags uint32) ([]byte, uint32, error)
+	DecodeKey(key string, value []byte, flags uint32) ([]byte, uint32, error)
+}
+
+// Pipeline is the sequence applied to values written and read by the typed
+// setters and getters. The order is fixed:
+//
+//  1. serialization (Serialize) assigns the pylibmc type flags
+//  2. Stages, in order, e.g. field level tokenization
+//  3. Compress, e.g. ZlibStage setting FLAG_ZLIB
+//  4. Encrypt
+//
+// Reads undo the stages in reverse order then deserialize. Compressing before
+// encrypting matters since encrypted bytes don't compress. Get and Set work on
+// items as stored and don't go through the pipeline.
+type Pipeline struct {
+	Stages   []Stage
+	Compress Stage
+	Encrypt  Stage
+
+	protocol int            // Options.PickleProtocol
+	codecs   *codecRegistry // Client.RegisterCodec
+}
+
+// ordered returns the byte stages in encode order
+func (p *Pipeline) ordered() []Stage {
+	stages := make([]Stage, 0, len(p.Stages)+2)
+	stages = append(stages, p.Stages...)
+	if p.Compress != nil {
+		stages = append(stages, p.Compress)
+	}
+	if p.Encrypt != nil {
+		stages = append(stages, p.Encrypt)
+	}
+	return stages
+}
+
+// empty reports whether the pipeline only serializes
+func (p *Pipeline) empty() bool {
+	return len(p.Stages) == 0 && p.Compress == nil && p.Encrypt == nil
+}
+
+// Encode serializes v and runs the stages returning the item to store under key
+func (p *Pipeline) Encode(key string, v interface{}) (*memcache.Item, error) {
+	value, flags, err := p.serialize(v)
+	if err != nil {
+		return nil, err
+	}
+	return p.encode(key, value, flags)
+}
+
+// encode runs the stages over a serialized value
+func (p *Pipeline) encode(key string, value []byte, flags uint32) (*memcache.Item, error) {
+	var err error
+	for _, s := range p.ordered() {
+		if value, flags, err = encodeStage(s, key, value, flags); err != nil {
+			return nil, err
+		}
+	}
+	return &memcache.Item{Key: key, Value: value, Flags: flags}, nil
+}
+
+// DecodeItem undoes the stages returning a copy of i holding the serialized value
+func (p *Pipeline) DecodeItem(i *memcache.Item) (*memcache.Item, error) {
+	stages := p.ordered()
+	value, flags := i.Value, i.Flags
+	for n := len(stages) - 1; n >= 0; n-- {
+		var err error
+		if value, flags, err = decodeStage(stages[n], i.Key, value, flags); err != nil {
+			return nil, err
+		}
+	}
+	cp := *i
+	cp.Value, cp.Flags = value, flags
+	return &cp, nil
+}
+
+// encodeStage runs s over a value stored under key
+func encodeStage(s Stage, key string, value []byte, flags uint32) ([]byte, uint32, error) {
+	if ks, ok := s.(KeyedStage); ok {
+		return ks.EncodeKey(key, value, flags)
+	}
+	return s.Encode(value, flags)
+}
+
+// decodeStage undoes s for a value read under key
+func decodeStage(s Stage, key string, value []byte, flags uint32) ([]byte, uint32, error) {
+	if ks, ok := s.(KeyedStage); ok {
+		return ks.DecodeKey(key, value, flags)
+	}
+	return s.Decode(value, flags)
+}
+
+// Decode undoes the stages and deserializes the value of i
+func (p *Pipeline) Decode(i *memcache.Item) (interface{}, error) {
+	d, err := p.DecodeItem(i)
+	if err != nil {
+		return nil, err
+	}
+	return p.deserialize(d.Value, d.Flags)
+}
+
+// serialize is serialize offering values pylibmc pickles to the codecs first
+func (p *Pipeline) serialize(v interface{}) ([]byte, uint32, error) {
+	switch v.(type) {
+	case string, []byte, bool, int, int64, *big.Int:
+	default:
+		if value, flags, ok, err := p.codecs.encode(v); ok {
+			return value, flags, err
+		}
+	}
+	return serialize(v, p.protocol)
+}
+
+// deserialize is Deserialize decoding values of a registered codec with it
+func (p *Pipeline) deserialize(value []byte, flags uint32) (interface{}, error) {
+	if codec := p.codecs.match(flags); codec != nil {
+		return codec.Decode(value)
+	}
+	return Deserialize(value, flags)
+}
+
+// Serialize encodes v the way pylibmc would: strings and []byte as is, integers
+// as FLAG_INTEGER, bools as FLAG_BOOL and anything else pickled
+func Serialize(v interface{}) ([]byte, uint32, error) {
+	return serialize(v, 0)
+}
+
+// serialize is Serialize pickling with protocol
+func serialize(v interface{}, protocol int) ([]byte, uint32, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), FLAG_NONE, nil
+	case []byte:
+		return v, FLAG_NONE, nil
+	case bool:
+		return BoolItem("", v).Value, FLAG_BOOL, nil
+	case int:
+		return formatInt64(int64(v)), FLAG_INTEGER, nil
+	case int64:
+		return formatInt64(v), FLAG_INTEGER, nil
+	case *big.Int:
+		return v.Append(nil, 10), FLAG_LONG, nil
+	}
+	b, err := picklecompat.EncodeWith(v, picklecompat.EncodeOptions{Protocol: protocol})
+	return b, FLAG_PICKLE, err
+}
+
+// Deserialize decodes a serialized value according to its pylibmc type flags,
+// inflating values with FLAG_ZLIB set. Values written by SetJSON are decoded
+// as encoding/json decodes into an interface{}.
+func Deserialize(value []byte, flags uint32) (interface{}, error) {
+	if flags&FLAG_ZLIB != 0 {
+		var err error
+		if value, flags, err = (ZlibStage{}).Decode(value, flags); err != nil {
+			return nil, err
+		}
+	}
+	switch flags {
+	case FLAG_NONE:
+		if looksPickled(value) {
+			return unpickle(value)
+		}
+		return string(value), nil
+	case FLAG_PICKLE:
+		return unpickle(value)
+	case FLAG_INTEGER, FLAG_LONG:
+		return deserializeInt(value)
+	case FLAG_BOOL:
+		return (&Item{&memcache.Item{Value: value, Flags: flags}}).Bool()
+	case FLAG_JSON:
+		var v interface{}
+		err := json.Unmarshal(value, &v)
+		return v, err
+	}
+	return nil, InvalidType
+}
+
+// DecodeItems deserializes items obtained other than through a Client, e.g.
+// from a dump or test fixtures, with the flag and pickle handling of
+// Deserialize. Values that fail to decode are reported in errs under their
+// key rather than in values.
+func DecodeItems(items map[string]*memcache.Item) (values map[string]interface{}, errs map[string]error) {
+	values = make(map[string]interface{}, len(items))
+	errs = make(map[string]error)
+	for k, i := range items {
+		if i == nil {
+			errs[k] = memcache.ErrCacheMiss
+			continue
+		}
+		v, err := Deserialize(i.Value, i.Flags)
+		if err != nil {
+			errs[k] = err
+			continue
+		}
+		values[k] = v
+	}
+	return values, errs
+}
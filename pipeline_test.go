@@ -0,0 +1,129 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const flagReversed uint32 = 1 << 8
+
+// reverseStage is a toy Stage reversing the serialized bytes
+type reverseStage struct{}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for n, c := range b {
+		r[len(b)-1-n] = c
+	}
+	return r
+}
+
+func (reverseStage) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return reverse(value), flags | flagReversed, nil
+}
+
+func (reverseStage) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&flagReversed == 0 {
+		return value, flags, nil
+	}
+	return reverse(value), flags &^ flagReversed, nil
+}
+
+// tagStage appends its tag so stage ordering is visible
+type tagStage string
+
+func (t tagStage) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return append(append([]byte{}, value...), t...), flags, nil
+}
+
+func (t tagStage) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return value[:len(value)-len(t)], flags, nil
+}
+
+func TestPipelineOrder(t *testing.T) {
+	p := &Pipeline{Encrypt: tagStage("E"), Compress: tagStage("C"), Stages: []Stage{tagStage("1"), tagStage("2")}}
+	i, err := p.Encode("k", "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(i.Value) != "v12CE" || i.Flags != FLAG_NONE {
+		t.Errorf("unexpected encoding %q %d", i.Value, i.Flags)
+	}
+	if v, err := p.Decode(i); err != nil || v != "v" {
+		t.Errorf("unexpected decoding %v %v", v, err)
+	}
+}
+
+func TestClientPipeline(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{
+		Pipeline: Pipeline{Stages: []Stage{reverseStage{}}},
+	})
+	if err := mc.SetString("pipeline_s", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := mc.Get("pipeline_s")
+	if err != nil || string(raw.Value) != "cba" || raw.Flags != flagReversed {
+		t.Errorf("unexpected stored item %v %v", raw, err)
+	}
+	if s, ok := mc.GetString("pipeline_s"); !ok || s != "abc" {
+		t.Errorf("Expected abc, got: %q %v", s, ok)
+	}
+	mc.SetInt64("pipeline_n", 12)
+	if n, ok := mc.GetInt64("pipeline_n"); !ok || n != 12 {
+		t.Errorf("Expected 12, got: %d %v", n, ok)
+	}
+	// values written without the stage still decode
+	mc.Set(StringItem("pipeline_plain", "plain"))
+	if s, ok := mc.GetString("pipeline_plain"); !ok || s != "plain" {
+		t.Errorf("Expected plain, got: %q %v", s, ok)
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	for _, tc := range []struct {
+		v     interface{}
+		value string
+		flags uint32
+	}{
+		{"s", "s", FLAG_NONE},
+		{[]byte("b"), "b", FLAG_NONE},
+		{true, "1", FLAG_BOOL},
+		{int64(-3), "-3", FLAG_INTEGER},
+		{7, "7", FLAG_INTEGER},
+		{nil, "\x80\x02N.", FLAG_PICKLE},
+	} {
+		value, flags, err := Serialize(tc.v)
+		if err != nil || string(value) != tc.value || flags != tc.flags {
+			t.Errorf("Serialize(%v) = %q %d %v", tc.v, value, flags, err)
+			continue
+		}
+		if _, err := Deserialize(value, flags); err != nil {
+			t.Errorf("Deserialize(%q, %d): %v", value, flags, err)
+		}
+	}
+	if _, err := Deserialize([]byte("x"), 1<<9); err != InvalidType {
+		t.Errorf("Expected InvalidType, got: %v", err)
+	}
+}
+
+func TestDecodeItems(t *testing.T) {
+	values, errs := DecodeItems(map[string]*memcache.Item{
+		"str":     UnicodeItem("str", "héllo"),
+		"int":     Int64Item("int", 7),
+		"raw":     {Value: []byte("raw")},
+		"pickled": {Value: []byte("\x80\x02]q\x00K\x01a."), Flags: FLAG_PICKLE},
+		"bad":     {Value: []byte("x"), Flags: 1 << 9},
+		"nil":     nil,
+	})
+	if len(values) != 4 || values["str"] != "héllo" || values["int"] != int64(7) || values["raw"] != "raw" {
+		t.Errorf("unexpected values %#v", values)
+	}
+	if fmt.Sprint(values["pickled"]) != "[1]" {
+		t.Errorf("unexpected list %#v", values["pickled"])
+	}
+	if len(errs) != 2 || errs["bad"] != InvalidType || errs["nil"] != memcache.ErrCacheMiss {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
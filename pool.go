@@ -0,0 +1,120 @@
+package memcache
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// PoolStats describes the connections this client has made to one server
+type PoolStats struct {
+	Addr string `json:"addr"`
+	// Open is the number of connections currently open (idle in the pool or in use)
+	Open int64 `json:"open"`
+	// Dials and DialErrors count connection attempts since the client was created
+	Dials      int64 `json:"dials"`
+	DialErrors int64 `json:"dial_errors"`
+}
+
+// PoolStats returns connection counts for every server that has been dialed,
+// ordered by address
+func (c *Client) PoolStats() []PoolStats {
+	return c.conns.snapshot()
+}
+
+// connTracker counts connections per server by wrapping the connections returned
+// from dial
+type connTracker struct {
+	mu      sync.Mutex
+	servers map[string]*PoolStats
+	open    map[string]map[*trackedConn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		servers: make(map[string]*PoolStats),
+		open:    make(map[string]map[*trackedConn]struct{}),
+	}
+}
+
+func (t *connTracker) server(address string) *PoolStats {
+	s, ok := t.servers[address]
+	if !ok {
+		s = &PoolStats{Addr: address}
+		t.servers[address] = s
+	}
+	return s
+}
+
+// dialed records a dial to address, wrapping a successful connection so its close is counted
+func (t *connTracker) dialed(address string, nc net.Conn, err error) net.Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.server(address)
+	s.Dials++
+	if err != nil {
+		s.DialErrors++
+		return nc
+	}
+	s.Open++
+	tc := &trackedConn{Conn: nc}
+	tc.onClose = func() {
+		t.mu.Lock()
+		s.Open--
+		delete(t.open[address], tc)
+		t.mu.Unlock()
+	}
+	if t.open[address] == nil {
+		t.open[address] = make(map[*trackedConn]struct{})
+	}
+	t.open[address][tc] = struct{}{}
+	return tc
+}
+
+// closeStale closes the open connections to address whose remote IP isn't one
+// of ips. Operations in flight on them fail, as they would have against a
+// server that moved.
+func (t *connTracker) closeStale(address string, ips []string) {
+	t.mu.Lock()
+	var stale []*trackedConn
+	for tc := range t.open[address] {
+		host, _, err := net.SplitHostPort(tc.RemoteAddr().String())
+		if err != nil {
+			continue
+		}
+		keep := false
+		for _, ip := range ips {
+			keep = keep || net.ParseIP(host).Equal(net.ParseIP(ip))
+		}
+		if !keep {
+			stale = append(stale, tc)
+		}
+	}
+	t.mu.Unlock()
+	for _, tc := range stale {
+		tc.Close()
+	}
+}
+
+func (t *connTracker) snapshot() []PoolStats {
+	t.mu.Lock()
+	stats := make([]PoolStats, 0, len(t.servers))
+	for _, s := range t.servers {
+		stats = append(stats, *s)
+	}
+	t.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Addr < stats[j].Addr })
+	return stats
+}
+
+// trackedConn calls onClose the first time the connection is closed
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
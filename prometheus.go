@@ -0,0 +1,192 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPrometheusBuckets are the latency buckets of PrometheusMetrics, in seconds
+var DefaultPrometheusBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// PrometheusMetrics is a Metrics collector serving what it receives in the
+// Prometheus text format, for scraping without a Prometheus client library:
+//
+//	m := &memcache.PrometheusMetrics{}
+//	mc := memcache.NewClientWithOptions(servers, memcache.Options{Metrics: m, OpMetrics: true})
+//	http.Handle("/metrics", m)
+//
+// Counters are exposed as counters named after the metric with '.' replaced
+// by '_' and a "_total" suffix, e.g. memcache_ops_total{op="get",result="hit",server="..."},
+// and timings as histograms with a "_seconds" suffix. Tags become labels. The
+// zero value is ready to use.
+type PrometheusMetrics struct {
+	// Buckets are the upper bounds of the timing histograms in seconds. nil uses
+	// DefaultPrometheusBuckets. They can't be changed once timings are recorded.
+	Buckets []float64
+
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*promHistogram
+}
+
+var _ Metrics = (*PrometheusMetrics)(nil)
+
+type promHistogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Count adds n to the counter name with tags
+func (p *PrometheusMetrics) Count(name string, n int64, tags map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.counters == nil {
+		p.counters = make(map[string]map[string]float64)
+	}
+	series := p.counters[name]
+	if series == nil {
+		series = make(map[string]float64)
+		p.counters[name] = series
+	}
+	series[promLabels(tags)] += float64(n)
+}
+
+// Timing observes d in the histogram name with tags
+func (p *PrometheusMetrics) Timing(name string, d time.Duration, tags map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.histograms == nil {
+		p.histograms = make(map[string]map[string]*promHistogram)
+	}
+	series := p.histograms[name]
+	if series == nil {
+		series = make(map[string]*promHistogram)
+		p.histograms[name] = series
+	}
+	buckets := p.buckets()
+	labels := promLabels(tags)
+	h := series[labels]
+	if h == nil {
+		h = &promHistogram{counts: make([]uint64, len(buckets)+1)}
+		series[labels] = h
+	}
+	v := d.Seconds()
+	h.counts[sort.SearchFloat64s(buckets, v)]++
+	h.sum += v
+	h.count++
+}
+
+func (p *PrometheusMetrics) buckets() []float64 {
+	if p.Buckets == nil {
+		return DefaultPrometheusBuckets
+	}
+	return p.Buckets
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := bufio.NewWriter(w)
+	p.write(b)
+	b.Flush()
+}
+
+// write writes every series, ordered by name and labels
+func (p *PrometheusMetrics) write(w *bufio.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range sortedKeys(p.counters) {
+		metric := promName(name) + "_total"
+		fmt.Fprintf(w, "# TYPE %s counter\n", metric)
+		series := p.counters[name]
+		for _, labels := range sortedKeys(series) {
+			fmt.Fprintf(w, "%s%s %s\n", metric, wrapLabels(labels), formatFloat(series[labels]))
+		}
+	}
+	buckets := p.buckets()
+	for _, name := range sortedKeys(p.histograms) {
+		metric := promName(name) + "_seconds"
+		fmt.Fprintf(w, "# TYPE %s histogram\n", metric)
+		series := p.histograms[name]
+		for _, labels := range sortedKeys(series) {
+			h := series[labels]
+			var cumulative uint64
+			for n, count := range h.counts {
+				cumulative += count
+				le := math.Inf(1)
+				if n < len(buckets) {
+					le = buckets[n]
+				}
+				bucketLabels := `le="` + formatFloat(le) + `"`
+				if labels != "" {
+					bucketLabels = labels + "," + bucketLabels
+				}
+				fmt.Fprintf(w, "%s_bucket{%s} %d\n", metric, bucketLabels, cumulative)
+			}
+			fmt.Fprintf(w, "%s_sum%s %s\n", metric, wrapLabels(labels), formatFloat(h.sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", metric, wrapLabels(labels), h.count)
+		}
+	}
+}
+
+// promName converts a metric name to a Prometheus metric name
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// promLabels formats tags as Prometheus labels ordered by name, without braces
+func promLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for n, k := range sortedKeys(tags) {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(promName(k))
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
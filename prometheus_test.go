@@ -0,0 +1,38 @@
+package memcache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	p := &PrometheusMetrics{Buckets: []float64{.001, .01}}
+	p.Count("memcache.ops", 2, map[string]string{"op": "get", "result": "hit"})
+	p.Count("memcache.ops", 1, map[string]string{"op": "get", "result": "hit"})
+	p.Count("memcache.stale_served", 1, nil)
+	p.Timing("memcache.op_latency", 500*time.Microsecond, map[string]string{"server": `a"b`})
+	p.Timing("memcache.op_latency", 5*time.Millisecond, map[string]string{"server": `a"b`})
+	p.Timing("memcache.op_latency", time.Second, map[string]string{"server": `a"b`})
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `# TYPE memcache_ops_total counter
+memcache_ops_total{op="get",result="hit"} 3
+# TYPE memcache_stale_served_total counter
+memcache_stale_served_total 1
+# TYPE memcache_op_latency_seconds histogram
+memcache_op_latency_seconds_bucket{server="a\"b",le="0.001"} 1
+memcache_op_latency_seconds_bucket{server="a\"b",le="0.01"} 2
+memcache_op_latency_seconds_bucket{server="a\"b",le="+Inf"} 3
+memcache_op_latency_seconds_sum{server="a\"b"} 1.0055
+memcache_op_latency_seconds_count{server="a\"b"} 3
+`
+	if got := w.Body.String(); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the text format content type, got %q", ct)
+	}
+}
@@ -0,0 +1,116 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ProxyMode selects the memcached proxy, if any, the client talks to
+type ProxyMode int
+
+const (
+	// ProxyNone talks to memcached servers directly, distributing keys as
+	// Options.Distribution selects
+	ProxyNone ProxyMode = iota
+	// ProxyTwemproxy talks to twemproxy (nutcracker), which supports only the
+	// storage and retrieval commands
+	ProxyTwemproxy
+	// ProxyMcrouter talks to mcrouter, which answers stats and version itself
+	// and doesn't route flush_all or lru_crawler
+	ProxyMcrouter
+)
+
+func (m ProxyMode) String() string {
+	switch m {
+	case ProxyNone:
+		return "none"
+	case ProxyTwemproxy:
+		return "twemproxy"
+	case ProxyMcrouter:
+		return "mcrouter"
+	}
+	return fmt.Sprintf("ProxyMode(%d)", int(m))
+}
+
+// ErrProxyUnsupported is returned (wrapped) instead of sending a command the
+// configured proxy doesn't support
+var ErrProxyUnsupported = errors.New("memcache: command not supported by proxy")
+
+// unsupported lists the commands each proxy doesn't pass on to memcached
+var unsupported = map[ProxyMode]map[string]bool{
+	ProxyTwemproxy: {"stats": true, "flush_all": true, "version": true, "verbosity": true, "lru_crawler": true, "mg": true},
+	ProxyMcrouter:  {"stats": true, "flush_all": true, "verbosity": true, "lru_crawler": true},
+}
+
+// proxyCheck returns an error if command can't be sent through proxy m
+func proxyCheck(m ProxyMode, command string) error {
+	verb := command
+	if i := strings.IndexByte(command, ' '); i >= 0 {
+		verb = command[:i]
+	}
+	if unsupported[m][verb] {
+		return fmt.Errorf("%w: %s through %s", ErrProxyUnsupported, verb, m)
+	}
+	return nil
+}
+
+// ProxyError is a SERVER_ERROR reported by a proxy, usually because the
+// backend server for the key is down or timed out
+type ProxyError struct {
+	Proxy   ProxyMode
+	Message string // the text after SERVER_ERROR, e.g. "Connection refused"
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("memcache: %s: %s", e.Proxy, e.Message)
+}
+
+// proxyError converts the errors the underlying client returns for SERVER_ERROR
+// responses to a *ProxyError
+func (c *Client) proxyError(err error) error {
+	if err == nil || c.opts.ProxyMode == ProxyNone {
+		return err
+	}
+	if err == memcache.ErrServerError {
+		return &ProxyError{Proxy: c.opts.ProxyMode, Message: "server error"}
+	}
+	s := err.Error()
+	i := strings.Index(s, "SERVER_ERROR ")
+	if i < 0 {
+		return err
+	}
+	msg := strings.TrimRight(s[i+len("SERVER_ERROR "):], "\"")
+	msg = strings.TrimSuffix(msg, `\r\n`)
+	return &ProxyError{Proxy: c.opts.ProxyMode, Message: msg}
+}
+
+// proxySelector sends every key to one proxy
+type proxySelector struct {
+	addrs []net.Addr
+}
+
+func newProxySelector(addresses []string) *proxySelector {
+	s := &proxySelector{}
+	for _, a := range addresses {
+		s.addrs = append(s.addrs, newHostAddress(a))
+	}
+	return s
+}
+
+func (s *proxySelector) PickServer(string) (net.Addr, error) {
+	if len(s.addrs) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+	return s.addrs[0], nil
+}
+
+func (s *proxySelector) Each(f func(net.Addr) error) error {
+	if len(s.addrs) == 0 {
+		return nil
+	}
+	return f(s.addrs[0])
+}
@@ -0,0 +1,126 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeTwemproxy answers gets with END and stores with a SERVER_ERROR, as
+// twemproxy does when the backend for a key is down
+func fakeTwemproxy(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch strings.Fields(line)[0] {
+					case "get", "gets":
+						c.Write([]byte("END\r\n"))
+					case "set":
+						r.ReadString('\n')
+						c.Write([]byte("SERVER_ERROR Connection refused\r\n"))
+					default:
+						c.Write([]byte("ERROR\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProxyMode(t *testing.T) {
+	addr := fakeTwemproxy(t)
+	mc := NewClientWithOptions([]string{addr}, Options{ProxyMode: ProxyTwemproxy})
+	ctx := context.Background()
+	if err := mc.ValidateConfig(ctx); err != nil {
+		t.Errorf("Expected the proxy to validate, got %v", err)
+	}
+	if _, ok := mc.GetString("proxy"); ok {
+		t.Errorf("Expected a miss")
+	}
+	err := mc.SetString("proxy", "v")
+	var perr *ProxyError
+	if !errors.As(err, &perr) || perr.Proxy != ProxyTwemproxy || perr.Message != "Connection refused" {
+		t.Errorf("Expected a ProxyError, got %#v", err)
+	}
+	a, _ := mc.selector.PickServer("proxy")
+	if _, err := mc.SlabStats(ctx, a); !errors.Is(err, ErrProxyUnsupported) {
+		t.Errorf("Expected ErrProxyUnsupported for stats, got %v", err)
+	}
+	admin, _ := NewAdminClient(mc, AdminOptions{Actor: "test", Allow: []AdminOp{AdminFlushAll}})
+	if err := admin.FlushAll(ctx); !errors.Is(err, ErrProxyUnsupported) {
+		t.Errorf("Expected ErrProxyUnsupported for flush_all, got %v", err)
+	}
+	if problems := CheckConfig(ctx, []string{addr, "127.0.0.1:11212"}, Options{ProxyMode: ProxyTwemproxy}); len(problems) != 1 {
+		t.Errorf("Expected one problem, got %v", problems)
+	}
+}
+
+func TestProxyCheck(t *testing.T) {
+	for _, tc := range []struct {
+		mode    ProxyMode
+		command string
+		ok      bool
+	}{
+		{ProxyNone, "stats slabs", true},
+		{ProxyTwemproxy, "stats slabs", false},
+		{ProxyTwemproxy, "version", false},
+		{ProxyTwemproxy, "get %s", true},
+		{ProxyMcrouter, "version", true},
+		{ProxyMcrouter, "lru_crawler metadump all", false},
+	} {
+		if err := proxyCheck(tc.mode, tc.command); (err == nil) != tc.ok {
+			t.Errorf("%s %q: got %v", tc.mode, tc.command, err)
+		}
+	}
+}
+
+func TestRoutingPrefix(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	addr := s.ln.Addr().String()
+	mc := NewClientWithOptions([]string{addr}, Options{ProxyMode: ProxyMcrouter, RoutingPrefix: "/us-east-1/main/", KeyPrefix: "app:"})
+	direct := NewClient([]string{addr})
+
+	if err := mc.SetString("routed", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := direct.GetString("/us-east-1/main/app:routed"); s != "v" {
+		t.Errorf("Expected the key sent with the routing prefix, got %q", s)
+	}
+	if s, ok := mc.GetString("routed"); !ok || s != "v" {
+		t.Errorf("Expected v, got %q %v", s, ok)
+	}
+	items, err := mc.GetMulti([]string{"routed"})
+	if err != nil || items["routed"] == nil || items["routed"].Key != "routed" {
+		t.Errorf("Expected the item under the caller's key, got %v %v", items, err)
+	}
+
+	twemproxy := NewClientWithOptions([]string{addr}, Options{ProxyMode: ProxyTwemproxy, RoutingPrefix: "/us-east-1/main/"})
+	twemproxy.SetString("unrouted", "v")
+	if s, _ := direct.GetString("unrouted"); s != "v" {
+		t.Errorf("Expected the routing prefix ignored without mcrouter, got %q", s)
+	}
+}
@@ -0,0 +1,279 @@
+package memcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+// PylibmcConfig is a client configuration translated from a pylibmc client by
+// ImportPylibmcConfig
+type PylibmcConfig struct {
+	Servers []string
+	// Weights holds the weight of each server (1 for servers without one)
+	// when the ketama_weighted behavior is set, in which case NewClient
+	// returns a weighted client
+	Weights map[string]uint64
+	Options Options
+	// Timeout is the socket timeout from the pylibmc timeouts. Zero leaves the
+	// gomemcache default.
+	Timeout time.Duration
+	// Unsupported lists the servers and behaviors that couldn't be carried over
+	// or behave differently here; review each before switching traffic
+	Unsupported []ConfigProblem
+}
+
+// pylibmcConfig is the JSON read by ImportPylibmcConfig
+type pylibmcConfig struct {
+	Servers   []string               `json:"servers"`
+	Binary    bool                   `json:"binary"`
+	Behaviors map[string]interface{} `json:"behaviors"`
+}
+
+// ImportPylibmcConfig reads a JSON dump of a pylibmc client's server list and
+// behaviors dict, e.g. from
+//
+//	json.dump({"servers": servers, "binary": False, "behaviors": mc.behaviors}, f)
+//
+// and returns the equivalent client configuration. Behaviors at their pylibmc
+// defaults are ignored; others without an equivalent are listed in
+// Unsupported rather than failing so a whole dump can be reviewed at once.
+// YAML dumps must be converted to JSON first.
+func ImportPylibmcConfig(r io.Reader) (*PylibmcConfig, error) {
+	var in pylibmcConfig
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("memcache: invalid pylibmc config: %w", err)
+	}
+	return importPylibmc(in), nil
+}
+
+// Behaviors is a pylibmc behaviors dict, copied from Python as written, e.g.
+//
+//	Behaviors{"ketama": true, "tcp_nodelay": true, "remove_failed": 5}
+//
+// Values are bools, numbers of any Go type or strings.
+type Behaviors map[string]interface{}
+
+// NewClientWithBehaviors returns a client configured like a pylibmc client of
+// the same servers (which may have pylibmc style weights) and behaviors, as
+// ImportPylibmcConfig would configure it. Behaviors that can't be carried over
+// fail with a *ConfigError listing them.
+func NewClientWithBehaviors(addresses []string, b Behaviors) (*Client, error) {
+	p := importPylibmc(pylibmcConfig{Servers: addresses, Behaviors: b})
+	if len(p.Unsupported) > 0 {
+		return nil, &ConfigError{Problems: p.Unsupported}
+	}
+	return p.NewClient(), nil
+}
+
+// importPylibmc translates a pylibmc configuration. Failed servers are ejected
+// by a FailureCountDetector as libmemcached ejects them.
+func importPylibmc(in pylibmcConfig) *PylibmcConfig {
+	p := &PylibmcConfig{}
+	add := func(server, format string, args ...interface{}) {
+		p.Unsupported = append(p.Unsupported, ConfigProblem{Server: server, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	weighted := pylibmcNumber(in.Behaviors["ketama_weighted"]) != 0
+	if weighted {
+		p.Weights = make(map[string]uint64)
+	}
+	for _, server := range in.Servers {
+		addr, weight, err := parsePylibmcServer(server)
+		if err != nil {
+			add(server, "%s", err)
+			continue
+		}
+		switch {
+		case weighted && weight != "":
+			n, err := strconv.ParseUint(weight, 10, 32)
+			if err != nil {
+				add(server, "invalid weight %s", weight)
+				continue
+			}
+			p.Weights[addr] = n
+		case weighted:
+			p.Weights[addr] = 1
+		case weight != "":
+			add(server, "server weight %s ignored by the non-weighted ketama ring", weight)
+		}
+		p.Servers = append(p.Servers, addr)
+	}
+	if len(p.Servers) == 0 {
+		add("", "no servers configured")
+	}
+	if in.Binary {
+		add("", "binary protocol isn't supported; the text protocol is used")
+	}
+
+	b := in.Behaviors
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// ketama implies consistent distribution with md5 hashing
+	ketama := pylibmcNumber(b["ketama"]) != 0
+	var ejectAfter time.Duration
+	ejectLimit := 0
+	eject := false
+	hashName := ""
+	for _, name := range names {
+		v := b[name]
+		n := pylibmcNumber(v)
+		switch name {
+		case "ketama":
+		case "distribution":
+			switch s := fmt.Sprint(v); s {
+			case "consistent", "consistent_ketama":
+			case "modula":
+				if !ketama {
+					p.Options.Distribution = DistributionModula
+				}
+			default:
+				add("", "distribution %s isn't supported; keys are distributed with the ketama ring", s)
+			}
+		case "hash", "ketama_hash":
+			s := fmt.Sprint(v)
+			hash, ok := ketamacompat.HashByName(s)
+			switch {
+			case s == "default" || s == "md5":
+			case !ok:
+				add("", "%s %s isn't supported; keys are hashed with md5", name, s)
+			case weighted:
+				add("", "%s %s is ignored by the weighted ketama ring, which hashes with md5", name, s)
+			case hashName != "" && hashName != s:
+				add("", "%s %s conflicts with %s; keys are hashed with %s", name, s, hashName, hashName)
+			default:
+				hashName = s
+				p.Options.KetamaHash = hash
+			}
+		case "ketama_weighted":
+		case "connect_timeout", "_poll_timeout":
+			// milliseconds
+			if d := time.Duration(n * float64(time.Millisecond)); d > p.Timeout {
+				p.Timeout = d
+			}
+		case "receive_timeout", "send_timeout":
+			// microseconds
+			if d := time.Duration(n * float64(time.Microsecond)); d > p.Timeout {
+				p.Timeout = d
+			}
+		case "remove_failed", "failure_limit":
+			if n != 0 {
+				eject = true
+			}
+			if int(n) > ejectLimit {
+				ejectLimit = int(n)
+			}
+		case "_auto_eject_hosts":
+			if n != 0 {
+				eject = true
+			}
+		case "dead_timeout", "retry_timeout", "_retry_timeout":
+			// seconds
+			if d := time.Duration(n * float64(time.Second)); d > ejectAfter {
+				ejectAfter = d
+			}
+		case "pickle_protocol":
+			switch proto := int(n); {
+			case proto < 0:
+				// pickle.HIGHEST_PROTOCOL on Python 3.8+
+				p.Options.PickleProtocol = 5
+			case proto == 2 || proto == 4 || proto == 5:
+				p.Options.PickleProtocol = proto
+			default:
+				add("", "pickle_protocol %d isn't supported; protocol 2 is written", proto)
+			}
+		case "cas", "no_block", "tcp_nodelay", "tcp_keepalive", "cache_lookups", "_sort_hosts",
+			"_io_msg_watermark", "_io_bytes_watermark", "_io_key_prefetch",
+			"_socket_send_size", "_socket_recv_size":
+			// no effect on which server a key maps to or how values are stored
+		case "buffer_requests":
+			p.Options.AsyncWrites = n != 0
+		case "num_replicas", "_number_of_replicas":
+			p.Options.Replicas = int(n)
+		case "_noreply", "verify_keys":
+			if n != 0 {
+				add("", "%s isn't supported", name)
+			}
+		default:
+			add("", "unknown behavior %s", name)
+		}
+	}
+	if eject {
+		d := NewFailureCountDetector(ejectLimit)
+		if ejectAfter > 0 {
+			d.RetryInterval = ejectAfter
+		}
+		p.Options.FailureDetector = d
+	}
+	return p
+}
+
+// NewClient returns a client for the imported configuration
+func (p *PylibmcConfig) NewClient() *Client {
+	var c *Client
+	if p.Weights != nil {
+		c = NewWeightedClientWithOptions(p.Weights, p.Options)
+	} else {
+		c = NewClientWithOptions(p.Servers, p.Options)
+	}
+	if p.Timeout > 0 {
+		c.Timeout = p.Timeout
+	}
+	return c
+}
+
+// parsePylibmcServer parses a pylibmc server string ("host", "host:port",
+// "host:port:weight", or a unix domain socket "/path" or "unix:/path") into an
+// address and the weight, if any
+func parsePylibmcServer(server string) (addr, weight string, err error) {
+	if strings.HasPrefix(server, "udp:") {
+		return "", "", fmt.Errorf("udp servers aren't supported")
+	}
+	if path := strings.TrimPrefix(server, "unix:"); strings.HasPrefix(path, "/") {
+		return path, "", nil
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, "", nil
+	}
+	if i := strings.LastIndexByte(server, ':'); i > 0 && isDigits(server[i+1:]) {
+		if _, _, err := net.SplitHostPort(server[:i]); err == nil {
+			return server[:i], server[i+1:], nil
+		}
+	}
+	if !strings.Contains(strings.Trim(server, "[]"), ":") || strings.HasPrefix(server, "[") {
+		return net.JoinHostPort(strings.Trim(server, "[]"), "11211"), "", nil
+	}
+	return "", "", fmt.Errorf("invalid address")
+}
+
+// pylibmcNumber returns the numeric value of a behavior, which dumps may hold
+// as a number or bool. Other values are zero.
+func pylibmcNumber(v interface{}) float64 {
+	if b, ok := v.(bool); ok {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch rv := reflect.ValueOf(v); {
+	case rv.CanFloat():
+		return rv.Float()
+	case rv.CanInt():
+		return float64(rv.Int())
+	case rv.CanUint():
+		return float64(rv.Uint())
+	}
+	return 0
+}
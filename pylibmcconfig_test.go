@@ -0,0 +1,194 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+// a json dump of a pylibmc 1.6 client's behaviors with ketama enabled
+const pylibmcDump = `{
+	"servers": ["10.0.0.1", "10.0.0.2:11212", "10.0.0.3:11211:2"],
+	"binary": false,
+	"behaviors": {
+		"_auto_eject_hosts": 0, "_io_bytes_watermark": 65536, "_io_key_prefetch": 0,
+		"_io_msg_watermark": 500, "_noreply": 0, "_poll_timeout": 1000, "_retry_timeout": 0,
+		"_sort_hosts": 0, "buffer_requests": 0, "cas": 1, "connect_timeout": 250,
+		"dead_timeout": 30, "distribution": "consistent", "failure_limit": 0, "hash": "md5",
+		"ketama": 1, "ketama_hash": "md5", "ketama_weighted": 0, "no_block": 1,
+		"num_replicas": 0, "pickle_protocol": -1, "receive_timeout": 500000, "remove_failed": 2,
+		"retry_timeout": 2, "send_timeout": 0, "tcp_keepalive": 0, "tcp_nodelay": 1,
+		"verify_keys": 0
+	}
+}`
+
+func TestImportPylibmcConfig(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(pylibmcDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11212", "10.0.0.3:11211"}
+	if !reflect.DeepEqual(p.Servers, servers) {
+		t.Errorf("Expected servers %v got %v", servers, p.Servers)
+	}
+	if p.Timeout != time.Second {
+		t.Errorf("Expected the longest timeout (1s) got %s", p.Timeout)
+	}
+	if p.Options.PickleProtocol != 5 {
+		t.Errorf("Expected pickle protocol 5 got %d", p.Options.PickleProtocol)
+	}
+	d, ok := p.Options.FailureDetector.(*FailureCountDetector)
+	if !ok || d.Limit != 2 || d.RetryInterval != 30*time.Second {
+		t.Errorf("Expected ejection after 2 failures for 30s got %#v", p.Options.FailureDetector)
+	}
+	if len(p.Unsupported) != 1 || !strings.Contains(p.Unsupported[0].String(), "10.0.0.3:11211:2: server weight 2 ignored") {
+		t.Errorf("Expected the weight to be flagged got %v", p.Unsupported)
+	}
+
+	c := p.NewClient()
+	if c.Timeout != time.Second {
+		t.Errorf("Expected client timeout 1s got %s", c.Timeout)
+	}
+}
+
+func TestImportPylibmcConfig_Unsupported(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		json    string
+		problem string
+	}{
+		{"distribution", `{"servers": ["a:1"], "behaviors": {"distribution": "random"}}`, "distribution random isn't supported"},
+		{"hash", `{"servers": ["a:1"], "behaviors": {"ketama": true, "hash": "jenkins"}}`, "hash jenkins isn't supported"},
+		{"weighted hash", `{"servers": ["a:1"], "behaviors": {"ketama_weighted": true, "hash": "crc"}}`, "hash crc is ignored by the weighted ketama ring"},
+		{"hash conflict", `{"servers": ["a:1"], "behaviors": {"hash": "crc", "ketama_hash": "murmur"}}`, "ketama_hash murmur conflicts with crc"},
+		{"noreply", `{"servers": ["a:1"], "behaviors": {"_noreply": 1}}`, "_noreply isn't supported"},
+		{"binary", `{"servers": ["a:1"], "binary": true}`, "binary protocol isn't supported"},
+		{"pickle", `{"servers": ["a:1"], "behaviors": {"pickle_protocol": 3}}`, "pickle_protocol 3 isn't supported"},
+		{"unknown", `{"servers": ["a:1"], "behaviors": {"frobnicate": 1}}`, "unknown behavior frobnicate"},
+		{"udp", `{"servers": ["udp:a:1"]}`, "udp:a:1: udp servers aren't supported"},
+	} {
+		p, err := ImportPylibmcConfig(strings.NewReader(tc.json))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if len(p.Unsupported) == 0 || !strings.HasPrefix(p.Unsupported[0].String(), tc.problem) {
+			t.Errorf("%s: expected %q got %v", tc.name, tc.problem, p.Unsupported)
+		}
+	}
+
+	if _, err := ImportPylibmcConfig(strings.NewReader("servers: [a]")); err == nil {
+		t.Error("Expected an error for yaml")
+	}
+}
+
+func TestImportPylibmcConfig_Weighted(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(`{"servers": ["10.0.0.1:11211:3", "10.0.0.2"], "behaviors": {"ketama_weighted": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"10.0.0.1:11211": 3, "10.0.0.2:11211": 1}
+	if !reflect.DeepEqual(p.Weights, want) || len(p.Unsupported) != 0 {
+		t.Fatalf("unexpected weights %v %v", p.Weights, p.Unsupported)
+	}
+	mc := p.NewClient()
+	ring := ketamacompat.NewWeightedRing(want)
+	for n := 0; n < 1000; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.selector.PickServer(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+	}
+}
+
+func TestImportPylibmcConfig_Hash(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(`{"servers": ["10.0.0.1:11211", "10.0.0.2:11211"], "behaviors": {"ketama": true, "hash": "fnv1a_32", "ketama_hash": "fnv1a_32"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Options.KetamaHash == nil || len(p.Unsupported) != 0 {
+		t.Fatalf("Expected the fnv1a_32 hash to be imported got %v", p.Unsupported)
+	}
+	mc := p.NewClient()
+	ring := ketamacompat.NewRingWithHash(p.Servers, ketamacompat.HashFNV1a_32)
+	for n := 0; n < 1000; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.selector.PickServer(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+	}
+}
+
+func TestImportPylibmcConfig_Modula(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(`{"servers": ["10.0.0.1", "10.0.0.2", "10.0.0.3"], "behaviors": {"distribution": "modula", "ketama": 0, "hash": "crc"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Options.Distribution != DistributionModula || len(p.Unsupported) != 0 {
+		t.Fatalf("Expected modula distribution got %s %v", p.Options.Distribution, p.Unsupported)
+	}
+	mc := p.NewClient()
+	for n := 0; n < 100; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		want := p.Servers[ketamacompat.HashCRC32(key)%3]
+		if addr, err := mc.selector.PickServer(key); err != nil || addr.String() != want {
+			t.Fatalf("%s: expected %s got %v %v", key, want, addr, err)
+		}
+	}
+}
+
+func TestImportPylibmcConfig_Socket(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(`{"servers": ["/var/run/memcached.sock", "unix:/var/run/other.sock"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/var/run/memcached.sock", "/var/run/other.sock"}; !reflect.DeepEqual(p.Servers, want) || len(p.Unsupported) != 0 {
+		t.Errorf("Expected servers %v got %v %v", want, p.Servers, p.Unsupported)
+	}
+}
+
+func TestNewClientWithBehaviors(t *testing.T) {
+	mc, err := NewClientWithBehaviors([]string{"10.0.0.1", "10.0.0.2:11212"}, Behaviors{
+		"ketama": true, "tcp_nodelay": true, "remove_failed": 5, "retry_timeout": 2,
+		"connect_timeout": 250, "receive_timeout": 500000, "hash": "fnv1a_32",
+		"buffer_requests": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.Timeout != 500*time.Millisecond {
+		t.Errorf("Expected timeout 500ms got %s", mc.Timeout)
+	}
+	if mc.async == nil {
+		t.Error("Expected buffer_requests to queue writes")
+	}
+	if d, ok := mc.opts.FailureDetector.(*FailureCountDetector); !ok || d.Limit != 5 || d.RetryInterval != 2*time.Second {
+		t.Errorf("Expected ejection after 5 failures for 2s got %#v", mc.opts.FailureDetector)
+	}
+	ring := ketamacompat.NewRingWithHash([]string{"10.0.0.1:11211", "10.0.0.2:11212"}, ketamacompat.HashFNV1a_32)
+	for n := 0; n < 100; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.ServerForKey(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+	}
+
+	mc, err = NewClientWithBehaviors([]string{"10.0.0.1:11211:3", "10.0.0.2:11211"}, Behaviors{"ketama_weighted": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ketamacompat.NewWeightedRing(map[string]uint64{"10.0.0.1:11211": 3, "10.0.0.2:11211": 1}).Points()
+	if !reflect.DeepEqual(mc.Continuum(), want) {
+		t.Error("Expected the weighted continuum")
+	}
+
+	_, err = NewClientWithBehaviors([]string{"10.0.0.1"}, Behaviors{"_noreply": true, "frobnicate": 1})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || len(ce.Problems) != 2 {
+		t.Errorf("Expected two problems got %v", err)
+	}
+}
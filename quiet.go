@@ -0,0 +1,40 @@
+package memcache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// SetQuiet is Set sent with noreply: it returns once the command is written,
+// without waiting for the server to store the item, so errors storing it (too
+// large, out of memory) are never reported. Reads on other connections may
+// not see the item for a moment. Use it for high volume best effort writes.
+func (c *Client) SetQuiet(item *memcache.Item) error {
+	return c.write(OpSet, c.prefixed(item), c.setQuiet)
+}
+
+// DeleteQuiet is Delete sent with noreply, as SetQuiet is. Deleting a missing
+// key isn't reported.
+func (c *Client) DeleteQuiet(key string) error {
+	key = c.fullKey(key)
+	return c.do(OpDelete, key, func() error {
+		return c.quiet(key, func(sc *serverConn) error { return sc.command("delete %s noreply", key) })
+	})
+}
+
+func (c *Client) setQuiet(item *memcache.Item) error {
+	return c.quiet(item.Key, func(sc *serverConn) error {
+		return sc.commandValue(item.Value, "set %s %d %d %d noreply", item.Key, item.Flags, item.Expiration, len(item.Value))
+	})
+}
+
+// quiet sends a noreply command for key with send
+func (c *Client) quiet(key string, send func(sc *serverConn) error) error {
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return err
+	}
+	return c.withMetaConn(addr, send)
+}
@@ -0,0 +1,62 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// eventually retries check until it passes or a second has passed, for
+// writes not acknowledged before they are visible
+func eventually(t *testing.T, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Error(err)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	for n := 0; n < 100; n++ {
+		if err := mc.SetQuiet(Int64Item(fmt.Sprintf("quiet_%d", n), int64(n))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, func() error {
+		if n, ok := mc.GetInt64("quiet_99"); !ok || n != 99 {
+			return fmt.Errorf("Expected 99 got %d %v", n, ok)
+		}
+		return nil
+	})
+	if n, ok := mc.GetInt64("quiet_0"); !ok || n != 0 {
+		t.Errorf("Expected 0 got %d %v", n, ok)
+	}
+
+	if err := mc.DeleteQuiet("quiet_0"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() error {
+		if _, err := mc.Get("quiet_0"); err != memcache.ErrCacheMiss {
+			return fmt.Errorf("Expected ErrCacheMiss got %v", err)
+		}
+		return nil
+	})
+	// deleting a missing key isn't reported
+	if err := mc.DeleteQuiet("quiet_0"); err != nil {
+		t.Error(err)
+	}
+	if err := mc.SetQuiet(StringItem("bad key", "x")); err != memcache.ErrMalformedKey {
+		t.Errorf("Expected ErrMalformedKey got %v", err)
+	}
+}
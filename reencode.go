@@ -0,0 +1,149 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// Scheme is a complete value format: the flag scheme, the pickle protocol used
+// for pickled values and the compression applied
+type Scheme struct {
+	Flags FlagScheme
+	// Protocol is the pickle protocol pickled values are rewritten with. Zero
+	// leaves pickles as they are.
+	Protocol int
+	// Compress is the stage compressed values are written (and read) with. nil
+	// stores values uncompressed.
+	Compress Stage
+}
+
+// Reencode returns a copy of i, stored under scheme from, with its value
+// re-encoded under scheme to: decompressed, flags translated, pickles rewritten
+// with to.Protocol then compressed with to.Compress.
+func (i *Item) Reencode(from, to Scheme) (*memcache.Item, error) {
+	value, flags := i.Value, i.Flags
+	var err error
+	if from.Compress != nil {
+		if value, flags, err = from.Compress.Decode(value, flags); err != nil {
+			return nil, err
+		}
+	}
+	if from.Flags.Compressed != 0 && flags&from.Flags.Compressed != 0 {
+		return nil, fmt.Errorf("memcache: %s value is compressed and no Compress stage was given", i.Key)
+	}
+	if flags, err = TranslateFlags(flags, from.Flags, to.Flags); err != nil {
+		return nil, err
+	}
+	if to.Protocol != 0 && to.Flags.Pickle != 0 && flags == to.Flags.Pickle {
+		v, err := picklecompat.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		opts := picklecompat.EncodeOptions{Protocol: to.Protocol, Memo: picklecompat.MemoPython3}
+		if value, err = picklecompat.EncodeWith(v, opts); err != nil {
+			return nil, err
+		}
+	}
+	if to.Compress != nil {
+		if value, flags, err = to.Compress.Encode(value, flags); err != nil {
+			return nil, err
+		}
+	}
+	cp := *i.Item
+	cp.Value, cp.Flags = value, flags
+	return &cp, nil
+}
+
+// Reencode rewrites key from one Scheme to another preserving its remaining TTL,
+// which is read with a meta get (memcached 1.6+). The write uses CompareAndSwap
+// so a concurrent update isn't clobbered. It returns whether the item changed.
+func (c *Client) Reencode(ctx context.Context, key string, from, to Scheme) (bool, error) {
+	i, err := c.casGet(key)
+	if err != nil {
+		return false, err
+	}
+	ttl, err := c.remainingTTL(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	out, err := (&Item{i}).Reencode(from, to)
+	if err != nil {
+		return false, err
+	}
+	if out.Flags == i.Flags && bytes.Equal(out.Value, i.Value) {
+		return false, nil
+	}
+	out.Expiration = c.expirationFor(ttl)
+	return true, c.CompareAndSwap(out)
+}
+
+// remainingTTL returns the seconds until key expires, or -1 if it doesn't, from
+// a meta get or else the server's metadump
+func (c *Client) remainingTTL(ctx context.Context, key string) (int64, error) {
+	key = c.fullKey(key)
+	addr, err := c.selector.PickServer(key)
+	if err != nil {
+		return 0, err
+	}
+	ttl := int64(-1)
+	err = c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		if err := sc.command("mg %s t", key); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		return parseMetaTTL(addr, line, &ttl)
+	})
+	if errors.Is(err, ErrFeatureUnsupported) {
+		return c.metadumpTTL(ctx, addr, key)
+	}
+	return ttl, err
+}
+
+// parseMetaTTL parses the t flag from a meta get response line like "HD t30"
+func parseMetaTTL(addr net.Addr, line string, ttl *int64) error {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) > 0 && fields[0] == "EN":
+		return memcache.ErrCacheMiss
+	case line == "ERROR":
+		return fmt.Errorf("%w: mg on %s", ErrFeatureUnsupported, addr)
+	case len(fields) == 0 || fields[0] != "HD":
+		return fmt.Errorf("memcache: meta get on %s: %s", addr, line)
+	}
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, "t") {
+			n, err := strconv.ParseInt(f[1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("memcache: meta get on %s: %s", addr, line)
+			}
+			*ttl = n
+			return nil
+		}
+	}
+	return errors.New("memcache: meta get response without a ttl")
+}
+
+// expirationFor converts a remaining TTL in seconds (-1 for none) to an item
+// Expiration, using an absolute timestamp beyond memcached's 30 day limit
+func (c *Client) expirationFor(ttl int64) int32 {
+	switch {
+	case ttl < 0:
+		return 0
+	case ttl == 0:
+		return 1
+	case ttl > 60*60*24*30:
+		return int32(c.now().Unix() + ttl)
+	}
+	return int32(ttl)
+}
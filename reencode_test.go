@@ -0,0 +1,110 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// zlibStage stands in for compression, reversing the bytes and setting FLAG_ZLIB
+type zlibStage struct{}
+
+func (zlibStage) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return reverse(value), flags | FLAG_ZLIB, nil
+}
+
+func (zlibStage) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&FLAG_ZLIB == 0 {
+		return value, flags, nil
+	}
+	return reverse(value), flags &^ FLAG_ZLIB, nil
+}
+
+func TestItemReencode(t *testing.T) {
+	pylibmc := Scheme{Flags: PylibmcFlags}
+	pylibmc4 := Scheme{Flags: PylibmcFlags, Protocol: 4}
+	pm := Scheme{Flags: PythonMemcachedFlags}
+	// pickle.dumps(["a", 1], protocol)
+	p2 := "\x80\x02]q\x00(X\x01\x00\x00\x00aq\x01K\x01e."
+	p4 := "\x80\x04\x95\x0b\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x01a\x94K\x01e."
+
+	for _, tc := range []struct {
+		name      string
+		value     string
+		flags     uint32
+		from, to  Scheme
+		wantValue string
+		wantFlags uint32
+	}{
+		{"text", "abc", 16, pm, pylibmc, "abc", FLAG_NONE},
+		{"pickle protocol", p2, FLAG_PICKLE, pylibmc, pylibmc4, p4, FLAG_PICKLE},
+		{"pickle and flags", p2, 1, pm, pylibmc4, p4, FLAG_PICKLE},
+		{"integer untouched by protocol", "42", FLAG_INTEGER, pylibmc, pylibmc4, "42", FLAG_INTEGER},
+		{"compress", "42", FLAG_INTEGER, pylibmc, Scheme{Flags: PylibmcFlags, Compress: zlibStage{}}, "24", FLAG_INTEGER | FLAG_ZLIB},
+		{"decompress", "24", FLAG_INTEGER | FLAG_ZLIB, Scheme{Flags: PylibmcFlags, Compress: zlibStage{}}, pm, "42", 2},
+	} {
+		i := &Item{&memcache.Item{Key: "k", Value: []byte(tc.value), Flags: tc.flags, Expiration: 10}}
+		out, err := i.Reencode(tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if string(out.Value) != tc.wantValue || out.Flags != tc.wantFlags || out.Key != "k" || out.Expiration != 10 {
+			t.Errorf("%s: got %q flags %d", tc.name, out.Value, out.Flags)
+		}
+		if string(i.Value) != tc.value {
+			t.Errorf("%s: Expected the original item to be unchanged", tc.name)
+		}
+	}
+
+	compressed := &Item{&memcache.Item{Key: "k", Value: []byte("x"), Flags: FLAG_ZLIB}}
+	if _, err := compressed.Reencode(pylibmc, pylibmc4); err == nil {
+		t.Errorf("Expected error reencoding a compressed value without a Compress stage")
+	}
+}
+
+func TestClientReencode(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	mc.Set(&memcache.Item{Key: "reencode", Value: []byte("\x80\x02X\x01\x00\x00\x00aq\x00."), Flags: FLAG_PICKLE, Expiration: 300})
+	mc.Set(&memcache.Item{Key: "reencode_forever", Value: []byte("abc"), Flags: 16})
+
+	to := Scheme{Flags: PylibmcFlags, Protocol: 4}
+	if changed, err := mc.Reencode(ctx, "reencode", Scheme{Flags: PylibmcFlags}, to); err != nil || !changed {
+		t.Fatalf("Expected reencode, got %v %v", changed, err)
+	}
+	if s, ok := mc.GetString("reencode"); !ok || s != "a" {
+		t.Errorf("Expected reencoded value to read back, got %q", s)
+	}
+	i, _ := mc.Get("reencode")
+	if string(i.Value[:2]) != "\x80\x04" {
+		t.Errorf("Expected protocol 4 pickle, got %q", i.Value)
+	}
+	if ttl, err := mc.remainingTTL(ctx, "reencode"); err != nil || ttl < 290 || ttl > 300 {
+		t.Errorf("Expected TTL to be preserved, got %d %v", ttl, err)
+	}
+	if changed, err := mc.Reencode(ctx, "reencode", Scheme{Flags: PylibmcFlags}, to); err != nil || changed {
+		t.Errorf("Expected no change the second time, got %v %v", changed, err)
+	}
+
+	if changed, err := mc.Reencode(ctx, "reencode_forever", Scheme{Flags: PythonMemcachedFlags}, to); err != nil || !changed {
+		t.Errorf("Expected reencode, got %v %v", changed, err)
+	}
+	if ttl, err := mc.remainingTTL(ctx, "reencode_forever"); err != nil || ttl != -1 {
+		t.Errorf("Expected no expiration, got %d %v", ttl, err)
+	}
+	if _, err := mc.Reencode(ctx, "reencode_missing", Scheme{Flags: PylibmcFlags}, to); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+}
+
+func TestExpirationFor(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Clock: NewFakeClock(time.Unix(1700000000, 0))})
+	for ttl, want := range map[int64]int32{-1: 0, 0: 1, 60: 60, 60 * 60 * 24 * 31: 1700000000 + 60*60*24*31} {
+		if got := mc.expirationFor(ttl); got != want {
+			t.Errorf("expirationFor(%d) = %d, want %d", ttl, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package memcache
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricReplicaReads counts Gets read from replicas under Options.Replicas
+// after the key's server missed or failed, tagged by result: "hit", "miss" or
+// "error"
+const MetricReplicaReads = "memcache.replica.reads"
+
+// MetricReplicaWriteErrors counts failed writes and deletes of replicas under
+// Options.Replicas, tagged by server
+const MetricReplicaWriteErrors = "memcache.replica.write_errors"
+
+// replicaAddrs returns the servers holding the replicas of key under
+// Options.Replicas: those following its server in the configured order,
+// wrapping around, where libmemcached's number_of_replicas places them
+func (c *Client) replicaAddrs(key string) []net.Addr {
+	if c.opts.Replicas <= 0 || c.opts.ProxyMode != ProxyNone {
+		return nil
+	}
+	primary, err := c.selector.PickServer(key)
+	if err != nil {
+		return nil
+	}
+	servers, err := c.servers()
+	if err != nil {
+		return nil
+	}
+	at := -1
+	for n, addr := range servers {
+		if addr.String() == primary.String() {
+			at = n
+			break
+		}
+	}
+	if at < 0 {
+		return nil
+	}
+	replicas := c.opts.Replicas
+	if replicas > len(servers)-1 {
+		replicas = len(servers) - 1
+	}
+	addrs := make([]net.Addr, replicas)
+	for n := range addrs {
+		addrs[n] = servers[(at+n+1)%len(servers)]
+	}
+	return addrs
+}
+
+// replicate runs send, a command for key, against each of its replicas. Writes
+// to replicas are best effort: failures are counted, not returned.
+func (c *Client) replicate(key string, send func(sc *serverConn) error) {
+	if c.opts.Replicas <= 0 || !legalKey(key) {
+		return
+	}
+	for _, addr := range c.replicaAddrs(key) {
+		if err := c.withMetaConn(addr, send); err != nil && !isProtocolError(err) {
+			c.opts.Metrics.Count(MetricReplicaWriteErrors, 1, map[string]string{"server": addr.String()})
+		}
+	}
+}
+
+// replicaSet writes item to the replicas of its key
+func (c *Client) replicaSet(item *memcache.Item) {
+	c.replicate(item.Key, func(sc *serverConn) error {
+		if err := sc.commandValue(item.Value, "set %s %d %d %d", item.Key, item.Flags, item.Expiration, len(item.Value)); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "STORED" {
+			return fmt.Errorf("memcache: unexpected set response from %s: %q", sc.addr, line)
+		}
+		return nil
+	})
+}
+
+// replicaDelete deletes key from its replicas, so they don't serve it after
+// the key's server loses it
+func (c *Client) replicaDelete(key string) {
+	c.replicate(key, func(sc *serverConn) error {
+		if err := sc.command("delete %s", key); err != nil {
+			return err
+		}
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "DELETED":
+			return nil
+		case "NOT_FOUND":
+			return memcache.ErrCacheMiss
+		}
+		return fmt.Errorf("memcache: unexpected delete response from %s: %q", sc.addr, line)
+	})
+}
+
+// replicaGet reads key from its replicas, in order, when reading it from its
+// server missed or failed. The first replica holding it answers; the original
+// miss or error is returned when none does, unless a replica answered that it
+// doesn't hold the key.
+func (c *Client) replicaGet(key string, i *memcache.Item, err error) (*memcache.Item, error) {
+	if c.opts.Replicas <= 0 || err == nil || err == memcache.ErrMalformedKey || !legalKey(key) {
+		return i, err
+	}
+	result := "error"
+	for _, addr := range c.replicaAddrs(key) {
+		var ri *memcache.Item
+		rerr := c.withMetaConn(addr, func(sc *serverConn) (err error) {
+			ri, err = readReplica(sc, key)
+			return
+		})
+		switch rerr {
+		case nil:
+			c.opts.Metrics.Count(MetricReplicaReads, 1, map[string]string{"result": "hit"})
+			return ri, nil
+		case memcache.ErrCacheMiss:
+			result, err = "miss", memcache.ErrCacheMiss
+		}
+	}
+	c.opts.Metrics.Count(MetricReplicaReads, 1, map[string]string{"result": result})
+	return i, err
+}
+
+// readReplica reads key with a classic get on sc
+func readReplica(sc *serverConn, key string) (*memcache.Item, error) {
+	if err := sc.command("get %s", key); err != nil {
+		return nil, err
+	}
+	var item *memcache.Item
+	for {
+		line, err := sc.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			if item == nil {
+				return nil, memcache.ErrCacheMiss
+			}
+			return item, nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			return nil, fmt.Errorf("memcache: unexpected get response from %s: %q", sc.addr, line)
+		}
+		flags, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("memcache: unexpected get response from %s: %q", sc.addr, line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("memcache: unexpected get response from %s: %q", sc.addr, line)
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(sc.rw, value); err != nil {
+			return nil, err
+		}
+		if string(value[size:]) != "\r\n" {
+			return nil, fmt.Errorf("memcache: corrupt get response from %s", sc.addr)
+		}
+		item = &memcache.Item{Key: fields[1], Value: value[:size], Flags: uint32(flags)}
+	}
+}
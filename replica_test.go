@@ -0,0 +1,124 @@
+package memcache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestReplicas(t *testing.T) {
+	down := closedAddr(t)
+	servers := []string{down}
+	for n := 0; n < 2; n++ {
+		s, err := newLocalServer(systemClock{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.ln.Close() })
+		servers = append(servers, s.ln.Addr().String())
+	}
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions(servers, Options{Replicas: 1, Metrics: metrics})
+
+	// keys of the server that is down are written to and read from the next
+	var onDown, onUp string
+	for n := 0; onDown == "" || onUp == ""; n++ {
+		key := fmt.Sprintf("replica_%d", n)
+		addr, err := mc.ServerForKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch addr.String() {
+		case down:
+			onDown = key
+		case servers[1]:
+			onUp = key
+		}
+	}
+	if replicas := mc.replicaAddrs(onDown); len(replicas) != 1 || replicas[0].String() != servers[1] {
+		t.Fatalf("Expected the replica on %s, got %v", servers[1], replicas)
+	}
+	if err := mc.Set(&memcache.Item{Key: onDown, Value: []byte("v")}); err == nil {
+		t.Error("Expected the failed write to the key's server to be returned")
+	}
+	if i, err := mc.Get(onDown); err != nil || string(i.Value) != "v" {
+		t.Errorf("Expected the replica to be read, got %v %v", i, err)
+	}
+	if n := metrics.get(MetricReplicaReads); n != 1 {
+		t.Errorf("Expected 1 replica read, got %d", n)
+	}
+
+	// a miss on the key's server falls back to the replica
+	if err := mc.Set(&memcache.Item{Key: onUp, Value: []byte("up")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewClient([]string{servers[1]}).Delete(onUp); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := mc.Get(onUp); err != nil || string(i.Value) != "up" {
+		t.Errorf("Expected the replica to be read, got %v %v", i, err)
+	}
+	if err := NewClient([]string{servers[2]}).Delete(onUp); err != nil {
+		t.Errorf("Expected the item on the replica: %v", err)
+	}
+
+	// deletes remove replicas
+	mc.Set(&memcache.Item{Key: onUp, Value: []byte("up")})
+	if err := mc.Delete(onUp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Get(onUp); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss after Delete, got: %v", err)
+	}
+}
+
+func TestImportPylibmcConfig_Replicas(t *testing.T) {
+	p, err := ImportPylibmcConfig(strings.NewReader(`{"servers": ["a:1", "b:1"], "behaviors": {"num_replicas": 1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Options.Replicas != 1 || len(p.Unsupported) != 0 {
+		t.Errorf("Expected 1 replica, got %d %v", p.Options.Replicas, p.Unsupported)
+	}
+}
+
+func TestReplicasCAS(t *testing.T) {
+	var servers []string
+	for n := 0; n < 2; n++ {
+		s, err := newLocalServer(systemClock{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.ln.Close() })
+		servers = append(servers, s.ln.Addr().String())
+	}
+	mc := NewClientWithOptions(servers, Options{Replicas: 1})
+	var key string
+	for n := 0; key == ""; n++ {
+		if k := fmt.Sprintf("replica_cas_%d", n); mustAddr(t, mc, k) == servers[0] {
+			key = k
+		}
+	}
+
+	// the key's server lost it but the replica still has it: the CAS flows
+	// read the key's server and create the key
+	if err := mc.Set(Int64Item(key, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewClient([]string{servers[0]}).Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.SetIf(key, Int64Item("", 2), func(old *Item) bool { return old == nil }); err != nil {
+		t.Errorf("Expected SetIf to add the key, got: %v", err)
+	}
+	if err := mc.UpdateInPlace(key, func(old []byte, flags uint32) ([]byte, uint32, error) {
+		return []byte("3"), flags, nil
+	}, 1); err != nil {
+		t.Errorf("Expected UpdateInPlace to update the key, got: %v", err)
+	}
+	if n, ok := NewClient([]string{servers[0]}).GetInt64(key); !ok || n != 3 {
+		t.Errorf("Expected 3 on the key's server, got %d %v", n, ok)
+	}
+}
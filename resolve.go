@@ -0,0 +1,110 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricResolveChanges counts servers whose hostname resolved to different
+// addresses under Options.ResolveInterval, tagged by server
+const MetricResolveChanges = "memcache.resolve.changes"
+
+// resolver re-resolves the hostnames of the servers every interval and closes
+// the connections to addresses they no longer resolve to, so rescheduled
+// servers are redialed at their new address. Keys stay hashed by hostname.
+type resolver struct {
+	c        *Client
+	interval time.Duration
+	lookup   func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	mu    sync.Mutex
+	addrs map[string][]string // by server, the sorted addresses last resolved
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newResolver(c *Client, interval time.Duration) *resolver {
+	r := &resolver{
+		c:        c,
+		interval: interval,
+		lookup:   net.DefaultResolver.LookupIPAddr,
+		addrs:    make(map[string][]string),
+		stop:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// run resolves the servers every interval until close
+func (r *resolver) run() {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.resolve()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// close stops re-resolving
+func (r *resolver) close() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+// resolve looks up every server given by hostname, closing the connections of
+// those whose addresses changed. A failed lookup leaves a server's connections
+// alone.
+func (r *resolver) resolve() {
+	servers, err := r.c.servers()
+	if err != nil {
+		return
+	}
+	for _, addr := range servers {
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil || addr.Network() != "tcp" || net.ParseIP(host) != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+		ips, err := r.lookup(ctx, host)
+		cancel()
+		if err != nil || len(ips) == 0 {
+			continue
+		}
+		current := make([]string, len(ips))
+		for n, ip := range ips {
+			current[n] = ip.IP.String()
+		}
+		sort.Strings(current)
+
+		server := addr.String()
+		r.mu.Lock()
+		previous, seen := r.addrs[server]
+		r.addrs[server] = current
+		r.mu.Unlock()
+		if !seen || equalStrings(previous, current) {
+			continue
+		}
+		r.c.opts.Metrics.Count(MetricResolveChanges, 1, map[string]string{"server": server})
+		r.c.conns.closeStale(server, current)
+	}
+}
+
+// equalStrings reports whether a and b hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestResolveInterval(t *testing.T) {
+	_, port, _ := net.SplitHostPort(slowServer(t, 0))
+	server := net.JoinHostPort("localhost", port)
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{server}, Options{ResolveInterval: time.Hour, Metrics: metrics})
+	defer mc.Close()
+	ip := "127.0.0.1"
+	mc.resolver.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host != "localhost" {
+			t.Errorf("Expected the hostname to be resolved, got: %s", host)
+		}
+		return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+	}
+	open := func() int64 {
+		for _, s := range mc.PoolStats() {
+			if s.Addr == server {
+				return s.Open
+			}
+		}
+		return 0
+	}
+
+	mc.resolver.resolve()
+	if _, err := mc.Get("resolve_key"); err != memcache.ErrCacheMiss {
+		t.Fatal(err)
+	}
+	if n := open(); n != 1 {
+		t.Fatalf("Expected 1 open connection, got: %d", n)
+	}
+
+	// an unchanged resolution keeps the connection
+	mc.resolver.resolve()
+	if n := open(); n != 1 || metrics.get(MetricResolveChanges) != 0 {
+		t.Errorf("Expected the connection to be kept, got: %d open", n)
+	}
+
+	// the server moved: connections to the old address are closed
+	ip = "127.0.0.2"
+	mc.resolver.resolve()
+	if n := open(); n != 0 || metrics.get(MetricResolveChanges) != 1 {
+		t.Errorf("Expected the stale connection to be closed, got: %d open", n)
+	}
+
+	// keys are still hashed by hostname
+	if addr, err := mc.ServerForKey("resolve_key"); err != nil || !strings.HasPrefix(addr.String(), "localhost:") {
+		t.Errorf("Expected the key to stay on %s, got: %v %v", server, addr, err)
+	}
+}
@@ -0,0 +1,101 @@
+package memcache
+
+import (
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ResultKind is the outcome of a read returning a Result
+type ResultKind int
+
+const (
+	// ResultMiss is a key that isn't cached. It is the zero ResultKind.
+	ResultMiss ResultKind = iota
+	// ResultHit is a value that decoded
+	ResultHit
+	// ResultNone is a cached Python None
+	ResultNone
+	// ResultError is a failed read, or a value that didn't decode
+	ResultError
+)
+
+func (k ResultKind) String() string {
+	switch k {
+	case ResultMiss:
+		return "miss"
+	case ResultHit:
+		return "hit"
+	case ResultNone:
+		return "none"
+	case ResultError:
+		return "error"
+	}
+	return fmt.Sprintf("ResultKind(%d)", int(k))
+}
+
+// Result is the outcome of a typed read, keeping apart what the typed getters'
+// false collapses: a hit, a miss, a cached None and an error
+type Result[T any] struct {
+	Kind ResultKind
+	// Value is the decoded value of a hit
+	Value T
+	// Err is the error of a ResultError
+	Err error
+}
+
+// Hit returns the Result of reading v
+func Hit[T any](v T) Result[T] { return Result[T]{Kind: ResultHit, Value: v} }
+
+// Miss returns the Result of reading a key that isn't cached
+func Miss[T any]() Result[T] { return Result[T]{Kind: ResultMiss} }
+
+// None returns the Result of reading a cached Python None
+func None[T any]() Result[T] { return Result[T]{Kind: ResultNone} }
+
+// Error returns the Result of a read failing with err
+func Error[T any](err error) Result[T] { return Result[T]{Kind: ResultError, Err: err} }
+
+// Get returns the value and whether r is a hit
+func (r Result[T]) Get() (T, bool) { return r.Value, r.Kind == ResultHit }
+
+// Or returns the value of a hit, or def
+func (r Result[T]) Or(def T) T {
+	if r.Kind == ResultHit {
+		return r.Value
+	}
+	return def
+}
+
+func (r Result[T]) String() string {
+	switch r.Kind {
+	case ResultHit:
+		return fmt.Sprintf("hit(%v)", r.Value)
+	case ResultError:
+		return fmt.Sprintf("error(%s)", r.Err)
+	}
+	return r.Kind.String()
+}
+
+// GetResult is Get returning a Result: ResultMiss for ErrCacheMiss, ResultNone
+// for a cached Python None (even when T is an interface) and ResultError for
+// any other error, including values that aren't a T
+func GetResult[T any](c *Client, key string, opts ...CallOption) Result[T] {
+	r, err := getDecoded(c.getter(opts), key, func(i *Item) (Result[T], error) {
+		if i.IsNone() {
+			return None[T](), nil
+		}
+		v, err := decodeGeneric[T](c, i)
+		if err != nil {
+			return Result[T]{}, err
+		}
+		return Hit(v), nil
+	})
+	switch {
+	case err == memcache.ErrCacheMiss:
+		return Miss[T]()
+	case err != nil:
+		return Error[T](err)
+	}
+	return r
+}
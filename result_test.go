@@ -0,0 +1,46 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetResult(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("r_missing")
+	Set(mc, "r_int", int64(42), 0)
+	mc.Set(NoneItem("r_none"))
+	mc.Set(UnicodeItem("r_str", "a"))
+
+	if r := GetResult[int64](mc, "r_int"); r.Kind != ResultHit || r.Value != 42 || r.String() != "hit(42)" {
+		t.Errorf("Expected hit(42) got %s", r)
+	}
+	if r := GetResult[int64](mc, "r_missing"); r.Kind != ResultMiss || r.Or(7) != 7 {
+		t.Errorf("Expected a miss got %s", r)
+	}
+	if r := GetResult[int64](mc, "r_none"); r.Kind != ResultNone {
+		t.Errorf("Expected none got %s", r)
+	}
+	if r := GetResult[interface{}](mc, "r_none"); r.Kind != ResultNone {
+		t.Errorf("Expected none for an interface got %s", r)
+	}
+	if r := GetResult[int64](mc, "r_str"); r.Kind != ResultError || !errors.Is(r.Err, InvalidType) {
+		t.Errorf("Expected InvalidType got %s", r)
+	}
+	if v, ok := GetResult[string](mc, "r_str").Get(); !ok || v != "a" {
+		t.Errorf("Expected a got %q %v", v, ok)
+	}
+
+	down := NewClient([]string{closedAddr(t)})
+	if r := GetResult[string](down, "r_str"); r.Kind != ResultError || r.Err == nil {
+		t.Errorf("Expected a network error got %s", r)
+	}
+
+	var zero Result[string]
+	if zero.Kind != ResultMiss {
+		t.Errorf("Expected the zero Result to be a miss got %s", zero)
+	}
+	if r := Error[int](errors.New("boom")); r.String() != "error(boom)" {
+		t.Errorf("unexpected %s", r)
+	}
+}
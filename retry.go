@@ -0,0 +1,102 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultRetryBackoff is the delay before the first retry when
+// RetryPolicy.Backoff is unset
+const DefaultRetryBackoff = 10 * time.Millisecond
+
+// MetricRetries counts Get, Set and Delete attempts retried under
+// Options.Retry, tagged by op
+const MetricRetries = "memcache.retries"
+
+// RetryPolicy retries Gets, Sets and Deletes failing with transient errors,
+// such as the connection resets of a memcached restart, rather than surfacing
+// them as misses. CompareAndSwap, Add, Replace, Append, Prepend and the
+// increments aren't idempotent and are never retried. The zero value doesn't
+// retry.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first. Values under
+	// 2 disable retries.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled before each later
+	// one with up to half of it randomized. Defaults to DefaultRetryBackoff.
+	Backoff time.Duration
+	// MaxBackoff caps Backoff. Zero doesn't cap it.
+	MaxBackoff time.Duration
+	// Retryable reports whether an attempt failing with err is retried. nil
+	// uses IsTransient.
+	Retryable func(err error) bool
+}
+
+// IsTransient reports whether err is a failure talking to a server that a
+// retry may not see: a network error, including timeouts, or the server
+// closing or resetting the connection. Misses, failed conditions and
+// ErrNoServers aren't transient, nor is ErrReconnectBackoff, which lasts
+// until the backoff elapses.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil, isProtocolError(err), err == memcache.ErrNoServers, err == ErrReconnectBackoff:
+		return false
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	}
+	var cte *memcache.ConnectTimeoutError
+	if errors.As(err, &cte) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// delay returns the backoff before the given retry, counting from 1
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = DefaultRetryBackoff
+	}
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// retry runs fn, the op of an idempotent Get, Set or Delete, again after a
+// backoff while it fails with a retryable error and Options.Retry allows more
+// attempts. Backoffs wait on Options.Clock and end early, returning the last
+// error, when ctx is done.
+func retry[T any](ctx context.Context, c *Client, op string, fn func() (T, error)) (T, error) {
+	p := c.opts.Retry
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return v, err
+		}
+		c.opts.Metrics.Count(MetricRetries, 1, map[string]string{"op": op})
+		if c.sleep(ctx, p.delay(attempt)) != nil {
+			return v, err
+		}
+	}
+}
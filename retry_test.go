@@ -0,0 +1,182 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// flakyServer closes the connection on each of the first failures commands it
+// receives and answers later ones as an empty server, counting every command
+func flakyServer(t *testing.T, failures int32) (string, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var commands int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if atomic.AddInt32(&commands, 1) <= failures {
+						return
+					}
+					switch f := strings.Fields(line); f[0] {
+					case "get", "gets":
+						io.WriteString(c, "END\r\n")
+					case "set":
+						r.ReadString('\n')
+						io.WriteString(c, "STORED\r\n")
+					case "cas":
+						r.ReadString('\n')
+						io.WriteString(c, "EXISTS\r\n")
+					case "delete":
+						io.WriteString(c, "NOT_FOUND\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &commands
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	addr, commands := flakyServer(t, 2)
+	mc := NewClientWithOptions([]string{addr}, Options{Retry: policy})
+	if _, err := mc.Get("retry_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss after two resets, got: %v", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 3 {
+		t.Errorf("Expected 3 attempts, got: %d", n)
+	}
+
+	addr, _ = flakyServer(t, 1)
+	mc = NewClientWithOptions([]string{addr}, Options{Retry: policy})
+	if err := mc.Set(StringItem("retry_key", "v")); err != nil {
+		t.Errorf("Expected the set to be retried, got: %v", err)
+	}
+
+	addr, _ = flakyServer(t, 1)
+	mc = NewClientWithOptions([]string{addr}, Options{Retry: policy})
+	if err := mc.Delete("retry_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected the delete to be retried, got: %v", err)
+	}
+
+	addr, _ = flakyServer(t, 5)
+	mc = NewClientWithOptions([]string{addr}, Options{Retry: policy})
+	if _, err := mc.Get("retry_key"); !IsTransient(err) {
+		t.Errorf("Expected the reset once attempts run out, got: %v", err)
+	}
+
+	// off by default
+	addr, _ = flakyServer(t, 1)
+	mc = NewClient([]string{addr})
+	if _, err := mc.Get("retry_key"); !IsTransient(err) {
+		t.Errorf("Expected no retry by default, got: %v", err)
+	}
+}
+
+func TestRetryClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewFakeClock(start)
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}
+	addr, commands := flakyServer(t, 2)
+	mc := NewClientWithOptions([]string{addr}, Options{Retry: policy, Clock: clock})
+	if _, err := mc.Get("retry_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected a miss after two resets, got: %v", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 3 {
+		t.Errorf("Expected 3 attempts, got: %d", n)
+	}
+	if d := clock.Now().Sub(start); d < time.Hour {
+		t.Errorf("Expected the backoffs on the clock, got %v", d)
+	}
+
+	// a done ctx ends the backoff returning the last error
+	addr, commands = flakyServer(t, 2)
+	mc = NewClientWithOptions([]string{addr}, Options{Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := mc.GetCtx(ctx, "retry_key"); err == nil || err == memcache.ErrCacheMiss {
+		t.Errorf("Expected the get to stop retrying, got: %v", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 1 {
+		t.Errorf("Expected 1 attempt, got: %d", n)
+	}
+}
+
+func TestRetryNotCAS(t *testing.T) {
+	addr, commands := flakyServer(t, 1)
+	mc := NewClientWithOptions([]string{addr}, Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	if err := mc.CompareAndSwap(&memcache.Item{Key: "retry_cas", Value: []byte("v")}); !IsTransient(err) {
+		t.Errorf("Expected the CAS not to be retried, got: %v", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 1 {
+		t.Errorf("Expected 1 attempt, got: %d", n)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	addr, commands := flakyServer(t, 1)
+	mc := NewClientWithOptions([]string{addr}, Options{Retry: RetryPolicy{
+		MaxAttempts: 3,
+		Retryable:   func(err error) bool { return false },
+	}})
+	if _, err := mc.Get("retry_key"); err == nil || err == memcache.ErrCacheMiss {
+		t.Errorf("Expected the reset, got: %v", err)
+	}
+	if n := atomic.LoadInt32(commands); n != 1 {
+		t.Errorf("Expected 1 attempt, got: %d", n)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{memcache.ErrCacheMiss, false},
+		{memcache.ErrNotStored, false},
+		{memcache.ErrNoServers, false},
+		{ErrReconnectBackoff, false},
+		{errors.New("memcache: unexpected response"), false},
+		{io.EOF, true},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{&memcache.ConnectTimeoutError{}, true},
+	} {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("%v: expected %v got %v", tc.err, tc.want, got)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}
+	for retry, max := range []time.Duration{10, 20, 30, 30} {
+		max *= time.Millisecond
+		if d := p.delay(retry + 1); d < max/2 || d > max {
+			t.Errorf("retry %d: expected a delay within [%s, %s] got %s", retry+1, max/2, max, d)
+		}
+	}
+}
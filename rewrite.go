@@ -0,0 +1,144 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// FlagScheme describes the flag bits a Python client library sets for each value
+// type. A zero bit means the library doesn't mark that type (e.g. pylibmc stores
+// text without a flag and python-memcached stores bools as integers).
+type FlagScheme struct {
+	Name       string
+	Pickle     uint32
+	Integer    uint32
+	Long       uint32
+	Compressed uint32
+	Text       uint32
+	Bool       uint32
+}
+
+// PylibmcFlags is the flag scheme used by pylibmc (and this package)
+var PylibmcFlags = FlagScheme{
+	Name:       "pylibmc",
+	Pickle:     FLAG_PICKLE,
+	Integer:    FLAG_INTEGER,
+	Long:       FLAG_LONG,
+	Compressed: FLAG_ZLIB,
+	Bool:       FLAG_BOOL,
+}
+
+// PythonMemcachedFlags is the flag scheme used by python-memcached. Note that
+// 1<<4 marks utf-8 text where pylibmc uses it for booleans.
+var PythonMemcachedFlags = FlagScheme{
+	Name:       "python-memcached",
+	Pickle:     1 << 0,
+	Integer:    1 << 1,
+	Long:       1 << 2,
+	Compressed: 1 << 3,
+	Text:       1 << 4,
+}
+
+// TranslateFlags converts flags written under scheme from to the equivalent flags
+// under scheme to. The value bytes for each type are the same in both schemes.
+func TranslateFlags(flags uint32, from, to FlagScheme) (uint32, error) {
+	var out uint32
+	if from.Compressed != 0 && flags&from.Compressed != 0 {
+		if to.Compressed == 0 {
+			return 0, fmt.Errorf("memcache: %s has no compressed flag", to.Name)
+		}
+		out |= to.Compressed
+		flags &^= from.Compressed
+	}
+	switch {
+	case flags == 0:
+	case flags == from.Pickle:
+		out |= to.Pickle
+	case flags == from.Integer:
+		out |= to.Integer
+	case flags == from.Long:
+		out |= to.Long
+	case from.Text != 0 && flags == from.Text:
+		out |= to.Text
+	case from.Bool != 0 && flags == from.Bool:
+		if to.Bool != 0 {
+			out |= to.Bool
+		} else {
+			// booleans are stored as the integers 0/1 by libraries without a bool flag
+			out |= to.Integer
+		}
+	default:
+		return 0, fmt.Errorf("memcache: unknown %s flags %d", from.Name, flags)
+	}
+	return out, nil
+}
+
+// Rewriter rewrites items stored under one flag scheme into another, for one-time
+// migration of a cluster's contents when switching Python client libraries.
+type Rewriter struct {
+	c        *Client
+	from, to FlagScheme
+}
+
+// NewRewriter returns a Rewriter converting items on c from one flag scheme to another
+func NewRewriter(c *Client, from, to FlagScheme) *Rewriter {
+	return &Rewriter{c: c, from: from, to: to}
+}
+
+// Rewrite rewrites key with translated flags and the given expiration (use an
+// absolute unix timestamp to preserve an existing TTL). The write uses
+// CompareAndSwap so a concurrent update by a new-scheme writer isn't clobbered.
+// It returns whether the item was rewritten (false when flags were already correct).
+func (r *Rewriter) Rewrite(key string, expiration int32) (bool, error) {
+	return r.rewrite(r.c.fullKey(key), expiration)
+}
+
+// rewrite is Rewrite of a full key (see Options.KeyPrefix)
+func (r *Rewriter) rewrite(key string, expiration int32) (bool, error) {
+	i, err := r.c.getFull(key, callOptions{cas: true})
+	if err != nil {
+		return false, err
+	}
+	flags, err := TranslateFlags(i.Flags, r.from, r.to)
+	if err != nil {
+		return false, err
+	}
+	if flags == i.Flags {
+		return false, nil
+	}
+	i.Flags = flags
+	i.Expiration = expiration
+	return true, r.c.write(OpCompareAndSwap, i, r.c.Client.CompareAndSwap)
+}
+
+// RewriteAll enumerates every item (via metadump) and rewrites it preserving its
+// expiration. report is called with the result for each item that needed
+// rewriting or failed; items that expired or changed during the scan are skipped.
+// It returns the number of items rewritten.
+func (r *Rewriter) RewriteAll(ctx context.Context, report func(key string, err error)) (int, error) {
+	var n int
+	err := r.c.Metadump(ctx, func(_ net.Addr, e MetadumpEntry) error {
+		var expiration int32
+		if e.Exp > 0 {
+			expiration = int32(e.Exp)
+		}
+		rewritten, err := r.rewrite(e.Key, expiration)
+		switch err {
+		case memcache.ErrCacheMiss, memcache.ErrCASConflict, memcache.ErrNotStored:
+			return nil
+		case nil:
+			if !rewritten {
+				return nil
+			}
+			n++
+		}
+		if report != nil {
+			report(e.Key, err)
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestTranslateFlags(t *testing.T) {
+	type testCase struct {
+		flags    uint32
+		from, to FlagScheme
+		expected uint32
+	}
+	for _, tc := range []testCase{
+		{0, PythonMemcachedFlags, PylibmcFlags, FLAG_NONE},
+		{16, PythonMemcachedFlags, PylibmcFlags, FLAG_NONE},
+		{1, PythonMemcachedFlags, PylibmcFlags, FLAG_PICKLE},
+		{2, PythonMemcachedFlags, PylibmcFlags, FLAG_INTEGER},
+		{4, PythonMemcachedFlags, PylibmcFlags, FLAG_LONG},
+		{9, PythonMemcachedFlags, PylibmcFlags, FLAG_PICKLE | FLAG_ZLIB},
+		{FLAG_BOOL, PylibmcFlags, PythonMemcachedFlags, 2},
+		{FLAG_PICKLE | FLAG_ZLIB, PylibmcFlags, PythonMemcachedFlags, 9},
+	} {
+		got, err := TranslateFlags(tc.flags, tc.from, tc.to)
+		if err != nil || got != tc.expected {
+			t.Errorf("%s %d -> %s Expected %d, got: %d %v", tc.from.Name, tc.flags, tc.to.Name, tc.expected, got, err)
+		}
+	}
+	if _, err := TranslateFlags(1<<10, PylibmcFlags, PythonMemcachedFlags); err == nil {
+		t.Errorf("Expected error for unknown flags")
+	}
+}
+
+func TestRewriteAll(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	flushAll(t, mc)
+	mc.Set(&memcache.Item{Key: "pm_text", Value: []byte("Iñtërnâtiôn"), Flags: 16})
+	mc.Set(&memcache.Item{Key: "pm_int", Value: []byte("42"), Flags: 2})
+
+	n, err := NewRewriter(mc, PythonMemcachedFlags, PylibmcFlags).RewriteAll(context.Background(), func(key string, err error) {
+		if err != nil {
+			t.Errorf("rewriting %s: %v", key, err)
+		}
+	})
+	if err != nil || n != 1 {
+		t.Errorf("Expected 1 rewrite, got: %d %v", n, err)
+	}
+	if v, ok := mc.GetString("pm_text"); !ok || v != "Iñtërnâtiôn" {
+		t.Errorf("Expected text readable as pylibmc string, got: %q", v)
+	}
+	if v, ok := mc.GetInt64("pm_int"); !ok || v != 42 {
+		t.Errorf("Expected 42, got: %v", v)
+	}
+}
+
+func TestRewriteKeyPrefix(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	mc := NewClientWithOptions([]string{s.ln.Addr().String()}, Options{KeyPrefix: "app:"})
+	mc.Set(&memcache.Item{Key: "pm_a", Value: []byte("text"), Flags: 16})
+	mc.Set(&memcache.Item{Key: "pm_b", Value: []byte("text"), Flags: 16})
+
+	r := NewRewriter(mc, PythonMemcachedFlags, PylibmcFlags)
+	if ok, err := r.Rewrite("pm_a", 0); !ok || err != nil {
+		t.Errorf("Expected pm_a rewritten, got %v %v", ok, err)
+	}
+	n, err := r.RewriteAll(context.Background(), func(key string, err error) {
+		if err != nil {
+			t.Errorf("rewriting %s: %v", key, err)
+		}
+	})
+	if err != nil || n != 1 {
+		t.Errorf("Expected 1 rewrite, got: %d %v", n, err)
+	}
+	for _, k := range []string{"pm_a", "pm_b"} {
+		if i, err := mc.Get(k); err != nil || i.Flags != 0 {
+			t.Errorf("Expected %s rewritten, got %v %v", k, i, err)
+		}
+	}
+}
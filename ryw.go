@@ -0,0 +1,62 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+type rywKey struct{}
+
+// writeBuffer holds the writes made during one request. A nil item records a delete.
+type writeBuffer struct {
+	mu     sync.Mutex
+	writes map[string]*memcache.Item
+}
+
+// WithReadYourWrites returns a context that records keys written through the
+// context aware methods (SetCtx, DeleteCtx) and serves later GetCtx and
+// GetMultiCtx calls for those keys from what was written, so a request always
+// sees its own writes even when replication or asynchronous sets lag. Create one
+// per request; the buffer lives as long as the context.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rywKey{}, &writeBuffer{writes: make(map[string]*memcache.Item)})
+}
+
+func writeBufferFrom(ctx context.Context) *writeBuffer {
+	b, _ := ctx.Value(rywKey{}).(*writeBuffer)
+	return b
+}
+
+// set records a successful write of item
+func (b *writeBuffer) set(item *memcache.Item) {
+	cp := *item
+	cp.Value = append([]byte(nil), item.Value...)
+	b.mu.Lock()
+	b.writes[item.Key] = &cp
+	b.mu.Unlock()
+}
+
+// delete records a successful delete of key
+func (b *writeBuffer) delete(key string) {
+	b.mu.Lock()
+	b.writes[key] = nil
+	b.mu.Unlock()
+}
+
+// get returns the written item for key, ErrCacheMiss if it was deleted, or
+// ok=false if the request hasn't written key
+func (b *writeBuffer) get(key string) (i *memcache.Item, err error, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w, ok := b.writes[key]
+	if !ok {
+		return nil, nil, false
+	}
+	if w == nil {
+		return nil, memcache.ErrCacheMiss, true
+	}
+	cp := *w
+	return &cp, nil, true
+}
@@ -0,0 +1,44 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(StringItem("ryw_a", "server"))
+	mc.Set(StringItem("ryw_b", "server"))
+	mc.Set(StringItem("ryw_c", "server"))
+
+	ctx := WithReadYourWrites(context.Background())
+	if err := mc.SetCtx(ctx, StringItem("ryw_a", "mine")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.DeleteCtx(ctx, "ryw_b"); err != nil {
+		t.Fatal(err)
+	}
+	// simulate a lagging or overwritten server copy
+	mc.Set(StringItem("ryw_a", "lagging"))
+	mc.Set(StringItem("ryw_b", "lagging"))
+
+	if i, err := mc.GetCtx(ctx, "ryw_a"); err != nil || string(i.Value) != "mine" {
+		t.Errorf("Expected own write, got: %v %v", i, err)
+	}
+	if _, err := mc.GetCtx(ctx, "ryw_b"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected own delete, got: %v", err)
+	}
+	m, err := mc.GetMultiCtx(ctx, []string{"ryw_a", "ryw_b", "ryw_c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || string(m["ryw_a"].Value) != "mine" || string(m["ryw_c"].Value) != "server" {
+		t.Errorf("unexpected items %v", m)
+	}
+
+	if i, err := mc.GetCtx(context.Background(), "ryw_a"); err != nil || string(i.Value) != "lagging" {
+		t.Errorf("Expected other requests to read the server, got: %v %v", i, err)
+	}
+}
@@ -0,0 +1,56 @@
+package memcache
+
+import (
+	"strings"
+)
+
+// KeyPattern is a key sanitizer (see Options.KeySanitizer) replacing the parts
+// of key that look like identifiers with "*", e.g. "user:1234:profile" becomes
+// "user:*:profile". Keys are split on ':', '/', '.' and '|'; a part is an
+// identifier if it's all digits, or is at least 8 characters of letters,
+// digits, '-' and '_' including a digit (hex digests, UUIDs and HashKey keys).
+func KeyPattern(key string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(key); i++ {
+		if i < len(key) && !strings.ContainsRune(":/.|", rune(key[i])) {
+			continue
+		}
+		if part := key[start:i]; isIdentifier(part) {
+			b.WriteByte('*')
+		} else {
+			b.WriteString(part)
+		}
+		if i < len(key) {
+			b.WriteByte(key[i])
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
+// isIdentifier reports whether a part of a key looks like an identifier
+func isIdentifier(part string) bool {
+	if part == "" {
+		return false
+	}
+	digits := 0
+	for i := 0; i < len(part); i++ {
+		switch c := part[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return digits == len(part) || (digits > 0 && len(part) >= 8)
+}
+
+// sanitizeKey returns key as it may appear in logs, metric tags and traces
+func (c *Client) sanitizeKey(key string) string {
+	if c.opts.KeySanitizer == nil || key == "" {
+		return key
+	}
+	return c.opts.KeySanitizer(key)
+}
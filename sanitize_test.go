@@ -0,0 +1,54 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyPattern(t *testing.T) {
+	for key, want := range map[string]string{
+		"user:1234:profile":           "user:*:profile",
+		"user:1234":                   "user:*",
+		"session:9f86d081884c7d65:v2": "session:*:v2",
+		"obj/123e4567-e89b-12d3-a456-426614174000/meta": "obj/*/meta",
+		"feed.en_US.page.3":                             "feed.en_US.page.*",
+		"config":                                        "config",
+		"a::b":                                          "a::b",
+		"":                                              "",
+	} {
+		if got := KeyPattern(key); got != want {
+			t.Errorf("KeyPattern(%q) expected %q got %q", key, want, got)
+		}
+	}
+}
+
+func TestKeySanitizer(t *testing.T) {
+	var writes []WriteRecord
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		KeySanitizer:    KeyPattern,
+		SlowOpThreshold: time.Nanosecond,
+		HotKeys:         10,
+		OnWrite:         func(r WriteRecord) { writes = append(writes, r) },
+		WriteSampleRate: 1,
+	})
+	for _, k := range []string{"sanitize:1", "sanitize:2"} {
+		if err := mc.SetString(k, "v"); err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := mc.GetString(k); !ok || s != "v" {
+			t.Errorf("Expected the raw key to be read got %q %v", s, ok)
+		}
+	}
+	mc.GetMulti([]string{"sanitize:1", "sanitize:3"})
+	for _, op := range mc.SlowOps() {
+		if op.Key != "sanitize:*" {
+			t.Errorf("unexpected slow op key %q", op.Key)
+		}
+	}
+	if hot := mc.HotKeys(); len(hot) != 1 || hot[0] != (HotKey{"sanitize:*", 4}) {
+		t.Errorf("unexpected hot keys %v", hot)
+	}
+	if len(writes) != 2 || writes[0].Key != "sanitize:*" || writes[0].Namespace != "sanitize" {
+		t.Errorf("unexpected write records %v", writes)
+	}
+}
@@ -0,0 +1,235 @@
+package memcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// FLAG_SEALED marks values encrypted or signed by a SealStage
+const FLAG_SEALED uint32 = 1 << 20
+
+// ErrSealBroken is returned reading a sealed value that fails verification:
+// tampered with, truncated or sealed under another key with the same ID. With
+// SealStage.Require it's also returned for values that aren't sealed.
+var ErrSealBroken = errors.New("memcache: sealed value failed verification")
+
+// ErrSealKey is returned reading a value sealed under a key ID the SealStage
+// doesn't hold
+var ErrSealKey = errors.New("memcache: value sealed with an unknown key")
+
+const (
+	sealVersion   = 1
+	sealEncrypted = 1 << 0
+	sealSigned    = 1 << 1
+	sealKeyed     = 1 << 2
+)
+
+// SealStage is the Pipeline Encrypt stage encrypting values with AES-GCM
+// and/or signing them with HMAC-SHA256, for values such as PII that must not
+// be readable or forgeable by whoever can reach the servers. Values are sealed
+// in an envelope naming the key used, so keys can be rotated: add the new key
+// to Keys, switch KeyID to it once every reader holds it, and drop the old key
+// once values sealed with it have expired. Sealed values keep their flags with
+// FLAG_SEALED set, and the flags are authenticated.
+//
+// Values sealed by a Pipeline are bound to their cache key (as passed to the
+// setter, before Options.KeyPrefix), so a value copied under another key
+// fails with ErrSealBroken rather than being read as that key's value. Values
+// sealed with Encode directly aren't bound to a key and can be moved; they
+// are read by a Pipeline unless Require is set. The envelope is
+//
+//	version (1) | mode (1 encrypted, 2 signed, 4 keyed) | key ID length | key ID | body
+//
+// where the body is the nonce (12 bytes) and AES-GCM output when encrypted,
+// followed by the HMAC-SHA256 of everything before it when signed. Both use
+// the envelope header, the flags (without FLAG_SEALED, as 4 big endian bytes)
+// and, when keyed, the cache key as additional data. Python readers open
+// values with
+//
+//	header, body = value[:3 + value[2]], value[3 + value[2]:]
+//	secret = keys[header[3:].decode()]
+//	flags &= ~(1 << 20)
+//	aad = header + flags.to_bytes(4, 'big')
+//	if value[1] & 4:
+//	    aad += key.encode()
+//	if value[1] & 2:
+//	    body, mac = body[:-32], body[-32:]
+//	    if not hmac.compare_digest(mac, hmac.new(secret, aad + body, hashlib.sha256).digest()):
+//	        raise ValueError('bad signature')
+//	if value[1] & 1:
+//	    body = AESGCM(secret).decrypt(body[:12], body[12:], aad)  # cryptography
+//
+// Only the typed setters and getters go through the Pipeline; Get and Set
+// store items as they are.
+type SealStage struct {
+	// Keys holds the keys by ID (at most 255 bytes). Keys used to encrypt must
+	// be 16, 24 or 32 bytes for AES-128, AES-192 or AES-256.
+	Keys map[string][]byte
+	// KeyID is the key sealing new values
+	KeyID string
+	// Encrypt encrypts values with AES-GCM, which also authenticates them
+	Encrypt bool
+	// Sign appends an HMAC-SHA256 of the envelope, leaving values readable
+	// unless Encrypt is set too
+	Sign bool
+	// Require fails reads of values that aren't sealed, or that a Pipeline
+	// reads without them being bound to their key, with ErrSealBroken rather
+	// than passing them through, so values written without the stage can't
+	// be slipped in
+	Require bool
+}
+
+// Encode seals value with the key KeyID without binding it to a cache key
+func (s SealStage) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return s.seal(value, flags, nil)
+}
+
+// EncodeKey seals value with the key KeyID bound to the cache key
+func (s SealStage) EncodeKey(key string, value []byte, flags uint32) ([]byte, uint32, error) {
+	return s.seal(value, flags, []byte(key))
+}
+
+// seal seals value, bound to cacheKey unless it's nil
+func (s SealStage) seal(value []byte, flags uint32, cacheKey []byte) ([]byte, uint32, error) {
+	if flags&FLAG_SEALED != 0 {
+		return value, flags, nil
+	}
+	key, ok := s.Keys[s.KeyID]
+	if !ok {
+		return nil, 0, fmt.Errorf("memcache: SealStage has no key %q", s.KeyID)
+	}
+	if len(s.KeyID) > 255 {
+		return nil, 0, fmt.Errorf("memcache: SealStage key ID %q longer than 255 bytes", s.KeyID)
+	}
+	if !s.Encrypt && !s.Sign {
+		return nil, 0, errors.New("memcache: SealStage neither encrypts nor signs")
+	}
+	var mode byte
+	if s.Encrypt {
+		mode |= sealEncrypted
+	}
+	if s.Sign {
+		mode |= sealSigned
+	}
+	if cacheKey != nil {
+		mode |= sealKeyed
+	}
+	header := append([]byte{sealVersion, mode, byte(len(s.KeyID))}, s.KeyID...)
+	aad := sealAAD(header, flags, cacheKey)
+	out := header
+	if s.Encrypt {
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, 0, err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, nonce...)
+		out = gcm.Seal(out, nonce, value, aad)
+	} else {
+		out = append(out, value...)
+	}
+	if s.Sign {
+		out = append(out, sealMAC(key, aad, out[len(header):])...)
+	}
+	return out, flags | FLAG_SEALED, nil
+}
+
+// Decode verifies and opens values with FLAG_SEALED set. Values bound to a
+// cache key fail with ErrSealBroken; they're opened with DecodeKey.
+func (s SealStage) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	return s.open(value, flags, nil)
+}
+
+// DecodeKey verifies and opens values with FLAG_SEALED set that were read
+// under the cache key
+func (s SealStage) DecodeKey(key string, value []byte, flags uint32) ([]byte, uint32, error) {
+	return s.open(value, flags, []byte(key))
+}
+
+// open opens value read under cacheKey, or outside of any key when nil
+func (s SealStage) open(value []byte, flags uint32, cacheKey []byte) ([]byte, uint32, error) {
+	if flags&FLAG_SEALED == 0 {
+		if s.Require {
+			return nil, 0, ErrSealBroken
+		}
+		return value, flags, nil
+	}
+	flags &^= FLAG_SEALED
+	if len(value) < 3 || value[0] != sealVersion || len(value) < 3+int(value[2]) {
+		return nil, 0, ErrSealBroken
+	}
+	header, body := value[:3+int(value[2])], value[3+int(value[2]):]
+	mode := header[1]
+	if mode&(sealEncrypted|sealSigned) == 0 || (s.Require && (s.Encrypt && mode&sealEncrypted == 0 || s.Sign && mode&sealSigned == 0)) {
+		return nil, 0, ErrSealBroken
+	}
+	if mode&sealKeyed == 0 {
+		if cacheKey != nil && s.Require {
+			return nil, 0, ErrSealBroken
+		}
+		cacheKey = nil
+	} else if cacheKey == nil {
+		return nil, 0, ErrSealBroken
+	}
+	key, ok := s.Keys[string(header[3:])]
+	if !ok {
+		return nil, 0, ErrSealKey
+	}
+	aad := sealAAD(header, flags, cacheKey)
+	if mode&sealSigned != 0 {
+		if len(body) < sha256.Size {
+			return nil, 0, ErrSealBroken
+		}
+		mac := body[len(body)-sha256.Size:]
+		body = body[:len(body)-sha256.Size]
+		if !hmac.Equal(mac, sealMAC(key, aad, body)) {
+			return nil, 0, ErrSealBroken
+		}
+	}
+	if mode&sealEncrypted != 0 {
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(body) < gcm.NonceSize() {
+			return nil, 0, ErrSealBroken
+		}
+		if body, err = gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], aad); err != nil {
+			return nil, 0, ErrSealBroken
+		}
+	}
+	return body, flags, nil
+}
+
+// sealAAD returns the data authenticated with a sealed value besides its body
+func sealAAD(header []byte, flags uint32, cacheKey []byte) []byte {
+	aad := make([]byte, len(header), len(header)+4+len(cacheKey))
+	copy(aad, header)
+	aad = binary.BigEndian.AppendUint32(aad, flags)
+	return append(aad, cacheKey...)
+}
+
+// sealMAC returns the HMAC-SHA256 of a sealed value's additional data and body
+func sealMAC(key, aad, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(aad)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("memcache: SealStage: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
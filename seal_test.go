@@ -0,0 +1,121 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	sealKey1 = bytes.Repeat([]byte{1}, 32)
+	sealKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestSealStage(t *testing.T) {
+	for _, s := range []SealStage{
+		{Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Encrypt: true},
+		{Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Sign: true},
+		{Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Encrypt: true, Sign: true},
+	} {
+		sealed, flags, err := s.Encode([]byte("alice@example.com"), FLAG_PICKLE)
+		if err != nil || flags != FLAG_PICKLE|FLAG_SEALED || sealed[0] != 1 || sealed[2] != 2 || string(sealed[3:5]) != "k1" {
+			t.Fatalf("Expected a sealed value, got %q %d %v", sealed, flags, err)
+		}
+		if s.Encrypt == bytes.Contains(sealed, []byte("alice")) {
+			t.Errorf("Expected the value encrypted only with Encrypt, got %q", sealed)
+		}
+		if v, f, err := s.Decode(sealed, flags); err != nil || string(v) != "alice@example.com" || f != FLAG_PICKLE {
+			t.Errorf("Expected the value opened, got %q %d %v", v, f, err)
+		}
+
+		tampered := append([]byte{}, sealed...)
+		tampered[len(tampered)-1] ^= 1
+		if _, _, err := s.Decode(tampered, flags); err != ErrSealBroken {
+			t.Errorf("Expected a tampered value to fail, got %v", err)
+		}
+		if _, _, err := s.Decode(sealed, flags|FLAG_ZLIB); err != ErrSealBroken {
+			t.Errorf("Expected changed flags to fail, got %v", err)
+		}
+	}
+
+	// unsealed values pass through unless required
+	s := SealStage{Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Encrypt: true}
+	if v, _, err := s.Decode([]byte("plain"), FLAG_NONE); err != nil || string(v) != "plain" {
+		t.Errorf("Expected unsealed values to pass through, got %q %v", v, err)
+	}
+	s.Require = true
+	if _, _, err := s.Decode([]byte("plain"), FLAG_NONE); err != ErrSealBroken {
+		t.Errorf("Expected Require to reject unsealed values, got %v", err)
+	}
+
+	if _, _, err := (SealStage{Keys: map[string][]byte{"k1": []byte("short")}, KeyID: "k1", Encrypt: true}).Encode([]byte("v"), 0); err == nil {
+		t.Error("Expected an invalid AES key to fail")
+	}
+}
+
+func TestSealStageRotation(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	old := NewClientWithOptions([]string{LocalAddress}, Options{Pipeline: Pipeline{Encrypt: SealStage{
+		Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Encrypt: true,
+	}}})
+	rotated := NewClientWithOptions([]string{LocalAddress}, Options{Pipeline: Pipeline{Encrypt: SealStage{
+		Keys: map[string][]byte{"k1": sealKey1, "k2": sealKey2}, KeyID: "k2", Encrypt: true,
+	}}})
+
+	if err := old.SetString("seal_old", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.SetString("seal_new", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := rotated.GetString("seal_old"); !ok || s != "v1" {
+		t.Errorf("Expected values sealed with the old key to open, got %q %v", s, ok)
+	}
+	if _, err := old.GetStringE("seal_new"); !errors.Is(err, ErrSealKey) {
+		t.Errorf("Expected ErrSealKey reading a newer key, got %v", err)
+	}
+	if i, err := mc.Get("seal_new"); err != nil || i.Flags != FLAG_SEALED || bytes.Contains(i.Value, []byte("v2")) {
+		t.Errorf("Expected the value stored sealed, got %v %v", i, err)
+	}
+}
+
+func TestSealStageKeyed(t *testing.T) {
+	s := SealStage{Keys: map[string][]byte{"k1": sealKey1}, KeyID: "k1", Encrypt: true, Sign: true}
+	mc := NewClient([]string{LocalAddress})
+	sealed := NewClientWithOptions([]string{LocalAddress}, Options{Pipeline: Pipeline{Encrypt: s}})
+	if err := sealed.SetString("seal_keyed_a", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := sealed.GetString("seal_keyed_a"); !ok || v != "secret" {
+		t.Errorf("Expected the value opened under its key, got %q %v", v, ok)
+	}
+
+	// a value copied under another key doesn't open
+	i, err := mc.Get("seal_keyed_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Key = "seal_keyed_b"
+	if err := mc.Set(i); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sealed.GetStringE("seal_keyed_b"); !errors.Is(err, ErrSealBroken) {
+		t.Errorf("Expected ErrSealBroken for a moved value, got %v", err)
+	}
+	if _, _, err := s.Decode(i.Value, i.Flags); err != ErrSealBroken {
+		t.Errorf("Expected Decode to reject a keyed value, got %v", err)
+	}
+
+	// values sealed without a key open unless Require is set
+	value, flags, err := s.Encode([]byte("unbound"), FLAG_NONE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := s.DecodeKey("any", value, flags); err != nil || string(v) != "unbound" {
+		t.Errorf("Expected an unbound value to open, got %q %v", v, err)
+	}
+	s.Require = true
+	if _, _, err := s.DecodeKey("any", value, flags); err != ErrSealBroken {
+		t.Errorf("Expected Require to reject an unbound value, got %v", err)
+	}
+}
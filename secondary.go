@@ -0,0 +1,84 @@
+package memcache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricSecondaryReads counts keys read from Options.Secondary after missing
+// locally, tagged by result: "hit", "miss" or "error"
+const MetricSecondaryReads = "memcache.secondary.reads"
+
+// MetricSecondaryBackfills counts values read from Options.Secondary added to
+// this client's servers, tagged by whether the add was "stored"
+const MetricSecondaryBackfills = "memcache.secondary.backfills"
+
+// secondaryGet reads key from Options.Secondary when the local Get missed. The
+// local miss is returned when the secondary misses or fails.
+func (c *Client) secondaryGet(key string, i *memcache.Item, err error) (*memcache.Item, error) {
+	if c.opts.Secondary == nil || err != memcache.ErrCacheMiss {
+		return i, err
+	}
+	si, serr := c.opts.Secondary.Get(key)
+	switch serr {
+	case nil:
+		c.opts.Metrics.Count(MetricSecondaryReads, 1, map[string]string{"result": "hit"})
+		c.backfill(si)
+		return si, nil
+	case memcache.ErrCacheMiss:
+		c.opts.Metrics.Count(MetricSecondaryReads, 1, map[string]string{"result": "miss"})
+	default:
+		c.opts.Metrics.Count(MetricSecondaryReads, 1, map[string]string{"result": "error"})
+	}
+	return i, err
+}
+
+// secondaryGetMulti reads the keys missing from a successful local GetMulti
+// from Options.Secondary, adding those found to m
+func (c *Client) secondaryGetMulti(keys []string, m map[string]*memcache.Item, err error) (map[string]*memcache.Item, error) {
+	if c.opts.Secondary == nil || err != nil {
+		return m, err
+	}
+	var missing []string
+	for _, k := range keys {
+		if _, ok := m[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return m, nil
+	}
+	sm, serr := c.opts.Secondary.GetMulti(missing)
+	if serr != nil {
+		c.opts.Metrics.Count(MetricSecondaryReads, int64(len(missing)), map[string]string{"result": "error"})
+		return m, nil
+	}
+	if m == nil {
+		m = make(map[string]*memcache.Item, len(keys))
+	}
+	for k, i := range sm {
+		m[k] = i
+		c.backfill(i)
+	}
+	if len(sm) > 0 {
+		c.opts.Metrics.Count(MetricSecondaryReads, int64(len(sm)), map[string]string{"result": "hit"})
+	}
+	if len(sm) < len(missing) {
+		c.opts.Metrics.Count(MetricSecondaryReads, int64(len(missing)-len(sm)), map[string]string{"result": "miss"})
+	}
+	return m, nil
+}
+
+// backfill adds i, read from Options.Secondary, to this client's servers when
+// Options.BackfillTTL is set. Add doesn't clobber a value written since the
+// local miss. Backfilling is best effort and doesn't fail the read.
+func (c *Client) backfill(i *memcache.Item) {
+	if c.opts.BackfillTTL <= 0 {
+		return
+	}
+	err := c.write(OpAdd, &memcache.Item{Key: i.Key, Value: i.Value, Flags: i.Flags, Expiration: ttlSeconds(c.opts.BackfillTTL)}, c.Client.Add)
+	stored := "true"
+	if err != nil {
+		stored = "false"
+	}
+	c.opts.Metrics.Count(MetricSecondaryBackfills, 1, map[string]string{"stored": stored})
+}
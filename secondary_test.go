@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestSecondary(t *testing.T) {
+	secondary := NewClient([]string{"127.0.0.1:11211"})
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Secondary: secondary, BackfillTTL: time.Minute, Metrics: metrics})
+	plain := NewClient([]string{LocalAddress})
+	for _, k := range []string{"secondary_a", "secondary_b", "secondary_missing"} {
+		mc.Delete(k)
+		secondary.Delete(k)
+	}
+	secondary.Set(UnicodeItem("secondary_a", "regional"))
+	secondary.Set(StringItem("secondary_b", "b"))
+
+	if s, ok := mc.GetString("secondary_a"); !ok || s != "regional" {
+		t.Errorf("Expected the secondary value got %q %v", s, ok)
+	}
+	if i, err := plain.Get("secondary_a"); err != nil || i.Flags != FLAG_PICKLE {
+		t.Errorf("Expected the value to be backfilled got %v %v", i, err)
+	}
+	if _, err := mc.Get("secondary_missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+
+	m, err := mc.GetMulti([]string{"secondary_a", "secondary_b", "secondary_missing"})
+	if err != nil || len(m) != 2 || string(m["secondary_b"].Value) != "b" {
+		t.Errorf("unexpected GetMulti %v %v", m, err)
+	}
+	if _, err := plain.Get("secondary_b"); err != nil {
+		t.Errorf("Expected secondary_b to be backfilled got %v", err)
+	}
+	// hits of secondary_a and secondary_b, secondary_missing missed twice
+	if n := metrics.get(MetricSecondaryReads); n != 4 {
+		t.Errorf("Expected 4 secondary reads got %d", n)
+	}
+	if n := metrics.get(MetricSecondaryBackfills); n != 2 {
+		t.Errorf("Expected 2 backfills got %d", n)
+	}
+
+	// without BackfillTTL nothing is written locally
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{Secondary: secondary})
+	secondary.Set(StringItem("secondary_c", "c"))
+	plain.Delete("secondary_c")
+	if s, ok := mc.GetString("secondary_c"); !ok || s != "c" {
+		t.Errorf("Expected the secondary value got %q %v", s, ok)
+	}
+	if _, err := plain.Get("secondary_c"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected no backfill got %v", err)
+	}
+}
+
+func TestSecondaryBatched(t *testing.T) {
+	secondary := NewClient([]string{"127.0.0.1:11211"})
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{Secondary: secondary, BatchWindow: time.Millisecond, Metrics: metrics})
+	mc.Delete("secondary_batched")
+	secondary.Delete("secondary_batched")
+	if _, err := mc.Get("secondary_batched"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss got %v", err)
+	}
+	if n := metrics.get(MetricSecondaryReads); n != 1 {
+		t.Errorf("Expected the secondary read once got %d", n)
+	}
+}
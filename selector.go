@@ -0,0 +1,157 @@
+package memcache
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+// Distribution selects how keys are distributed over servers
+type Distribution int
+
+const (
+	// DistributionKetama is libmemcached's ketama consistent hashing, which
+	// moves few keys when servers are added or removed
+	DistributionKetama Distribution = iota
+	// DistributionModula is libmemcached's default distribution (pylibmc
+	// without the ketama behavior): the key's hash modulo the number of
+	// servers, which moves most keys when servers change. Servers must be
+	// given in the same order as to the Python clients, and
+	// Options.FailureDetector doesn't move the keys of failed servers.
+	DistributionModula
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case DistributionKetama:
+		return "ketama"
+	case DistributionModula:
+		return "modula"
+	}
+	return fmt.Sprintf("Distribution(%d)", int(d))
+}
+
+// ringSelector adapts a ketamacompat.Ring to memcache.ServerSelector
+type ringSelector struct {
+	ring  *ketamacompat.Ring
+	addrs map[string]net.Addr
+	order []net.Addr
+	// available, when set, reports whether a server may receive keys; keys of
+	// unavailable servers go to the next available server on the ring
+	available func(server string) bool
+}
+
+func newRingSelector(ring *ketamacompat.Ring) *ringSelector {
+	s := &ringSelector{ring: ring, addrs: make(map[string]net.Addr)}
+	for _, server := range ring.Servers() {
+		if _, ok := s.addrs[server]; ok {
+			continue
+		}
+		// construct our own address instead of net.ResolveTCPAddress since we want to
+		// keep hostnames for hashing instead of the actual ip address
+		addr := newHostAddress(server)
+		s.addrs[server] = addr
+		s.order = append(s.order, addr)
+	}
+	return s
+}
+
+func (s *ringSelector) PickServer(key string) (net.Addr, error) {
+	var server string
+	if s.available != nil {
+		server = s.ring.ServerForFunc(key, s.available)
+	}
+	if server == "" {
+		// with every server unavailable keys still go to their owner
+		server = s.ring.ServerFor(key)
+	}
+	if server == "" {
+		return nil, memcache.ErrNoServers
+	}
+	return s.addrs[server], nil
+}
+
+func (s *ringSelector) Each(f func(net.Addr) error) error {
+	for _, addr := range s.order {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modulaSelector places keys like libmemcached's MEMCACHED_DISTRIBUTION_MODULA:
+// on the server at the key's hash modulo the number of servers, in the order
+// configured
+type modulaSelector struct {
+	addrs []net.Addr
+	order []net.Addr
+	hash  ketamacompat.HashFunc
+}
+
+func newModulaSelector(servers []string, hash ketamacompat.HashFunc) *modulaSelector {
+	s := &modulaSelector{hash: hash}
+	seen := make(map[string]net.Addr)
+	for _, server := range servers {
+		addr, ok := seen[server]
+		if !ok {
+			addr = newHostAddress(server)
+			seen[server] = addr
+			s.order = append(s.order, addr)
+		}
+		// repeated servers keep their slots, as in libmemcached
+		s.addrs = append(s.addrs, addr)
+	}
+	return s
+}
+
+func (s *modulaSelector) PickServer(key string) (net.Addr, error) {
+	switch len(s.addrs) {
+	case 0:
+		return nil, memcache.ErrNoServers
+	case 1:
+		// libmemcached doesn't hash keys for a single server
+		return s.addrs[0], nil
+	}
+	return s.addrs[s.hash(key)%uint32(len(s.addrs))], nil
+}
+
+func (s *modulaSelector) Each(f func(net.Addr) error) error {
+	for _, addr := range s.order {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServerForKey returns the server key is sent to: its owner on the ketama
+// continuum, or the next available server while Options.FailureDetector
+// reports the owner down
+func (c *Client) ServerForKey(key string) (net.Addr, error) {
+	return c.selector.PickServer(c.fullKey(key))
+}
+
+// Continuum returns the ketama continuum in hash order, each point with the
+// server owning keys hashing up to it, to compare with libmemcached's
+// (memcached_server_by_key or the ketama_test tool) during migrations. It
+// returns nil in ProxyMode and with DistributionModula.
+func (c *Client) Continuum() []ketamacompat.Point {
+	if rs, ok := c.selector.current().(*ringSelector); ok {
+		return rs.ring.Points()
+	}
+	return nil
+}
+
+// KetamaCollisions returns the continuum points shared by different servers,
+// whose keys clients breaking ties differently than libmemcached place
+// differently (see ketamacompat.Ring.Collisions). It returns nil in ProxyMode
+// and with DistributionModula.
+func (c *Client) KetamaCollisions() []ketamacompat.Collision {
+	if rs, ok := c.selector.current().(*ringSelector); ok {
+		return rs.ring.Collisions()
+	}
+	return nil
+}
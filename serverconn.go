@@ -0,0 +1,144 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// serverConn is a dedicated connection to one server, outside the memcache.Client
+// pool, for commands the underlying client doesn't support (stats, metadump...)
+type serverConn struct {
+	nc       net.Conn
+	rw       *bufio.ReadWriter
+	timeout  time.Duration
+	deadline time.Time
+	done     chan struct{} // closed to stop watching the context
+	proxy    ProxyMode
+	addr     string
+	features *ServerFeatures // nil until detected
+}
+
+// withServerConn dials addr and runs fn on the connection, closing it afterwards
+func (c *Client) withServerConn(ctx context.Context, addr net.Addr, fn func(sc *serverConn) error) error {
+	sc, err := c.dialServerConn(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer sc.close()
+	return fn(sc)
+}
+
+// dialServerConn dials addr returning a connection whose operations are
+// interrupted once ctx is done. It must be closed.
+func (c *Client) dialServerConn(ctx context.Context, addr net.Addr) (*serverConn, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = memcache.DefaultTimeout
+	}
+	dctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	nc, err := c.dial(dctx, addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+	sc := &serverConn{
+		nc:      nc,
+		rw:      bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)),
+		timeout: timeout,
+		proxy:   c.opts.ProxyMode,
+		addr:    addr.String(),
+	}
+	if f, ok := c.features.get(sc.addr); ok {
+		sc.features = &f
+	}
+	sc.deadline, _ = ctx.Deadline()
+	if ctx.Done() != nil {
+		sc.done = make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				nc.SetDeadline(time.Now())
+			case <-sc.done:
+			}
+		}()
+	}
+	return sc, nil
+}
+
+// close closes the connection
+func (sc *serverConn) close() error {
+	if sc.done != nil {
+		close(sc.done)
+	}
+	return sc.nc.Close()
+}
+
+// extendDeadline pushes the connection deadline out by the socket timeout,
+// capped at the context deadline
+func (sc *serverConn) extendDeadline() {
+	d := time.Now().Add(sc.timeout)
+	if !sc.deadline.IsZero() && sc.deadline.Before(d) {
+		d = sc.deadline
+	}
+	sc.nc.SetDeadline(d)
+}
+
+// command writes a command line terminated with \r\n
+func (sc *serverConn) command(format string, args ...interface{}) error {
+	if err := sc.writeCommand(format, args...); err != nil {
+		return err
+	}
+	return sc.rw.Flush()
+}
+
+// commandValue writes a command line followed by a data block, as for storage
+// commands
+func (sc *serverConn) commandValue(value []byte, format string, args ...interface{}) error {
+	if err := sc.writeCommand(format, args...); err != nil {
+		return err
+	}
+	sc.rw.Write(value)
+	sc.rw.WriteString("\r\n")
+	return sc.rw.Flush()
+}
+
+// writeCommand buffers a command line unless the server doesn't support it
+func (sc *serverConn) writeCommand(format string, args ...interface{}) error {
+	if err := proxyCheck(sc.proxy, format); err != nil {
+		return err
+	}
+	if err := featureCheck(sc.features, sc.addr, format); err != nil {
+		return err
+	}
+	sc.extendDeadline()
+	_, err := fmt.Fprintf(sc.rw, format+"\r\n", args...)
+	return err
+}
+
+// readLine reads one response line without the trailing \r\n
+func (sc *serverConn) readLine() (string, error) {
+	sc.extendDeadline()
+	line, err := sc.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) >= 2 && line[len(line)-2] == '\r' {
+		return line[:len(line)-2], nil
+	}
+	return line[:len(line)-1], nil
+}
+
+// servers returns the addresses of every configured server
+func (c *Client) servers() ([]net.Addr, error) {
+	var addrs []net.Addr
+	err := c.selector.Each(func(addr net.Addr) error {
+		addrs = append(addrs, addr)
+		return nil
+	})
+	return addrs, err
+}
@@ -0,0 +1,156 @@
+package memcache
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// serverStatsSamples is the number of recent operations kept per server
+const serverStatsSamples = 1024
+
+// ServerStats is a snapshot of one server's recent activity as seen by this client
+type ServerStats struct {
+	Addr string `json:"addr"`
+	// Requests and Errors count operations completed within Options.ServerStatsWindow.
+	// Misses and failed conditions (ErrCacheMiss, ErrNotStored...) are not errors.
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	// InFlight is the number of operations currently waiting on the server
+	InFlight int64 `json:"in_flight"`
+	// Latency percentiles over the operations within the window
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	// Backoff is set while new connections to the server are delayed after failed dials
+	Backoff bool `json:"backoff"`
+	// Breaker is the server's circuit state under Options.BreakerFailures
+	Breaker BreakerState `json:"breaker"`
+}
+
+// ServerStats returns a snapshot of recent latency, errors and state for every
+// server, ordered by address. Latency and error tracking require
+// Options.ServerStatsWindow to be set.
+func (c *Client) ServerStats() []ServerStats {
+	now := c.now()
+	var stats []ServerStats
+	c.selector.Each(func(addr net.Addr) error {
+		s := ServerStats{Addr: addr.String()}
+		if c.stats != nil {
+			c.stats.snapshot(&s, now)
+		}
+		if c.backoff != nil {
+			s.Backoff = c.backoff.check(s.Addr, now) != nil
+		}
+		if c.breaker != nil {
+			s.Breaker = c.breaker.state(s.Addr, now)
+		}
+		stats = append(stats, s)
+		return nil
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Addr < stats[j].Addr })
+	return stats
+}
+
+// statsTracker keeps a rolling window of operation samples per server
+type statsTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	servers map[string]*serverSamples
+}
+
+type serverSamples struct {
+	inFlight int64
+	samples  []opSample
+	next     int
+}
+
+type opSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+func newStatsTracker(window time.Duration) *statsTracker {
+	return &statsTracker{
+		window:  window,
+		servers: make(map[string]*serverSamples),
+	}
+}
+
+func (t *statsTracker) server(addr string) *serverSamples {
+	s, ok := t.servers[addr]
+	if !ok {
+		s = &serverSamples{samples: make([]opSample, 0, serverStatsSamples)}
+		t.servers[addr] = s
+	}
+	return s
+}
+
+// start records the beginning of an operation against addr returning a func to
+// call with its result
+func (t *statsTracker) start(addr string, now time.Time) func(err error, end time.Time) {
+	t.mu.Lock()
+	t.server(addr).inFlight++
+	t.mu.Unlock()
+	return func(err error, end time.Time) {
+		sample := opSample{
+			at:      end,
+			latency: end.Sub(now),
+			failed:  err != nil && !isProtocolError(err),
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		s := t.server(addr)
+		s.inFlight--
+		if len(s.samples) < serverStatsSamples {
+			s.samples = append(s.samples, sample)
+		} else {
+			s.samples[s.next] = sample
+			s.next = (s.next + 1) % serverStatsSamples
+		}
+	}
+}
+
+// snapshot fills the tracked fields of stats from samples within the window
+func (t *statsTracker) snapshot(stats *ServerStats, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.servers[stats.Addr]
+	if !ok {
+		return
+	}
+	stats.InFlight = s.inFlight
+	cutoff := now.Add(-t.window)
+	var latencies []time.Duration
+	for _, sample := range s.samples {
+		if sample.at.Before(cutoff) {
+			continue
+		}
+		stats.Requests++
+		if sample.failed {
+			stats.Errors++
+		}
+		latencies = append(latencies, sample.latency)
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.P50 = percentile(latencies, 0.50)
+	stats.P95 = percentile(latencies, 0.95)
+	stats.P99 = percentile(latencies, 0.99)
+}
+
+// percentile returns the p quantile of sorted (nearest rank)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
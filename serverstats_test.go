@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerStats(t *testing.T) {
+	down := closedAddr(t)
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211", down}, Options{
+		ServerStatsWindow: time.Minute,
+		ReconnectBackoff:  time.Minute,
+	})
+	for i := 0; i < 20; i++ {
+		mc.Set(StringItem("stats_"+string(rune('a'+i)), "v"))
+	}
+	mc.GetMulti([]string{"stats_a", "stats_b", "stats_c", "stats_d"})
+
+	stats := mc.ServerStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 servers, got: %v", stats)
+	}
+	var requests int64
+	for _, s := range stats {
+		requests += s.Requests
+		if s.InFlight != 0 {
+			t.Errorf("Expected nothing in flight for %s, got: %d", s.Addr, s.InFlight)
+		}
+		switch s.Addr {
+		case down:
+			if s.Requests > 0 && s.Errors != s.Requests {
+				t.Errorf("Expected every request to %s to fail: %+v", s.Addr, s)
+			}
+			if s.Requests > 0 && !s.Backoff {
+				t.Errorf("Expected %s to be in reconnect backoff", s.Addr)
+			}
+		default:
+			if s.Errors != 0 {
+				t.Errorf("Unexpected errors for %s: %+v", s.Addr, s)
+			}
+			if s.Requests > 0 && s.P99 < s.P50 {
+				t.Errorf("Expected p99 >= p50: %+v", s)
+			}
+		}
+	}
+	if requests < 21 {
+		t.Errorf("Expected at least 21 requests recorded, got: %d", requests)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	for p, expected := range map[float64]time.Duration{0.5: 50, 0.95: 95, 0.99: 99, 1: 100} {
+		if got := percentile(sorted, p); got != expected {
+			t.Errorf("p%v Expected %d, got: %d", p, expected, got)
+		}
+	}
+}
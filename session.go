@@ -0,0 +1,245 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrSessionServer is returned for keys owned by another server than the one a
+// Session is pinned to
+var ErrSessionServer = errors.New("memcache: key isn't owned by the session's server")
+
+// ErrSessionClosed is returned for operations on a closed Session
+var ErrSessionClosed = errors.New("memcache: session closed")
+
+// Session pins a short sequence of operations to a single connection to one
+// server. Behind proxies such as twemproxy or mcrouter, a CAS token from gets
+// may only be valid on the backend connection that returned it, which pooled
+// connections don't guarantee. A Session is not safe for concurrent use.
+type Session struct {
+	c    *Client
+	addr net.Addr
+	sc   *serverConn
+	err  error // sticky connection error; the session can't be used after one
+	once sync.Once
+}
+
+// Checkout returns a Session on its own connection to the server owning key.
+// Every key used in the session must be owned by that server. ctx bounds the
+// whole session, which must be closed.
+func (c *Client) Checkout(ctx context.Context, key string) (*Session, error) {
+	addr, err := c.selector.PickServer(c.fullKey(key))
+	if err != nil {
+		return nil, err
+	}
+	sc, err := c.dialServerConn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{c: c, addr: addr, sc: sc}, nil
+}
+
+// Addr returns the server the session is pinned to
+func (s *Session) Addr() net.Addr { return s.addr }
+
+// Close releases the session's connection
+func (s *Session) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.sc.close()
+		if s.err == nil {
+			s.err = ErrSessionClosed
+		}
+	})
+	return err
+}
+
+// Get gets key with its CAS ID for CompareAndSwap
+func (s *Session) Get(key string) (*memcache.Item, error) {
+	item, err := s.get(s.c.fullKey(key))
+	return unprefixed(item, key), err
+}
+
+// get is Get of a full key
+func (s *Session) get(key string) (item *memcache.Item, err error) {
+	if err := s.check(key); err != nil {
+		return nil, err
+	}
+	err = s.c.do(OpGet, key, func() error {
+		return s.run(func() error {
+			if err := s.sc.command("gets %s", key); err != nil {
+				return err
+			}
+			for {
+				line, err := s.sc.readLine()
+				if err != nil {
+					return err
+				}
+				if line == "END" {
+					if item == nil {
+						return memcache.ErrCacheMiss
+					}
+					return nil
+				}
+				i, err := s.readValue(line)
+				if err != nil {
+					return err
+				}
+				item = i
+			}
+		})
+	})
+	return item, err
+}
+
+// readValue reads the data of a "VALUE <key> <flags> <bytes> <cas>" line
+func (s *Session) readValue(line string) (*memcache.Item, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 || fields[0] != "VALUE" {
+		return nil, fmt.Errorf("memcache: unexpected gets response from %s: %q", s.addr, line)
+	}
+	flags, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("memcache: unexpected gets response from %s: %q", s.addr, line)
+	}
+	size, err := strconv.Atoi(fields[3])
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("memcache: unexpected gets response from %s: %q", s.addr, line)
+	}
+	cas, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("memcache: unexpected gets response from %s: %q", s.addr, line)
+	}
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(s.sc.rw, value); err != nil {
+		return nil, err
+	}
+	if string(value[size:]) != "\r\n" {
+		return nil, fmt.Errorf("memcache: corrupt gets response from %s", s.addr)
+	}
+	return &memcache.Item{Key: fields[1], Value: value[:size], Flags: uint32(flags), CasID: cas}, nil
+}
+
+// Set unconditionally stores item
+func (s *Session) Set(item *memcache.Item) error {
+	return s.store(OpSet, item)
+}
+
+// CompareAndSwap stores item if it hasn't changed since it was read with Get
+// in this session, returning ErrCASConflict if it has and ErrCacheMiss if it
+// was deleted
+func (s *Session) CompareAndSwap(item *memcache.Item) error {
+	return s.store(OpCompareAndSwap, item)
+}
+
+func (s *Session) store(op string, item *memcache.Item) error {
+	item = s.c.prefixed(item)
+	if err := s.check(item.Key); err != nil {
+		return err
+	}
+	return s.c.write(op, item, func(item *memcache.Item) error {
+		return s.run(func() error {
+			cas := ""
+			if op == OpCompareAndSwap {
+				cas = " " + strconv.FormatUint(item.CasID, 10)
+			}
+			s.sc.extendDeadline()
+			fmt.Fprintf(s.sc.rw, "%s %s %d %d %d%s\r\n", op, item.Key, item.Flags, item.Expiration, len(item.Value), cas)
+			s.sc.rw.Write(item.Value)
+			s.sc.rw.WriteString("\r\n")
+			if err := s.sc.rw.Flush(); err != nil {
+				return err
+			}
+			line, err := s.sc.readLine()
+			if err != nil {
+				return err
+			}
+			switch line {
+			case "STORED":
+				return nil
+			case "EXISTS":
+				return memcache.ErrCASConflict
+			case "NOT_FOUND":
+				return memcache.ErrCacheMiss
+			case "NOT_STORED":
+				return memcache.ErrNotStored
+			}
+			return fmt.Errorf("memcache: unexpected %s response from %s: %q", op, s.addr, line)
+		})
+	})
+}
+
+// Delete deletes key
+func (s *Session) Delete(key string) error {
+	key = s.c.fullKey(key)
+	if err := s.check(key); err != nil {
+		return err
+	}
+	return s.c.do(OpDelete, key, func() error {
+		return s.run(func() error {
+			if err := s.sc.command("delete %s", key); err != nil {
+				return err
+			}
+			line, err := s.sc.readLine()
+			if err != nil {
+				return err
+			}
+			switch line {
+			case "DELETED":
+				return nil
+			case "NOT_FOUND":
+				return memcache.ErrCacheMiss
+			}
+			return fmt.Errorf("memcache: unexpected delete response from %s: %q", s.addr, line)
+		})
+	})
+}
+
+// check returns an error if key can't be used in the session
+func (s *Session) check(key string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	addr, err := s.c.selector.PickServer(key)
+	if err != nil {
+		return err
+	}
+	if addr.String() != s.addr.String() {
+		return ErrSessionServer
+	}
+	return nil
+}
+
+// run runs fn making connection errors sticky since the protocol stream may be
+// out of sync after one
+func (s *Session) run(fn func() error) error {
+	err := fn()
+	if err != nil && !isProtocolError(err) {
+		s.err = err
+	}
+	return err
+}
+
+// legalKey reports whether key is valid in the memcached text protocol
+func legalKey(key string) bool {
+	if len(key) == 0 || len(key) > 250 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
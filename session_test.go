@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestSession(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	s, err := mc.Checkout(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Delete("session")
+	if _, err := s.Get("session"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+	if err := s.Set(&memcache.Item{Key: "session", Value: []byte("a"), Flags: FLAG_INTEGER}); err != nil {
+		t.Fatal(err)
+	}
+	i, err := s.Get("session")
+	if err != nil || string(i.Value) != "a" || i.Flags != FLAG_INTEGER || i.CasID == 0 {
+		t.Fatalf("unexpected item %v %v", i, err)
+	}
+	// a write from another connection invalidates the CAS token
+	mc.Set(StringItem("session", "b"))
+	i.Value = []byte("c")
+	if err := s.CompareAndSwap(i); err != memcache.ErrCASConflict {
+		t.Errorf("Expected ErrCASConflict, got %v", err)
+	}
+	i, _ = s.Get("session")
+	i.Value = []byte("c")
+	if err := s.CompareAndSwap(i); err != nil {
+		t.Errorf("Expected CAS to succeed, got %v", err)
+	}
+	if s, ok := mc.GetString("session"); !ok || s != "c" {
+		t.Errorf("Expected c, got %q %v", s, ok)
+	}
+	if err := s.Delete("session"); err != nil {
+		t.Error(err)
+	}
+	if err := s.Delete("session"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got %v", err)
+	}
+	if _, err := s.Get("bad key"); err != memcache.ErrMalformedKey {
+		t.Errorf("Expected ErrMalformedKey, got %v", err)
+	}
+	s.Close()
+	if _, err := s.Get("session"); err != ErrSessionClosed {
+		t.Errorf("Expected ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestSessionServer(t *testing.T) {
+	mc := NewClient([]string{LocalAddress, "127.0.0.1:1"})
+	var local, other string
+	for _, k := range []string{"sa", "sb", "sc", "sd", "se", "sf", "sg", "sh"} {
+		if addr, _ := mc.selector.PickServer(k); addr.String() == LocalAddress {
+			local = k
+		} else {
+			other = k
+		}
+	}
+	if local == "" || other == "" {
+		t.Fatal("keys all owned by one server")
+	}
+	s, err := mc.Checkout(context.Background(), local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Addr().String() != LocalAddress {
+		t.Errorf("Expected session on %s, got %s", LocalAddress, s.Addr())
+	}
+	if _, err := s.Get(other); err != ErrSessionServer {
+		t.Errorf("Expected ErrSessionServer, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultShardedCounterRetries is how many times Incr retries after a shard is
+// created or deleted concurrently
+const DefaultShardedCounterRetries = 10
+
+// ShardedCounter is a counter spread over Shards keys named
+// "<key>:shard:<n>" (n from 0 to Shards-1) so increments of a hot counter are
+// spread over the servers owning them rather than all going to one. Each
+// increment goes to a random shard and reads sum them. Shards hold integers
+// stored the way pylibmc stores ints, so Python can share the counter:
+//
+//	def incr(mc, key, shards, delta=1):
+//	    k = '%s:shard:%d' % (key, random.randrange(shards))
+//	    try:
+//	        mc.incr(k, delta)
+//	    except pylibmc.NotFound:
+//	        if not mc.add(k, delta):
+//	            mc.incr(k, delta)
+//
+//	def value(mc, key, shards):
+//	    keys = ['%s:shard:%d' % (key, n) for n in range(shards)]
+//	    return sum(mc.get_multi(keys).values())
+//
+// Changing Shards loses the counts of shards no longer read.
+type ShardedCounter struct {
+	c *Client
+	// Key names the counter
+	Key string
+	// Shards is the number of shard keys
+	Shards int
+	// Expiration is the expiration of shards when they are created by an
+	// increment. Zero never expires them.
+	Expiration int32
+}
+
+// ShardedCounter returns a ShardedCounter spreading key over shards keys
+func (c *Client) ShardedCounter(key string, shards int) *ShardedCounter {
+	if shards < 1 {
+		shards = 1
+	}
+	return &ShardedCounter{c: c, Key: key, Shards: shards}
+}
+
+// ShardKey returns the key of shard n
+func (s *ShardedCounter) ShardKey(n int) string {
+	return fmt.Sprintf("%s:shard:%d", s.Key, n)
+}
+
+// Keys returns the keys of every shard
+func (s *ShardedCounter) Keys() []string {
+	keys := make([]string, s.Shards)
+	for n := range keys {
+		keys[n] = s.ShardKey(n)
+	}
+	return keys
+}
+
+// Incr adds delta to a random shard, creating it if it doesn't exist
+func (s *ShardedCounter) Incr(delta uint64) error {
+	key := s.ShardKey(rand.Intn(s.Shards))
+	for attempt := 0; ; attempt++ {
+		_, err := s.c.Increment(key, delta)
+		if err == memcache.ErrCacheMiss {
+			item := Int64Item(key, int64(delta))
+			item.Expiration = s.Expiration
+			err = s.c.Add(item)
+		}
+		switch err {
+		case nil:
+			return nil
+		case memcache.ErrCacheMiss, memcache.ErrNotStored:
+			// another writer created (or deleted) the shard first
+			if attempt < DefaultShardedCounterRetries {
+				continue
+			}
+		}
+		return err
+	}
+}
+
+// Value returns the sum of every shard. Missing shards count as zero.
+func (s *ShardedCounter) Value(ctx context.Context) (int64, error) {
+	items, err := s.c.GetMultiCtx(ctx, s.Keys())
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for k, i := range items {
+		n, err := (&Item{i}).Int64()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", k, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Reset deletes every shard
+func (s *ShardedCounter) Reset() error {
+	for _, k := range s.Keys() {
+		if err := s.c.Delete(k); err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
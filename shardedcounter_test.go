@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestShardedCounter(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	s := mc.ShardedCounter("sharded", 4)
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if s.ShardKey(3) != "sharded:shard:3" || len(s.Keys()) != 4 {
+		t.Errorf("unexpected keys %v", s.Keys())
+	}
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Incr(2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := s.Value(ctx); err != nil || n != 40 {
+		t.Errorf("Expected 40 got %d %v", n, err)
+	}
+	// a shard written by python as an int
+	mc.Set(Int64Item("sharded:shard:0", 100))
+	items, _ := mc.GetMulti(s.Keys())
+	var want int64 = 100
+	for k, i := range items {
+		if k != "sharded:shard:0" {
+			n, _ := (&Item{i}).Int64()
+			want += n
+		}
+	}
+	if n, err := s.Value(ctx); err != nil || n != want {
+		t.Errorf("Expected %d got %d %v", want, n, err)
+	}
+
+	mc.Set(UnicodeItem("sharded:shard:1", "x"))
+	if _, err := s.Value(ctx); err == nil {
+		t.Error("Expected an error for a shard that isn't an int")
+	}
+	s.Reset()
+	if n, err := s.Value(ctx); err != nil || n != 0 {
+		t.Errorf("Expected 0 after Reset got %d %v", n, err)
+	}
+}
@@ -0,0 +1,223 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultSimulationStep is the simulated time between batches of Simulate requests
+const DefaultSimulationStep = 100 * time.Millisecond
+
+// Simulation describes synthetic read-through traffic replayed by Simulate to
+// predict miss storms: requests read a key and, on a miss, load and store it
+type Simulation struct {
+	// Keys is the number of distinct keys
+	Keys int
+	// Skew is the Zipf s parameter of key popularity, which must be above 1.
+	// Zero picks keys uniformly.
+	Skew float64
+	// Rate is the number of requests per simulated second
+	Rate int
+	// Duration is the simulated time to run for
+	Duration time.Duration
+	// TTL is the expiration values are stored with
+	TTL time.Duration
+	// TTLJitter randomly lengthens or shortens each TTL by up to this fraction
+	// (0-1) so keys written together don't expire together
+	TTLJitter float64
+	// LoaderCost is how long loading a missed value takes
+	LoaderCost time.Duration
+	// Warm stores every key at the start, as after a cache warm or a deploy
+	// writing a batch of keys, instead of starting with an empty cache
+	Warm bool
+
+	// Lease lets only the request that wins an add of a lease key load a missed
+	// value (dogpile locking); other requests missing it wait
+	Lease bool
+	// LeaseTTL is the expiration of lease keys. Zero uses twice LoaderCost.
+	LeaseTTL time.Duration
+	// EarlyRefresh is the beta of probabilistic early refresh (XFetch): hits
+	// load the value ahead of its expiry with a probability rising as it nears,
+	// 1 being the usual setting. Zero disables early refresh.
+	EarlyRefresh float64
+
+	// Step is the simulated time between batches of requests. Zero uses
+	// DefaultSimulationStep.
+	Step time.Duration
+	// Seed seeds key selection and jitter so runs are repeatable
+	Seed int64
+}
+
+// SimulationResult is the outcome of Simulate
+type SimulationResult struct {
+	Requests int
+	Hits     int
+	Misses   int
+	// Loads is the number of loader invocations, including early refreshes
+	Loads          int
+	EarlyRefreshes int
+	// LeaseWaits is the number of misses that waited for another request's load
+	LeaseWaits int
+	// PeakConcurrentLoads is the most loads in progress at once
+	PeakConcurrentLoads int
+	// LoadsPerSecond is the number of loads started in each simulated second
+	LoadsPerSecond []int
+}
+
+// HitRatio returns the fraction of requests that hit
+func (r *SimulationResult) HitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+// PeakLoadsPerSecond returns the most loads started in one simulated second
+func (r *SimulationResult) PeakLoadsPerSecond() int {
+	var peak int
+	for _, n := range r.LoadsPerSecond {
+		if n > peak {
+			peak = n
+		}
+	}
+	return peak
+}
+
+// Simulate replays the traffic described by sim against a private embedded
+// server (see LocalAddress) on a FakeClock, so minutes of traffic run in
+// seconds without touching a real cluster. Compare results with and without
+// Lease, EarlyRefresh or TTLJitter to evaluate them before enabling them.
+func Simulate(ctx context.Context, sim Simulation) (*SimulationResult, error) {
+	if sim.Keys <= 0 || sim.Rate <= 0 || sim.Duration <= 0 {
+		return nil, errors.New("memcache: simulation needs Keys, Rate and Duration")
+	}
+	if sim.Skew != 0 && sim.Skew <= 1 {
+		return nil, errors.New("memcache: simulation Skew must be above 1")
+	}
+	if sim.Step <= 0 {
+		sim.Step = DefaultSimulationStep
+	}
+	if sim.LeaseTTL <= 0 {
+		sim.LeaseTTL = 2 * sim.LoaderCost
+	}
+
+	clock := NewFakeClock(time.Unix(1e9, 0))
+	srv, err := newLocalServer(clock)
+	if err != nil {
+		return nil, err
+	}
+	defer srv.ln.Close()
+	c := NewClientWithOptions([]string{srv.ln.Addr().String()}, Options{Clock: clock})
+	defer c.Client.Close()
+
+	rnd := rand.New(rand.NewSource(sim.Seed))
+	next := func() uint64 { return uint64(rnd.Intn(sim.Keys)) }
+	if sim.Skew != 0 {
+		next = rand.NewZipf(rnd, sim.Skew, 1, uint64(sim.Keys-1)).Uint64
+	}
+
+	start := clock.Now()
+	r := &SimulationResult{LoadsPerSecond: make([]int, int(math.Ceil(sim.Duration.Seconds())))}
+	type load struct {
+		key  string
+		done time.Time
+	}
+	var loads []load
+	loading := make(map[string]int)
+	expires := make(map[string]time.Time)
+
+	store := func(key string, now time.Time) error {
+		ttl := sim.TTL
+		if sim.TTLJitter > 0 {
+			ttl += time.Duration((rnd.Float64()*2 - 1) * sim.TTLJitter * float64(sim.TTL))
+		}
+		expires[key] = now.Add(ttl)
+		return c.Set(&memcache.Item{Key: key, Value: []byte("v"), Expiration: ttlSeconds(ttl)})
+	}
+	startLoad := func(key string, now time.Time) {
+		r.Loads++
+		if s := int(now.Sub(start) / time.Second); s < len(r.LoadsPerSecond) {
+			r.LoadsPerSecond[s]++
+		}
+		loading[key]++
+		loads = append(loads, load{key, now.Add(sim.LoaderCost)})
+		if len(loads) > r.PeakConcurrentLoads {
+			r.PeakConcurrentLoads = len(loads)
+		}
+	}
+
+	if sim.Warm {
+		for k := 0; k < sim.Keys; k++ {
+			if err := store("sim:"+strconv.Itoa(k), start); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var pending float64
+	for now := start; now.Before(start.Add(sim.Duration)); now = clock.Now() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		kept := loads[:0]
+		for _, l := range loads {
+			if l.done.After(now) {
+				kept = append(kept, l)
+				continue
+			}
+			if err := store(l.key, now); err != nil {
+				return nil, err
+			}
+			if loading[l.key]--; loading[l.key] == 0 {
+				delete(loading, l.key)
+			}
+			if sim.Lease {
+				c.Delete("sim-lease:" + l.key)
+			}
+		}
+		loads = kept
+
+		pending += float64(sim.Rate) * sim.Step.Seconds()
+		for ; pending >= 1; pending-- {
+			key := "sim:" + strconv.FormatUint(next(), 10)
+			r.Requests++
+			_, err := c.Get(key)
+			switch err {
+			case nil:
+				r.Hits++
+				if sim.EarlyRefresh > 0 && loading[key] == 0 {
+					ahead := time.Duration(-float64(sim.LoaderCost) * sim.EarlyRefresh * math.Log(rnd.Float64()))
+					if !now.Add(ahead).Before(expires[key]) {
+						r.EarlyRefreshes++
+						startLoad(key, now)
+					}
+				}
+			case memcache.ErrCacheMiss:
+				r.Misses++
+				if !sim.Lease {
+					startLoad(key, now)
+					continue
+				}
+				err := c.Add(&memcache.Item{Key: "sim-lease:" + key, Value: []byte("1"), Expiration: ttlSeconds(sim.LeaseTTL)})
+				switch err {
+				case nil:
+					startLoad(key, now)
+				case memcache.ErrNotStored:
+					r.LeaseWaits++
+				default:
+					return nil, err
+				}
+			default:
+				return nil, err
+			}
+		}
+		clock.Advance(sim.Step)
+	}
+	return r, nil
+}
@@ -0,0 +1,68 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	sim := Simulation{
+		Keys:       50,
+		Skew:       1.2,
+		Rate:       500,
+		Duration:   15 * time.Second,
+		TTL:        10 * time.Second,
+		LoaderCost: time.Second,
+		Warm:       true,
+		Seed:       1,
+	}
+	storm, err := Simulate(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storm.Requests != 7500 || storm.Hits+storm.Misses != storm.Requests {
+		t.Errorf("unexpected request counts %+v", storm)
+	}
+	// every key was written together so they all expire after 10s
+	if storm.LoadsPerSecond[5] != 0 || storm.PeakLoadsPerSecond() != storm.LoadsPerSecond[10] {
+		t.Errorf("Expected a miss storm at 10s, got %v", storm.LoadsPerSecond)
+	}
+	if storm.Loads <= sim.Keys {
+		t.Errorf("Expected concurrent misses to load keys several times, got %d loads", storm.Loads)
+	}
+
+	sim.Lease = true
+	leased, err := Simulate(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leased.Loads > sim.Keys || leased.LeaseWaits == 0 || leased.PeakConcurrentLoads >= storm.PeakConcurrentLoads {
+		t.Errorf("Expected leases to load each key once, got %+v", leased)
+	}
+
+	sim.Lease = false
+	sim.TTLJitter = 0.3
+	jittered, err := Simulate(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jittered.PeakLoadsPerSecond() >= storm.PeakLoadsPerSecond() {
+		t.Errorf("Expected jitter to spread loads, got %v vs %v", jittered.LoadsPerSecond, storm.LoadsPerSecond)
+	}
+
+	sim.TTLJitter = 0
+	sim.EarlyRefresh = 1
+	early, err := Simulate(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if early.EarlyRefreshes == 0 || early.Misses >= storm.Misses {
+		t.Errorf("Expected early refresh to avoid misses, got %+v", early)
+	}
+
+	if _, err := Simulate(ctx, Simulation{Keys: 1, Rate: 1, Duration: time.Second, Skew: 0.5}); err == nil {
+		t.Errorf("Expected an error for Skew <= 1")
+	}
+}
@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"sync"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricCoalescedGets counts Gets answered by a concurrent Get of the same key
+// under Options.CoalesceGets instead of their own round trip
+const MetricCoalescedGets = "memcache.gets.coalesced"
+
+// flightGroup runs one read per key at a time, handing its result to every
+// caller reading the key meanwhile
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	item *memcache.Item
+	err  error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do returns the result of fn for key, or of the call of fn already in flight
+// for key, reporting whether it was shared
+func (g *flightGroup) do(key string, fn func() (*memcache.Item, error)) (*memcache.Item, error, bool) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		if f.item == nil {
+			return nil, f.err, true
+		}
+		// each caller gets its own copy, value included, to modify and CAS
+		return cloneItem(f.item), f.err, true
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.item, f.err = fn()
+	if f.item == nil {
+		return nil, f.err, false
+	}
+	// copied too so the caller's changes don't race the waiters' copies
+	return cloneItem(f.item), f.err, false
+}
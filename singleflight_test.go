@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestCoalesceGets(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{slowServer(t, 50*time.Millisecond)}, Options{CoalesceGets: true, Metrics: metrics})
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mc.Get("coalesce_key"); err != memcache.ErrCacheMiss {
+				t.Errorf("Expected a miss, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := metrics.get(MetricCoalescedGets); n == 0 || n > 19 {
+		t.Errorf("Expected concurrent Gets to share round trips, got %d shared", n)
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	g := newFlightGroup()
+	release := make(chan struct{})
+	calls := 0
+	var wg sync.WaitGroup
+	results := make([]*memcache.Item, 5)
+	for n := range results {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			i, err, _ := g.do("k", func() (*memcache.Item, error) {
+				calls++
+				<-release
+				return &memcache.Item{Key: "k", Value: []byte("v")}, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			results[n] = i
+		}(n)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	for n, i := range results {
+		if string(i.Value) != "v" {
+			t.Errorf("Expected the shared value, got %q", i.Value)
+		}
+		for _, other := range results[n+1:] {
+			if i == other || &i.Value[0] == &other.Value[0] {
+				t.Error("Expected each caller to get its own item and value")
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SlabClass is one slab class as reported by `stats slabs`
+type SlabClass struct {
+	Class        int   `json:"class"`
+	ChunkSize    int64 `json:"chunk_size"`
+	UsedChunks   int64 `json:"used_chunks"`
+	TotalPages   int64 `json:"total_pages"`
+	TotalChunks  int64 `json:"total_chunks"`
+	FreeChunks   int64 `json:"free_chunks"`
+	MemRequested int64 `json:"mem_requested"`
+}
+
+// SlabStats returns the slab classes of addr from `stats slabs`, keyed by class
+func (c *Client) SlabStats(ctx context.Context, addr net.Addr) (map[int]*SlabClass, error) {
+	classes := make(map[int]*SlabClass)
+	err := c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		return readStats(sc, addr, "stats slabs", func(name, value string) {
+			slabStat(classes, name, value)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// slabStat sets the field for a `stats slabs` value named like "1:chunk_size",
+// reporting false for totals such as active_slabs
+func slabStat(classes map[int]*SlabClass, name, value string) bool {
+	id, name, ok := strings.Cut(name, ":")
+	if !ok {
+		return false
+	}
+	class, err := strconv.Atoi(id)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return true
+	}
+	s, ok := classes[class]
+	if !ok {
+		s = &SlabClass{Class: class}
+		classes[class] = s
+	}
+	switch name {
+	case "chunk_size":
+		s.ChunkSize = n
+	case "used_chunks":
+		s.UsedChunks = n
+	case "total_pages":
+		s.TotalPages = n
+	case "total_chunks":
+		s.TotalChunks = n
+	case "free_chunks":
+		s.FreeChunks = n
+	case "mem_requested":
+		s.MemRequested = n
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// slowLogSize is the number of slow operations kept
+const slowLogSize = 100
+
+// SlowOp is an operation that took longer than Options.SlowOpThreshold
+type SlowOp struct {
+	At       time.Time     `json:"at"`
+	Op       string        `json:"op"`
+	Key      string        `json:"key"`
+	Addr     string        `json:"addr"`
+	Duration time.Duration `json:"duration"`
+	Err      string        `json:"error,omitempty"`
+	// TraceID and SpanID identify the request trace of operations made with a
+	// context carrying one (see WithTraceContext and Options.TraceExtractor)
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+// SlowOps returns the most recent slow operations, newest first. Logging requires
+// Options.SlowOpThreshold to be set.
+func (c *Client) SlowOps() []SlowOp {
+	if c.slowLog == nil {
+		return nil
+	}
+	return c.slowLog.recent()
+}
+
+// slowLog is a ring buffer of the most recent slow operations
+type slowLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	ops       []SlowOp
+	next      int
+}
+
+func newSlowLog(threshold time.Duration) *slowLog {
+	return &slowLog{threshold: threshold}
+}
+
+func (l *slowLog) record(op SlowOp) {
+	if op.Duration < l.threshold {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.ops) < slowLogSize {
+		l.ops = append(l.ops, op)
+	} else {
+		l.ops[l.next] = op
+	}
+	l.next = (l.next + 1) % slowLogSize
+}
+
+func (l *slowLog) recent() []SlowOp {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ops := make([]SlowOp, 0, len(l.ops))
+	for i := 0; i < len(l.ops); i++ {
+		// walk backwards from the most recently written entry
+		j := (l.next - 1 - i + 2*len(l.ops)) % len(l.ops)
+		ops = append(ops, l.ops[j])
+	}
+	return ops
+}
@@ -0,0 +1,158 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// ErrReadOnly is returned by write operations on a SnapshotClient
+var ErrReadOnly = errors.New("memcache: snapshot is read-only")
+
+// snapshotRecord is one line of an export file
+type snapshotRecord struct {
+	Key    string `json:"key"`
+	Flags  uint32 `json:"flags"`
+	Exp    int64  `json:"exp"`
+	Value  []byte `json:"value"`
+	Server string `json:"server"`
+}
+
+// Export writes every item in the cluster (enumerated with metadump) to w as JSON
+// lines with the raw value and flags, for loading with NewSnapshotClient. Items
+// expiring or evicted during the export are skipped. It returns the number of
+// items written.
+func (c *Client) Export(ctx context.Context, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	var n int
+	err := c.Metadump(ctx, func(server net.Addr, e MetadumpEntry) error {
+		i, err := c.Client.Get(e.Key)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		n++
+		return enc.Encode(snapshotRecord{
+			Key:    i.Key,
+			Flags:  i.Flags,
+			Exp:    e.Exp,
+			Value:  i.Value,
+			Server: server.String(),
+		})
+	})
+	return n, err
+}
+
+// SnapshotClient is a read-only Cacher serving items from an export file, for
+// offline analysis, local development without memcached and incident forensics.
+// Values are decoded exactly as they would be from a live Client.
+type SnapshotClient struct {
+	items map[string]*memcache.Item
+}
+
+var _ Cacher = (*SnapshotClient)(nil)
+
+// NewSnapshotClient loads an export written by Client.Export from r
+func NewSnapshotClient(r io.Reader) (*SnapshotClient, error) {
+	s := &SnapshotClient{items: make(map[string]*memcache.Item)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec snapshotRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		s.items[rec.Key] = &memcache.Item{Key: rec.Key, Value: rec.Value, Flags: rec.Flags}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSnapshot loads the export file at path
+func OpenSnapshot(path string) (*SnapshotClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewSnapshotClient(f)
+}
+
+// Keys returns every key in the snapshot
+func (s *SnapshotClient) Keys() []string {
+	keys := make([]string, 0, len(s.items))
+	for k := range s.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Get returns a copy of the item for key or ErrCacheMiss
+func (s *SnapshotClient) Get(key string) (*memcache.Item, error) {
+	i, ok := s.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	cp := *i
+	return &cp, nil
+}
+
+// GetMulti returns copies of the items present for keys
+func (s *SnapshotClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	m := make(map[string]*memcache.Item, len(keys))
+	for _, k := range keys {
+		if i, err := s.Get(k); err == nil {
+			m[k] = i
+		}
+	}
+	return m, nil
+}
+
+func (s *SnapshotClient) GetString(k string, _ ...CallOption) (string, bool) { return getString(s, k) }
+func (s *SnapshotClient) GetInt64(k string, _ ...CallOption) (int64, bool)   { return getInt64(s, k) }
+func (s *SnapshotClient) GetBool(k string, _ ...CallOption) (bool, bool)     { return getBool(s, k) }
+func (s *SnapshotClient) GetFloat64(k string, _ ...CallOption) (float64, bool) {
+	return getFloat64(s, k)
+}
+func (s *SnapshotClient) GetMap(k string, _ ...CallOption) (map[string]interface{}, bool) {
+	return getMap(s, k)
+}
+func (s *SnapshotClient) GetBigInt(k string, _ ...CallOption) (*big.Int, bool) {
+	return getBigInt(s, k)
+}
+func (s *SnapshotClient) GetBytes(k string, _ ...CallOption) ([]byte, bool) { return getBytes(s, k) }
+func (s *SnapshotClient) GetTime(k string, _ ...CallOption) (time.Time, bool) {
+	return getTime(s, k)
+}
+func (s *SnapshotClient) GetDecimal(k string, _ ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(s, k)
+}
+func (s *SnapshotClient) GetAny(k string, _ ...CallOption) (interface{}, Type, bool) {
+	return getAny(s, k, deserializeItem)
+}
+
+// The write operations below always fail with ErrReadOnly.
+
+func (s *SnapshotClient) Set(*memcache.Item) error                 { return ErrReadOnly }
+func (s *SnapshotClient) Add(*memcache.Item) error                 { return ErrReadOnly }
+func (s *SnapshotClient) Replace(*memcache.Item) error             { return ErrReadOnly }
+func (s *SnapshotClient) CompareAndSwap(*memcache.Item) error      { return ErrReadOnly }
+func (s *SnapshotClient) Delete(string) error                      { return ErrReadOnly }
+func (s *SnapshotClient) Touch(string, int32) error                { return ErrReadOnly }
+func (s *SnapshotClient) Increment(string, uint64) (uint64, error) { return 0, ErrReadOnly }
+func (s *SnapshotClient) Decrement(string, uint64) (uint64, error) { return 0, ErrReadOnly }
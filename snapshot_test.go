@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestSnapshotClient(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	flushAll(t, mc)
+	u := "Iñtërnâtiôn�lizætiøn"
+	mc.Set(UnicodeItem("snap_unicode", u))
+	mc.Set(Int64Item("snap_int", 7))
+	mc.Set(BoolItem("snap_bool", true))
+
+	var b bytes.Buffer
+	n, err := mc.Export(context.Background(), &b)
+	if err != nil || n != 3 {
+		t.Fatalf("Expected 3 items exported, got: %d %v", n, err)
+	}
+
+	snap, err := NewSnapshotClient(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := snap.GetString("snap_unicode"); !ok || v != u {
+		t.Errorf("Expected %q, got: %q", u, v)
+	}
+	if v, ok := snap.GetInt64("snap_int"); !ok || v != 7 {
+		t.Errorf("Expected 7, got: %v", v)
+	}
+	if v, ok := snap.GetBool("snap_bool"); !ok || !v {
+		t.Errorf("Expected true, got: %v", v)
+	}
+	if _, err := snap.Get("missing"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got: %v", err)
+	}
+	if m, _ := snap.GetMulti([]string{"snap_int", "missing"}); len(m) != 1 {
+		t.Errorf("Expected 1 item, got: %v", m)
+	}
+	if err := snap.Set(StringItem("x", "y")); err != ErrReadOnly {
+		t.Errorf("Expected ErrReadOnly, got: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// FLAG_SOFT_TTL marks values written by SetSoftTTL
+const FLAG_SOFT_TTL uint32 = 1 << 17
+
+// DefaultExpiryLeaseTTL is the ExpiryLeaseTTL used when Options.ExpiryLeaseTTL
+// is unset
+const DefaultExpiryLeaseTTL = 10 * time.Second
+
+// ExpiryEvent describes a key read past the soft TTL it was written with
+type ExpiryEvent struct {
+	Key string
+	// StaleAt is when the value went stale
+	StaleAt time.Time
+	// Item is the stale item read, without its soft TTL envelope
+	Item *memcache.Item
+}
+
+// SetSoftTTL stores item with a soft TTL: the value is served as usual until
+// its Expiration (the hard TTL) but is stale once soft has passed, which reads
+// report to OnProbableExpiry callbacks. The value is prefixed with a line
+// recording when it goes stale, e.g. "stale=1700000000.123\n", so Python
+// readers must strip it with
+//
+//	meta, _, value = raw.partition(b"\n")
+//
+// Reads through a Client strip it.
+func (c *Client) SetSoftTTL(item *memcache.Item, soft time.Duration) error {
+	return c.setSoft(item, soft, url.Values{})
+}
+
+// setSoft stores item with a soft TTL, recording meta in the envelope too
+func (c *Client) setSoft(item *memcache.Item, soft time.Duration, meta url.Values) error {
+	at := float64(c.now().Add(soft).UnixNano()) / float64(time.Second)
+	meta.Set("stale", strconv.FormatFloat(at, 'f', 3, 64))
+	line := meta.Encode()
+	value := make([]byte, 0, len(line)+1+len(item.Value))
+	value = append(value, line...)
+	value = append(value, '\n')
+	value = append(value, item.Value...)
+	return c.Set(&memcache.Item{Key: item.Key, Value: value, Flags: item.Flags | FLAG_SOFT_TTL, Expiration: item.Expiration})
+}
+
+// expiryWatchers holds the OnProbableExpiry subscriptions of a Client
+type expiryWatchers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]expirySub
+}
+
+type expirySub struct {
+	prefix string
+	fn     func(ExpiryEvent)
+}
+
+// OnProbableExpiry calls fn, in its own goroutine, when a read through this
+// client finds a key starting with prefix past the soft TTL it was written
+// with by SetSoftTTL, so refreshers can be driven by reads rather than timers.
+// Only the read winning a lease key (added with Options.ExpiryLeaseTTL) for
+// the value's soft expiration notifies, so across every client watching the
+// key fn is called about once per value going stale; if the value isn't
+// refreshed, a read after the lease expires notifies again. Keys that aren't
+// read aren't reported. Call stop to unsubscribe.
+func (c *Client) OnProbableExpiry(prefix string, fn func(ExpiryEvent)) (stop func()) {
+	w := c.expiry
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[int]expirySub)
+	}
+	id := w.next
+	w.next++
+	w.subs[id] = expirySub{prefix, fn}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// watching returns the callbacks subscribed to key
+func (w *expiryWatchers) watching(key string) []func(ExpiryEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var fns []func(ExpiryEvent)
+	for _, s := range w.subs {
+		if strings.HasPrefix(key, s.prefix) {
+			fns = append(fns, s.fn)
+		}
+	}
+	return fns
+}
+
+// softExpiry strips the soft TTL envelope from a read item, notifying the
+// OnProbableExpiry callbacks watching it if it's stale. Every item read from
+// the servers passes through it (or softExpiryMulti), which reassembles
+// chunked values and counts its flags.
+func (c *Client) softExpiry(i *memcache.Item, err error) (*memcache.Item, error) {
+	i, err = c.unchunk(i, err)
+	if err == nil {
+		c.countFlags(i)
+	}
+	if err != nil || i.Flags&FLAG_SOFT_TTL == 0 {
+		return i, err
+	}
+	out, staleAt, _, err := softEnvelope(i)
+	if err != nil || staleAt.IsZero() || c.now().Before(staleAt) {
+		return out, err
+	}
+	if fns := c.expiry.watching(i.Key); len(fns) > 0 && c.expiryLease(i.Key, staleAt) {
+		ev := ExpiryEvent{Key: i.Key, StaleAt: staleAt, Item: out}
+		for _, fn := range fns {
+			go fn(ev)
+		}
+	}
+	return out, nil
+}
+
+// softEnvelope strips the soft TTL envelope from i, returning when it goes
+// stale (zero if not recorded) and the rest of the envelope. Items without
+// one are returned as they are.
+func softEnvelope(i *memcache.Item) (*memcache.Item, time.Time, url.Values, error) {
+	if i.Flags&FLAG_SOFT_TTL == 0 {
+		return i, time.Time{}, nil, nil
+	}
+	n := bytes.IndexByte(i.Value, '\n')
+	if n < 0 {
+		return nil, time.Time{}, nil, errors.New("memcache: corrupt soft TTL envelope")
+	}
+	q, err := url.ParseQuery(string(i.Value[:n]))
+	if err != nil {
+		return nil, time.Time{}, nil, errors.New("memcache: corrupt soft TTL envelope")
+	}
+	// a copy keeps the item's cas id
+	cp := *i
+	out := &cp
+	out.Value, out.Flags = i.Value[n+1:], i.Flags&^FLAG_SOFT_TTL
+	at, err := strconv.ParseFloat(q.Get("stale"), 64)
+	if err != nil {
+		return out, time.Time{}, q, nil
+	}
+	return out, time.UnixMilli(int64(math.Round(at * 1000))), q, nil
+}
+
+// softExpiryMulti is softExpiry for the items of a GetMulti
+func (c *Client) softExpiryMulti(m map[string]*memcache.Item, err error) (map[string]*memcache.Item, error) {
+	for k, i := range m {
+		if i.Flags&(FLAG_SOFT_TTL|FLAG_CHUNKED) == 0 {
+			// softExpiry counts the rest
+			c.countFlags(i)
+			continue
+		}
+		if i, ierr := c.softExpiry(i, nil); ierr == nil {
+			m[k] = i
+		} else {
+			delete(m, k)
+			if err == nil && ierr != memcache.ErrCacheMiss {
+				err = ierr
+			}
+		}
+	}
+	return m, err
+}
+
+// expiryLease adds the lease for key going stale at staleAt, reporting whether
+// this read won it
+func (c *Client) expiryLease(key string, staleAt time.Time) bool {
+	ttl := c.opts.ExpiryLeaseTTL
+	if ttl <= 0 {
+		ttl = DefaultExpiryLeaseTTL
+	}
+	lease := key + ":expiry-lease:" + strconv.FormatInt(staleAt.UnixMilli(), 10)
+	return c.Add(&memcache.Item{Key: lease, Value: []byte("1"), Expiration: ttlSeconds(ttl)}) == nil
+}
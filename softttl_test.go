@@ -0,0 +1,66 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnProbableExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	a := NewClientWithOptions([]string{LocalAddress}, Options{Clock: clock})
+	b := NewClientWithOptions([]string{LocalAddress}, Options{Clock: clock})
+	events := make(chan ExpiryEvent, 10)
+	stopA := a.OnProbableExpiry("softttl:", func(ev ExpiryEvent) { events <- ev })
+	defer b.OnProbableExpiry("softttl:", func(ev ExpiryEvent) { events <- ev })()
+
+	item := UnicodeItem("softttl:a", "v1")
+	item.Expiration = 3600
+	if err := a.SetSoftTTL(item, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := b.GetString("softttl:a"); !ok || s != "v1" {
+		t.Errorf("Expected the envelope to be stripped got %q %v", s, ok)
+	}
+	if raw, _ := a.Client.Get("softttl:a"); raw.Flags != FLAG_PICKLE|FLAG_SOFT_TTL {
+		t.Errorf("unexpected stored flags %d", raw.Flags)
+	}
+
+	clock.Advance(11 * time.Second)
+	for _, c := range []*Client{a, b, a, b} {
+		if s, ok := c.GetString("softttl:a"); !ok || s != "v1" {
+			t.Errorf("Expected the stale value to be served got %q %v", s, ok)
+		}
+	}
+	select {
+	case ev := <-events:
+		if ev.Key != "softttl:a" || !ev.StaleAt.Before(clock.Now()) || ev.Item.Flags != FLAG_PICKLE {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an expiry event")
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Expected a single event for the stale value got another %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// refreshing starts a new soft TTL, and the next transition is reported
+	stopA()
+	a.SetSoftTTL(UnicodeItem("softttl:a", "v2"), 10*time.Second)
+	m, err := a.GetMulti([]string{"softttl:a"})
+	if err != nil || string(m["softttl:a"].Value) != string(UnicodeItem("", "v2").Value) {
+		t.Errorf("unexpected GetMulti %v %v", m, err)
+	}
+	clock.Advance(11 * time.Second)
+	a.GetMulti([]string{"softttl:a"})
+	b.GetMulti([]string{"softttl:a"})
+	select {
+	case ev := <-events:
+		if s, _ := (&Item{ev.Item}).String(); s != "v2" {
+			t.Errorf("unexpected event item %q", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an expiry event from b's read")
+	}
+}
@@ -0,0 +1,91 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultStaleCacheSize is the number of entries kept for stale serving when
+// Options.StaleCacheSize is unset
+const DefaultStaleCacheSize = 10000
+
+// MetricStaleServed counts reads answered from the in-process stale copy because
+// no server was reachable
+const MetricStaleServed = "memcache.stale_served"
+
+// staleGet records the result of a Get for stale serving. When Options.MaxStaleness
+// is set and the server is unreachable, the last value read through this client is
+// returned if it is no older than MaxStaleness.
+func (c *Client) staleGet(key string, i *memcache.Item, err error) (*memcache.Item, error) {
+	if c.stale == nil {
+		return i, err
+	}
+	switch {
+	case err == nil:
+		c.stale.add(key, i, c.now())
+	case err == memcache.ErrCacheMiss:
+		c.stale.remove(key)
+	case unreachable(err):
+		if si, ok := c.stale.get(key, c.now(), c.opts.MaxStaleness); ok {
+			c.opts.Metrics.Count(MetricStaleServed, 1, nil)
+			return si, nil
+		}
+	}
+	return i, err
+}
+
+// staleGetMulti is staleGet for GetMulti. When Options.MaxStaleness is set and some
+// servers are unreachable, keys on those servers are answered from the in-process
+// stale copy and the connection error is not returned.
+func (c *Client) staleGetMulti(keys []string, m map[string]*memcache.Item, err error) (map[string]*memcache.Item, error) {
+	if c.stale == nil {
+		return m, err
+	}
+	now := c.now()
+	if err != nil {
+		if !unreachable(err) {
+			return m, err
+		}
+		if m == nil {
+			m = make(map[string]*memcache.Item, len(keys))
+		}
+		var served int64
+		for _, k := range keys {
+			if _, ok := m[k]; ok {
+				continue
+			}
+			if si, ok := c.stale.get(k, now, c.opts.MaxStaleness); ok {
+				m[k] = si
+				served++
+			}
+		}
+		if served > 0 {
+			c.opts.Metrics.Count(MetricStaleServed, served, nil)
+		}
+		return m, nil
+	}
+	for _, k := range keys {
+		if i, ok := m[k]; ok {
+			c.stale.add(k, i, now)
+		} else {
+			c.stale.remove(k)
+		}
+	}
+	return m, nil
+}
+
+// unreachable reports whether err means the server could not be contacted
+// (as opposed to a protocol level error or a miss)
+func unreachable(err error) bool {
+	if err == memcache.ErrNoServers || err == ErrReconnectBackoff || err == ErrCircuitOpen {
+		return true
+	}
+	var cte *memcache.ConnectTimeoutError
+	if errors.As(err, &cte) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,80 @@
+package memcache
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingMetrics struct {
+	nopMetrics
+	sync.Mutex
+	counts map[string]int64
+}
+
+func (m *countingMetrics) Count(name string, n int64, tags map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[string]int64)
+	}
+	m.counts[name] += n
+}
+
+func (m *countingMetrics) get(name string) int64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.counts[name]
+}
+
+// closedAddr returns an address nothing is listening on
+func closedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestStaleServing(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{closedAddr(t)}, Options{
+		Clock:        clock,
+		MaxStaleness: time.Minute,
+		Metrics:      metrics,
+	})
+	mc.stale.add("stale", StringItem("stale", "old"), clock.Now())
+
+	if v, ok := mc.GetString("stale"); !ok || v != "old" {
+		t.Errorf("Expected stale value, got: %q", v)
+	}
+	if items, err := mc.GetMulti([]string{"stale", "other"}); err != nil || len(items) != 1 {
+		t.Errorf("Expected 1 stale item, got: %v %v", items, err)
+	}
+	if n := metrics.get(MetricStaleServed); n != 2 {
+		t.Errorf("Expected 2 stale reads counted, got: %d", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := mc.GetString("stale"); ok {
+		t.Errorf("Expected value older than MaxStaleness to not be served")
+	}
+}
+
+func TestStaleRecordsReads(t *testing.T) {
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{MaxStaleness: time.Minute})
+	mc.Set(StringItem("stale_recorded", "v"))
+	mc.GetString("stale_recorded")
+	if _, ok := mc.stale.get("stale_recorded", time.Now(), time.Minute); !ok {
+		t.Errorf("Expected read to be recorded for stale serving")
+	}
+	mc.Delete("stale_recorded")
+	mc.GetString("stale_recorded")
+	if mc.stale.len() != 0 {
+		t.Errorf("Expected miss to remove the stale copy")
+	}
+}
@@ -0,0 +1,219 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats is what a server reports about itself from `stats`, `stats items` and
+// `stats slabs`, see Client.Stats
+type Stats struct {
+	Addr    string        `json:"addr"`
+	Version string        `json:"version"`
+	Uptime  time.Duration `json:"uptime"`
+
+	CurrConnections  int64 `json:"curr_connections"`
+	TotalConnections int64 `json:"total_connections"`
+	CurrItems        int64 `json:"curr_items"`
+	TotalItems       int64 `json:"total_items"`
+	// Bytes is the memory used storing items and LimitMaxBytes the memory the
+	// server may use for them
+	Bytes         int64 `json:"bytes"`
+	LimitMaxBytes int64 `json:"limit_maxbytes"`
+	GetHits       int64 `json:"get_hits"`
+	GetMisses     int64 `json:"get_misses"`
+	Evictions     int64 `json:"evictions"`
+	// HitRate is GetHits as a fraction of all gets since the server started,
+	// zero before any
+	HitRate float64 `json:"hit_rate"`
+
+	// ActiveSlabs and TotalMalloced are the slab allocator totals
+	ActiveSlabs   int64 `json:"active_slabs"`
+	TotalMalloced int64 `json:"total_malloced"`
+	// Items and Slabs are the item and slab classes, keyed by class
+	Items map[int]*ItemClass `json:"items"`
+	Slabs map[int]*SlabClass `json:"slabs"`
+
+	// Raw holds every `stats` value by name, including those without a field
+	Raw map[string]string `json:"raw"`
+}
+
+// ItemClass is the items of one slab class as reported by `stats items`
+type ItemClass struct {
+	Class  int   `json:"class"`
+	Number int64 `json:"number"`
+	// Age is the age of the oldest item in the class
+	Age         time.Duration `json:"age"`
+	Evicted     int64         `json:"evicted"`
+	OutOfMemory int64         `json:"outofmemory"`
+	Reclaimed   int64         `json:"reclaimed"`
+	// ExpiredUnfetched and EvictedUnfetched count items that went unread
+	// before expiring or being evicted, a sign of caching what nobody reads
+	ExpiredUnfetched int64 `json:"expired_unfetched"`
+	EvictedUnfetched int64 `json:"evicted_unfetched"`
+}
+
+// Stats returns the stats of every server keyed by address. Servers that
+// can't be reached are left out, with their errors joined in the returned
+// error.
+func (c *Client) Stats(ctx context.Context) (map[string]Stats, error) {
+	addrs, err := c.servers()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]Stats, len(addrs))
+	var errs []error
+	for _, addr := range addrs {
+		s, err := c.StatsServer(ctx, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stats[s.Addr] = s
+	}
+	return stats, errors.Join(errs...)
+}
+
+// StatsServer returns the stats of addr
+func (c *Client) StatsServer(ctx context.Context, addr net.Addr) (Stats, error) {
+	s := Stats{
+		Addr:  addr.String(),
+		Items: make(map[int]*ItemClass),
+		Slabs: make(map[int]*SlabClass),
+		Raw:   make(map[string]string),
+	}
+	err := c.withServerConn(ctx, addr, func(sc *serverConn) error {
+		err := readStats(sc, addr, "stats", func(name, value string) {
+			s.Raw[name] = value
+			s.general(name, value)
+		})
+		if err != nil {
+			return err
+		}
+		err = readStats(sc, addr, "stats items", func(name, value string) {
+			// items:1:number 3
+			if rest, ok := strings.CutPrefix(name, "items:"); ok {
+				itemStat(s.Items, rest, value)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return readStats(sc, addr, "stats slabs", func(name, value string) {
+			if slabStat(s.Slabs, name, value) {
+				return
+			}
+			switch n, _ := strconv.ParseInt(value, 10, 64); name {
+			case "active_slabs":
+				s.ActiveSlabs = n
+			case "total_malloced":
+				s.TotalMalloced = n
+			}
+		})
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	if gets := s.GetHits + s.GetMisses; gets > 0 {
+		s.HitRate = float64(s.GetHits) / float64(gets)
+	}
+	return s, nil
+}
+
+// general sets the field for the `stats` value name, if any
+func (s *Stats) general(name, value string) {
+	if name == "version" {
+		s.Version = value
+		return
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+	switch name {
+	case "uptime":
+		s.Uptime = time.Duration(n) * time.Second
+	case "curr_connections":
+		s.CurrConnections = n
+	case "total_connections":
+		s.TotalConnections = n
+	case "curr_items":
+		s.CurrItems = n
+	case "total_items":
+		s.TotalItems = n
+	case "bytes":
+		s.Bytes = n
+	case "limit_maxbytes":
+		s.LimitMaxBytes = n
+	case "get_hits":
+		s.GetHits = n
+	case "get_misses":
+		s.GetMisses = n
+	case "evictions":
+		s.Evictions = n
+	}
+}
+
+// itemStat sets the field for a `stats items` value named like "1:number"
+func itemStat(classes map[int]*ItemClass, name, value string) {
+	id, name, ok := strings.Cut(name, ":")
+	if !ok {
+		return
+	}
+	class, err := strconv.Atoi(id)
+	if err != nil {
+		return
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+	i, ok := classes[class]
+	if !ok {
+		i = &ItemClass{Class: class}
+		classes[class] = i
+	}
+	switch name {
+	case "number":
+		i.Number = n
+	case "age":
+		i.Age = time.Duration(n) * time.Second
+	case "evicted":
+		i.Evicted = n
+	case "outofmemory":
+		i.OutOfMemory = n
+	case "reclaimed":
+		i.Reclaimed = n
+	case "expired_unfetched":
+		i.ExpiredUnfetched = n
+	case "evicted_unfetched":
+		i.EvictedUnfetched = n
+	}
+}
+
+// readStats sends a stats command on sc calling fn with each value up to END
+func readStats(sc *serverConn, addr net.Addr, command string, fn func(name, value string)) error {
+	if err := sc.command(command); err != nil {
+		return err
+	}
+	for {
+		line, err := sc.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+		// STAT curr_connections 10
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			return fmt.Errorf("memcache: %s on %s: %s", command, addr, line)
+		}
+		fn(fields[1], fields[2])
+	}
+}
@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	addr := s.ln.Addr().String()
+	down := closedAddr(t)
+	mc := NewClient([]string{addr, down})
+
+	var keys []string
+	for i := 0; len(keys) < 2; i++ {
+		if k := fmt.Sprintf("stats_key%d", i); mustAddr(t, mc, k) == addr {
+			keys = append(keys, k)
+		}
+	}
+	mc.Set(StringItem(keys[0], "x"))
+	mc.Get(keys[0])
+	mc.Get(keys[0])
+	mc.Get(keys[1])
+
+	stats, err := mc.Stats(context.Background())
+	if err == nil {
+		t.Error("Expected an error for the closed server")
+	}
+	if _, ok := stats[down]; ok || len(stats) != 1 {
+		t.Fatalf("Expected stats for only %s, got %v", addr, stats)
+	}
+	st := stats[addr]
+	if st.Version != "1.6.0-local" || st.CurrItems != 1 || st.GetHits != 2 || st.GetMisses != 1 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+	if st.HitRate < 0.66 || st.HitRate > 0.67 {
+		t.Errorf("Expected a 2/3 hit rate, got %v", st.HitRate)
+	}
+	if st.Raw["curr_items"] != "1" {
+		t.Errorf("Expected raw curr_items 1, got %q", st.Raw["curr_items"])
+	}
+	if i, ok := st.Items[1]; !ok || i.Number != 1 {
+		t.Errorf("Unexpected item class 1: %+v", i)
+	}
+	if sl, ok := st.Slabs[1]; !ok || sl.ChunkSize != 96 || sl.UsedChunks != 1 || st.ActiveSlabs != 1 {
+		t.Errorf("Unexpected slab class 1: %+v (active %d)", sl, st.ActiveSlabs)
+	}
+}
+
+func TestStatsGeneral(t *testing.T) {
+	var s Stats
+	for name, value := range map[string]string{"uptime": "60", "evictions": "7", "limit_maxbytes": "67108864", "rusage_user": "0.5"} {
+		s.general(name, value)
+	}
+	if s.Uptime.Seconds() != 60 || s.Evictions != 7 || s.LimitMaxBytes != 64<<20 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	items := make(map[int]*ItemClass)
+	itemStat(items, "3:age", "120")
+	itemStat(items, "3:evicted_unfetched", "2")
+	itemStat(items, "total", "9")
+	if i := items[3]; len(items) != 1 || i.Age.Minutes() != 2 || i.EvictedUnfetched != 2 {
+		t.Errorf("Unexpected item classes %+v", items)
+	}
+}
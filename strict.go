@@ -0,0 +1,191 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStrictTimeout bounds the host lookups and server checks of NewClientStrict
+const DefaultStrictTimeout = 5 * time.Second
+
+// ConfigProblem is one problem found in a client configuration
+type ConfigProblem struct {
+	// Server is the configured server address the problem concerns, "" when it
+	// concerns the configuration as a whole
+	Server  string
+	Problem string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Server == "" {
+		return p.Problem
+	}
+	return p.Server + ": " + p.Problem
+}
+
+// ConfigError is returned by NewClientStrict listing every problem found
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	s := make([]string, len(e.Problems))
+	for n, p := range e.Problems {
+		s[n] = p.String()
+	}
+	return "memcache: invalid configuration: " + strings.Join(s, "; ")
+}
+
+// NewClientStrict is NewClientWithOptions refusing configurations that would
+// build a broken ring. It runs CheckConfig then, if that found nothing, pings
+// every server (see ValidateConfig). All problems are returned together in a
+// *ConfigError.
+func NewClientStrict(addresses []string, opts Options) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultStrictTimeout)
+	defer cancel()
+	if problems := CheckConfig(ctx, addresses, opts); len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	c := NewClientWithOptions(addresses, opts)
+	addrs, err := c.servers()
+	if err != nil {
+		return nil, err
+	}
+	var problems []ConfigProblem
+	for n, err := range c.checkServers(ctx, addrs) {
+		if err != nil {
+			problems = append(problems, ConfigProblem{Server: addrs[n].String(), Problem: err.Error()})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return c, nil
+}
+
+// CheckConfig reports problems with a client configuration without connecting
+// to any server: no or duplicate servers, malformed addresses (including
+// pylibmc style weights, which the non-weighted ring would hash as part of the
+// name), hosts that don't resolve or resolve to the same address as another
+// server, several servers in proxy mode, pipeline stages setting flag bits
+// pylibmc or another stage uses, and TTL policies that can't be met. Hosts
+// aren't resolved when opts.DialContext is set since it may not use DNS.
+func CheckConfig(ctx context.Context, addresses []string, opts Options) []ConfigProblem {
+	var problems []ConfigProblem
+	add := func(server, format string, args ...interface{}) {
+		problems = append(problems, ConfigProblem{Server: server, Problem: fmt.Sprintf(format, args...)})
+	}
+	if len(addresses) == 0 {
+		add("", "no servers configured")
+	}
+	if opts.ProxyMode != ProxyNone && len(addresses) > 1 {
+		add("", "%s proxy mode sends every key to the first of %d servers", opts.ProxyMode, len(addresses))
+	}
+	seen := make(map[string]bool)
+	resolved := make(map[string]string)
+	for _, server := range addresses {
+		if seen[server] {
+			add(server, "listed more than once, giving it extra weight on the ring")
+			continue
+		}
+		seen[server] = true
+		if server == LocalAddress || server == disabledAddress {
+			continue
+		}
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			if i := strings.LastIndexByte(server, ':'); i > 0 && isDigits(server[i+1:]) && strings.Count(server, ":") == 2 {
+				add(server, "server weights aren't supported by the non-weighted ketama ring")
+			} else {
+				add(server, "invalid address: %s", err)
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			add(server, "invalid port %q", port)
+			continue
+		}
+		if opts.DialContext != nil {
+			continue
+		}
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			add(server, "unresolvable host: %s", err)
+			continue
+		}
+		for _, ip := range ips {
+			ipPort := net.JoinHostPort(ip, port)
+			if other, ok := resolved[ipPort]; ok && other != server {
+				add(server, "resolves to the same address (%s) as %s", ipPort, other)
+				break
+			}
+			resolved[ipPort] = server
+		}
+	}
+	switch opts.PickleProtocol {
+	case 0, 2, 4, 5:
+	default:
+		add("", "unsupported pickle protocol %d", opts.PickleProtocol)
+	}
+	problems = append(problems, checkPipelineFlags(opts.Pipeline)...)
+	for _, p := range opts.TTLPolicies {
+		if p.Min > 0 && p.Max > 0 && p.Min > p.Max {
+			add("", "TTL policy for prefix %q has Min %s above Max %s", p.Prefix, p.Min, p.Max)
+		}
+	}
+	return problems
+}
+
+// checkPipelineFlags encodes a probe value through each stage of p reporting
+// stages that set pylibmc flag bits (other than FLAG_ZLIB for Compress) or a
+// bit an earlier stage sets, which would make values undecodable
+func checkPipelineFlags(p Pipeline) []ConfigProblem {
+	type named struct {
+		name    string
+		stage   Stage
+		allowed uint32
+	}
+	var stages []named
+	for n, s := range p.Stages {
+		stages = append(stages, named{fmt.Sprintf("pipeline stage %d (%T)", n, s), s, 0})
+	}
+	if p.Compress != nil {
+		stages = append(stages, named{fmt.Sprintf("Compress stage (%T)", p.Compress), p.Compress, FLAG_ZLIB})
+	}
+	if p.Encrypt != nil {
+		stages = append(stages, named{fmt.Sprintf("Encrypt stage (%T)", p.Encrypt), p.Encrypt, 0})
+	}
+	var problems []ConfigProblem
+	var used uint32
+	for _, s := range stages {
+		_, flags, err := s.stage.Encode([]byte("probe"), FLAG_NONE)
+		if err != nil {
+			problems = append(problems, ConfigProblem{Problem: fmt.Sprintf("%s fails to encode: %s", s.name, err)})
+			continue
+		}
+		if conflict := flags & pylibmcFlags &^ s.allowed; conflict != 0 {
+			problems = append(problems, ConfigProblem{Problem: fmt.Sprintf("%s sets pylibmc flag bits %#x", s.name, conflict)})
+		}
+		if conflict := flags & used; conflict != 0 {
+			problems = append(problems, ConfigProblem{Problem: fmt.Sprintf("%s sets flag bits %#x already set by an earlier stage", s.name, conflict)})
+		}
+		used |= flags
+	}
+	return problems
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
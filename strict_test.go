@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckConfig(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		addrs   []string
+		opts    Options
+		problem string
+	}{
+		{"none", nil, Options{}, "no servers configured"},
+		{"duplicate", []string{"127.0.0.1:11211", "127.0.0.1:11211"}, Options{}, "127.0.0.1:11211: listed more than once"},
+		{"same address", []string{"localhost:11211", "127.0.0.1:11211"}, Options{}, "127.0.0.1:11211: resolves to the same address (127.0.0.1:11211) as localhost:11211"},
+		{"no port", []string{"127.0.0.1"}, Options{}, "127.0.0.1: invalid address"},
+		{"bad port", []string{"127.0.0.1:0"}, Options{}, `127.0.0.1:0: invalid port "0"`},
+		{"weight", []string{"127.0.0.1:11211:2"}, Options{}, "127.0.0.1:11211:2: server weights aren't supported"},
+		{"unresolvable", []string{"nonexistent.invalid:11211"}, Options{}, "nonexistent.invalid:11211: unresolvable host"},
+		{"pylibmc flag", []string{LocalAddress}, Options{Pipeline: Pipeline{Stages: []Stage{zlibStage{}}}}, "pipeline stage 0 (memcache.zlibStage) sets pylibmc flag bits 0x8"},
+		{"shared flag", []string{LocalAddress}, Options{Pipeline: Pipeline{Stages: []Stage{reverseStage{}}, Encrypt: reverseStage{}}}, "Encrypt stage (memcache.reverseStage) sets flag bits 0x100 already set"},
+		{"ttl policy", []string{LocalAddress}, Options{TTLPolicies: []TTLPolicy{{Prefix: "a", Min: time.Hour, Max: time.Minute}}}, `TTL policy for prefix "a" has Min 1h0m0s above Max 1m0s`},
+	} {
+		problems := CheckConfig(ctx, tc.addrs, tc.opts)
+		if len(problems) != 1 || !strings.HasPrefix(problems[0].String(), tc.problem) {
+			t.Errorf("%s: expected %q got %v", tc.name, tc.problem, problems)
+		}
+	}
+	ok := Options{Pipeline: Pipeline{Stages: []Stage{reverseStage{}}, Compress: zlibStage{}}}
+	if problems := CheckConfig(ctx, []string{LocalAddress, "127.0.0.1:11211", "127.0.0.1:11212"}, ok); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+}
+
+func TestNewClientStrict(t *testing.T) {
+	mc, err := NewClientStrict([]string{LocalAddress}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.SetString("strict", "v"); err != nil {
+		t.Error(err)
+	}
+
+	_, err = NewClientStrict([]string{LocalAddress, "127.0.0.1:1"}, Options{})
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) || len(cerr.Problems) != 1 || cerr.Problems[0].Server != "127.0.0.1:1" {
+		t.Errorf("Expected the unreachable server to be reported, got %v", err)
+	}
+	_, err = NewClientStrict([]string{LocalAddress, LocalAddress}, Options{})
+	if !errors.As(err, &cerr) || len(cerr.Problems) != 1 {
+		t.Errorf("Expected the duplicate server to be reported, got %v", err)
+	}
+}
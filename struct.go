@@ -0,0 +1,327 @@
+package memcache
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// StructItem returns a memcache.Item storing the struct v (or a pointer to one)
+// pickled as a python dict with protocol 2, keys in sorted order. Each exported
+// field is a dict entry named by its `pickle:"field_name"` tag, or the field
+// name when untagged; `pickle:"-"` skips a field and `pickle:"name,omitempty"`
+// skips it when zero. Fields of embedded structs are promoted as they are by
+// encoding/json. Nested structs become dicts, slices and arrays lists, maps with
+// string keys dicts, nil pointers None, and time.Time, picklecompat.Decimal and
+// *big.Int the types picklecompat.Encode writes them as.
+func StructItem(k string, v interface{}) (*memcache.Item, error) {
+	m, err := structDict(v)
+	if err != nil {
+		return nil, err
+	}
+	return PickleItem(k, m)
+}
+
+// StructItem is StructItem pickling v with Options.PickleProtocol
+func (c *Client) StructItem(k string, v interface{}) (*memcache.Item, error) {
+	m, err := structDict(v)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.pickle(m)
+	if err != nil {
+		return nil, err
+	}
+	return &memcache.Item{Key: k, Value: b, Flags: FLAG_PICKLE}, nil
+}
+
+// Unmarshal decodes a pickled python dict into the struct dst points to,
+// matching entries to fields the way StructItem names them. Entries without a
+// field are ignored and fields without an entry are left unchanged. Python
+// ints, floats, strs, bytes, lists, tuples and dicts are converted to the
+// field's type, failing with InvalidType when they can't be (including ints
+// overflowing it), and None sets a field to its zero value.
+func (i *Item) Unmarshal(dst interface{}) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: Unmarshal needs a struct pointer got %T", InvalidType, dst)
+	}
+	d, err := i.dict()
+	if err != nil {
+		return err
+	}
+	return assignValue(dv.Elem(), d)
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(picklecompat.Decimal(""))
+	bigIntType  = reflect.TypeOf((*big.Int)(nil))
+)
+
+// structField is a field of a struct pickled by StructItem
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns the pickled fields of struct type t, promoting the
+// fields of untagged embedded structs
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for n := 0; n < t.NumField(); n++ {
+		f := t.Field(n)
+		tag := f.Tag.Get("pickle")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, e := range structFields(f.Type) {
+				e.index = append([]int{n}, e.index...)
+				fields = append(fields, e)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: f.Index, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+// structDict converts the struct v to the map StructItem pickles
+func structDict(v interface{}) (map[string]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return nil, fmt.Errorf("%w: %T isn't a struct", picklecompat.ErrUnsupportedType, v)
+	}
+	m, err := structValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	return m.(map[string]interface{}), nil
+}
+
+// structValue converts v to the value picklecompat.Encode writes for it
+func structValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Type() {
+	case timeType, decimalType, bigIntType:
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return structValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > math.MaxInt64 {
+			return new(big.Int).SetUint64(n), nil
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		l := make([]interface{}, v.Len())
+		for n := range l {
+			e, err := structValue(v.Index(n))
+			if err != nil {
+				return nil, err
+			}
+			l[n] = e
+		}
+		return l, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s", picklecompat.ErrUnsupportedType, v.Type())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			e, err := structValue(it.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[it.Key().String()] = e
+		}
+		return m, nil
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		m := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			e, err := structValue(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			m[f.name] = e
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w: %s", picklecompat.ErrUnsupportedType, v.Type())
+}
+
+// assignValue sets dv to the decoded python value v, converting it to dv's type
+func assignValue(dv reflect.Value, v interface{}) error {
+	if v == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	if sv := reflect.ValueOf(v); sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	mismatch := fmt.Errorf("%w: can't store %T in %s", InvalidType, v, dv.Type())
+	switch dv.Kind() {
+	case reflect.Pointer:
+		e := reflect.New(dv.Type().Elem())
+		if err := assignValue(e.Elem(), v); err != nil {
+			return err
+		}
+		dv.Set(e)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := pyInt(v)
+		if !ok || dv.OverflowInt(n) {
+			return mismatch
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := pyInt(v)
+		if !ok || n < 0 || dv.OverflowUint(uint64(n)) {
+			return mismatch
+		}
+		dv.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch f := v.(type) {
+		case float64:
+			dv.SetFloat(f)
+			return nil
+		case int:
+			dv.SetFloat(float64(f))
+			return nil
+		}
+	case reflect.String:
+		switch s := v.(type) {
+		case string:
+			dv.SetString(s)
+			return nil
+		case []byte:
+			dv.SetString(string(s))
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := v.(string); ok && dv.Type().Elem().Kind() == reflect.Uint8 {
+			dv.SetBytes([]byte(s))
+			return nil
+		}
+		var l []interface{}
+		switch s := v.(type) {
+		case *types.List:
+			l = *s
+		case *types.Tuple:
+			l = *s
+		default:
+			return mismatch
+		}
+		out := reflect.MakeSlice(dv.Type(), len(l), len(l))
+		for n, e := range l {
+			if err := assignValue(out.Index(n), e); err != nil {
+				return err
+			}
+		}
+		dv.Set(out)
+		return nil
+	case reflect.Map:
+		d, ok := v.(*types.Dict)
+		if !ok || dv.Type().Key().Kind() != reflect.String {
+			return mismatch
+		}
+		out := reflect.MakeMapWithSize(dv.Type(), d.Len())
+		for _, e := range *d {
+			k, ok := e.Key.(string)
+			if !ok {
+				return fmt.Errorf("%w: dict key %T isn't a string", InvalidType, e.Key)
+			}
+			ev := reflect.New(dv.Type().Elem()).Elem()
+			if err := assignValue(ev, e.Value); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(dv.Type().Key()), ev)
+		}
+		dv.Set(out)
+		return nil
+	case reflect.Struct:
+		d, ok := v.(*types.Dict)
+		if !ok {
+			return mismatch
+		}
+		for _, f := range structFields(dv.Type()) {
+			e, ok := d.Get(f.name)
+			if !ok {
+				continue
+			}
+			if err := assignValue(dv.FieldByIndex(f.index), e); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		return nil
+	}
+	return mismatch
+}
+
+// pyInt converts a decoded python int to an int64
+func pyInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case *big.Int:
+		return v.Int64(), v.IsInt64()
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package memcache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+type structAddress struct {
+	City string `pickle:"city"`
+}
+
+type structBase struct {
+	ID int64 `pickle:"id"`
+}
+
+type structUser struct {
+	structBase
+	Name     string               `pickle:"name"`
+	Age      uint8                `pickle:"age"`
+	Score    float64              `pickle:"score"`
+	Tags     []string             `pickle:"tags"`
+	Address  *structAddress       `pickle:"address"`
+	Attrs    map[string]int       `pickle:"attrs,omitempty"`
+	Joined   time.Time            `pickle:"joined"`
+	Balance  picklecompat.Decimal `pickle:"balance"`
+	Extra    interface{}          `pickle:"extra"`
+	Password string               `pickle:"-"`
+	internal int
+}
+
+func TestStructItem(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	want := structUser{
+		structBase: structBase{ID: 7},
+		Name:       "ada",
+		Age:        36,
+		Score:      2.5,
+		Tags:       []string{"a", "b"},
+		Address:    &structAddress{"London"},
+		Joined:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Balance:    "12.50",
+		Password:   "secret",
+		internal:   1,
+	}
+	item, err := StructItem("struct", &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Set(item); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := mc.GetMap("struct")
+	if !ok || len(m) != 9 || m["name"] != "ada" || m["id"] != 7 {
+		t.Errorf("unexpected dict %v %v", m, ok)
+	}
+	if _, ok := m["attrs"]; ok {
+		t.Error("Expected attrs to be omitted")
+	}
+
+	i, _ := mc.Get("struct")
+	var got structUser
+	if err := (&Item{i}).Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	want.Password, want.internal = "", 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v got %+v", want, got)
+	}
+
+	if _, err := StructItem("struct", "ada"); !errors.Is(err, picklecompat.ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType got %v", err)
+	}
+	if err := (&Item{i}).Unmarshal(got); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a non pointer got %v", err)
+	}
+}
+
+func TestUnmarshalPython(t *testing.T) {
+	// pickle.dumps({'name': u'ada', 'age': 36, 'score': 2.5, 'tags': ('a', u'b'),
+	// 'address': {'city': u'London'}, 'extra': None, 'unknown': 1}, 2) from python 2
+	i := &Item{&memcache.Item{Flags: FLAG_PICKLE, Value: []byte("\x80\x02}q\x00(U\x04nameq\x01X\x03\x00\x00\x00adaq\x02U\x05extraq\x03NU\x07unknownq\x04K\x01U\x03ageq\x05K$U\x04tagsq\x06U\x01aq\x07X\x01\x00\x00\x00bq\x08\x86q\tU\x05scoreq\nG@\x04\x00\x00\x00\x00\x00\x00U\x07addressq\x0b}q\x0cU\x04cityq\rX\x06\x00\x00\x00Londonq\x0esu.")}}
+	got := structUser{Extra: "set", Name: "unchanged"}
+	if err := i.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := structUser{Name: "ada", Age: 36, Score: 2.5, Tags: []string{"a", "b"}, Address: &structAddress{"London"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v got %+v", want, got)
+	}
+
+	var small struct {
+		Age  int8 `pickle:"age"`
+		Name int  `pickle:"name"`
+	}
+	if err := i.Unmarshal(&small); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a str field got %v", err)
+	}
+	if err := (&Item{UnicodeItem("", "ada")}).Unmarshal(&small); !errors.Is(err, InvalidType) {
+		t.Errorf("Expected InvalidType for a str got %v", err)
+	}
+}
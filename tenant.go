@@ -0,0 +1,221 @@
+package memcache
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/picklecompat"
+)
+
+// ErrTenantQuota is returned by TenantClient operations exceeding the tenant's quota
+var ErrTenantQuota = errors.New("memcache: tenant quota exceeded")
+
+// MetricTenantOps counts TenantClient operations tagged by tenant and op
+const MetricTenantOps = "memcache.tenant.ops"
+
+// MetricTenantRejected counts TenantClient operations rejected by ErrTenantQuota
+const MetricTenantRejected = "memcache.tenant.rejected"
+
+// TenantQuota limits what a single tenant may do through a TenantClient
+type TenantQuota struct {
+	// Rate limits operations per second for the tenant. Zero is unlimited.
+	Rate float64
+	// Burst is the number of operations allowed at once under Rate
+	Burst int
+	// MaxItemSize limits the value size the tenant may store. Zero is unlimited.
+	MaxItemSize int
+}
+
+// TenantClient is a Cacher scoped to one tenant. Every key is prefixed with the
+// tenant (the prefix is removed again from returned items) so tenants can't read
+// or overwrite each other's keys, and operations are subject to the tenant's
+// quota and counted in per tenant metrics.
+type TenantClient struct {
+	c      *Client
+	id     string
+	prefix string
+	quota  TenantQuota
+	bucket *tokenBucket
+}
+
+var _ Cacher = (*TenantClient)(nil)
+
+// tenantBuckets holds the rate limiters shared by every TenantClient of a tenant
+type tenantBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (b *tenantBuckets) get(c *Client, id string, q TenantQuota) *tokenBucket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tb, ok := b.buckets[id]; ok {
+		return tb
+	}
+	tb := newTokenBucket(c.opts.Clock, q.Rate, q.Burst)
+	b.buckets[id] = tb
+	return tb
+}
+
+// WithTenant returns a TenantClient for tenant id. Its quota is
+// Options.TenantQuotas[id] if set, otherwise Options.TenantQuota. Clients for the
+// same tenant share one rate limit.
+func (c *Client) WithTenant(id string) *TenantClient {
+	q, ok := c.opts.TenantQuotas[id]
+	if !ok {
+		q = c.opts.TenantQuota
+	}
+	var sb strings.Builder
+	sb.WriteString("tenant:")
+	escapeKeyPart(&sb, id, ":")
+	sb.WriteString(":")
+	t := &TenantClient{
+		c:      c,
+		id:     id,
+		prefix: sb.String(),
+		quota:  q,
+	}
+	if q.Rate > 0 {
+		t.bucket = c.tenants.get(c, id, q)
+	}
+	return t
+}
+
+// Tenant returns the tenant id
+func (t *TenantClient) Tenant() string { return t.id }
+
+// Prefix returns the prefix added to the tenant's keys
+func (t *TenantClient) Prefix() string { return t.prefix }
+
+// admit counts op and checks the rate quota
+func (t *TenantClient) admit(op string) error {
+	tags := map[string]string{"tenant": t.id, "op": op}
+	if t.bucket != nil && !t.bucket.allow() {
+		t.c.opts.Metrics.Count(MetricTenantRejected, 1, tags)
+		return ErrTenantQuota
+	}
+	t.c.opts.Metrics.Count(MetricTenantOps, 1, tags)
+	return nil
+}
+
+// scoped returns a copy of item with the tenant prefix applied
+func (t *TenantClient) scoped(op string, item *memcache.Item) (*memcache.Item, error) {
+	if t.quota.MaxItemSize > 0 && len(item.Value) > t.quota.MaxItemSize {
+		t.c.opts.Metrics.Count(MetricTenantRejected, 1, map[string]string{"tenant": t.id, "op": op})
+		return nil, ErrTenantQuota
+	}
+	if err := t.admit(op); err != nil {
+		return nil, err
+	}
+	cp := *item
+	cp.Key = t.prefix + item.Key
+	return &cp, nil
+}
+
+// unscoped removes the tenant prefix from an item read from memcache
+func (t *TenantClient) unscoped(i *memcache.Item) *memcache.Item {
+	if i != nil {
+		i.Key = strings.TrimPrefix(i.Key, t.prefix)
+	}
+	return i
+}
+
+func (t *TenantClient) Get(key string) (*memcache.Item, error) {
+	if err := t.admit(OpGet); err != nil {
+		return nil, err
+	}
+	i, err := t.c.Get(t.prefix + key)
+	return t.unscoped(i), err
+}
+
+func (t *TenantClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	if err := t.admit(OpGetMulti); err != nil {
+		return nil, err
+	}
+	scoped := make([]string, len(keys))
+	for n, k := range keys {
+		scoped[n] = t.prefix + k
+	}
+	items, err := t.c.GetMulti(scoped)
+	m := make(map[string]*memcache.Item, len(items))
+	for _, i := range items {
+		i = t.unscoped(i)
+		m[i.Key] = i
+	}
+	return m, err
+}
+
+func (t *TenantClient) Set(item *memcache.Item) error {
+	return t.store(OpSet, item, t.c.Set)
+}
+
+func (t *TenantClient) Add(item *memcache.Item) error {
+	return t.store(OpAdd, item, t.c.Add)
+}
+
+func (t *TenantClient) Replace(item *memcache.Item) error {
+	return t.store(OpReplace, item, t.c.Replace)
+}
+
+func (t *TenantClient) CompareAndSwap(item *memcache.Item) error {
+	return t.store(OpCompareAndSwap, item, t.c.CompareAndSwap)
+}
+
+func (t *TenantClient) store(op string, item *memcache.Item, fn func(*memcache.Item) error) error {
+	cp, err := t.scoped(op, item)
+	if err != nil {
+		return err
+	}
+	return fn(cp)
+}
+
+func (t *TenantClient) Delete(key string) error {
+	if err := t.admit(OpDelete); err != nil {
+		return err
+	}
+	return t.c.Delete(t.prefix + key)
+}
+
+func (t *TenantClient) Touch(key string, seconds int32) error {
+	if err := t.admit(OpTouch); err != nil {
+		return err
+	}
+	return t.c.Touch(t.prefix+key, seconds)
+}
+
+func (t *TenantClient) Increment(key string, delta uint64) (uint64, error) {
+	if err := t.admit(OpIncrement); err != nil {
+		return 0, err
+	}
+	return t.c.Increment(t.prefix+key, delta)
+}
+
+func (t *TenantClient) Decrement(key string, delta uint64) (uint64, error) {
+	if err := t.admit(OpDecrement); err != nil {
+		return 0, err
+	}
+	return t.c.Decrement(t.prefix+key, delta)
+}
+
+func (t *TenantClient) GetString(k string, _ ...CallOption) (string, bool)   { return getString(t, k) }
+func (t *TenantClient) GetInt64(k string, _ ...CallOption) (int64, bool)     { return getInt64(t, k) }
+func (t *TenantClient) GetBool(k string, _ ...CallOption) (bool, bool)       { return getBool(t, k) }
+func (t *TenantClient) GetFloat64(k string, _ ...CallOption) (float64, bool) { return getFloat64(t, k) }
+func (t *TenantClient) GetMap(k string, _ ...CallOption) (map[string]interface{}, bool) {
+	return getMap(t, k)
+}
+func (t *TenantClient) GetBigInt(k string, _ ...CallOption) (*big.Int, bool) {
+	return getBigInt(t, k)
+}
+func (t *TenantClient) GetBytes(k string, _ ...CallOption) ([]byte, bool)   { return getBytes(t, k) }
+func (t *TenantClient) GetTime(k string, _ ...CallOption) (time.Time, bool) { return getTime(t, k) }
+func (t *TenantClient) GetDecimal(k string, _ ...CallOption) (picklecompat.Decimal, bool) {
+	return getDecimal(t, k)
+}
+func (t *TenantClient) GetAny(k string, _ ...CallOption) (interface{}, Type, bool) {
+	return getAny(t, k, deserializeItem)
+}
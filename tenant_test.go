@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestTenantClient(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{Metrics: metrics})
+	a, b := mc.WithTenant("a"), mc.WithTenant("b b")
+	if b.Prefix() != "tenant:b%20b:" {
+		t.Errorf("unexpected prefix %q", b.Prefix())
+	}
+
+	if err := a.Set(StringItem("tenant_key", "from a")); err != nil {
+		t.Fatal(err)
+	}
+	b.Delete("tenant_key")
+	if _, err := b.Get("tenant_key"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected tenants to be isolated, got: %v", err)
+	}
+	i, err := a.Get("tenant_key")
+	if err != nil || i.Key != "tenant_key" || string(i.Value) != "from a" {
+		t.Fatalf("unexpected item %v %v", i, err)
+	}
+	i.Value = []byte("swapped")
+	if err := a.CompareAndSwap(i); err != nil {
+		t.Errorf("Expected CAS through tenant to succeed, got: %v", err)
+	}
+	if s, ok := a.GetString("tenant_key"); !ok || s != "swapped" {
+		t.Errorf("unexpected value %q %v", s, ok)
+	}
+	if raw, err := mc.Get("tenant:a:tenant_key"); err != nil || string(raw.Value) != "swapped" {
+		t.Errorf("Expected prefixed key in memcache, got: %v %v", raw, err)
+	}
+	items, err := a.GetMulti([]string{"tenant_key", "tenant_missing"})
+	if err != nil || len(items) != 1 || items["tenant_key"] == nil {
+		t.Errorf("unexpected items %v %v", items, err)
+	}
+	if n := metrics.get(MetricTenantOps); n < 5 {
+		t.Errorf("Expected tenant ops to be counted, got: %d", n)
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{"127.0.0.1:11211"}, Options{
+		Clock:        clock,
+		Metrics:      metrics,
+		TenantQuota:  TenantQuota{Rate: 1, Burst: 2},
+		TenantQuotas: map[string]TenantQuota{"small": {MaxItemSize: 4}},
+	})
+
+	tc := mc.WithTenant("limited")
+	for n := 0; n < 2; n++ {
+		if err := tc.Set(StringItem("quota", "x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a second client for the tenant shares the limit
+	if err := mc.WithTenant("limited").Set(StringItem("quota", "x")); err != ErrTenantQuota {
+		t.Errorf("Expected ErrTenantQuota, got: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := tc.Set(StringItem("quota", "x")); err != nil {
+		t.Errorf("Expected quota to refill, got: %v", err)
+	}
+
+	small := mc.WithTenant("small")
+	if err := small.Set(StringItem("quota", "too large")); err != ErrTenantQuota {
+		t.Errorf("Expected ErrTenantQuota for large item, got: %v", err)
+	}
+	if err := small.Set(StringItem("quota", "ok")); err != nil {
+		t.Errorf("Expected small item to be stored, got: %v", err)
+	}
+	if n := metrics.get(MetricTenantRejected); n != 2 {
+		t.Errorf("Expected 2 rejections, got: %d", n)
+	}
+}
@@ -0,0 +1,20 @@
+# Regenerates the fixtures read by fixtures_test.go. The py2_ files are written
+# by Python 2.7 and the py3_ files by Python 3:
+#
+#   python2 generate.py && python3 generate.py
+import datetime
+import pickle
+import sys
+
+
+def dump(name, v, protocol):
+    with open(name + '.pkl', 'wb') as f:
+        pickle.dump(v, f, protocol)
+
+
+if sys.version_info[0] == 2:
+    dump('py2_unicode', u'caf\xe9', 2)
+    dump('py2_dict', {'name': u'ada', 'age': 36, 'tags': [u'a', u'b']}, 2)
+    dump('py2_datetime', datetime.datetime(2024, 3, 1, 12, 30, 45, 123456), 2)
+else:
+    dump('py3_list', ['x', 1, 2.5, None, b'\x00\xff'], 4)
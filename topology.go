@@ -0,0 +1,235 @@
+package memcache
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+// switchSelector is the selector of a Client, delegating to the selector of
+// its current servers so SetServers can replace it atomically while the
+// embedded memcache.Client keeps using the one selector it was created with
+type switchSelector struct {
+	v atomic.Value // selectorRef
+}
+
+type selectorRef struct{ memcache.ServerSelector }
+
+func (s *switchSelector) current() memcache.ServerSelector {
+	return s.v.Load().(selectorRef).ServerSelector
+}
+
+func (s *switchSelector) set(selector memcache.ServerSelector) {
+	s.v.Store(selectorRef{selector})
+}
+
+func (s *switchSelector) PickServer(key string) (net.Addr, error) {
+	return s.current().PickServer(key)
+}
+
+func (s *switchSelector) Each(f func(net.Addr) error) error {
+	return s.current().Each(f)
+}
+
+// topology is the server list a Client was configured with, as given
+type topology struct {
+	mu        sync.Mutex
+	addresses []string
+	weights   map[string]uint64 // for weighted clients; addresses is unused
+}
+
+// newSelector returns the selector placing keys on addresses as opts
+// distributes them, or on the servers of weights with the weighted ketama ring
+// when weights isn't nil
+func newSelector(addresses []string, weights map[string]uint64, opts Options) memcache.ServerSelector {
+	if weights != nil {
+		return newRingSelector(ketamacompat.NewWeightedRingWithOptions(weights, ketamacompat.RingOptions{PointsPerServer: opts.KetamaPoints}))
+	}
+	if opts.ProxyMode != ProxyNone {
+		return newProxySelector(addresses)
+	}
+	if opts.Distribution == DistributionModula {
+		hash := opts.KetamaHash
+		if hash == nil {
+			hash = ketamacompat.Hash
+		}
+		return newModulaSelector(addresses, hash)
+	}
+	ring := ketamacompat.NewRingWithOptions(addresses, ketamacompat.RingOptions{Hash: opts.KetamaHash, PointsPerServer: opts.KetamaPoints})
+	return newRingSelector(ring)
+}
+
+// useSelector makes selector the client's, moving the keys of servers
+// Options.FailureDetector reports down, and closes the connections to servers
+// it no longer has
+func (c *Client) useSelector(selector memcache.ServerSelector) {
+	if rs, ok := selector.(*ringSelector); ok && c.opts.FailureDetector != nil {
+		d := c.opts.FailureDetector
+		// observed by address, which for sockets given as URLs is their path
+		rs.available = func(server string) bool { return d.Available(rs.addrs[server].String(), c.now()) }
+	}
+	if c.selector.v.Load() == nil {
+		c.selector.set(selector)
+		return
+	}
+	removed := make(map[string]bool)
+	c.selector.Each(func(addr net.Addr) error {
+		removed[addr.String()] = true
+		return nil
+	})
+	c.selector.set(selector)
+	selector.Each(func(addr net.Addr) error {
+		delete(removed, addr.String())
+		return nil
+	})
+	for server := range removed {
+		c.conns.closeStale(server, nil)
+	}
+}
+
+// Servers returns the servers of the client as given to the constructor or
+// the last SetServers, AddServer or RemoveServer. Weighted clients return
+// their servers in no particular order.
+func (c *Client) Servers() []string {
+	c.topology.mu.Lock()
+	defer c.topology.mu.Unlock()
+	if c.topology.weights != nil {
+		servers := make([]string, 0, len(c.topology.weights))
+		for server := range c.topology.weights {
+			servers = append(servers, server)
+		}
+		return servers
+	}
+	return append([]string(nil), c.topology.addresses...)
+}
+
+// SetServers replaces the servers keys are distributed over, rebuilding the
+// ketama continuum atomically: operations see either the old or the new
+// servers. Connections to servers kept are reused; those to servers removed
+// are closed, failing operations in flight on them. Servers of a weighted
+// client are given weight 1; see SetWeightedServers.
+func (c *Client) SetServers(addresses []string) error {
+	if err := checkServers(addresses); err != nil {
+		return err
+	}
+	c.topology.mu.Lock()
+	defer c.topology.mu.Unlock()
+	if c.topology.weights != nil {
+		weights := make(map[string]uint64, len(addresses))
+		for _, server := range addresses {
+			weights[server] = 1
+		}
+		c.setTopology(nil, weights)
+		return nil
+	}
+	c.setTopology(append([]string(nil), addresses...), nil)
+	return nil
+}
+
+// SetWeightedServers is SetServers for weighted clients. Unweighted clients
+// fail with an error.
+func (c *Client) SetWeightedServers(weights map[string]uint64) error {
+	servers := make([]string, 0, len(weights))
+	for server := range weights {
+		servers = append(servers, server)
+	}
+	if err := checkServers(servers); err != nil {
+		return err
+	}
+	c.topology.mu.Lock()
+	defer c.topology.mu.Unlock()
+	if c.topology.weights == nil {
+		return fmt.Errorf("memcache: weights set on an unweighted client")
+	}
+	copied := make(map[string]uint64, len(weights))
+	for server, weight := range weights {
+		copied[server] = weight
+	}
+	c.setTopology(nil, copied)
+	return nil
+}
+
+// AddServer adds address to the servers (with weight 1 for weighted clients)
+// unless it is already one of them
+func (c *Client) AddServer(address string) error {
+	if err := checkServers([]string{address}); err != nil {
+		return err
+	}
+	c.topology.mu.Lock()
+	defer c.topology.mu.Unlock()
+	if c.topology.weights != nil {
+		if _, ok := c.topology.weights[address]; ok {
+			return nil
+		}
+		weights := make(map[string]uint64, len(c.topology.weights)+1)
+		for server, weight := range c.topology.weights {
+			weights[server] = weight
+		}
+		weights[address] = 1
+		c.setTopology(nil, weights)
+		return nil
+	}
+	for _, server := range c.topology.addresses {
+		if server == address {
+			return nil
+		}
+	}
+	c.setTopology(append(append([]string(nil), c.topology.addresses...), address), nil)
+	return nil
+}
+
+// RemoveServer removes address from the servers, as given to the constructor
+// or added
+func (c *Client) RemoveServer(address string) error {
+	c.topology.mu.Lock()
+	defer c.topology.mu.Unlock()
+	if c.topology.weights != nil {
+		if _, ok := c.topology.weights[address]; !ok {
+			return fmt.Errorf("memcache: %s isn't a server", address)
+		}
+		weights := make(map[string]uint64, len(c.topology.weights))
+		for server, weight := range c.topology.weights {
+			if server != address {
+				weights[server] = weight
+			}
+		}
+		c.setTopology(nil, weights)
+		return nil
+	}
+	var addresses []string
+	for _, server := range c.topology.addresses {
+		if server != address {
+			addresses = append(addresses, server)
+		}
+	}
+	if len(addresses) == len(c.topology.addresses) {
+		return fmt.Errorf("memcache: %s isn't a server", address)
+	}
+	c.setTopology(addresses, nil)
+	return nil
+}
+
+// setTopology switches to the servers of addresses or weights. The caller
+// holds c.topology.mu.
+func (c *Client) setTopology(addresses []string, weights map[string]uint64) {
+	c.topology.addresses, c.topology.weights = addresses, weights
+	c.useSelector(newSelector(addresses, weights, c.opts))
+}
+
+// checkServers returns an error for a server that is neither "host:port", a
+// unix domain socket nor LocalAddress
+func checkServers(servers []string) error {
+	for _, server := range servers {
+		if _, ok := ketamacompat.SocketPath(server); ok || server == LocalAddress || server == disabledAddress {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return fmt.Errorf("memcache: invalid server %q: %w", server, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package memcache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jehiah/memcache_pycompat/ketamacompat"
+)
+
+func TestSetServers(t *testing.T) {
+	mc := NewClient([]string{"10.0.0.1:11211", "10.0.0.2:11211"})
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	if err := mc.SetServers(servers); err != nil {
+		t.Fatal(err)
+	}
+	if got := mc.Servers(); !reflect.DeepEqual(got, servers) {
+		t.Errorf("Expected servers %v got %v", servers, got)
+	}
+	ring := ketamacompat.NewRing(servers)
+	for n := 0; n < 1000; n++ {
+		key := fmt.Sprintf("key_%d", n)
+		if addr, err := mc.ServerForKey(key); err != nil || addr.String() != ring.ServerFor(key) {
+			t.Fatalf("%s: expected %s got %v %v", key, ring.ServerFor(key), addr, err)
+		}
+	}
+	if !reflect.DeepEqual(mc.Continuum(), ring.Points()) {
+		t.Error("Expected the continuum of the new servers")
+	}
+
+	if err := mc.RemoveServer("10.0.0.2:11211"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.AddServer("10.0.0.4:11211"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.AddServer("10.0.0.4:11211"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:11211", "10.0.0.3:11211", "10.0.0.4:11211"}
+	if got := mc.Servers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected servers %v got %v", want, got)
+	}
+	if err := mc.RemoveServer("10.0.0.2:11211"); err == nil {
+		t.Error("Expected an error removing a server twice")
+	}
+	if err := mc.SetServers([]string{"10.0.0.5"}); err == nil {
+		t.Error("Expected an error for a server without a port")
+	}
+	if err := mc.SetWeightedServers(map[string]uint64{"10.0.0.5:11211": 2}); err == nil {
+		t.Error("Expected an error setting weights on an unweighted client")
+	}
+
+	if err := mc.SetServers(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.Get("key"); err != memcache.ErrNoServers {
+		t.Errorf("Expected ErrNoServers, got: %v", err)
+	}
+}
+
+func TestSetWeightedServers(t *testing.T) {
+	mc := NewWeightedClient(map[string]uint64{"10.0.0.1:11211": 1})
+	weights := map[string]uint64{"10.0.0.1:11211": 3, "10.0.0.2:11211": 1}
+	if err := mc.SetWeightedServers(weights); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mc.Continuum(), ketamacompat.NewWeightedRing(weights).Points()) {
+		t.Error("Expected the weighted continuum")
+	}
+	if err := mc.AddServer("10.0.0.3:11211"); err != nil {
+		t.Fatal(err)
+	}
+	weights["10.0.0.3:11211"] = 1
+	if !reflect.DeepEqual(mc.Continuum(), ketamacompat.NewWeightedRing(weights).Points()) {
+		t.Error("Expected the added server with weight 1")
+	}
+	servers := mc.Servers()
+	sort.Strings(servers)
+	if want := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}; !reflect.DeepEqual(servers, want) {
+		t.Errorf("Expected servers %v got %v", want, servers)
+	}
+}
+
+func TestSetServersConnections(t *testing.T) {
+	a, b := slowServer(t, 0), slowServer(t, 0)
+	mc := NewClient([]string{a, b})
+	for n := 0; n < 20; n++ {
+		mc.Get(fmt.Sprintf("key_%d", n))
+	}
+	open := func() map[string]int64 {
+		m := make(map[string]int64)
+		for _, s := range mc.PoolStats() {
+			m[s.Addr] = s.Open
+		}
+		return m
+	}
+	if o := open(); o[a] == 0 || o[b] == 0 {
+		t.Fatalf("Expected connections to both servers, got: %v", o)
+	}
+	before := open()[a]
+
+	if err := mc.RemoveServer(b); err != nil {
+		t.Fatal(err)
+	}
+	if o := open(); o[a] != before || o[b] != 0 {
+		t.Errorf("Expected the connections to %s to be kept and those to %s closed, got: %v", a, b, o)
+	}
+	for n := 0; n < 20; n++ {
+		if addr, err := mc.ServerForKey(fmt.Sprintf("key_%d", n)); err != nil || addr.String() != a {
+			t.Fatalf("Expected every key on %s, got: %v %v", a, addr, err)
+		}
+	}
+}
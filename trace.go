@@ -0,0 +1,51 @@
+package memcache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TraceContext identifies the request trace and span an operation ran under
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+}
+
+type traceKey struct{}
+
+// WithTraceContext returns a copy of ctx carrying tc so slow operations made
+// with it (GetCtx, GetMultiCtx, SetCtx and DeleteCtx) are logged with its IDs
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey{}, tc)
+}
+
+// TraceContextFrom returns the trace context set with WithTraceContext
+func TraceContextFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ParseB3 returns the trace context of Zipkin B3 propagation headers: the single
+// "b3" header ({TraceId}-{SpanId}[-{SamplingState}[-{ParentSpanId}]]) or the
+// X-B3-TraceId and X-B3-SpanId headers
+func ParseB3(h http.Header) (TraceContext, bool) {
+	if b3 := h.Get("b3"); b3 != "" {
+		parts := strings.Split(b3, "-")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			// "0", "1" or "d" carry only a sampling decision
+			return TraceContext{}, false
+		}
+		return TraceContext{TraceID: parts[0], SpanID: parts[1]}, true
+	}
+	tc := TraceContext{TraceID: h.Get("X-B3-TraceId"), SpanID: h.Get("X-B3-SpanId")}
+	return tc, tc.TraceID != ""
+}
+
+// traceContext returns the trace context of ctx using Options.TraceExtractor
+func (c *Client) traceContext(ctx context.Context) (TraceContext, bool) {
+	if c.opts.TraceExtractor != nil {
+		return c.opts.TraceExtractor(ctx)
+	}
+	return TraceContextFrom(ctx)
+}
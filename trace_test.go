@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseB3(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   TraceContext
+		ok     bool
+	}{
+		{http.Header{"B3": {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"}}, TraceContext{"80f198ee56343ba864fe8b2a57d3eff7", "e457b5a2e4d86bd1"}, true},
+		{http.Header{"B3": {"a3ce929d0e0e4736-00f067aa0ba902b7"}}, TraceContext{"a3ce929d0e0e4736", "00f067aa0ba902b7"}, true},
+		{http.Header{"B3": {"0"}}, TraceContext{}, false},
+		{http.Header{"X-B3-Traceid": {"463ac35c9f6413ad"}, "X-B3-Spanid": {"a2fb4a1d1a96d312"}}, TraceContext{"463ac35c9f6413ad", "a2fb4a1d1a96d312"}, true},
+		{http.Header{}, TraceContext{}, false},
+	}
+	for _, tc := range tests {
+		got, ok := ParseB3(tc.header)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ParseB3(%v) = %v %v, want %v %v", tc.header, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestSlowOpTrace(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{SlowOpThreshold: time.Nanosecond})
+	ctx := WithTraceContext(context.Background(), TraceContext{TraceID: "463ac35c9f6413ad", SpanID: "a2fb4a1d1a96d312"})
+	mc.SetCtx(ctx, StringItem("traced", "v"))
+	mc.GetCtx(ctx, "traced")
+	mc.Get("traced")
+	ops := mc.SlowOps()
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 slow ops, got %v", ops)
+	}
+	if ops[0].TraceID != "" {
+		t.Errorf("Expected no trace for Get, got %+v", ops[0])
+	}
+	for _, op := range ops[1:] {
+		if op.TraceID != "463ac35c9f6413ad" || op.SpanID != "a2fb4a1d1a96d312" {
+			t.Errorf("Expected trace IDs, got %+v", op)
+		}
+	}
+
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{
+		SlowOpThreshold: time.Nanosecond,
+		TraceExtractor: func(ctx context.Context) (TraceContext, bool) {
+			return TraceContext{TraceID: "custom"}, true
+		},
+	})
+	mc.GetMultiCtx(context.Background(), []string{"traced"})
+	if ops := mc.SlowOps(); len(ops) != 1 || ops[0].TraceID != "custom" {
+		t.Errorf("Expected the extractor's trace ID, got %+v", ops)
+	}
+}
@@ -0,0 +1,135 @@
+package memcache
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Tracer starts a client span for each Get, GetMulti, Set and Delete (and
+// their context variants, whose context is the span's parent), see
+// Options.Tracer. An OpenTelemetry adapter is:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) StartSpan(ctx context.Context, name string) memcache.Span {
+//		_, span := o.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value interface{}) {
+//		switch v := value.(type) {
+//		case string:
+//			s.SetAttributes(attribute.String(key, v))
+//		case int:
+//			s.SetAttributes(attribute.Int(key, v))
+//		case bool:
+//			s.SetAttributes(attribute.Bool(key, v))
+//		}
+//	}
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.RecordError(err)
+//			s.SetStatus(codes.Error, err.Error())
+//		}
+//		s.Span.End()
+//	}
+type Tracer interface {
+	// StartSpan starts the span name, e.g. "memcache.get", as a child of ctx's
+	StartSpan(ctx context.Context, name string) Span
+}
+
+// Span is a span started by a Tracer. Attributes follow the OpenTelemetry
+// database conventions: db.system ("memcached"), db.operation, server.address
+// and server.port, plus memcached.key (passed through Options.KeySanitizer,
+// e.g. HashKey to keep identifiers out of traces), memcached.hit (bool) and
+// memcached.value_size (int) or, for get_multi, memcached.keys and
+// memcached.hits (int). Values are strings, ints and bools.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	// End ends the span, failed with err if it's non-nil. Misses and failed
+	// conditions (ErrCacheMiss, ErrNotStored...) aren't errors.
+	End(err error)
+}
+
+// opSpan is the span of one operation; its methods do nothing on nil
+type opSpan struct {
+	span Span
+}
+
+// startSpan starts the span of op on key (stored under full) under
+// Options.Tracer, returning nil without one
+func (c *Client) startSpan(ctx context.Context, op, key, full string) *opSpan {
+	if c.opts.Tracer == nil {
+		return nil
+	}
+	s := c.opts.Tracer.StartSpan(ctx, "memcache."+op)
+	s.SetAttribute("db.system", "memcached")
+	s.SetAttribute("db.operation", op)
+	if op != OpGetMulti {
+		s.SetAttribute("memcached.key", c.sanitizeKey(key))
+		if addr, err := c.selector.PickServer(full); err == nil {
+			if host, port, err := net.SplitHostPort(addr.String()); err == nil {
+				s.SetAttribute("server.address", host)
+				if p, err := strconv.Atoi(port); err == nil {
+					s.SetAttribute("server.port", p)
+				}
+			} else {
+				s.SetAttribute("server.address", addr.String())
+			}
+		}
+	}
+	return &opSpan{s}
+}
+
+// read ends the span of a Get
+func (s *opSpan) read(i *memcache.Item, err error) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttribute("memcached.hit", err == nil)
+	if err == nil {
+		s.span.SetAttribute("memcached.value_size", len(i.Value))
+	}
+	s.end(err)
+}
+
+// readMulti ends the span of a GetMulti of keys
+func (s *opSpan) readMulti(keys int, m map[string]*memcache.Item, err error) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttribute("memcached.keys", keys)
+	s.span.SetAttribute("memcached.hits", len(m))
+	size := 0
+	for _, i := range m {
+		size += len(i.Value)
+	}
+	s.span.SetAttribute("memcached.value_size", size)
+	s.end(err)
+}
+
+// write ends the span of a Set
+func (s *opSpan) write(item *memcache.Item, err error) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttribute("memcached.value_size", len(item.Value))
+	s.end(err)
+}
+
+// end ends the span with err unless it's a protocol result
+func (s *opSpan) end(err error) {
+	if s == nil {
+		return
+	}
+	if isProtocolError(err) {
+		err = nil
+	}
+	s.span.End(err)
+}
@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordedSpan struct {
+	name   string
+	parent interface{}
+	attrs  map[string]interface{}
+	ended  bool
+	err    error
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordedSpan) End(err error)                              { s.ended, s.err = true, err }
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type parentKey struct{}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &recordedSpan{name: name, parent: ctx.Value(parentKey{}), attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func TestTracer(t *testing.T) {
+	s, err := newLocalServer(systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.ln.Close()
+	tracer := &recordingTracer{}
+	mc := NewClientWithOptions([]string{s.ln.Addr().String()}, Options{Tracer: tracer, KeySanitizer: KeyPattern})
+
+	mc.SetString("trace:12345", "value")
+	ctx := context.WithValue(context.Background(), parentKey{}, "request")
+	mc.GetCtx(ctx, "trace:12345")
+	mc.Get("trace:missing")
+	mc.GetMulti([]string{"trace:12345", "trace:missing"})
+
+	if len(tracer.spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(tracer.spans))
+	}
+	set, get, miss, multi := tracer.spans[0], tracer.spans[1], tracer.spans[2], tracer.spans[3]
+	if set.name != "memcache.set" || set.attrs["memcached.value_size"] != 5 || set.attrs["db.system"] != "memcached" || !set.ended {
+		t.Errorf("Unexpected set span %+v", set)
+	}
+	if get.parent != "request" || get.attrs["memcached.hit"] != true || get.attrs["memcached.key"] != "trace:*" || get.attrs["server.address"] != "127.0.0.1" {
+		t.Errorf("Expected a hit span under the request with a sanitized key, got %+v", get)
+	}
+	if _, ok := get.attrs["server.port"].(int); !ok {
+		t.Errorf("Expected the server port, got %+v", get.attrs)
+	}
+	if miss.attrs["memcached.hit"] != false || miss.err != nil {
+		t.Errorf("Expected a miss span without error, got %+v", miss)
+	}
+	if multi.name != "memcache.get_multi" || multi.attrs["memcached.keys"] != 2 || multi.attrs["memcached.hits"] != 1 {
+		t.Errorf("Unexpected get_multi span %+v", multi)
+	}
+}
@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrTTLPolicy is returned for writes rejected by a TTLPolicy
+var ErrTTLPolicy = errors.New("memcache: TTL violates policy")
+
+// MetricTTLPolicyViolations counts writes outside a TTLPolicy tagged by prefix
+// and action ("clamped" or "rejected")
+const MetricTTLPolicyViolations = "memcache.ttl_policy.violations"
+
+// TTLPolicy bounds the TTL of items written under a key prefix, e.g. nothing
+// under "session:" may live longer than a day
+type TTLPolicy struct {
+	// Prefix selects the keys the policy applies to; "" matches every key. When
+	// several policies match, the one with the longest prefix applies.
+	Prefix string
+	// Min is the shortest TTL allowed. Zero has no floor.
+	Min time.Duration
+	// Max is the longest TTL allowed. Items without expiration exceed any Max, so
+	// setting Max forbids storing items forever. Zero has no cap.
+	Max time.Duration
+	// Reject fails violating writes with ErrTTLPolicy instead of clamping their TTL
+	Reject bool
+}
+
+// ttlPolicy returns the policy with the longest prefix matching key
+func (c *Client) ttlPolicy(key string) (TTLPolicy, bool) {
+	var best TTLPolicy
+	var found bool
+	for _, p := range c.opts.TTLPolicies {
+		if len(key) >= len(p.Prefix) && key[:len(p.Prefix)] == p.Prefix && (!found || len(p.Prefix) > len(best.Prefix)) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// enforceTTL returns the expiration to write key with under its TTLPolicy.
+// Negative expirations (immediately expired) are left alone.
+func (c *Client) enforceTTL(key string, exp int32) (int32, error) {
+	p, ok := c.ttlPolicy(key)
+	if !ok {
+		return exp, nil
+	}
+	return c.applyTTLPolicy(p, key, exp)
+}
+
+// applyTTLPolicy returns the expiration to write key with under policy p
+func (c *Client) applyTTLPolicy(p TTLPolicy, key string, exp int32) (int32, error) {
+	if exp < 0 {
+		return exp, nil
+	}
+	now := c.now()
+	ttl := expirationTTL(exp, now)
+	var want time.Duration
+	switch {
+	case p.Max > 0 && (exp == 0 || ttl > p.Max):
+		want = p.Max
+	case p.Min > 0 && exp != 0 && ttl < p.Min:
+		want = p.Min
+	default:
+		return exp, nil
+	}
+	tags := map[string]string{"prefix": p.Prefix, "action": "clamped"}
+	if p.Reject {
+		tags["action"] = "rejected"
+		c.opts.Metrics.Count(MetricTTLPolicyViolations, 1, tags)
+		return 0, fmt.Errorf("%w: %s TTL %s outside [%s, %s] for prefix %q", ErrTTLPolicy, key, ttl, p.Min, p.Max, p.Prefix)
+	}
+	c.opts.Metrics.Count(MetricTTLPolicyViolations, 1, tags)
+	return c.expirationFor(int64(ttlSeconds(want))), nil
+}
+
+// withTTLPolicy returns item, or a copy with its expiration clamped by policy
+func (c *Client) withTTLPolicy(item *memcache.Item) (*memcache.Item, error) {
+	if len(c.opts.TTLPolicies) == 0 {
+		return item, nil
+	}
+	exp, err := c.enforceTTL(item.Key, item.Expiration)
+	if err != nil || exp == item.Expiration {
+		return item, err
+	}
+	cp := *item
+	cp.Expiration = exp
+	return &cp, nil
+}
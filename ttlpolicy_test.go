@@ -0,0 +1,70 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestTTLPolicy(t *testing.T) {
+	metrics := &countingMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		Metrics: metrics,
+		TTLPolicies: []TTLPolicy{
+			{Max: 30 * 24 * time.Hour},
+			{Prefix: "session:", Min: time.Minute, Max: 24 * time.Hour},
+			{Prefix: "session:strict:", Max: time.Hour, Reject: true},
+		},
+	})
+	ctx := context.Background()
+	ttl := func(key string) int64 {
+		n, err := mc.remainingTTL(ctx, key)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		return n
+	}
+
+	item := &memcache.Item{Key: "session:long", Value: []byte("v"), Expiration: 7 * 24 * 3600}
+	if err := mc.Set(item); err != nil {
+		t.Fatal(err)
+	}
+	if n := ttl("session:long"); n != 24*3600 {
+		t.Errorf("Expected TTL capped at a day, got %d", n)
+	}
+	if item.Expiration != 7*24*3600 {
+		t.Errorf("Expected the caller's item to be unchanged")
+	}
+	mc.Set(&memcache.Item{Key: "session:short", Value: []byte("v"), Expiration: 5})
+	if n := ttl("session:short"); n != 60 {
+		t.Errorf("Expected TTL raised to a minute, got %d", n)
+	}
+	mc.Set(&memcache.Item{Key: "forever", Value: []byte("v")})
+	if n := ttl("forever"); n != 30*24*3600 {
+		t.Errorf("Expected infinite TTL capped at 30 days, got %d", n)
+	}
+	mc.Set(&memcache.Item{Key: "session:ok", Value: []byte("v"), Expiration: 600})
+	if n := ttl("session:ok"); n != 600 {
+		t.Errorf("Expected TTL within policy unchanged, got %d", n)
+	}
+
+	err := mc.Set(&memcache.Item{Key: "session:strict:x", Value: []byte("v"), Expiration: 7200})
+	if !errors.Is(err, ErrTTLPolicy) {
+		t.Errorf("Expected ErrTTLPolicy, got %v", err)
+	}
+	if _, err := mc.Get("session:strict:x"); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected rejected write not to be stored, got %v", err)
+	}
+	if err := mc.Touch("session:ok", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := ttl("session:ok"); n != 24*3600 {
+		t.Errorf("Expected Touch to be capped, got %d", n)
+	}
+	if got := metrics.get(MetricTTLPolicyViolations); got != 5 {
+		t.Errorf("Expected 5 violations, got %d", got)
+	}
+}
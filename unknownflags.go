@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MetricUnknownFlags counts values read by the typed getters with flag bits
+// pylibmc doesn't set, tagged by the flags value, to find foreign writers on
+// shared clusters
+const MetricUnknownFlags = "memcache.unknown_flags"
+
+// MetricReadFlags counts values read from the servers, tagged by their flags
+// value and namespace, when Options.ReadFlagMetrics is set. A flags value
+// appearing in a namespace shows a new client library or misconfigured service
+// writing there.
+const MetricReadFlags = "memcache.read_flags"
+
+// pylibmcFlags are all the flag bits pylibmc sets
+const pylibmcFlags = FLAG_PICKLE | FLAG_INTEGER | FLAG_LONG | FLAG_ZLIB | FLAG_BOOL
+
+// UnknownFlagsPolicy selects how the typed getters treat values with flag bits
+// pylibmc doesn't set
+type UnknownFlagsPolicy int
+
+const (
+	// UnknownFlagsInvalid fails the value as InvalidType
+	UnknownFlagsInvalid UnknownFlagsPolicy = iota
+	// UnknownFlagsRaw reads the value as if it had no flags, i.e. as raw bytes
+	UnknownFlagsRaw
+	// UnknownFlagsPickle reads the value as a pickle
+	UnknownFlagsPickle
+	// UnknownFlagsError fails the read with an *ErrUnknownFlags
+	UnknownFlagsError
+)
+
+// ErrUnknownFlags is returned for values with unknown flag bits under
+// UnknownFlagsError. It matches InvalidType with errors.Is.
+type ErrUnknownFlags struct {
+	Flags uint32
+}
+
+func (e *ErrUnknownFlags) Error() string {
+	return fmt.Sprintf("memcache: unknown flags %d", e.Flags)
+}
+
+func (e *ErrUnknownFlags) Is(target error) bool { return target == InvalidType }
+
+// unknownFlags applies Options.UnknownFlags to i, counting unknown flag values
+func (c *Client) unknownFlags(i *memcache.Item) (*memcache.Item, error) {
+	if i.Flags&^pylibmcFlags == 0 || i.Flags&^FLAG_ZLIB == FLAG_JSON || c.opts.Pipeline.codecs.match(i.Flags) != nil {
+		return i, nil
+	}
+	c.opts.Metrics.Count(MetricUnknownFlags, 1, map[string]string{"flags": strconv.FormatUint(uint64(i.Flags), 10)})
+	var flags uint32
+	switch c.opts.UnknownFlags {
+	case UnknownFlagsRaw:
+		flags = FLAG_NONE
+	case UnknownFlagsPickle:
+		flags = FLAG_PICKLE
+	case UnknownFlagsError:
+		return nil, &ErrUnknownFlags{Flags: i.Flags}
+	default:
+		return i, nil
+	}
+	cp := *i
+	cp.Flags = flags
+	return &cp, nil
+}
+
+// checksFlags reports whether reads must go through unknownFlags
+func (c *Client) checksFlags() bool {
+	_, nop := c.opts.Metrics.(nopMetrics)
+	return c.opts.UnknownFlags != UnknownFlagsInvalid || !nop
+}
+
+// countFlags counts i's flags under MetricReadFlags when
+// Options.ReadFlagMetrics is set
+func (c *Client) countFlags(i *memcache.Item) {
+	if !c.opts.ReadFlagMetrics {
+		return
+	}
+	namespace := defaultNamespace
+	if c.opts.WriteNamespace != nil {
+		namespace = c.opts.WriteNamespace
+	}
+	c.opts.Metrics.Count(MetricReadFlags, 1, map[string]string{
+		"namespace": namespace(i.Key),
+		"flags":     strconv.FormatUint(uint64(i.Flags), 10),
+	})
+}
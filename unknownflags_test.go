@@ -0,0 +1,103 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestUnknownFlags(t *testing.T) {
+	const foreign = 1 << 9
+	pickled := UnicodeItem("", "p").Value
+	for _, tc := range []struct {
+		policy UnknownFlagsPolicy
+		value  []byte
+		want   string
+		ok     bool
+	}{
+		{UnknownFlagsInvalid, []byte("abc"), "", false},
+		{UnknownFlagsRaw, []byte("abc"), "abc", true},
+		{UnknownFlagsPickle, pickled, "p", true},
+		{UnknownFlagsPickle, []byte("abc"), "", false},
+		{UnknownFlagsError, []byte("abc"), "", false},
+	} {
+		metrics := &countingMetrics{}
+		mc := NewClientWithOptions([]string{LocalAddress}, Options{UnknownFlags: tc.policy, Metrics: metrics})
+		mc.Set(&memcache.Item{Key: "unknown_flags", Value: tc.value, Flags: foreign})
+		if s, ok := mc.GetString("unknown_flags"); s != tc.want || ok != tc.ok {
+			t.Errorf("policy %d: got %q %v", tc.policy, s, ok)
+		}
+		if n := metrics.get(MetricUnknownFlags); n != 1 {
+			t.Errorf("policy %d: expected 1 unknown flags value counted, got %d", tc.policy, n)
+		}
+	}
+
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{UnknownFlags: UnknownFlagsError})
+	mc.Set(&memcache.Item{Key: "unknown_flags", Value: []byte("abc"), Flags: foreign})
+	var s string
+	err := mc.FetchAll(context.Background(), Specs{{Key: "unknown_flags", Dest: &s}})
+	var uf *ErrUnknownFlags
+	if !errors.As(err, &uf) || uf.Flags != foreign || !errors.Is(err, InvalidType) {
+		t.Errorf("Expected ErrUnknownFlags, got %v", err)
+	}
+	mc.SetString("unknown_flags", "known")
+	if s, ok := mc.GetString("unknown_flags"); !ok || s != "known" {
+		t.Errorf("Expected known, got %q %v", s, ok)
+	}
+}
+
+// flagMetrics counts MetricReadFlags by namespace and flags
+type flagMetrics struct {
+	nopMetrics
+	sync.Mutex
+	counts map[[2]string]int64
+}
+
+func (m *flagMetrics) Count(name string, n int64, tags map[string]string) {
+	if name != MetricReadFlags {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[[2]string]int64)
+	}
+	m.counts[[2]string{tags["namespace"], tags["flags"]}] += n
+}
+
+func TestReadFlagMetrics(t *testing.T) {
+	metrics := &flagMetrics{}
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{ReadFlagMetrics: true, Metrics: metrics})
+	mc.SetString("users:1", "ada")
+	mc.Set(&memcache.Item{Key: "users:2", Value: []byte("{}"), Flags: 1 << 9})
+	mc.Set(Int64Item("counts:1", 3))
+	mc.SetSoftTTL(UnicodeItem("users:3", "grace"), time.Minute)
+
+	mc.GetString("users:1")
+	mc.Get("users:2")
+	mc.GetMulti([]string{"users:1", "users:3", "counts:1", "missing:1"})
+	mc.GetMultiCtx(context.Background(), []string{"users:2"})
+
+	want := map[[2]string]int64{
+		{"users", "0"}:   2,
+		{"users", "512"}: 2,
+		{"users", fmt.Sprint(FLAG_SOFT_TTL | FLAG_PICKLE)}: 1,
+		{"counts", fmt.Sprint(FLAG_INTEGER)}:               1,
+	}
+	if !reflect.DeepEqual(metrics.counts, want) {
+		t.Errorf("Expected %v got %v", want, metrics.counts)
+	}
+
+	metrics = &flagMetrics{}
+	mc = NewClientWithOptions([]string{LocalAddress}, Options{Metrics: metrics})
+	mc.Get("users:1")
+	if len(metrics.counts) != 0 {
+		t.Errorf("Expected no counts without ReadFlagMetrics got %v", metrics.counts)
+	}
+}
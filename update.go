@@ -0,0 +1,150 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// UpdateFunc computes the new value and flags of an item from its current ones.
+// Returning an error aborts the update.
+type UpdateFunc func(old []byte, flags uint32) (new []byte, newFlags uint32, err error)
+
+// ErrFlagsStripped is returned when an UpdateFunc returns no flags for a value
+// stored with flags, which would make Python read it back as a plain string.
+// Use Set to deliberately store the value without flags.
+var ErrFlagsStripped = errors.New("memcache: update strips the value's flags")
+
+// keepTTL is the expiration passed to updateInPlace to carry an item's TTL
+// forward
+const keepTTL = math.MinInt32
+
+// UpdateInPlace does a read-modify-write of key with gets/cas, calling fn again
+// with the fresh value whenever another writer wins the race, up to maxRetries
+// retries. fn receives and returns the flags so values written by Python keep
+// their encoding (return flags unchanged to preserve it); returning zero flags
+// for a flagged value fails with ErrFlagsStripped.
+//
+// ErrCacheMiss is returned if key doesn't exist (or is deleted mid update) and
+// ErrCASConflict once retries are exhausted. The item's remaining TTL is read
+// with a meta get (memcached 1.6+), or metadump, on each attempt and carried
+// forward. When it can't be read, e.g. through a proxy without meta commands,
+// the error (such as ErrFeatureUnsupported) is returned and nothing is stored
+// rather than storing the item without expiration.
+func (c *Client) UpdateInPlace(key string, fn UpdateFunc, maxRetries int) error {
+	return c.updateInPlace(key, fn, keepTTL, maxRetries)
+}
+
+// updateInPlace is UpdateInPlace storing the item with expiration, or its
+// current TTL for keepTTL
+func (c *Client) updateInPlace(key string, fn UpdateFunc, expiration int32, maxRetries int) error {
+	for attempt := 0; ; attempt++ {
+		i, err := c.casGet(key)
+		if err != nil {
+			return err
+		}
+		exp := expiration
+		if expiration == keepTTL {
+			// read on each attempt as the writer winning a race may change it
+			if exp, err = c.currentExpiration(key); err != nil {
+				return err
+			}
+		}
+		value, flags, err := fn(i.Value, i.Flags)
+		if err != nil {
+			return err
+		}
+		if flags == 0 && i.Flags != 0 {
+			return ErrFlagsStripped
+		}
+		i.Value, i.Flags, i.Expiration = value, flags, exp
+		err = c.CompareAndSwap(i)
+		if err != memcache.ErrCASConflict || attempt >= maxRetries {
+			return err
+		}
+	}
+}
+
+// casGet gets key for a CompareAndSwap from the server owning it, rather than
+// an in-process copy, a replica or another tier whose CasID may be stale, unset
+// or another server's
+func (c *Client) casGet(key string) (*memcache.Item, error) {
+	return c.getWith(key, callOptions{cas: true})
+}
+
+// currentExpiration returns the Expiration that keeps key's remaining TTL
+func (c *Client) currentExpiration(key string) (int32, error) {
+	ttl, err := c.remainingTTL(context.Background(), key)
+	if err != nil {
+		return 0, err
+	}
+	return c.expirationFor(ttl), nil
+}
+
+// upsert is UpdateInPlace that stores initial pickled instead when key doesn't
+// exist, retrying the update if another writer creates it first
+func (c *Client) upsert(key string, fn UpdateFunc, initial interface{}, maxRetries int) error {
+	return c.upsertExpiring(key, fn, initial, keepTTL, maxRetries)
+}
+
+// upsertExpiring is upsert storing the item with expiration, or carrying its
+// TTL forward for keepTTL
+func (c *Client) upsertExpiring(key string, fn UpdateFunc, initial interface{}, expiration int32, maxRetries int) error {
+	for attempt := 0; ; attempt++ {
+		err := c.updateInPlace(key, fn, expiration, maxRetries)
+		if err != memcache.ErrCacheMiss {
+			return err
+		}
+		value, err := c.pickle(initial)
+		if err != nil {
+			return err
+		}
+		item := &memcache.Item{Key: key, Value: value, Flags: FLAG_PICKLE}
+		if expiration != keepTTL {
+			item.Expiration = expiration
+		}
+		err = c.Add(item)
+		if err != memcache.ErrNotStored || attempt >= maxRetries {
+			return err
+		}
+	}
+}
+
+// setIfRetries is how many times SetIf re-reads a key another writer changed
+const setIfRetries = 10
+
+// SetIf stores item as the value of key (item's Key is ignored) only if pred
+// passes for the current value, e.g. to keep racing workers from overwriting a
+// newer version with an older one. pred is called with nil when key doesn't
+// exist, and called again with the fresh value whenever another writer changes
+// it first, up to 10 times before ErrCASConflict is returned. ErrNotStored is
+// returned when pred fails.
+func (c *Client) SetIf(key string, item *memcache.Item, pred func(old *Item) bool) error {
+	for attempt := 0; ; attempt++ {
+		i, err := c.casGet(key)
+		switch err {
+		case nil:
+			if !pred(&Item{i}) {
+				return memcache.ErrNotStored
+			}
+			i.Value, i.Flags, i.Expiration = item.Value, item.Flags, item.Expiration
+			err = c.CompareAndSwap(i)
+		case memcache.ErrCacheMiss:
+			if !pred(nil) {
+				return memcache.ErrNotStored
+			}
+			err = c.Add(&memcache.Item{Key: key, Value: item.Value, Flags: item.Flags, Expiration: item.Expiration})
+			if err == memcache.ErrNotStored {
+				// created since the get
+				err = memcache.ErrCASConflict
+			}
+		default:
+			return err
+		}
+		if (err != memcache.ErrCASConflict && err != memcache.ErrCacheMiss) || attempt >= setIfRetries {
+			return err
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestUpdateInPlace(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(UnicodeItem("update_s", "python"))
+
+	err := mc.UpdateInPlace("update_s", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		s, err := (&Item{&memcache.Item{Value: old, Flags: flags}}).String()
+		if err != nil {
+			return nil, 0, err
+		}
+		i := UnicodeItem("", s+" updated")
+		return i.Value, i.Flags, nil
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := mc.Get("update_s")
+	if err != nil || i.Flags != FLAG_PICKLE {
+		t.Fatalf("Expected pickle flags preserved, got: %v %v", i, err)
+	}
+	if s, _ := (&Item{i}).String(); s != "python updated" {
+		t.Errorf("unexpected value %q", s)
+	}
+
+	mc.Delete("update_missing")
+	if err := mc.UpdateInPlace("update_missing", nil, 3); err != memcache.ErrCacheMiss {
+		t.Errorf("Expected ErrCacheMiss, got: %v", err)
+	}
+
+	abort := errors.New("abort")
+	err = mc.UpdateInPlace("update_s", func([]byte, uint32) ([]byte, uint32, error) { return nil, 0, abort }, 3)
+	if err != abort {
+		t.Errorf("Expected fn error, got: %v", err)
+	}
+}
+
+func TestUpdateInPlaceConcurrent(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(Int64Item("update_n", 0))
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := mc.UpdateInPlace("update_n", func(old []byte, flags uint32) ([]byte, uint32, error) {
+				n, err := strconv.ParseInt(string(old), 10, 64)
+				return []byte(strconv.FormatInt(n+1, 10)), flags, err
+			}, 100)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, ok := mc.GetInt64("update_n"); !ok || n != 10 {
+		t.Errorf("Expected 10, got: %d %v", n, ok)
+	}
+}
+
+func TestUpdateInPlaceRetriesExhausted(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.Set(StringItem("update_race", "a"))
+	calls := 0
+	err := mc.UpdateInPlace("update_race", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		calls++
+		mc.Set(StringItem("update_race", "interfering"))
+		return []byte("b"), flags, nil
+	}, 2)
+	if err != memcache.ErrCASConflict || calls != 3 {
+		t.Errorf("Expected ErrCASConflict after 3 attempts, got: %v after %d", err, calls)
+	}
+}
+
+func TestUpdateInPlaceRereadsTTL(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Set(StringItem("update_ttl", "a"))
+	calls := 0
+	err := mc.UpdateInPlace("update_ttl", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		if calls++; calls == 1 {
+			// the writer winning the race gives the item a TTL
+			mc.Set(&memcache.Item{Key: "update_ttl", Value: []byte("b"), Expiration: 300})
+		}
+		return append(old, 'c'), flags, nil
+	}, 1)
+	if err != nil || calls != 2 {
+		t.Fatalf("Expected the update to succeed on the retry, got %v after %d", err, calls)
+	}
+	if ttl, err := mc.remainingTTL(context.Background(), "update_ttl"); err != nil || ttl < 290 || ttl > 300 {
+		t.Errorf("Expected the TTL set by the other writer, got %d %v", ttl, err)
+	}
+}
+
+// noMetaServer answers gets with the value "a" and cas with STORED, counting
+// the cas commands, and every other command, such as mg, with ERROR
+func noMetaServer(t *testing.T) (string, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var stores int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch f := strings.Fields(line); f[0] {
+					case "gets":
+						fmt.Fprintf(c, "VALUE %s 0 1 7\r\na\r\nEND\r\n", f[1])
+					case "cas":
+						r.ReadString('\n')
+						atomic.AddInt32(&stores, 1)
+						io.WriteString(c, "STORED\r\n")
+					default:
+						io.WriteString(c, "ERROR\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &stores
+}
+
+func TestUpdateInPlaceTTLUnreadable(t *testing.T) {
+	addr, stores := noMetaServer(t)
+	mc := NewClient([]string{addr})
+	err := mc.UpdateInPlace("update_nometa", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		return []byte("b"), flags, nil
+	}, 1)
+	if err == nil {
+		t.Error("Expected an error reading the TTL without meta commands or metadump")
+	}
+	if n := atomic.LoadInt32(stores); n != 0 {
+		t.Errorf("Expected nothing stored without the TTL, got %d stores", n)
+	}
+	// an explicit expiration doesn't need the TTL
+	if err := mc.SetIf("update_nometa", StringItem("", "b"), func(*Item) bool { return true }); err != nil {
+		t.Errorf("Expected SetIf to store, got %v", err)
+	}
+}
+
+// TestRewritesPreserveFlagsAndTTL checks no operation rewriting an existing
+// value drops its python-compat flags or its TTL
+func TestRewritesPreserveFlagsAndTTL(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	ctx := context.Background()
+	check := func(name, key string, flags uint32) {
+		t.Helper()
+		i, err := mc.Get(key)
+		if err != nil || i.Flags != flags {
+			t.Errorf("%s: expected flags %d, got %v %v", name, flags, i, err)
+		}
+		if ttl, err := mc.remainingTTL(ctx, key); err != nil || ttl < 290 || ttl > 300 {
+			t.Errorf("%s: expected TTL to be preserved, got %d %v", name, ttl, err)
+		}
+	}
+	set := func(i *memcache.Item) *memcache.Item {
+		i.Expiration = 300
+		mc.Set(i)
+		return i
+	}
+
+	set(UnicodeItem("preserve_update", "a"))
+	err := mc.UpdateInPlace("preserve_update", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		return UnicodeItem("", "b").Value, flags, nil
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("UpdateInPlace", "preserve_update", FLAG_PICKLE)
+	err = mc.UpdateInPlace("preserve_update", func(old []byte, flags uint32) ([]byte, uint32, error) {
+		return []byte("c"), 0, nil
+	}, 3)
+	if err != ErrFlagsStripped {
+		t.Errorf("Expected ErrFlagsStripped, got %v", err)
+	}
+	if s, _ := mc.GetString("preserve_update"); s != "b" {
+		t.Errorf("Expected the value to be left alone, got %q", s)
+	}
+
+	mc.Delete("preserve_events")
+	mc.PushEvent("preserve_events", "a", 0)
+	mc.Touch("preserve_events", 300)
+	mc.PushEvent("preserve_events", "b", 0)
+	check("PushEvent", "preserve_events", FLAG_PICKLE)
+
+	set(Int64Item("preserve_float", 1))
+	if _, err := mc.IncrFloat("preserve_float", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	check("IncrFloat", "preserve_float", FLAG_PICKLE)
+
+	set(Int64Item("preserve_touch", 1))
+	mc.Touch("preserve_touch", 300)
+	check("Touch", "preserve_touch", FLAG_INTEGER)
+
+	set(&memcache.Item{Key: "preserve_append", Value: []byte("1"), Flags: FLAG_INTEGER})
+	mc.Append(&memcache.Item{Key: "preserve_append", Value: []byte("2")})
+	check("Append", "preserve_append", FLAG_INTEGER)
+	if n, ok := mc.GetInt64("preserve_append"); !ok || n != 12 {
+		t.Errorf("Expected 12, got %d %v", n, ok)
+	}
+
+	set(&memcache.Item{Key: "preserve_migrate", Value: []byte("abc"), Flags: 1 << 4})
+	m := MigrationSerde{Primary: Scheme{Flags: PylibmcFlags}, Fallback: Scheme{Flags: PythonMemcachedFlags}, Rewrite: true}
+	mc.GetMigrating(ctx, "preserve_migrate", m)
+	check("GetMigrating", "preserve_migrate", FLAG_NONE)
+}
+
+func TestSetIf(t *testing.T) {
+	mc := NewClient([]string{LocalAddress})
+	mc.Delete("setif_version")
+	newer := func(v int64) func(*Item) bool {
+		return func(old *Item) bool {
+			if old == nil {
+				return true
+			}
+			n, err := old.Int64()
+			return err == nil && n < v
+		}
+	}
+	if err := mc.SetIf("setif_version", Int64Item("", 2), newer(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.SetIf("setif_version", Int64Item("", 1), newer(1)); err != memcache.ErrNotStored {
+		t.Errorf("Expected ErrNotStored for an older version, got: %v", err)
+	}
+	if n, ok := mc.GetInt64("setif_version"); !ok || n != 2 {
+		t.Errorf("Expected 2, got: %d %v", n, ok)
+	}
+
+	// racing workers storing versions out of order leave the newest
+	var wg sync.WaitGroup
+	for v := int64(3); v <= 12; v++ {
+		wg.Add(1)
+		go func(v int64) {
+			defer wg.Done()
+			if err := mc.SetIf("setif_version", Int64Item("", v), newer(v)); err != nil && err != memcache.ErrNotStored {
+				t.Error(err)
+			}
+		}(v)
+	}
+	wg.Wait()
+	if n, ok := mc.GetInt64("setif_version"); !ok || n != 12 {
+		t.Errorf("Expected 12, got: %d %v", n, ok)
+	}
+
+	mc.Delete("setif_missing")
+	if err := mc.SetIf("setif_missing", Int64Item("", 1), func(old *Item) bool { return old != nil }); err != memcache.ErrNotStored {
+		t.Errorf("Expected ErrNotStored, got: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Warm establishes connsPerServer connections to every server and validates each
+// with a round trip so they are pooled before the first request. MaxIdleConns
+// should be at least connsPerServer or the extra connections are closed again.
+// The returned error joins the failures for each server.
+func (c *Client) Warm(ctx context.Context, connsPerServer int) error {
+	keys, err := c.serverProbeKeys()
+	if err != nil {
+		return err
+	}
+	results := make(chan error, len(keys)*connsPerServer)
+	for addr, key := range keys {
+		for i := 0; i < connsPerServer; i++ {
+			go func(addr, key string) {
+				_, err := c.Client.Get(key)
+				if err != nil && err != memcache.ErrCacheMiss {
+					results <- fmt.Errorf("memcache: warming %s: %w", addr, err)
+					return
+				}
+				results <- nil
+			}(addr, key)
+		}
+	}
+	var errs []error
+	for i := 0; i < len(keys)*connsPerServer; i++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serverProbeKeys returns a key owned by each server, keyed by server address,
+// for operations that need to reach a specific server through the selector
+func (c *Client) serverProbeKeys() (map[string]string, error) {
+	var servers []string
+	err := c.selector.Each(func(addr net.Addr) error {
+		servers = append(servers, addr.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string, len(servers))
+	for i := 0; len(keys) < len(servers) && i < 1000*len(servers); i++ {
+		key := "memcache_pycompat_probe_" + strconv.Itoa(i)
+		addr, err := c.selector.PickServer(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := keys[addr.String()]; !ok {
+			keys[addr.String()] = key
+		}
+	}
+	if len(keys) < len(servers) {
+		return nil, errors.New("memcache: unable to find a probe key for every server")
+	}
+	return keys, nil
+}
@@ -0,0 +1,35 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWarm(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+	mc.MaxIdleConns = 4
+	if err := mc.Warm(context.Background(), 4); err != nil {
+		t.Errorf("Expected warm to succeed, got: %v", err)
+	}
+
+	mc = NewClient([]string{closedAddr(t)})
+	if err := mc.Warm(context.Background(), 2); err == nil {
+		t.Errorf("Expected warm to fail for unreachable server")
+	}
+}
+
+func TestServerProbeKeys(t *testing.T) {
+	mc := NewClient([]string{"a:11211", "b:11211", "c:11211"})
+	keys, err := mc.serverProbeKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, key := range keys {
+		if picked, _ := mc.selector.PickServer(key); picked.String() != addr {
+			t.Errorf("probe key %q maps to %s not %s", key, picked, addr)
+		}
+	}
+	if len(keys) != 3 {
+		t.Errorf("Expected a probe key per server, got: %v", keys)
+	}
+}
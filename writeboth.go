@@ -0,0 +1,58 @@
+package memcache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// WriteOrder selects the sequence of cache and durable store operations used by WriteBoth
+type WriteOrder int
+
+const (
+	// InvalidateWriteSet deletes the cached key, writes the store, then sets the cache.
+	// Readers racing the write see a miss rather than the old value.
+	InvalidateWriteSet WriteOrder = iota
+	// WriteSet writes the store and then sets the cache
+	WriteSet
+	// WriteInvalidate writes the store and then deletes the cached key so the next
+	// read repopulates it from the store
+	WriteInvalidate
+)
+
+// WriteBoth writes a value to the durable store (via store) and to the cache
+// following the cache-aside discipline selected by order.
+//
+// If store returns an error the cached key is invalidated (so readers fall back
+// to the store) and the store error is returned. An error returned from a cache
+// operation after store succeeded means the durable write has been committed but
+// the cache may be missing the new value.
+func (c *Client) WriteBoth(item *memcache.Item, order WriteOrder, store func() error) error {
+	if order == InvalidateWriteSet {
+		if err := c.invalidate(item.Key); err != nil {
+			return err
+		}
+	}
+	if err := store(); err != nil {
+		c.invalidate(item.Key)
+		return err
+	}
+	switch order {
+	case WriteInvalidate:
+		return c.invalidate(item.Key)
+	default:
+		if err := c.Set(item); err != nil {
+			// don't leave the previous value behind when the new one couldn't be cached
+			c.invalidate(item.Key)
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidate deletes k treating an already absent key as success
+func (c *Client) invalidate(k string) error {
+	err := c.Delete(k)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
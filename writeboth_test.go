@@ -0,0 +1,40 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWriteBoth(t *testing.T) {
+	mc := NewClient([]string{"127.0.0.1:11211"})
+
+	var stored string
+	err := mc.WriteBoth(StringItem("writeboth", "v1"), InvalidateWriteSet, func() error {
+		stored = "v1"
+		return nil
+	})
+	if err != nil || stored != "v1" {
+		t.Fatalf("unexpected error %v stored:%q", err, stored)
+	}
+	if v, ok := mc.GetString("writeboth"); !ok || v != "v1" {
+		t.Errorf("Expected v1, got: %v", v)
+	}
+
+	storeErr := errors.New("store failed")
+	err = mc.WriteBoth(StringItem("writeboth", "v2"), WriteSet, func() error { return storeErr })
+	if err != storeErr {
+		t.Errorf("Expected store error, got: %v", err)
+	}
+	if v, ok := mc.GetString("writeboth"); ok {
+		t.Errorf("Expected key to be invalidated, got: %v", v)
+	}
+
+	mc.Set(StringItem("writeboth", "v3"))
+	err = mc.WriteBoth(StringItem("writeboth", "v4"), WriteInvalidate, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := mc.GetString("writeboth"); ok {
+		t.Errorf("Expected miss after WriteInvalidate, got: %v", v)
+	}
+}
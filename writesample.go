@@ -0,0 +1,147 @@
+package memcache
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// WriteRecord describes a sampled write for capacity planning
+type WriteRecord struct {
+	At  time.Time `json:"at"`
+	Op  string    `json:"op"`
+	Key string    `json:"key"`
+	// Type is the value type from its flags: "str", "pickle", "int", "long",
+	// "bool" or "unknown"
+	Type       string `json:"type"`
+	Compressed bool   `json:"compressed,omitempty"`
+	Size       int    `json:"size"`
+	Namespace  string `json:"namespace"`
+	// TTL is the time to live the item was written with; zero means no expiration
+	TTL time.Duration `json:"ttl"`
+	// Value is only set when Options.SampleWriteValues is set
+	Value []byte `json:"value,omitempty"`
+}
+
+// writeSampler passes a fraction of successful writes to a sink
+type writeSampler struct {
+	sink      func(WriteRecord)
+	rate      float64
+	values    bool
+	namespace func(key string) string
+	sanitize  func(key string) string
+	rand      func() float64
+}
+
+func newWriteSampler(opts Options) *writeSampler {
+	s := &writeSampler{
+		sink:      opts.OnWrite,
+		rate:      opts.WriteSampleRate,
+		values:    opts.SampleWriteValues,
+		namespace: opts.WriteNamespace,
+		sanitize:  opts.KeySanitizer,
+		rand:      rand.Float64,
+	}
+	if s.namespace == nil {
+		s.namespace = defaultNamespace
+	}
+	return s
+}
+
+// defaultNamespace is the part of key before the first ':', or "" if there's none
+func defaultNamespace(key string) string {
+	if ns, _, ok := strings.Cut(key, ":"); ok {
+		return ns
+	}
+	return ""
+}
+
+func (s *writeSampler) sample(op string, item *memcache.Item, now time.Time) {
+	if s.rate < 1 && s.rand() >= s.rate {
+		return
+	}
+	r := WriteRecord{
+		At:         now,
+		Op:         op,
+		Key:        item.Key,
+		Type:       flagType(item.Flags &^ FLAG_ZLIB),
+		Compressed: item.Flags&FLAG_ZLIB != 0,
+		Size:       len(item.Value),
+		Namespace:  s.namespace(item.Key),
+		TTL:        expirationTTL(item.Expiration, now),
+	}
+	if s.sanitize != nil {
+		r.Key = s.sanitize(r.Key)
+	}
+	if s.values {
+		r.Value = append([]byte(nil), item.Value...)
+	}
+	s.sink(r)
+}
+
+// flagType names the value type for pylibmc flags
+func flagType(flags uint32) string {
+	switch flags {
+	case FLAG_NONE:
+		return "str"
+	case FLAG_PICKLE:
+		return "pickle"
+	case FLAG_INTEGER:
+		return "int"
+	case FLAG_LONG:
+		return "long"
+	case FLAG_BOOL:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// expirationTTL converts an item Expiration (relative seconds up to 30 days,
+// else a unix timestamp) to a time to live
+func expirationTTL(exp int32, now time.Time) time.Duration {
+	switch {
+	case exp <= 0:
+		return 0
+	case exp > 60*60*24*30:
+		return time.Unix(int64(exp), 0).Sub(now)
+	}
+	return time.Duration(exp) * time.Second
+}
+
+// write runs fn as the item write op, applying Options.ExpirationJitter and
+// any TTLPolicy (append and prepend leave the expiration unchanged) and
+// sampling it once it succeeds
+func (c *Client) write(op string, item *memcache.Item, fn func(*memcache.Item) error) error {
+	return c.writeCtx(context.Background(), op, item, fn)
+}
+
+// writeCtx is write attributing the operation to ctx's trace
+func (c *Client) writeCtx(ctx context.Context, op string, item *memcache.Item, fn func(*memcache.Item) error) error {
+	if op != OpAppend && op != OpPrepend {
+		var err error
+		if item, err = c.withTTLPolicy(c.withJitter(item)); err != nil {
+			return err
+		}
+	}
+	if c.opts.DryRun {
+		return c.dryRun(op, item.Key, item)
+	}
+	if op == OpSet && c.opts.ChunkSize > 0 {
+		fn = c.chunkSet(fn)
+	}
+	if op == OpSet && c.opts.Overflow != nil {
+		fn = c.overflowSet(fn)
+	}
+	err := c.doCtx(ctx, op, item.Key, func() error { return fn(item) })
+	c.localWrite(op, item, err)
+	if err == nil {
+		c.recordBytes(op, item.Key, "written", len(item.Value))
+	}
+	if err == nil && c.writes != nil {
+		c.writes.sample(op, item, c.now())
+	}
+	return err
+}
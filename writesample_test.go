@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestWriteSampling(t *testing.T) {
+	var mu sync.Mutex
+	var records []WriteRecord
+	now := time.Unix(1700000000, 0)
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		Clock:           NewFakeClock(now),
+		WriteSampleRate: 1,
+		OnWrite: func(r WriteRecord) {
+			mu.Lock()
+			records = append(records, r)
+			mu.Unlock()
+		},
+	})
+	mc.Set(&memcache.Item{Key: "session:abc", Value: []byte("hello"), Expiration: 60})
+	mc.Set(Int64Item("counter", 5))
+	mc.Add(&memcache.Item{Key: "session:abc", Value: []byte("not stored")})
+	mc.Set(&memcache.Item{Key: "user:1", Value: []byte("x"), Flags: FLAG_PICKLE | FLAG_ZLIB, Expiration: int32(now.Unix()) + 3600})
+	mc.Get("session:abc")
+
+	want := []WriteRecord{
+		{At: now, Op: OpSet, Key: "session:abc", Type: "str", Size: 5, Namespace: "session", TTL: time.Minute},
+		{At: now, Op: OpSet, Key: "counter", Type: "int", Size: 1},
+		{At: now, Op: OpSet, Key: "user:1", Type: "pickle", Compressed: true, Size: 1, Namespace: "user", TTL: time.Hour},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got: %+v", len(want), records)
+	}
+	for n, r := range records {
+		if r.Value != nil {
+			t.Errorf("Expected no value by default, got %q", r.Value)
+		}
+		if !reflect.DeepEqual(r, want[n]) {
+			t.Errorf("record %d: got %+v, want %+v", n, r, want[n])
+		}
+	}
+}
+
+func TestWriteSamplingRate(t *testing.T) {
+	var got []WriteRecord
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{
+		WriteSampleRate:   0.5,
+		SampleWriteValues: true,
+		WriteNamespace:    func(string) string { return "ns" },
+		OnWrite:           func(r WriteRecord) { got = append(got, r) },
+	})
+	rolls := []float64{0.1, 0.9, 0.4}
+	mc.writes.rand = func() float64 {
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}
+	for _, k := range []string{"rate_a", "rate_b", "rate_c"} {
+		mc.Set(&memcache.Item{Key: k, Value: []byte(k)})
+	}
+	if len(got) != 2 || got[0].Key != "rate_a" || got[1].Key != "rate_c" {
+		t.Fatalf("Expected rate_a and rate_c sampled, got: %+v", got)
+	}
+	if string(got[1].Value) != "rate_c" || got[1].Namespace != "ns" {
+		t.Errorf("Expected value and custom namespace, got: %+v", got[1])
+	}
+
+	if NewClientWithOptions([]string{LocalAddress}, Options{OnWrite: func(WriteRecord) {}}).writes != nil {
+		t.Errorf("Expected sampling disabled without a rate")
+	}
+}
@@ -0,0 +1,95 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+// ZlibStage is the Pipeline Compress stage compressing values the way pylibmc
+// does with min_compress_len: values of at least MinCompressLen bytes are zlib
+// compressed and marked with FLAG_ZLIB, unless compression doesn't shrink them.
+// Decode inflates values with FLAG_ZLIB set, whoever wrote them.
+type ZlibStage struct {
+	// MinCompressLen is the smallest value compressed. Zero compresses every value.
+	MinCompressLen int
+	// Level is the zlib compression level. Zero uses zlib.DefaultCompression,
+	// as pylibmc does.
+	Level int
+}
+
+// Encode compresses value if it is long enough
+func (z ZlibStage) Encode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if len(value) < z.MinCompressLen || flags&FLAG_ZLIB != 0 {
+		return value, flags, nil
+	}
+	level := z.Level
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	var b bytes.Buffer
+	w, err := zlib.NewWriterLevel(&b, level)
+	if err != nil {
+		return nil, 0, err
+	}
+	w.Write(value)
+	if err := w.Close(); err != nil {
+		return nil, 0, err
+	}
+	if b.Len() >= len(value) {
+		return value, flags, nil
+	}
+	return b.Bytes(), flags | FLAG_ZLIB, nil
+}
+
+// Decode inflates values with FLAG_ZLIB set
+func (ZlibStage) Decode(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&FLAG_ZLIB == 0 {
+		return value, flags, nil
+	}
+	b, err := inflate(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, flags &^ FLAG_ZLIB, nil
+}
+
+// inflate decompresses zlib data
+func inflate(value []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// inflatePickle undoes FLAG_ZLIB, returning values with the pickle preamble
+// stored without flags as FLAG_PICKLE
+func inflatePickle(value []byte, flags uint32) ([]byte, uint32, error) {
+	if flags&FLAG_ZLIB != 0 {
+		var err error
+		if value, err = inflate(value); err != nil {
+			return nil, 0, err
+		}
+		flags &^= FLAG_ZLIB
+	}
+	if flags == FLAG_NONE && looksPickled(value) {
+		flags = FLAG_PICKLE
+	}
+	return value, flags, nil
+}
+
+// inflated returns i with its value decompressed if FLAG_ZLIB is set
+func (i *Item) inflated() (*Item, error) {
+	if i.Flags&FLAG_ZLIB == 0 {
+		return i, nil
+	}
+	b, err := inflate(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	cp := *i.Item
+	cp.Value, cp.Flags = b, i.Flags&^FLAG_ZLIB
+	return &Item{&cp}, nil
+}
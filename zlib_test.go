@@ -0,0 +1,81 @@
+package memcache
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestItemZlib(t *testing.T) {
+	// zlib.compress(b'hello '*20)
+	i := &Item{&memcache.Item{Value: mustHex(t, "789ccb48cdc9c957c8a03b090077ba2c11"), Flags: FLAG_ZLIB}}
+	if s, err := i.String(); err != nil || s != strings.Repeat("hello ", 20) {
+		t.Errorf("unexpected string %q %v", s, err)
+	}
+	// zlib.compress(b'12345')
+	i = &Item{&memcache.Item{Value: mustHex(t, "789c3334323631050002f80100"), Flags: FLAG_INTEGER | FLAG_ZLIB}}
+	if n, err := i.Int64(); err != nil || n != 12345 {
+		t.Errorf("unexpected int %d %v", n, err)
+	}
+	// zlib.compress(pickle.dumps(u'café '*20, 2))
+	i = &Item{&memcache.Item{Value: mustHex(t, "789c6b608aa8606060484e4c3bbc5281fe6421831e002047382a"), Flags: FLAG_PICKLE | FLAG_ZLIB}}
+	if s, err := i.String(); err != nil || s != strings.Repeat("café ", 20) {
+		t.Errorf("unexpected string %q %v", s, err)
+	}
+	if v, err := Deserialize(i.Value, i.Flags); err != nil || v != strings.Repeat("café ", 20) {
+		t.Errorf("unexpected value %v %v", v, err)
+	}
+	i = &Item{&memcache.Item{Value: []byte("not zlib"), Flags: FLAG_ZLIB}}
+	if _, err := i.String(); err == nil {
+		t.Errorf("Expected error for corrupt compressed value")
+	}
+}
+
+func TestZlibStage(t *testing.T) {
+	z := ZlibStage{MinCompressLen: 10}
+	for _, v := range []string{"short", strings.Repeat("x", 9) + "y", strings.Repeat("abc", 100)} {
+		b, flags, err := z.Encode([]byte(v), FLAG_PICKLE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := flags&FLAG_ZLIB != 0
+		if compressed != (len(v) == 300) {
+			t.Errorf("%q: unexpected compression %v", v, compressed)
+		}
+		d, dflags, err := z.Decode(b, flags)
+		if err != nil || string(d) != v || dflags != FLAG_PICKLE {
+			t.Errorf("%q: round trip got %q %d %v", v, d, dflags, err)
+		}
+	}
+}
+
+func TestClientZlib(t *testing.T) {
+	mc := NewClientWithOptions([]string{LocalAddress}, Options{MinCompressLen: 100})
+	long := strings.Repeat("hello ", 100)
+	mc.SetString("zlib_long", long)
+	mc.SetString("zlib_short", "hello")
+	if i, _ := mc.Get("zlib_long"); i.Flags != FLAG_ZLIB || len(i.Value) >= len(long) {
+		t.Errorf("Expected a compressed value, got flags %d len %d", i.Flags, len(i.Value))
+	}
+	if i, _ := mc.Get("zlib_short"); i.Flags != FLAG_NONE {
+		t.Errorf("Expected an uncompressed value, got flags %d", i.Flags)
+	}
+	if s, ok := mc.GetString("zlib_long"); !ok || s != long {
+		t.Errorf("unexpected string %q %v", s, ok)
+	}
+	// values compressed by Python are read without compression enabled
+	plain := NewClient([]string{LocalAddress})
+	if s, ok := plain.GetString("zlib_long"); !ok || s != long {
+		t.Errorf("unexpected string %q %v", s, ok)
+	}
+}